@@ -0,0 +1,29 @@
+// +build with_ble
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+)
+
+func init() {
+	buildTags = append(buildTags, "with_ble")
+}
+
+// bleWake performs the writes of the wake sequence on the camera with the given Bluetooth address using gatttool,
+// which is part of BlueZ. The camera must have been paired with the host before.
+func bleWake(addr string, writes []bleWrite) error {
+	for _, w := range writes {
+		out, err := exec.Command(
+			"gatttool", "-b", addr, "--char-write-req", "-a", fmt.Sprintf("0x%04x", w.handle), "-n", hex.EncodeToString(w.value),
+		).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("bluetooth write to handle 0x%04x: %w: %s", w.handle, err, bytes.TrimSpace(out))
+		}
+	}
+
+	return nil
+}
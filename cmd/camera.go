@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-ini/ini"
+	"github.com/malc0mn/ptp-ip/ip"
+	"log"
+	"sort"
+	"strings"
+)
+
+// cameraSectionPrefix is the prefix of the config file sections defining a named camera, e.g. [camera.xt1].
+const cameraSectionPrefix = "camera."
+
+// camera is a named camera profile as defined in the config file. Profiles are selected using the -camera flag.
+type camera struct {
+	name   string
+	vendor string
+	host   string
+	port   uint16Value
+	cport  uint16Value
+	eport  uint16Value
+	sport  uint16Value
+	fname  string
+	guid   string
+
+	bleAddr      string
+	bleWake      string
+	wifiSSID     string
+	wifiPassword string
+
+	// srvPort, webPort and grpcPort are required when loading several cameras in server mode, each camera needing its
+	// own ports.
+	srvPort  uint16Value
+	webPort  uint16Value
+	grpcPort uint16Value
+}
+
+// defaultCamera returns the camera as configured by the command line flags and the [initiator], [responder] and
+// [server] config file sections.
+func defaultCamera() *camera {
+	return &camera{
+		vendor:       conf.vendor,
+		host:         conf.host,
+		port:         conf.port,
+		cport:        conf.cport,
+		eport:        conf.eport,
+		sport:        conf.sport,
+		fname:        conf.fname,
+		guid:         conf.guid,
+		bleAddr:      conf.bleAddr,
+		bleWake:      conf.bleWake,
+		wifiSSID:     conf.wifiSSID,
+		wifiPassword: conf.wifiPassword,
+		srvPort:      conf.srvPort,
+		webPort:      conf.webPort,
+		grpcPort:     conf.grpcPort,
+	}
+}
+
+// loadCamera reads a camera profile from the given config file section. Settings missing from the profile are taken
+// from the defaults.
+func loadCamera(name string, s *ini.Section) *camera {
+	cam := &camera{
+		name:   name,
+		vendor: ip.DefaultVendor,
+		host:   ip.DefaultIpAddress,
+		port:   uint16Value(ip.DefaultPort),
+	}
+
+	for key, v := range map[string]*string{"vendor": &cam.vendor, "host": &cam.host, "friendly_name": &cam.fname, "guid": &cam.guid,
+		"ble_address": &cam.bleAddr, "ble_wake": &cam.bleWake,
+		"wifi_ssid": &cam.wifiSSID, "wifi_password": &cam.wifiPassword} {
+		if k, err := s.GetKey(key); err == nil {
+			*v = k.String()
+		}
+	}
+
+	for key, p := range map[string]*uint16Value{
+		"port":          &cam.port,
+		"cmd_data_port": &cam.cport,
+		"event_port":    &cam.eport,
+		"stream_port":   &cam.sport,
+		"server_port":   &cam.srvPort,
+		"web_port":      &cam.webPort,
+		"grpc_port":     &cam.grpcPort,
+	} {
+		if k, err := s.GetKey(key); err == nil {
+			if err := p.Set(k.String()); err != nil {
+				log.Fatal(valueOutOfRange)
+			}
+		}
+	}
+
+	if cam.cport != 0 && cam.eport != 0 {
+		cam.port = 0
+	}
+	if cam.port != 0 && (cam.cport != 0 || cam.eport != 0) {
+		log.Fatalf("camera %s: %s", name, portSpecAmbiguous)
+	}
+
+	return cam
+}
+
+// use applies the camera profile to the global config so that the camera is used as if it had been configured using
+// the command line flags. The server ports are only changed when set in the profile.
+func (cam *camera) use() {
+	conf.vendor = cam.vendor
+	conf.host = cam.host
+	conf.port = cam.port
+	conf.cport = cam.cport
+	conf.eport = cam.eport
+	conf.sport = cam.sport
+	conf.fname = cam.fname
+	conf.guid = cam.guid
+	conf.bleAddr = cam.bleAddr
+	conf.bleWake = cam.bleWake
+	conf.wifiSSID = cam.wifiSSID
+	conf.wifiPassword = cam.wifiPassword
+	if cam.srvPort != 0 {
+		conf.srvPort = cam.srvPort
+	}
+	if cam.webPort != 0 {
+		conf.webPort = cam.webPort
+	}
+	if cam.grpcPort != 0 {
+		conf.grpcPort = cam.grpcPort
+	}
+}
+
+// newClient creates a client for the camera and connects to it.
+func (cam *camera) newClient() (*ip.Client, error) {
+	c, err := ip.NewClient(cam.vendor, cam.host, uint16(cam.port), cam.fname, cam.guid, verbosity)
+	if err != nil {
+		return nil, err
+	}
+	c.SetLogger(clientLogger)
+	if debugCommands {
+		c.EnableRawPackets()
+	}
+
+	if cam.cport != 0 {
+		c.SetCommandDataPort(uint16(cam.cport))
+	}
+	if cam.eport != 0 {
+		c.SetEventPort(uint16(cam.eport))
+	}
+	if cam.sport != 0 {
+		c.SetStreamerPort(uint16(cam.sport))
+	}
+
+	return c, nil
+}
+
+// configValues returns the settings of the camera in the format used by dumpConfig. Ports that have not been set are
+// left out.
+func (cam *camera) configValues() []configValue {
+	vals := []configValue{
+		{key: "vendor", value: cam.vendor, quote: true},
+		{key: "host", value: cam.host, quote: true},
+	}
+	vals = append(vals, portValue("port", cam.port)...)
+	vals = append(vals, portValue("cmd_data_port", cam.cport)...)
+	vals = append(vals, portValue("event_port", cam.eport)...)
+	vals = append(vals, portValue("stream_port", cam.sport)...)
+	vals = append(vals, configValue{key: "friendly_name", value: cam.fname, quote: true})
+	vals = append(vals, configValue{key: "guid", value: cam.guid, quote: true})
+	vals = append(vals, bleValues(cam.bleAddr, cam.bleWake)...)
+	vals = append(vals, wifiValues(cam.wifiSSID, cam.wifiPassword)...)
+	vals = append(vals, portValue("server_port", cam.srvPort)...)
+	vals = append(vals, portValue("web_port", cam.webPort)...)
+	vals = append(vals, portValue("grpc_port", cam.grpcPort)...)
+
+	return vals
+}
+
+// cameraNames returns the names of all camera profiles, sorted alphabetically.
+func cameraNames() []string {
+	names := make([]string, 0, len(conf.cameras))
+	for name := range conf.cameras {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// selectCameras returns the camera profiles for the comma separated list of names. Loading several cameras at once is
+// only possible in server mode, in which case each camera must have its own server port.
+func selectCameras(names string) ([]*camera, error) {
+	if names == "" {
+		return nil, nil
+	}
+
+	var (
+		cams  []*camera
+		ports = make(map[uint16Value]string)
+	)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		cam, ok := conf.cameras[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown camera %s, must be one of %s", name, strings.Join(cameraNames(), ", "))
+		}
+		cams = append(cams, cam)
+	}
+
+	if len(cams) == 1 {
+		return cams, nil
+	}
+
+	for _, cam := range cams {
+		if cam.srvPort == 0 {
+			return nil, fmt.Errorf("camera %s has no server_port: it is required when loading several cameras", cam.name)
+		}
+		for _, p := range []uint16Value{cam.srvPort, cam.webPort, cam.grpcPort} {
+			if p == 0 {
+				continue
+			}
+			if other, dup := ports[p]; dup {
+				return nil, fmt.Errorf("cameras %s and %s both use port %d", other, cam.name, p)
+			}
+			ports[p] = cam.name
+		}
+	}
+
+	return cams, nil
+}
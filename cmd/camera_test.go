@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"github.com/malc0mn/ptp-ip/ip"
+	"testing"
+)
+
+func resetConf() {
+	conf = &config{
+		vendor:  ip.DefaultVendor,
+		host:    ip.DefaultIpAddress,
+		port:    uint16Value(ip.DefaultPort),
+		srvAddr: defaultIp,
+		srvPort: uint16Value(ip.DefaultPort),
+	}
+}
+
+func TestLoadConfigCameras(t *testing.T) {
+	for _, f := range []string{"testdata/test_cameras.conf", "testdata/test_cameras.yaml"} {
+		resetConf()
+		file = f
+		loadConfig()
+
+		if len(conf.cameras) != 2 {
+			t.Fatalf("loadConfig() %s cameras = %d; want 2", f, len(conf.cameras))
+		}
+
+		xt1 := conf.cameras["xt1"]
+		if xt1.vendor != "fuji" || xt1.host != "192.168.0.10" || xt1.fname != "Golang X-T1 client" {
+			t.Errorf("loadConfig() %s xt1 = %s %s %s; want fuji 192.168.0.10 Golang X-T1 client", f, xt1.vendor, xt1.host, xt1.fname)
+		}
+		if xt1.port != 0 || xt1.cport != 55740 || xt1.eport != 55741 || xt1.sport != 55742 {
+			t.Errorf("loadConfig() %s xt1 ports = %d, %d, %d, %d; want 0, 55740, 55741, 55742", f, xt1.port, xt1.cport, xt1.eport, xt1.sport)
+		}
+		if xt1.srvPort != 25740 || xt1.webPort != 25741 || xt1.grpcPort != 25742 {
+			t.Errorf("loadConfig() %s xt1 server ports = %d, %d, %d; want 25740, 25741, 25742", f, xt1.srvPort, xt1.webPort, xt1.grpcPort)
+		}
+
+		d750 := conf.cameras["d750"]
+		if d750.vendor != "nikon" || d750.port != uint16Value(ip.DefaultPort) || d750.srvPort != 25750 {
+			t.Errorf("loadConfig() %s d750 = %s port %d server port %d; want nikon port %d server port 25750", f, d750.vendor, d750.port, d750.srvPort, ip.DefaultPort)
+		}
+
+		if conf.vendor != "fuji" || conf.host != "192.168.0.2" {
+			t.Errorf("loadConfig() %s default camera = %s %s; want fuji 192.168.0.2", f, conf.vendor, conf.host)
+		}
+	}
+	resetConf()
+}
+
+func TestSelectCameras(t *testing.T) {
+	resetConf()
+	conf.cameras = map[string]*camera{
+		"xt1":  {name: "xt1", vendor: "fuji", host: "192.168.0.10", srvPort: 25740, webPort: 25741},
+		"d750": {name: "d750", vendor: "nikon", host: "192.168.0.11", srvPort: 25750},
+		"a7":   {name: "a7", vendor: "sony", host: "192.168.0.12"},
+		"z6":   {name: "z6", vendor: "nikon", host: "192.168.0.13", srvPort: 25741},
+	}
+	defer resetConf()
+
+	if cams, err := selectCameras(""); err != nil || cams != nil {
+		t.Errorf("selectCameras() got = %v, %v; want nil, nil", cams, err)
+	}
+
+	cams, err := selectCameras("xt1, d750")
+	if err != nil || len(cams) != 2 || cams[0].name != "xt1" || cams[1].name != "d750" {
+		t.Errorf("selectCameras() got = %v, %v; want xt1 and d750", cams, err)
+	}
+
+	for _, names := range []string{"x100", "xt1,a7", "xt1,z6"} {
+		if _, err := selectCameras(names); err == nil {
+			t.Errorf("selectCameras(%s) err = nil; want error", names)
+		}
+	}
+
+	cams, err = selectCameras("a7")
+	if err != nil || len(cams) != 1 {
+		t.Fatalf("selectCameras() got = %v, %v; want a7", cams, err)
+	}
+	cams[0].use()
+	if conf.vendor != "sony" || conf.host != "192.168.0.12" || conf.srvPort != uint16Value(ip.DefaultPort) {
+		t.Errorf("use() got = %s %s server port %d; want sony 192.168.0.12 server port %d", conf.vendor, conf.host, conf.srvPort, ip.DefaultPort)
+	}
+}
+
+func TestDumpConfigCameras(t *testing.T) {
+	resetConf()
+	conf.cameras = map[string]*camera{
+		"xt1":  {name: "xt1", vendor: "fuji", host: "192.168.0.10", cport: 55740, eport: 55741, srvPort: 25740},
+		"d750": {name: "d750", vendor: "nikon", host: "192.168.0.11", port: 15740},
+	}
+	defer resetConf()
+
+	var b bytes.Buffer
+	dumpConfig(&b, formatYAML)
+	f, err := parseYAMLConfig(b.Bytes())
+	if err != nil {
+		t.Fatalf("dumpConfig() output does not parse: %s\n%s", err, b.String())
+	}
+
+	if got := f.Section("camera.xt1").Key("server_port").String(); got != "25740" {
+		t.Errorf("dumpConfig() camera.xt1 server_port = %s; want 25740", got)
+	}
+	if got := f.Section("camera.d750").Key("vendor").String(); got != "nikon" {
+		t.Errorf("dumpConfig() camera.d750 vendor = %s; want nikon", got)
+	}
+	if got := f.Section("server").Key("address").String(); got != defaultIp {
+		t.Errorf("dumpConfig() server address = %s; want %s", got, defaultIp)
+	}
+}
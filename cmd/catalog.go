@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// catalogFile is the name of the file holding the catalog of a sync directory.
+const catalogFile = ".ptpip-sync.json"
+
+// catalogEntry describes an object downloaded by the sync command.
+type catalogEntry struct {
+	Handle   uint32    `json:"handle"`
+	Filename string    `json:"filename"`
+	Size     uint32    `json:"size"`
+	Captured time.Time `json:"captured"`
+	// SHA256 is the hexadecimal SHA-256 hash of the data of the object.
+	SHA256 string `json:"sha256"`
+	// Path is the path of the downloaded file, relative to the sync directory.
+	Path       string    `json:"path"`
+	Downloaded time.Time `json:"downloaded"`
+}
+
+// syncCatalog keeps track of the objects downloaded to a sync directory, so that they are not downloaded again when
+// the camera assigns them new handles, e.g. after being switched off, or when the downloaded files have been moved or
+// deleted, e.g. when culling the images. Objects are identified by their file name, size and capture date, which do not
+// change. The hash of their data is used to avoid writing the same data twice.
+type syncCatalog struct {
+	store   fileStore
+	Entries []catalogEntry `json:"entries"`
+	byKey   map[string]int
+	byHash  map[string]int
+	changed bool
+}
+
+// loadCatalog reads the catalog of the given storage. An empty catalog is returned when the storage has none yet.
+func loadCatalog(store fileStore) (*syncCatalog, error) {
+	cat := &syncCatalog{store: store}
+
+	data, err := store.read(catalogFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, cat); err != nil {
+			return nil, fmt.Errorf("reading catalog %s: %s", catalogFile, err)
+		}
+	}
+
+	cat.byKey, cat.byHash = make(map[string]int), make(map[string]int)
+	for i, e := range cat.Entries {
+		cat.byKey[catalogKey(e.Filename, e.Size, e.Captured)] = i
+		cat.byHash[e.SHA256] = i
+	}
+
+	return cat, nil
+}
+
+// catalogKey returns the key identifying an object in the catalog.
+func catalogKey(name string, size uint32, captured time.Time) string {
+	key := fmt.Sprintf("%s/%d", strings.ToUpper(name), size)
+	if !captured.IsZero() {
+		key += "/" + captured.UTC().Format(time.RFC3339)
+	}
+
+	return key
+}
+
+// contains reports whether the object has been downloaded before.
+func (cat *syncCatalog) contains(obj ip.Object) bool {
+	_, ok := cat.byKey[catalogKey(obj.Info.Filename, obj.Info.ObjectCompressedSize, obj.Info.CaptureDate)]
+
+	return ok
+}
+
+// duplicate returns the path of a downloaded file, relative to the directory, holding the data with the given hash.
+// False is returned when there is none or when the file no longer exists.
+func (cat *syncCatalog) duplicate(sum string) (string, bool) {
+	i, ok := cat.byHash[sum]
+	if !ok {
+		return "", false
+	}
+	if _, err := cat.store.stat(filepath.FromSlash(cat.Entries[i].Path)); err != nil {
+		return "", false
+	}
+
+	return cat.Entries[i].Path, true
+}
+
+// add records the object as downloaded to the given path, which is relative to the directory.
+func (cat *syncCatalog) add(obj ip.Object, path, sum string) {
+	e := catalogEntry{
+		Handle:     uint32(obj.Handle),
+		Filename:   obj.Info.Filename,
+		Size:       obj.Info.ObjectCompressedSize,
+		Captured:   obj.Info.CaptureDate,
+		SHA256:     sum,
+		Path:       filepath.ToSlash(path),
+		Downloaded: time.Now(),
+	}
+
+	key := catalogKey(e.Filename, e.Size, e.Captured)
+	if i, ok := cat.byKey[key]; ok {
+		cat.Entries[i] = e
+	} else {
+		cat.byKey[key] = len(cat.Entries)
+		cat.Entries = append(cat.Entries, e)
+	}
+	if _, ok := cat.byHash[sum]; !ok {
+		cat.byHash[sum] = cat.byKey[key]
+	}
+	cat.changed = true
+}
+
+// save writes the catalog to the storage when it has changed. The storage makes sure that an interrupted sync does not
+// corrupt it.
+func (cat *syncCatalog) save() error {
+	if !cat.changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(cat, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := cat.store.write(catalogFile, data); err != nil {
+		return err
+	}
+	cat.changed = false
+
+	return nil
+}
+
+// hashData returns the hexadecimal SHA-256 hash of the data.
+func hashData(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
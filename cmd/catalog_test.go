@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncCatalog(t *testing.T) {
+	dir := t.TempDir()
+	captured := time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local)
+	obj := ip.Object{Handle: 0x10, Info: &ptp.ObjectInfo{Filename: "DSCF0001.JPG", ObjectCompressedSize: 3, CaptureDate: captured}}
+
+	cat, err := loadCatalog(localStore{dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cat.contains(obj) {
+		t.Error("contains() got = true; want false for an empty catalog")
+	}
+
+	data := []byte{0xFF, 0xD8, 0x01}
+	if err := os.WriteFile(filepath.Join(dir, "DSCF0001.JPG"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := hashData(data)
+	cat.add(obj, "DSCF0001.JPG", sum)
+	if err := cat.save(); err != nil {
+		t.Fatalf("save() error = %s; want <nil>", err)
+	}
+
+	if cat, err = loadCatalog(localStore{dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	// The camera assigned a new handle to the same object.
+	renumbered := ip.Object{Handle: 0x20, Info: obj.Info}
+	if !cat.contains(renumbered) {
+		t.Error("contains() got = false; want true for a renumbered object")
+	}
+	other := ip.Object{Handle: 0x10, Info: &ptp.ObjectInfo{Filename: "DSCF0001.JPG", ObjectCompressedSize: 3, CaptureDate: captured.Add(time.Hour)}}
+	if cat.contains(other) {
+		t.Error("contains() got = true; want false for another object with the same name")
+	}
+
+	if path, ok := cat.duplicate(sum); !ok || path != "DSCF0001.JPG" {
+		t.Errorf("duplicate() got = %s, %t; want DSCF0001.JPG, true", path, ok)
+	}
+
+	// A deleted file is still considered downloaded, but no longer holds a duplicate.
+	os.Remove(filepath.Join(dir, "DSCF0001.JPG"))
+	if _, ok := cat.duplicate(sum); ok || !cat.contains(obj) {
+		t.Errorf("duplicate() got = %t and contains() got = %t after deleting the file; want false and true", ok, cat.contains(obj))
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 func init() {
@@ -24,7 +29,23 @@ func (capture) alias() []string {
 	return []string{"shoot", "shutter", "snap"}
 }
 
-func (cap capture) execute(c *ip.Client, f []string, asyncOut chan<- string) string {
+func (cap capture) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	res, _ := cap.run(c, f, asyncOut)
+
+	return res
+}
+
+// run captures the requested amount of images. The capture stops at the first failure, which is returned as an
+// *ip.CaptureError. Failing to save a preview is reported once all captures have been made.
+func (cap capture) run(c ip.ClientAPI, f []string, asyncOut chan<- string) (string, error) {
+	errorFmt := "capture error: %s\n"
+
+	f, inTerm := hasPreviewFlag(f)
+	namer, f, err := extractFileNaming(f, collisionOverwrite)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err), err
+	}
+
 	amount := 1
 	if len(f) >= 1 {
 		if val, err := strconv.Atoi(f[0]); err == nil {
@@ -34,30 +55,67 @@ func (cap capture) execute(c *ip.Client, f []string, asyncOut chan<- string) str
 	}
 
 	var (
-		imgs chan []byte
-		wg   sync.WaitGroup
+		imgs   chan []byte
+		wg     sync.WaitGroup
+		errImg error
 	)
-	if len(f) >= 1 {
-		imgs = make(chan []byte, 10)
-		var path string
-		if !cap.isView(f[0]) {
-			ext := filepath.Ext(f[0])
-			path = strings.TrimSuffix(f[0], ext) + "-%d" + ext
+	if len(f) >= 1 || inTerm {
+		var film ip.FujiFilmSimulation
+		if len(f) >= 1 && !cap.isView(f[0]) {
+			path := f[0]
+			if amount > 1 && !strings.Contains(path, "{counter") {
+				ext := filepath.Ext(path)
+				path = strings.TrimSuffix(path, ext) + "-{counter:1}" + ext
+			}
+			if namer.tmpl, err = parseNameTemplate(filepath.ToSlash(path)); err != nil {
+				err = fmt.Errorf("invalid filepath %s: %s", f[0], err)
+				return fmt.Sprintf(errorFmt, err), err
+			}
+			namer.camera = c.ResponderFriendlyName()
+			// The film simulation cannot be requested while capturing.
+			if namer.tmpl.has("film") && c.ResponderVendor() == ptp.VE_FujiPhotoFilmCoLtd {
+				if v, err := c.GetDevicePropertyValue(ip.DPC_Fuji_FilmSimulation); err == nil {
+					film = ip.FujiFilmSimulation(v)
+				}
+			}
 		}
 
+		imgs = make(chan []byte, 10)
 		wg.Add(1)
 		go func() {
-			i := 1
 			for img := range imgs {
-				if path != "" {
-					file := fmt.Sprintf(path, i)
-					if err := ioutil.WriteFile(file, img, 0644); err != nil {
+				if inTerm {
+					ti, err := termImage(img)
+					if err != nil {
+						if errImg == nil {
+							errImg = err
+						}
+						ti = err.Error()
+					}
+					asyncOut <- ti
+				}
+				if namer.tmpl != nil {
+					file := filepath.FromSlash(namer.name(captureObject(time.Now()), func() (*ip.ObjectMetadata, error) {
+						return captureMetadata(img, film), nil
+					}))
+					if namer.collision == collisionSkip && namer.exists(file) {
+						asyncOut <- fmt.Sprintf("Image preview not saved, %s exists", file)
+						continue
+					}
+					file = namer.resolve(file)
+					err := os.MkdirAll(filepath.Dir(file), 0755)
+					if err == nil {
+						err = ioutil.WriteFile(file, img, 0644)
+					}
+					if err != nil {
+						if errImg == nil {
+							errImg = err
+						}
 						asyncOut <- err.Error()
 						continue
 					}
 					asyncOut <- fmt.Sprintf("Image preview saved to %s", file)
-					i++
-				} else {
+				} else if len(f) >= 1 {
 					asyncOut <- preview(img)
 				}
 			}
@@ -72,19 +130,31 @@ func (cap capture) execute(c *ip.Client, f []string, asyncOut chan<- string) str
 		if amount > 1 {
 			asyncOut <- fmt.Sprintf("  capturing image %d", i+1)
 		}
-		var err error
-		img, err := c.InitiateCapture()
-		if err != nil {
-			return err.Error()
+		var img []byte
+		if img, err = c.InitiateCapture(); err != nil {
+			break
 		}
+		reviewCapture(img)
 		if imgs != nil {
+			if len(img) == 0 {
+				err = &ip.CaptureError{Released: true, Err: errors.New("the camera did not return an image")}
+				break
+			}
 			imgs <- img
 		}
 	}
 	if imgs != nil {
 		close(imgs)
 		wg.Wait()
-		return ""
+	}
+	if err == nil {
+		err = errImg
+	}
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err), err
+	}
+	if imgs != nil {
+		return "", nil
 	}
 
 	plural := ""
@@ -92,11 +162,25 @@ func (cap capture) execute(c *ip.Client, f []string, asyncOut chan<- string) str
 		plural = "s"
 	}
 
-	return fmt.Sprintf("Image%s captured, check the camera\n", plural)
+	return fmt.Sprintf("Image%s captured, check the camera\n", plural), nil
+}
+
+// captureMetadata returns the EXIF metadata of a captured image, using the given film simulation when the image does
+// not hold it.
+func captureMetadata(img []byte, film ip.FujiFilmSimulation) *ip.ObjectMetadata {
+	md, err := ip.ReadMetadata(bytes.NewReader(img))
+	if err != nil {
+		md = &ip.ObjectMetadata{}
+	}
+	if md.FilmSimulation == 0 {
+		md.FilmSimulation = film
+	}
+
+	return md
 }
 
 func (cap capture) help() string {
-	help := `"` + cap.name() + `" will make the responder capture a single image.` + "\n"
+	help := `"` + cap.name() + `" will make the responder capture a single image, waiting for the camera to return a preview of it. When used with the -c flag, a failed capture results in a non-zero exit code.` + "\n"
 	help += helpAddAliases(cap.alias())
 
 	if args := cap.arguments(); len(args) > 0 {
@@ -108,18 +192,38 @@ func (cap capture) help() string {
 			case 1:
 				help += "\t- " + `"` + arg + `" opens a window to display the capture preview if the camera returns it` + "\n\tOR\n"
 			case 2:
-				help += "\t- a " + arg + " to save the capture preview to\n"
+				help += "\t- a " + arg + " to save the capture preview to. It may be a name template holding the fields listed below, e.g. /tmp/{camera}-{date}-{counter}.jpg. When capturing several images without the {counter} field, a sequence number is added to the file name\n"
+			case 3:
+				help += "\t- " + arg + ": display the capture preview in the terminal using the sixel, iTerm2 or kitty image protocol, which works over SSH. Can be combined with a filepath\n"
 			}
 		}
+		help += helpAddTemplateFields()
+		help += helpAddCollision(collisionOverwrite)
 	}
 
 	return help
 }
 
 func (capture) arguments() []string {
-	return []string{"amount", "view", "filepath"}
+	return []string{"amount", "view", "filepath", previewFlag}
 }
 
 func (cap capture) isView(param string) bool {
 	return param == cap.arguments()[1]
 }
+
+func (capture) usage() string {
+	return "capture [amount] [view | filepath] [collision=overwrite|skip|rename] [--preview]"
+}
+
+func (capture) examples() []string {
+	return []string{
+		"capture",
+		"capture 3",
+		"capture view",
+		"capture /tmp/preview.jpg",
+		"capture 2 /tmp/preview.jpg",
+		"capture 5 /tmp/{camera}-{date}-{counter}.jpg collision=rename",
+		"snap --preview",
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
+	"testing"
+)
+
+func TestCapture_run(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	err = executeCommand("capture /tmp/capture.jpg", bufio.NewWriter(&b), c, "test")
+
+	var ce *ip.CaptureError
+	if !errors.As(err, &ce) {
+		t.Fatalf("executeCommand() error = %v; want *ip.CaptureError", err)
+	}
+	if got := b.String(); !strings.HasPrefix(got, "capture error: capture failed: ") {
+		t.Errorf("executeCommand() got = %s; want capture error", got)
+	}
+	if got := commandExitCode(err); got != errCapture {
+		t.Errorf("commandExitCode() got = %d; want %d", got, errCapture)
+	}
+	if got := commandExitCode(errors.New("other")); got != errGeneral {
+		t.Errorf("commandExitCode() got = %d; want %d", got, errGeneral)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+)
+
+func init() {
+	registerCommand(&conformance{})
+}
+
+// formatConformanceReport formats the outcome of the conformance checks as a table followed by a summary line.
+func formatConformanceReport(res []ip.ConformanceResult) string {
+	w, buf := newTabWriter()
+	rows := [][]string{
+		{"Group", "Check", "Result", "Detail"},
+		{"-----", "-----", "------", "------"},
+	}
+	count := make(map[ip.ConformanceStatus]int)
+	for _, r := range res {
+		rows = append(rows, []string{r.Group, r.Name, r.Status.String(), r.Detail})
+		count[r.Status]++
+	}
+	formatRows(w, rows)
+
+	fmt.Fprintf(buf, "\n%d passed, %d failed, %d skipped\n", count[ip.CS_Pass], count[ip.CS_Fail], count[ip.CS_Skip])
+
+	return buf.String()
+}
+
+type conformance struct{}
+
+func (conformance) name() string {
+	return "conformance"
+}
+
+func (conformance) alias() []string {
+	return []string{"compliance"}
+}
+
+func (conformance) execute(c ip.ClientAPI, _ []string, _ chan<- string) string {
+	return formatConformanceReport(c.CheckConformance())
+}
+
+func (cf conformance) help() string {
+	help := `"` + cf.name() + `" runs a battery of protocol checks against the camera and prints a compliance report. The checks cover the init handshake, probe handling, data phase edge cases and cancelling a transaction. The init checks open extra connections to the camera, which might briefly disturb a camera that only allows a single client. Checks sending standard PTP/IP packets are skipped for vendors using their own packet layout, such as Fujifilm.` + "\n"
+	help += helpAddAliases(cf.alias())
+
+	return help
+}
+
+func (conformance) arguments() []string {
+	return []string{}
+}
+
+func (conformance) usage() string {
+	return "conformance"
+}
+
+func (conformance) examples() []string {
+	return []string{
+		"conformance",
+		"compliance",
+	}
+}
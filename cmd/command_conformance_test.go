@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
+	"testing"
+)
+
+func TestFormatConformanceReport(t *testing.T) {
+	got := formatConformanceReport([]ip.ConformanceResult{
+		{Group: "init", Name: "a second Initiator is answered", Status: ip.CS_Pass, Detail: "refused: busy"},
+		{Group: "probe", Name: "a probe request is answered", Status: ip.CS_Fail, Detail: "timeout"},
+		{Group: "cancel", Name: "a cancelled data-out phase is ended", Status: ip.CS_Skip},
+	})
+
+	for _, want := range []string{"Group", "a second Initiator is answered", "FAIL", "refused: busy", "1 passed, 1 failed, 1 skipped\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatConformanceReport() got = %s; want it to contain %s", got, want)
+		}
+	}
+}
@@ -19,7 +19,7 @@ func (describe) alias() []string {
 	return []string{}
 }
 
-func (describe) execute(c *ip.Client, f []string, _ chan<- string) string {
+func (describe) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
 	errorFmt := "describe error: %s\n"
 
 	cod, err := formatDeviceProperty(c, f[0])
@@ -62,3 +62,14 @@ func (d describe) help() string {
 func (describe) arguments() []string {
 	return []string{"property", "json", "pretty"}
 }
+
+func (describe) usage() string {
+	return "describe property [json [pretty]]"
+}
+
+func (describe) examples() []string {
+	return []string{
+		"describe 0x5005",
+		"describe whitebalance json pretty",
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"strconv"
+)
+
+func init() {
+	registerCommand(&discover{})
+}
+
+// formatDiscoveredResponders formats the responders found by ip.Discover as a table, or as JSON when f starts with
+// "json".
+func formatDiscoveredResponders(list []ip.DiscoveredResponder, f []string) string {
+	if len(f) >= 1 && f[0] == "json" {
+		var opt string
+		if len(f) > 1 {
+			opt = f[1]
+		}
+
+		return fujiFormatJson(list, opt) + "\n"
+	}
+
+	if len(list) == 0 {
+		return "no cameras found\n"
+	}
+
+	w, buf := newTabWriter()
+	rows := [][]string{
+		{"Address", "Port", "Vendor", "Name", "GUID", "Found using"},
+		{"-------", "----", "------", "----", "----", "-----------"},
+	}
+	for _, r := range list {
+		rows = append(rows, []string{r.IpAddress, strconv.Itoa(int(r.Port)), r.Vendor, r.FriendlyName, r.GUID, string(r.Method)})
+	}
+	formatRows(w, rows)
+
+	return buf.String()
+}
+
+type discover struct{}
+
+func (discover) name() string {
+	return "discover"
+}
+
+func (discover) alias() []string {
+	return []string{}
+}
+
+// standalone marks discover as a command that does not need a connection to the camera.
+func (discover) standalone() {}
+
+func (d discover) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	res, _ := d.run(c, f, asyncOut)
+
+	return res
+}
+
+func (d discover) run(_ ip.ClientAPI, f []string, _ chan<- string) (string, error) {
+	timeout := ip.DefaultDiscoveryTimeout
+	if len(f) > 0 && f[0] != "json" {
+		var err error
+		if timeout, err = parseDuration(f[0]); err != nil || timeout <= 0 {
+			err = fmt.Errorf("invalid duration %s", f[0])
+			return fmt.Sprintf("discover error: %s\n", err), err
+		}
+		f = f[1:]
+	}
+
+	list, err := ip.Discover(timeout)
+	if err != nil {
+		return fmt.Sprintf("discover error: %s\n", err), err
+	}
+
+	return formatDiscoveredResponders(list, f), nil
+}
+
+func (d discover) help() string {
+	help := `"` + d.name() + `" looks for cameras on the local network using mDNS, SSDP and the broadcasts sent by some vendors, and lists the cameras found with their address, vendor and GUID. This command does not need a connection to a camera, so running it using the -c flag does not connect to the configured camera.` + "\n"
+
+	if args := d.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		help += "\t- the " + args[0] + " to listen for cameras, e.g. 5s or a number of seconds, which defaults to " + ip.DefaultDiscoveryTimeout.String() + "\n"
+		help += "\t- " + `"` + args[1] + `" to output the data in parsable json format` + "\n"
+		help += "\t- " + `"` + args[2] + `" to be used together with "` + args[1] + `": format the output in a human readable way` + "\n"
+	}
+
+	return help
+}
+
+func (discover) arguments() []string {
+	return []string{"duration", "json", "pretty"}
+}
+
+func (discover) usage() string {
+	return "discover [duration] [json [pretty]]"
+}
+
+func (discover) examples() []string {
+	return []string{
+		"discover",
+		"discover 10",
+		"discover 5s json pretty",
+	}
+}
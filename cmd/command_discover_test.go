@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
+	"testing"
+)
+
+func TestFormatDiscoveredResponders(t *testing.T) {
+	list := []ip.DiscoveredResponder{{
+		FriendlyName: "X-T3",
+		Vendor:       "fuji",
+		GUID:         "0f5b3c3a-7c0b-4f5c-9c61-7b3a1d4c1a2b",
+		IpAddress:    "192.168.0.1",
+		Port:         15740,
+		Method:       ip.DiscoveryMDNS,
+	}}
+
+	got := formatDiscoveredResponders(list, nil)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("formatDiscoveredResponders() got %d lines; want 3", len(lines))
+	}
+	if want := strings.Fields("192.168.0.1 15740 fuji X-T3 0f5b3c3a-7c0b-4f5c-9c61-7b3a1d4c1a2b mdns"); strings.Join(strings.Fields(lines[2]), " ") != strings.Join(want, " ") {
+		t.Errorf("formatDiscoveredResponders() got = '%s'; want '%s'", lines[2], strings.Join(want, " "))
+	}
+
+	got = formatDiscoveredResponders(list, []string{"json"})
+	want := `[{"name":"X-T3","vendor":"fuji","guid":"0f5b3c3a-7c0b-4f5c-9c61-7b3a1d4c1a2b","address":"192.168.0.1","port":15740,"method":"mdns"}]` + "\n"
+	if got != want {
+		t.Errorf("formatDiscoveredResponders() got = '%s'; want '%s'", got, want)
+	}
+
+	got = formatDiscoveredResponders(nil, nil)
+	want = "no cameras found\n"
+	if got != want {
+		t.Errorf("formatDiscoveredResponders() got = '%s'; want '%s'", got, want)
+	}
+}
+
+func TestDiscover_run(t *testing.T) {
+	got, err := discover{}.run(nil, []string{"soon"}, make(chan string))
+	want := "discover error: invalid duration soon\n"
+	if got != want || err == nil {
+		t.Errorf("run() got = '%s', %v; want '%s', error", got, err, want)
+	}
+}
+
+func TestIsStandalone(t *testing.T) {
+	check := map[string]bool{
+		"discover 5": true,
+		"info":       false,
+		"":           false,
+	}
+	for msg, want := range check {
+		if got := isStandalone(msg); got != want {
+			t.Errorf("isStandalone(%s) got = %v; want %v", msg, got, want)
+		}
+	}
+}
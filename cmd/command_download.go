@@ -0,0 +1,422 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand(&download{})
+}
+
+// downloadObjects downloads the objects matching the filter arguments to dir, which is a local directory or the URL
+// of remote storage, see openStore, reporting the progress on the asynchronous output channel. When sync is true,
+// objects recorded in the catalog of dir, or already present in dir with the same size, are skipped. A limit=rate argument limits the transfer rate for the duration of the download.
+// The files are named using the name=template argument, applying the collision=policy argument to existing files,
+// which defaults to overwriting them, or to renaming the new file when syncing. The xmp argument writes an XMP sidecar
+// next to each RAW file. The transcode argument converts the objects before storing them, see extractTranscoding.
+func downloadObjects(c ip.ClientAPI, dir string, args []string, sync bool, asyncOut chan<- string) (string, error) {
+	limit, args, err := extractRateLimit(args)
+	if err != nil {
+		return "", err
+	}
+	collision := collisionOverwrite
+	if sync {
+		collision = collisionRename
+	}
+	namer, args, err := extractFileNaming(args, collision)
+	if err != nil {
+		return "", err
+	}
+	namer.camera = c.ResponderFriendlyName()
+	xmp, args := extractSidecarFlag(args)
+	steps, args, err := extractTranscoding(args)
+	if err != nil {
+		return "", err
+	}
+	bursts, args, err := extractBurstMode(args)
+	if err != nil {
+		return "", err
+	}
+	filter, err := parseObjectFilter(args)
+	if err != nil {
+		return "", err
+	}
+	if limit > 0 {
+		prev := c.TransferRateLimit()
+		c.SetTransferRateLimit(limit)
+		defer c.SetTransferRateLimit(prev)
+	}
+	store, err := openStore(dir)
+	if err != nil {
+		return "", err
+	}
+	namer.store = store
+
+	// Counting the objects is quick, reading the ObjectInfo dataset of each of them to filter them is not.
+	if n, err := c.GetNumObjects(ip.AllStorages, 0, 0); err == nil {
+		asyncOut <- fmt.Sprintf("looking up %d objects", n)
+	}
+
+	objs, err := c.FindObjects(filter)
+	if err != nil {
+		return "", err
+	}
+	if len(objs) == 0 {
+		return "no objects found\n", nil
+	}
+	// The paths are relative to the store.
+	objs, pathOf := applyBurstMode(bursts, "", objs)
+	pathOf = namer.pathFunc(pathOf, func(obj ip.Object) (*ip.ObjectMetadata, error) {
+		return c.GetObjectMetadata(obj.Handle)
+	})
+
+	var cat *syncCatalog
+	if sync {
+		if cat, err = loadCatalog(store); err != nil {
+			return "", err
+		}
+		// Continue counting from the objects synced before.
+		namer.counter = len(cat.Entries)
+	}
+	skip := func(obj ip.Object) bool {
+		if cat != nil {
+			if cat.contains(obj) {
+				return true
+			}
+			path := pathOf(obj)
+			size, err := store.stat(path)
+			if err == nil && size == int64(obj.Info.ObjectCompressedSize) {
+				// Add the files downloaded before the directory had a catalog.
+				if data, err := store.read(path); err == nil {
+					cat.add(obj, path, hashData(data))
+				}
+				return true
+			}
+		}
+		return namer.collision == collisionSkip && namer.exists(pathOf(obj))
+	}
+
+	var (
+		total                                                         int64
+		count, skipped, duplicates, sidecars, transcodes, hooksFailed int
+	)
+	write := func(obj ip.Object, data []byte) error {
+		var sum string
+		if cat != nil {
+			sum = hashData(data)
+			if dup, ok := cat.duplicate(sum); ok {
+				cat.add(obj, dup, sum)
+				duplicates++
+				return nil
+			}
+		}
+
+		// The sidecar and the catalog keep using the data of the object as stored on the camera.
+		path, out := pathOf(obj), data
+		tf := transcodeFile{Name: filepath.Base(path), Data: data, Object: obj, Vendor: c.ResponderVendor().String()}
+		if tf, err := steps.apply(tf); err != nil {
+			transcodes++
+			asyncOut <- fmt.Sprintf("%s: %s, storing it as is", filepath.Base(path), err)
+		} else {
+			path, out = filepath.Join(filepath.Dir(path), tf.Name), tf.Data
+		}
+
+		path = namer.resolveObject(obj, func(ip.Object) string { return path })
+		if err := store.write(path, out); err != nil {
+			return err
+		}
+		if cat != nil {
+			cat.add(obj, path, sum)
+		}
+		if xmp && ip.KindOfObject(obj.Info) == ip.ObjectKindRAW {
+			if sidecar, err := writeSidecar(c, store, path, obj, data); err != nil {
+				asyncOut <- fmt.Sprintf("%s: writing the XMP sidecar failed: %s", filepath.Base(path), err)
+			} else if sidecar != "" {
+				sidecars++
+			}
+		}
+
+		hooksFailed += runDownloadHooks(downloadedFile{Path: store.location(path), Object: obj, Vendor: c.ResponderVendor().String()}, asyncOut)
+
+		return nil
+	}
+
+	err = c.DownloadObjects(objs, skip, write, func(p ip.DownloadProgress) {
+		name := p.Object.Info.Filename
+		if path, ok := namer.path(p.Object); ok {
+			name = path
+		}
+		switch {
+		case p.Err != nil:
+			asyncOut <- fmt.Sprintf("[%d/%d] %s: %s", p.Index, p.Total, name, p.Err)
+		case p.Skipped:
+			skipped++
+			asyncOut <- fmt.Sprintf("[%d/%d] %s: already downloaded", p.Index, p.Total, name)
+		default:
+			count++
+			total += int64(p.Bytes)
+			asyncOut <- fmt.Sprintf("[%d/%d] %s: %s%s", p.Index, p.Total, name, formatBytes(int64(p.Bytes)), formatThroughput(p))
+		}
+	})
+
+	res := fmt.Sprintf("%d of %d objects downloaded to %s (%s)", count, len(objs), dir, formatBytes(total))
+	if skipped > 0 {
+		res += fmt.Sprintf(", %d already downloaded", skipped)
+	}
+	if duplicates > 0 {
+		res += fmt.Sprintf(", %d duplicates not written", duplicates)
+	}
+	if sidecars > 0 {
+		res += fmt.Sprintf(", %d XMP sidecars written", sidecars)
+	}
+	if failed := len(objs) - count - skipped; failed > 0 {
+		res += fmt.Sprintf(", %d failed", failed)
+	}
+	if transcodes > 0 {
+		res += fmt.Sprintf(", %d transcodings failed", transcodes)
+	}
+	if hooksFailed > 0 {
+		res += fmt.Sprintf(", %d download hooks failed", hooksFailed)
+	}
+	if cat != nil {
+		if err := cat.save(); err != nil {
+			res += fmt.Sprintf(", saving the catalog failed: %s", err)
+		}
+	}
+
+	return res + "\n", err
+}
+
+// formatThroughput formats the transfer rate and the estimated time left of the download, if known.
+func formatThroughput(p ip.DownloadProgress) string {
+	if p.Rate <= 0 {
+		return ""
+	}
+
+	res := fmt.Sprintf(", %s/s", formatBytes(int64(p.Rate)))
+	if p.ETA > 0 {
+		res += fmt.Sprintf(", %s left", p.ETA.Round(time.Second))
+	}
+
+	return res
+}
+
+// extractRateLimit removes the limit=rate argument from args, returning the rate in bytes per second and the remaining
+// arguments. A rate of zero is returned when there is no limit argument.
+func extractRateLimit(args []string) (int64, []string, error) {
+	var (
+		limit int64
+		rest  []string
+	)
+	for _, arg := range args {
+		val, found := strings.CutPrefix(arg, "limit=")
+		if !found {
+			rest = append(rest, arg)
+			continue
+		}
+
+		var err error
+		if limit, err = parseByteSize(strings.TrimSuffix(val, "/s")); err != nil {
+			return 0, nil, fmt.Errorf("invalid limit %s: %s", val, err)
+		}
+	}
+
+	return limit, rest, nil
+}
+
+// Burst modes of the download commands.
+const (
+	// burstFolder downloads each burst to a folder of its own, so that the best shot can be picked from it.
+	burstFolder = "folder"
+	// burstFirst only downloads the first shot of each burst.
+	burstFirst = "first"
+)
+
+// extractBurstMode removes the bursts=mode argument from args, returning the mode and the remaining arguments. An
+// empty mode is returned when there is no bursts argument.
+func extractBurstMode(args []string) (string, []string, error) {
+	var (
+		mode string
+		rest []string
+	)
+	for _, arg := range args {
+		val, found := strings.CutPrefix(arg, "bursts=")
+		if !found {
+			rest = append(rest, arg)
+			continue
+		}
+		if val != burstFolder && val != burstFirst {
+			return "", nil, fmt.Errorf("invalid bursts %s, must be %s or %s", val, burstFolder, burstFirst)
+		}
+		mode = val
+	}
+
+	return mode, rest, nil
+}
+
+// applyBurstMode groups the objects into bursts according to the burst mode and returns the objects to download,
+// together with a function returning the path to download an object to.
+func applyBurstMode(mode, dir string, objs []ip.Object) ([]ip.Object, func(ip.Object) string) {
+	pathOf := func(obj ip.Object) string {
+		return objectPath(dir, obj)
+	}
+	if mode == "" {
+		return objs, pathOf
+	}
+
+	bursts := ip.GroupBursts(objs, ip.DefaultBurstGap)
+	switch mode {
+	case burstFirst:
+		objs = nil
+		for _, b := range bursts {
+			objs = append(objs, b.Shots[0]...)
+		}
+	case burstFolder:
+		dirs := make(map[ptp.ObjectHandle]string)
+		for _, b := range bursts {
+			if len(b.Shots) < 2 {
+				continue
+			}
+			for _, obj := range b.Objects() {
+				dirs[obj.Handle] = burstDir(b)
+			}
+		}
+		pathOf = func(obj ip.Object) string {
+			return objectPath(filepath.Join(dir, dirs[obj.Handle]), obj)
+		}
+	}
+
+	return objs, pathOf
+}
+
+// burstDir returns the name of the folder to download a burst to, based on the capture date of its first shot or the
+// handle of its first object when the capture date is unknown.
+func burstDir(b ip.Burst) string {
+	if start := b.Start(); !start.IsZero() {
+		return "burst-" + start.Format("20060102-150405")
+	}
+
+	return fmt.Sprintf("burst-%08x", uint32(b.Shots[0][0].Handle))
+}
+
+// helpAddBurstMode returns the help text of the bursts argument of the download commands.
+func helpAddBurstMode() string {
+	return "	- bursts=mode: " + burstFolder + " downloads each burst of shots to a folder of its own named after the time it started, e.g. burst-20210314-153045, to pick the best shot from it, " + burstFirst + " only downloads the first shot of each burst\n"
+}
+
+// parseByteSize parses a number of bytes with an optional decimal unit as printed by formatBytes, e.g. 500kB or 2.5MB.
+func parseByteSize(s string) (int64, error) {
+	num := strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(s, "B"), "b"))
+
+	mul := 1.0
+	if i := len(num) - 1; i >= 0 {
+		if exp := strings.IndexByte("kmgt", num[i]|0x20); exp >= 0 {
+			for ; exp >= 0; exp-- {
+				mul *= 1000
+			}
+			num = strings.TrimSpace(num[:i])
+		}
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a positive size such as 500kB or 2MB")
+	}
+
+	return int64(n * mul), nil
+}
+
+// helpAddRateLimit returns the help text of the limit argument of the download commands.
+func helpAddRateLimit() string {
+	return "\t- limit=rate: the maximum transfer rate in bytes per second, e.g. 500kB or 2MB, so that the download does not starve e.g. the liveview stream\n"
+}
+
+// objectPath returns the path to download the object to. Objects without a file name are named after their handle.
+func objectPath(dir string, obj ip.Object) string {
+	name := filepath.Base(obj.Info.Filename)
+	if obj.Info.Filename == "" || name == "." || name == string(filepath.Separator) {
+		name = fmt.Sprintf("%08x", uint32(obj.Handle))
+	}
+
+	return filepath.Join(dir, name)
+}
+
+type download struct{}
+
+func (download) name() string {
+	return "download"
+}
+
+func (download) alias() []string {
+	return []string{"dl"}
+}
+
+// longRunning always returns true: downloading many objects can take any amount of time.
+func (download) longRunning(_ []string) bool {
+	return true
+}
+
+func (d download) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	res, _ := d.run(c, f, asyncOut)
+
+	return res
+}
+
+func (d download) run(c ip.ClientAPI, f []string, asyncOut chan<- string) (string, error) {
+	if len(f) < 1 {
+		return "download error: missing directory\n", fmt.Errorf("missing directory")
+	}
+
+	res, err := downloadObjects(c, f[0], f[1:], false, asyncOut)
+	if err != nil {
+		return res + fmt.Sprintf("download error: %s\n", err), err
+	}
+
+	return res, nil
+}
+
+func (d download) help() string {
+	help := `"` + d.name() + `" downloads the objects, such as images and videos, stored on the camera to a directory. Existing files are overwritten by default, use the collision argument or the sync command to skip them instead.` + "\n"
+	help += helpAddAliases(d.alias())
+
+	if args := d.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		help += "\t- the " + args[0] + " to download to, which is created when it does not exist, or the URL of remote storage: s3://bucket/prefix for S3-compatible object storage, configured using the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION and AWS_ENDPOINT_URL environment variables, or sftp://user@host/path for an SFTP server, using the OpenSSH sftp client and SSH keys\n"
+		help += helpAddObjectFilters()
+		help += helpAddRateLimit()
+		help += helpAddBurstMode()
+		help += helpAddFileNaming(collisionOverwrite)
+		help += helpAddSidecar()
+		help += helpAddTranscoding()
+	}
+
+	return help
+}
+
+func (download) arguments() []string {
+	return []string{"directory", "filter"}
+}
+
+func (download) usage() string {
+	return "download directory [filter...] [limit=rate] [bursts=folder|first] [name=template] [collision=overwrite|skip|rename] [xmp] [transcode=name[:arg]]"
+}
+
+func (download) examples() []string {
+	return []string{
+		"download /tmp/photos",
+		"download /tmp/raw format=raw since=2021-03-14",
+		"dl /tmp/photos handles=0x10-0x20",
+		"download /tmp/videos format=video limit=2MB",
+		"download /tmp/bursts since=2021-03-14 bursts=folder",
+		"download /tmp/photos name={film}/{date}-{name} collision=rename",
+		"download /tmp/raw format=raw xmp",
+		"download /tmp/previews format=jpeg transcode=resize:1024",
+		"download s3://photos/studio?endpoint=http://localhost:9000 since=2021-03-14",
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// objectsClient is a mockClient holding objects to download.
+type objectsClient struct {
+	*mockClient
+	objs []ip.Object
+}
+
+func (oc *objectsClient) ResponderFriendlyName() string {
+	return "X-T1"
+}
+
+func (oc *objectsClient) GetNumObjects(_ ptp.StorageID, _ ptp.ObjectFormatCode, _ ptp.ObjectHandle) (int, error) {
+	return len(oc.objs), nil
+}
+
+func (oc *objectsClient) FindObjects(_ ip.ObjectFilter) ([]ip.Object, error) {
+	return oc.objs, nil
+}
+
+func (oc *objectsClient) DownloadObjects(objs []ip.Object, skip func(ip.Object) bool, write func(ip.Object, []byte) error, progress func(ip.DownloadProgress)) error {
+	for i, obj := range objs {
+		p := ip.DownloadProgress{Index: i + 1, Total: len(objs), Object: obj}
+		if skip(obj) {
+			p.Skipped = true
+		} else {
+			data := []byte(strings.Repeat("x", int(obj.Info.ObjectCompressedSize)))
+			p.Err = write(obj, data)
+			p.Bytes = len(data)
+		}
+		progress(p)
+	}
+
+	return nil
+}
+
+func TestExtractRateLimit(t *testing.T) {
+	limit, rest, err := extractRateLimit([]string{"format=raw", "limit=2.5MB", "since=2021-03-14"})
+	if err != nil {
+		t.Fatalf("extractRateLimit() error = %s; want <nil>", err)
+	}
+	if limit != 2500000 {
+		t.Errorf("extractRateLimit() limit = %d; want 2500000", limit)
+	}
+	if len(rest) != 2 || rest[0] != "format=raw" || rest[1] != "since=2021-03-14" {
+		t.Errorf("extractRateLimit() rest = %v; want [format=raw since=2021-03-14]", rest)
+	}
+
+	if limit, _, _ := extractRateLimit(nil); limit != 0 {
+		t.Errorf("extractRateLimit() limit = %d; want 0", limit)
+	}
+
+	for _, arg := range []string{"limit=fast", "limit=0", "limit=-1MB"} {
+		if _, _, err := extractRateLimit([]string{arg}); err == nil {
+			t.Errorf("extractRateLimit(%s) error = <nil>; want error", arg)
+		}
+	}
+}
+
+func TestExtractBurstMode(t *testing.T) {
+	mode, rest, err := extractBurstMode([]string{"format=raw", "bursts=folder"})
+	if mode != burstFolder || len(rest) != 1 || rest[0] != "format=raw" || err != nil {
+		t.Errorf("extractBurstMode() got = %s, %v, %v; want %s, [format=raw], <nil>", mode, rest, err, burstFolder)
+	}
+
+	if _, _, err := extractBurstMode([]string{"bursts=best"}); err == nil {
+		t.Error("extractBurstMode() error = <nil>; want error")
+	}
+}
+
+func TestApplyBurstMode(t *testing.T) {
+	start := time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local)
+	obj := func(h ptp.ObjectHandle, name string, offset time.Duration) ip.Object {
+		return ip.Object{Handle: h, Info: &ptp.ObjectInfo{Filename: name, CaptureDate: start.Add(offset)}}
+	}
+	objs := []ip.Object{
+		obj(1, "DSCF0001.JPG", 0),
+		obj(2, "DSCF0002.JPG", 100*time.Millisecond),
+		obj(3, "DSCF0003.JPG", time.Minute),
+	}
+
+	got, pathOf := applyBurstMode(burstFolder, "photos", objs)
+	if len(got) != 3 {
+		t.Errorf("applyBurstMode() got %d objects; want 3", len(got))
+	}
+	for _, check := range []struct {
+		obj  ip.Object
+		want string
+	}{
+		{objs[1], filepath.Join("photos", "burst-20210314-153045", "DSCF0002.JPG")},
+		{objs[2], filepath.Join("photos", "DSCF0003.JPG")},
+	} {
+		if p := pathOf(check.obj); p != check.want {
+			t.Errorf("applyBurstMode() path = %s; want %s", p, check.want)
+		}
+	}
+
+	got, _ = applyBurstMode(burstFirst, "photos", objs)
+	if len(got) != 2 || got[0].Handle != 1 || got[1].Handle != 3 {
+		t.Errorf("applyBurstMode() got = %v; want the objects 1 and 3", got)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	check := map[string]int64{
+		"512":    512,
+		"500kB":  500000,
+		"500 KB": 500000,
+		"2MB":    2000000,
+		"1.5m":   1500000,
+		"1GB/s":  0,
+	}
+
+	for in, want := range check {
+		got, err := parseByteSize(in)
+		if want == 0 {
+			if err == nil {
+				t.Errorf("parseByteSize(%s) error = <nil>; want error", in)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%s) got = %d, %v; want %d, <nil>", in, got, err, want)
+		}
+	}
+}
+
+func TestFormatThroughput(t *testing.T) {
+	check := []struct {
+		p    ip.DownloadProgress
+		want string
+	}{
+		{ip.DownloadProgress{}, ""},
+		{ip.DownloadProgress{Rate: 4200000}, ", 4.2 MB/s"},
+		{ip.DownloadProgress{Rate: 4200000, ETA: 80400 * time.Millisecond}, ", 4.2 MB/s, 1m20s left"},
+	}
+
+	for _, tt := range check {
+		if got := formatThroughput(tt.p); got != tt.want {
+			t.Errorf("formatThroughput() got = %q; want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDownloadObjects_sync(t *testing.T) {
+	captured := time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local)
+	c := &objectsClient{
+		mockClient: newMockClient(ptp.VE_MicrosoftCorporation),
+		objs: []ip.Object{
+			{Handle: 1, Info: &ptp.ObjectInfo{Filename: "DSCF0001.JPG", ObjectCompressedSize: 3, CaptureDate: captured}},
+			{Handle: 2, Info: &ptp.ObjectInfo{Filename: "DSCF0002.JPG", ObjectCompressedSize: 4, CaptureDate: captured}},
+		},
+	}
+	f := startFakeS3(t)
+
+	for _, dir := range []string{t.TempDir(), "s3://photos/studio"} {
+		out := make(chan string, 10)
+		res, err := downloadObjects(c, dir, nil, true, out)
+		if err != nil || !strings.HasPrefix(res, "2 of 2 objects downloaded to "+dir) {
+			t.Errorf("downloadObjects(%s) got = %s, %v; want 2 objects downloaded", dir, res, err)
+		}
+		// The catalog keeps the objects from being downloaded again.
+		res, err = downloadObjects(c, dir, nil, true, out)
+		if err != nil || !strings.Contains(res, "2 already downloaded") {
+			t.Errorf("downloadObjects(%s) got = %s, %v; want 2 objects already downloaded", dir, res, err)
+		}
+		if !strings.HasPrefix(dir, "s3://") {
+			if data, err := os.ReadFile(filepath.Join(dir, "DSCF0002.JPG")); string(data) != "xxxx" || err != nil {
+				t.Errorf("downloadObjects(%s) wrote %s, %v; want xxxx", dir, data, err)
+			}
+		}
+	}
+
+	for _, p := range []string{"/photos/studio/DSCF0001.JPG", "/photos/studio/DSCF0002.JPG", "/photos/studio/" + catalogFile} {
+		if _, ok := f.object(p); !ok {
+			t.Errorf("downloadObjects() did not upload %s", p)
+		}
+	}
+}
+
+func TestDownloadObjects_transcode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands require sh")
+	}
+	setExecTranscoders(map[string]string{"topng": `printf '\211PNG\r\n\032\n'`, "fail": "exit 1"})
+	defer setExecTranscoders(nil)
+
+	c := &objectsClient{
+		mockClient: newMockClient(ptp.VE_MicrosoftCorporation),
+		objs:       []ip.Object{{Handle: 1, Info: &ptp.ObjectInfo{Filename: "DSCF0001.JPG", ObjectCompressedSize: 3}}},
+	}
+	dir := t.TempDir()
+	out := make(chan string, 10)
+
+	if res, err := downloadObjects(c, dir, []string{"transcode=topng"}, false, out); err != nil || !strings.HasPrefix(res, "1 of 1 objects downloaded") {
+		t.Errorf("downloadObjects() got = %s, %v; want 1 object downloaded", res, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "DSCF0001.PNG")); err != nil || !strings.HasPrefix(string(data), "\x89PNG") {
+		t.Errorf("downloadObjects() wrote %q, %v; want the PNG output of the transcoder", data, err)
+	}
+
+	// A failing transcoder stores the object as is.
+	res, err := downloadObjects(c, dir, []string{"transcode=fail"}, false, out)
+	if err != nil || !strings.Contains(res, "1 transcodings failed") {
+		t.Errorf("downloadObjects() got = %s, %v; want 1 transcoding failed", res, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "DSCF0001.JPG")); string(data) != "xxx" || err != nil {
+		t.Errorf("downloadObjects() wrote %q, %v; want xxx", data, err)
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"fmt"
 	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
 	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strings"
 )
 
 func init() {
 	registerCommand(&get{})
 }
 
+// watchFlag is the argument making the get command print every change of the properties.
+const watchFlag = "--watch"
+
 type get struct{}
 
 func (get) name() string {
@@ -20,31 +25,131 @@ func (get) alias() []string {
 	return []string{}
 }
 
-func (get) execute(c *ip.Client, f []string, _ chan<- string) string {
+// longRunning returns true when watching the properties, which runs until it is stopped.
+func (get) longRunning(f []string) bool {
+	_, ok := hasFlag(f, watchFlag)
+
+	return ok
+}
+
+func (g get) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
 	errorFmt := "get error: %s\n"
 
-	cod, err := formatDeviceProperty(c, f[0])
-	if err != nil {
-		return fmt.Sprintf(errorFmt, err)
+	if f, preview := hasPreviewFlag(f); preview {
+		if len(f) < 1 {
+			return fmt.Sprintf(errorFmt, "missing object handle")
+		}
+		h, err := parseObjectHandle(f[0])
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		thumb, err := c.GetThumb(h)
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		img, err := termImage(thumb)
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		return img
+	}
+
+	f, watching := hasFlag(f, watchFlag)
+	if len(f) < 1 {
+		return fmt.Sprintf(errorFmt, "missing property")
+	}
+
+	codes := make([]ptp.DevicePropCode, len(f))
+	for i, param := range f {
+		cod, err := formatDeviceProperty(c, param)
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		codes[i] = cod
+	}
+
+	vendor := c.ResponderVendor()
+	allowed := make([]string, len(codes))
+	for i, cod := range codes {
+		allowed[i] = formatAllowedValues(vendor, describeDeviceProperty(c, cod))
+	}
+
+	if watching {
+		return g.watch(c, codes, allowed, asyncOut)
 	}
 
-	v, err := c.GetDevicePropertyValue(cod)
+	var res []string
+	for i, cod := range codes {
+		line := ""
+		if len(codes) > 1 {
+			line = f[i] + ": "
+		}
+		v, err := c.GetDevicePropertyValue(cod)
+		switch {
+		case err != nil && len(codes) == 1:
+			return fmt.Sprintf(errorFmt, err)
+		case err != nil:
+			line += "error " + err.Error()
+		default:
+			line += ptpfmt.DevicePropValAsString(vendor, cod, int64(v)) + fmt.Sprintf(" (%#x)", v)
+		}
+		res = append(res, line)
+		if allowed[i] != "" {
+			res = append(res, "  allowed: "+allowed[i])
+		}
+	}
+
+	return strings.Join(res, "\n")
+}
+
+// describeDeviceProperty returns the description of the device property, or nil when the camera cannot describe it.
+func describeDeviceProperty(c ip.ClientAPI, cod ptp.DevicePropCode) *ptp.DevicePropDesc {
+	if dpd := c.CachedDevicePropertyDescription(cod); dpd != nil {
+		return dpd
+	}
+
+	dpd, err := c.GetDevicePropertyDescription(cod)
 	if err != nil {
-		return fmt.Sprintf(errorFmt, err)
+		c.Debugf("Unable to describe property %#x: %s", cod, err)
+		return nil
 	}
 
-	return ptpfmt.DevicePropValAsString(c.ResponderVendor(), cod, int64(v)) + fmt.Sprintf(" (%#x)", v)
+	return dpd
+}
+
+// watch prints the current value of the device properties followed by every change until the program is interrupted.
+// The properties are read again as soon as the camera reports a change.
+func (get) watch(c ip.ClientAPI, codes []ptp.DevicePropCode, allowed []string, asyncOut chan<- string) string {
+	vendor := c.ResponderVendor()
+	stop := c.WatchProperties(codes, ip.DefaultWatchInterval, func(pc ip.PropertyChange) {
+		asyncOut <- propertyChangeLine(vendor, pc).String()
+		if !pc.Initial {
+			return
+		}
+		for i, cod := range codes {
+			if cod == pc.Code && allowed[i] != "" {
+				asyncOut <- "  allowed: " + allowed[i]
+			}
+		}
+	})
+	defer stop()
+
+	<-quit
+
+	return "get stopped\n"
 }
 
 func (g get) help() string {
-	help := `"` + g.name() + `" gets the current value for the given property.` + "\n"
+	help := `"` + g.name() + `" gets the current value for the given properties, followed by the values allowed by the camera when it can describe the property. When passing "` + watchFlag + `", every change of the properties is printed until the program is interrupted, the properties being read again as soon as the camera reports a change. When passing "` + previewFlag + `", it displays the thumbnail of the given object in the terminal instead, using the sixel, iTerm2 or kitty image protocol, which works over SSH.` + "\n"
 
 	if args := g.arguments(); len(args) > 0 {
 		help += helpAddArgumentsTitle()
 		for i, arg := range args {
 			switch i {
 			case 0:
-				help += "\t- " + arg + ": a hexadecimal field code in the form of '0x5001' or one of the supported unified field names:\n" + helpAddUnifiedFieldNames()
+				help += "\t- " + arg + ": one or more hexadecimal field codes in the form of '0x5001' or the supported unified field names:\n" + helpAddUnifiedFieldNames()
+			case 1:
+				help += "\t- " + arg + `: an object handle as listed by the "objects" command, to be used with "` + previewFlag + `"` + "\n"
 			}
 		}
 	}
@@ -53,5 +158,19 @@ func (g get) help() string {
 }
 
 func (get) arguments() []string {
-	return []string{"property"}
+	return []string{"property", "handle"}
+}
+
+func (get) usage() string {
+	return "get property... [" + watchFlag + "] | " + previewFlag + " handle"
+}
+
+func (get) examples() []string {
+	return []string{
+		"get 0x5007",
+		"get iso",
+		"get iso whitebalance exp-bias",
+		"get iso " + watchFlag,
+		"get " + previewFlag + " 0x1a",
+	}
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ptp"
+	"testing"
+)
+
+func TestGet_execute(t *testing.T) {
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+	c.props[ptp.DPC_WhiteBalance] = uint32(ptp.WB_Daylight)
+	c.props[ptp.DPC_ExposureIndex] = 400
+	c.descs[ptp.DPC_WhiteBalance] = &ptp.DevicePropDesc{DevicePropertyCode: ptp.DPC_WhiteBalance, Form: &ptp.EnumerationForm{
+		NumberOfValues:  2,
+		SupportedValues: [][]byte{{0x02, 0x00}, {0x04, 0x00}},
+	}}
+
+	check := map[string][]string{
+		"single":     {"0x5005"},
+		"multiple":   {"whitebalance", "0x5005", "0x500f"},
+		"unknown":    {"0x5005", "0xd0ff"},
+		"missing":    {"--watch"},
+		"not a prop": {"0x5005", "aperture"},
+	}
+	want := map[string]string{
+		"single":     "daylight (0x4)\n  allowed: automatic, daylight",
+		"multiple":   "whitebalance: daylight (0x4)\n  allowed: automatic, daylight\n0x5005: daylight (0x4)\n  allowed: automatic, daylight\n0x500f: 400 (0x190)",
+		"unknown":    "0x5005: daylight (0x4)\n  allowed: automatic, daylight\n0xd0ff: error device property not supported",
+		"missing":    "get error: missing property\n",
+		"not a prop": "get error: error converting: strconv.ParseUint: parsing \"aperture\": invalid syntax or unknown field name 'aperture'\n",
+	}
+	for name, args := range check {
+		if got := (get{}).execute(c, args, nil); got != want[name] {
+			t.Errorf("execute() %s got = %q; want %q", name, got, want[name])
+		}
+	}
+
+	if got, want := (get{}).execute(c, []string{"0xd0ff"}, nil), "get error: device property not supported\n"; got != want {
+		t.Errorf("execute() got = %q; want %q", got, want)
+	}
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"github.com/malc0mn/ptp-ip/ip"
-	"sort"
+	"strings"
 )
 
 func init() {
@@ -19,37 +19,41 @@ func (help) alias() []string {
 	return []string{}
 }
 
-func (help) execute(_ *ip.Client, f []string, _ chan<- string) string {
+func (help) execute(_ ip.ClientAPI, f []string, _ chan<- string) string {
 	if len(f) == 0 {
-		names := make([]string, 0, len(commands))
-		for name := range commands {
-			names = append(names, name)
-		}
-		sort.Strings(names)
-
 		txt := "\nSupported commands:\n\n"
-		for _, name := range names {
-			txt += commands[name].help() + "\n\n"
+		for _, name := range commandNames() {
+			cmd := commands[name]
+			txt += "  " + cmd.usage() + "\n"
+			txt += "\t" + strings.SplitN(cmd.help(), "\n", 2)[0] + "\n"
 		}
-		return txt
+		if names := macroNames(); len(names) > 0 {
+			txt += "\nMacros:\n\n"
+			for _, name := range names {
+				body, _ := macroByName(name)
+				txt += "  " + name + "\n\t" + body + "\n"
+			}
+		}
+		return txt + "\n" + `Use "help command" to get the arguments and examples of a single command.` + "\n"
 	}
 
-	if cmd, exists := commands[f[0]]; exists {
-		return "\n" + cmd.help()
+	cmd := commandByName(f[0])
+	if _, ok := cmd.(*unknown); ok {
+		return "\n" + cmd.execute(nil, nil, nil)
 	}
 
-	return "\nUnknown command " + f[0] + "!\n"
+	return "\nUsage: " + cmd.usage() + "\n\n" + cmd.help() + helpAddExamples(cmd.examples())
 }
 
 func (h help) help() string {
-	help := `"` + h.name() + `" displays help for all commands or for a single one.` + "\n"
+	help := `"` + h.name() + `" lists all commands or displays the usage, arguments and examples of a single one.` + "\n"
 
 	if args := h.arguments(); len(args) > 0 {
 		help += helpAddArgumentsTitle()
 		for i, arg := range args {
 			switch i {
 			case 0:
-				help += "\t- " + arg + " to get help for\n"
+				help += "\t- " + arg + " to get help for, aliases are accepted as well\n"
 			}
 		}
 	}
@@ -60,3 +64,14 @@ func (h help) help() string {
 func (help) arguments() []string {
 	return []string{"command"}
 }
+
+func (help) usage() string {
+	return "help [command]"
+}
+
+func (help) examples() []string {
+	return []string{
+		"help",
+		"help capture",
+	}
+}
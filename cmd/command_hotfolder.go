@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand(&hotfolder{})
+}
+
+// defaultHotFolderInterval is the interval at which the hot folder is scanned when none is given.
+const defaultHotFolderInterval = 2 * time.Second
+
+// fileStamp identifies a version of a file in the hot folder.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// hotFolder keeps track of the files in a local folder to find the new ones. A file is only considered to be complete
+// when its size and modification time did not change between two scans, so that files that are still being written are
+// not picked up.
+type hotFolder struct {
+	dir string
+	// seen holds the files that have been handed out by scan, or that were present when the hot folder was created.
+	seen map[string]fileStamp
+	// pending holds the new files found by the last scan.
+	pending map[string]fileStamp
+}
+
+// newHotFolder returns a hotFolder for dir, the files currently present being considered to be old.
+func newHotFolder(dir string) (*hotFolder, error) {
+	h := &hotFolder{dir: dir, seen: make(map[string]fileStamp), pending: make(map[string]fileStamp)}
+
+	files, err := h.files()
+	if err != nil {
+		return nil, err
+	}
+	h.seen = files
+
+	return h, nil
+}
+
+// files lists the regular files in the folder. Hidden files and the temporary files written by the download commands
+// are left out.
+func (h *hotFolder) files() (map[string]fileStamp, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]fileStamp)
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), ".part") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files[e.Name()] = fileStamp{size: fi.Size(), modTime: fi.ModTime()}
+	}
+
+	return files, nil
+}
+
+// scan returns the names of the files that are new or have changed since they were last handed out, and that are
+// complete, sorted by name.
+func (h *hotFolder) scan() ([]string, error) {
+	files, err := h.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var ready []string
+	pending := make(map[string]fileStamp)
+	for name, stamp := range files {
+		if old, ok := h.seen[name]; ok && old == stamp {
+			continue
+		}
+		if prev, ok := h.pending[name]; ok && prev == stamp {
+			ready = append(ready, name)
+			h.seen[name] = stamp
+			continue
+		}
+		pending[name] = stamp
+	}
+	h.pending = pending
+	sort.Strings(ready)
+
+	return ready, nil
+}
+
+// uploadTarget returns the store and the folder to upload to for the given slash separated path on the camera. The
+// root of the first store is used for the / path.
+func uploadTarget(c ip.ClientAPI, p string) (ptp.StorageID, ptp.ObjectHandle, error) {
+	if strings.Trim(p, "/") != "" {
+		n, err := c.ObjectTree(ip.AllStorages).Lookup(p)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !n.IsFolder() {
+			return 0, 0, fmt.Errorf("%s is not a folder", p)
+		}
+		return n.Info.StorageID, n.Handle, nil
+	}
+
+	sids, err := c.GetStorageIDs()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(sids) == 0 {
+		return 0, 0, errors.New("the camera has no stores")
+	}
+
+	return sids[0], ip.RootObjects, nil
+}
+
+// uploadFile sends the local file to the given store and folder of the camera, returning the handle of the new
+// object.
+func uploadFile(c ip.ClientAPI, path string, sid ptp.StorageID, parent ptp.ObjectHandle) (ptp.ObjectHandle, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() > int64(^uint32(0)) {
+		return 0, fmt.Errorf("%s is larger than 4GB", filepath.Base(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	oi := &ptp.ObjectInfo{
+		StorageID:            sid,
+		ObjectFormat:         ip.ObjectFormatOf(path),
+		ObjectCompressedSize: uint32(len(data)),
+		ParentObject:         parent,
+		Filename:             filepath.Base(path),
+		ModificationDate:     fi.ModTime(),
+	}
+	if parent == ip.RootObjects {
+		oi.ParentObject = 0
+	}
+
+	return c.SendObject(sid, parent, oi, data)
+}
+
+type hotfolder struct{}
+
+func (hotfolder) name() string {
+	return "hotfolder"
+}
+
+func (hotfolder) alias() []string {
+	return []string{"upload"}
+}
+
+// longRunning always returns true: hotfolder runs until it is stopped.
+func (hotfolder) longRunning(_ []string) bool {
+	return true
+}
+
+func (hf hotfolder) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	errorFmt := "hotfolder error: %s\n"
+
+	if len(f) < 1 {
+		return fmt.Sprintf(errorFmt, "missing directory")
+	}
+	dir, target, interval := f[0], "/", defaultHotFolderInterval
+	for _, arg := range f[1:] {
+		if strings.HasPrefix(arg, "/") {
+			target = arg
+			continue
+		}
+		d, err := parseDuration(arg)
+		if err != nil || d <= 0 {
+			return fmt.Sprintf(errorFmt, fmt.Sprintf("invalid interval %s", arg))
+		}
+		interval = d
+	}
+
+	sid, parent, err := uploadTarget(c, target)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	h, err := newHotFolder(dir)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	asyncOut <- fmt.Sprintf("watching %s, uploading new files to %s", dir, target)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var count, failed int
+	for {
+		select {
+		case <-ticker.C:
+			names, err := h.scan()
+			if err != nil {
+				return fmt.Sprintf(errorFmt, err)
+			}
+			for _, name := range names {
+				oh, err := uploadFile(c, filepath.Join(dir, name), sid, parent)
+				if err != nil {
+					failed++
+					asyncOut <- fmt.Sprintf("%s: %s", name, err)
+					continue
+				}
+				count++
+				asyncOut <- fmt.Sprintf("%s: uploaded as object %#x", name, uint32(oh))
+			}
+		case <-quit:
+			res := fmt.Sprintf("hotfolder stopped, %d files uploaded", count)
+			if failed > 0 {
+				res += fmt.Sprintf(", %d failed", failed)
+			}
+			return res + "\n"
+		}
+	}
+}
+
+func (hf hotfolder) help() string {
+	help := `"` + hf.name() + `" watches a local directory and uploads the files added to it to the camera using the SendObject operation until the program is interrupted. The files present when the command starts are not uploaded. A file is uploaded once its size stopped changing, a file that is replaced being uploaded again. Hidden files are ignored.` + "\n"
+	help += helpAddAliases(hf.alias())
+
+	if args := hf.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		help += "\t- the " + args[0] + " to watch\n"
+		help += "\t- the " + args[1] + " of the folder on the camera to upload to, e.g. /DCIM/100_FUJI, which defaults to the root of the first store\n"
+		help += "\t- the " + args[2] + " at which the directory is scanned, e.g. 500ms or a number of seconds, which defaults to " + defaultHotFolderInterval.String() + "\n"
+	}
+
+	return help
+}
+
+func (hotfolder) arguments() []string {
+	return []string{"directory", "/path", "interval"}
+}
+
+func (hotfolder) usage() string {
+	return "hotfolder directory [/path] [interval]"
+}
+
+func (hotfolder) examples() []string {
+	return []string{
+		"hotfolder /tmp/outbox",
+		"hotfolder /tmp/outbox /DCIM/100_FUJI",
+		"upload /tmp/outbox /DCIM 500ms",
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHotFolder_scan(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string, mod time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mod := time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local)
+	write("old.jpg", "old", mod)
+
+	h, err := newHotFolder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("b.jpg", "b", mod)
+	write("a.jpg", "a", mod)
+	write(".hidden", "h", mod)
+	write("c.jpg.part", "c", mod)
+
+	scans := [][]string{nil, {"a.jpg", "b.jpg"}, nil}
+	for i, want := range scans {
+		got, err := h.scan()
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("scan() %d got = %v, %v; want %v, <nil>", i, got, err, want)
+		}
+	}
+
+	// A file still being written is only picked up once it stopped changing.
+	write("old.jpg", "new", mod.Add(time.Second))
+	write("d.jpg", "d", mod)
+	h.scan()
+	write("d.jpg", "dd", mod.Add(time.Second))
+	got, _ := h.scan()
+	if want := []string{"old.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scan() got = %v; want %v", got, want)
+	}
+	got, _ = h.scan()
+	if want := []string{"d.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scan() got = %v; want %v", got, want)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "DSCF0001.JPG")
+	if err := os.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newMockClient(ptp.VE_FujiPhotoFilmCoLtd)
+	h, err := uploadFile(c, path, 0x10001, ip.RootObjects)
+	if err != nil || h != 0x42 {
+		t.Errorf("uploadFile() got = %#x, %v; want 0x42, <nil>", h, err)
+	}
+	want := []uint32{0x10001, uint32(ip.RootObjects), uint32(ptp.OFC_EXIF_JPEG), 4}
+	if got := c.requests[ptp.OC_SendObjectInfo]; !reflect.DeepEqual(got, want) {
+		t.Errorf("uploadFile() SendObjectInfo got = %#x; want %#x", got, want)
+	}
+	if got := c.requests[ptp.OC_SendObject]; !reflect.DeepEqual(got, []uint32{4}) {
+		t.Errorf("uploadFile() SendObject got = %v; want [4]", got)
+	}
+}
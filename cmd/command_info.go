@@ -18,7 +18,7 @@ func (info) alias() []string {
 	return []string{}
 }
 
-func (info) execute(c *ip.Client, f []string, _ chan<- string) string {
+func (info) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
 	res, err := c.GetDeviceInfo()
 
 	if err != nil {
@@ -49,3 +49,14 @@ func (i info) help() string {
 func (info) arguments() []string {
 	return []string{"json", "pretty"}
 }
+
+func (info) usage() string {
+	return "info [json [pretty]]"
+}
+
+func (info) examples() []string {
+	return []string{
+		"info",
+		"info json pretty",
+	}
+}
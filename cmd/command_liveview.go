@@ -11,16 +11,48 @@ import (
 	"github.com/malc0mn/ptp-ip/ptp"
 	"github.com/malc0mn/ptp-ip/viewfinder"
 	"image"
-	"image/draw"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	lvState   bool
+	lvState bool
+	// lvHistogram holds the viewfinder.HistogramMode and can be changed while the live view is running.
+	lvHistogram int32
+	// lvZoom holds the digital punch-in zoom settings and can be changed while the live view is running.
+	lvZoom    = punchIn{factor: 1, cx: 0.5, cy: 0.5}
 	mainStack = make(chan func())
+	// lvReviews passes the captured images to review to the live view window.
+	lvReviews = make(chan *image.RGBA, 1)
 )
 
+// punchIn holds the digital zoom factor and the center of the zoomed region as a fraction of the image size.
+type punchIn struct {
+	factor float64
+	cx     float64
+	cy     float64
+	mu     sync.Mutex
+}
+
+func (p *punchIn) set(factor, cx, cy float64) {
+	p.mu.Lock()
+	p.factor, p.cx, p.cy = factor, cx, cy
+	p.mu.Unlock()
+}
+
+func (p *punchIn) apply(img *image.RGBA) *image.RGBA {
+	p.mu.Lock()
+	factor, cx, cy := p.factor, p.cx, p.cy
+	p.mu.Unlock()
+
+	return viewfinder.PunchIn(img, factor, cx, cy)
+}
+
 func init() {
+	buildTags = append(buildTags, "with_lv")
 	registerCommand(&liveview{})
 }
 
@@ -34,13 +66,41 @@ func (liveview) alias() []string {
 	return []string{}
 }
 
-func (l liveview) execute(c *ip.Client, f []string, _ chan<- string) string {
+func (l liveview) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
 	errorFmt := "liveview error: %s\n"
 
-	if lvState {
+	if isLvStop(f) {
+		return stopLiveviewStream(c)
+	}
+
+	if l.isHistogram(f) {
+		return l.toggleHistogram(f[1:])
+	}
+
+	if l.isZoom(f) {
+		return l.zoom(c, f[1:])
+	}
+
+	if l.isTheme(f) {
+		return l.theme(f[1:])
+	}
+
+	if l.isReview(f) {
+		return l.review(f[1:])
+	}
+
+	if lvState || lvStreams != nil {
 		return "already enabled!\n"
 	}
 
+	if isLvStream(f) {
+		return startLiveviewStream(c, f)
+	}
+
+	if lvDecoder == noneFrameDecoder {
+		return fmt.Sprintf(errorFmt, errFrameDecodingDisabled)
+	}
+
 	lvState = true
 
 	if err := c.ToggleLiveView(lvState); err != nil {
@@ -52,7 +112,9 @@ func (l liveview) execute(c *ip.Client, f []string, _ chan<- string) string {
 		withVf = !l.isNoVf(f[0])
 	}
 
-	runOnMain(func() { liveViewUI(c, withVf) })
+	// The window only cares about the most recent frame.
+	sub := c.SubscribeLiveview(1, ip.DropOldest)
+	runOnMain(func() { liveViewUI(c, sub, withVf) })
 
 	return "enabled\n"
 }
@@ -66,6 +128,17 @@ func (l liveview) help() string {
 			switch i {
 			case 0:
 				help += "\t- " + `"` + arg + `" disables the viewfinder overlay which eliminates camera state polling` + "\n"
+			case 1:
+				help += "\t- " + `"` + arg + ` [luma|rgb|off]" toggles the histogram overlay, also while the live view is running. Without a mode, the luminance histogram is toggled on or off` + "\n"
+			case 2:
+				help += "\t- " + `"` + arg + ` factor [x y]" digitally magnifies the live view for critical focus checking, also while it is running. x and y set the center of the magnified region as a fraction of the frame size and default to "0.5 0.5". Use a factor of 1 to zoom out again` + "\n"
+				help += "\t- " + `"` + arg + ` native level" drives the native live view magnification of the camera, if it supports it. Level 0 disables the magnification` + "\n"
+			case 3:
+				help += "\t- " + `"` + arg + ` [setting value]" changes the colours and font faces of the viewfinder overlay, also while the live view is running. Without a setting, the current theme is listed. The settings are ` + strings.Join(themeKeys, ", ") + `. Use "` + arg + ` reset" to restore the default theme` + "\n"
+			case 4:
+				help += "\t- " + `"` + arg + ` [duration|off]" shows each captured image, or the thumbnail of each object added to the camera, in the live view window for the given duration, e.g. 2s, before returning to the live view. Without a duration, the current one is shown` + "\n\tOR\n"
+			default:
+				help += helpLiveviewStreamArg(arg)
 			}
 		}
 	}
@@ -74,13 +147,171 @@ func (l liveview) help() string {
 }
 
 func (liveview) arguments() []string {
-	return []string{"novf"}
+	return []string{"novf", "histogram", "zoom", "theme", "review", lvHttpArg, lvRtspArg, lvRecArg, lvWebcamArg, lvFpsArg, lvStopArg}
+}
+
+func (liveview) usage() string {
+	return "liveview [novf] | liveview histogram [luma|rgb|off] | liveview zoom factor [x y] | liveview zoom native level | " +
+		"liveview theme [setting value | reset] | liveview review [duration|off] | liveview [" + lvHttpArg + " address] [" + lvRtspArg + " address] [" +
+		lvRecArg + " directory] [" + lvWebcamArg + " device] [" + lvFpsArg + " rate] | liveview " + lvStopArg
+}
+
+func (liveview) examples() []string {
+	return []string{
+		"liveview",
+		"liveview histogram rgb",
+		"liveview zoom 4 0.25 0.75",
+		"liveview theme warning #ff8000",
+		"liveview review 2s",
+		"liveview " + lvHttpArg + " :8080 " + lvFpsArg + " 10",
+		"liveview " + lvWebcamArg + " /dev/video10",
+		"liveview " + lvStopArg,
+	}
 }
 
 func (l liveview) isNoVf(param string) bool {
 	return param == l.arguments()[0]
 }
 
+func (l liveview) isHistogram(f []string) bool {
+	return len(f) >= 1 && f[0] == l.arguments()[1]
+}
+
+func (l liveview) isZoom(f []string) bool {
+	return len(f) >= 1 && f[0] == l.arguments()[2]
+}
+
+func (l liveview) isTheme(f []string) bool {
+	return len(f) >= 1 && f[0] == l.arguments()[3]
+}
+
+func (l liveview) isReview(f []string) bool {
+	return len(f) >= 1 && f[0] == l.arguments()[4]
+}
+
+// review shows or sets the duration captured images are reviewed for in the live view window.
+func (liveview) review(f []string) string {
+	if len(f) >= 1 {
+		if err := setReviewDuration(f[0]); err != nil {
+			return fmt.Sprintf("liveview error: %s\n", err)
+		}
+	}
+
+	if d := reviewDuration(); d > 0 {
+		return fmt.Sprintf("captured images are reviewed for %s\n", d)
+	}
+
+	return "capture review disabled\n"
+}
+
+// reviewCapture hands the captured image to the live view window to review it, when the window is open and the review
+// is enabled. An image still waiting to be shown is replaced.
+func reviewCapture(img []byte) {
+	if !lvState || reviewDuration() == 0 || len(img) == 0 {
+		return
+	}
+
+	rgba, err := lvDecoder.decoder().decode(img)
+	if err != nil {
+		return
+	}
+
+	for {
+		select {
+		case lvReviews <- rgba:
+			return
+		default:
+			select {
+			case <-lvReviews:
+			default:
+			}
+		}
+	}
+}
+
+// theme lists the viewfinder theme, changes a single setting of it or restores the default theme.
+func (liveview) theme(f []string) string {
+	switch {
+	case len(f) == 0:
+		return vfTheme.String()
+	case f[0] == "reset":
+		vfTheme.reset()
+		return "default theme restored\n"
+	case len(f) < 2:
+		return fmt.Sprintf("liveview error: missing value for theme setting %s\n", f[0])
+	}
+
+	if err := vfTheme.set(f[0], f[1]); err != nil {
+		return fmt.Sprintf("liveview error: %s\n", err)
+	}
+
+	return fmt.Sprintf("theme setting %s set to %s\n", f[0], f[1])
+}
+
+// zoom sets the digital punch-in zoom or, when the first argument is "native", the magnification of the camera.
+func (liveview) zoom(c ip.ClientAPI, f []string) string {
+	errorFmt := "liveview error: %s\n"
+
+	if len(f) >= 1 && f[0] == "native" {
+		if len(f) < 2 {
+			return fmt.Sprintf(errorFmt, "missing zoom level")
+		}
+		lvl, err := strconv.Atoi(f[1])
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		if lvl, err = c.SetLiveviewZoom(lvl); err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+
+		return fmt.Sprintf("native zoom level set to %d\n", lvl)
+	}
+
+	vals := []float64{2, 0.5, 0.5}
+	for i := 0; i < len(f) && i < len(vals); i++ {
+		v, err := strconv.ParseFloat(f[i], 64)
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		vals[i] = v
+	}
+	if vals[0] < 1 || vals[1] < 0 || vals[1] > 1 || vals[2] < 0 || vals[2] > 1 {
+		return fmt.Sprintf(errorFmt, "the factor must be at least 1 and the center must lie between 0 and 1")
+	}
+	lvZoom.set(vals[0], vals[1], vals[2])
+
+	if vals[0] == 1 {
+		return "zoom disabled\n"
+	}
+
+	return fmt.Sprintf("zoomed in %gx on %g, %g\n", vals[0], vals[1], vals[2])
+}
+
+// toggleHistogram sets the histogram mode to the one given or toggles the luminance histogram when none is given.
+func (liveview) toggleHistogram(f []string) string {
+	mode := viewfinder.HistogramLuma
+	if len(f) >= 1 {
+		switch f[0] {
+		case "luma":
+		case "rgb":
+			mode = viewfinder.HistogramRGB
+		case "off":
+			mode = viewfinder.HistogramOff
+		default:
+			return fmt.Sprintf("liveview error: unknown histogram mode %s\n", f[0])
+		}
+	} else if viewfinder.HistogramMode(atomic.LoadInt32(&lvHistogram)) != viewfinder.HistogramOff {
+		mode = viewfinder.HistogramOff
+	}
+	atomic.StoreInt32(&lvHistogram, int32(mode))
+
+	if mode == viewfinder.HistogramOff {
+		return "histogram disabled\n"
+	}
+
+	return "histogram enabled\n"
+}
+
 // mainThread is used to execute on the main thread, which is what OpenGL requires.
 func mainThread() {
 	for {
@@ -98,7 +329,9 @@ func runOnMain(f func()) {
 	mainStack <- f
 }
 
-func liveViewUI(c *ip.Client, withVf bool) error {
+func liveViewUI(c ip.ClientAPI, sub *ip.FrameSubscription, withVf bool) error {
+	defer sub.Close()
+
 	if err := gl.Init(); err != nil {
 		return err
 	}
@@ -108,48 +341,110 @@ func liveViewUI(c *ip.Client, withVf bool) error {
 	}
 	defer glfw.Terminate()
 
-	img := <-c.StreamChan
-	window, err := showImage(img, "Live view")
+	dec := lvDecoder.decoder()
+	frame, ok := <-sub.Frames()
+	if !ok {
+		return nil
+	}
+	first, err := dec.decode(frame.Data)
+	frame.Release()
 	if err != nil {
 		return err
 	}
+	window, err := newWindow(first, "Live view")
+	if err != nil {
+		return err
+	}
+	window.draw()
 
 	// TODO: add support to allow toggling the viewfinder on or off.
 	var (
-		vf *viewfinder.Viewfinder
-		s  interface{}
+		vf    *viewfinder.Viewfinder
+		s     interface{}
+		vfGen uint64
 	)
 	ticker := time.NewTicker(1 * time.Second)
+	// changed is signalled when the camera reports a property change, such as the focus point being moved, so that the
+	// viewfinder is updated right away rather than at the next tick.
+	var changed chan struct{}
 	if withVf {
 		s, err = c.GetDeviceState()
 		if err != nil {
 			s = []*ptp.DevicePropDesc{}
 		}
 
-		im, _, err := image.Decode(bytes.NewReader(img))
-		if err == nil {
-			vf = viewfinder.NewViewfinder(toRGBA(im), c.ResponderVendor())
-		}
+		changed = make(chan struct{}, 1)
+		removeHandler := c.OnEvent(func(p ip.EventPacket) {
+			if p.GetEventCode() != ptp.EC_DevicePropChanged {
+				return
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+		defer removeHandler()
+
+		var t viewfinder.Theme
+		t, vfGen = vfTheme.get()
+		vf = viewfinder.NewThemedViewfinder(first, c.ResponderVendor(), t)
 	} else {
 		ticker.Stop()
 	}
 
+	// Also review the objects added to the camera, e.g. when the shutter is released on the camera itself, using their
+	// thumbnail.
+	removeReviewHandler := c.OnEvent(func(p ip.EventPacket) {
+		if p.GetEventCode() != ptp.EC_ObjectAdded || reviewDuration() == 0 {
+			return
+		}
+		go func(h ptp.ObjectHandle) {
+			if thumb, err := c.GetThumb(h); err == nil {
+				reviewCapture(thumb)
+			}
+		}(ptp.ObjectHandle(p.GetParameter1()))
+	})
+	defer removeReviewHandler()
+	// reviewUntil holds the time up until which a captured image is shown instead of the live view frames.
+	var reviewUntil time.Time
+
 poller:
 	for !window.ShouldClose() {
 		select {
-		case img := <-c.StreamChan:
-			im, _, err := image.Decode(bytes.NewReader(img))
+		case frame, ok := <-sub.Frames():
+			if !ok {
+				break poller
+			}
+			if time.Now().Before(reviewUntil) {
+				frame.Release()
+				break
+			}
+			rgba, err := dec.decode(frame.Data)
+			// The decoded image is all we need, so hand the buffer back for the frames to come.
+			frame.Release()
 			if err == nil {
-				rgba := toRGBA(im)
+				// Compute the histogram before anything is drawn on top of the image.
+				hist := viewfinder.NewHistogram(rgba, viewfinder.HistogramMode(atomic.LoadInt32(&lvHistogram)))
+				rgba = lvZoom.apply(rgba)
+				// Rebuild the viewfinder when the theme has been changed.
+				if t, gen := vfTheme.get(); vf != nil && gen != vfGen {
+					vf, vfGen = viewfinder.NewThemedViewfinder(rgba, c.ResponderVendor(), t), gen
+				}
 				if vf != nil {
 					if data, ok := s.([]*ptp.DevicePropDesc); ok {
 						viewfinder.DrawViewfinder(vf, rgba, data)
 					}
 				}
+				viewfinder.DrawHistogram(rgba, hist)
 				window.setImage(rgba)
 			}
 		case <-ticker.C:
 			s, _ = c.GetDeviceState()
+		case <-changed:
+			s, _ = c.GetDeviceState()
+		case rgba := <-lvReviews:
+			window.setImage(rgba)
+			reviewUntil = time.Now().Add(reviewDuration())
 		case <-quit:
 			break poller
 		}
@@ -165,16 +460,6 @@ poller:
 	return nil
 }
 
-func toRGBA(img image.Image) *image.RGBA {
-	rgba, ok := img.(*image.RGBA)
-	if !ok {
-		rgba = image.NewRGBA(img.Bounds())
-		draw.Draw(rgba, rgba.Rect, img, image.Point{}, draw.Src)
-	}
-
-	return rgba
-}
-
 func preview(img []byte) string {
 	// TODO: figure out how to cleanly have multiple windows open at the same time 'on the main thread' by introducing some
 	//  sort of extremely simple window manager.
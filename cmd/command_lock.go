@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+)
+
+func init() {
+	registerCommand(&lock{})
+	registerCommand(&unlock{})
+}
+
+const notShared = "only available in server mode"
+
+type lock struct{}
+
+func (lock) name() string {
+	return "lock"
+}
+
+func (lock) alias() []string {
+	return []string{}
+}
+
+func (l lock) execute(c ip.ClientAPI, _ []string, _ chan<- string) string {
+	sc, ok := sharedClientOf(c)
+	if !ok {
+		return fmt.Sprintf("lock error: %s\n", notShared)
+	}
+	if err := sc.q.lock(sc.owner); err != nil {
+		return fmt.Sprintf("lock error: %s\n", err)
+	}
+
+	return "camera locked\n"
+}
+
+func (l lock) help() string {
+	return `"` + l.name() + `" locks the camera in server mode: destructive commands, such as deleting objects or formatting a memory card, are only executed for the client holding the lock. Other commands can still be executed by all clients. The lock is released using "unlock" or when the client holding it disconnects.` + "\n"
+}
+
+func (lock) arguments() []string {
+	return []string{}
+}
+
+func (lock) usage() string {
+	return "lock"
+}
+
+func (lock) examples() []string {
+	return []string{"lock"}
+}
+
+type unlock struct{}
+
+func (unlock) name() string {
+	return "unlock"
+}
+
+func (unlock) alias() []string {
+	return []string{}
+}
+
+func (unlock) execute(c ip.ClientAPI, _ []string, _ chan<- string) string {
+	sc, ok := sharedClientOf(c)
+	if !ok {
+		return fmt.Sprintf("unlock error: %s\n", notShared)
+	}
+	if err := sc.q.unlock(sc.owner); err != nil {
+		return fmt.Sprintf("unlock error: %s\n", err)
+	}
+
+	return "camera unlocked\n"
+}
+
+func (u unlock) help() string {
+	return `"` + u.name() + `" releases the lock taken using "lock".` + "\n"
+}
+
+func (unlock) arguments() []string {
+	return []string{}
+}
+
+func (unlock) usage() string {
+	return "unlock"
+}
+
+func (unlock) examples() []string {
+	return []string{"unlock"}
+}
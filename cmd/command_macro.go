@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	macrosMu sync.RWMutex
+	// macros maps the name of each macro to its body: a sequence of commands separated by semicolons.
+	macros = make(map[string]string)
+	// configMacros holds the names of the macros defined in the config file. Guarded by macrosMu.
+	configMacros = make(map[string]bool)
+)
+
+func init() {
+	registerCommand(&macro{})
+}
+
+// defineMacro adds a macro or replaces an existing one. A macro cannot take the name of a command or of an alias.
+func defineMacro(name, body string) error {
+	if err := checkMacro(name, body); err != nil {
+		return err
+	}
+
+	macrosMu.Lock()
+	macros[name] = body
+	macrosMu.Unlock()
+
+	return nil
+}
+
+// checkMacro returns an error when the macro cannot be defined, see defineMacro.
+func checkMacro(name, body string) error {
+	if name == "" || strings.ContainsAny(name, " \t;$") {
+		return fmt.Errorf("invalid macro name %q", name)
+	}
+	if isCommand(name) {
+		return fmt.Errorf("%s is a command, it cannot be used as a macro name", name)
+	}
+	_, err := parseScript(macroScript(body))
+
+	return err
+}
+
+// setConfigMacros defines the macros found in the config file, which must have been checked using checkMacro. The
+// macros defined by an earlier version of the config file that are no longer in it are deleted, the macros defined
+// using the macro command are left alone.
+func setConfigMacros(defs map[string]string) {
+	macrosMu.Lock()
+	defer macrosMu.Unlock()
+
+	for name := range configMacros {
+		if _, ok := defs[name]; !ok {
+			delete(macros, name)
+			delete(configMacros, name)
+		}
+	}
+	for name, body := range defs {
+		macros[name] = body
+		configMacros[name] = true
+	}
+}
+
+func deleteMacro(name string) bool {
+	macrosMu.Lock()
+	defer macrosMu.Unlock()
+
+	_, ok := macros[name]
+	delete(macros, name)
+
+	return ok
+}
+
+func macroByName(name string) (string, bool) {
+	macrosMu.RLock()
+	defer macrosMu.RUnlock()
+
+	body, ok := macros[name]
+
+	return body, ok
+}
+
+// macroNames returns the names of all macros, sorted alphabetically.
+func macroNames() []string {
+	macrosMu.RLock()
+	defer macrosMu.RUnlock()
+
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// macroScript turns the body of a macro into a script, so a macro can do anything a script can.
+func macroScript(body string) []byte {
+	return []byte(strings.ReplaceAll(body, ";", "\n"))
+}
+
+// runMacro is what commandByName returns for a macro: it executes the commands of the macro in order.
+type runMacro struct {
+	macro string
+	body  string
+}
+
+func (m runMacro) name() string {
+	return m.macro
+}
+
+func (runMacro) alias() []string {
+	return []string{}
+}
+
+func (m runMacro) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	res, _ := m.run(c, f, asyncOut)
+
+	return res
+}
+
+// run executes the commands of the macro, returning the error that stopped them so that a script executing the macro
+// stops as well.
+func (m runMacro) run(c ip.ClientAPI, f []string, asyncOut chan<- string) (string, error) {
+	w := bufio.NewWriter(asyncWriter{out: asyncOut})
+	if err := runScriptSource("macro "+m.macro, macroScript(m.body), f, w, c); err != nil {
+		return fmt.Sprintf("macro error: %s\n", err), err
+	}
+
+	return "", nil
+}
+
+func (m runMacro) help() string {
+	return `"` + m.macro + `" is a macro executing: ` + m.body + "\n"
+}
+
+func (runMacro) arguments() []string {
+	return []string{}
+}
+
+func (m runMacro) usage() string {
+	return m.macro + " [argument...]"
+}
+
+func (runMacro) examples() []string {
+	return []string{}
+}
+
+type macro struct{}
+
+func (macro) name() string {
+	return "macro"
+}
+
+func (macro) alias() []string {
+	return []string{}
+}
+
+func (m macro) execute(_ ip.ClientAPI, f []string, _ chan<- string) string {
+	errorFmt := "macro error: %s\n"
+
+	if len(f) == 0 {
+		names := macroNames()
+		if len(names) == 0 {
+			return "no macros defined\n"
+		}
+		var res string
+		for _, name := range names {
+			body, _ := macroByName(name)
+			res += name + " = " + body + "\n"
+		}
+		return res
+	}
+
+	if m.isDelete(f[0]) {
+		if len(f) < 2 {
+			return fmt.Sprintf(errorFmt, "missing macro name")
+		}
+		if !deleteMacro(f[1]) {
+			return fmt.Sprintf(errorFmt, "unknown macro "+f[1])
+		}
+		return fmt.Sprintf("macro %s deleted\n", f[1])
+	}
+
+	if len(f) == 1 {
+		body, ok := macroByName(f[0])
+		if !ok {
+			return fmt.Sprintf(errorFmt, "unknown macro "+f[0])
+		}
+		return f[0] + " = " + body + "\n"
+	}
+
+	if err := defineMacro(f[0], strings.Join(f[1:], " ")); err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	return fmt.Sprintf("macro %s defined\n", f[0])
+}
+
+func (m macro) help() string {
+	help := `"` + m.name() + `" defines a macro executing a sequence of commands, separated by semicolons. Once defined, the macro is executed by using its name as a command. Any arguments given are available to the commands as $1, $2 and so on. Without arguments, all macros are listed. Macros can also be defined in the [macros] section of the config file.` + "\n"
+
+	if args := m.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		for i, arg := range args {
+			switch i {
+			case 0:
+				help += "\t- the " + arg + " of the macro. When no commands follow, the macro is displayed\n"
+			case 1:
+				help += "\t- the " + arg + " to execute, separated by semicolons. Anything allowed in a script can be used\n\tOR\n"
+			case 2:
+				help += "\t- " + `"` + arg + ` name" to delete a macro` + "\n"
+			}
+		}
+	}
+
+	return help
+}
+
+func (macro) arguments() []string {
+	return []string{"name", "commands", "delete"}
+}
+
+func (macro) usage() string {
+	return "macro [name [commands]] | macro delete name"
+}
+
+func (macro) examples() []string {
+	return []string{
+		"macro studio set iso 0xc8; set 0x5007 0x320; set whitebalance 0x4",
+		"macro burst capture $1; sleep 1; capture $1",
+		"studio",
+		"burst 3",
+		"macro delete burst",
+	}
+}
+
+func (m macro) isDelete(param string) bool {
+	return param == m.arguments()[2]
+}
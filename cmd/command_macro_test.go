@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
+	"testing"
+)
+
+func TestMacro(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deleteMacro("helpme")
+
+	m := macro{}
+	if got := m.execute(c, []string{"helpme", "echo", "arg", "$1;", "help", "get"}, nil); got != "macro helpme defined\n" {
+		t.Errorf("execute() got = %s; want macro helpme defined", got)
+	}
+	if got := m.execute(c, []string{"helpme"}, nil); got != "helpme = echo arg $1; help get\n" {
+		t.Errorf("execute() got = %s; want the macro body", got)
+	}
+
+	if _, ok := commandByName("helpme").(*runMacro); !ok {
+		t.Fatalf("commandByName() got = %T; want *runMacro", commandByName("helpme"))
+	}
+
+	var b bytes.Buffer
+	executeCommand("helpme one", bufio.NewWriter(&b), c, "test")
+	if got := b.String(); !strings.HasPrefix(got, "arg one\n") || !strings.Contains(got, "Usage: get property") {
+		t.Errorf("executeCommand() got = %s; want the output of both commands", got)
+	}
+
+	for _, name := range []string{"get", "shoot", "has space", ""} {
+		if err := defineMacro(name, "help"); err == nil {
+			t.Errorf("defineMacro(%s) err = nil; want error", name)
+		}
+	}
+	if err := defineMacro("broken", "if 1; help"); err == nil {
+		t.Error("defineMacro() err = nil; want if without end error")
+	}
+
+	if got := m.execute(c, []string{"delete", "helpme"}, nil); got != "macro helpme deleted\n" {
+		t.Errorf("execute() got = %s; want macro helpme deleted", got)
+	}
+	if _, ok := commandByName("helpme").(*unknown); !ok {
+		t.Errorf("commandByName() got = %T; want *unknown", commandByName("helpme"))
+	}
+}
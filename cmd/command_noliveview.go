@@ -20,16 +20,47 @@ func (liveview) alias() []string {
 	return []string{}
 }
 
-func (liveview) execute(_ *ip.Client, _ []string, _ chan<- string) string {
+func (liveview) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
+	if isLvStop(f) {
+		return stopLiveviewStream(c)
+	}
+
+	if isLvStream(f) {
+		return startLiveviewStream(c, f)
+	}
+
 	return nolv + "\n"
 }
 
 func (l liveview) help() string {
-	return `"` + l.name() + `" is not supported in this build!`
+	help := `"` + l.name() + `" can only stream the live view over HTTP or RTSP, record it to disk or feed it to a virtual webcam in this build, no window can be opened!` + "\n"
+
+	if args := l.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		for _, arg := range args {
+			help += helpLiveviewStreamArg(arg)
+		}
+	}
+
+	return help
 }
 
 func (liveview) arguments() []string {
-	return []string{}
+	return []string{lvHttpArg, lvRtspArg, lvRecArg, lvWebcamArg, lvFpsArg, lvStopArg}
+}
+
+func (liveview) usage() string {
+	return "liveview [" + lvHttpArg + " address] [" + lvRtspArg + " address] [" + lvRecArg + " directory] [" +
+		lvWebcamArg + " device] [" + lvFpsArg + " rate] | liveview " + lvStopArg
+}
+
+func (liveview) examples() []string {
+	return []string{
+		"liveview " + lvHttpArg + " :8080",
+		"liveview " + lvRtspArg + " :8554 " + lvRecArg + " /tmp/lv " + lvFpsArg + " 10",
+		"liveview " + lvWebcamArg + " /dev/video10",
+		"liveview " + lvStopArg,
+	}
 }
 
 func mainThread() {
@@ -39,3 +70,5 @@ func mainThread() {
 func preview(_ []byte) string {
 	return nolv
 }
+
+func reviewCapture(_ []byte) {}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand(&objects{})
+}
+
+// objectFilterKeys are the keys of the filter arguments accepted by the objects, download and sync commands.
+var objectFilterKeys = []string{"since", "until", "format", "handles"}
+
+// parseObjectFilter converts filter arguments in the form of key=value to an object filter:
+//   - since and until: a date such as 2021-03-14 or a date and time such as 2021-03-14T15:30, until including the
+//     whole day when only a date is given
+//   - format: a comma separated list of object kinds, e.g. raw,jpeg
+//   - handles: a range of object handles such as 0x10-0x20, or a single handle
+func parseObjectFilter(args []string) (ip.ObjectFilter, error) {
+	var f ip.ObjectFilter
+
+	for _, arg := range args {
+		key, val, found := strings.Cut(arg, "=")
+		if !found || val == "" {
+			return f, fmt.Errorf("invalid filter %s, expected key=value", arg)
+		}
+
+		var err error
+		switch key {
+		case "since":
+			f.Since, _, err = parseFilterDate(val)
+		case "until":
+			var dateOnly bool
+			if f.Until, dateOnly, err = parseFilterDate(val); dateOnly {
+				f.Until = f.Until.Add(24*time.Hour - time.Nanosecond)
+			}
+		case "format":
+			for _, k := range strings.Split(val, ",") {
+				kind := ip.ObjectKind(strings.ToLower(k))
+				switch kind {
+				case ip.ObjectKindJPEG, ip.ObjectKindRAW, ip.ObjectKindVideo, ip.ObjectKindOther:
+					f.Kinds = append(f.Kinds, kind)
+				default:
+					return f, fmt.Errorf("unknown format %s, must be one of jpeg, raw, video or other", k)
+				}
+			}
+		case "handles":
+			first, last, isRange := strings.Cut(val, "-")
+			if f.FirstHandle, err = parseObjectHandle(first); err != nil {
+				break
+			}
+			f.LastHandle = f.FirstHandle
+			if isRange {
+				f.LastHandle, err = parseObjectHandle(last)
+			}
+		default:
+			return f, fmt.Errorf("unknown filter %s, must be one of %s", key, strings.Join(objectFilterKeys, ", "))
+		}
+		if err != nil {
+			return f, fmt.Errorf("invalid filter %s: %s", arg, err)
+		}
+	}
+
+	return f, nil
+}
+
+// parseCountFilter converts the filter arguments of the objects count command in the form of key=value to the
+// arguments of GetNumObjects:
+//   - storage: the ID of a store, all stores being counted by default
+//   - format: an object format code, e.g. 0x3801 for EXIF/JPEG
+//   - parent: the handle of a folder or root for the root of the stores, to only count the objects directly in it
+func parseCountFilter(args []string) (ptp.StorageID, ptp.ObjectFormatCode, ptp.ObjectHandle, error) {
+	var (
+		sid    = ip.AllStorages
+		ofc    ptp.ObjectFormatCode
+		parent ptp.ObjectHandle
+	)
+
+	for _, arg := range args {
+		key, val, found := strings.Cut(arg, "=")
+		if !found || val == "" {
+			return sid, ofc, parent, fmt.Errorf("invalid filter %s, expected key=value", arg)
+		}
+
+		var (
+			v   uint64
+			err error
+		)
+		switch key {
+		case "storage":
+			v, err = strconv.ParseUint(val, 0, 32)
+			sid = ptp.StorageID(v)
+		case "format":
+			v, err = strconv.ParseUint(val, 0, 16)
+			ofc = ptp.ObjectFormatCode(v)
+		case "parent":
+			if val == "root" {
+				parent = ip.RootObjects
+				break
+			}
+			parent, err = parseObjectHandle(val)
+		default:
+			return sid, ofc, parent, fmt.Errorf("unknown filter %s, must be one of storage, format or parent", key)
+		}
+		if err != nil {
+			return sid, ofc, parent, fmt.Errorf("invalid filter %s", arg)
+		}
+	}
+
+	return sid, ofc, parent, nil
+}
+
+// parseFilterDate parses a date, or a date and time, in local time. The boolean returned is true when only a date was
+// given.
+func parseFilterDate(s string) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, false, nil
+		}
+	}
+
+	return time.Time{}, false, fmt.Errorf("expected a date such as 2021-03-14 or 2021-03-14T15:30")
+}
+
+// parseObjectHandle parses a handle in decimal or in hexadecimal notation when prefixed with 0x.
+func parseObjectHandle(s string) (ptp.ObjectHandle, error) {
+	h, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid object handle %s", s)
+	}
+
+	return ptp.ObjectHandle(h), nil
+}
+
+// formatBytes formats a number of bytes in a human readable way, e.g. 4.2 MB.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+type objects struct{}
+
+func (objects) name() string {
+	return "objects"
+}
+
+func (objects) alias() []string {
+	return []string{"ls"}
+}
+
+func (o objects) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
+	errorFmt := "objects error: %s\n"
+
+	if len(f) >= 1 && f[0] == o.arguments()[0] {
+		return o.count(c, f[1:])
+	}
+
+	if len(f) >= 1 && f[0] == o.arguments()[1] {
+		return o.meta(c, f[1:])
+	}
+
+	if len(f) >= 1 && f[0] == o.arguments()[2] {
+		return o.bursts(c, f[1:])
+	}
+
+	if len(f) >= 1 && strings.HasPrefix(f[0], "/") {
+		return o.browse(c, f[0])
+	}
+
+	filter, err := parseObjectFilter(f)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	objs, err := c.FindObjects(filter)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	if len(objs) == 0 {
+		return "no objects found\n"
+	}
+
+	return formatObjects(objs)
+}
+
+// browse lists the contents of the folder at the given path, or the object itself when it is not a folder.
+func (objects) browse(c ip.ClientAPI, path string) string {
+	errorFmt := "objects error: %s\n"
+
+	n, err := c.ObjectTree(ip.AllStorages).Lookup(path)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	if !n.IsFolder() {
+		return formatObjects([]ip.Object{n.Object})
+	}
+
+	children, err := n.Children()
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	if len(children) == 0 {
+		return fmt.Sprintf("%s is empty\n", n.Path())
+	}
+
+	objs := make([]ip.Object, len(children))
+	for i, child := range children {
+		objs[i] = child.Object
+	}
+
+	return formatObjects(objs)
+}
+
+// formatObjects formats the objects as a table. Folders are listed with a trailing slash.
+func formatObjects(objs []ip.Object) string {
+	w, buf := newTabWriter()
+	rows := [][]string{
+		{"Handle", "File name", "Format", "Size", "Captured"},
+		{"------", "---------", "------", "----", "--------"},
+	}
+	for _, obj := range objs {
+		captured := ""
+		if !obj.Info.CaptureDate.IsZero() {
+			captured = obj.Info.CaptureDate.Format("2006-01-02 15:04:05")
+		}
+		name, size := obj.Info.Filename, formatBytes(int64(obj.Info.ObjectCompressedSize))
+		kind := ip.KindOfObject(obj.Info)
+		if kind == ip.ObjectKindFolder {
+			name, size = name+"/", ""
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf("%#x", obj.Handle),
+			name,
+			string(kind),
+			size,
+			captured,
+		})
+	}
+	formatRows(w, rows)
+
+	return buf.String()
+}
+
+// count shows the number of objects on the camera without listing them.
+func (objects) count(c ip.ClientAPI, f []string) string {
+	errorFmt := "objects error: %s\n"
+
+	sid, ofc, parent, err := parseCountFilter(f)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	n, err := c.GetNumObjects(sid, ofc, parent)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	return fmt.Sprintf("%d objects\n", n)
+}
+
+// bursts lists the images matching the filter that were captured in a burst, grouped per burst.
+func (objects) bursts(c ip.ClientAPI, f []string) string {
+	errorFmt := "objects error: %s\n"
+
+	filter, err := parseObjectFilter(f)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	objs, err := c.FindObjects(filter)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	var res string
+	for _, b := range ip.GroupBursts(objs, ip.DefaultBurstGap) {
+		if len(b.Shots) < 2 {
+			continue
+		}
+		if res != "" {
+			res += "\n"
+		}
+		res += fmt.Sprintf("Burst of %d shots captured %s:\n", len(b.Shots), b.Start().Format("2006-01-02 15:04:05"))
+		res += formatObjects(b.Objects())
+	}
+	if res == "" {
+		return "no bursts found\n"
+	}
+
+	return res
+}
+
+// meta lists the EXIF metadata of the images matching the filter. Only the start of each image is downloaded.
+func (objects) meta(c ip.ClientAPI, f []string) string {
+	errorFmt := "objects error: %s\n"
+
+	filter, err := parseObjectFilter(f)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	if len(filter.Kinds) == 0 {
+		filter.Kinds = []ip.ObjectKind{ip.ObjectKindJPEG, ip.ObjectKindRAW}
+	}
+
+	objs, err := c.FindObjects(filter)
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	if len(objs) == 0 {
+		return "no objects found\n"
+	}
+
+	w, buf := newTabWriter()
+	rows := [][]string{
+		{"Handle", "File name", "Camera", "Lens", "Exposure", "ISO", "Focal length"},
+		{"------", "---------", "------", "----", "--------", "---", "------------"},
+	}
+	for _, obj := range objs {
+		row := []string{fmt.Sprintf("%#x", obj.Handle), obj.Info.Filename}
+		md, err := c.GetObjectMetadata(obj.Handle)
+		if err != nil {
+			c.Debugf("Reading the metadata of object %#x failed: %s", obj.Handle, err)
+			rows = append(rows, append(row, "-", "", "", "", ""))
+			continue
+		}
+		rows = append(rows, append(row, formatMetadata(md)...))
+	}
+	formatRows(w, rows)
+
+	return buf.String()
+}
+
+// formatMetadata returns the camera, the lens, the exposure, the ISO and the focal length of the metadata, e.g.
+// "1/250 f/5.6" as the exposure.
+func formatMetadata(md *ip.ObjectMetadata) []string {
+	var exposure []string
+	switch {
+	case md.ExposureTime <= 0:
+	case md.ExposureTime < 1:
+		exposure = append(exposure, fmt.Sprintf("1/%.0f", 1/md.ExposureTime))
+	default:
+		exposure = append(exposure, strconv.FormatFloat(md.ExposureTime, 'f', -1, 64)+"s")
+	}
+	if md.FNumber > 0 {
+		exposure = append(exposure, "f/"+strconv.FormatFloat(md.FNumber, 'f', -1, 64))
+	}
+
+	iso, focal := "", ""
+	if md.ISO > 0 {
+		iso = strconv.Itoa(md.ISO)
+	}
+	if md.FocalLength > 0 {
+		focal = strconv.FormatFloat(md.FocalLength, 'f', -1, 64) + "mm"
+	}
+
+	return []string{strings.TrimSpace(md.Make + " " + md.Model), md.LensModel, strings.Join(exposure, " "), iso, focal}
+}
+
+func (o objects) help() string {
+	help := `"` + o.name() + `" lists the objects, such as images and videos, stored on the camera. Folders are not listed.` + "\n"
+	help += helpAddAliases(o.alias())
+	help += helpAddArgumentsTitle() + helpAddObjectFilters() + "\tOR\n"
+	help += "\t- " + `"/path"` + ` browses the folders on the camera: the contents of the folder at the given path, e.g. /DCIM/100_FUJI, are listed, folders included. Only the folders along the path are read from the camera` + "\n\tOR\n"
+	help += "\t- " + `"` + o.arguments()[0] + ` [filter...]" shows the number of objects, folders included, without listing them. The filters are:` + "\n" +
+		"\t    storage=id: the objects of a single store, e.g. 0x10001\n" +
+		"\t    format=code: the objects having the given object format code, e.g. 0x3801 for EXIF/JPEG\n" +
+		"\t    parent=handle: the objects directly in the given folder, or in the root of the stores using root\n"
+	help += "\tOR\n\t- " + `"` + o.arguments()[2] + ` [filter...]" lists the images matching the filters that were captured in a burst, grouped per burst. Shots belong to the same burst when the camera numbered them as a sequence or when they were captured less than a second apart` + "\n"
+	help += "\tOR\n\t- " + `"` + o.arguments()[1] + ` [filter...]" lists the EXIF metadata of the JPEG and RAW images matching the filters, such as the camera, the lens and the exposure. Only the start of each image is downloaded to read it` + "\n"
+
+	return help
+}
+
+// helpAddObjectFilters describes the filter arguments parsed by parseObjectFilter.
+func helpAddObjectFilters() string {
+	return "\t- any number of filters in the form of key=value:\n" +
+		"\t    since=date: objects captured on or after the date, e.g. 2021-03-14 or 2021-03-14T15:30\n" +
+		"\t    until=date: objects captured on or before the date\n" +
+		"\t    format=kinds: a comma separated list of jpeg, raw, video or other\n" +
+		"\t    handles=range: a range of object handles, e.g. 0x10-0x20, or a single handle\n"
+}
+
+func (objects) arguments() []string {
+	return []string{"count", "meta", "bursts", "filter"}
+}
+
+func (objects) usage() string {
+	return "objects [filter...] | objects /path | objects count [storage=id] [format=code] [parent=handle|root] | objects meta [filter...] | objects bursts [filter...]"
+}
+
+func (objects) examples() []string {
+	return []string{
+		"objects",
+		"objects format=raw since=2021-03-14",
+		"ls handles=0x10-0x20",
+		"ls /DCIM/100_FUJI",
+		"objects count storage=0x10001 format=0x3801",
+		"objects meta format=raw since=2021-03-14",
+		"objects bursts since=2021-03-14",
+	}
+}
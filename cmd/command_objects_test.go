@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseObjectFilter(t *testing.T) {
+	got, err := parseObjectFilter([]string{"since=2021-03-14", "until=2021-03-15", "format=RAW,jpeg", "handles=0x10-32"})
+	if err != nil {
+		t.Fatalf("parseObjectFilter() error = %s; want <nil>", err)
+	}
+
+	if want := time.Date(2021, 3, 14, 0, 0, 0, 0, time.Local); !got.Since.Equal(want) {
+		t.Errorf("parseObjectFilter() Since = %s; want %s", got.Since, want)
+	}
+	if want := time.Date(2021, 3, 15, 23, 59, 59, 999999999, time.Local); !got.Until.Equal(want) {
+		t.Errorf("parseObjectFilter() Until = %s; want %s", got.Until, want)
+	}
+	if len(got.Kinds) != 2 || got.Kinds[0] != ip.ObjectKindRAW || got.Kinds[1] != ip.ObjectKindJPEG {
+		t.Errorf("parseObjectFilter() Kinds = %v; want [raw jpeg]", got.Kinds)
+	}
+	if got.FirstHandle != 0x10 || got.LastHandle != 32 {
+		t.Errorf("parseObjectFilter() handles = %#x-%#x; want 0x10-0x20", got.FirstHandle, got.LastHandle)
+	}
+
+	got, err = parseObjectFilter([]string{"until=2021-03-15T12:30", "handles=7"})
+	if err != nil {
+		t.Fatalf("parseObjectFilter() error = %s; want <nil>", err)
+	}
+	if want := time.Date(2021, 3, 15, 12, 30, 0, 0, time.Local); !got.Until.Equal(want) {
+		t.Errorf("parseObjectFilter() Until = %s; want %s", got.Until, want)
+	}
+	if got.FirstHandle != 7 || got.LastHandle != 7 {
+		t.Errorf("parseObjectFilter() handles = %d-%d; want 7-7", got.FirstHandle, got.LastHandle)
+	}
+
+	for _, args := range [][]string{{"since"}, {"format=gif"}, {"size=10"}, {"handles=0x10-zz"}, {"since=14/03/2021"}} {
+		if _, err := parseObjectFilter(args); err == nil {
+			t.Errorf("parseObjectFilter(%v) error = <nil>; want error", args)
+		}
+	}
+}
+
+func TestParseCountFilter(t *testing.T) {
+	sid, ofc, parent, err := parseCountFilter(nil)
+	if sid != ip.AllStorages || ofc != 0 || parent != 0 || err != nil {
+		t.Errorf("parseCountFilter() got = %#x, %#x, %#x, %v; want %#x, 0x0, 0x0, <nil>", sid, ofc, parent, err, ip.AllStorages)
+	}
+
+	sid, ofc, parent, err = parseCountFilter([]string{"storage=0x10001", "format=0x3801", "parent=root"})
+	if sid != 0x10001 || ofc != ptp.OFC_EXIF_JPEG || parent != ip.RootObjects || err != nil {
+		t.Errorf("parseCountFilter() got = %#x, %#x, %#x, %v; want 0x10001, %#x, %#x, <nil>", sid, ofc, parent, err, ptp.OFC_EXIF_JPEG, ip.RootObjects)
+	}
+
+	if _, _, parent, _ = parseCountFilter([]string{"parent=0x20"}); parent != 0x20 {
+		t.Errorf("parseCountFilter() parent = %#x; want 0x20", parent)
+	}
+
+	for _, args := range [][]string{{"storage"}, {"format=0x10000"}, {"parent=folder"}, {"since=2021-03-14"}} {
+		if _, _, _, err := parseCountFilter(args); err == nil {
+			t.Errorf("parseCountFilter(%v) error = <nil>; want error", args)
+		}
+	}
+}
+
+func TestFormatObjects(t *testing.T) {
+	got := formatObjects([]ip.Object{
+		{Handle: 1, Info: &ptp.ObjectInfo{Filename: "DCIM", ObjectFormat: ptp.OFC_Association}},
+		{Handle: 2, Info: &ptp.ObjectInfo{Filename: "DSCF0001.JPG", ObjectFormat: ptp.OFC_EXIF_JPEG, ObjectCompressedSize: 4200000}},
+	})
+	for _, want := range []string{"0x1     DCIM/         folder", "0x2     DSCF0001.JPG  jpeg    4.2 MB"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatObjects() got = %q; want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	check := map[int64]string{
+		12:        "12 B",
+		4200:      "4.2 kB",
+		4200000:   "4.2 MB",
+		999999999: "1000.0 MB",
+	}
+
+	for in, want := range check {
+		if got := formatBytes(in); got != want {
+			t.Errorf("formatBytes(%d) got = %s; want %s", in, got, want)
+		}
+	}
+}
+
+func TestObjectPath(t *testing.T) {
+	check := []struct {
+		obj  ip.Object
+		want string
+	}{
+		{ip.Object{Handle: 1, Info: &ptp.ObjectInfo{Filename: "DSCF0001.JPG"}}, "/tmp/photos/DSCF0001.JPG"},
+		{ip.Object{Handle: 2, Info: &ptp.ObjectInfo{Filename: "../DSCF0002.JPG"}}, "/tmp/photos/DSCF0002.JPG"},
+		{ip.Object{Handle: 0x2a, Info: &ptp.ObjectInfo{}}, "/tmp/photos/0000002a"},
+	}
+
+	for _, tt := range check {
+		if got := objectPath("/tmp/photos", tt.obj); got != tt.want {
+			t.Errorf("objectPath() got = %s; want %s", got, tt.want)
+		}
+	}
+}
+
+func TestFormatMetadata(t *testing.T) {
+	got := formatMetadata(&ip.ObjectMetadata{Make: "FUJIFILM", Model: "X-T1", LensModel: "XF35mmF1.4 R", ExposureTime: 0.004, FNumber: 5.6, ISO: 400, FocalLength: 35})
+	want := []string{"FUJIFILM X-T1", "XF35mmF1.4 R", "1/250 f/5.6", "400", "35mm"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("formatMetadata() got = %q; want %q", got, want)
+	}
+
+	got = formatMetadata(&ip.ObjectMetadata{ExposureTime: 2.5})
+	if want := []string{"", "", "2.5s", "", ""}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("formatMetadata() got = %q; want %q", got, want)
+	}
+}
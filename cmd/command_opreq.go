@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 
 	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
 	"github.com/malc0mn/ptp-ip/ip"
@@ -15,6 +17,72 @@ func init() {
 
 type opreq struct{}
 
+// opreqRequest holds the arguments of the opreq command.
+type opreqRequest struct {
+	code   ptp.OperationCode
+	params []uint32
+	// dataIn is true when the operation has a data-in phase, either according to the catalog or because data=in was
+	// passed.
+	dataIn bool
+	// dataOut holds the payload of the data-out phase given using data=<hex>, nil meaning there is no data-out phase.
+	dataOut []byte
+}
+
+// parseOperationCode converts the name of a standard operation, e.g. GetObjectInfo, or a hexadecimal operation code to
+// an operation code.
+func parseOperationCode(s string) (ptp.OperationCode, error) {
+	if code, ok := ptp.OperationCodeByName(s); ok {
+		return code, nil
+	}
+
+	cod, err := ptpfmt.HexStringToUint64(s, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown operation %s, expected an operation name or a hexadecimal operation code", s)
+	}
+
+	return ptp.OperationCode(cod), nil
+}
+
+// parseOpreqArgs converts the arguments of the opreq command: the operation, followed by the hexadecimal parameters
+// and an optional data=<hex> or data=in argument.
+func parseOpreqArgs(f []string) (*opreqRequest, error) {
+	if len(f) == 0 {
+		return nil, errors.New("missing operation")
+	}
+
+	code, err := parseOperationCode(f[0])
+	if err != nil {
+		return nil, err
+	}
+	req := &opreqRequest{code: code}
+	if oi, ok := ptp.OperationCodeInfo(code); ok {
+		req.dataIn = oi.Data == ptp.DD_In
+	}
+
+	for _, arg := range f[1:] {
+		if val, found := strings.CutPrefix(arg, "data="); found {
+			if val == "in" {
+				req.dataIn = true
+				continue
+			}
+			val = strings.TrimPrefix(strings.ToLower(val), "0x")
+			if req.dataOut, err = hex.DecodeString(val); err != nil || len(req.dataOut) == 0 {
+				return nil, fmt.Errorf("invalid data %s, expected data=in or hexadecimal bytes such as data=0x0200", arg)
+			}
+			req.dataIn = false
+			continue
+		}
+
+		conv, err := ptpfmt.HexStringToUint64(arg, 64)
+		if err != nil {
+			return nil, err
+		}
+		req.params = append(req.params, uint32(conv))
+	}
+
+	return req, nil
+}
+
 func (opreq) name() string {
 	return "opreq"
 }
@@ -23,51 +91,102 @@ func (opreq) alias() []string {
 	return []string{}
 }
 
-func (opreq) execute(c *ip.Client, f []string, _ chan<- string) string {
-	var res string
+// destructive returns true for the operations deleting objects or formatting a store.
+func (opreq) destructive(f []string) bool {
+	if len(f) == 0 {
+		return false
+	}
+	code, err := parseOperationCode(f[0])
+	if err != nil {
+		return false
+	}
+
+	switch code {
+	case ptp.OC_DeleteObject, ptp.OC_FormatStore:
+		return true
+	}
+
+	return false
+}
+
+func (opreq) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
 	errorFmt := "opreq error: %s\n"
 
-	cod, err := ptpfmt.HexStringToUint64(f[0], 16)
+	req, err := parseOpreqArgs(f)
 	if err != nil {
 		return fmt.Sprintf(errorFmt, err)
 	}
-	c.Debugf("Converted uint16: %#x", cod)
+	c.Debugf("Converted operation: %#x, params: %#x", req.code, req.params)
 
-	params := f[1:]
-	p := make([]uint32, len(params))
-	for i, param := range params {
-		conv, err := ptpfmt.HexStringToUint64(param, 64)
+	if req.dataIn {
+		data, err := c.OperationRequestData(req.code, req.params)
 		if err != nil {
 			return fmt.Sprintf(errorFmt, err)
 		}
-		p[i] = uint32(conv)
+		return formatDataIn(req.code, data)
 	}
 
-	c.Debugf("Converted params: %#x", p)
-
-	d, err := c.OperationRequestRaw(ptp.OperationCode(cod), p)
+	var d []byte
+	if req.dataOut != nil {
+		d, err = c.SendData(req.code, req.params, req.dataOut, uint64(len(req.dataOut)))
+	} else {
+		d, err = c.OperationRequestRaw(req.code, req.params)
+	}
 	if err != nil {
 		return fmt.Sprintf(errorFmt, err)
 	}
 
-	// for _, raw := range d {
-	// 	res += fmt.Sprintf("\nReceived %d bytes. HEX dump:\n%s", len(d), hex.Dump(d))
-	// }
-	res += fmt.Sprintf("\nReceived %d bytes. HEX dump:\n%s", len(d), hex.Dump(d))
+	res := fmt.Sprintf("\nReceived %d bytes. HEX dump:\n%s", len(d), hex.Dump(d))
+	res += "Packet dump:\n" + c.DumpRawPacket(d)
+	return res
+}
+
+// formatDataIn returns a hexadecimal dump of the payload of a data-in phase, followed by the decoded dataset for the
+// standard operations of which the dataset is known.
+func formatDataIn(code ptp.OperationCode, data []byte) string {
+	res := fmt.Sprintf("\nReceived %d bytes of data. HEX dump:\n%s", len(data), hex.Dump(data))
+
+	ds, err := ip.DecodeDataset(code, data)
+	switch {
+	case errors.Is(err, ip.ErrUnknownDataset):
+		return res
+	case err != nil:
+		return res + fmt.Sprintf("Unable to decode the %s dataset: %s\n", ptp.OperationCodeName(code), err)
+	}
+
+	res += fmt.Sprintf("Decoded %s dataset:\n", ptp.OperationCodeName(code))
+	switch v := ds.(type) {
+	case []ptp.StorageID:
+		for _, sid := range v {
+			res += fmt.Sprintf("%#x\n", uint32(sid))
+		}
+	case []ptp.ObjectHandle:
+		for _, h := range v {
+			res += fmt.Sprintf("%#x\n", uint32(h))
+		}
+	case *ptp.DevicePropDesc:
+		// The form refers back to the description, which cannot be marshalled as is.
+		res += fujiFormatJson(&ptpfmt.DevicePropDescJSON{DevicePropDesc: v}, "pretty") + "\n"
+	default:
+		res += fujiFormatJson(v, "pretty") + "\n"
+	}
+
 	return res
 }
 
 func (o opreq) help() string {
-	help := `"` + o.name() + `" This command is intended for reverse engineering and/or debugging purposes. The output will always be a hexadecimal dump of the packets received from the responder.` + "\n"
+	help := `"` + o.name() + `" This command is intended for protocol exploration, reverse engineering and/or debugging purposes. For operations with a data-in phase, the output is a hexadecimal dump of the data received from the responder, followed by the decoded dataset for the standard operations of which the dataset is known. For all other operations, the output is a hexadecimal dump of the packet received from the responder, followed by an annotated field by field dump of the packet naming the operation or response code.` + "\n"
 
 	if args := o.arguments(); len(args) > 0 {
 		help += helpAddArgumentsTitle()
 		for i, arg := range args {
 			switch i {
 			case 0:
-				help += "\t- " + arg + ": a hexadecimal operation code in the form of '0x1014'. The supported operation codes will vary from vendor to vendor.\n"
+				help += "\t- " + arg + ": the name of a standard operation such as 'GetDevicePropDesc', or a hexadecimal operation code in the form of '0x1014'. The supported operation codes will vary from vendor to vendor.\n"
 			case 1:
-				help += "\t- " + arg + ": depending on the operation code, an additional parameter might be required. It is expected to be in hexadecimal form, e.g. '0x5003'\n"
+				help += "\t- " + arg + ": depending on the operation code, additional parameters might be required. They are expected to be in hexadecimal form, e.g. '0x5003'\n"
+			case 2:
+				help += "\t- " + arg + ": 'data=' followed by hexadecimal bytes, e.g. 'data=0x0200', sends the bytes in a data-out phase. 'data=in' expects a data-in phase, which is only needed for vendor-extended operations since the data phase of standard operations is known.\n"
 			}
 		}
 	}
@@ -76,5 +195,18 @@ func (o opreq) help() string {
 }
 
 func (opreq) arguments() []string {
-	return []string{"opcode", "param"}
+	return []string{"opcode", "param", "data"}
+}
+
+func (opreq) usage() string {
+	return "opreq opcode [param...] [data=in|data=hex]"
+}
+
+func (opreq) examples() []string {
+	return []string{
+		"opreq 0x1001",
+		"opreq GetDevicePropDesc 0x5003",
+		"opreq SetDevicePropValue 0x5005 data=0x0200",
+		"opreq 0x902b data=in",
+	}
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strings"
+	"testing"
+)
+
+func TestOpreq_execute(t *testing.T) {
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+	c.responses[ptp.OC_InitiateCapture] = []byte{0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x0c, 0x20, 0x03, 0x00, 0x00, 0x00}
+
+	got := opreq{}.execute(c, []string{"0x100e", "0x0", "0x3801"}, nil)
+	if params := c.requests[ptp.OC_InitiateCapture]; len(params) != 2 || params[1] != 0x3801 {
+		t.Errorf("execute() params = %#x; want [0x0 0x3801]", params)
+	}
+	for _, want := range []string{"Received 14 bytes. HEX dump:\n", "ResponseCode: 0x200c (Store_Full)\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("execute() got = %s; want it to contain %s", got, want)
+		}
+	}
+
+	got = opreq{}.execute(c, []string{"0x1014", "0x5003"}, nil)
+	if want := "opreq error: operation not supported\n"; got != want {
+		t.Errorf("execute() got = %s; want %s", got, want)
+	}
+}
+
+func TestOpreq_execute_data(t *testing.T) {
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+	c.responses[ptp.OC_GetStorageIDs] = []byte{0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00}
+	c.responses[ptp.OC_SetDevicePropValue] = []byte{0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x01, 0x20, 0x04, 0x00, 0x00, 0x00}
+
+	got := opreq{}.execute(c, []string{"getstorageids"}, nil)
+	for _, want := range []string{"Received 8 bytes of data. HEX dump:\n", "Decoded GetStorageIDs dataset:\n0x10001\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("execute() got = %s; want it to contain %s", got, want)
+		}
+	}
+
+	c.responses[ptp.OC_GetDevicePropDesc] = []byte{0x01, 0x50, 0x02, 0x00, 0x00, 0x64, 0x32, 0x01, 0x00, 0x64, 0x01}
+	got = opreq{}.execute(c, []string{"getdevicepropdesc", "0x5001"}, nil)
+	if want := "Decoded GetDevicePropDesc dataset:\n{"; !strings.Contains(got, want) {
+		t.Errorf("execute() got = %s; want it to contain %s", got, want)
+	}
+
+	got = opreq{}.execute(c, []string{"SetDevicePropValue", "0x5005", "data=0x0200"}, nil)
+	if data := c.sent[ptp.OC_SetDevicePropValue]; !bytes.Equal(data, []byte{0x02, 0x00}) {
+		t.Errorf("execute() data = %#x; want 0x0200", data)
+	}
+	if want := "ResponseCode: 0x2001 (OK)\n"; !strings.Contains(got, want) {
+		t.Errorf("execute() got = %s; want it to contain %s", got, want)
+	}
+
+	check := map[string]string{
+		"TakePicture":     "opreq error: unknown operation TakePicture, expected an operation name or a hexadecimal operation code\n",
+		"0x1016 data=0x2": "opreq error: invalid data data=0x2, expected data=in or hexadecimal bytes such as data=0x0200\n",
+		"0x902b data=in":  "opreq error: operation not supported\n",
+	}
+	for args, want := range check {
+		if got := (opreq{}).execute(c, strings.Fields(args), nil); got != want {
+			t.Errorf("execute() got = %s; want %s", got, want)
+		}
+	}
+	if _, ok := c.requests[ptp.OperationCode(0x902b)]; !ok {
+		t.Errorf("execute() did not request the data of 0x902b")
+	}
+}
+
+func TestOpreq_destructive(t *testing.T) {
+	check := map[string]bool{
+		"0x100b":      true,
+		"formatstore": true,
+		"GetObject":   false,
+		"unknown":     false,
+	}
+	for arg, want := range check {
+		if got := (opreq{}).destructive([]string{arg}); got != want {
+			t.Errorf("destructive() got = %t; want %t", got, want)
+		}
+	}
+}
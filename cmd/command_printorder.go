@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerCommand(&printorder{})
+}
+
+type printorder struct{}
+
+func (printorder) name() string {
+	return "printorder"
+}
+
+func (printorder) alias() []string {
+	return []string{"dpof"}
+}
+
+func (p printorder) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
+	errorFmt := "printorder error: %s\n"
+
+	po, err := c.GetPrintOrder()
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	if len(f) == 0 {
+		if len(po.Jobs) == 0 {
+			return "no print order\n"
+		}
+		return formatPrintOrder(po)
+	}
+
+	args := p.arguments()
+	switch f[0] {
+	case args[0], args[1]:
+		if len(f) < 2 {
+			return fmt.Sprintf(errorFmt, "missing object handle")
+		}
+		h, err := parseObjectHandle(f[1])
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		qty := 1
+		if f[0] == args[1] {
+			qty = 0
+		} else if len(f) > 2 {
+			if qty, err = strconv.Atoi(f[2]); err != nil || qty < 1 || qty > 999 {
+				return fmt.Sprintf(errorFmt, fmt.Sprintf("invalid quantity %s, must be between 1 and 999", f[2]))
+			}
+		}
+		path, err := c.ObjectPath(h)
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		setPrintQuantity(po, path, qty)
+	case args[2]:
+		po.Jobs = nil
+	default:
+		return fmt.Sprintf(errorFmt, fmt.Sprintf("unknown subcommand %s", f[0]))
+	}
+
+	if err := c.SetPrintOrder(po); err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	return fmt.Sprintf("print order updated, %d images to print\n", len(po.Jobs))
+}
+
+// setPrintQuantity sets the number of standard prints of the image at the given path, removing the image from the
+// print order when the quantity is 0.
+func setPrintQuantity(po *ip.PrintOrder, path string, qty int) {
+	for i, job := range po.Jobs {
+		if job.Type != ip.PT_Standard || !strings.EqualFold(job.Path, path) {
+			continue
+		}
+		if qty == 0 {
+			po.Jobs = append(po.Jobs[:i], po.Jobs[i+1:]...)
+			return
+		}
+		po.Jobs[i].Quantity = qty
+		return
+	}
+
+	if qty > 0 {
+		po.Jobs = append(po.Jobs, ip.PrintJob{Type: ip.PT_Standard, Quantity: qty, Path: path})
+	}
+}
+
+// formatPrintOrder formats the jobs of the print order as a table.
+func formatPrintOrder(po *ip.PrintOrder) string {
+	w, buf := newTabWriter()
+	rows := [][]string{
+		{"Qty", "Type", "Image"},
+		{"---", "----", "-----"},
+	}
+	for _, job := range po.Jobs {
+		rows = append(rows, []string{strconv.Itoa(job.Quantity), string(job.Type), job.Path})
+	}
+	formatRows(w, rows)
+
+	return buf.String()
+}
+
+func (p printorder) help() string {
+	help := `"` + p.name() + `" shows the DPOF print order stored on the memory card, which printing kiosks and printers use to print the selected images. The print order is stored in ` + ip.DPOFPath + `.` + "\n"
+	help += helpAddAliases(p.alias())
+
+	args := p.arguments()
+	help += helpAddArgumentsTitle()
+	help += "\t- " + `"` + args[0] + ` handle [quantity]"` + ` adds an image to the print order, or changes the number of prints when it is already in it. One print is ordered by default` + "\n\tOR\n"
+	help += "\t- " + `"` + args[1] + ` handle"` + ` removes an image from the print order` + "\n\tOR\n"
+	help += "\t- " + `"` + args[2] + `"` + ` deletes the print order` + "\n"
+
+	return help
+}
+
+func (printorder) arguments() []string {
+	return []string{"add", "remove", "clear"}
+}
+
+func (printorder) usage() string {
+	return "printorder [add handle [quantity] | remove handle | clear]"
+}
+
+func (printorder) examples() []string {
+	return []string{
+		"printorder",
+		"printorder add 0x1a 2",
+		"dpof remove 0x1a",
+		"dpof clear",
+	}
+}
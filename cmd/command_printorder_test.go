@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"testing"
+)
+
+func TestSetPrintQuantity(t *testing.T) {
+	po := &ip.PrintOrder{Jobs: []ip.PrintJob{
+		{Type: ip.PT_Index, Quantity: 1, Path: "/DCIM/100_FUJI/DSCF0001.JPG"},
+		{Type: ip.PT_Standard, Quantity: 1, Path: "/DCIM/100_FUJI/DSCF0001.JPG"},
+	}}
+
+	setPrintQuantity(po, "/dcim/100_fuji/dscf0001.jpg", 3)
+	if len(po.Jobs) != 2 || po.Jobs[1].Quantity != 3 || po.Jobs[0].Quantity != 1 {
+		t.Errorf("setPrintQuantity() got = %+v; want the standard print quantity set to 3", po.Jobs)
+	}
+
+	setPrintQuantity(po, "/DCIM/100_FUJI/DSCF0002.JPG", 2)
+	if len(po.Jobs) != 3 || po.Jobs[2] != (ip.PrintJob{Type: ip.PT_Standard, Quantity: 2, Path: "/DCIM/100_FUJI/DSCF0002.JPG"}) {
+		t.Errorf("setPrintQuantity() got = %+v; want DSCF0002.JPG added", po.Jobs)
+	}
+
+	setPrintQuantity(po, "/DCIM/100_FUJI/DSCF0001.JPG", 0)
+	if len(po.Jobs) != 2 || po.Jobs[0].Type != ip.PT_Index || po.Jobs[1].Path != "/DCIM/100_FUJI/DSCF0002.JPG" {
+		t.Errorf("setPrintQuantity() got = %+v; want the standard print of DSCF0001.JPG removed", po.Jobs)
+	}
+
+	setPrintQuantity(po, "/DCIM/100_FUJI/DSCF0003.JPG", 0)
+	if len(po.Jobs) != 2 {
+		t.Errorf("setPrintQuantity() got = %+v; want no changes", po.Jobs)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strconv"
+)
+
+func init() {
+	registerCommand(&protect{})
+	registerCommand(&rate{})
+}
+
+// starRatings maps a rating in stars to the MTP rating, using the same values as Windows does when rating a file.
+var starRatings = []int{0, 1, 25, 50, 75, 99}
+
+// starsToRating converts a rating of zero to five stars to a rating ranging from 0 to ip.MTPMaxRating.
+func starsToRating(s string) (int, error) {
+	stars, err := strconv.Atoi(s)
+	if err != nil || stars < 0 || stars >= len(starRatings) {
+		return 0, fmt.Errorf("invalid rating %s, must be between 0 and %d stars", s, len(starRatings)-1)
+	}
+
+	return starRatings[stars], nil
+}
+
+type protect struct{}
+
+func (protect) name() string {
+	return "protect"
+}
+
+func (protect) alias() []string {
+	return []string{}
+}
+
+func (p protect) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
+	errorFmt := "protect error: %s\n"
+
+	if len(f) < 1 {
+		return fmt.Sprintf(errorFmt, "missing object handle")
+	}
+	h, err := parseObjectHandle(f[0])
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	status, msg := ptp.PS_ReadOnly, "protected"
+	if len(f) > 1 {
+		switch f[1] {
+		case "on":
+		case "off":
+			status, msg = ptp.PS_NoProtection, "unprotected"
+		default:
+			return fmt.Sprintf(errorFmt, fmt.Sprintf("invalid state %s, must be on or off", f[1]))
+		}
+	}
+
+	if err := c.SetObjectProtection(h, status); err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	return fmt.Sprintf("object %#x %s\n", h, msg)
+}
+
+func (p protect) help() string {
+	help := `"` + p.name() + `" write-protects an object on the camera, so that it cannot be deleted until the protection is removed. The protection is stored on the memory card, making it a way to flag the selected images that is also shown by the camera.` + "\n"
+
+	if args := p.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		for i, arg := range args {
+			switch i {
+			case 0:
+				help += "\t- " + arg + `: an object handle as listed by the "objects" command` + "\n"
+			case 1:
+				help += "\t- " + arg + ": on to protect the object, which is the default, or off to remove the protection\n"
+			}
+		}
+	}
+
+	return help
+}
+
+func (protect) arguments() []string {
+	return []string{"handle", "state"}
+}
+
+func (protect) usage() string {
+	return "protect handle [on|off]"
+}
+
+func (protect) examples() []string {
+	return []string{
+		"protect 0x1a",
+		"protect 0x1a off",
+	}
+}
+
+type rate struct{}
+
+func (rate) name() string {
+	return "rate"
+}
+
+func (rate) alias() []string {
+	return []string{}
+}
+
+func (r rate) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
+	errorFmt := "rate error: %s\n"
+
+	if len(f) < 2 {
+		return fmt.Sprintf(errorFmt, "expected an object handle and a rating")
+	}
+	h, err := parseObjectHandle(f[0])
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+	rating, err := starsToRating(f[1])
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	if err := c.SetObjectRating(h, rating); err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	return fmt.Sprintf("object %#x rated %s stars\n", h, f[1])
+}
+
+func (r rate) help() string {
+	help := `"` + r.name() + `" rates an object on the camera. The rating is written using the MTP object properties, so it is only supported by cameras speaking MTP. Use "protect" to flag images on other cameras.` + "\n"
+
+	if args := r.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		for i, arg := range args {
+			switch i {
+			case 0:
+				help += "\t- " + arg + `: an object handle as listed by the "objects" command` + "\n"
+			case 1:
+				help += "\t- " + arg + ": the number of stars from 1 to 5, 0 removing the rating\n"
+			}
+		}
+	}
+
+	return help
+}
+
+func (rate) arguments() []string {
+	return []string{"handle", "stars"}
+}
+
+func (rate) usage() string {
+	return "rate handle stars"
+}
+
+func (rate) examples() []string {
+	return []string{
+		"rate 0x1a 5",
+		"rate 0x1a 0",
+	}
+}
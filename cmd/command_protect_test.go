@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strings"
+	"testing"
+)
+
+func TestStarsToRating(t *testing.T) {
+	for stars, want := range map[string]int{"0": 0, "1": 1, "3": 50, "5": 99} {
+		if got, err := starsToRating(stars); got != want || err != nil {
+			t.Errorf("starsToRating(%s) got = %d, %v; want %d, <nil>", stars, got, err, want)
+		}
+	}
+
+	for _, stars := range []string{"-1", "6", "five"} {
+		if _, err := starsToRating(stars); err == nil {
+			t.Errorf("starsToRating(%s) error = <nil>; want error", stars)
+		}
+	}
+}
+
+func TestProtect_execute(t *testing.T) {
+	mc := newMockClient(ptp.VE_FujiPhotoFilmCoLtd)
+
+	if got := (protect{}).execute(mc, []string{"0x1a"}, nil); got != "object 0x1a protected\n" {
+		t.Errorf("execute() got = %q; want %q", got, "object 0x1a protected\n")
+	}
+	if got := mc.requests[ptp.OC_SetObjectProtection]; got[0] != 0x1a || got[1] != uint32(ptp.PS_ReadOnly) {
+		t.Errorf("execute() params = %#x; want [0x1a %#x]", got, ptp.PS_ReadOnly)
+	}
+
+	(protect{}).execute(mc, []string{"0x1a", "off"}, nil)
+	if got := mc.requests[ptp.OC_SetObjectProtection]; got[1] != uint32(ptp.PS_NoProtection) {
+		t.Errorf("execute() params = %#x; want [0x1a %#x]", got, ptp.PS_NoProtection)
+	}
+
+	if got := (protect{}).execute(mc, []string{"0x1a", "maybe"}, nil); !strings.HasPrefix(got, "protect error") {
+		t.Errorf("execute() got = %q; want an error", got)
+	}
+}
+
+func TestRate_execute(t *testing.T) {
+	mc := newMockClient(ptp.VE_MicrosoftCorporation)
+
+	if got := (rate{}).execute(mc, []string{"0x1a", "4"}, nil); got != "object 0x1a rated 4 stars\n" {
+		t.Errorf("execute() got = %q; want %q", got, "object 0x1a rated 4 stars\n")
+	}
+	if got := mc.requests[ip.OC_MTP_SetObjectPropValue]; got[0] != 0x1a || got[1] != 75 {
+		t.Errorf("execute() params = %v; want [26 75]", got)
+	}
+
+	if got := (rate{}).execute(mc, []string{"0x1a"}, nil); !strings.HasPrefix(got, "rate error") {
+		t.Errorf("execute() got = %q; want an error", got)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ip"
+)
+
+// rawsendWait is how long the rawsend command collects the packets sent back by the responder.
+const rawsendWait = time.Second
+
+func init() {
+	registerCommand(&rawsend{})
+}
+
+type rawsend struct{}
+
+func (rawsend) name() string {
+	return "rawsend"
+}
+
+func (rawsend) alias() []string {
+	return []string{}
+}
+
+// destructive always returns true: there is no telling what an arbitrary packet does to the camera.
+func (rawsend) destructive(_ []string) bool {
+	return true
+}
+
+func (rawsend) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
+	errorFmt := "rawsend error: %s\n"
+
+	if !debugCommands {
+		return fmt.Sprintf(errorFmt, "debug commands are disabled, use the -debug flag to enable them")
+	}
+	if len(f) < 2 {
+		return fmt.Sprintf(errorFmt, "expected a channel and the packet in hexadecimal form")
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(strings.Join(f[1:], "")), "0x"))
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rawsendWait)
+	defer cancel()
+	packets, err := c.SendRawPacket(ctx, f[0], data)
+	if errors.Is(err, ip.RawPacketsDisabledError) {
+		return fmt.Sprintf(errorFmt, "raw packets are not enabled for this camera, it was connected to without the -debug flag")
+	}
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	if len(packets) == 0 {
+		return fmt.Sprintf("No packets received within %s.\n", rawsendWait)
+	}
+	var res string
+	for _, p := range packets {
+		res += fmt.Sprintf("\nReceived %d bytes. HEX dump:\n%s", len(p), hex.Dump(p))
+		if f[0] == "cmd" {
+			res += "Packet dump:\n" + c.DumpRawPacket(p)
+		}
+	}
+
+	return res
+}
+
+func (r rawsend) help() string {
+	help := `"` + r.name() + `" This command is intended for reverse engineering undocumented vendor operations. It writes an arbitrary packet to the responder and prints all packets the responder sends back on the same channel within ` + rawsendWait.String() + `, including packets belonging to other transactions. Only the length field is added to the packet, it is not validated in any way. The command is only available when starting with the -debug flag and is considered destructive in server mode.` + "\n"
+
+	if args := r.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		for i, arg := range args {
+			switch i {
+			case 0:
+				help += "\t- " + arg + ": the channel to write the packet to: 'cmd' for the command/data channel or 'event' for the event channel.\n"
+			case 1:
+				help += "\t- " + arg + ": the packet in hexadecimal form without the length field, e.g. '0d000000' for a probe request. For standard packets, it starts with the packet type. For Fuji operation requests, it starts with the data phase and the operation code. Spaces are allowed in between the bytes.\n"
+			}
+		}
+	}
+
+	return help
+}
+
+func (rawsend) arguments() []string {
+	return []string{"channel", "hexbytes"}
+}
+
+func (rawsend) usage() string {
+	return "rawsend channel hexbytes"
+}
+
+func (rawsend) examples() []string {
+	return []string{
+		"rawsend event 0d000000",
+		"rawsend cmd 06000000 01000000 0410 00010000",
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strings"
+	"testing"
+)
+
+func TestRawsend_execute(t *testing.T) {
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+
+	got := rawsend{}.execute(c, []string{"event", "0d000000"}, nil)
+	if want := "rawsend error: debug commands are disabled, use the -debug flag to enable them\n"; got != want {
+		t.Errorf("execute() got = %s; want %s", got, want)
+	}
+
+	defer func(d bool) { debugCommands = d }(debugCommands)
+	debugCommands = true
+
+	got = rawsend{}.execute(c, []string{"event", "0d000000"}, nil)
+	if want := "No packets received within 1s.\n"; got != want {
+		t.Errorf("execute() got = %s; want %s", got, want)
+	}
+
+	c.rawResponses = [][]byte{{0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x01, 0x20, 0x01, 0x00, 0x00, 0x00}}
+	got = rawsend{}.execute(c, []string{"cmd", "0x06000000", "01000000", "0410", "01000000"}, nil)
+	if want := "cmd:\x06\x00\x00\x00\x01\x00\x00\x00\x04\x10\x01\x00\x00\x00"; string(c.raw[1]) != want {
+		t.Errorf("execute() sent = %q; want %q", c.raw[1], want)
+	}
+	for _, want := range []string{"Received 14 bytes. HEX dump:\n", "ResponseCode: 0x2001 (OK)\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("execute() got = %s; want it to contain %s", got, want)
+		}
+	}
+
+	check := map[string]string{
+		"cmd":         "rawsend error: expected a channel and the packet in hexadecimal form\n",
+		"cmd 0d0":     "rawsend error: encoding/hex: odd length hex string\n",
+		"stream 0d00": "rawsend error: unknown connection \"stream\", must be cmd or event\n",
+	}
+	for args, want := range check {
+		if got := (rawsend{}).execute(c, strings.Fields(args), nil); got != want {
+			t.Errorf("execute() got = %s; want %s", got, want)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"fmt"
-	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
 	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
 )
 
 func init() {
@@ -20,22 +20,26 @@ func (set) alias() []string {
 	return []string{}
 }
 
-func (set) execute(c *ip.Client, f []string, _ chan<- string) string {
+func (s set) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
 	errorFmt := "set error: %s\n"
 
+	if len(f) < 2 {
+		return fmt.Sprintf(errorFmt, "usage: "+s.usage())
+	}
+
 	cod, err := formatDeviceProperty(c, f[0])
 	if err != nil {
 		return fmt.Sprintf(errorFmt, err)
 	}
 
-	// TODO: add support for "string" values such as "astia" for film simulation.
-	val, err := ptpfmt.HexStringToUint64(f[1], 32)
+	// Human readable values can contain spaces, e.g. "classic chrome".
+	val, err := formatDevicePropertyValue(c, cod, strings.Join(f[1:], " "))
 	if err != nil {
 		return fmt.Sprintf(errorFmt, err)
 	}
 	c.Debugf("Converted value to: %#x", val)
 
-	err = c.SetDeviceProperty(cod, uint32(val))
+	err = c.SetDeviceProperty(cod, val)
 	if err != nil {
 		return fmt.Sprintf(errorFmt, err)
 	}
@@ -53,7 +57,7 @@ func (s set) help() string {
 			case 0:
 				help += "\t- " + arg + " is a hexadecimal field code in the form of '0x5001' or one of the supported unified field names:\n" + helpAddUnifiedFieldNames()
 			case 1:
-				help += "\t- " + arg + " is a hexadecimal value to set the field to. E.g. '0x6'\n"
+				help += "\t- " + arg + " is a hexadecimal value to set the field to, e.g. '0x6', or a human readable value as displayed by the get command, e.g. 'classic chrome' or 'f/5.6'. When a human readable value is not supported by the camera, the allowed values are listed\n"
 			}
 		}
 	}
@@ -64,3 +68,17 @@ func (s set) help() string {
 func (set) arguments() []string {
 	return []string{"property", "value"}
 }
+
+func (set) usage() string {
+	return "set property value"
+}
+
+func (set) examples() []string {
+	return []string{
+		"set 0x5005 0x2",
+		"set exp-bias 0x0",
+		"set effect classic chrome",
+		"set whitebalance daylight",
+		"set iso 400",
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerCommand(&snapshot{})
+}
+
+type snapshot struct{}
+
+func (snapshot) name() string {
+	return "snapshot"
+}
+
+func (snapshot) alias() []string {
+	return []string{"lvsnap"}
+}
+
+func (snapshot) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
+	errorFmt := "snapshot error: %s\n"
+
+	frame, err := c.LiveviewSnapshot()
+	if err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	if f, preview := hasPreviewFlag(f); preview {
+		img, err := termImage(frame.Data)
+		if err != nil {
+			return fmt.Sprintf(errorFmt, err)
+		}
+		if len(f) == 0 {
+			return img
+		}
+		if err := writeSnapshot(f[0], frame.Data); err != nil {
+			return img + fmt.Sprintf(errorFmt, err)
+		}
+		return img + fmt.Sprintf("live view frame %d saved to %s\n", frame.Meta.Sequence, f[0])
+	}
+
+	path := "snapshot-" + frame.Meta.Received.Format("20060102-150405.000") + ".jpg"
+	if len(f) >= 1 {
+		path = f[0]
+	}
+
+	if err := writeSnapshot(path, frame.Data); err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	return fmt.Sprintf("live view frame %d saved to %s\n", frame.Meta.Sequence, path)
+}
+
+func (s snapshot) help() string {
+	help := `"` + s.name() + `" saves the most recent live view frame to a file without triggering the shutter. The live view must be enabled.` + "\n"
+	help += helpAddAliases(s.alias())
+
+	if args := s.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		for i, arg := range args {
+			switch i {
+			case 0:
+				help += "\t- a " + arg + ` to save the frame to. Use the ".png" extension to save it as a PNG image, any other extension will save the JPEG data as is. Defaults to "snapshot-<timestamp>.jpg" in the current directory` + "\n"
+			case 1:
+				help += "\t- " + arg + ": display the frame in the terminal using the sixel, iTerm2 or kitty image protocol, which works over SSH. The frame is only saved when a filepath is given as well\n"
+			}
+		}
+	}
+
+	return help
+}
+
+func (snapshot) arguments() []string {
+	return []string{"filepath", previewFlag}
+}
+
+// writeSnapshot writes the JPEG data to the given path, converting it to PNG when the path has the .png extension.
+func writeSnapshot(path string, data []byte) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		return writePNG(path, img)
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (snapshot) usage() string {
+	return "snapshot [filepath] [--preview]"
+}
+
+func (snapshot) examples() []string {
+	return []string{
+		"snapshot",
+		"snapshot /tmp/frame.png",
+		"snapshot --preview",
+	}
+}
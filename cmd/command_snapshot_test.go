@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	jpg := filepath.Join(dir, "frame.jpg")
+	if err := writeSnapshot(jpg, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(jpg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, buf.Bytes()) {
+		t.Error("writeSnapshot() JPEG data differs from the frame data")
+	}
+
+	p := filepath.Join(dir, "frame.PNG")
+	if err := writeSnapshot(p, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("writeSnapshot() did not write a PNG image: %s", err)
+	}
+	if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 {
+		t.Errorf("writeSnapshot() PNG size = %v; want 16x8", img.Bounds())
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
+)
+
+func init() {
+	registerCommand(&source{})
+}
+
+type source struct{}
+
+func (source) name() string {
+	return "source"
+}
+
+func (source) alias() []string {
+	return []string{"run"}
+}
+
+func (s source) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	res, _ := s.run(c, f, asyncOut)
+
+	return res
+}
+
+// run executes the script, returning the error that stopped it so that the script sourcing it stops as well.
+func (source) run(c ip.ClientAPI, f []string, asyncOut chan<- string) (string, error) {
+	if len(f) < 1 {
+		return "source error: missing script file\n", errors.New("missing script file")
+	}
+
+	w := bufio.NewWriter(asyncWriter{out: asyncOut})
+	if err := runScript(f[0], f[1:], w, c); err != nil {
+		return fmt.Sprintf("source error: %s\n", err), err
+	}
+
+	return "", nil
+}
+
+func (s source) help() string {
+	help := `"` + s.name() + `" executes the shell commands found in a script file, which can use variables, delays and conditionals. See the README for the script syntax.` + "\n"
+	help += helpAddAliases(s.alias())
+
+	if args := s.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		for i, arg := range args {
+			switch i {
+			case 0:
+				help += "\t- the " + arg + " to execute\n"
+			case 1:
+				help += "\t- any number of " + arg + ", available in the script as $1, $2 and so on\n"
+			}
+		}
+	}
+
+	return help
+}
+
+func (source) arguments() []string {
+	return []string{"filepath", "arguments"}
+}
+
+func (source) usage() string {
+	return "source filepath [argument...]"
+}
+
+func (source) examples() []string {
+	return []string{
+		"source bracketing.ptp",
+		"source timelapse.ptp 100 5s",
+	}
+}
+
+// asyncWriter sends everything written to it to the asynchronous output channel of a command, so that a command can
+// stream the output of the commands it executes itself.
+type asyncWriter struct {
+	out chan<- string
+}
+
+func (w asyncWriter) Write(b []byte) (int, error) {
+	w.out <- strings.TrimSuffix(string(b), "\n")
+
+	return len(b), nil
+}
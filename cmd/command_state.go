@@ -18,7 +18,7 @@ func (state) alias() []string {
 	return []string{}
 }
 
-func (state) execute(c *ip.Client, f []string, _ chan<- string) string {
+func (state) execute(c ip.ClientAPI, f []string, _ chan<- string) string {
 	res, err := c.GetDeviceState()
 
 	if err != nil {
@@ -49,3 +49,14 @@ func (i state) help() string {
 func (state) arguments() []string {
 	return []string{"json", "pretty"}
 }
+
+func (state) usage() string {
+	return "state [json [pretty]]"
+}
+
+func (state) examples() []string {
+	return []string{
+		"state",
+		"state json",
+	}
+}
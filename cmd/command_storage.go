@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"strconv"
+)
+
+func init() {
+	registerCommand(&storage{})
+}
+
+// formatStorageBytes formats an optional number of bytes of a StorageInfo dataset, which the camera sets to
+// 0xFFFFFFFF, or all bits set, when it does not report it.
+func formatStorageBytes(n uint64) string {
+	if n == 0xFFFFFFFF || n == 0xFFFFFFFFFFFFFFFF {
+		return "-"
+	}
+
+	return formatBytes(int64(n))
+}
+
+type storage struct{}
+
+func (storage) name() string {
+	return "storage"
+}
+
+func (storage) alias() []string {
+	return []string{"df"}
+}
+
+func (s storage) execute(c ip.ClientAPI, _ []string, _ chan<- string) string {
+	storages, err := c.GetStorages()
+	if err != nil {
+		return fmt.Sprintf("storage error: %s\n", err)
+	}
+	if len(storages) == 0 {
+		return "no storage found\n"
+	}
+
+	w, buf := newTabWriter()
+	rows := [][]string{
+		{"ID", "Description", "Label", "Type", "Filesystem", "Access", "Capacity", "Free", "Free images"},
+		{"--", "-----------", "-----", "----", "----------", "------", "--------", "----", "-----------"},
+	}
+	for _, st := range storages {
+		images := "-"
+		if st.Info.FreeSpaceInImages != 0xFFFFFFFF {
+			images = strconv.FormatUint(uint64(st.Info.FreeSpaceInImages), 10)
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf("%#x", st.ID),
+			st.Info.StorageDescription,
+			st.Info.VolumeLabel,
+			st.Info.StorageType.String(),
+			st.Info.FilesystemType.String(),
+			st.Info.AccessCapability.String(),
+			formatStorageBytes(st.Info.MaxCapacity),
+			formatStorageBytes(st.Info.FreeSpaceInBytes),
+			images,
+		})
+	}
+	formatRows(w, rows)
+
+	return buf.String()
+}
+
+func (s storage) help() string {
+	help := `"` + s.name() + `" lists the stores of the camera, such as its memory cards, together with their capacity and free space. Memory card slots without a card are not listed.` + "\n"
+	help += helpAddAliases(s.alias())
+
+	return help
+}
+
+func (storage) arguments() []string {
+	return []string{}
+}
+
+func (storage) usage() string {
+	return "storage"
+}
+
+func (storage) examples() []string {
+	return []string{
+		"storage",
+		"df",
+	}
+}
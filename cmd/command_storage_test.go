@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestFormatStorageBytes(t *testing.T) {
+	check := map[uint64]string{
+		4200:               "4.2 kB",
+		0xFFFFFFFF:         "-",
+		0xFFFFFFFFFFFFFFFF: "-",
+	}
+
+	for n, want := range check {
+		if got := formatStorageBytes(n); got != want {
+			t.Errorf("formatStorageBytes(%d) got = %s; want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+)
+
+func init() {
+	registerCommand(&syncDir{})
+}
+
+type syncDir struct{}
+
+func (syncDir) name() string {
+	return "sync"
+}
+
+func (syncDir) alias() []string {
+	return []string{}
+}
+
+// longRunning always returns true: downloading many objects can take any amount of time.
+func (syncDir) longRunning(_ []string) bool {
+	return true
+}
+
+func (s syncDir) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	res, _ := s.run(c, f, asyncOut)
+
+	return res
+}
+
+func (s syncDir) run(c ip.ClientAPI, f []string, asyncOut chan<- string) (string, error) {
+	if len(f) < 1 {
+		return "sync error: missing directory\n", fmt.Errorf("missing directory")
+	}
+
+	res, err := downloadObjects(c, f[0], f[1:], true, asyncOut)
+	if err != nil {
+		return res + fmt.Sprintf("sync error: %s\n", err), err
+	}
+
+	return res, nil
+}
+
+func (s syncDir) help() string {
+	help := `"` + s.name() + `" downloads the objects stored on the camera that have not been downloaded to the directory yet. The downloaded objects are recorded in the ` + catalogFile + ` catalog of the directory using their file name, size, capture date and hash, so that they are not downloaded again after the camera renumbered them or after the files have been moved or deleted. Files with the same name and size are considered to be downloaded as well.` + "\n"
+
+	if args := s.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		help += "\t- the " + args[0] + " to synchronise, which is created when it does not exist, or the URL of remote storage: s3://bucket/prefix for S3-compatible object storage, configured using the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION and AWS_ENDPOINT_URL environment variables, or sftp://user@host/path for an SFTP server, using the OpenSSH sftp client and SSH keys\n"
+		help += helpAddObjectFilters()
+		help += helpAddRateLimit()
+		help += helpAddBurstMode()
+		help += helpAddFileNaming(collisionRename)
+		help += helpAddSidecar()
+		help += helpAddTranscoding()
+	}
+
+	return help
+}
+
+func (syncDir) arguments() []string {
+	return []string{"directory", "filter"}
+}
+
+func (syncDir) usage() string {
+	return "sync directory [filter...] [limit=rate] [bursts=folder|first] [name=template] [collision=overwrite|skip|rename] [xmp] [transcode=name[:arg]]"
+}
+
+func (syncDir) examples() []string {
+	return []string{
+		"sync /tmp/photos",
+		"sync /tmp/photos format=jpeg,raw",
+		"sync /tmp/photos limit=500kB",
+		"sync /tmp/photos bursts=first",
+		"sync /tmp/photos name={date:2006/01/02}/{camera}-{counter}",
+		"sync s3://photos/studio format=jpeg",
+		"sync sftp://photographer@nas.local/srv/photos",
+		"sync /tmp/photos transcode=heic2jpeg",
+	}
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
 )
 
 // No init function here!!!
 
-type unknown struct{}
+// unknown is returned by commandByName when no command goes by the given name.
+type unknown struct {
+	cmd string
+}
 
 func (unknown) name() string {
 	return "unknown"
@@ -16,8 +20,13 @@ func (unknown) alias() []string {
 	return []string{}
 }
 
-func (unknown) execute(_ *ip.Client, _ []string, _ chan<- string) string {
-	return "unknown command\n"
+func (u unknown) execute(_ ip.ClientAPI, _ []string, _ chan<- string) string {
+	res := `unknown command "` + u.cmd + `"`
+	if s := suggestCommands(u.cmd); len(s) > 0 && u.cmd != "" {
+		res += `, did you mean "` + strings.Join(s, `" or "`) + `"?`
+	}
+
+	return res + "\n" + `Use "help" to list all supported commands.` + "\n"
 }
 
 func (c unknown) help() string {
@@ -27,3 +36,11 @@ func (c unknown) help() string {
 func (unknown) arguments() []string {
 	return []string{}
 }
+
+func (unknown) usage() string {
+	return ""
+}
+
+func (unknown) examples() []string {
+	return []string{}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"time"
+)
+
+func init() {
+	registerCommand(&watch{})
+}
+
+// watchEventBuffer is the number of events buffered while the output is being written. Events arriving when the buffer
+// is full are dropped because the event listener must never be blocked.
+const watchEventBuffer = 32
+
+// watchLine is the JSON representation of a single line printed by the watch command.
+type watchLine struct {
+	Time       string   `json:"time"`
+	Type       string   `json:"type"`
+	Code       string   `json:"code"`
+	Name       string   `json:"name,omitempty"`
+	Old        string   `json:"old,omitempty"`
+	New        string   `json:"new,omitempty"`
+	Initial    bool     `json:"initial,omitempty"`
+	Parameters []string `json:"parameters,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// String formats the line for humans, e.g. "15:04:05 property ISO (0xd02a): 400 -> 800".
+func (l watchLine) String() string {
+	name := l.Code
+	if l.Name != "" {
+		name = l.Name + " (" + l.Code + ")"
+	}
+
+	res := l.Time + " " + l.Type + " " + name
+	switch {
+	case l.Error != "":
+		res += ": error " + l.Error
+	case l.Type == "event":
+		for _, p := range l.Parameters {
+			res += " " + p
+		}
+	case l.Initial:
+		res += ": " + l.New
+	default:
+		res += ": " + l.Old + " -> " + l.New
+	}
+
+	return res
+}
+
+// propertyChangeLine converts a property change to a line of output.
+func propertyChangeLine(vendor ptp.VendorExtension, pc ip.PropertyChange) watchLine {
+	l := watchLine{
+		Time: pc.Time.Format("15:04:05"),
+		Type: "property",
+		Code: ptpfmt.ConvertToHexString(uint16(pc.Code)),
+		Name: ptpfmt.DevicePropCodeAsString(pc.Code),
+	}
+	if pc.Err != nil {
+		l.Error = pc.Err.Error()
+		return l
+	}
+
+	l.New = watchPropValue(vendor, pc.Code, pc.New)
+	l.Initial = pc.Initial
+	if !pc.Initial {
+		l.Old = watchPropValue(vendor, pc.Code, pc.Old)
+	}
+
+	return l
+}
+
+// watchPropValue formats a property value, falling back to hexadecimal notation for values the fmt package does not
+// know.
+func watchPropValue(vendor ptp.VendorExtension, code ptp.DevicePropCode, v uint32) string {
+	if s := ptpfmt.DevicePropValAsString(vendor, code, int64(v)); s != "" {
+		return s
+	}
+
+	return ptpfmt.ConvertToHexString(v)
+}
+
+// eventLine converts an event received at the given time to a line of output.
+func eventLine(vendor ptp.VendorExtension, p ip.EventPacket, t time.Time) watchLine {
+	return watchLine{
+		Time:       t.Format("15:04:05"),
+		Type:       "event",
+		Code:       ptpfmt.ConvertToHexString(uint16(p.GetEventCode())),
+		Name:       ptpfmt.EventCodeAsString(vendor, p.GetEventCode()),
+		Parameters: []string{ptpfmt.ConvertToHexString(p.GetParameter1())},
+	}
+}
+
+type watch struct{}
+
+func (watch) name() string {
+	return "watch"
+}
+
+func (watch) alias() []string {
+	return []string{}
+}
+
+// longRunning always returns true: watch runs until it is stopped.
+func (watch) longRunning(_ []string) bool {
+	return true
+}
+
+func (w watch) execute(c ip.ClientAPI, f []string, asyncOut chan<- string) string {
+	errorFmt := "watch error: %s\n"
+
+	interval := ip.DefaultWatchInterval
+	asJSON := false
+	for _, arg := range f {
+		if arg == "json" {
+			asJSON = true
+			continue
+		}
+		d, err := parseDuration(arg)
+		if err != nil || d <= 0 {
+			return fmt.Sprintf(errorFmt, fmt.Sprintf("invalid interval %s", arg))
+		}
+		interval = d
+	}
+
+	vendor := c.ResponderVendor()
+	var codes []ptp.DevicePropCode
+	for _, field := range ptpfmt.UnifiedFieldNames {
+		if code, err := ptpfmt.PropNameToDevicePropCode(vendor, field); err == nil {
+			codes = append(codes, code)
+		}
+	}
+
+	out := func(l watchLine) {
+		if !asJSON {
+			asyncOut <- l.String()
+			return
+		}
+		b, err := json.Marshal(l)
+		if err != nil {
+			logger.Errorf("watch error: %s", err)
+			return
+		}
+		asyncOut <- string(b)
+	}
+
+	events := make(chan watchLine, watchEventBuffer)
+	removeHandler := c.OnEvent(func(p ip.EventPacket) {
+		select {
+		case events <- eventLine(vendor, p, time.Now()):
+		default:
+		}
+	})
+	defer removeHandler()
+
+	stop := c.WatchProperties(codes, interval, func(pc ip.PropertyChange) {
+		out(propertyChangeLine(vendor, pc))
+	})
+	defer stop()
+
+	for {
+		select {
+		case l := <-events:
+			out(l)
+		case <-quit:
+			return "watch stopped\n"
+		}
+	}
+}
+
+func (w watch) help() string {
+	help := `"` + w.name() + `" prints the properties that change and the events sent by the camera until the program is interrupted. The current value of each property is printed first.` + "\n"
+
+	if args := w.arguments(); len(args) > 0 {
+		help += helpAddArgumentsTitle()
+		help += "\t- the " + args[0] + " at which the properties are read, e.g. 500ms or a number of seconds, which defaults to one second\n"
+		help += "\t- " + args[1] + " to print each change as a JSON object on a line of its own\n"
+		help += "\tThe properties watched are:\n" + helpAddUnifiedFieldNames()
+	}
+
+	return help
+}
+
+func (watch) arguments() []string {
+	return []string{"interval", "json"}
+}
+
+func (watch) usage() string {
+	return "watch [interval] [json]"
+}
+
+func (watch) examples() []string {
+	return []string{
+		"watch",
+		"watch 500ms",
+		"watch 2 json",
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"testing"
+	"time"
+)
+
+func TestPropertyChangeLine(t *testing.T) {
+	tm := time.Date(2021, 3, 14, 15, 30, 5, 0, time.Local)
+	vendor := ptp.VE_MicrosoftCorporation
+
+	tests := []struct {
+		pc   ip.PropertyChange
+		want string
+	}{
+		{
+			ip.PropertyChange{Code: ptp.DPC_WhiteBalance, New: uint32(ptp.WB_Automatic), Initial: true, Time: tm},
+			"15:30:05 property white balance (0x5005): automatic",
+		},
+		{
+			ip.PropertyChange{Code: ptp.DPC_WhiteBalance, Old: uint32(ptp.WB_Automatic), New: uint32(ptp.WB_Daylight), Time: tm},
+			"15:30:05 property white balance (0x5005): automatic -> daylight",
+		},
+		{
+			ip.PropertyChange{Code: ptp.DevicePropCode(0xd0ff), Old: 1, New: 2, Time: tm},
+			"15:30:05 property 0xd0ff: 0x1 -> 0x2",
+		},
+		{
+			ip.PropertyChange{Code: ptp.DPC_WhiteBalance, Err: errors.New("busy"), Time: tm},
+			"15:30:05 property white balance (0x5005): error busy",
+		},
+	}
+	for _, tt := range tests {
+		if got := propertyChangeLine(vendor, tt.pc).String(); got != tt.want {
+			t.Errorf("propertyChangeLine() got = '%s'; want '%s'", got, tt.want)
+		}
+	}
+}
+
+func TestEventLine(t *testing.T) {
+	tm := time.Date(2021, 3, 14, 15, 30, 5, 0, time.Local)
+	p := &ip.GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_ObjectAdded, Parameter1: []byte{0x10}}}
+
+	got := eventLine(ptp.VE_MicrosoftCorporation, p, tm).String()
+	want := "15:30:05 event object added (0x4002) 0x10"
+	if got != want {
+		t.Errorf("eventLine() got = '%s'; want '%s'", got, want)
+	}
+}
+
+func TestWatch_execute(t *testing.T) {
+	got := watch{}.execute(&ip.Client{}, []string{"soon"}, make(chan string))
+	want := "watch error: invalid interval soon\n"
+	if got != want {
+		t.Errorf("execute() got = '%s'; want '%s'", got, want)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"bufio"
 	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
 	"github.com/malc0mn/ptp-ip/ip"
-	"log"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -19,9 +19,53 @@ type command interface {
 	name() string
 	alias() []string
 	// TODO: is there a more elegant solution to drop at least the async output channel argument here...?
-	execute(*ip.Client, []string, chan<- string) string
+	execute(ip.ClientAPI, []string, chan<- string) string
 	help() string
 	arguments() []string
+	// usage returns a one line synopsis of the command and its arguments.
+	usage() string
+	// examples returns a few invocations of the command to include in its help output.
+	examples() []string
+}
+
+// failingCommand is implemented by commands that report a failure as an error, so that executing the command using
+// the -c flag results in a non-zero exit code. The output returned by run includes the error message, making execute
+// and run interchangeable for the user.
+type failingCommand interface {
+	command
+	run(ip.ClientAPI, []string, chan<- string) (string, error)
+}
+
+// destructiveCommand is implemented by commands that can destroy data on the camera, such as deleting objects or
+// formatting a memory card. In server mode, only the client holding the camera lock may execute them, see jobQueue.
+type destructiveCommand interface {
+	command
+	destructive([]string) bool
+}
+
+// standaloneCommand is implemented by commands that do not talk to the camera, so that they can be executed using the -c
+// flag without connecting to a camera first.
+type standaloneCommand interface {
+	command
+	standalone()
+}
+
+// longRunningCommand is implemented by commands that run until they are stopped, such as watching events, or that take
+// as long as the amount of data they transfer requires. In server mode, they are not subject to the command timeout,
+// see jobQueue.
+type longRunningCommand interface {
+	command
+	longRunning([]string) bool
+}
+
+// isLongRunning returns true when the command line executes a long-running command.
+func isLongRunning(f []string) bool {
+	if len(f) == 0 {
+		return false
+	}
+	cmd, ok := commandByName(f[0]).(longRunningCommand)
+
+	return ok && cmd.longRunning(f[1:])
 }
 
 func registerCommand(cmd command) {
@@ -59,29 +103,52 @@ func helpAddArgumentsTitle() string {
 	return "\tAllowed arguments:\n"
 }
 
+// hasFlag removes the given flag, such as --preview, from the command arguments and returns true when it was present.
+func hasFlag(f []string, flag string) ([]string, bool) {
+	for i, arg := range f {
+		if arg == flag {
+			return append(f[:i:i], f[i+1:]...), true
+		}
+	}
+
+	return f, false
+}
+
 func helpAddUnifiedFieldNames() string {
 	return "\t" + `  "` + strings.Join(ptpfmt.UnifiedFieldNames, `", "`) + `"` + "\n"
 }
 
-func readAndExecuteCommand(rw *bufio.ReadWriter, c *ip.Client, lmp string) {
-	msg, err := rw.ReadString('\n')
+// readCommand reads a single command line, returning false when there is no command to execute.
+func readCommand(r *bufio.Reader, lmp string) (string, bool) {
+	msg, err := r.ReadString('\n')
 	if err != nil {
-		log.Printf("%s error reading message '%s'", lmp, err)
-		return
+		logger.Errorf("%s error reading message '%s'", lmp, err)
+		return "", false
 	}
 	msg = strings.TrimSuffix(msg, "\n")
 	if msg == "" {
-		log.Printf("%s ignoring empty message!", lmp)
-		return
+		logger.Warnf("%s ignoring empty message!", lmp)
+		return "", false
 	}
-	log.Printf("%s message received: '%s'", lmp, msg)
+	logger.Infof("%s message received: '%s'", lmp, msg)
 
-	executeCommand(msg, rw.Writer, c, lmp)
+	return msg, true
 }
 
-func executeCommand(msg string, w *bufio.Writer, c *ip.Client, lmp string) {
+// executeCommand executes the command line and writes the output to w. The error returned is the failure reported by
+// commands implementing failingCommand, or the refusal of a destructive command the client of a jobQueue is not allowed
+// to execute.
+func executeCommand(msg string, w *bufio.Writer, c ip.ClientAPI, lmp string) error {
 	var wg sync.WaitGroup
 	f := strings.Fields(msg)
+
+	if sc, ok := sharedClientOf(c); ok {
+		if err := sc.checkDestructive(f); err != nil {
+			w.WriteString(err.Error() + "\n")
+			w.Flush()
+			return err
+		}
+	}
 	asyncOut := make(chan string)
 
 	// Launch async output routine.
@@ -89,27 +156,40 @@ func executeCommand(msg string, w *bufio.Writer, c *ip.Client, lmp string) {
 	go func() {
 		for msg := range asyncOut {
 			if _, err := w.Write([]byte(msg + "\n")); err != nil {
-				log.Printf("%s error writing response: '%s'", lmp, err)
+				logger.Errorf("%s error writing response: '%s'", lmp, err)
 				continue
 			}
 			if err := w.Flush(); err != nil {
-				log.Printf("%s error flushing buffer: '%s'", lmp, err)
+				logger.Errorf("%s error flushing buffer: '%s'", lmp, err)
 			}
 		}
 		wg.Done()
 	}()
 
-	_, err := w.Write([]byte(commandByName(f[0]).execute(c, f[1:], asyncOut)))
+	var (
+		res    string
+		cmdErr error
+	)
+	if cmd, ok := commandByName(f[0]).(failingCommand); ok {
+		res, cmdErr = cmd.run(c, f[1:], asyncOut)
+	} else {
+		res = commandByName(f[0]).execute(c, f[1:], asyncOut)
+	}
+
+	// The asynchronous output routine must be done with w before the result is written to it.
 	close(asyncOut)
 	wg.Wait()
+	_, err := w.Write([]byte(res))
 	if err != nil {
-		log.Printf("%s error writing response: '%s'", lmp, err)
-		return
+		logger.Errorf("%s error writing response: '%s'", lmp, err)
+		return cmdErr
 	}
 	err = w.Flush()
 	if err != nil {
-		log.Printf("%s error flushing buffer: '%s'", lmp, err)
+		logger.Errorf("%s error flushing buffer: '%s'", lmp, err)
 	}
+
+	return cmdErr
 }
 
 func commandByName(n string) command {
@@ -121,5 +201,119 @@ func commandByName(n string) command {
 		return cmd
 	}
 
-	return &unknown{}
+	if body, exists := macroByName(n); exists {
+		return &runMacro{macro: n, body: body}
+	}
+
+	return &unknown{cmd: n}
+}
+
+// isStandalone returns true when the command line executes a command that does not need a connection to the camera.
+func isStandalone(msg string) bool {
+	f := strings.Fields(msg)
+	if len(f) == 0 {
+		return false
+	}
+	_, ok := commandByName(f[0]).(standaloneCommand)
+
+	return ok
+}
+
+// isCommand returns true when n is the name or an alias of a command.
+func isCommand(n string) bool {
+	if _, exists := aliases[n]; exists {
+		return true
+	}
+	_, exists := commands[n]
+
+	return exists
+}
+
+// commandNames returns the names of all registered commands, sorted alphabetically.
+func commandNames() []string {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// suggestCommands returns the names of the commands resembling n, for when n is not a known command or alias. A
+// command resembles n when one starts with the other or when they differ by a single typo, or two for longer names.
+func suggestCommands(n string) []string {
+	maxDist := 1
+	if len(n) > 4 {
+		maxDist = 2
+	}
+
+	var res []string
+	for _, name := range commandNames() {
+		cmd := commands[name]
+		for _, cand := range append([]string{name}, cmd.alias()...) {
+			if strings.HasPrefix(cand, n) || strings.HasPrefix(n, cand) || editDistance(cand, n) <= maxDist {
+				res = append(res, name)
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// editDistance computes the optimal string alignment distance between a and b: the number of insertions, deletions,
+// substitutions and transpositions of adjacent characters needed to turn a into b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+func minInt(v ...int) int {
+	m := v[0]
+	for _, i := range v[1:] {
+		if i < m {
+			m = i
+		}
+	}
+
+	return m
+}
+
+// helpAddExamples formats the examples of a command for its help output.
+func helpAddExamples(examples []string) string {
+	if len(examples) == 0 {
+		return ""
+	}
+
+	help := "	Examples:\n"
+	for _, ex := range examples {
+		help += "	  " + ex + "\n"
+	}
+
+	return help
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
 	"testing"
 )
 
@@ -10,16 +11,31 @@ func TestCommandByName(t *testing.T) {
 	cmds := map[string]command{
 		"capture":  &capture{},
 		"describe": &describe{},
+		"discover": &discover{},
+		"download": &download{},
+		"dl":       &download{},
 		"get":      &get{},
 		"help":     &help{},
 		"info":     &info{},
 		"liveview": &liveview{},
+		"objects":  &objects{},
+		"ls":       &objects{},
 		"opreq":    &opreq{},
+		"rawsend":  &rawsend{},
 		"shoot":    &capture{},
 		"shutter":  &capture{},
 		"snap":     &capture{},
+		"snapshot": &snapshot{},
+		"lvsnap":   &snapshot{},
 		"set":      &set{},
 		"state":    &state{},
+		"storage":  &storage{},
+		"lock":     &lock{},
+		"unlock":   &unlock{},
+		"source":   &source{},
+		"run":      &source{},
+		"sync":     &syncDir{},
+		"watch":    &watch{},
 	}
 	for name, want := range cmds {
 		got := commandByName(name)
@@ -30,9 +46,50 @@ func TestCommandByName(t *testing.T) {
 }
 
 func TestUnknown(t *testing.T) {
-	got := unknown{}.execute(&ip.Client{}, []string{}, make(chan string))
-	want := "unknown command\n"
+	got := unknown{cmd: "captur"}.execute(&ip.Client{}, []string{}, make(chan string))
+	want := `unknown command "captur", did you mean "capture"?` + "\n" + `Use "help" to list all supported commands.` + "\n"
 	if got != want {
 		t.Errorf("got = '%s'; want '%s'", got, want)
 	}
+
+	got = commandByName("xyzzy").execute(&ip.Client{}, []string{}, make(chan string))
+	want = `unknown command "xyzzy"` + "\n" + `Use "help" to list all supported commands.` + "\n"
+	if got != want {
+		t.Errorf("got = '%s'; want '%s'", got, want)
+	}
+}
+
+func TestSuggestCommands(t *testing.T) {
+	tests := map[string]string{
+		"gte":    "get",
+		"desc":   "describe",
+		"shoott": "capture",
+		"lvsnp":  "snapshot",
+	}
+	for in, want := range tests {
+		if got := suggestCommands(in); len(got) == 0 || got[0] != want {
+			t.Errorf("suggestCommands(%s) got = %v; want %s", in, got, want)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	got := help{}.execute(nil, []string{}, nil)
+	for _, name := range commandNames() {
+		if !strings.Contains(got, "\n  "+commands[name].usage()+"\n") {
+			t.Errorf("help got = %s; want usage of %s", got, name)
+		}
+	}
+
+	got = help{}.execute(nil, []string{"shoot"}, nil)
+	for _, want := range []string{"Usage: capture [amount] [view | filepath] [collision=overwrite|skip|rename] [--preview]\n", "Allowed arguments:", "Examples:\n\t  capture\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("help shoot got = %s; want it to contain %s", got, want)
+		}
+	}
+
+	got = help{}.execute(nil, []string{"stat"}, nil)
+	if !strings.Contains(got, `did you mean "state"`) {
+		t.Errorf("help stat got = %s; want a suggestion", got)
+	}
 }
@@ -2,11 +2,10 @@ package main
 
 import (
 	"errors"
-	"fmt"
-	"github.com/go-ini/ini"
 	"github.com/malc0mn/ptp-ip/ip"
 	"log"
-	"os"
+	"strings"
+	"time"
 )
 
 type config struct {
@@ -18,28 +17,45 @@ type config struct {
 	sport  uint16Value
 	fname  string
 	guid   string
+	// bleAddr is the Bluetooth address of the responder and bleWake the sequence waking it up, see wakeCamera.
+	bleAddr string
+	bleWake string
+	// wifiSSID is the SSID pattern of the WiFi network hosted by the responder, see joinCameraNetwork.
+	wifiSSID     string
+	wifiPassword string
 
 	srvAddr string
 	srvPort uint16Value
+	webPort uint16Value
+	// grpcPort is the port of the gRPC server, see grpcServer.
+	grpcPort uint16Value
+	// wsOrigins holds the origins allowed to use the WebSocket API besides the web UI, see originAllowed.
+	wsOrigins listValue
+	pidFile   string
+	// cmdTimeout is the time a client of the servers or the interactive shell waits for a command, see jobQueue.
+	cmdTimeout time.Duration
+
+	// cameras holds the named camera profiles found in the config file.
+	cameras map[string]*camera
 }
 
 var (
 	portSpecAmbiguous = errors.New("ambiguous port specification: use a single port OR define multiple ports")
 
 	conf = &config{
-		vendor:  ip.DefaultVendor,
-		host:    ip.DefaultIpAddress,
-		port:    uint16Value(ip.DefaultPort),
-		srvAddr: defaultIp,
-		srvPort: uint16Value(ip.DefaultPort),
+		vendor:     ip.DefaultVendor,
+		host:       ip.DefaultIpAddress,
+		port:       uint16Value(ip.DefaultPort),
+		srvAddr:    defaultIp,
+		srvPort:    uint16Value(ip.DefaultPort),
+		cmdTimeout: defaultCommandTimeout,
 	}
 )
 
 func loadConfig() {
-	f, err := ini.Load(file)
+	f, err := readConfigFile(file)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening config file - %s\n", err)
-		os.Exit(errOpenConfig)
+		fail(nil, errOpenConfig, "opening config file", err)
 	}
 
 	// Initiator
@@ -80,6 +96,60 @@ func loadConfig() {
 				log.Fatal(valueOutOfRange)
 			}
 		}
+		if k, err := i.GetKey("ble_address"); err == nil {
+			conf.bleAddr = k.String()
+		}
+		if k, err := i.GetKey("ble_wake"); err == nil {
+			conf.bleWake = k.String()
+		}
+		if k, err := i.GetKey("wifi_ssid"); err == nil {
+			conf.wifiSSID = k.String()
+		}
+		if k, err := i.GetKey("wifi_password"); err == nil {
+			conf.wifiPassword = k.String()
+		}
+	}
+
+	// Cameras
+	for _, s := range f.Sections() {
+		if name := strings.TrimPrefix(s.Name(), cameraSectionPrefix); name != s.Name() && name != "" {
+			if conf.cameras == nil {
+				conf.cameras = make(map[string]*camera)
+			}
+			conf.cameras[name] = loadCamera(name, s)
+		}
+	}
+
+	// Macros, download hooks, transcoders, logging and the command timeout
+	rs, err := readReloadableSettings(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rs.apply()
+
+	// Live view
+	if i, err := f.GetSection("liveview"); err == nil {
+		if k, err := i.GetKey("decoder"); err == nil && k.String() != "" {
+			if err := lvDecoder.Set(k.String()); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if k, err := i.GetKey("review"); err == nil && k.String() != "" {
+			if err := setReviewDuration(k.String()); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	// Viewfinder
+	if i, err := f.GetSection("viewfinder"); err == nil {
+		for _, key := range themeKeys {
+			if k, err := i.GetKey(key); err == nil {
+				if err := vfTheme.set(key, k.String()); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
 	}
 
 	// Server
@@ -97,6 +167,22 @@ func loadConfig() {
 				log.Fatal(valueOutOfRange)
 			}
 		}
+		if k, err := i.GetKey("web_port"); err == nil {
+			if err := conf.webPort.Set(k.String()); err != nil {
+				log.Fatal(valueOutOfRange)
+			}
+		}
+		if k, err := i.GetKey("grpc_port"); err == nil {
+			if err := conf.grpcPort.Set(k.String()); err != nil {
+				log.Fatal(valueOutOfRange)
+			}
+		}
+		if k, err := i.GetKey("ws_origins"); err == nil {
+			conf.wsOrigins.Set(k.String())
+		}
+		if k, err := i.GetKey("pid_file"); err == nil {
+			conf.pidFile = k.String()
+		}
 	}
 }
 
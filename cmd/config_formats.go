@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/go-ini/ini"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Supported config file formats, determined by the extension of the config file.
+const (
+	formatINI  = "ini"
+	formatTOML = "toml"
+	formatYAML = "yaml"
+)
+
+// configFormat returns the format of the config file based on its extension. Anything unknown is considered to be INI.
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return formatTOML
+	case ".yaml", ".yml":
+		return formatYAML
+	}
+
+	return formatINI
+}
+
+// readConfigFile loads the config file in any of the supported formats. The config schema only consists of sections
+// holding simple key/value pairs, so the TOML and YAML files are converted to an INI file which keeps the rest of the
+// config handling format agnostic.
+func readConfigFile(path string) (*ini.File, error) {
+	format := configFormat(path)
+	if format == formatINI {
+		return parseINIConfig(path)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == formatTOML {
+		return parseTOMLConfig(b)
+	}
+
+	return parseYAMLConfig(b)
+}
+
+// parseINIConfig parses an INI file, src being a path or the contents. Comments following a value must be preceded by a
+// space so that values such as "#ff8000" or "set iso 0xc8; capture" can be used.
+func parseINIConfig(src interface{}) (*ini.File, error) {
+	return ini.LoadSources(ini.LoadOptions{SpaceBeforeInlineComment: true}, src)
+}
+
+// parseTOMLConfig parses the subset of TOML needed for the config file: tables holding strings, integers and booleans.
+func parseTOMLConfig(b []byte) (*ini.File, error) {
+	f := ini.Empty()
+	sec := f.Section(ini.DefaultSection)
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			name, _, _ := strings.Cut(line, "#")
+			name = strings.TrimSpace(name)
+			if !strings.HasSuffix(name, "]") {
+				return nil, fmt.Errorf("line %d: invalid table %s", n, line)
+			}
+			var err error
+			if sec, err = f.NewSection(strings.TrimSpace(name[1 : len(name)-1])); err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			continue
+		}
+
+		key, val, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		if err := addConfigKey(sec, key, val); err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+	}
+
+	return f, s.Err()
+}
+
+// parseYAMLConfig parses the subset of YAML needed for the config file: nested mappings of sections, the innermost
+// ones holding scalars. Nested section names are joined using a dot, so the camera profiles end up in the same sections
+// as they do in the INI and TOML formats.
+func parseYAMLConfig(b []byte) (*ini.File, error) {
+	f := ini.Empty()
+
+	type level struct {
+		indent int
+		name   string
+	}
+	var path []level
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' || trimmed == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		key, val, found := strings.Cut(trimmed, ":")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key: value", n)
+		}
+		key = strings.TrimSpace(key)
+
+		for len(path) > 0 && path[len(path)-1].indent >= indent {
+			path = path[:len(path)-1]
+		}
+
+		if val = strings.TrimSpace(val); val == "" || val[0] == '#' {
+			path = append(path, level{indent: indent, name: key})
+			continue
+		}
+
+		if len(path) == 0 {
+			return nil, fmt.Errorf("line %d: key %s outside of a section", n, key)
+		}
+		names := make([]string, len(path))
+		for i, l := range path {
+			names[i] = l.name
+		}
+		sec, err := f.NewSection(strings.Join(names, "."))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		if err := addConfigKey(sec, key, val); err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+	}
+
+	return f, s.Err()
+}
+
+func addConfigKey(sec *ini.Section, key, val string) error {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+	v, err := parseConfigValue(val)
+	if err != nil {
+		return fmt.Errorf("key %s: %s", key, err)
+	}
+	_, err = sec.NewKey(key, v)
+
+	return err
+}
+
+// parseConfigValue parses a scalar value as used in both TOML and YAML: a double quoted string with Go compatible escape
+// sequences, a single quoted string or a plain value. A comment may follow the value.
+func parseConfigValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	var (
+		v    string
+		rest string
+	)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := 1
+		for ; end < len(s) && s[end] != '"'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		var err error
+		if v, err = strconv.Unquote(s[:end+1]); err != nil {
+			return "", fmt.Errorf("invalid string %s", s[:end+1])
+		}
+		rest = s[end+1:]
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		v, rest = s[1:end+1], s[end+2:]
+	default:
+		v = s
+		if i := strings.Index(s, " #"); i != -1 {
+			v = strings.TrimSpace(s[:i])
+		} else if strings.HasPrefix(s, "#") {
+			v = ""
+		}
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected %s after value", rest)
+	}
+
+	return v, nil
+}
+
+// configValue is a single setting of the effective configuration.
+type configValue struct {
+	key   string
+	value string
+	// quote is true for string values.
+	quote bool
+}
+
+// configSection holds the settings of a single config file section.
+type configSection struct {
+	name   string
+	values []configValue
+}
+
+// effectiveConfig returns the configuration as it is after merging the command line flags and the config file. Ports
+// that have not been set are left out.
+func effectiveConfig() []configSection {
+	responder := []configValue{{key: "vendor", value: conf.vendor, quote: true}, {key: "host", value: conf.host, quote: true}}
+	responder = append(responder, portValue("port", conf.port)...)
+	responder = append(responder, portValue("cmd_data_port", conf.cport)...)
+	responder = append(responder, portValue("event_port", conf.eport)...)
+	responder = append(responder, portValue("stream_port", conf.sport)...)
+	responder = append(responder, bleValues(conf.bleAddr, conf.bleWake)...)
+	responder = append(responder, wifiValues(conf.wifiSSID, conf.wifiPassword)...)
+
+	srv := []configValue{
+		{key: "enabled", value: strconv.FormatBool(server)},
+		{key: "address", value: conf.srvAddr, quote: true},
+	}
+	srv = append(srv, portValue("port", conf.srvPort)...)
+	srv = append(srv, portValue("web_port", conf.webPort)...)
+	srv = append(srv, portValue("grpc_port", conf.grpcPort)...)
+	if len(conf.wsOrigins) > 0 {
+		srv = append(srv, configValue{key: "ws_origins", value: conf.wsOrigins.String(), quote: true})
+	}
+	if conf.pidFile != "" {
+		srv = append(srv, configValue{key: "pid_file", value: conf.pidFile, quote: true})
+	}
+	srv = append(srv, configValue{key: "command_timeout", value: conf.cmdTimeout.String(), quote: true})
+
+	var vf []configValue
+	for _, s := range vfTheme.settings() {
+		_, err := strconv.Atoi(s[1])
+		vf = append(vf, configValue{key: s[0], value: s[1], quote: err != nil})
+	}
+
+	secs := []configSection{
+		{name: "initiator", values: []configValue{
+			{key: "friendly_name", value: conf.fname, quote: true},
+			{key: "guid", value: conf.guid, quote: true},
+		}},
+		{name: "responder", values: responder},
+		{name: "logging", values: []configValue{
+			{key: "level", value: verbosity.String(), quote: true},
+			{key: "format", value: logFmt.String(), quote: true},
+		}},
+		{name: "server", values: srv},
+		{name: "liveview", values: []configValue{
+			{key: "decoder", value: lvDecoder.String(), quote: true},
+			{key: "review", value: reviewDuration().String(), quote: true},
+		}},
+		{name: "viewfinder", values: vf},
+	}
+	if names := macroNames(); len(names) > 0 {
+		sec := configSection{name: "macros"}
+		for _, name := range names {
+			body, _ := macroByName(name)
+			sec.values = append(sec.values, configValue{key: name, value: body, quote: true})
+		}
+		secs = append(secs, sec)
+	}
+	if hooks := execHookValues(); len(hooks) > 0 {
+		secs = append(secs, configSection{name: "download_hooks", values: hooks})
+	}
+	if transcoders := execTranscoderValues(); len(transcoders) > 0 {
+		secs = append(secs, configSection{name: "transcoders", values: transcoders})
+	}
+	for _, name := range cameraNames() {
+		secs = append(secs, configSection{name: cameraSectionPrefix + name, values: conf.cameras[name].configValues()})
+	}
+
+	return secs
+}
+
+// portValue returns the given port as a config value, or nothing when the port has not been set.
+func portValue(key string, p uint16Value) []configValue {
+	if p == 0 {
+		return nil
+	}
+
+	return []configValue{{key: key, value: p.String()}}
+}
+
+// bleValues returns the Bluetooth LE wake settings as config values, or nothing when no Bluetooth address has been set.
+func bleValues(addr, wake string) []configValue {
+	if addr == "" {
+		return nil
+	}
+
+	return []configValue{{key: "ble_address", value: addr, quote: true}, {key: "ble_wake", value: wake, quote: true}}
+}
+
+// wifiValues returns the settings of the WiFi network hosted by the responder as config values, or nothing when no SSID
+// has been set. The password is left out when empty.
+func wifiValues(ssid, password string) []configValue {
+	if ssid == "" {
+		return nil
+	}
+
+	vals := []configValue{{key: "wifi_ssid", value: ssid, quote: true}}
+	if password != "" {
+		vals = append(vals, configValue{key: "wifi_password", value: password, quote: true})
+	}
+
+	return vals
+}
+
+// dumpConfig writes the effective configuration to w in the given format.
+func dumpConfig(w io.Writer, format string) {
+	var prev string
+	for i, sec := range effectiveConfig() {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		indent := "  "
+		if format == formatYAML {
+			// Dotted section names become nested mappings.
+			if parent, name, found := strings.Cut(sec.name, "."); found {
+				if !strings.HasPrefix(prev, parent+".") {
+					fmt.Fprintf(w, "%s:\n", parent)
+				}
+				fmt.Fprintf(w, "  %s:\n", name)
+				indent = "    "
+			} else {
+				fmt.Fprintf(w, "%s:\n", sec.name)
+			}
+		} else {
+			fmt.Fprintf(w, "[%s]\n", sec.name)
+		}
+		prev = sec.name
+
+		for _, v := range sec.values {
+			val := v.value
+			if v.quote {
+				val = strconv.Quote(val)
+			}
+
+			if format == formatYAML {
+				fmt.Fprintf(w, "%s%s: %s\n", indent, v.key, val)
+			} else {
+				fmt.Fprintf(w, "%s = %s\n", v.key, val)
+			}
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"github.com/go-ini/ini"
 	"github.com/malc0mn/ptp-ip/ip"
+	"image/color"
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -144,6 +148,33 @@ func TestLoadconfigOk2(t *testing.T) {
 	if conf.srvPort != wantPort {
 		t.Errorf("loadConfig() sport = %d; want %d", conf.srvPort, wantPort)
 	}
+
+	want = "/run/ptpip.pid"
+	if conf.pidFile != want {
+		t.Errorf("loadConfig() pidFile = %s; want %s", conf.pidFile, want)
+	}
+
+	wantTimeout := 90 * time.Second
+	if conf.cmdTimeout != wantTimeout {
+		t.Errorf("loadConfig() cmdTimeout = %s; want %s", conf.cmdTimeout, wantTimeout)
+	}
+
+	th, _ := vfTheme.get()
+	wantColour := color.RGBA{R: 255, G: 128, A: 255}
+	if th.Warning != wantColour {
+		t.Errorf("loadConfig() warning = %v; want %v", th.Warning, wantColour)
+	}
+
+	wantScale := 2
+	if th.Scale != wantScale {
+		t.Errorf("loadConfig() scale = %d; want %d", th.Scale, wantScale)
+	}
+	vfTheme.reset()
+
+	if body, ok := macroByName("studio"); !ok || body != "set iso 0xc8; set whitebalance 0x4" {
+		t.Errorf("loadConfig() macro studio = %s, %v; want set iso 0xc8; set whitebalance 0x4", body, ok)
+	}
+	deleteMacro("studio")
 }
 
 func TestLoadConfigWrongPath(t *testing.T) {
@@ -193,3 +224,152 @@ func TestLoadConfigFail2(t *testing.T) {
 		t.Fatalf("loadConfig() ran with err %v, want exit status %d", err, want)
 	}
 }
+
+func TestLoadConfigTOMLAndYAML(t *testing.T) {
+	for _, f := range []string{"testdata/test_ok3.toml", "testdata/test_ok3.yaml"} {
+		conf = &config{
+			vendor:  ip.DefaultVendor,
+			host:    ip.DefaultIpAddress,
+			port:    uint16Value(ip.DefaultPort),
+			srvAddr: defaultIp,
+			srvPort: uint16Value(ip.DefaultPort),
+		}
+		verbosity = ip.LevelSilent
+
+		file = f
+		loadConfig()
+		checkPorts()
+
+		want := "Golang test OK3 client"
+		if conf.fname != want {
+			t.Errorf("loadConfig() %s fname = %s; want %s", f, conf.fname, want)
+		}
+
+		want = "0a4d3a4c-4f0c-4a47-9e5e-1b9c1a3f4e01"
+		if conf.guid != want {
+			t.Errorf("loadConfig() %s guid = %s; want %s", f, conf.guid, want)
+		}
+
+		want = "fuji"
+		if conf.vendor != want {
+			t.Errorf("loadConfig() %s vendor = %s; want %s", f, conf.vendor, want)
+		}
+
+		want = "192.168.0.3"
+		if conf.host != want {
+			t.Errorf("loadConfig() %s host = %s; want %s", f, conf.host, want)
+		}
+
+		if conf.port != 0 || conf.cport != 55740 || conf.eport != 55741 {
+			t.Errorf("loadConfig() %s ports = %d, %d, %d; want 0, 55740, 55741", f, conf.port, conf.cport, conf.eport)
+		}
+
+		if verbosity != ip.LevelVeryVerbose {
+			t.Errorf("loadConfig() %s verbosity = %d; want %d", f, verbosity, ip.LevelVeryVerbose)
+		}
+
+		if logFmt != logFormatJSON {
+			t.Errorf("loadConfig() %s log format = %s; want %s", f, logFmt, logFormatJSON)
+		}
+
+		want = "127.0.0.4"
+		if !server || conf.srvAddr != want || conf.srvPort != 45740 || conf.webPort != 45741 || conf.grpcPort != 45742 {
+			t.Errorf("loadConfig() %s server = %v %s:%d web %d gRPC %d; want true %s:45740 web 45741 gRPC 45742", f, server, conf.srvAddr, conf.srvPort, conf.webPort, conf.grpcPort, want)
+		}
+
+		want = "cp $1 /mnt/backup/"
+		if execHooks["backup"] != want || downloadHooks["backup"] == nil {
+			t.Errorf("loadConfig() %s download hook backup = %s; want %s", f, execHooks["backup"], want)
+		}
+		delete(execHooks, "backup")
+		delete(downloadHooks, "backup")
+
+		th, _ := vfTheme.get()
+		wantColour := color.RGBA{R: 255, G: 128, A: 255}
+		if th.Warning != wantColour || th.Scale != 3 {
+			t.Errorf("loadConfig() %s warning = %v scale %d; want %v scale 3", f, th.Warning, th.Scale, wantColour)
+		}
+		vfTheme.reset()
+	}
+	verbosity = ip.LevelSilent
+	logFmt = logFormatText
+}
+
+func TestLoadConfigFail3(t *testing.T) {
+	if _, err := readConfigFile("testdata/test_fail3.yaml"); err == nil {
+		t.Error("readConfigFile() err = nil; want error")
+	}
+}
+
+func TestParseConfigValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{` "a # b" # comment`, "a # b", false},
+		{`"tab\there"`, "tab\there", false},
+		{`'C:\path'`, `C:\path`, false},
+		{`15740 # port`, "15740", false},
+		{`true`, "true", false},
+		{`# only a comment`, "", false},
+		{`"unterminated`, "", true},
+		{`"a" b`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseConfigValue(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseConfigValue(%s) got = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDumpConfig(t *testing.T) {
+	conf = &config{
+		vendor:  "fuji",
+		host:    "192.168.0.5",
+		cport:   55740,
+		eport:   55741,
+		fname:   `The "quoted" client`,
+		srvAddr: defaultIp,
+		srvPort: uint16Value(ip.DefaultPort),
+	}
+	vfTheme.set("warning", "#ff8000")
+	defer vfTheme.reset()
+
+	for _, format := range []string{formatINI, formatTOML, formatYAML} {
+		var b bytes.Buffer
+		dumpConfig(&b, format)
+
+		var (
+			f   *ini.File
+			err error
+		)
+		switch format {
+		case formatINI:
+			f, err = parseINIConfig(b.Bytes())
+		case formatTOML:
+			f, err = parseTOMLConfig(b.Bytes())
+		case formatYAML:
+			f, err = parseYAMLConfig(b.Bytes())
+		}
+		if err != nil {
+			t.Fatalf("dumpConfig() %s output does not parse: %s\n%s", format, err, b.String())
+		}
+
+		if got := f.Section("responder").Key("cmd_data_port").String(); got != "55740" {
+			t.Errorf("dumpConfig() %s cmd_data_port = %s; want 55740", format, got)
+		}
+		if f.Section("responder").HasKey("port") {
+			t.Errorf("dumpConfig() %s got port; want it to be left out", format)
+		}
+		if got, err := parseColour(f.Section("viewfinder").Key("warning").String()); err != nil || got != (color.RGBA{R: 255, G: 128, A: 255}) {
+			t.Errorf("dumpConfig() %s warning = %v, %v; want ff8000", format, got, err)
+		}
+		if format != formatINI {
+			if got := f.Section("initiator").Key("friendly_name").String(); got != conf.fname {
+				t.Errorf("dumpConfig() %s friendly_name = %s; want %s", format, got, conf.fname)
+			}
+		}
+	}
+}
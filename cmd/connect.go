@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"net"
+	"time"
+)
+
+// maxRetryBackoff is the longest time waited in between two attempts to connect to a responder.
+const maxRetryBackoff = 30 * time.Second
+
+// noAnswerError is returned when the responder did not answer any of the attempts to connect to it, as opposed to
+// answering and refusing the connection.
+type noAnswerError struct {
+	attempts int
+	err      error
+}
+
+func (e *noAnswerError) Error() string {
+	if e.attempts <= 1 {
+		return fmt.Sprintf("responder did not answer: %s", e.err)
+	}
+
+	return fmt.Sprintf("responder did not answer %d attempts: %s", e.attempts, e.err)
+}
+
+func (e *noAnswerError) Unwrap() error {
+	return e.err
+}
+
+// isNoAnswer returns true when the error means the responder could not be reached or did not respond at all.
+func isNoAnswer(err error) bool {
+	var (
+		ife *ip.InitFailError
+		ne  net.Error
+	)
+	if errors.As(err, &ife) {
+		return false
+	}
+
+	return errors.As(err, &ne) || errors.Is(err, ip.WaitForResponseError)
+}
+
+// connectCamera wakes up the responder and connects to it. When the responder does not answer, connecting is retried
+// the number of times set using the -retries flag, the time waited in between doubling after every attempt starting
+// from the -retry-backoff flag. A *noAnswerError is returned when none of the attempts got an answer.
+func connectCamera(cam *camera, c *ip.Client) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := wakeCamera(cam, c)
+		if err == nil {
+			if err = c.Dial(); err == nil {
+				return nil
+			}
+			// Start all over on the next attempt.
+			c.Close()
+		}
+		if !isNoAnswer(err) {
+			return err
+		}
+		if attempt > retries {
+			return &noAnswerError{attempts: attempt, err: err}
+		}
+
+		logger.Warnf("[Connect] no answer from %s, retrying in %s (%d/%d): %s", c.CommandDataAddress(), backoff, attempt, retries, err)
+		select {
+		case <-time.After(backoff):
+		case <-quit:
+			return &noAnswerError{attempts: attempt, err: err}
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"net"
+	"testing"
+)
+
+func TestIsNoAnswer(t *testing.T) {
+	check := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{fmt.Errorf("responder did not come up within 30s: %w", &net.OpError{Op: "dial", Err: errors.New("i/o timeout")}), true},
+		{fmt.Errorf("command data connection: %w", ip.WaitForResponseError), true},
+		{fmt.Errorf("command data connection: %w", (&ip.InitFailPacket{Reason: ip.FR_FailBusy}).ReasonAsError()), false},
+		{ip.ConnectionLostError, false},
+		{errors.New("other"), false},
+	}
+
+	for _, tt := range check {
+		if got := isNoAnswer(tt.err); got != tt.want {
+			t.Errorf("isNoAnswer() %v got = %v; want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNoAnswerError_Error(t *testing.T) {
+	err := &noAnswerError{attempts: 3, err: ip.WaitForResponseError}
+	if want := "responder did not answer 3 attempts: " + ip.WaitForResponseError.Error(); err.Error() != want {
+		t.Errorf("Error() got = %s; want %s", err, want)
+	}
+	if !errors.Is(err, ip.WaitForResponseError) {
+		t.Errorf("Unwrap() got = %v; want %v", errors.Unwrap(err), ip.WaitForResponseError)
+	}
+}
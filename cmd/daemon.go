@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// shutdownTimeout is the time given to the commands being executed to finish when shutting down.
+	shutdownTimeout = 5 * time.Second
+	// listenFdsStart is the first file descriptor passed by systemd when using socket activation.
+	listenFdsStart = 3
+	// probeInterval is the interval at which the cameras are probed for the readiness endpoint.
+	probeInterval = 30 * time.Second
+)
+
+// daemon keeps track of everything that has to be stopped when shutting down, which is done in reverse order of
+// starting: the servers stop accepting new work first, then the camera sessions and connections are closed and finally
+// the PID file is removed.
+type daemon struct {
+	pidFile string
+	started time.Time
+
+	mu        sync.Mutex
+	clients   []*ip.Client
+	listeners []net.Listener
+	servers   []*http.Server
+	rpcs      []*grpcServer
+	// commands counts the commands being executed by the local servers.
+	commands sync.WaitGroup
+	stopped  bool
+	// probes holds the error of the last probe of each camera being probed, see probe.
+	probes map[*ip.Client]error
+}
+
+func newDaemon(pidFile string) *daemon {
+	return &daemon{pidFile: pidFile, started: time.Now()}
+}
+
+// addClient registers a client to be closed when shutting down.
+func (d *daemon) addClient(c *ip.Client) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clients = append(d.clients, c)
+}
+
+// writePidFile writes the process ID to the PID file, refusing to do so when the file belongs to a process that is
+// still running.
+func (d *daemon) writePidFile() error {
+	if d.pidFile == "" {
+		return nil
+	}
+
+	if b, err := os.ReadFile(d.pidFile); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && pid != os.Getpid() && processExists(pid) {
+			return fmt.Errorf("already running with PID %d according to %s", pid, d.pidFile)
+		}
+	}
+
+	return os.WriteFile(d.pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// processExists returns true when a process with the given PID is running.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// shutdown stops the servers, closes the camera sessions and connections and removes the PID file. It is safe to call
+// shutdown more than once.
+func (d *daemon) shutdown() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	d.mu.Unlock()
+
+	sdNotify("STOPPING=1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, l := range d.listeners {
+		l.Close()
+	}
+	for _, srv := range d.servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Errorf("[Daemon] error stopping web server: %s", err)
+		}
+	}
+	for _, s := range d.rpcs {
+		s.stop(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.commands.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Warn("[Daemon] timeout waiting for commands to finish")
+	}
+
+	for _, c := range d.clients {
+		if err := c.Shutdown(); err != nil {
+			logger.Errorf("[Daemon] error closing connection with %s: %s", c.ResponderFriendlyName(), err)
+		}
+	}
+
+	if d.pidFile != "" {
+		if err := os.Remove(d.pidFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Errorf("[Daemon] error removing PID file: %s", err)
+		}
+	}
+}
+
+// exit shuts down and exits with the given code.
+func (d *daemon) exit(code int) {
+	d.shutdown()
+	os.Exit(code)
+}
+
+// listen returns the listener passed by systemd under the given name or, when there is none, a new listener on the
+// server address using the given port.
+func (d *daemon) listen(activated map[string]net.Listener, name string, port uint16Value) (net.Listener, error) {
+	l, ok := activated[name]
+	if !ok {
+		if ip := net.ParseIP(conf.srvAddr); ip == nil {
+			return nil, fmt.Errorf("invalid IP address '%s'", conf.srvAddr)
+		}
+
+		var err error
+		if l, err = net.Listen("tcp", net.JoinHostPort(conf.srvAddr, port.String())); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, l)
+
+	return l, nil
+}
+
+// serveWeb serves the given handler using l until shutting down.
+func (d *daemon) serveWeb(l net.Listener, h http.Handler) {
+	srv := &http.Server{Handler: h}
+
+	d.mu.Lock()
+	d.servers = append(d.servers, srv)
+	d.mu.Unlock()
+
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Errorf("[Web server] error %s...", err)
+	}
+}
+
+// serveGrpc serves the gRPC server using l until shutting down.
+func (d *daemon) serveGrpc(l net.Listener, s *grpcServer) {
+	d.mu.Lock()
+	d.rpcs = append(d.rpcs, s)
+	d.mu.Unlock()
+
+	s.serve(l)
+}
+
+// healthStatus is the response of the health endpoints.
+type healthStatus struct {
+	Status    string `json:"status"`
+	Camera    string `json:"camera"`
+	Connected bool   `json:"connected"`
+	// EventListener tells whether packets are still being received from the event connection.
+	EventListener bool   `json:"event_listener"`
+	LastEvent     string `json:"last_event,omitempty"`
+	LastProbe     string `json:"last_probe,omitempty"`
+	ProbeError    string `json:"probe_error,omitempty"`
+	// QueueDepth is the number of commands waiting for their turn, see jobQueue.
+	QueueDepth int    `json:"queue_depth"`
+	Uptime     string `json:"uptime"`
+}
+
+// health returns the health of the daemon and the given camera. It is alive as long as it is not shutting down and
+// the session with the camera is not lost, and ready when it is alive and the camera answered the last probe.
+func (d *daemon) health(c *ip.Client, q *jobQueue) (hs healthStatus, alive, ready bool) {
+	d.mu.Lock()
+	stopped := d.stopped
+	probeErr := d.probes[c]
+	d.mu.Unlock()
+
+	hs = healthStatus{
+		Camera:        c.ResponderFriendlyName(),
+		Connected:     c.CommandDataConn != nil,
+		EventListener: c.EventListenerRunning(),
+		LastEvent:     formatHealthTime(c.LastEventPacket()),
+		LastProbe:     formatHealthTime(c.LastProbe()),
+		QueueDepth:    q.depth(),
+		Uptime:        time.Since(d.started).Round(time.Second).String(),
+	}
+	if probeErr != nil {
+		hs.ProbeError = probeErr.Error()
+	}
+
+	alive = !stopped && hs.Connected && hs.EventListener
+	ready = alive && probeErr == nil
+
+	return hs, alive, ready
+}
+
+// healthHandler reports the health of the daemon and the given camera, using status 503 when it is not alive or, when
+// readiness is true, not ready. See health.
+func (d *daemon) healthHandler(c *ip.Client, q *jobQueue, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hs, alive, ready := d.health(c, q)
+
+		hs.Status = "ok"
+		code := http.StatusOK
+		if !alive || readiness && !ready {
+			hs.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(hs)
+	})
+}
+
+// formatHealthTime formats the time for the health endpoints, returning an empty string for the zero time.
+func formatHealthTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// canProbe returns true when the camera can be probed, which is not the case for vendors using their own packet
+// layout on the event connection.
+func canProbe(c *ip.Client) bool {
+	return c.ResponderVendor() != ptp.VE_FujiPhotoFilmCoLtd
+}
+
+// probe probes the camera every probeInterval until quitting, so that the readiness endpoint notices a camera that
+// stopped answering while the connections are still open.
+func (d *daemon) probe(c *ip.Client) {
+	t := time.NewTicker(probeInterval)
+	defer t.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), ip.DefaultReadTimeout)
+		_, err := c.Probe(ctx)
+		cancel()
+		if err != nil {
+			logger.Warnf("[Daemon] %s did not answer a probe: %s", c.ResponderFriendlyName(), err)
+		}
+
+		d.mu.Lock()
+		if d.probes == nil {
+			d.probes = make(map[*ip.Client]error)
+		}
+		d.probes[c] = err
+		d.mu.Unlock()
+
+		select {
+		case <-t.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// systemdListeners returns the sockets passed by systemd when using socket activation, keyed by the name set using
+// FileDescriptorName= in the socket unit. Unnamed sockets are named after their purpose in the order they are passed:
+// "server" followed by "web" and "grpc".
+func systemdListeners() (map[string]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	defaults := []string{"server", "web", "grpc"}
+	res := make(map[string]net.Listener, n)
+	for i := 0; i < n; i++ {
+		name := ""
+		if i < len(names) && names[i] != "" && names[i] != "unknown" {
+			name = names[i]
+		} else if i < len(defaults) {
+			name = defaults[i]
+		} else {
+			name = strconv.Itoa(i)
+		}
+
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket %s: %w", name, err)
+		}
+		res[name] = l
+	}
+
+	// Do not pass the sockets on to child processes.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	return res, nil
+}
+
+// listenerName returns the name of the socket used by the given server of a camera. Cameras loaded by name use the
+// camera name as suffix, e.g. "web-xt1".
+func listenerName(server string, cam *camera) string {
+	if cam.name == "" {
+		return server
+	}
+
+	return server + "-" + cam.name
+}
+
+// sdNotify sends a state change to systemd when running as a service of Type=notify. It does nothing otherwise.
+func sdNotify(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+	if strings.HasPrefix(addr, "@") {
+		addr = "\x00" + addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		logger.Errorf("[Daemon] error notifying systemd: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		logger.Errorf("[Daemon] error notifying systemd: %s", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/malc0mn/ptp-ip/ip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDaemon_writePidFile(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "ptpip.pid")
+
+	// A PID file left behind by a process that is no longer running is overwritten.
+	if err := os.WriteFile(pidFile, []byte("999999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := newDaemon(pidFile)
+	if err := d.writePidFile(); err != nil {
+		t.Fatalf("writePidFile() error = %s; want <nil>", err)
+	}
+	b, _ := os.ReadFile(pidFile)
+	if want := strconv.Itoa(os.Getpid()) + "\n"; string(b) != want {
+		t.Errorf("writePidFile() wrote '%s'; want '%s'", b, want)
+	}
+
+	// The parent process is running for sure.
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getppid())), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.writePidFile(); err == nil {
+		t.Error("writePidFile() error = <nil>; want already running")
+	}
+
+	d.shutdown()
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Errorf("shutdown() PID file error = %v; want not exist", err)
+	}
+	// Shutting down twice must do no harm.
+	d.shutdown()
+}
+
+func TestDaemon_shutdown(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newDaemon("")
+	d.addClient(c)
+	l, err := d.listen(nil, "server", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		launchServer(c, newJobQueue(0), l, &d.commands)
+		close(stopped)
+	}()
+
+	d.shutdown()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("shutdown() launchServer() did not return")
+	}
+}
+
+func TestDaemon_healthHandler(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newJobQueue(0)
+	q.add("test", &job{})
+
+	d := newDaemon("")
+	for _, readiness := range []bool{false, true} {
+		srv := httptest.NewServer(d.healthHandler(c, q, readiness))
+
+		res, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("healthHandler(%t) status = %d; want %d", readiness, res.StatusCode, http.StatusServiceUnavailable)
+		}
+		var got healthStatus
+		if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "unavailable" || got.Connected || got.EventListener || got.LastProbe != "" || got.QueueDepth != 1 {
+			t.Errorf("healthHandler(%t) got = %+v; want unavailable, not connected and a queue depth of 1", readiness, got)
+		}
+
+		res.Body.Close()
+		srv.Close()
+	}
+}
+
+func TestSystemdListeners(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "2")
+
+	got, err := systemdListeners()
+	if got != nil || err != nil {
+		t.Errorf("systemdListeners() got = %v, %v; want <nil>, <nil> for another process", got, err)
+	}
+}
+
+func TestListenerName(t *testing.T) {
+	if got := listenerName("web", &camera{}); got != "web" {
+		t.Errorf("listenerName() got = %s; want web", got)
+	}
+	if got := listenerName("web", &camera{name: "xt1"}); got != "web-xt1" {
+		t.Errorf("listenerName() got = %s; want web-xt1", got)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	"sort"
+	"strings"
+)
+
+const (
+	stdFrameDecoder  frameDecoderName = "std"
+	noneFrameDecoder frameDecoderName = "none"
+)
+
+var (
+	errFrameDecodingDisabled = errors.New("live view frames are not decoded, use the http, rtsp or rec argument to pass them through")
+
+	// lvDecoder is the decoder turning the live view frames into images for the live view window.
+	lvDecoder = stdFrameDecoder
+
+	// frameDecoders holds the frame decoders that can be selected using the -lv-decoder flag, by name.
+	frameDecoders = map[frameDecoderName]frameDecoder{
+		stdFrameDecoder:  stdDecoder{},
+		noneFrameDecoder: noDecoder{},
+	}
+)
+
+// frameDecoder decodes a JPEG image received from the camera, such as a live view frame, into an RGBA image which is
+// what the live view window, the viewfinder overlay and the histogram work with. Consumers passing the frames through,
+// such as the HTTP and RTSP streams, the recorder and the web UI, never decode them.
+type frameDecoder interface {
+	decode(data []byte) (*image.RGBA, error)
+}
+
+// registerFrameDecoder makes a frame decoder available to the -lv-decoder flag. Decoders requiring a C library are
+// registered from an init function in a file that is only compiled when using the matching build tag.
+func registerFrameDecoder(name frameDecoderName, d frameDecoder) {
+	frameDecoders[name] = d
+}
+
+// frameDecoderNames returns the names of all available frame decoders in alphabetical order.
+func frameDecoderNames() []string {
+	names := make([]string, 0, len(frameDecoders))
+	for name := range frameDecoders {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// frameDecoderName is the name of a registered frame decoder.
+type frameDecoderName string
+
+// Set implements the flag.Value interface.
+func (n *frameDecoderName) Set(s string) error {
+	if _, ok := frameDecoders[frameDecoderName(s)]; !ok {
+		return fmt.Errorf("unknown live view decoder %s, must be one of %s", s, strings.Join(frameDecoderNames(), ", "))
+	}
+	*n = frameDecoderName(s)
+
+	return nil
+}
+
+// String implements the flag.Value interface.
+func (n *frameDecoderName) String() string {
+	return string(*n)
+}
+
+// decoder returns the frame decoder registered using the name.
+func (n frameDecoderName) decoder() frameDecoder {
+	if d, ok := frameDecoders[n]; ok {
+		return d
+	}
+
+	return stdDecoder{}
+}
+
+// stdDecoder decodes frames using the image/jpeg package of the standard library. It is written in pure Go and is
+// always available, but it is the bottleneck at high live view frame rates.
+type stdDecoder struct{}
+
+func (stdDecoder) decode(data []byte) (*image.RGBA, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return toRGBA(img), nil
+}
+
+// noDecoder does not decode frames at all, for when the live view is only passed through.
+type noDecoder struct{}
+
+func (noDecoder) decode([]byte) (*image.RGBA, error) {
+	return nil, errFrameDecodingDisabled
+}
+
+// toRGBA returns img as an RGBA image, converting it when needed.
+func toRGBA(img image.Image) *image.RGBA {
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Rect, img, image.Point{}, draw.Src)
+	}
+
+	return rgba
+}
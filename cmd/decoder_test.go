@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func TestFrameDecoderName_Set(t *testing.T) {
+	n := stdFrameDecoder
+	if err := n.Set("none"); err != nil || n != noneFrameDecoder {
+		t.Errorf("Set() got = %s, %v; want %s, <nil>", n, err, noneFrameDecoder)
+	}
+	if err := n.Set("libjpeg"); err == nil || n != noneFrameDecoder {
+		t.Errorf("Set() got = %s, %v; want %s, error", n, err, noneFrameDecoder)
+	}
+	if _, ok := n.decoder().(noDecoder); !ok {
+		t.Errorf("decoder() got = %T; want noDecoder", n.decoder())
+	}
+}
+
+func TestStdDecoder_decode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := stdDecoder{}.decode(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decode() error = %s; want <nil>", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 16, 8); got != want {
+		t.Errorf("decode() bounds = %v; want %v", got, want)
+	}
+
+	if _, err := (stdDecoder{}).decode([]byte{0xff, 0xd8}); err == nil {
+		t.Errorf("decode() error = <nil>; want error")
+	}
+}
+
+func TestNoDecoder_decode(t *testing.T) {
+	if _, err := (noDecoder{}).decode([]byte{0xff, 0xd8}); err != errFrameDecodingDisabled {
+		t.Errorf("decode() error = %v; want %s", err, errFrameDecodingDisabled)
+	}
+}
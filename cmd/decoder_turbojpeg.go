@@ -0,0 +1,64 @@
+// +build with_turbojpeg
+
+package main
+
+/*
+#cgo LDFLAGS: -lturbojpeg
+#include <turbojpeg.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"unsafe"
+)
+
+const turboFrameDecoder frameDecoderName = "turbojpeg"
+
+func init() {
+	buildTags = append(buildTags, "with_turbojpeg")
+
+	if h := C.tjInitDecompress(); h != nil {
+		registerFrameDecoder(turboFrameDecoder, &turboDecoder{h: h})
+		lvDecoder = turboFrameDecoder
+	}
+}
+
+// turboDecoder decodes frames using libjpeg-turbo, which uses SIMD instructions and decodes straight into RGBA,
+// making it several times faster than the standard library decoder. It becomes the default decoder when the binary is
+// built using the with_turbojpeg tag.
+type turboDecoder struct {
+	// mu guards h: a TurboJPEG handle must not be used by several goroutines at once.
+	mu sync.Mutex
+	h  C.tjhandle
+}
+
+func (d *turboDecoder) decode(data []byte) (*image.RGBA, error) {
+	if len(data) == 0 {
+		return nil, errors.New("turbojpeg: empty frame")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	src := (*C.uchar)(unsafe.Pointer(&data[0]))
+	var w, h, subsamp, colorspace C.int
+	if C.tjDecompressHeader3(d.h, src, C.ulong(len(data)), &w, &h, &subsamp, &colorspace) != 0 {
+		return nil, d.err()
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	dst := (*C.uchar)(unsafe.Pointer(&img.Pix[0]))
+	if C.tjDecompress2(d.h, src, C.ulong(len(data)), dst, w, C.int(img.Stride), h, C.TJPF_RGBA, C.TJFLAG_FASTDCT) != 0 {
+		return nil, d.err()
+	}
+
+	return img, nil
+}
+
+// err returns the last error reported by libjpeg-turbo.
+func (d *turboDecoder) err() error {
+	return errors.New("turbojpeg: " + C.GoString(C.tjGetErrorStr2(d.h)))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	downloadHooksMu sync.RWMutex
+	// downloadHooks maps the name of each hook to the function run after each object downloaded by the download and
+	// sync commands.
+	downloadHooks = make(map[string]downloadHook)
+	// execHooks maps the name of each hook defined in the config file to its shell command. Guarded by downloadHooksMu.
+	execHooks = make(map[string]string)
+)
+
+// downloadedFile describes an object that has just been downloaded.
+type downloadedFile struct {
+	// Path is the absolute path the object has been written to, or its URL when it was written to remote storage, see
+	// openStore.
+	Path   string
+	Object ip.Object
+	// Vendor is the vendor of the camera the object was downloaded from.
+	Vendor string
+}
+
+// remote returns true when the file was written to remote storage.
+func (f downloadedFile) remote() bool {
+	return strings.Contains(f.Path, "://")
+}
+
+// split returns the directory and the name of the file.
+func (f downloadedFile) split() (string, string) {
+	if f.remote() {
+		i := strings.LastIndexByte(f.Path, '/')
+		return f.Path[:i], f.Path[i+1:]
+	}
+
+	return filepath.Dir(f.Path), filepath.Base(f.Path)
+}
+
+// downloadHook processes a downloaded file, e.g. by copying it to the hot folder of a photo editor or by making a
+// backup. It runs before the next object is downloaded, so a slow hook slows the download down.
+type downloadHook func(downloadedFile) error
+
+// registerDownloadHook adds a hook run after each downloaded object. Hooks run in the order of their names.
+func registerDownloadHook(name string, h downloadHook) {
+	downloadHooksMu.Lock()
+	defer downloadHooksMu.Unlock()
+	if h == nil {
+		panic("cmd: registerDownloadHook hook is nil")
+	}
+	if _, dup := downloadHooks[name]; dup {
+		panic("cmd: registerDownloadHook called twice for hook " + name)
+	}
+
+	downloadHooks[name] = h
+}
+
+// defineExecHook adds a hook running the shell command after each downloaded object, or replaces an existing one. The
+// command receives the path of the file as its first argument and the details of the object in the environment, see
+// downloadHookEnv.
+func defineExecHook(name, command string) error {
+	if err := checkExecHook(name, command); err != nil {
+		return err
+	}
+
+	downloadHooksMu.Lock()
+	defer downloadHooksMu.Unlock()
+	setExecHook(name, command)
+
+	return nil
+}
+
+// checkExecHook returns an error when the hook cannot be defined, see defineExecHook.
+func checkExecHook(name, command string) error {
+	if name == "" || strings.TrimSpace(command) == "" {
+		return fmt.Errorf("invalid download hook %q: a name and a command are required", name)
+	}
+
+	downloadHooksMu.RLock()
+	defer downloadHooksMu.RUnlock()
+	if _, dup := downloadHooks[name]; dup && execHooks[name] == "" {
+		return fmt.Errorf("%s is a built-in download hook, it cannot be redefined", name)
+	}
+
+	return nil
+}
+
+// setExecHooks replaces all hooks running a shell command by the given ones, which must have been checked using
+// checkExecHook.
+func setExecHooks(defs map[string]string) {
+	downloadHooksMu.Lock()
+	defer downloadHooksMu.Unlock()
+
+	for name := range execHooks {
+		if _, ok := defs[name]; !ok {
+			delete(downloadHooks, name)
+			delete(execHooks, name)
+		}
+	}
+	for name, command := range defs {
+		setExecHook(name, command)
+	}
+}
+
+// setExecHook adds or replaces the hook running the shell command. The caller must hold downloadHooksMu.
+func setExecHook(name, command string) {
+	execHooks[name] = command
+	downloadHooks[name] = func(f downloadedFile) error {
+		return runExecHook(command, f)
+	}
+}
+
+// runExecHook runs the shell command for the downloaded file. The output of the command is included in the error when
+// it fails.
+func runExecHook(command string, f downloadedFile) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		// Use %PTPIP_FILE% to get the path.
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		// The second argument becomes $0, making the path available as $1.
+		cmd = exec.Command("sh", "-c", command, exe+"-hook", f.Path)
+	}
+	if !f.remote() {
+		cmd.Dir = filepath.Dir(f.Path)
+	}
+	cmd.Env = append(os.Environ(), downloadHookEnv(f)...)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %s", err, msg)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// downloadHookEnv returns the environment variables describing the downloaded file for shell command hooks.
+func downloadHookEnv(f downloadedFile) []string {
+	oi := f.Object.Info
+	dir, name := f.split()
+	env := []string{
+		"PTPIP_FILE=" + f.Path,
+		"PTPIP_DIR=" + dir,
+		"PTPIP_NAME=" + name,
+		fmt.Sprintf("PTPIP_HANDLE=%#08x", uint32(f.Object.Handle)),
+		"PTPIP_KIND=" + string(ip.KindOfObject(oi)),
+		fmt.Sprintf("PTPIP_FORMAT=%#04x", uint16(oi.ObjectFormat)),
+		"PTPIP_SIZE=" + strconv.FormatUint(uint64(oi.ObjectCompressedSize), 10),
+		"PTPIP_VENDOR=" + f.Vendor,
+	}
+	if !oi.CaptureDate.IsZero() {
+		env = append(env, "PTPIP_CAPTURE_DATE="+oi.CaptureDate.Format(time.RFC3339))
+	}
+
+	return env
+}
+
+// downloadHookNames returns the names of all registered hooks, sorted alphabetically.
+func downloadHookNames() []string {
+	downloadHooksMu.RLock()
+	defer downloadHooksMu.RUnlock()
+
+	names := make([]string, 0, len(downloadHooks))
+	for name := range downloadHooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// execHookValues returns the hooks defined in the config file as config values, sorted by name.
+func execHookValues() []configValue {
+	var values []configValue
+	for _, name := range downloadHookNames() {
+		downloadHooksMu.RLock()
+		command, ok := execHooks[name]
+		downloadHooksMu.RUnlock()
+		if ok {
+			values = append(values, configValue{key: name, value: command, quote: true})
+		}
+	}
+
+	return values
+}
+
+// runDownloadHooks runs all registered hooks for the downloaded file, reporting failures on the asynchronous output
+// channel. A failing hook does not stop the other hooks from running. The number of failed hooks is returned.
+func runDownloadHooks(f downloadedFile, asyncOut chan<- string) int {
+	var failed int
+	for _, name := range downloadHookNames() {
+		downloadHooksMu.RLock()
+		h := downloadHooks[name]
+		downloadHooksMu.RUnlock()
+
+		if err := h(f); err != nil {
+			failed++
+			asyncOut <- fmt.Sprintf("%s: hook %s failed: %s", filepath.Base(f.Path), name, err)
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDownloadedFile(dir string) downloadedFile {
+	return downloadedFile{
+		Path: filepath.Join(dir, "DSCF0001.JPG"),
+		Object: ip.Object{
+			Handle: 0x1a,
+			Info: &ptp.ObjectInfo{
+				ObjectFormat:         ptp.OFC_EXIF_JPEG,
+				ObjectCompressedSize: 4096,
+				Filename:             "DSCF0001.JPG",
+				CaptureDate:          time.Date(2021, 3, 14, 15, 30, 0, 0, time.UTC),
+			},
+		},
+		Vendor: "fuji",
+	}
+}
+
+func removeDownloadHook(name string) {
+	downloadHooksMu.Lock()
+	defer downloadHooksMu.Unlock()
+	delete(downloadHooks, name)
+	delete(execHooks, name)
+}
+
+func TestDownloadHookEnv(t *testing.T) {
+	got := strings.Join(downloadHookEnv(testDownloadedFile("/tmp/photos")), "\n")
+
+	for _, want := range []string{
+		"PTPIP_FILE=/tmp/photos/DSCF0001.JPG",
+		"PTPIP_DIR=/tmp/photos",
+		"PTPIP_NAME=DSCF0001.JPG",
+		"PTPIP_HANDLE=0x0000001a",
+		"PTPIP_KIND=jpeg",
+		"PTPIP_FORMAT=0x3801",
+		"PTPIP_SIZE=4096",
+		"PTPIP_VENDOR=fuji",
+		"PTPIP_CAPTURE_DATE=2021-03-14T15:30:00Z",
+	} {
+		if !strings.Contains(got, want+"\n") && !strings.HasSuffix(got, want) {
+			t.Errorf("downloadHookEnv() got = %s; want it to contain %s", got, want)
+		}
+	}
+}
+
+func TestDownloadHookEnv_remote(t *testing.T) {
+	f := testDownloadedFile("/tmp/photos")
+	f.Path = "s3://photos/studio/DSCF0001.JPG"
+	got := strings.Join(downloadHookEnv(f), "\n")
+
+	for _, want := range []string{"PTPIP_DIR=s3://photos/studio\n", "PTPIP_NAME=DSCF0001.JPG\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("downloadHookEnv() got = %s; want it to contain %s", got, want)
+		}
+	}
+}
+
+func TestDefineExecHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command requires sh")
+	}
+	defer removeDownloadHook("test")
+
+	if err := defineExecHook("test", ""); err == nil {
+		t.Errorf("defineExecHook() error = <nil>; want error for empty command")
+	}
+
+	dir := t.TempDir()
+	if err := defineExecHook("test", `echo "$1 $PTPIP_KIND" > hook.out`); err != nil {
+		t.Fatal(err)
+	}
+
+	f := testDownloadedFile(dir)
+	out := make(chan string, 1)
+	if failed := runDownloadHooks(f, out); failed != 0 {
+		t.Fatalf("runDownloadHooks() failed = %d; want 0: %s", failed, <-out)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "hook.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := f.Path + " jpeg\n"; string(got) != want {
+		t.Errorf("defineExecHook() hook output = %q; want %q", got, want)
+	}
+
+	if err := defineExecHook("test", "exit 3"); err != nil {
+		t.Fatal(err)
+	}
+	if failed := runDownloadHooks(f, out); failed != 1 {
+		t.Errorf("runDownloadHooks() failed = %d; want 1", failed)
+	}
+	if got, want := <-out, "DSCF0001.JPG: hook test failed: exit status 3"; got != want {
+		t.Errorf("runDownloadHooks() output = %s; want %s", got, want)
+	}
+}
+
+func TestRegisterDownloadHook(t *testing.T) {
+	defer removeDownloadHook("a-test")
+	defer removeDownloadHook("b-test")
+
+	var order []string
+	registerDownloadHook("b-test", func(f downloadedFile) error {
+		order = append(order, "b")
+		return errors.New("b failed")
+	})
+	registerDownloadHook("a-test", func(f downloadedFile) error {
+		order = append(order, "a")
+		return nil
+	})
+
+	if err := defineExecHook("a-test", "true"); err == nil {
+		t.Errorf("defineExecHook() error = <nil>; want error when redefining a built-in hook")
+	}
+
+	out := make(chan string, 1)
+	if failed := runDownloadHooks(testDownloadedFile(t.TempDir()), out); failed != 1 {
+		t.Errorf("runDownloadHooks() failed = %d; want 1", failed)
+	}
+	if got := strings.Join(order, ""); got != "ab" {
+		t.Errorf("runDownloadHooks() order = %s; want ab", got)
+	}
+}
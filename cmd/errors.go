@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"io"
+	"net"
+	"os"
+)
+
+// exitClasses holds the name of the failure class of each exit code, used when reporting errors as JSON.
+var exitClasses = map[int]string{
+	ok:                   "ok",
+	errGeneral:           "general",
+	errInvalidArgs:       "invalid-args",
+	errOpenConfig:        "open-config",
+	errCreateClient:      "create-client",
+	errResponderConnect:  "responder-connect",
+	errScript:            "script",
+	errCapture:           "capture",
+	errServer:            "server",
+	errPidFile:           "pid-file",
+	errResponderRejected: "responder-rejected",
+	errDeviceBusy:        "device-busy",
+	errTimeout:           "timeout",
+	errNotSupported:      "not-supported",
+	errConnectionLost:    "connection-lost",
+	errNoAnswer:          "no-answer",
+}
+
+// cliError is an error reported on stderr when exiting, either as plain text or as a single line JSON object when the
+// -json-errors flag is set.
+type cliError struct {
+	Code         int    `json:"code"`
+	Class        string `json:"error"`
+	Message      string `json:"message"`
+	ResponseCode string `json:"response_code,omitempty"`
+	// text is the message when reporting the error as plain text.
+	text string
+}
+
+// newCliError returns the error to report for err, using the exit code of the failure class of err or, when err does
+// not belong to a specific class, the fallback exit code. The message describes what failed, err may be nil when the
+// message says it all.
+func newCliError(fallback int, msg string, err error) cliError {
+	ce := cliError{Code: exitCode(err, fallback), Message: msg, text: msg}
+	if err != nil {
+		ce.Message += ": " + err.Error()
+		ce.text = "Error " + msg + " - " + err.Error()
+	}
+	ce.Class = exitClasses[ce.Code]
+
+	var ore *ptp.OperationResponseError
+	if errors.As(err, &ore) {
+		ce.ResponseCode = fmt.Sprintf("%#x", uint16(ore.Code))
+	}
+
+	return ce
+}
+
+// exitCode returns the exit code of the failure class err belongs to, or the fallback exit code when there is none.
+func exitCode(err error, fallback int) int {
+	var (
+		ce  *ip.CaptureError
+		ife *ip.InitFailError
+		ore *ptp.OperationResponseError
+		nae *noAnswerError
+		ne  net.Error
+	)
+
+	switch {
+	case err == nil:
+		return fallback
+	case errors.As(err, &nae):
+		return errNoAnswer
+	case errors.As(err, &ce):
+		return errCapture
+	case errors.As(err, &ife):
+		switch ife.Reason {
+		case ip.FR_FailRejectedInitiator:
+			return errResponderRejected
+		case ip.FR_FailBusy, ip.FR_Fuji_DeviceBusy:
+			return errDeviceBusy
+		}
+	case errors.As(err, &ore):
+		switch ore.Code {
+		case ptp.RC_DeviceBusy:
+			return errDeviceBusy
+		case ptp.RC_OperationNotSupported, ptp.RC_ParameterNotSupported, ptp.RC_DevicePropNotSupported:
+			return errNotSupported
+		}
+	case errors.Is(err, ip.WaitForResponseError), errors.As(err, &ne) && ne.Timeout():
+		return errTimeout
+	case errors.Is(err, ip.ConnectionLostError), errors.Is(err, ip.NotConnectedError):
+		return errConnectionLost
+	}
+
+	return fallback
+}
+
+// printError writes the error to w, as JSON when the -json-errors flag is set.
+func printError(w io.Writer, ce cliError) {
+	if jsonErrors {
+		json.NewEncoder(w).Encode(ce)
+		return
+	}
+
+	fmt.Fprintln(w, ce.text)
+}
+
+// fail reports the error on stderr and exits using the exit code of its failure class or the fallback exit code. Pass
+// a nil daemon when there is nothing to shut down yet.
+func fail(d *daemon, fallback int, msg string, err error) {
+	ce := newCliError(fallback, msg, err)
+	printError(os.Stderr, ce)
+
+	if d != nil {
+		d.exit(ce.Code)
+	}
+	os.Exit(ce.Code)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	check := []struct {
+		err  error
+		want int
+	}{
+		{nil, errResponderConnect},
+		{errors.New("other"), errResponderConnect},
+		{&ip.CaptureError{Err: ptp.ResponseCodeAsError(ptp.RC_DeviceBusy)}, errCapture},
+		{fmt.Errorf("command data connection: %w", (&ip.InitFailPacket{Reason: ip.FR_FailRejectedInitiator}).ReasonAsError()), errResponderRejected},
+		{(&ip.InitFailPacket{Reason: ip.FR_Fuji_DeviceBusy}).ReasonAsError(), errDeviceBusy},
+		{ptp.ResponseCodeAsError(ptp.RC_DeviceBusy), errDeviceBusy},
+		{ptp.ResponseCodeAsError(ptp.RC_OperationNotSupported), errNotSupported},
+		{ptp.ResponseCodeAsError(ptp.RC_StoreFull), errResponderConnect},
+		{ip.WaitForResponseError, errTimeout},
+		{fmt.Errorf("reading: %w", ip.ConnectionLostError), errConnectionLost},
+		{&noAnswerError{attempts: 3, err: ip.WaitForResponseError}, errNoAnswer},
+	}
+
+	for _, tt := range check {
+		if got := exitCode(tt.err, errResponderConnect); got != tt.want {
+			t.Errorf("exitCode() %v got = %d; want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPrintError(t *testing.T) {
+	defer func() { jsonErrors = false }()
+
+	ce := newCliError(errGeneral, "executing command", ptp.ResponseCodeAsError(ptp.RC_DeviceBusy))
+
+	var buf bytes.Buffer
+	printError(&buf, ce)
+	if want := "Error executing command - device busy\n"; buf.String() != want {
+		t.Errorf("printError() got = %q; want %q", buf.String(), want)
+	}
+
+	jsonErrors = true
+	buf.Reset()
+	printError(&buf, ce)
+	var got cliError
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := cliError{Code: errDeviceBusy, Class: "device-busy", Message: "executing command: device busy", ResponseCode: "0x2019"}
+	if got != want {
+		t.Errorf("printError() got = %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"fmt"
+	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDateLayout is the layout of the {date} field of a name template when none is given.
+	defaultDateLayout = "20060102-150405"
+	// defaultCounterWidth is the width of the {counter} field of a name template when none is given.
+	defaultCounterWidth = 4
+	// unknownFieldValue replaces the fields of a name template that are unknown for an object, e.g. the lens of a
+	// video.
+	unknownFieldValue = "unknown"
+)
+
+// templateFields describes the fields of a name template, in the order they are listed in the help text.
+var templateFields = []struct {
+	name, help string
+	metadata   bool
+}{
+	{name: "name", help: "the file name on the camera without its extension"},
+	{name: "ext", help: "the extension of the file name on the camera, including the dot"},
+	{name: "filename", help: "the file name on the camera"},
+	{name: "date", help: "the capture date, using the Go layout given after a colon, e.g. {date:2006/01/02}, or " + defaultDateLayout + " by default"},
+	{name: "counter", help: "the sequence number of the file, using the width given after a colon, e.g. {counter:6}, or " + strconv.Itoa(defaultCounterWidth) + " digits by default"},
+	{name: "handle", help: "the object handle"},
+	{name: "kind", help: "jpeg, raw, video or other"},
+	{name: "camera", help: "the friendly name of the camera"},
+	{name: "make", help: "the camera make", metadata: true},
+	{name: "model", help: "the camera model", metadata: true},
+	{name: "lens", help: "the lens model", metadata: true},
+	{name: "iso", help: "the ISO sensitivity", metadata: true},
+	{name: "aperture", help: "the f-number, e.g. f5.6", metadata: true},
+	{name: "shutter", help: "the exposure time, e.g. 1-250s", metadata: true},
+	{name: "focal", help: "the focal length, e.g. 35mm", metadata: true},
+	{name: "film", help: "the film simulation of Fujifilm cameras, e.g. Classic Chrome", metadata: true},
+}
+
+// templatePart is a literal text or a field of a name template.
+type templatePart struct {
+	literal string
+	field   string
+	arg     string
+}
+
+// nameTemplate is a template for the path of a downloaded file, relative to the download directory, e.g.
+// {date:2006/01/02}/{camera}-{counter}{ext}. The fields between braces are replaced by the values of each file.
+type nameTemplate struct {
+	parts []templatePart
+}
+
+// parseNameTemplate parses a name template, failing on unknown fields or invalid field arguments.
+func parseNameTemplate(s string) (*nameTemplate, error) {
+	t := &nameTemplate{}
+	for s != "" {
+		i := strings.IndexByte(s, '{')
+		if i < 0 {
+			t.parts = append(t.parts, templatePart{literal: s})
+			break
+		}
+		if i > 0 {
+			t.parts = append(t.parts, templatePart{literal: s[:i]})
+		}
+
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated field %s", s[i:])
+		}
+		field, arg, _ := strings.Cut(s[i+1:i+j], ":")
+		if !isTemplateField(field) {
+			return nil, fmt.Errorf("unknown field {%s}", field)
+		}
+		if field == "counter" && arg != "" {
+			if w, err := strconv.Atoi(arg); err != nil || w < 1 || w > 10 {
+				return nil, fmt.Errorf("invalid counter width %s", arg)
+			}
+		}
+		t.parts = append(t.parts, templatePart{field: field, arg: arg})
+		s = s[i+j+1:]
+	}
+	if len(t.parts) == 0 {
+		return nil, fmt.Errorf("empty name template")
+	}
+
+	return t, nil
+}
+
+// isTemplateField reports whether name is a field of a name template.
+func isTemplateField(name string) bool {
+	for _, f := range templateFields {
+		if f.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// has reports whether the template holds one of the given fields.
+func (t *nameTemplate) has(fields ...string) bool {
+	for _, p := range t.parts {
+		for _, f := range fields {
+			if p.field == f {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// needsMetadata reports whether the template holds fields read from the EXIF metadata of the files.
+func (t *nameTemplate) needsMetadata() bool {
+	for _, f := range templateFields {
+		if f.metadata && t.has(f.name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// templateValues holds the values of the fields of a name template for a single file.
+type templateValues struct {
+	obj     ip.Object
+	md      *ip.ObjectMetadata
+	camera  string
+	counter int
+}
+
+// expand returns the slash separated path for the given values. The extension of the file on the camera is added when
+// the template holds neither the {ext} nor the {filename} field and does not end with it.
+func (t *nameTemplate) expand(v templateValues) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.field == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		val := v.field(p.field, p.arg)
+		if p.field == "date" {
+			// The layout of the date may add folders.
+			segs := strings.Split(val, "/")
+			for i, seg := range segs {
+				segs[i] = sanitizeFieldValue(seg)
+			}
+			val = strings.Join(segs, "/")
+		} else {
+			val = sanitizeFieldValue(val)
+		}
+		if val == "" && p.field != "ext" {
+			val = unknownFieldValue
+		}
+		b.WriteString(val)
+	}
+
+	name := b.String()
+	if ext := path.Ext(v.obj.Info.Filename); !t.has("ext", "filename") && !strings.EqualFold(path.Ext(name), ext) {
+		name += ext
+	}
+
+	return name
+}
+
+// field returns the value of a field of the template.
+func (v templateValues) field(name, arg string) string {
+	oi := v.obj.Info
+	switch name {
+	case "name":
+		return strings.TrimSuffix(oi.Filename, path.Ext(oi.Filename))
+	case "ext":
+		return path.Ext(oi.Filename)
+	case "filename":
+		return oi.Filename
+	case "date":
+		date := oi.CaptureDate
+		if v.md != nil && !v.md.Captured.IsZero() {
+			date = v.md.Captured
+		}
+		if date.IsZero() {
+			return ""
+		}
+		if arg == "" {
+			arg = defaultDateLayout
+		}
+		return date.Format(arg)
+	case "counter":
+		width := defaultCounterWidth
+		if arg != "" {
+			width, _ = strconv.Atoi(arg)
+		}
+		return fmt.Sprintf("%0*d", width, v.counter)
+	case "handle":
+		return fmt.Sprintf("%08x", uint32(v.obj.Handle))
+	case "kind":
+		return string(ip.KindOfObject(oi))
+	case "camera":
+		if v.camera == "" && v.md != nil {
+			return v.md.Model
+		}
+		return v.camera
+	}
+
+	md := v.md
+	if md == nil {
+		return ""
+	}
+	switch name {
+	case "make":
+		return md.Make
+	case "model":
+		return md.Model
+	case "lens":
+		return md.LensModel
+	case "iso":
+		if md.ISO > 0 {
+			return strconv.Itoa(md.ISO)
+		}
+	case "aperture":
+		if md.FNumber > 0 {
+			return "f" + strconv.FormatFloat(md.FNumber, 'f', -1, 64)
+		}
+	case "shutter":
+		switch {
+		case md.ExposureTime <= 0:
+		case md.ExposureTime < 1:
+			return fmt.Sprintf("1-%.0fs", 1/md.ExposureTime)
+		default:
+			return strconv.FormatFloat(md.ExposureTime, 'f', -1, 64) + "s"
+		}
+	case "focal":
+		if md.FocalLength > 0 {
+			return strconv.FormatFloat(md.FocalLength, 'f', -1, 64) + "mm"
+		}
+	case "film":
+		return ptpfmt.FujiFilmSimulationAsString(md.FilmSimulation)
+	}
+
+	return ""
+}
+
+// sanitizeFieldValue replaces the characters that are not allowed in file names on common file systems, so that the
+// value of a field does not add folders to the path.
+func sanitizeFieldValue(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if s == "." || s == ".." {
+		return "_"
+	}
+
+	return s
+}
+
+// Collision policies applied when the file to write already exists.
+const (
+	// collisionOverwrite replaces the existing file.
+	collisionOverwrite = "overwrite"
+	// collisionSkip keeps the existing file and does not download the object.
+	collisionSkip = "skip"
+	// collisionRename adds a sequence number to the name of the file, e.g. DSCF0001-1.JPG.
+	collisionRename = "rename"
+)
+
+// fileNamer names the files written by the download and capture commands using an optional name template, applying a
+// collision policy when the file to write exists, or when an earlier file of the same command run was given the same
+// name.
+type fileNamer struct {
+	tmpl      *nameTemplate
+	collision string
+	// camera is the value of the {camera} field.
+	camera string
+	// counter is the value of the {counter} field of the last named file.
+	counter int
+	// store is the storage the files are written to, used to find the existing files.
+	store    fileStore
+	paths    map[ptp.ObjectHandle]string
+	resolved map[ptp.ObjectHandle]string
+	claimed  map[string]bool
+}
+
+// extractFileNaming removes the name=template and collision=policy arguments from args, returning a fileNamer using
+// them and the remaining arguments. The given default collision policy is used when there is no collision argument.
+func extractFileNaming(args []string, collision string) (*fileNamer, []string, error) {
+	n := &fileNamer{
+		collision: collision,
+		store:     localStore{},
+		paths:     make(map[ptp.ObjectHandle]string),
+		resolved:  make(map[ptp.ObjectHandle]string),
+		claimed:   make(map[string]bool),
+	}
+
+	var rest []string
+	for _, arg := range args {
+		if val, found := strings.CutPrefix(arg, "name="); found {
+			tmpl, err := parseNameTemplate(val)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid name %s: %s", val, err)
+			}
+			n.tmpl = tmpl
+			continue
+		}
+		if val, found := strings.CutPrefix(arg, "collision="); found {
+			switch val {
+			case collisionOverwrite, collisionSkip, collisionRename:
+				n.collision = val
+			default:
+				return nil, nil, fmt.Errorf("invalid collision %s, must be %s, %s or %s", val, collisionOverwrite, collisionSkip, collisionRename)
+			}
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	return n, rest, nil
+}
+
+// name returns the slash separated path for the object, relative to the download directory, counting it as the next
+// file. The metadata function is only called when the template needs the EXIF metadata of the object, failing to read
+// it leaving those fields unknown. The file name on the camera is returned when there is no template.
+func (n *fileNamer) name(obj ip.Object, metadata func() (*ip.ObjectMetadata, error)) string {
+	n.counter++
+	if n.tmpl == nil {
+		return obj.Info.Filename
+	}
+
+	v := templateValues{obj: obj, camera: n.camera, counter: n.counter}
+	if n.tmpl.needsMetadata() && metadata != nil {
+		v.md, _ = metadata()
+	}
+
+	return n.tmpl.expand(v)
+}
+
+// pathFunc returns a function returning the path to download an object to, which replaces the file name of the path
+// returned by base with the name of the object. The name is only determined once for each object, so that it keeps
+// its counter. Once resolved, the path the object was written to is returned.
+func (n *fileNamer) pathFunc(base func(ip.Object) string, metadata func(ip.Object) (*ip.ObjectMetadata, error)) func(ip.Object) string {
+	return func(obj ip.Object) string {
+		if p, ok := n.path(obj); ok {
+			return p
+		}
+
+		p := base(obj)
+		if n.tmpl != nil {
+			p = filepath.Join(filepath.Dir(p), filepath.FromSlash(n.name(obj, func() (*ip.ObjectMetadata, error) {
+				return metadata(obj)
+			})))
+		}
+		n.paths[obj.Handle] = p
+
+		return p
+	}
+}
+
+// path returns the path of the object when it has been determined by the path function returned by pathFunc.
+func (n *fileNamer) path(obj ip.Object) (string, bool) {
+	if p, ok := n.resolved[obj.Handle]; ok {
+		return p, true
+	}
+	p, ok := n.paths[obj.Handle]
+
+	return p, ok
+}
+
+// exists reports whether a file exists at the given path or was given the path earlier.
+func (n *fileNamer) exists(p string) bool {
+	if n.claimed[p] {
+		return true
+	}
+	_, err := n.store.stat(p)
+
+	return err == nil
+}
+
+// resolve applies the rename collision policy to the path, returning the path to write to and claiming it. The path is
+// returned as is for the other policies.
+func (n *fileNamer) resolve(p string) string {
+	if n.collision == collisionRename && n.exists(p) {
+		ext := filepath.Ext(p)
+		base := strings.TrimSuffix(p, ext)
+		for i := 1; ; i++ {
+			if c := fmt.Sprintf("%s-%d%s", base, i, ext); !n.exists(c) {
+				p = c
+				break
+			}
+		}
+	}
+	n.claimed[p] = true
+
+	return p
+}
+
+// resolveObject resolves the path of the object, so that the path function returned by pathFunc returns the path it
+// is written to from then on.
+func (n *fileNamer) resolveObject(obj ip.Object, pathOf func(ip.Object) string) string {
+	p := n.resolve(pathOf(obj))
+	n.resolved[obj.Handle] = p
+
+	return p
+}
+
+// helpAddFileNaming returns the help text of the name and collision arguments of the download commands, the default
+// collision policy being given.
+func helpAddFileNaming(collision string) string {
+	help := "\t- name=template: names the files using a template holding the fields listed below instead of using their name on the camera. The template may hold folders, e.g. name={date:2006/01/02}/{camera}-{counter}{ext}, the extension of the file being added when the template does not end with it\n"
+
+	return help + helpAddTemplateFields() + helpAddCollision(collision)
+}
+
+// helpAddTemplateFields returns the help text describing the fields of a name template.
+func helpAddTemplateFields() string {
+	help := "\t- the fields of a name template are:\n"
+	for _, f := range templateFields {
+		help += "\t    {" + f.name + "}: " + f.help + "\n"
+	}
+
+	return help + "\t  The fields read from the EXIF metadata, from {make} onwards, require reading the start of each image first. Unknown fields are replaced by " + unknownFieldValue + "\n"
+}
+
+// helpAddCollision returns the help text of the collision argument, the default collision policy being given.
+func helpAddCollision(collision string) string {
+	return "\t- collision=policy: what to do when the file exists, " + collisionOverwrite + " replaces it, " + collisionSkip + " keeps it and " + collisionRename + " adds a sequence number to the new file, e.g. DSCF0001-1.JPG. Defaults to " + collision + "\n"
+}
+
+// captureObject returns the object describing an image captured at the given time, which is not stored on the camera
+// or has not been looked up, to name it using a name template.
+func captureObject(captured time.Time) ip.Object {
+	return ip.Object{Info: &ptp.ObjectInfo{CaptureDate: captured, ObjectFormat: ptp.OFC_EXIF_JPEG}}
+}
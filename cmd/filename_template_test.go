@@ -0,0 +1,117 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseNameTemplate(t *testing.T) {
+	for _, tmpl := range []string{"", "{date", "{lens}-{unknown}", "{counter:0}", "{counter:x}"} {
+		if _, err := parseNameTemplate(tmpl); err == nil {
+			t.Errorf("parseNameTemplate() %q error = <nil>; want error", tmpl)
+		}
+	}
+}
+
+func TestNameTemplate_expand(t *testing.T) {
+	v := templateValues{
+		obj: ip.Object{Handle: 0x1a, Info: &ptp.ObjectInfo{
+			Filename:     "DSCF0001.RAF",
+			ObjectFormat: ptp.OFC_Undefined,
+			CaptureDate:  time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local),
+		}},
+		md: &ip.ObjectMetadata{
+			Model:          "X-T1",
+			LensModel:      "XF35mm/F1.4 R",
+			ExposureTime:   0.004,
+			FNumber:        5.6,
+			FilmSimulation: ip.FS_Fuji_ClassicChrome,
+		},
+		camera:  "X-T1 lounge",
+		counter: 12,
+	}
+
+	check := map[string]string{
+		"{name}{ext}":                          "DSCF0001.RAF",
+		"{date:2006/01/02}/{camera}-{counter}": "2021/03/14/X-T1 lounge-0012.RAF",
+		"{film}/{date}_{counter:2}.raf":        "Classic Chrome/20210314-153045_12.raf",
+		"{lens} {aperture} {shutter} {iso}":    "XF35mm_F1.4 R f5.6 1-250s unknown.RAF",
+		"{kind}-{handle}{ext}":                 "raw-0000001a.RAF",
+	}
+	for tmpl, want := range check {
+		nt, err := parseNameTemplate(tmpl)
+		if err != nil {
+			t.Fatalf("parseNameTemplate() %q error = %s; want <nil>", tmpl, err)
+		}
+		if got := nt.expand(v); got != want {
+			t.Errorf("expand() %q got = %s; want %s", tmpl, got, want)
+		}
+	}
+}
+
+func TestExtractFileNaming(t *testing.T) {
+	n, rest, err := extractFileNaming([]string{"format=raw", "name={counter}", "collision=skip"}, collisionOverwrite)
+	if err != nil {
+		t.Fatalf("extractFileNaming() error = %s; want <nil>", err)
+	}
+	if n.tmpl == nil || n.collision != collisionSkip {
+		t.Errorf("extractFileNaming() got = %+v; want a template and collision %s", n, collisionSkip)
+	}
+	if len(rest) != 1 || rest[0] != "format=raw" {
+		t.Errorf("extractFileNaming() rest = %v; want [format=raw]", rest)
+	}
+
+	for _, arg := range []string{"collision=keep", "name={foo}"} {
+		if _, _, err := extractFileNaming([]string{arg}, collisionOverwrite); err == nil {
+			t.Errorf("extractFileNaming() %s error = <nil>; want error", arg)
+		}
+	}
+}
+
+func TestFileNamer_resolve(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "DSCF0001.JPG"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, _, _ := extractFileNaming(nil, collisionRename)
+	want := []string{"DSCF0001-1.JPG", "DSCF0001-2.JPG", "DSCF0002.JPG"}
+	for i, name := range []string{"DSCF0001.JPG", "DSCF0001.JPG", "DSCF0002.JPG"} {
+		if got := n.resolve(filepath.Join(dir, name)); got != filepath.Join(dir, want[i]) {
+			t.Errorf("resolve() got = %s; want %s", got, want[i])
+		}
+	}
+
+	n, _, _ = extractFileNaming(nil, collisionOverwrite)
+	if got := n.resolve(filepath.Join(dir, "DSCF0001.JPG")); got != filepath.Join(dir, "DSCF0001.JPG") {
+		t.Errorf("resolve() got = %s; want %s", got, filepath.Join(dir, "DSCF0001.JPG"))
+	}
+}
+
+func TestFileNamer_pathFunc(t *testing.T) {
+	n, _, _ := extractFileNaming([]string{"name={counter:2}-{model}"}, collisionOverwrite)
+	var reads int
+	pathOf := n.pathFunc(func(obj ip.Object) string {
+		return objectPath("photos", obj)
+	}, func(ip.Object) (*ip.ObjectMetadata, error) {
+		reads++
+		return &ip.ObjectMetadata{Model: "X-T1"}, nil
+	})
+
+	objs := []ip.Object{
+		{Handle: 1, Info: &ptp.ObjectInfo{Filename: "DSCF0001.JPG"}},
+		{Handle: 2, Info: &ptp.ObjectInfo{Filename: "DSCF0002.JPG"}},
+	}
+	for i, want := range []string{"01-X-T1.JPG", "02-X-T1.JPG", "01-X-T1.JPG"} {
+		if got := pathOf(objs[i%2]); got != filepath.Join("photos", want) {
+			t.Errorf("pathOf() got = %s; want %s", got, filepath.Join("photos", want))
+		}
+	}
+	if reads != 2 {
+		t.Errorf("pathOf() metadata reads = %d; want 2", reads)
+	}
+}
@@ -6,25 +6,43 @@ import (
 	"fmt"
 	"github.com/malc0mn/ptp-ip/ip"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	defaultIp = "127.0.0.1"
+	// defaultCommandTimeout is the default time a client waits for a command in server mode.
+	defaultCommandTimeout = time.Minute
 )
 
 var (
 	valueOutOfRange = errors.New("value out of range")
 
-	cmd  string
-	file string
+	cmd     string
+	script  string
+	file    string
+	cameras string
 
 	interactive bool
 	server      bool
 
-	showHelp    bool
-	showVersion bool
+	showHelp         bool
+	showVersion      bool
+	showCapabilities bool
+	showConfig       bool
 
 	verbosity ip.LogLevel
+
+	jsonErrors bool
+
+	debugCommands bool
+
+	// retries and retryBackoff control connecting to a responder that does not answer, see connectCamera. cmdDeadline
+	// is the time allowed for connecting and executing the command given using the -c flag.
+	retries      int
+	retryBackoff time.Duration
+	cmdDeadline  time.Duration
 )
 
 // Custom flag type that will only accept uint16 values, ideal for ports!
@@ -44,8 +62,26 @@ func (i *uint16Value) String() string {
 	return strconv.FormatInt(int64(*i), 10)
 }
 
+// listValue is a flag holding a comma separated list of values.
+type listValue []string
+
+func (l *listValue) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+
+	return nil
+}
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
 func initFlags() {
-	flag.StringVar(&conf.vendor, "t", ip.DefaultVendor, "The vendor of the responder that will be connected to.")
+	flag.StringVar(&conf.vendor, "t", ip.DefaultVendor, "The vendor of the responder that will be connected to. Use 'auto' to detect the vendor when connecting.")
 	flag.StringVar(&conf.host, "h", ip.DefaultIpAddress, "The responder host to connect to.")
 	flag.Var(&conf.port, "p", "The responder port to connect to. Use this flag when the responder has only ONE port for all channels!")
 	flag.Var(&conf.cport, "pc", "The responder port used for the Command/Data connection.")
@@ -53,20 +89,42 @@ func initFlags() {
 	flag.Var(&conf.sport, "ps", "The responder port used for the streamer or 'live view' connection.")
 	flag.StringVar(&conf.fname, "n", "", "A custom friendly name to use for the initiator.")
 	flag.StringVar(&conf.guid, "g", "", "A custom GUID to use for the initiator. (default random)")
+	flag.StringVar(&conf.bleAddr, "ble", "", "The Bluetooth address of the responder: it is woken up over Bluetooth LE before connecting. Requires a binary built with the with_ble tag.")
+	flag.StringVar(&conf.bleWake, "ble-wake", "", "To be used in combination with '-ble': the wake sequence as comma separated handle:value pairs, the value being hex encoded, e.g. '0x0012:0100'.")
+	flag.StringVar(&conf.wifiSSID, "wifi", "", "Join the WiFi network hosted by the responder before connecting: the first SSID matching this pattern is joined and the responder is discovered on it, e.g. 'FUJIFILM-X-T1-*'. Linux only, requires NetworkManager.")
+	flag.StringVar(&conf.wifiPassword, "wifi-password", "", "To be used in combination with '-wifi': the password of the WiFi network, when it is protected.")
 
 	flag.BoolVar(&interactive, "i", false, fmt.Sprintf("This will run the %s command with an interactive shell.", exe))
 
 	flag.StringVar(&cmd, "c", "", "The command to send to the responder.")
+	flag.IntVar(&retries, "retries", 0, "The number of times to retry connecting when the responder does not answer.")
+	flag.DurationVar(&retryBackoff, "retry-backoff", time.Second, fmt.Sprintf("To be used in combination with '-retries': the time to wait before retrying to connect, which doubles after every attempt up to %s.", maxRetryBackoff))
+	flag.DurationVar(&cmdDeadline, "deadline", 0, "To be used in combination with '-c': the time allowed for connecting to the responder, retries included, and executing the command. When exceeded, the exit code tells whether the responder never answered or the command timed out. (default no deadline)")
+	flag.StringVar(&script, "script", "", "Execute the commands found in the given script file.")
+	flag.StringVar(&cameras, "camera", "", "Use the camera with the given name as defined in the config file. In server mode, several cameras can be loaded at once by separating their names with a comma.")
 	flag.StringVar(&file, "f", "", "Read all settings from a config file. The config file will override any command line flags present.")
 
 	flag.BoolVar(&server, "s", false, fmt.Sprintf("This will run the %s command as a server", exe))
 	flag.StringVar(&conf.srvAddr, "sa", defaultIp, "To be used in combination with '-s': this defines the server address to listen on.")
 	flag.Var(&conf.srvPort, "sp", "To be used in combination with '-s': this defines the server port to listen on.")
+	flag.Var(&conf.webPort, "sw", "To be used in combination with '-s': this defines the port to serve the web UI, the WebSocket API and the health endpoints on, using the server address. (default disabled)")
+	flag.Var(&conf.grpcPort, "sg", "To be used in combination with '-s': this defines the port to serve the gRPC API described in proto/ptpip.proto on, using the server address. (default disabled)")
+	flag.Var(&conf.wsOrigins, "ws-origins", "To be used in combination with '-sw': a comma separated list of the origins of the web pages allowed to use the WebSocket API and to capture using the web UI API besides the web UI, e.g. 'https://example.com'. Use '*' to allow all origins. (default only the web UI)")
+	flag.DurationVar(&conf.cmdTimeout, "command-timeout", defaultCommandTimeout, "To be used in combination with '-s' or '-i': the time a client waits for a command to finish, including the time waiting for its turn. Long-running commands such as watch and download are not affected. Use 0 to wait forever.")
+	flag.StringVar(&conf.pidFile, "pid", "", "To be used in combination with '-s': write the process ID to this file, which is removed again when shutting down.")
 
 	flag.BoolVar(&showHelp, "?", false, "Display usage information.")
 	flag.BoolVar(&showVersion, "version", false, "Display version info.")
+	flag.BoolVar(&showVersion, "V", false, "Display version info, same as -version.")
+	flag.BoolVar(&showCapabilities, "capabilities", false, "List the capabilities per vendor, the available commands and the supported formats.")
+	flag.BoolVar(&showConfig, "dump-config", false, "Print the effective configuration, after merging the config file and the command line flags, and exit. The output uses the format of the config file, which defaults to INI.")
 
 	flag.Var(&verbosity, "v", "PTP/IP log level verbosity: ranges from v to vvv.")
+	flag.BoolVar(&jsonErrors, "json-errors", false, "Report errors on stderr as a single line JSON object holding the exit code, the failure class, the message and the PTP response code if any.")
+	flag.BoolVar(&debugCommands, "debug", false, "Enable the commands meant for reverse engineering the protocol, such as rawsend, which write arbitrary packets to the responder.")
+	flag.Var(&previewProtocol, "preview-protocol", "The protocol used to display images in the terminal when passing --preview to a command: 'auto', 'sixel', 'iterm2' or 'kitty'. Auto detection relies on environment variables such as TERM and TERM_PROGRAM.")
+	flag.Var(&logFmt, "log-format", "The format of the log messages: 'text' or 'json', which outputs one JSON object per line.")
+	flag.Var(&lvDecoder, "lv-decoder", "The decoder used to decode the live view frames displayed in the live view window: 'std', 'turbojpeg' when built using the with_turbojpeg tag, or 'none' when the live view is only streamed or recorded.")
 
 	// Set a custom usage function.
 	flag.Usage = printUsage
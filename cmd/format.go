@@ -12,7 +12,7 @@ import (
 	"text/tabwriter"
 )
 
-func formatDeviceProperty(c *ip.Client, param string) (ptp.DevicePropCode, error) {
+func formatDeviceProperty(c ip.ClientAPI, param string) (ptp.DevicePropCode, error) {
 	var cod ptp.DevicePropCode
 
 	conv, errH := ptpfmt.HexStringToUint64(param, 16)
@@ -32,6 +32,99 @@ func formatDeviceProperty(c *ip.Client, param string) (ptp.DevicePropCode, error
 	return cod, nil
 }
 
+// rangeFormMaxValues limits the number of values taken from a range form when resolving human readable values.
+const rangeFormMaxValues = 1024
+
+// formatDevicePropertyValue converts param to a value for the given device property. Human readable values such as
+// "classic chrome" or "f/5.6" are looked up in the values supported by the camera. Anything else is considered to be a
+// hexadecimal value, which may start with 0x but this is not mandatory.
+func formatDevicePropertyValue(c ip.ClientAPI, cod ptp.DevicePropCode, param string) (uint32, error) {
+	if strings.HasPrefix(param, "0x") {
+		conv, err := ptpfmt.HexStringToUint64(param, 32)
+		return uint32(conv), err
+	}
+
+	dpd := c.CachedDevicePropertyDescription(cod)
+	if dpd == nil {
+		var err error
+		if dpd, err = c.GetDevicePropertyDescription(cod); err != nil {
+			c.Debugf("Unable to describe property %#x: %s", cod, err)
+		}
+	}
+
+	var errV error
+	if values := supportedDevicePropertyValues(dpd); len(values) > 0 {
+		v, err := ptpfmt.DevicePropValFromString(c.ResponderVendor(), cod, param, values)
+		if err == nil {
+			c.Debugf("Converted %s: %#x", param, v)
+			return uint32(v), nil
+		}
+		errV = err
+	}
+
+	conv, err := ptpfmt.HexStringToUint64(param, 32)
+	if err != nil {
+		if errV != nil {
+			return 0, errV
+		}
+		return 0, err
+	}
+
+	return uint32(conv), nil
+}
+
+// supportedDevicePropertyValues returns the values allowed by the form of the device property description. Range forms
+// holding too many values are not expanded.
+func supportedDevicePropertyValues(dpd *ptp.DevicePropDesc) []int64 {
+	if dpd == nil {
+		return nil
+	}
+
+	switch form := dpd.Form.(type) {
+	case *ptp.EnumerationForm:
+		return form.SupportedValuesAsInt64Array()
+	case *ptp.RangeForm:
+		min, max, step := form.MinimumValueAsInt64(), form.MaximumValueAsInt64(), form.StepSizeAsInt64()
+		if step <= 0 || max < min || (max-min)/step >= rangeFormMaxValues {
+			return nil
+		}
+		var values []int64
+		for v := min; v <= max; v += step {
+			values = append(values, v)
+		}
+		return values
+	}
+
+	return nil
+}
+
+// formatAllowedValues returns the values allowed by the form of the device property description in human readable form,
+// e.g. "200, 400, 800" or "100 to 6400 in steps of 100". An empty string is returned when there is no form.
+func formatAllowedValues(vendor ptp.VendorExtension, dpd *ptp.DevicePropDesc) string {
+	if dpd == nil {
+		return ""
+	}
+
+	switch form := dpd.Form.(type) {
+	case *ptp.RangeForm:
+		return fmt.Sprintf(
+			"%s to %s in steps of %d",
+			watchPropValue(vendor, dpd.DevicePropertyCode, uint32(form.MinimumValueAsInt64())),
+			watchPropValue(vendor, dpd.DevicePropertyCode, uint32(form.MaximumValueAsInt64())),
+			form.StepSizeAsInt64(),
+		)
+	case *ptp.EnumerationForm:
+		vals := form.SupportedValuesAsInt64Array()
+		str := make([]string, len(vals))
+		for i, val := range vals {
+			str[i] = watchPropValue(vendor, dpd.DevicePropertyCode, uint32(val))
+		}
+		return strings.Join(str, ", ")
+	}
+
+	return ""
+}
+
 func formatDeviceInfo(vendor ptp.VendorExtension, data interface{}, f []string) string {
 	switch vendor {
 	case ptp.VE_FujiPhotoFilmCoLtd:
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"github.com/malc0mn/ptp-ip/ip"
 	"github.com/malc0mn/ptp-ip/ptp"
 	"testing"
@@ -54,3 +55,53 @@ func TestFormatDeviceProperty(t *testing.T) {
 		t.Errorf("formatDeviceProperty() got %#x; want %#x", got, want)
 	}
 }
+
+func TestSupportedDevicePropertyValues(t *testing.T) {
+	if got := supportedDevicePropertyValues(nil); got != nil {
+		t.Errorf("supportedDevicePropertyValues() got = %v; want <nil>", got)
+	}
+
+	dpd := &ptp.DevicePropDesc{Form: &ptp.RangeForm{
+		MinimumValue: []byte{0x01, 0x00},
+		MaximumValue: []byte{0x07, 0x00},
+		StepSize:     []byte{0x02, 0x00},
+	}}
+	want := []int64{1, 3, 5, 7}
+	got := supportedDevicePropertyValues(dpd)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("supportedDevicePropertyValues() got = %v; want %v", got, want)
+	}
+
+	dpd.Form = &ptp.RangeForm{
+		MinimumValue: []byte{0x00, 0x00},
+		MaximumValue: []byte{0xff, 0xff},
+		StepSize:     []byte{0x01, 0x00},
+	}
+	if got := supportedDevicePropertyValues(dpd); got != nil {
+		t.Errorf("supportedDevicePropertyValues() got = %v; want <nil>", got)
+	}
+}
+
+func TestFormatAllowedValues(t *testing.T) {
+	vendor := ptp.VE_MicrosoftCorporation
+	if got := formatAllowedValues(vendor, nil); got != "" {
+		t.Errorf("formatAllowedValues() got = %s; want ''", got)
+	}
+
+	dpd := &ptp.DevicePropDesc{DevicePropertyCode: ptp.DPC_WhiteBalance, Form: &ptp.EnumerationForm{
+		NumberOfValues:  2,
+		SupportedValues: [][]byte{{0x02, 0x00}, {0x04, 0x00}},
+	}}
+	if got, want := formatAllowedValues(vendor, dpd), "automatic, daylight"; got != want {
+		t.Errorf("formatAllowedValues() got = %s; want %s", got, want)
+	}
+
+	dpd = &ptp.DevicePropDesc{DevicePropertyCode: ptp.DevicePropCode(0xd0ff), Form: &ptp.RangeForm{
+		MinimumValue: []byte{0x01, 0x00},
+		MaximumValue: []byte{0x07, 0x00},
+		StepSize:     []byte{0x02, 0x00},
+	}}
+	if got, want := formatAllowedValues(vendor, dpd), "0x1 to 0x7 in steps of 2"; got != want {
+		t.Errorf("formatAllowedValues() got = %s; want %s", got, want)
+	}
+}
@@ -2,22 +2,91 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"github.com/malc0mn/ptp-ip/ip"
 	"os"
+	"strings"
 	"time"
 )
 
-func iShell(c *ip.Client) {
+// shellOwner identifies the interactive shell as a client of the job queue.
+const shellOwner = "shell"
+
+// iShell executes the commands read from stdin using the job queue, so that they do not interfere with the clients of
+// the servers. The shell may execute destructive commands without locking the camera, see jobQueue. Everything a script
+// can do is allowed in the shell as well: the variables are kept until the shell is closed.
+func iShell(c *ip.Client, q *jobQueue) {
+	lmp := "[iShell]"
+	q.setLocal(shellOwner)
 	rw := bufio.NewReadWriter(bufio.NewReader(os.Stdin), bufio.NewWriter(os.Stdout))
+	sh := newShellSession(c, rw.Writer)
+	sh.s.execute = func(cmd string, w *bufio.Writer) error {
+		return q.execute(cmd, w, c, shellOwner, lmp)
+	}
+
 	fmt.Print("Interactive shell ready to receive commands.\n")
 	for {
-		// TODO: find a good way (not sleep) to "separate" the outputs so that the '> ' below does not get 'mixed' with
-		//  the Dial() debug output from the client...
-		time.Sleep(1 * time.Second)
+		if sh.pending() {
+			fmt.Print("... ")
+		} else {
+			// TODO: find a good way (not sleep) to "separate" the outputs so that the '> ' below does not get 'mixed'
+			//  with the Dial() debug output from the client...
+			time.Sleep(1 * time.Second)
+			fmt.Print("> ")
+		}
 
-		fmt.Print("> ")
-		readAndExecuteCommand(rw, c, "[iShell]")
+		msg, ok := readCommand(rw.Reader, lmp)
+		if !ok {
+			continue
+		}
+		done, err := sh.feed(msg)
+		if !done {
+			continue
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
 		fmt.Print("\n\n")
 	}
 }
+
+// shellSession executes the lines entered in the interactive shell as a script.
+type shellSession struct {
+	s *scriptRunner
+	// block holds the lines of a block, such as an if or a repeat statement, that has not been closed yet.
+	block []string
+}
+
+func newShellSession(c ip.ClientAPI, w *bufio.Writer) *shellSession {
+	return &shellSession{s: newScriptRunner(c, w)}
+}
+
+// pending returns true when waiting for the remainder of a block.
+func (sh *shellSession) pending() bool {
+	return len(sh.block) > 0
+}
+
+// feed executes the line or, when it opens a block, waits for the lines completing the block before executing the
+// block. It returns false when the line has not been executed yet.
+func (sh *shellSession) feed(line string) (bool, error) {
+	sh.block = append(sh.block, line)
+	stmts, err := parseScript([]byte(strings.Join(sh.block, "\n")))
+	var ue *scriptUnterminatedError
+	if errors.As(err, &ue) {
+		return false, nil
+	}
+	sh.block = nil
+	if err != nil {
+		return true, err
+	}
+
+	err = sh.s.run(stmts)
+	var ce *scriptCommandError
+	if len(stmts) == 1 && stmts[0].keyword == "" && errors.As(err, &ce) {
+		// The output of the command already holds the error.
+		return true, nil
+	}
+
+	return true, err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strings"
+	"testing"
+)
+
+func TestShellSession_feed(t *testing.T) {
+	var b bytes.Buffer
+	sh := newShellSession(newMockClient(ptp.VE_MicrosoftCorporation), bufio.NewWriter(&b))
+	var cmds []string
+	sh.s.execute = func(cmd string, _ *bufio.Writer) error {
+		cmds = append(cmds, cmd)
+		if cmd == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	lines := []struct {
+		line string
+		done bool
+	}{
+		{"let iso = 200", true},
+		{"repeat 3 {", false},
+		{"set iso $iso", false},
+		{"let iso = $((iso * 2))", false},
+		{"}", true},
+		{"echo $iso", true},
+	}
+	for _, l := range lines {
+		done, err := sh.feed(l.line)
+		if err != nil || done != l.done {
+			t.Errorf("feed(%s) got = %v, %v; want %v, <nil>", l.line, done, err, l.done)
+		}
+		if sh.pending() == l.done {
+			t.Errorf("pending() after %s got = %v; want %v", l.line, sh.pending(), !l.done)
+		}
+	}
+
+	want := "set iso 200,set iso 400,set iso 800"
+	if got := strings.Join(cmds, ","); got != want {
+		t.Errorf("feed() executed %s; want %s", got, want)
+	}
+	if got := b.String(); got != "1600\n" {
+		t.Errorf("feed() got = %q; want %q", got, "1600\n")
+	}
+
+	if done, err := sh.feed("end"); !done || err == nil || sh.pending() {
+		t.Errorf("feed(end) got = %v, %v; want true and an error", done, err)
+	}
+
+	// A failing command stops a block, but is not reported twice when entered on its own.
+	if _, err := sh.feed("fail"); err != nil {
+		t.Errorf("feed(fail) error = %s; want <nil>", err)
+	}
+	cmds = nil
+	sh.feed("repeat 2 {")
+	sh.feed("fail")
+	if _, err := sh.feed("}"); err == nil || len(cmds) != 1 {
+		t.Errorf("feed(}) got = %v after %d commands; want error after 1 command", err, len(cmds))
+	}
+}
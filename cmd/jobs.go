@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errCameraLocked  = errors.New("camera locked by another client")
+	errLockRequired  = errors.New("lock the camera first using the lock command")
+	errNotLockHolder = errors.New("camera not locked by this client")
+)
+
+// eventLookupTimeout is the time the event broadcasters of the servers wait for their turn to look up the new value of
+// a changed device property. The value is left out when it passes.
+const eventLookupTimeout = 5 * time.Second
+
+// job is a command line, or any other piece of work talking to the camera, submitted to a jobQueue.
+type job struct {
+	run     func()
+	started chan struct{}
+	done    chan struct{}
+}
+
+// jobTimeoutError is returned when a job did not finish within the timeout of its jobQueue. When the job was still
+// waiting for its turn, it has been dropped. Otherwise, it keeps on running because the PTP/IP session offers no way
+// to abort an operation, but the client no longer waits for it. It implements net.Error so that it is reported as a
+// timeout, see exitCode.
+type jobTimeoutError struct {
+	timeout time.Duration
+	queued  bool
+}
+
+func (e *jobTimeoutError) Error() string {
+	if e.queued {
+		return fmt.Sprintf("timed out after %s waiting for the camera, the command was not executed", e.timeout)
+	}
+
+	return fmt.Sprintf("timed out after %s, the command is still being executed by the camera", e.timeout)
+}
+
+func (e *jobTimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *jobTimeoutError) Temporary() bool {
+	return true
+}
+
+// jobQueue arbitrates the access of the server clients, i.e. the local server connections, the WebSocket clients and
+// the web UI, to the single PTP/IP session of a camera. Jobs are executed one at a time, the clients taking turns so
+// that a client submitting many jobs cannot starve the others. Live view frames are not affected: they are broadcast to
+// all viewers without passing through the queue.
+// A client can lock the camera, after which it is the only one allowed to execute destructive commands. Without a
+// lock, destructive commands are refused altogether so that clients cannot destroy data another client relies on.
+// A client does not wait longer than the timeout of the queue for its job, long-running commands excepted.
+type jobQueue struct {
+	mu sync.Mutex
+	// pending holds the jobs waiting per client, order the clients in the order they take turns.
+	pending map[string][]*job
+	order   []string
+	// holder is the client holding the lock, if any.
+	holder string
+	// local is the client using the interactive shell, who may execute destructive commands without locking the
+	// camera as long as no other client holds the lock.
+	local string
+	// timeout is the time a client waits for its job to finish, zero meaning there is no timeout. It can be changed
+	// while running, see setTimeout.
+	timeout time.Duration
+	wake    chan struct{}
+}
+
+func newJobQueue(timeout time.Duration) *jobQueue {
+	q := &jobQueue{
+		pending: make(map[string][]*job),
+		timeout: timeout,
+		wake:    make(chan struct{}, 1),
+	}
+	go q.work()
+
+	return q
+}
+
+// queue queues the work for the given client and returns the job.
+func (q *jobQueue) queue(owner string, run func()) *job {
+	j := &job{run: run, started: make(chan struct{}), done: make(chan struct{})}
+	q.add(owner, j)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return j
+}
+
+// timer returns a channel receiving when the timeout of the queue has passed, or a nil channel when there is no
+// timeout.
+func (q *jobQueue) timer(timeout time.Duration) (<-chan time.Time, func()) {
+	if timeout <= 0 {
+		return nil, func() {}
+	}
+	t := time.NewTimer(timeout)
+
+	return t.C, func() { t.Stop() }
+}
+
+// submit queues the work for the given client and waits for it to finish. When the work has not started by the time
+// the timeout of the queue has passed, it is dropped and a *jobTimeoutError returned. Work that has started is always
+// waited for, since the caller needs its results.
+func (q *jobQueue) submit(owner string, run func()) error {
+	return q.submitWithin(owner, q.currentTimeout(), run)
+}
+
+// submitWithin is submit using the given timeout rather than the timeout of the queue.
+func (q *jobQueue) submitWithin(owner string, timeout time.Duration, run func()) error {
+	j := q.queue(owner, run)
+	expired, stop := q.timer(timeout)
+	defer stop()
+
+	select {
+	case <-j.started:
+	case <-expired:
+		if q.cancel(owner, j) {
+			return &jobTimeoutError{timeout: timeout, queued: true}
+		}
+	}
+	<-j.done
+
+	return nil
+}
+
+// execute queues the command line for the given client and waits for it to finish. See executeCommand. When the
+// command has not finished by the time the timeout of the queue has passed, unless it is a long-running command, a
+// *jobTimeoutError is returned and written to w. The output of a command that keeps on running is discarded.
+func (q *jobQueue) execute(msg string, w *bufio.Writer, c ip.ClientAPI, owner, lmp string) error {
+	timeout := q.currentTimeout()
+	if isLongRunning(strings.Fields(msg)) {
+		timeout = 0
+	}
+	out := &jobOutput{w: w}
+	errc := make(chan error, 1)
+	j := q.queue(owner, func() {
+		errc <- executeCommand(msg, bufio.NewWriter(out), &sharedClient{ClientAPI: c, q: q, owner: owner}, lmp)
+	})
+	expired, stop := q.timer(timeout)
+	defer stop()
+
+	select {
+	case <-j.done:
+		return <-errc
+	case <-expired:
+		err := &jobTimeoutError{timeout: timeout, queued: q.cancel(owner, j)}
+		logger.Warnf("%s '%s' %s", lmp, msg, err)
+		out.abandon(err.Error() + "\n")
+		return err
+	}
+}
+
+// cancel removes the job from the queue, returning false when it is no longer waiting for its turn.
+func (q *jobQueue) cancel(owner string, j *job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.pending[owner]
+	for i, pj := range jobs {
+		if pj != j {
+			continue
+		}
+		if len(jobs) > 1 {
+			q.pending[owner] = append(jobs[:i:i], jobs[i+1:]...)
+			return true
+		}
+		delete(q.pending, owner)
+		for k, o := range q.order {
+			if o == owner {
+				q.order = append(q.order[:k:k], q.order[k+1:]...)
+				break
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// add queues the job for the given client.
+func (q *jobQueue) add(owner string, j *job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.pending[owner]; !ok {
+		q.order = append(q.order, owner)
+	}
+	q.pending[owner] = append(q.pending[owner], j)
+}
+
+func (q *jobQueue) work() {
+	for range q.wake {
+		for j := q.next(); j != nil; j = q.next() {
+			close(j.started)
+			j.run()
+			close(j.done)
+		}
+	}
+}
+
+// next returns the first job of the client whose turn it is, moving the client to the back of the line.
+func (q *jobQueue) next() *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.order) == 0 {
+		return nil
+	}
+	owner := q.order[0]
+	q.order = q.order[1:]
+
+	jobs := q.pending[owner]
+	j := jobs[0]
+	if len(jobs) > 1 {
+		q.pending[owner] = jobs[1:]
+		q.order = append(q.order, owner)
+	} else {
+		delete(q.pending, owner)
+	}
+
+	return j
+}
+
+// depth returns the number of jobs waiting for their turn, the job being executed not included.
+func (q *jobQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, jobs := range q.pending {
+		n += len(jobs)
+	}
+
+	return n
+}
+
+// setTimeout changes the timeout of the queue. Jobs already waiting keep the timeout they were submitted with.
+func (q *jobQueue) setTimeout(timeout time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.timeout = timeout
+}
+
+func (q *jobQueue) currentTimeout() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.timeout
+}
+
+// setLocal sets the client using the interactive shell.
+func (q *jobQueue) setLocal(owner string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.local = owner
+}
+
+// lock locks the camera for the given client. Locking a camera that the client has locked before is a no-op.
+func (q *jobQueue) lock(owner string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.holder != "" && q.holder != owner {
+		return errCameraLocked
+	}
+	q.holder = owner
+
+	return nil
+}
+
+// unlock releases the lock held by the given client.
+func (q *jobQueue) unlock(owner string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.holder != owner {
+		return errNotLockHolder
+	}
+	q.holder = ""
+
+	return nil
+}
+
+// release releases the lock when held by the given client, e.g. because the client went away.
+func (q *jobQueue) release(owner string) {
+	q.unlock(owner)
+}
+
+// mayDestroy returns nil when the given client is allowed to execute destructive commands.
+func (q *jobQueue) mayDestroy(owner string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch q.holder {
+	case owner:
+		return nil
+	case "":
+		if owner == q.local {
+			return nil
+		}
+		return errLockRequired
+	default:
+		return errCameraLocked
+	}
+}
+
+// jobOutput passes the output of a command to the writer of its client until the client stops waiting for it.
+type jobOutput struct {
+	mu        sync.Mutex
+	w         *bufio.Writer
+	abandoned bool
+}
+
+func (o *jobOutput) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.abandoned {
+		return len(b), nil
+	}
+	if _, err := o.w.Write(b); err != nil {
+		return 0, err
+	}
+
+	return len(b), o.w.Flush()
+}
+
+// abandon writes the final message to the writer of the client, which is not written to any longer after that.
+func (o *jobOutput) abandon(msg string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.abandoned = true
+	o.w.WriteString(msg)
+	o.w.Flush()
+}
+
+// sharedClient is the client passed to the commands executed by a jobQueue on behalf of one of its clients.
+type sharedClient struct {
+	ip.ClientAPI
+	q     *jobQueue
+	owner string
+}
+
+// sharedClientOf returns the sharedClient the command is executed for, which may have been handed to a script first.
+func sharedClientOf(c ip.ClientAPI) (*sharedClient, bool) {
+	sc, ok := unwrapScriptClient(c).(*sharedClient)
+
+	return sc, ok
+}
+
+// checkDestructive returns an error when the command line holds a destructive command the client is not allowed to
+// execute.
+func (sc *sharedClient) checkDestructive(f []string) error {
+	cmd, ok := commandByName(f[0]).(destructiveCommand)
+	if !ok || !cmd.destructive(f[1:]) {
+		return nil
+	}
+	if err := sc.q.mayDestroy(sc.owner); err != nil {
+		return fmt.Errorf("%s: %w", f[0], err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobQueue_next(t *testing.T) {
+	q := &jobQueue{pending: make(map[string][]*job)}
+
+	var got []string
+	for _, owner := range []string{"a", "a", "a", "b", "c"} {
+		owner := owner
+		q.add(owner, &job{run: func() { got = append(got, owner) }})
+	}
+	if got := q.depth(); got != 5 {
+		t.Errorf("depth() got = %d; want 5", got)
+	}
+	for j := q.next(); j != nil; j = q.next() {
+		j.run()
+	}
+	if got := q.depth(); got != 0 {
+		t.Errorf("depth() got = %d; want 0", got)
+	}
+
+	if want := "abcaa"; strings.Join(got, "") != want {
+		t.Errorf("next() got = %s; want %s", strings.Join(got, ""), want)
+	}
+}
+
+func TestJobQueue_lock(t *testing.T) {
+	q := newJobQueue(0)
+
+	if err := q.mayDestroy("a"); !errors.Is(err, errLockRequired) {
+		t.Errorf("mayDestroy() error = %v; want %s", err, errLockRequired)
+	}
+	if err := q.lock("a"); err != nil {
+		t.Errorf("lock() error = %s; want <nil>", err)
+	}
+	if err := q.lock("b"); !errors.Is(err, errCameraLocked) {
+		t.Errorf("lock() error = %v; want %s", err, errCameraLocked)
+	}
+	if err := q.mayDestroy("a"); err != nil {
+		t.Errorf("mayDestroy() error = %s; want <nil>", err)
+	}
+	if err := q.mayDestroy("b"); !errors.Is(err, errCameraLocked) {
+		t.Errorf("mayDestroy() error = %v; want %s", err, errCameraLocked)
+	}
+	if err := q.unlock("b"); !errors.Is(err, errNotLockHolder) {
+		t.Errorf("unlock() error = %v; want %s", err, errNotLockHolder)
+	}
+
+	q.release("a")
+	if err := q.lock("b"); err != nil {
+		t.Errorf("lock() error = %s; want <nil>", err)
+	}
+
+	q.setLocal("shell")
+	if err := q.mayDestroy("shell"); !errors.Is(err, errCameraLocked) {
+		t.Errorf("mayDestroy() error = %v; want %s", err, errCameraLocked)
+	}
+	q.release("b")
+	if err := q.mayDestroy("shell"); err != nil {
+		t.Errorf("mayDestroy() error = %s; want <nil>", err)
+	}
+}
+
+func TestJobQueue_timeout(t *testing.T) {
+	q := newJobQueue(50 * time.Millisecond)
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+
+	// Keep the queue busy while the other jobs time out waiting for their turn.
+	block := make(chan struct{})
+	go q.submit("a", func() { <-block })
+	time.Sleep(10 * time.Millisecond)
+
+	ran := false
+	var jte *jobTimeoutError
+	if err := q.submit("b", func() { ran = true }); !errors.As(err, &jte) || !jte.queued {
+		t.Errorf("submit() error = %v; want queued *jobTimeoutError", err)
+	}
+
+	var b bytes.Buffer
+	if err := q.execute("help help", bufio.NewWriter(&b), c, "b", "test"); !errors.As(err, &jte) || !jte.queued {
+		t.Errorf("execute() error = %v; want queued *jobTimeoutError", err)
+	}
+	if got := b.String(); !strings.Contains(got, "timed out") {
+		t.Errorf("execute() got = %q; want timeout message", got)
+	}
+	if code := exitCode(jte, errGeneral); code != errTimeout {
+		t.Errorf("exitCode() got = %d; want %d", code, errTimeout)
+	}
+	q.setTimeout(0)
+	if err := q.submitWithin("c", 10*time.Millisecond, func() { ran = true }); !errors.As(err, &jte) || jte.timeout != 10*time.Millisecond {
+		t.Errorf("submitWithin() error = %v; want queued *jobTimeoutError after 10ms", err)
+	}
+
+	close(block)
+	b.Reset()
+	if err := q.execute("help help", bufio.NewWriter(&b), c, "b", "test"); err != nil {
+		t.Errorf("execute() error = %s; want <nil>", err)
+	}
+	if !strings.Contains(b.String(), `"help"`) {
+		t.Errorf("execute() got = %q; want help output", b.String())
+	}
+	if ran {
+		t.Error("submit() ran the job that timed out")
+	}
+	if len(q.pending) != 0 || len(q.order) != 0 {
+		t.Errorf("cancel() left pending = %v, order = %v; want none", q.pending, q.order)
+	}
+}
+
+func TestIsLongRunning(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"watch", true},
+		{"get iso --watch", true},
+		{"get iso", false},
+		{"download /tmp", true},
+		{"help", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isLongRunning(strings.Fields(tt.msg)); got != tt.want {
+			t.Errorf("isLongRunning(%q) got = %v; want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestJobQueue_execute(t *testing.T) {
+	q := newJobQueue(0)
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+
+	var b bytes.Buffer
+	if err := q.execute("opreq 0x100b 0x1", bufio.NewWriter(&b), c, "a", "test"); !errors.Is(err, errLockRequired) {
+		t.Errorf("execute() error = %v; want %s", err, errLockRequired)
+	}
+	if _, ok := c.requests[ptp.OC_DeleteObject]; ok {
+		t.Errorf("execute() sent DeleteObject; want it refused")
+	}
+
+	b.Reset()
+	q.execute("lock", bufio.NewWriter(&b), c, "a", "test")
+	if got := b.String(); got != "camera locked\n" {
+		t.Errorf("execute() got = %q; want %q", got, "camera locked\n")
+	}
+
+	q.execute("opreq 0x100b 0x1", bufio.NewWriter(&b), c, "a", "test")
+	if _, ok := c.requests[ptp.OC_DeleteObject]; !ok {
+		t.Errorf("execute() did not send DeleteObject for the lock holder")
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/streamer"
+)
+
+const (
+	lvHttpArg   = "--http"
+	lvRtspArg   = "--rtsp"
+	lvRecArg    = "--record"
+	lvWebcamArg = "--webcam"
+	lvStopArg   = "stop"
+
+	lvRecSizeArg     = "--record-size"
+	lvRecDurationArg = "--record-duration"
+	lvRecFormatArg   = "--record-format"
+	lvFpsArg         = "--fps"
+)
+
+// lvStreams holds the servers streaming the live view. It is nil when no streaming is taking place.
+var lvStreams []io.Closer
+
+// lvArgValue returns the value following the given argument and true when the argument is present.
+func lvArgValue(f []string, arg string) (string, bool) {
+	for i, a := range f {
+		if a == arg {
+			if i+1 < len(f) {
+				return f[i+1], true
+			}
+			return "", true
+		}
+	}
+
+	return "", false
+}
+
+// isLvStream returns true when the live view must be streamed instead of being displayed in a window.
+func isLvStream(f []string) bool {
+	_, h := lvArgValue(f, lvHttpArg)
+	_, r := lvArgValue(f, lvRtspArg)
+	_, rec := lvArgValue(f, lvRecArg)
+	_, w := lvArgValue(f, lvWebcamArg)
+
+	return h || r || rec || w
+}
+
+// isLvStop returns true when the liveview should be stopped.
+func isLvStop(f []string) bool {
+	return len(f) >= 1 && f[0] == lvStopArg
+}
+
+// lvFrames hands out a channel receiving the raw JPEG data of each live view frame using the given buffer size and
+// drop policy. The frame rate is limited to fps when it is not 0. The channel is closed when the live view is disabled.
+func lvFrames(c ip.ClientAPI, buffer int, policy ip.DropPolicy, fps float64) <-chan []byte {
+	sub := c.SubscribeLiveview(buffer, policy)
+	sub.SetMaxFPS(fps)
+	ch := make(chan []byte)
+
+	go func() {
+		defer close(ch)
+		for frame := range sub.Frames() {
+			ch <- frame.Data
+		}
+	}()
+
+	return ch
+}
+
+// lvRecorder creates a recorder writing to the given directory using the recording options found in f.
+func lvRecorder(c ip.ClientAPI, dir string, fps float64, f []string) (*streamer.Recorder, error) {
+	rec := streamer.NewRecorder(dir, lvFrames(c, 25, ip.DropOldest, fps))
+
+	if v, ok := lvArgValue(f, lvRecSizeArg); ok {
+		mb, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", lvRecSizeArg, err)
+		}
+		rec.MaxSize = mb * 1024 * 1024
+	}
+
+	if v, ok := lvArgValue(f, lvRecDurationArg); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", lvRecDurationArg, err)
+		}
+		rec.MaxDuration = d
+	}
+
+	if v, ok := lvArgValue(f, lvRecFormatArg); ok {
+		switch v {
+		case "mjpeg":
+		case "mp4":
+			rec.Encoder = streamer.CommandEncoder(".mp4", "ffmpeg", "-loglevel", "error", "-f", "mjpeg", "-i", "-", "-c:v", "libx264", "-pix_fmt", "yuv420p", "{}")
+		default:
+			return nil, fmt.Errorf("invalid value for %s: %s", lvRecFormatArg, v)
+		}
+	}
+
+	return rec, nil
+}
+
+// startLiveviewStream opens the streamer connection and serves the liveview frames as an MJPEG stream over HTTP
+// and/or an RTP/JPEG stream over RTSP and/or records them to disk and/or feeds them to a virtual webcam, depending on the
+// arguments given.
+func startLiveviewStream(c ip.ClientAPI, f []string) string {
+	errorFmt := "liveview error: %s\n"
+
+	httpAddr, withHttp := lvArgValue(f, lvHttpArg)
+	rtspAddr, withRtsp := lvArgValue(f, lvRtspArg)
+	if withHttp && httpAddr == "" {
+		return fmt.Sprintf(errorFmt, "missing address for "+lvHttpArg)
+	}
+	if withRtsp && rtspAddr == "" {
+		return fmt.Sprintf(errorFmt, "missing address for "+lvRtspArg)
+	}
+	recDir, withRec := lvArgValue(f, lvRecArg)
+	if withRec && recDir == "" {
+		return fmt.Sprintf(errorFmt, "missing directory for "+lvRecArg)
+	}
+	webcamDev, withWebcam := lvArgValue(f, lvWebcamArg)
+	if withWebcam && webcamDev == "" {
+		return fmt.Sprintf(errorFmt, "missing device for "+lvWebcamArg)
+	}
+	var fps float64
+	if v, ok := lvArgValue(f, lvFpsArg); ok {
+		var err error
+		if fps, err = strconv.ParseFloat(v, 64); err != nil || fps < 0 {
+			return fmt.Sprintf(errorFmt, "invalid value for "+lvFpsArg+": "+v)
+		}
+	}
+	if lvStreams != nil {
+		return "already enabled!\n"
+	}
+
+	if err := c.ToggleLiveView(true); err != nil {
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	fail := func(err error) string {
+		for _, srv := range lvStreams {
+			srv.Close()
+		}
+		lvStreams = nil
+		c.ToggleLiveView(false)
+
+		return fmt.Sprintf(errorFmt, err)
+	}
+
+	var res string
+	if withHttp {
+		srv := streamer.NewMJPEGServer(httpAddr, lvFrames(c, 1, ip.DropOldest, fps))
+		if err := srv.Listen(); err != nil {
+			return fail(err)
+		}
+		lvStreams = append(lvStreams, srv)
+
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Errorf("MJPEG server stopped: %s", err)
+			}
+		}()
+
+		res += fmt.Sprintf("streaming MJPEG on http://%s/\n", srv.Addr())
+	}
+
+	if withRtsp {
+		srv := streamer.NewRTSPServer(rtspAddr, lvFrames(c, 1, ip.DropOldest, fps))
+		if err := srv.Listen(); err != nil {
+			return fail(err)
+		}
+		lvStreams = append(lvStreams, srv)
+
+		go func() {
+			if err := srv.ListenAndServe(); err != nil {
+				logger.Errorf("RTSP server stopped: %s", err)
+			}
+		}()
+
+		res += fmt.Sprintf("streaming RTP/JPEG on rtsp://%s/\n", srv.Addr())
+	}
+
+	if withRec {
+		rec, err := lvRecorder(c, recDir, fps, f)
+		if err != nil {
+			return fail(err)
+		}
+		lvStreams = append(lvStreams, rec)
+
+		go func() {
+			if err := rec.Record(); err != nil {
+				logger.Errorf("liveview recording stopped: %s", err)
+			}
+		}()
+
+		res += fmt.Sprintf("recording to %s\n", recDir)
+	}
+
+	if withWebcam {
+		sink, err := openWebcam(webcamDev, fps)
+		if err != nil {
+			return fail(err)
+		}
+		cam := newWebcam(sink, lvFrames(c, 1, ip.DropOldest, fps))
+		lvStreams = append(lvStreams, cam)
+
+		go func() {
+			if err := cam.run(); err != nil {
+				logger.Errorf("webcam output stopped: %s", err)
+			}
+		}()
+
+		res += fmt.Sprintf("feeding the %s webcam %s\n", webcamDriver, webcamDev)
+	}
+
+	return res
+}
+
+// stopLiveviewStream stops all streaming servers and closes the streamer connection.
+func stopLiveviewStream(c ip.ClientAPI) string {
+	if lvStreams == nil {
+		return "not enabled!\n"
+	}
+
+	for _, srv := range lvStreams {
+		srv.Close()
+	}
+	lvStreams = nil
+
+	if err := c.ToggleLiveView(false); err != nil {
+		return fmt.Sprintf("liveview error: %s\n", err)
+	}
+
+	return "disabled\n"
+}
+
+// helpLiveviewStreamArg returns the help line for the given streaming argument.
+func helpLiveviewStreamArg(arg string) string {
+	switch arg {
+	case lvHttpArg:
+		return "\t- " + `"` + arg + ` address" serves the live view as an MJPEG stream over HTTP on the given address, e.g. ":8080"` + "\n"
+	case lvRtspArg:
+		return "\t- " + `"` + arg + ` address" serves the live view as an RTP/JPEG stream over RTSP on the given address, e.g. ":8554". Can be combined with ` + lvHttpArg + "\n"
+	case lvRecArg:
+		return "\t- " + `"` + arg + ` directory" records the live view to the given directory. Can be combined with ` + lvHttpArg + ` and ` + lvRtspArg + `. Additional options are:` + "\n" +
+			"\t\t- " + `"` + lvRecSizeArg + ` megabytes" starts a new file when the current one reaches the given size` + "\n" +
+			"\t\t- " + `"` + lvRecDurationArg + ` duration" starts a new file after the given duration, e.g. "5m"` + "\n" +
+			"\t\t- " + `"` + lvRecFormatArg + ` format" sets the file format: "mjpeg" (default) or "mp4" which requires ffmpeg to be installed` + "\n"
+	case lvWebcamArg:
+		return "\t- " + `"` + arg + ` device" feeds the live view to a virtual webcam using ` + webcamDriver + `, e.g. "/dev/video10" for v4l2loopback on Linux or "AkVCamVideoDevice0" for akvirtualcamera on macOS and Windows. Can be combined with the other arguments` + "\n"
+	case lvFpsArg:
+		return "\t- " + `"` + arg + ` rate" limits the number of frames per second that are streamed, recorded or fed to the webcam, e.g. "5"` + "\n"
+	case lvStopArg:
+		return "\t- " + `"` + arg + `" stops streaming or recording the live view` + "\n"
+	}
+
+	return ""
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"github.com/malc0mn/ptp-ip/ip"
+	"log"
+	"os"
+	"sync"
+)
+
+const (
+	logFormatText logFormat = "text"
+	logFormatJSON logFormat = "json"
+)
+
+var (
+	logFormatUnknown = errors.New("unknown log format")
+
+	logFmt = logFormatText
+	// logger is used for the messages of the command itself. Info messages are always output since they report what
+	// the servers are doing, the verbosity only adds debug messages.
+	logger = newReloadableLogger(ip.LevelVeryVerbose)
+	// clientLogger is used by the clients, it only outputs the messages allowed by the verbosity.
+	clientLogger = newReloadableLogger(ip.LevelSilent)
+)
+
+// logFormat is the output format of the log messages: plain text or one JSON object per line.
+type logFormat string
+
+// Set implements the flag.Value interface.
+func (lf *logFormat) Set(s string) error {
+	switch logFormat(s) {
+	case logFormatText, logFormatJSON:
+		*lf = logFormat(s)
+	default:
+		return logFormatUnknown
+	}
+
+	return nil
+}
+
+// String implements the flag.Value interface.
+func (lf *logFormat) String() string {
+	return string(*lf)
+}
+
+// newLogger returns a logger writing to stderr in the configured log format.
+func newLogger(level ip.LogLevel) ip.Logger {
+	if logFmt == logFormatJSON {
+		return ip.NewJSONLogger(level, os.Stderr)
+	}
+
+	return ip.NewLogger(level, os.Stderr, "", log.LstdFlags)
+}
+
+// initLogging sets up the loggers once the flags and the config file have been read, and again each time the config
+// file is reloaded.
+func initLogging() {
+	logger.reset()
+	clientLogger.reset()
+}
+
+// reloadableLogger passes every log entry on to a logger using the current verbosity and log format, so that both can
+// be changed while the clients are connected.
+type reloadableLogger struct {
+	// min is the lowest level the logger outputs at, whatever the verbosity.
+	min ip.LogLevel
+
+	mu  sync.RWMutex
+	cur ip.StructuredLogger
+}
+
+func newReloadableLogger(min ip.LogLevel) *reloadableLogger {
+	rl := &reloadableLogger{min: min}
+	rl.reset()
+
+	return rl
+}
+
+// reset replaces the logger by one using the current verbosity and log format.
+func (rl *reloadableLogger) reset() {
+	level := verbosity
+	if level < rl.min {
+		level = rl.min
+	}
+	l := newLogger(level).(ip.StructuredLogger)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.cur = l
+}
+
+func (rl *reloadableLogger) current() ip.StructuredLogger {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	return rl.cur
+}
+
+func (rl *reloadableLogger) Enabled(s ip.Severity) bool {
+	return rl.current().Enabled(s)
+}
+
+func (rl *reloadableLogger) Log(s ip.Severity, msg string, fields ...ip.Field) {
+	rl.current().Log(s, msg, fields...)
+}
+
+func (rl *reloadableLogger) Debug(v ...interface{}) {
+	rl.current().Debug(v...)
+}
+
+func (rl *reloadableLogger) Debugf(format string, v ...interface{}) {
+	rl.current().Debugf(format, v...)
+}
+
+func (rl *reloadableLogger) Debugln(v ...interface{}) {
+	rl.current().Debugln(v...)
+}
+
+func (rl *reloadableLogger) Error(v ...interface{}) {
+	rl.current().Error(v...)
+}
+
+func (rl *reloadableLogger) Errorf(format string, v ...interface{}) {
+	rl.current().Errorf(format, v...)
+}
+
+func (rl *reloadableLogger) Errorln(v ...interface{}) {
+	rl.current().Errorln(v...)
+}
+
+func (rl *reloadableLogger) Fatal(v ...interface{}) {
+	rl.current().Fatal(v...)
+}
+
+func (rl *reloadableLogger) Fatalf(format string, v ...interface{}) {
+	rl.current().Fatalf(format, v...)
+}
+
+func (rl *reloadableLogger) Fatalln(v ...interface{}) {
+	rl.current().Fatalln(v...)
+}
+
+func (rl *reloadableLogger) Info(v ...interface{}) {
+	rl.current().Info(v...)
+}
+
+func (rl *reloadableLogger) Infof(format string, v ...interface{}) {
+	rl.current().Infof(format, v...)
+}
+
+func (rl *reloadableLogger) Infoln(v ...interface{}) {
+	rl.current().Infoln(v...)
+}
+
+func (rl *reloadableLogger) Warn(v ...interface{}) {
+	rl.current().Warn(v...)
+}
+
+func (rl *reloadableLogger) Warnf(format string, v ...interface{}) {
+	rl.current().Warnf(format, v...)
+}
+
+func (rl *reloadableLogger) Warnln(v ...interface{}) {
+	rl.current().Warnln(v...)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"testing"
+)
+
+func TestLogFormat_Set(t *testing.T) {
+	var lf logFormat
+	if err := lf.Set("json"); err != nil || lf != logFormatJSON {
+		t.Errorf("Set() got = %s, %v; want %s, <nil>", lf, err, logFormatJSON)
+	}
+	if err := lf.Set("xml"); err != logFormatUnknown {
+		t.Errorf("Set() error = %v; want %s", err, logFormatUnknown)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	defer func() { logFmt = logFormatText }()
+
+	if _, ok := newLogger(ip.LevelVerbose).(*ip.StdLogger); !ok {
+		t.Errorf("newLogger() text got = %T; want *ip.StdLogger", newLogger(ip.LevelVerbose))
+	}
+
+	logFmt = logFormatJSON
+	if _, ok := newLogger(ip.LevelVerbose).(*ip.JSONLogger); !ok {
+		t.Errorf("newLogger() json got = %T; want *ip.JSONLogger", newLogger(ip.LevelVerbose))
+	}
+}
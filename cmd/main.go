@@ -2,12 +2,14 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/malc0mn/ptp-ip/ip"
 )
@@ -19,6 +21,18 @@ const (
 	errOpenConfig       = 102
 	errCreateClient     = 104
 	errResponderConnect = 105
+	errScript           = 106
+	errCapture          = 107
+	errServer           = 108
+	errPidFile          = 109
+	// The exit codes below are used for the failure classes recognised in the error returned, overriding the exit code
+	// of the action that failed.
+	errResponderRejected = 110
+	errDeviceBusy        = 111
+	errTimeout           = 112
+	errNotSupported      = 113
+	errConnectionLost    = 114
+	errNoAnswer          = 115
 )
 
 var (
@@ -43,19 +57,44 @@ func main() {
 	}
 
 	if showVersion {
-		// fmt.Printf("%s version %s built on %s\n", exe, version, buildTime)
+		printVersion(os.Stdout)
+		os.Exit(ok)
+	}
+
+	if showCapabilities {
+		printCapabilities(os.Stdout)
 		os.Exit(ok)
 	}
 
 	if file != "" {
 		loadConfig()
 	}
+	initLogging()
 
 	checkPorts()
 
-	if cmd != "" && (interactive || server) || (interactive && server) {
-		fmt.Fprintln(os.Stderr, "Too many arguments: either run in server mode OR interactive mode OR execute a single command; not all at once!")
-		os.Exit(errInvalidArgs)
+	cams, err := selectCameras(cameras)
+	if err != nil {
+		fail(nil, errInvalidArgs, "selecting camera", err)
+	}
+	if len(cams) == 1 {
+		cams[0].use()
+	}
+
+	if showConfig {
+		dumpConfig(os.Stdout, configFormat(file))
+		os.Exit(ok)
+	}
+
+	if (cmd != "" || script != "") && (interactive || server) || (interactive && server) || (cmd != "" && script != "") {
+		fail(nil, errInvalidArgs, "Too many arguments: either run in server mode OR interactive mode OR execute a single command OR a script; not all at once!", nil)
+	}
+
+	if len(cams) > 1 && !server {
+		fail(nil, errInvalidArgs, "Several cameras can only be loaded in server mode!", nil)
+	}
+	if len(cams) <= 1 {
+		cams = []*camera{defaultCamera()}
 	}
 
 	// TODO: finish this implementation so CTRL+C will also abort client.Dial() etc. properly.
@@ -63,46 +102,84 @@ func main() {
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigs
-		log.Printf("Received signal %s, shutting down...\n", sig)
+		logger.Infof("Received signal %s, shutting down...", sig)
 		close(quit)
+
+		// Give up on a clean shutdown when asked a second time.
+		sig = <-sigs
+		logger.Infof("Received signal %s again, exiting immediately", sig)
+		os.Exit(errGeneral)
 	}()
 
-	client, err := ip.NewClient(conf.vendor, conf.host, uint16(conf.port), conf.fname, conf.guid, verbosity)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating PTP/IP client - %s\n", err)
-		os.Exit(errCreateClient)
+	if cmd != "" && isStandalone(cmd) {
+		if err := executeCommand(cmd, bufio.NewWriter(os.Stdout), nil, "cli"); err != nil {
+			commandFailed(nil, err)
+		}
+		os.Exit(ok)
 	}
-	defer client.Close()
 
-	if conf.cport != 0 {
-		client.SetCommandDataPort(uint16(conf.cport))
-	}
-	if conf.eport != 0 {
-		client.SetEventPort(uint16(conf.eport))
+	// From here on, exiting must be done using d.exit() so the camera connections are closed properly.
+	d := newDaemon(conf.pidFile)
+	if server {
+		if err := d.writePidFile(); err != nil {
+			fail(nil, errPidFile, "writing PID file", err)
+		}
 	}
-	if conf.sport != 0 {
-		client.SetStreamerPort(uint16(conf.sport))
+
+	var connected atomic.Bool
+	if cmd != "" && cmdDeadline > 0 {
+		time.AfterFunc(cmdDeadline, func() { deadlineExceeded(d, connected.Load()) })
 	}
 
-	// fmt.Printf("Created new client with name '%s' and GUID '%s'.\n", client.InitiatorFriendlyName(), client.InitiatorGUIDAsString())
-	// fmt.Printf("Attempting to connect to %s\n", client.CommandDataAddress())
-	err = client.Dial()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error connecting to responder - %s\n", err)
-		os.Exit(errResponderConnect)
+	clients := make([]*ip.Client, len(cams))
+	for i, cam := range cams {
+		if err := joinCameraNetwork(cam); err != nil {
+			fail(d, errResponderConnect, "joining the WiFi network of the responder", err)
+		}
+		client, err := cam.newClient()
+		if err != nil {
+			fail(d, errCreateClient, "creating PTP/IP client", err)
+		}
+		d.addClient(client)
+
+		// fmt.Printf("Created new client with name '%s' and GUID '%s'.\n", client.InitiatorFriendlyName(), client.InitiatorGUIDAsString())
+		// fmt.Printf("Attempting to connect to %s\n", client.CommandDataAddress())
+		if err := connectCamera(cam, client); err != nil {
+			fail(d, errResponderConnect, "connecting to responder", err)
+		}
+		clients[i] = client
+		// Keep track of the camera state for the XMP sidecars of the images captured while connected.
+		recordCaptureStates(client)
 	}
+	client := clients[0]
+	connected.Store(true)
 
 	if cmd != "" {
-		executeCommand(cmd, bufio.NewWriter(os.Stdout), client, "cli")
+		if err := executeCommand(cmd, bufio.NewWriter(os.Stdout), client, "cli"); err != nil {
+			commandFailed(d, err)
+		}
+	}
+
+	if script != "" {
+		if err := runScript(script, flag.Args(), bufio.NewWriter(os.Stdout), client); err != nil {
+			fail(d, errScript, "executing script", err)
+		}
 	}
 
 	if server || interactive {
+		// All clients of the servers of a camera and the interactive shell share its PTP/IP session.
+		queues := make([]*jobQueue, len(clients))
+		for i := range queues {
+			queues[i] = newJobQueue(conf.cmdTimeout)
+		}
+
 		if interactive {
-			go iShell(client)
+			go iShell(client, queues[0])
 		}
 
 		if server {
-			go launchServer(client)
+			startServers(d, cams, clients, queues)
+			go handleReload(queues)
 		}
 
 		mainThread()
@@ -111,5 +188,75 @@ func main() {
 		fmt.Println("Bye bye!")
 	}
 
-	os.Exit(ok)
+	d.exit(ok)
+}
+
+// startServers starts the local server of each camera, and the web and gRPC servers when enabled, using the sockets
+// passed by systemd when present. The servers of a camera use the job queue of the camera.
+func startServers(d *daemon, cams []*camera, clients []*ip.Client, queues []*jobQueue) {
+	activated, err := systemdListeners()
+	if err != nil {
+		fail(d, errServer, "using socket activation", err)
+	}
+
+	for i, cam := range cams {
+		q := queues[i]
+
+		l, err := d.listen(activated, listenerName("server", cam), cam.srvPort)
+		if err != nil {
+			fail(d, errServer, "starting server", err)
+		}
+		go launchServer(clients[i], q, l, &d.commands)
+
+		name := listenerName("web", cam)
+		if _, ok := activated[name]; ok || cam.webPort != 0 {
+			l, err := d.listen(activated, name, cam.webPort)
+			if err != nil {
+				fail(d, errServer, "starting web server", err)
+			}
+			go launchWebServer(clients[i], q, l, d)
+			if canProbe(clients[i]) {
+				go d.probe(clients[i])
+			}
+		}
+
+		name = listenerName("grpc", cam)
+		if _, ok := activated[name]; ok || cam.grpcPort != 0 {
+			l, err := d.listen(activated, name, cam.grpcPort)
+			if err != nil {
+				fail(d, errServer, "starting gRPC server", err)
+			}
+			go launchGrpcServer(clients[i], q, l, d)
+		}
+	}
+
+	sdNotify("READY=1")
+}
+
+// deadlineExceeded exits when the deadline set using the -deadline flag has passed, using the exit code telling
+// whether the responder never answered or the command did not finish in time.
+func deadlineExceeded(d *daemon, connected bool) {
+	err := fmt.Errorf("deadline of %s exceeded", cmdDeadline)
+	if !connected {
+		fail(d, errResponderConnect, "connecting to responder", &noAnswerError{attempts: 1, err: err})
+	}
+	fail(d, errTimeout, "executing command", err)
+}
+
+// commandExitCode returns the exit code for the error reported by a command executed using the -c flag.
+func commandExitCode(err error) int {
+	return exitCode(err, errGeneral)
+}
+
+// commandFailed exits using the exit code for the error reported by a command executed using the -c flag. The command
+// has already written the error to its output, so the error is only reported on stderr when using -json-errors.
+func commandFailed(d *daemon, err error) {
+	if jsonErrors {
+		printError(os.Stderr, newCliError(errGeneral, "executing command", err))
+	}
+
+	if d != nil {
+		d.exit(commandExitCode(err))
+	}
+	os.Exit(commandExitCode(err))
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// mockClient replaces the camera in command tests. Only the methods used by the tested commands are implemented, any
+// other method panics since the embedded ClientAPI is nil.
+type mockClient struct {
+	ip.ClientAPI
+	vendor ptp.VendorExtension
+	// responses maps operation codes to the raw packet returned by OperationRequestRaw and SendData, or the data
+	// returned by OperationRequestData.
+	responses map[ptp.OperationCode][]byte
+	// requests records the parameters of each operation request.
+	requests map[ptp.OperationCode][]uint32
+	// sent records the data sent by SendData for each operation.
+	sent map[ptp.OperationCode][]byte
+	// raw records the packets sent by SendRawPacket, prefixed with the connection, e.g. "cmd:".
+	raw [][]byte
+	// rawResponses holds the packets returned by SendRawPacket.
+	rawResponses [][]byte
+	// props maps device property codes to the value returned by GetDevicePropertyValue.
+	props map[ptp.DevicePropCode]uint32
+	// descs maps device property codes to the description returned by GetDevicePropertyDescription.
+	descs map[ptp.DevicePropCode]*ptp.DevicePropDesc
+}
+
+func newMockClient(vendor ptp.VendorExtension) *mockClient {
+	return &mockClient{
+		vendor:    vendor,
+		responses: make(map[ptp.OperationCode][]byte),
+		requests:  make(map[ptp.OperationCode][]uint32),
+		sent:      make(map[ptp.OperationCode][]byte),
+		props:     make(map[ptp.DevicePropCode]uint32),
+		descs:     make(map[ptp.DevicePropCode]*ptp.DevicePropDesc),
+	}
+}
+
+func (mc *mockClient) Debugf(_ string, _ ...interface{}) {}
+
+func (mc *mockClient) ResponderVendor() ptp.VendorExtension {
+	return mc.vendor
+}
+
+func (mc *mockClient) OperationRequestRaw(code ptp.OperationCode, params []uint32) ([]byte, error) {
+	mc.requests[code] = params
+	if res, ok := mc.responses[code]; ok {
+		return res, nil
+	}
+
+	return nil, ptp.ResponseCodeAsError(ptp.RC_OperationNotSupported)
+}
+
+func (mc *mockClient) OperationRequestData(code ptp.OperationCode, params []uint32) ([]byte, error) {
+	mc.requests[code] = params
+	if data, ok := mc.responses[code]; ok {
+		return data, nil
+	}
+
+	return nil, ptp.ResponseCodeAsError(ptp.RC_OperationNotSupported)
+}
+
+func (mc *mockClient) SendData(code ptp.OperationCode, params []uint32, data []byte, _ uint64) ([]byte, error) {
+	mc.requests[code] = params
+	mc.sent[code] = data
+	if res, ok := mc.responses[code]; ok {
+		return res, nil
+	}
+
+	return nil, ptp.ResponseCodeAsError(ptp.RC_OperationNotSupported)
+}
+
+func (mc *mockClient) SendRawPacket(_ context.Context, conn string, data []byte) ([][]byte, error) {
+	mc.raw = append(mc.raw, append([]byte(conn+":"), data...))
+	if conn != "cmd" && conn != "event" {
+		return nil, fmt.Errorf("unknown connection %q, must be cmd or event", conn)
+	}
+
+	return mc.rawResponses, nil
+}
+
+func (mc *mockClient) DumpRawPacket(raw []byte) string {
+	return ip.GenericDumpRawPacket(raw)
+}
+
+func (mc *mockClient) SetObjectProtection(h ptp.ObjectHandle, status ptp.ProtectionStatus) error {
+	mc.requests[ptp.OC_SetObjectProtection] = []uint32{uint32(h), uint32(status)}
+
+	return nil
+}
+
+func (mc *mockClient) SetObjectRating(h ptp.ObjectHandle, rating int) error {
+	mc.requests[ip.OC_MTP_SetObjectPropValue] = []uint32{uint32(h), uint32(rating)}
+
+	return nil
+}
+
+func (mc *mockClient) GetDevicePropertyValue(code ptp.DevicePropCode) (uint32, error) {
+	if v, ok := mc.props[code]; ok {
+		return v, nil
+	}
+
+	return 0, ptp.ResponseCodeAsError(ptp.RC_DevicePropNotSupported)
+}
+
+func (mc *mockClient) CachedDevicePropertyDescription(_ ptp.DevicePropCode) *ptp.DevicePropDesc {
+	return nil
+}
+
+func (mc *mockClient) GetDevicePropertyDescription(code ptp.DevicePropCode) (*ptp.DevicePropDesc, error) {
+	if dpd, ok := mc.descs[code]; ok {
+		return dpd, nil
+	}
+
+	return nil, ptp.ResponseCodeAsError(ptp.RC_DevicePropNotSupported)
+}
+
+func (mc *mockClient) SendObject(sid ptp.StorageID, parent ptp.ObjectHandle, oi *ptp.ObjectInfo, data []byte) (ptp.ObjectHandle, error) {
+	mc.requests[ptp.OC_SendObjectInfo] = []uint32{uint32(sid), uint32(parent), uint32(oi.ObjectFormat), oi.ObjectCompressedSize}
+	mc.requests[ptp.OC_SendObject] = []uint32{uint32(len(data))}
+
+	return 0x42, nil
+}
@@ -0,0 +1,11 @@
+// +build !with_ble
+
+package main
+
+import "errors"
+
+var errNoBle = errors.New("binary not compiled with Bluetooth LE support")
+
+func bleWake(_ string, _ []bleWrite) error {
+	return errNoBle
+}
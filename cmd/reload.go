@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-ini/ini"
+	"github.com/malc0mn/ptp-ip/ip"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reloadableSettings holds the settings of the config file that can be changed without reconnecting to the cameras:
+// the macros, the download hooks, the transcoders, the log level and format and the command timeout.
+type reloadableSettings struct {
+	macros      map[string]string
+	hooks       map[string]string
+	transcoders map[string]string
+	level       ip.LogLevel
+	format      logFormat
+	cmdTimeout  time.Duration
+}
+
+// readReloadableSettings reads the reloadable settings from the config file. Settings missing from the file keep their
+// current value, except for the macros, the download hooks and the transcoders defined in the file: these are replaced
+// as a whole.
+func readReloadableSettings(f *ini.File) (*reloadableSettings, error) {
+	rs := &reloadableSettings{
+		macros:      make(map[string]string),
+		hooks:       make(map[string]string),
+		transcoders: make(map[string]string),
+		level:       verbosity,
+		format:      logFmt,
+		cmdTimeout:  conf.cmdTimeout,
+	}
+
+	if i, err := f.GetSection("macros"); err == nil {
+		for _, k := range i.Keys() {
+			if err := checkMacro(k.Name(), k.String()); err != nil {
+				return nil, err
+			}
+			rs.macros[k.Name()] = k.String()
+		}
+	}
+
+	if i, err := f.GetSection("download_hooks"); err == nil {
+		for _, k := range i.Keys() {
+			if err := checkExecHook(k.Name(), k.String()); err != nil {
+				return nil, err
+			}
+			rs.hooks[k.Name()] = k.String()
+		}
+	}
+
+	if i, err := f.GetSection("transcoders"); err == nil {
+		for _, k := range i.Keys() {
+			if err := checkExecTranscoder(k.Name(), k.String()); err != nil {
+				return nil, err
+			}
+			rs.transcoders[k.Name()] = k.String()
+		}
+	}
+
+	if i, err := f.GetSection("logging"); err == nil {
+		if k, err := i.GetKey("level"); err == nil {
+			if err := rs.level.Set(k.String()); err != nil && k.String() != "" {
+				return nil, err
+			}
+		}
+		if k, err := i.GetKey("format"); err == nil && k.String() != "" {
+			if err := rs.format.Set(k.String()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if i, err := f.GetSection("server"); err == nil {
+		if k, err := i.GetKey("command_timeout"); err == nil {
+			v, err := k.Duration()
+			if err != nil {
+				return nil, fmt.Errorf("invalid command_timeout: %w", err)
+			}
+			rs.cmdTimeout = v
+		}
+	}
+
+	return rs, nil
+}
+
+// apply makes the settings the current ones. The loggers and the job queues pick them up in reloadConfig.
+func (rs *reloadableSettings) apply() {
+	setConfigMacros(rs.macros)
+	setExecHooks(rs.hooks)
+	setExecTranscoders(rs.transcoders)
+	verbosity = rs.level
+	logFmt = rs.format
+	conf.cmdTimeout = rs.cmdTimeout
+}
+
+// reloadConfig reads the config file again and applies the reloadable settings, leaving the PTP/IP sessions alone. The
+// file is checked as a whole before applying anything, so an invalid file leaves the running config untouched.
+func reloadConfig(queues []*jobQueue) error {
+	if file == "" {
+		return fmt.Errorf("no config file given, use the -f flag")
+	}
+
+	f, err := readConfigFile(file)
+	if err != nil {
+		return err
+	}
+	rs, err := readReloadableSettings(f)
+	if err != nil {
+		return err
+	}
+
+	rs.apply()
+	initLogging()
+	for _, q := range queues {
+		q.setTimeout(conf.cmdTimeout)
+	}
+
+	return nil
+}
+
+// handleReload reloads the config file each time the process receives a SIGHUP, see reloadConfig.
+func handleReload(queues []*jobQueue) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+
+	for sig := range sigs {
+		logger.Infof("[Daemon] Received signal %s, reloading config file %s", sig, file)
+		sdNotify("RELOADING=1")
+		if err := reloadConfig(queues); err != nil {
+			logger.Errorf("[Daemon] error reloading config file, keeping the current config: %s", err)
+		} else {
+			logger.Info("[Daemon] config file reloaded")
+		}
+		sdNotify("READY=1")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	oldFile, oldVerbosity, oldTimeout := file, verbosity, conf.cmdTimeout
+	defer func() {
+		file, verbosity, conf.cmdTimeout = oldFile, oldVerbosity, oldTimeout
+		setConfigMacros(nil)
+		setExecHooks(nil)
+		setExecTranscoders(nil)
+		initLogging()
+	}()
+
+	file = filepath.Join(t.TempDir(), "reload.conf")
+	write := func(s string) {
+		if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := newJobQueue(time.Minute)
+
+	write("[macros]\nreload-test = help\n[download_hooks]\nreload-test = true\n[transcoders]\nreload-test = cat\n[logging]\nlevel = vv\n[server]\ncommand_timeout = 5s\n")
+	if err := reloadConfig([]*jobQueue{q}); err != nil {
+		t.Fatalf("reloadConfig() err = %s; want nil", err)
+	}
+	if _, ok := macroByName("reload-test"); !ok {
+		t.Error("reloadConfig() macro not defined")
+	}
+	if execHooks["reload-test"] != "true" {
+		t.Errorf("reloadConfig() exec hook = %q; want true", execHooks["reload-test"])
+	}
+	if execTranscoders["reload-test"] != "cat" {
+		t.Errorf("reloadConfig() exec transcoder = %q; want cat", execTranscoders["reload-test"])
+	}
+	if verbosity != ip.LevelVeryVerbose || !clientLogger.Enabled(ip.SeverityInfo) || clientLogger.Enabled(ip.SeverityDebug) {
+		t.Errorf("reloadConfig() verbosity = %d; want %d", verbosity, ip.LevelVeryVerbose)
+	}
+	if got := q.currentTimeout(); got != 5*time.Second {
+		t.Errorf("reloadConfig() timeout = %s; want 5s", got)
+	}
+
+	// Macros defined using the macro command survive a reload, those removed from the file do not.
+	if err := defineMacro("reload-keep", "help"); err != nil {
+		t.Fatal(err)
+	}
+	defer deleteMacro("reload-keep")
+	write("[logging]\nlevel = vvv\n")
+	if err := reloadConfig([]*jobQueue{q}); err != nil {
+		t.Fatalf("reloadConfig() err = %s; want nil", err)
+	}
+	if _, ok := macroByName("reload-test"); ok {
+		t.Error("reloadConfig() macro removed from the config file still defined")
+	}
+	if _, ok := macroByName("reload-keep"); !ok {
+		t.Error("reloadConfig() macro defined using the macro command deleted")
+	}
+	if _, ok := execHooks["reload-test"]; ok {
+		t.Error("reloadConfig() exec hook removed from the config file still defined")
+	}
+	if _, ok := transcoders["reload-test"]; ok {
+		t.Error("reloadConfig() exec transcoder removed from the config file still defined")
+	}
+	if !clientLogger.Enabled(ip.SeverityDebug) {
+		t.Error("reloadConfig() client logger does not output debug messages at level vvv")
+	}
+	if got := q.currentTimeout(); got != 5*time.Second {
+		t.Errorf("reloadConfig() timeout = %s; want 5s kept", got)
+	}
+
+	// An invalid file changes nothing.
+	write("[macros]\nreload-test = help\n[server]\ncommand_timeout = soon\n")
+	if err := reloadConfig([]*jobQueue{q}); err == nil {
+		t.Error("reloadConfig() err = nil; want invalid command_timeout error")
+	}
+	if _, ok := macroByName("reload-test"); ok {
+		t.Error("reloadConfig() invalid config file partially applied")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// lvReview holds the time.Duration the captured image is shown in the live view window after each capture, before
+// returning to the live view. Zero disables the review.
+var lvReview int64
+
+// reviewDuration returns how long a captured image is shown in the live view window.
+func reviewDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&lvReview))
+}
+
+// setReviewDuration sets how long a captured image is shown in the live view window from a duration such as 2s, or
+// off to disable the review.
+func setReviewDuration(s string) error {
+	if s == "off" {
+		atomic.StoreInt64(&lvReview, 0)
+		return nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid review duration %s, expected e.g. 2s or off", s)
+	}
+	atomic.StoreInt64(&lvReview, int64(d))
+
+	return nil
+}
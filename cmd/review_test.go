@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetReviewDuration(t *testing.T) {
+	defer setReviewDuration("off")
+
+	if err := setReviewDuration("2.5s"); err != nil || reviewDuration() != 2500*time.Millisecond {
+		t.Errorf("setReviewDuration() got = %s, %v; want 2.5s, <nil>", reviewDuration(), err)
+	}
+	if err := setReviewDuration("off"); err != nil || reviewDuration() != 0 {
+		t.Errorf("setReviewDuration() got = %s, %v; want 0s, <nil>", reviewDuration(), err)
+	}
+	for _, s := range []string{"2", "-1s", "soon"} {
+		if err := setReviewDuration(s); err == nil {
+			t.Errorf("setReviewDuration(%s) error = <nil>; want error", s)
+		}
+	}
+}
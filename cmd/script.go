@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scriptMaxDepth limits the number of nested source commands and macros, protecting against scripts sourcing
+// themselves.
+const scriptMaxDepth = 10
+
+var (
+	errScriptAborted = errors.New("script aborted")
+	errScriptTooDeep = fmt.Errorf("scripts nested more than %d levels deep", scriptMaxDepth)
+	scriptCompares   = []string{"==", "!=", "<=", ">=", "<", ">", "contains"}
+)
+
+// scriptClient is the client passed to the commands executed by a script. It tells the source commands and macros
+// executed by the script how deeply they are nested, so that each invocation of a script is limited on its own.
+type scriptClient struct {
+	ip.ClientAPI
+	depth int
+}
+
+// unwrapScriptClient returns the client the script was executed with.
+func unwrapScriptClient(c ip.ClientAPI) ip.ClientAPI {
+	if sc, ok := c.(*scriptClient); ok {
+		return sc.ClientAPI
+	}
+
+	return c
+}
+
+// scriptUnterminatedError is returned when parsing a script in which a block is not closed, which the interactive shell
+// uses to read the remainder of the block.
+type scriptUnterminatedError struct {
+	line    int
+	keyword string
+	end     string
+}
+
+func (e *scriptUnterminatedError) Error() string {
+	return fmt.Sprintf("line %d: %s without %s", e.line, e.keyword, e.end)
+}
+
+// scriptCommandError is returned when a shell command executed by a script fails. The output of the command already
+// holds the error.
+type scriptCommandError struct {
+	err error
+}
+
+func (e *scriptCommandError) Error() string {
+	return e.err.Error()
+}
+
+func (e *scriptCommandError) Unwrap() error {
+	return e.err
+}
+
+// scriptStmt is a single statement of a script. Depending on the keyword, the other fields are set:
+//   - let: name and args holding the value
+//   - sleep, echo: args
+//   - if: args holding the condition, then and otherwise holding the statements to execute
+//   - repeat: args holding the count, name holding the optional loop variable and then holding the statements to repeat
+//   - anything else is a shell command: args holding the complete command line
+type scriptStmt struct {
+	line      int
+	keyword   string
+	name      string
+	args      string
+	then      []scriptStmt
+	otherwise []scriptStmt
+}
+
+// parseScript turns the script into a list of statements. Empty lines and lines starting with # are ignored.
+func parseScript(b []byte) ([]scriptStmt, error) {
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	stmts, n, end, err := parseScriptBlock(lines, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch end {
+	case "":
+	case "}":
+		return nil, fmt.Errorf("line %d: } without repeat", n)
+	default:
+		return nil, fmt.Errorf("line %d: %s without if", n, end)
+	}
+
+	return stmts, nil
+}
+
+// parseScriptBlock parses the lines starting at index i until the end of the script or until an else, end or } keyword
+// is encountered. It returns the statements, the index of the line following the block and the keyword ending the block.
+func parseScriptBlock(lines []string, i int) ([]scriptStmt, int, string, error) {
+	var stmts []scriptStmt
+	for i < len(lines) {
+		line := strings.TrimSpace(lines[i])
+		i++
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		keyword, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+		st := scriptStmt{line: i, keyword: keyword, args: args}
+
+		switch keyword {
+		case "else", "end", "}":
+			if args != "" {
+				return nil, i, "", fmt.Errorf("line %d: unexpected %s after %s", i, args, keyword)
+			}
+			return stmts, i, keyword, nil
+		case "let":
+			name, val, found := strings.Cut(args, "=")
+			st.name, st.args = strings.TrimSpace(name), strings.TrimSpace(val)
+			if !found || st.name == "" || strings.ContainsAny(st.name, " \t$") {
+				return nil, i, "", fmt.Errorf("line %d: expected let name = value", i)
+			}
+		case "sleep":
+			if args == "" {
+				return nil, i, "", fmt.Errorf("line %d: missing duration", i)
+			}
+		case "echo":
+		case "if":
+			if args == "" {
+				return nil, i, "", fmt.Errorf("line %d: missing condition", i)
+			}
+			var (
+				end string
+				err error
+			)
+			if st.then, i, end, err = parseScriptBlock(lines, i); err != nil {
+				return nil, i, "", err
+			}
+			if end == "else" {
+				if st.otherwise, i, end, err = parseScriptBlock(lines, i); err != nil {
+					return nil, i, "", err
+				}
+			}
+			if end != "end" {
+				return nil, i, "", &scriptUnterminatedError{line: st.line, keyword: "if", end: "end"}
+			}
+		case "repeat":
+			count, open := strings.CutSuffix(args, "{")
+			count = strings.TrimSpace(count)
+			// A trailing space makes sure a missing name is noticed.
+			if c, name, found := strings.Cut(count+" ", " as "); found {
+				count, st.name = strings.TrimSpace(c), strings.TrimSpace(name)
+				if st.name == "" || strings.ContainsAny(st.name, " \t$") {
+					return nil, i, "", fmt.Errorf("line %d: expected repeat count as name {", i)
+				}
+			}
+			if !open || count == "" {
+				return nil, i, "", fmt.Errorf("line %d: expected repeat count {", i)
+			}
+			st.args = count
+			var (
+				end string
+				err error
+			)
+			if st.then, i, end, err = parseScriptBlock(lines, i); err != nil {
+				return nil, i, "", err
+			}
+			if end != "}" {
+				return nil, i, "", &scriptUnterminatedError{line: st.line, keyword: "repeat", end: "}"}
+			}
+		default:
+			st.keyword, st.args = "", line
+		}
+
+		stmts = append(stmts, st)
+	}
+
+	return stmts, i, "", nil
+}
+
+// scriptRunner holds the state of a running script.
+type scriptRunner struct {
+	c    ip.ClientAPI
+	w    *bufio.Writer
+	vars map[string]string
+	// execute executes a shell command, writing its output to w. The error returned is the failure of the command, see
+	// failingCommand, which stops the script.
+	execute func(cmd string, w *bufio.Writer) error
+}
+
+func newScriptRunner(c ip.ClientAPI, w *bufio.Writer) *scriptRunner {
+	return &scriptRunner{
+		c:    c,
+		w:    w,
+		vars: make(map[string]string),
+		execute: func(cmd string, w *bufio.Writer) error {
+			return executeCommand(cmd, w, c, "[Script]")
+		},
+	}
+}
+
+// runScript executes the script found at path, writing all output to w. The arguments are available to the script
+// as the variables $1, $2 and so on.
+func runScript(path string, args []string, w *bufio.Writer, c ip.ClientAPI) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return runScriptSource(path, b, args, w, c)
+}
+
+// runScriptSource executes the given script, name being used to prefix errors. The script stops at the first command
+// that fails.
+func runScriptSource(name string, b []byte, args []string, w *bufio.Writer, c ip.ClientAPI) error {
+	depth := 1
+	if sc, ok := c.(*scriptClient); ok {
+		depth = sc.depth + 1
+	}
+	if depth > scriptMaxDepth {
+		return errScriptTooDeep
+	}
+	c = &scriptClient{ClientAPI: unwrapScriptClient(c), depth: depth}
+
+	stmts, err := parseScript(b)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+
+	s := newScriptRunner(c, w)
+	for i, a := range args {
+		s.vars[strconv.Itoa(i+1)] = a
+	}
+
+	if err := s.run(stmts); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *scriptRunner) run(stmts []scriptStmt) error {
+	for _, st := range stmts {
+		select {
+		case <-quit:
+			return errScriptAborted
+		default:
+		}
+
+		if err := s.exec(st); err != nil {
+			return fmt.Errorf("line %d: %w", st.line, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *scriptRunner) exec(st scriptStmt) error {
+	switch st.keyword {
+	case "let":
+		if strings.HasPrefix(st.args, "$(") && !strings.HasPrefix(st.args, "$((") && strings.HasSuffix(st.args, ")") {
+			cmd, err := s.expand(st.args[2 : len(st.args)-1])
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(cmd) == "" {
+				return errors.New("empty command")
+			}
+			out, err := s.output(cmd)
+			if err != nil {
+				return &scriptCommandError{err: err}
+			}
+			s.vars[st.name] = strings.TrimSpace(out)
+			return nil
+		}
+		v, err := s.expand(st.args)
+		if err != nil {
+			return err
+		}
+		s.vars[st.name] = unquoteScriptValue(v)
+	case "sleep":
+		v, err := s.expand(st.args)
+		if err != nil {
+			return err
+		}
+		return scriptSleep(v)
+	case "echo":
+		v, err := s.expand(st.args)
+		if err != nil {
+			return err
+		}
+		s.w.WriteString(v + "\n")
+		return s.w.Flush()
+	case "if":
+		ok, err := s.condition(st.args)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return s.run(st.then)
+		}
+		return s.run(st.otherwise)
+	case "repeat":
+		v, err := s.expand(st.args)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(unquoteScriptValue(v))
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid repeat count %s", v)
+		}
+		for i := 1; i <= n; i++ {
+			if st.name != "" {
+				s.vars[st.name] = strconv.Itoa(i)
+			}
+			if err := s.run(st.then); err != nil {
+				return err
+			}
+		}
+	default:
+		cmd, err := s.expand(st.args)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(cmd) == "" {
+			return errors.New("empty command")
+		}
+		if err := s.execute(cmd, s.w); err != nil {
+			return &scriptCommandError{err: err}
+		}
+	}
+
+	return nil
+}
+
+// output executes a shell command and returns its output rather than writing it.
+func (s *scriptRunner) output(cmd string) (string, error) {
+	var b bytes.Buffer
+	err := s.execute(cmd, bufio.NewWriter(&b))
+
+	return b.String(), err
+}
+
+// expand replaces all $name and ${name} occurrences with the value of the variable and all $((expression))
+// occurrences with the result of the expression, see evalArithmetic. Use $$ for a literal $. Using an undefined
+// variable is an error.
+func (s *scriptRunner) expand(in string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(in); i++ {
+		if in[i] != '$' || i == len(in)-1 {
+			out.WriteByte(in[i])
+			continue
+		}
+
+		i++
+		if in[i] == '$' {
+			out.WriteByte('$')
+			continue
+		}
+
+		if strings.HasPrefix(in[i:], "((") {
+			end := arithmeticEnd(in[i:])
+			if end == -1 {
+				return "", errors.New("unterminated $((")
+			}
+			expr, err := s.expand(in[i+2 : i+end-2])
+			if err != nil {
+				return "", err
+			}
+			v, err := evalArithmetic(expr, s.vars)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(formatArithmetic(v))
+			i += end - 1
+			continue
+		}
+
+		var name string
+		if in[i] == '{' {
+			end := strings.IndexByte(in[i:], '}')
+			if end == -1 {
+				return "", errors.New("unterminated ${")
+			}
+			name = in[i+1 : i+end]
+			i += end
+		} else {
+			start := i
+			for i < len(in) && (in[i] == '_' || in[i] >= 'a' && in[i] <= 'z' || in[i] >= 'A' && in[i] <= 'Z' || in[i] >= '0' && in[i] <= '9') {
+				i++
+			}
+			name = in[start:i]
+			i--
+		}
+		if name == "" {
+			out.WriteByte('$')
+			continue
+		}
+
+		v, ok := s.vars[name]
+		if _, err := strconv.Atoi(name); !ok && err != nil {
+			// Arguments that were not passed to the script are simply empty.
+			return "", fmt.Errorf("undefined variable %s", name)
+		}
+		out.WriteString(v)
+	}
+
+	return out.String(), nil
+}
+
+// arithmeticEnd returns the length of the ((expression)) the input starts with, including the parentheses, or -1 when
+// the parentheses are not balanced.
+func arithmeticEnd(in string) int {
+	depth := 0
+	for i := 0; i < len(in); i++ {
+		switch in[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				if i < 1 || in[i-1] != ')' {
+					return -1
+				}
+				return i + 1
+			}
+		}
+	}
+
+	return -1
+}
+
+// condition evaluates "a op b" where op is one of scriptCompares, or a single value which is true unless it is empty,
+// "0" or "false". Values are compared as numbers when both of them are numbers.
+func (s *scriptRunner) condition(cond string) (bool, error) {
+	cond, err := s.expand(cond)
+	if err != nil {
+		return false, err
+	}
+
+	for _, op := range scriptCompares {
+		a, b, found := strings.Cut(cond, " "+op+" ")
+		if !found {
+			continue
+		}
+		a, b = unquoteScriptValue(strings.TrimSpace(a)), unquoteScriptValue(strings.TrimSpace(b))
+
+		if op == "contains" {
+			return strings.Contains(a, b), nil
+		}
+
+		cmp := strings.Compare(a, b)
+		fa, errA := strconv.ParseFloat(a, 64)
+		fb, errB := strconv.ParseFloat(b, 64)
+		if errA == nil && errB == nil {
+			cmp = 0
+			if fa < fb {
+				cmp = -1
+			} else if fa > fb {
+				cmp = 1
+			}
+		}
+
+		switch op {
+		case "==":
+			return cmp == 0, nil
+		case "!=":
+			return cmp != 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">=":
+			return cmp >= 0, nil
+		case "<":
+			return cmp < 0, nil
+		case ">":
+			return cmp > 0, nil
+		}
+	}
+
+	v := unquoteScriptValue(strings.TrimSpace(cond))
+
+	return v != "" && v != "0" && v != "false", nil
+}
+
+// unquoteScriptValue removes the double quotes surrounding a value, if any.
+func unquoteScriptValue(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
+
+// parseDuration parses either a Go duration such as "1m30s" or a number of seconds.
+func parseDuration(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		sec, errF := strconv.ParseFloat(v, 64)
+		if errF != nil {
+			return 0, fmt.Errorf("invalid duration %s", v)
+		}
+		d = time.Duration(sec * float64(time.Second))
+	}
+
+	return d, nil
+}
+
+// scriptSleep waits for the given duration, see parseDuration. Sleeping is interrupted when the program is asked to
+// quit.
+func scriptSleep(v string) error {
+	d, err := parseDuration(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-time.After(d):
+		return nil
+	case <-quit:
+		return errScriptAborted
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// arithParser evaluates the arithmetic expressions of scripts written as $((expression)). It supports the +, -, *, /
+// and % operators, parentheses, numbers and the names of variables holding a number. All arithmetic is done using
+// floating point numbers, so that exposure compensation steps such as 0.3 work as expected.
+type arithParser struct {
+	in   string
+	pos  int
+	vars map[string]string
+}
+
+// evalArithmetic evaluates the expression using the given variables.
+func evalArithmetic(expr string, vars map[string]string) (float64, error) {
+	p := &arithParser{in: expr, vars: vars}
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.in) {
+		return 0, fmt.Errorf("unexpected %s in expression %s", p.in[p.pos:], expr)
+	}
+
+	return v, nil
+}
+
+// formatArithmetic formats the result of an expression, leaving out the decimals of whole numbers.
+func formatArithmetic(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (p *arithParser) skipSpace() {
+	for p.pos < len(p.in) && (p.in[p.pos] == ' ' || p.in[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// next returns the next operator, if it is one of ops, and skips it.
+func (p *arithParser) next(ops string) (byte, bool) {
+	p.skipSpace()
+	if p.pos < len(p.in) && strings.IndexByte(ops, p.in[p.pos]) != -1 {
+		p.pos++
+		return p.in[p.pos-1], true
+	}
+
+	return 0, false
+}
+
+// expr parses the terms separated by + and -.
+func (p *arithParser) expr() (float64, error) {
+	v, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.next("+-")
+		if !ok {
+			return v, nil
+		}
+		t, err := p.term()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			v += t
+		} else {
+			v -= t
+		}
+	}
+}
+
+// term parses the factors separated by *, / and %.
+func (p *arithParser) term() (float64, error) {
+	v, err := p.factor()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.next("*/%")
+		if !ok {
+			return v, nil
+		}
+		f, err := p.factor()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			v *= f
+		case '/', '%':
+			if f == 0 {
+				return 0, errors.New("division by zero")
+			}
+			if op == '/' {
+				v /= f
+			} else {
+				v = math.Mod(v, f)
+			}
+		}
+	}
+}
+
+// factor parses a number, a variable name, an expression between parentheses or a factor preceded by a sign.
+func (p *arithParser) factor() (float64, error) {
+	if op, ok := p.next("+-"); ok {
+		v, err := p.factor()
+		if op == '-' {
+			v = -v
+		}
+		return v, err
+	}
+	if _, ok := p.next("("); ok {
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := p.next(")"); !ok {
+			return 0, fmt.Errorf("missing ) in expression %s", p.in)
+		}
+		return v, nil
+	}
+
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.in) && isArithOperand(p.in[p.pos]) {
+		p.pos++
+	}
+	tok := p.in[start:p.pos]
+	if tok == "" {
+		if p.pos == len(p.in) {
+			return 0, fmt.Errorf("incomplete expression %s", p.in)
+		}
+		return 0, fmt.Errorf("unexpected %c in expression %s", p.in[p.pos], p.in)
+	}
+
+	if v, err := strconv.ParseFloat(tok, 64); err == nil {
+		return v, nil
+	}
+	val, ok := p.vars[tok]
+	if !ok {
+		return 0, fmt.Errorf("undefined variable %s", tok)
+	}
+	v, err := strconv.ParseFloat(unquoteScriptValue(strings.TrimSpace(val)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("variable %s is not a number: %s", tok, val)
+	}
+
+	return v, nil
+}
+
+// isArithOperand returns true for the characters making up a number or a variable name.
+func isArithOperand(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
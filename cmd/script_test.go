@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunScript(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := runScript("testdata/test_script.ptp", []string{"3"}, bufio.NewWriter(&b), c); err != nil {
+		t.Fatalf("runScript() err = %s", err)
+	}
+
+	want := "hello world!\nget has a usage line\nat least three\ncosts $5\ntotal 12, half 3\n"
+	if got := b.String(); got != want {
+		t.Errorf("runScript() got = %q; want %q", got, want)
+	}
+
+	if err := runScript("testdata/does-not-exist.ptp", nil, bufio.NewWriter(&b), c); err == nil {
+		t.Error("runScript() err = nil; want error")
+	}
+}
+
+func TestRunScriptSource_failure(t *testing.T) {
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+
+	var b bytes.Buffer
+	err := runScriptSource("test", []byte("echo a\nsource testdata/does-not-exist.ptp\necho b\n"), nil, bufio.NewWriter(&b), c)
+	var ce *scriptCommandError
+	if !errors.As(err, &ce) || !strings.HasPrefix(err.Error(), "test: line 2: ") {
+		t.Errorf("runScriptSource() err = %v; want command error at line 2", err)
+	}
+	if got := b.String(); !strings.HasPrefix(got, "a\nsource error: ") || strings.Contains(got, "b\n") {
+		t.Errorf("runScriptSource() got = %q; want the script to stop at the failing command", got)
+	}
+}
+
+func TestRunScriptSource_depth(t *testing.T) {
+	c := newMockClient(ptp.VE_MicrosoftCorporation)
+	path := filepath.Join(t.TempDir(), "self.ptp")
+	if err := os.WriteFile(path, []byte("echo level\nsource "+path+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every invocation is limited on its own, even when running at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var b bytes.Buffer
+			err := runScript(path, nil, bufio.NewWriter(&b), c)
+			if !errors.Is(err, errScriptTooDeep) {
+				t.Errorf("runScript() err = %v; want %s", err, errScriptTooDeep)
+			}
+			if got := strings.Count(b.String(), "level\n"); got != scriptMaxDepth {
+				t.Errorf("runScript() ran %d levels; want %d", got, scriptMaxDepth)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParseScript(t *testing.T) {
+	tests := map[string]string{
+		"if 1\necho x\n":        "line 1: if without end",
+		"echo x\nend\n":         "line 2: end without if",
+		"let = 5\n":             "line 1: expected let name = value",
+		"let a b\n":             "line 1: expected let name = value",
+		"sleep\n":               "line 1: missing duration",
+		"if\nend\n":             "line 1: missing condition",
+		"if 1\nelse 2\nend\n":   "line 2: unexpected 2 after else",
+		"repeat 3 {\necho x\n":  "line 1: repeat without }",
+		"repeat 3\necho x\n}\n": "line 1: expected repeat count {",
+		"repeat 3 as {\n}\n":    "line 1: expected repeat count as name {",
+		"echo x\n}\n":           "line 2: } without repeat",
+		"if 1\n}\n":             "line 1: if without end",
+	}
+	for in, want := range tests {
+		if _, err := parseScript([]byte(in)); err == nil || err.Error() != want {
+			t.Errorf("parseScript(%q) err = %v; want %s", in, err, want)
+		}
+	}
+
+	stmts, err := parseScript([]byte("# comment\n\nif $a == 1\n  get iso\nelse\n  capture\nend\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 1 || stmts[0].keyword != "if" || len(stmts[0].then) != 1 || stmts[0].then[0].args != "get iso" || len(stmts[0].otherwise) != 1 {
+		t.Errorf("parseScript() got = %+v; want a single if statement", stmts)
+	}
+}
+
+func TestScriptRunner_expand(t *testing.T) {
+	s := &scriptRunner{vars: map[string]string{"ev": "-0.3", "n": "4"}}
+	tests := map[string]string{
+		"$((1 + 2 * 3))":             "7",
+		"$(( (1 + 2) * 3 ))":         "9",
+		"bias $((ev + 0.6))":         "bias 0.3",
+		"$(( $n / 8 )) and $((n%3))": "0.5 and 1",
+		"$(( -n ))":                  "-4",
+		"$$((1))":                    "$((1))",
+	}
+	for in, want := range tests {
+		if got, err := s.expand(in); err != nil || got != want {
+			t.Errorf("expand(%s) got = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"$((1 / 0))", "$((1 +))", "$((missing))", "$((1 + 2)", "$((1 2))"} {
+		if _, err := s.expand(in); err == nil {
+			t.Errorf("expand(%s) err = nil; want error", in)
+		}
+	}
+}
+
+func TestScriptRunner_condition(t *testing.T) {
+	s := &scriptRunner{vars: map[string]string{"iso": "200", "wb": "Daylight"}}
+	tests := map[string]bool{
+		"$iso == 200":       true,
+		"$iso != 200":       false,
+		"$iso < 1000":       true,
+		"$iso >= 200.0":     true,
+		`$wb == "Daylight"`: true,
+		`$wb contains day`:  false,
+		`$wb contains Day`:  true,
+		"$iso":              true,
+		"0":                 false,
+		"false":             false,
+		`""`:                false,
+	}
+	for cond, want := range tests {
+		got, err := s.condition(cond)
+		if err != nil || got != want {
+			t.Errorf("condition(%s) got = %v, %v; want %v", cond, got, err, want)
+		}
+	}
+
+	if _, err := s.condition("$missing == 1"); err == nil {
+		t.Error("condition() err = nil; want undefined variable error")
+	}
+	if got, err := s.condition(`"$2" == ""`); err != nil || !got {
+		t.Errorf("condition() missing argument got = %v, %v; want true", got, err)
+	}
+}
@@ -2,43 +2,93 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"github.com/malc0mn/ptp-ip/ip"
-	"log"
 	"net"
+	"strings"
+	"sync"
+	"time"
 )
 
-func validateAddress() {
-	if ip := net.ParseIP(conf.srvAddr); ip == nil {
-		log.Fatalf("Invalid IP address '%s'", conf.srvAddr)
-	}
-}
-
-func launchServer(c *ip.Client) {
-	validateAddress()
-
+// launchServer executes the commands received on sock until sock is closed. Every command being executed is counted
+// by the wait group, so that shutting down can wait for them to finish.
+func launchServer(c *ip.Client, q *jobQueue, sock net.Listener, commands *sync.WaitGroup) {
 	lmp := "[Local server]"
-	sock, err := net.Listen("tcp", net.JoinHostPort(conf.srvAddr, conf.srvPort.String()))
-	defer sock.Close()
-	if err != nil {
-		log.Printf("%s error %s...", lmp, err)
-		return
-	}
-	log.Printf("%s listening on %s...", lmp, sock.Addr().String())
-	log.Printf("%s awaiting messages... (CTRL+C to quit)", lmp)
+	logger.Infof("%s listening on %s...", lmp, sock.Addr().String())
+	logger.Infof("%s awaiting messages... (CTRL+C to quit)", lmp)
 
+	conns := &serverConns{conns: make(map[net.Conn]struct{})}
 	for {
 		conn, err := sock.Accept()
 		if err != nil {
-			log.Printf("%s accept error %s...", lmp, err)
+			if errors.Is(err, net.ErrClosed) {
+				conns.stopReading()
+				return
+			}
+			logger.Errorf("%s accept error %s...", lmp, err)
 			continue
 		}
-		go handleMessages(conn, c, lmp)
+		commands.Add(1)
+		conns.add(conn)
+		go func() {
+			defer commands.Done()
+			defer conns.remove(conn)
+			handleMessages(conn, c, q, lmp)
+		}()
+	}
+}
+
+// serverConns holds the open connections of a local server. The clients of the JSON protocol keep their connection
+// open, so they must stop being read from when shutting down or shutting down would wait for them to go away.
+type serverConns struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (sc *serverConns) add(conn net.Conn) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.conns[conn] = struct{}{}
+}
+
+func (sc *serverConns) remove(conn net.Conn) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	delete(sc.conns, conn)
+}
+
+// stopReading makes all pending reads return without closing the connections, so that the commands being executed
+// can still send their output.
+func (sc *serverConns) stopReading() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for conn := range sc.conns {
+		conn.SetReadDeadline(time.Now())
 	}
 }
 
-func handleMessages(conn net.Conn, c *ip.Client, lmp string) {
+// handleMessages executes the commands received on conn using the job queue. Every connection is a client of its own,
+// releasing the lock it holds when it is closed. When the first line is a JSON message, the client speaks the JSON
+// protocol and the connection is kept open for the next messages. Otherwise, the line is a plain text command: its
+// output is written as is and the connection is closed.
+func handleMessages(conn net.Conn, c ip.ClientAPI, q *jobQueue, lmp string) {
 	defer conn.Close()
 	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
 
-	readAndExecuteCommand(rw, c, lmp)
+	msg, ok := readCommand(rw.Reader, lmp)
+	if !ok {
+		return
+	}
+	owner := "tcp " + conn.RemoteAddr().String()
+	defer q.release(owner)
+	if isProtoMessage(msg) {
+		newProtoSession(conn, c, q, owner, lmp).serve(msg, rw.Reader)
+		return
+	}
+
+	if strings.TrimSpace(msg) == "" {
+		logger.Warnf("%s ignoring empty command!", lmp)
+		return
+	}
+	q.execute(msg, rw.Writer, c, owner, lmp)
 }
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"errors"
+	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/proto/ptpipv1"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync"
+)
+
+const (
+	// grpcChunkSize is the maximum size of the chunks GetObject streams an object in.
+	grpcChunkSize = 64 * 1024
+	// grpcEventQueueSize is the number of events waiting to be sent to a StreamEvents call. A call falling this far
+	// behind is ended.
+	grpcEventQueueSize = 64
+	// grpcEventsOwner identifies the event broadcaster of the gRPC server as a client of the job queue.
+	grpcEventsOwner = "grpc events"
+)
+
+// grpcServer implements the Camera service defined in proto/ptpip.proto. Like the commands of the other servers, all
+// calls are executed using the job queue of the camera, each connection being a client of its own.
+type grpcServer struct {
+	ptpipv1.UnimplementedCameraServer
+
+	c   ip.ClientAPI
+	q   *jobQueue
+	srv *grpc.Server
+
+	events chan ip.EventPacket
+	mu     sync.Mutex
+	// streams holds the send queue of each StreamEvents call.
+	streams map[chan *ptpipv1.Event]struct{}
+	// done is closed when shutting down, ending all StreamEvents calls.
+	done chan struct{}
+}
+
+func newGrpcServer(c ip.ClientAPI, q *jobQueue) *grpcServer {
+	s := &grpcServer{
+		c:       c,
+		q:       q,
+		srv:     grpc.NewServer(),
+		events:  make(chan ip.EventPacket, 20),
+		streams: make(map[chan *ptpipv1.Event]struct{}),
+		done:    make(chan struct{}),
+	}
+	ptpipv1.RegisterCameraServer(s.srv, s)
+	c.OnEvent(s.queue)
+	go s.run()
+
+	return s
+}
+
+// queue is registered as event handler on the client: it must never block the event listener.
+func (s *grpcServer) queue(p ip.EventPacket) {
+	select {
+	case s.events <- p:
+	default:
+		logger.Warnf("[gRPC server] event queue full, dropping event %#x", p.GetEventCode())
+	}
+}
+
+// run pushes the queued events to all StreamEvents calls. A property change is followed by the new value of the
+// property, which is only looked up using the job queue when there is a call to send it to.
+func (s *grpcServer) run() {
+	for p := range s.events {
+		s.broadcast(&ptpipv1.Event{Kind: &ptpipv1.Event_Camera{Camera: &ptpipv1.CameraEvent{
+			Code:          uint32(p.GetEventCode()),
+			TransactionId: uint32(p.GetTransactionID()),
+			Parameter1:    p.GetParameter1(),
+		}}})
+
+		s.mu.Lock()
+		listening := len(s.streams) > 0
+		s.mu.Unlock()
+		if p.GetEventCode() != ptp.EC_DevicePropChanged || !listening {
+			continue
+		}
+		var (
+			prop *ptpipv1.Property
+			err  error
+		)
+		if qerr := s.q.submitWithin(grpcEventsOwner, eventLookupTimeout, func() {
+			prop, err = s.property(ptp.DevicePropCode(p.GetParameter1()))
+		}); qerr == nil && err == nil {
+			s.broadcast(&ptpipv1.Event{Kind: &ptpipv1.Event_Property{Property: prop}})
+		}
+	}
+}
+
+// broadcast queues the event for all StreamEvents calls without waiting for it to be sent. The send queue of a call
+// that is full is closed, ending the call.
+func (s *grpcServer) broadcast(ev *ptpipv1.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for send := range s.streams {
+		select {
+		case send <- ev:
+		default:
+			logger.Warnf("[gRPC server] client too slow, ending event stream")
+			delete(s.streams, send)
+			close(send)
+		}
+	}
+}
+
+// serve serves the Camera service using l until shutting down.
+func (s *grpcServer) serve(l net.Listener) {
+	if err := s.srv.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		logger.Errorf("[gRPC server] error %s...", err)
+	}
+}
+
+// stop ends all event streams and waits for the other calls to finish, until the context is done.
+func (s *grpcServer) stop(ctx context.Context) {
+	close(s.done)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.srv.Stop()
+	}
+}
+
+// submit executes the work on behalf of the connection of the call using the job queue. The error returned by the
+// work, or the error of the queue when the work timed out waiting for its turn, is converted to a status error.
+func (s *grpcServer) submit(ctx context.Context, run func() error) error {
+	owner := "grpc"
+	if p, ok := peer.FromContext(ctx); ok {
+		owner += " " + p.Addr.String()
+	}
+
+	var err error
+	if qerr := s.q.submit(owner, func() { err = run() }); qerr != nil {
+		err = qerr
+	}
+
+	return grpcError(err)
+}
+
+// grpcError returns the status error reported to the clients for err, using the failure class of err to pick the
+// status code.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codes.Unknown
+	switch exitCode(err, errGeneral) {
+	case errNotSupported:
+		code = codes.Unimplemented
+	case errDeviceBusy, errConnectionLost:
+		code = codes.Unavailable
+	case errTimeout:
+		code = codes.DeadlineExceeded
+	case errCapture:
+		code = codes.Aborted
+	}
+
+	return status.Error(code, err.Error())
+}
+
+func (s *grpcServer) GetDeviceInfo(ctx context.Context, _ *ptpipv1.GetDeviceInfoRequest) (*ptpipv1.DeviceInfo, error) {
+	res := &ptpipv1.DeviceInfo{}
+	err := s.submit(ctx, func() error {
+		di, err := s.c.GetDeviceInfo()
+		if err != nil {
+			return err
+		}
+
+		switch di := di.(type) {
+		case *ptp.DeviceInfo:
+			res.Manufacturer = di.Manufacturer
+			res.Model = di.Model
+			res.DeviceVersion = di.DeviceVersion
+			res.SerialNumber = di.SerialNumber
+			res.VendorExtensionId = di.VendorExtensionID
+			for _, op := range di.OperationsSupported {
+				res.OperationsSupported = append(res.OperationsSupported, uint32(op))
+			}
+			for _, ev := range di.EventsSupported {
+				res.EventsSupported = append(res.EventsSupported, uint32(ev))
+			}
+			for _, cod := range di.DevicePropertiesSupported {
+				res.DevicePropertiesSupported = append(res.DevicePropertiesSupported, uint32(cod))
+			}
+		case []*ptp.DevicePropDesc:
+			// Fuji cameras describe all their properties instead.
+			res.VendorExtensionId = uint32(s.c.ResponderVendor())
+			for _, dpd := range di {
+				res.DevicePropertiesSupported = append(res.DevicePropertiesSupported, uint32(dpd.DevicePropertyCode))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// propertyCode returns the device property code from the code or the name set in the request.
+func (s *grpcServer) propertyCode(code uint32, name string) (ptp.DevicePropCode, error) {
+	if name == "" {
+		return ptp.DevicePropCode(code), nil
+	}
+
+	cod, err := formatDeviceProperty(s.c, name)
+	if err != nil {
+		return 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return cod, nil
+}
+
+// property returns the current value of the device property together with its description, when available.
+func (s *grpcServer) property(cod ptp.DevicePropCode) (*ptpipv1.Property, error) {
+	v, err := s.c.GetDevicePropertyValue(cod)
+	if err != nil {
+		return nil, err
+	}
+
+	prop := &ptpipv1.Property{
+		Code:      uint32(cod),
+		Name:      ptpfmt.DevicePropCodeAsString(cod),
+		Value:     int64(v),
+		Formatted: ptpfmt.DevicePropValAsString(s.c.ResponderVendor(), cod, int64(v)),
+	}
+	if dpd := describeDeviceProperty(s.c, cod); dpd != nil {
+		prop.Writable = dpd.GetSet == ptp.DPD_GetSet
+		prop.Allowed = supportedDevicePropertyValues(dpd)
+	}
+
+	return prop, nil
+}
+
+func (s *grpcServer) GetProperty(ctx context.Context, req *ptpipv1.GetPropertyRequest) (*ptpipv1.Property, error) {
+	cod, err := s.propertyCode(req.GetCode(), req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	var prop *ptpipv1.Property
+	if err := s.submit(ctx, func() error {
+		prop, err = s.property(cod)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return prop, nil
+}
+
+func (s *grpcServer) SetProperty(ctx context.Context, req *ptpipv1.SetPropertyRequest) (*ptpipv1.Property, error) {
+	cod, err := s.propertyCode(req.GetCode(), req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	var prop *ptpipv1.Property
+	if err := s.submit(ctx, func() error {
+		if err := s.c.SetDeviceProperty(cod, req.GetValue()); err != nil {
+			return err
+		}
+		prop, err = s.property(cod)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return prop, nil
+}
+
+func (s *grpcServer) Capture(ctx context.Context, _ *ptpipv1.CaptureRequest) (*ptpipv1.CaptureResponse, error) {
+	res := &ptpipv1.CaptureResponse{}
+	if err := s.submit(ctx, func() error {
+		var err error
+		res.Preview, err = s.c.InitiateCapture()
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *grpcServer) ListObjects(ctx context.Context, req *ptpipv1.ListObjectsRequest) (*ptpipv1.ListObjectsResponse, error) {
+	sid := ptp.StorageID(req.GetStorageId())
+	if sid == 0 {
+		sid = ip.AllStorages
+	}
+
+	res := &ptpipv1.ListObjectsResponse{}
+	if err := s.submit(ctx, func() error {
+		handles, err := s.c.GetObjectHandles(sid, 0, 0)
+		for _, h := range handles {
+			res.Handles = append(res.Handles, uint32(h))
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *grpcServer) GetObject(req *ptpipv1.GetObjectRequest, stream ptpipv1.Camera_GetObjectServer) error {
+	h := ptp.ObjectHandle(req.GetHandle())
+
+	return s.submit(stream.Context(), func() error {
+		cw := &grpcChunkWriter{stream: stream}
+		if oi, err := s.c.GetObjectInfo(h); err == nil {
+			cw.total = uint64(oi.ObjectCompressedSize)
+		}
+		_, err := s.c.GetObjectTo(h, cw)
+		return err
+	})
+}
+
+// grpcChunkWriter streams everything written to it to a GetObject call, in chunks of at most grpcChunkSize.
+type grpcChunkWriter struct {
+	stream ptpipv1.Camera_GetObjectServer
+	offset uint64
+	total  uint64
+}
+
+func (cw *grpcChunkWriter) Write(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		chunk := b[n:]
+		if len(chunk) > grpcChunkSize {
+			chunk = chunk[:grpcChunkSize]
+		}
+		if err := cw.stream.Send(&ptpipv1.ObjectChunk{Data: chunk, Offset: cw.offset, Total: cw.total}); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		cw.offset += uint64(len(chunk))
+	}
+
+	return n, nil
+}
+
+func (s *grpcServer) StreamEvents(_ *ptpipv1.StreamEventsRequest, stream ptpipv1.Camera_StreamEventsServer) error {
+	send := make(chan *ptpipv1.Event, grpcEventQueueSize)
+	s.mu.Lock()
+	s.streams[send] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.streams[send]; ok {
+			delete(s.streams, send)
+			close(send)
+		}
+	}()
+
+	for {
+		select {
+		case ev, ok := <-send:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "client too slow, events dropped")
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server shutting down")
+		}
+	}
+}
+
+// launchGrpcServer serves the Camera service using the given listener.
+func launchGrpcServer(c ip.ClientAPI, q *jobQueue, l net.Listener, d *daemon) {
+	logger.Infof("[gRPC server] listening on %s...", l.Addr().String())
+	d.serveGrpc(l, newGrpcServer(c, q))
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/proto/ptpipv1"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// grpcMockClient adds the capture, object and event methods used by the gRPC server to the mock client.
+type grpcMockClient struct {
+	*mockClient
+	objects map[ptp.ObjectHandle][]byte
+
+	mu      sync.Mutex
+	handler func(ip.EventPacket)
+}
+
+func (mc *grpcMockClient) OnEvent(f func(ip.EventPacket)) func() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.handler = f
+
+	return func() {}
+}
+
+func (mc *grpcMockClient) GetDeviceInfo() (interface{}, error) {
+	return &ptp.DeviceInfo{Manufacturer: "ptpip", Model: "mock", OperationsSupported: []ptp.OperationCode{ptp.OC_GetDeviceInfo}}, nil
+}
+
+func (mc *grpcMockClient) SetDeviceProperty(code ptp.DevicePropCode, val uint32) error {
+	if _, ok := mc.props[code]; !ok {
+		return ptp.ResponseCodeAsError(ptp.RC_DevicePropNotSupported)
+	}
+	mc.props[code] = val
+
+	return nil
+}
+
+func (mc *grpcMockClient) InitiateCapture() ([]byte, error) {
+	return []byte("preview"), nil
+}
+
+func (mc *grpcMockClient) GetObjectHandles(sid ptp.StorageID, _ ptp.ObjectFormatCode, _ ptp.ObjectHandle) ([]ptp.ObjectHandle, error) {
+	mc.requests[ptp.OC_GetObjectHandles] = []uint32{uint32(sid)}
+
+	var handles []ptp.ObjectHandle
+	for h := range mc.objects {
+		handles = append(handles, h)
+	}
+
+	return handles, nil
+}
+
+func (mc *grpcMockClient) GetObjectInfo(h ptp.ObjectHandle) (*ptp.ObjectInfo, error) {
+	return &ptp.ObjectInfo{ObjectCompressedSize: uint32(len(mc.objects[h]))}, nil
+}
+
+func (mc *grpcMockClient) GetObjectTo(h ptp.ObjectHandle, w io.Writer) (int64, error) {
+	data, ok := mc.objects[h]
+	if !ok {
+		return 0, ptp.ResponseCodeAsError(ptp.RC_InvalidObjectHandle)
+	}
+	n, err := w.Write(data)
+
+	return int64(n), err
+}
+
+// grpcTestClient serves the gRPC server using an in-memory connection and returns a client connected to it.
+func grpcTestClient(t *testing.T, mc *grpcMockClient) (*grpcServer, ptpipv1.CameraClient) {
+	l := bufconn.Listen(1024 * 1024)
+	s := newGrpcServer(mc, newJobQueue(0))
+	go s.serve(l)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.stop(ctx)
+	})
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return s, ptpipv1.NewCameraClient(conn)
+}
+
+func TestGrpcServer_GetDeviceInfo(t *testing.T) {
+	_, c := grpcTestClient(t, &grpcMockClient{mockClient: newMockClient(ptp.VE_MicrosoftCorporation)})
+
+	di, err := c.GetDeviceInfo(context.Background(), &ptpipv1.GetDeviceInfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if di.Manufacturer != "ptpip" || di.Model != "mock" || len(di.OperationsSupported) != 1 || di.OperationsSupported[0] != uint32(ptp.OC_GetDeviceInfo) {
+		t.Errorf("GetDeviceInfo() got = %v; want ptpip mock supporting GetDeviceInfo", di)
+	}
+}
+
+func TestGrpcServer_property(t *testing.T) {
+	mc := &grpcMockClient{mockClient: newMockClient(ptp.VE_MicrosoftCorporation)}
+	mc.props[ptp.DPC_ExposureIndex] = 200
+	mc.descs[ptp.DPC_ExposureIndex] = &ptp.DevicePropDesc{DevicePropertyCode: ptp.DPC_ExposureIndex, GetSet: ptp.DPD_GetSet}
+	_, c := grpcTestClient(t, mc)
+	ctx := context.Background()
+
+	prop, err := c.GetProperty(ctx, &ptpipv1.GetPropertyRequest{Property: &ptpipv1.GetPropertyRequest_Name{Name: "iso"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prop.Code != uint32(ptp.DPC_ExposureIndex) || prop.Value != 200 || !prop.Writable {
+		t.Errorf("GetProperty() got = %v; want writable 0x500f with value 200", prop)
+	}
+
+	prop, err = c.SetProperty(ctx, &ptpipv1.SetPropertyRequest{Property: &ptpipv1.SetPropertyRequest_Code{Code: uint32(ptp.DPC_ExposureIndex)}, Value: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prop.Value != 400 || mc.props[ptp.DPC_ExposureIndex] != 400 {
+		t.Errorf("SetProperty() got = %v; want value 400", prop)
+	}
+
+	_, err = c.GetProperty(ctx, &ptpipv1.GetPropertyRequest{Property: &ptpipv1.GetPropertyRequest_Name{Name: "nope"}})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("GetProperty() code = %s; want %s", got, codes.InvalidArgument)
+	}
+	_, err = c.GetProperty(ctx, &ptpipv1.GetPropertyRequest{Property: &ptpipv1.GetPropertyRequest_Code{Code: uint32(ptp.DPC_FNumber)}})
+	if got := status.Code(err); got != codes.Unimplemented {
+		t.Errorf("GetProperty() code = %s; want %s", got, codes.Unimplemented)
+	}
+}
+
+func TestGrpcServer_Capture(t *testing.T) {
+	_, c := grpcTestClient(t, &grpcMockClient{mockClient: newMockClient(ptp.VE_MicrosoftCorporation)})
+
+	res, err := c.Capture(context.Background(), &ptpipv1.CaptureRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Preview) != "preview" {
+		t.Errorf("Capture() got = %q; want preview", res.Preview)
+	}
+}
+
+func TestGrpcServer_objects(t *testing.T) {
+	data := bytes.Repeat([]byte("ptpip"), grpcChunkSize/2)
+	mc := &grpcMockClient{mockClient: newMockClient(ptp.VE_MicrosoftCorporation), objects: map[ptp.ObjectHandle][]byte{7: data}}
+	_, c := grpcTestClient(t, mc)
+	ctx := context.Background()
+
+	list, err := c.ListObjects(ctx, &ptpipv1.ListObjectsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Handles) != 1 || list.Handles[0] != 7 || mc.requests[ptp.OC_GetObjectHandles][0] != uint32(ip.AllStorages) {
+		t.Errorf("ListObjects() got = %v, storage %#x; want handle 7 listed from all storages", list.Handles, mc.requests[ptp.OC_GetObjectHandles][0])
+	}
+
+	stream, err := c.GetObject(ctx, &ptpipv1.GetObjectRequest{Handle: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		got    []byte
+		chunks int
+	)
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Offset != uint64(len(got)) || chunk.Total != uint64(len(data)) {
+			t.Errorf("GetObject() chunk offset = %d, total = %d; want %d, %d", chunk.Offset, chunk.Total, len(got), len(data))
+		}
+		got = append(got, chunk.Data...)
+		chunks++
+	}
+	if !bytes.Equal(got, data) || chunks != 3 {
+		t.Errorf("GetObject() got = %d bytes in %d chunks; want %d bytes in 3 chunks", len(got), chunks, len(data))
+	}
+
+	stream, err = c.GetObject(ctx, &ptpipv1.GetObjectRequest{Handle: 8})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if got := status.Code(err); got != codes.Unknown {
+		t.Errorf("GetObject() code = %s; want %s", got, codes.Unknown)
+	}
+}
+
+func TestGrpcServer_StreamEvents(t *testing.T) {
+	mc := &grpcMockClient{mockClient: newMockClient(ptp.VE_MicrosoftCorporation)}
+	mc.props[ptp.DPC_ExposureIndex] = 800
+	s, c := grpcTestClient(t, mc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.StreamEvents(ctx, &ptpipv1.StreamEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make sure the call has been registered before queueing the event.
+	for registered := false; !registered; time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		registered = len(s.streams) == 1
+		s.mu.Unlock()
+	}
+	mc.mu.Lock()
+	queue := mc.handler
+	mc.mu.Unlock()
+	// The property lookup must wait for the command being executed by another client.
+	release := make(chan struct{})
+	s.q.queue("test", func() { <-release })
+	queue(&ip.GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_DevicePropChanged, TransactionID: 7, Parameter1: []byte{0x0f, 0x50}}})
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ce := ev.GetCamera(); ce == nil || ce.Code != uint32(ptp.EC_DevicePropChanged) || ce.TransactionId != 7 || ce.Parameter1 != uint32(ptp.DPC_ExposureIndex) {
+		t.Errorf("StreamEvents() got = %v; want event 0x4006", ev)
+	}
+	received := make(chan *ptpipv1.Event)
+	go func() {
+		ev, _ := stream.Recv()
+		received <- ev
+	}()
+	select {
+	case ev = <-received:
+		t.Fatalf("StreamEvents() got = %v while the queue was busy; want the lookup to wait for its turn", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if ev = <-received; ev == nil {
+		t.Fatal("StreamEvents() no property received")
+	}
+	if prop := ev.GetProperty(); prop == nil || prop.Code != uint32(ptp.DPC_ExposureIndex) || prop.Value != 800 {
+		t.Errorf("StreamEvents() got = %v; want property 0x500f with value 800", ev)
+	}
+}
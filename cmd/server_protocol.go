@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// protocolVersion is the version of the JSON protocol of the local server. Bump it when changing the meaning of an
+// existing field, new fields and capabilities can be added without doing so.
+const protocolVersion = 1
+
+var (
+	// protocolVersions holds the versions of the JSON protocol the local server speaks.
+	protocolVersions = []int{protocolVersion}
+	// protocolCapabilities holds the optional features of the JSON protocol a client can ask for in its hello message:
+	//   - stream: send the output of a command in output messages as it comes in, instead of all at once in the result
+	protocolCapabilities = []string{"stream"}
+)
+
+// protoRequest is a message sent by a client of the local server using the JSON protocol, one per line. The type is
+// either "hello", to negotiate the version and the capabilities, or "command", which is the default.
+type protoRequest struct {
+	Version int    `json:"v"`
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type,omitempty"`
+	// Capabilities holds the capabilities the client asks for in a hello message.
+	Capabilities []string `json:"capabilities,omitempty"`
+	Command      string   `json:"command,omitempty"`
+}
+
+// protoResponse is a message sent to a client of the local server using the JSON protocol, one per line. The type
+// determines which of the other fields are set:
+//   - hello: the answer to a hello message, listing what the server supports and the capabilities it enabled
+//   - output: part of the output of the command with the given ID, only sent when the stream capability is enabled
+//   - result: the command with the given ID has finished, holding the remainder of its output and its error, if any
+//   - error: the request with the given ID was invalid
+type protoResponse struct {
+	Version      int       `json:"v"`
+	Type         string    `json:"type"`
+	ID           string    `json:"id,omitempty"`
+	Versions     []int     `json:"versions,omitempty"`
+	Capabilities []string  `json:"capabilities,omitempty"`
+	Enabled      []string  `json:"enabled,omitempty"`
+	Commands     []string  `json:"commands,omitempty"`
+	Server       string    `json:"server,omitempty"`
+	Output       string    `json:"output,omitempty"`
+	Error        *cliError `json:"error,omitempty"`
+}
+
+// protoSession is a connection of a client of the local server using the JSON protocol. Contrary to the plain text
+// protocol, the connection stays open until the client closes it so that it can send several commands.
+type protoSession struct {
+	c     ip.ClientAPI
+	q     *jobQueue
+	owner string
+	lmp   string
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	// stream is true when the client asked for the stream capability.
+	stream bool
+}
+
+func newProtoSession(w io.Writer, c ip.ClientAPI, q *jobQueue, owner, lmp string) *protoSession {
+	return &protoSession{c: c, q: q, owner: owner, lmp: lmp, enc: json.NewEncoder(w)}
+}
+
+// isProtoMessage returns true when the line holds a message of the JSON protocol rather than a plain text command.
+func isProtoMessage(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "{")
+}
+
+// serve handles the first line, which has already been read, and all lines read from r after it until the client goes
+// away.
+func (s *protoSession) serve(first string, r *bufio.Reader) {
+	line := first
+	for {
+		if strings.TrimSpace(line) != "" {
+			s.handle(line)
+		}
+
+		var err error
+		if line, err = r.ReadString('\n'); err != nil {
+			var ne net.Error
+			if err != io.EOF && !(errors.As(err, &ne) && ne.Timeout()) {
+				logger.Errorf("%s error reading message '%s'", s.lmp, err)
+			}
+			return
+		}
+	}
+}
+
+// handle executes a single message.
+func (s *protoSession) handle(line string) {
+	var req protoRequest
+	if err := json.Unmarshal([]byte(line), &req); err != nil {
+		s.write(protoResponse{Type: "error", Error: protoError("invalid message", err)})
+		return
+	}
+	if !supportedProtocolVersion(req.Version) {
+		s.write(protoResponse{
+			Type:     "error",
+			ID:       req.ID,
+			Versions: protocolVersions,
+			Error:    protoError(fmt.Sprintf("unsupported protocol version %d", req.Version), nil),
+		})
+		return
+	}
+
+	switch req.Type {
+	case "hello":
+		s.hello(req)
+	case "", "command":
+		s.command(req)
+	default:
+		s.write(protoResponse{Type: "error", ID: req.ID, Error: protoError(fmt.Sprintf("unknown message type %s", req.Type), nil)})
+	}
+}
+
+// hello enables the capabilities asked for that the server supports and tells the client what the server supports.
+func (s *protoSession) hello(req protoRequest) {
+	var enabled []string
+	for _, cp := range req.Capabilities {
+		switch cp {
+		case "stream":
+			s.stream = true
+		default:
+			continue
+		}
+		enabled = append(enabled, cp)
+	}
+
+	s.write(protoResponse{
+		Type:         "hello",
+		ID:           req.ID,
+		Versions:     protocolVersions,
+		Capabilities: protocolCapabilities,
+		Enabled:      enabled,
+		Commands:     commandNames(),
+		Server:       exe + " " + version,
+	})
+}
+
+// command executes the command using the job queue and sends the result.
+func (s *protoSession) command(req protoRequest) {
+	msg := strings.TrimSpace(req.Command)
+	if msg == "" {
+		s.write(protoResponse{Type: "error", ID: req.ID, Error: protoError("empty command", nil)})
+		return
+	}
+	logger.Infof("%s message received: '%s'", s.lmp, msg)
+
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	if s.stream {
+		w = bufio.NewWriter(protoOutput{s: s, id: req.ID})
+	}
+
+	res := protoResponse{Type: "result", ID: req.ID}
+	if err := s.q.execute(msg, w, s.c, s.owner, s.lmp); err != nil {
+		ce := newCliError(errGeneral, strings.Fields(msg)[0], err)
+		res.Error = &ce
+	}
+	res.Output = b.String()
+	s.write(res)
+}
+
+// write sends a single message. The output of a command is written from two goroutines, hence the lock.
+func (s *protoSession) write(res protoResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Version = protocolVersion
+	if err := s.enc.Encode(res); err != nil {
+		logger.Errorf("%s error writing response: '%s'", s.lmp, err)
+	}
+}
+
+// protoOutput sends everything written to it as command output to a client of the JSON protocol.
+type protoOutput struct {
+	s  *protoSession
+	id string
+}
+
+func (o protoOutput) Write(b []byte) (int, error) {
+	o.s.write(protoResponse{Type: "output", ID: o.id, Output: string(b)})
+
+	return len(b), nil
+}
+
+// protoError returns the error to send for an invalid request.
+func protoError(msg string, err error) *cliError {
+	ce := newCliError(errInvalidArgs, msg, err)
+
+	return &ce
+}
+
+// supportedProtocolVersion returns true when the local server speaks the given version of the JSON protocol.
+func supportedProtocolVersion(v int) bool {
+	for _, sv := range protocolVersions {
+		if v == sv {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// protoTestRead reads a single message of the JSON protocol.
+func protoTestRead(t *testing.T, r *bufio.Reader) protoResponse {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res protoResponse
+	if err := json.Unmarshal([]byte(line), &res); err != nil {
+		t.Fatalf("protoResponse unmarshal err = %s; line %s", err, line)
+	}
+
+	return res
+}
+
+func TestHandleMessages_text(t *testing.T) {
+	srv, conn := net.Pipe()
+	go handleMessages(srv, newMockClient(ptp.VE_MicrosoftCorporation), newJobQueue(0), "test")
+
+	if _, err := conn.Write([]byte("help help\n")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); !strings.Contains(got, `"help"`) {
+		t.Errorf("handleMessages() got = %q; want help output", got)
+	}
+}
+
+func TestHandleMessages_blank(t *testing.T) {
+	srv, conn := net.Pipe()
+	go handleMessages(srv, newMockClient(ptp.VE_MicrosoftCorporation), newJobQueue(0), "test")
+
+	if _, err := conn.Write([]byte(" \t\n")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 0 {
+		t.Errorf("handleMessages() got = %q; want no output", b)
+	}
+}
+
+func TestHandleMessages_releaseLock(t *testing.T) {
+	srv, conn := net.Pipe()
+	q := newJobQueue(0)
+	done := make(chan struct{})
+	go func() {
+		handleMessages(srv, newMockClient(ptp.VE_MicrosoftCorporation), q, "test")
+		close(done)
+	}()
+
+	conn.Write([]byte(`{"v":1,"id":"1","command":"lock"}` + "\n"))
+	if res := protoTestRead(t, bufio.NewReader(conn)); res.Type != "result" || res.Error != nil {
+		t.Fatalf("lock got = %+v; want result", res)
+	}
+	if err := q.mayDestroy("tcp " + srv.RemoteAddr().String()); err != nil {
+		t.Errorf("mayDestroy() error = %s; want <nil> for the connection holding the lock", err)
+	}
+
+	conn.Close()
+	<-done
+	if err := q.lock("other"); err != nil {
+		t.Errorf("lock() error = %s; want <nil> after the connection holding the lock closed", err)
+	}
+}
+
+func TestHandleMessages_json(t *testing.T) {
+	srv, conn := net.Pipe()
+	defer conn.Close()
+	go handleMessages(srv, newMockClient(ptp.VE_MicrosoftCorporation), newJobQueue(0), "test")
+	r := bufio.NewReader(conn)
+
+	conn.Write([]byte(`{"v":1,"id":"h","type":"hello","capabilities":["unknown"]}` + "\n"))
+	res := protoTestRead(t, r)
+	if res.Type != "hello" || res.ID != "h" || res.Version != protocolVersion || len(res.Enabled) != 0 {
+		t.Errorf("hello got = %+v; want hello without capabilities enabled", res)
+	}
+	if len(res.Versions) != 1 || res.Versions[0] != protocolVersion {
+		t.Errorf("hello versions got = %v; want [%d]", res.Versions, protocolVersion)
+	}
+	if !strings.Contains(strings.Join(res.Commands, ","), "opreq") {
+		t.Errorf("hello commands got = %v; want opreq included", res.Commands)
+	}
+
+	// The connection stays open for the next commands.
+	for _, id := range []string{"1", "2"} {
+		conn.Write([]byte(`{"v":1,"id":"` + id + `","command":"help help"}` + "\n"))
+		res = protoTestRead(t, r)
+		if res.Type != "result" || res.ID != id || !strings.Contains(res.Output, `"help"`) || res.Error != nil {
+			t.Errorf("command got = %+v; want help output for ID %s", res, id)
+		}
+	}
+
+	conn.Write([]byte(`{"v":1,"id":"3","command":"opreq DeleteObject 0x1"}` + "\n"))
+	res = protoTestRead(t, r)
+	if res.Type != "result" || res.Error == nil || res.Error.Class != "general" {
+		t.Errorf("command got = %+v; want general error", res)
+	}
+
+	conn.Write([]byte(`{"v":99,"id":"4","command":"help"}` + "\n"))
+	res = protoTestRead(t, r)
+	if res.Type != "error" || res.ID != "4" || res.Error == nil || res.Error.Class != "invalid-args" || len(res.Versions) != 1 {
+		t.Errorf("command got = %+v; want invalid-args error listing the versions", res)
+	}
+
+	conn.Write([]byte("{nope\n"))
+	if res = protoTestRead(t, r); res.Type != "error" {
+		t.Errorf("command got = %+v; want error", res)
+	}
+}
+
+func TestHandleMessages_stream(t *testing.T) {
+	srv, conn := net.Pipe()
+	defer conn.Close()
+	go handleMessages(srv, newMockClient(ptp.VE_MicrosoftCorporation), newJobQueue(0), "test")
+	r := bufio.NewReader(conn)
+
+	conn.Write([]byte(`{"v":1,"type":"hello","capabilities":["stream"]}` + "\n"))
+	if res := protoTestRead(t, r); len(res.Enabled) != 1 || res.Enabled[0] != "stream" {
+		t.Errorf("hello enabled got = %v; want [stream]", res.Enabled)
+	}
+
+	conn.Write([]byte(`{"v":1,"id":"1","command":"help help"}` + "\n"))
+	res := protoTestRead(t, r)
+	if res.Type != "output" || res.ID != "1" || !strings.Contains(res.Output, `"help"`) {
+		t.Errorf("command got = %+v; want help output for ID 1", res)
+	}
+	if res = protoTestRead(t, r); res.Type != "result" || res.ID != "1" || res.Output != "" {
+		t.Errorf("command got = %+v; want empty result for ID 1", res)
+	}
+}
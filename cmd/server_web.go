@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// wsRequest is the message a WebSocket client sends to execute a command. Plain text messages are executed as is.
+type wsRequest struct {
+	// ID is optional and is copied to all responses to the command so a client can match them to the request.
+	ID      string `json:"id,omitempty"`
+	Command string `json:"command"`
+}
+
+// wsResponse is the message sent to the WebSocket clients. The type determines which of the other fields are set:
+//   - output: part of the output of the command with the given ID
+//   - done: the command with the given ID has finished
+//   - error: the request with the given ID was invalid
+//   - event: an event was received from the camera
+//   - property: the value of a device property has changed
+type wsResponse struct {
+	Type     string      `json:"type"`
+	ID       string      `json:"id,omitempty"`
+	Output   string      `json:"output,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Event    *wsEvent    `json:"event,omitempty"`
+	Property *wsProperty `json:"property,omitempty"`
+}
+
+type wsEvent struct {
+	Code          string `json:"code"`
+	TransactionID uint32 `json:"transaction_id"`
+	Parameter1    uint32 `json:"parameter1"`
+}
+
+type wsProperty struct {
+	Code string `json:"code"`
+	Name string `json:"name,omitempty"`
+	// Value is omitted when the value could not be retrieved from the camera.
+	Value string `json:"value,omitempty"`
+}
+
+// wsSendQueueSize is the number of events waiting to be sent to a WebSocket client. A client falling this far behind
+// is disconnected.
+const wsSendQueueSize = 64
+
+// wsEventsOwner identifies the event broadcaster of the WebSocket API as a client of the job queue.
+const wsEventsOwner = "ws events"
+
+// wsHub keeps track of the connected WebSocket clients and pushes the camera events to all of them.
+type wsHub struct {
+	c *ip.Client
+	q *jobQueue
+	// origins holds the origins allowed to open a WebSocket besides the web UI, see originAllowed.
+	origins []string
+	clients map[*wsConn]chan wsResponse
+	events  chan ip.EventPacket
+	mu      sync.Mutex
+}
+
+func newWsHub(c *ip.Client, q *jobQueue, origins []string) *wsHub {
+	h := &wsHub{
+		c:       c,
+		q:       q,
+		origins: origins,
+		clients: make(map[*wsConn]chan wsResponse),
+		events:  make(chan ip.EventPacket, 20),
+	}
+	c.OnEvent(h.queue)
+	go h.run()
+
+	return h
+}
+
+// queue is registered as event handler on the client: it must never block the event listener.
+func (h *wsHub) queue(p ip.EventPacket) {
+	select {
+	case h.events <- p:
+	default:
+		logger.Warnf("[WebSocket] event queue full, dropping event %#x", p.GetEventCode())
+	}
+}
+
+// run pushes the queued events to all clients. A property change is followed by the new value of the property, which
+// requires a round trip to the camera: that is why this cannot be done from the event handler. Like any other command,
+// the lookup uses the job queue.
+func (h *wsHub) run() {
+	for p := range h.events {
+		h.broadcast(wsResponse{Type: "event", Event: &wsEvent{
+			Code:          ptpfmt.ConvertToHexString(p.GetEventCode()),
+			TransactionID: uint32(p.GetTransactionID()),
+			Parameter1:    p.GetParameter1(),
+		}})
+
+		if p.GetEventCode() != ptp.EC_DevicePropChanged {
+			continue
+		}
+		code := ptp.DevicePropCode(p.GetParameter1())
+		prop := &wsProperty{Code: ptpfmt.ConvertToHexString(code), Name: ptpfmt.DevicePropCodeAsString(code)}
+		var (
+			v   uint32
+			err error
+		)
+		if qerr := h.q.submitWithin(wsEventsOwner, eventLookupTimeout, func() {
+			v, err = h.c.GetDevicePropertyValue(code)
+		}); qerr == nil && err == nil {
+			prop.Value = ptpfmt.DevicePropValAsString(h.c.ResponderVendor(), code, int64(v))
+		}
+		h.broadcast(wsResponse{Type: "property", Property: prop})
+	}
+}
+
+// broadcast queues the message for all clients without waiting for it to be sent, so that a slow client does not hold
+// up the others. A client whose send queue is full is disconnected.
+func (h *wsHub) broadcast(res wsResponse) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ws, send := range h.clients {
+		select {
+		case send <- res:
+		default:
+			logger.Warnf("[WebSocket] client too slow, disconnecting")
+			ws.Close()
+		}
+	}
+}
+
+// send writes the messages queued for the client until the queue is closed. After a write error, the connection is
+// closed and the remaining messages are dropped.
+func (h *wsHub) send(ws *wsConn, send <-chan wsResponse, done chan<- struct{}) {
+	defer close(done)
+
+	var err error
+	for res := range send {
+		if err != nil {
+			continue
+		}
+		if err = ws.writeJSON(res); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Errorf("[WebSocket] error writing to client: %s", err)
+			}
+			ws.Close()
+		}
+	}
+}
+
+// ServeHTTP upgrades the connection and executes the commands received until the client goes away. Each connection is
+// a client of the job queue of its own, the camera lock it holds is released when it goes away.
+func (h *wsHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lmp := "[WebSocket]"
+	ws, err := upgradeWebSocket(w, r, h.origins)
+	if err != nil {
+		logger.Errorf("%s %s", lmp, err)
+		return
+	}
+
+	send, done := make(chan wsResponse, wsSendQueueSize), make(chan struct{})
+	go h.send(ws, send, done)
+	h.mu.Lock()
+	h.clients[ws] = send
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, ws)
+		h.mu.Unlock()
+		close(send)
+		ws.Close()
+		<-done
+	}()
+	logger.Infof("%s client %s connected", lmp, r.RemoteAddr)
+	owner := "ws " + r.RemoteAddr
+	defer h.q.release(owner)
+
+	for {
+		_, msg, err := ws.readMessage()
+		if err != nil {
+			if err != io.EOF {
+				logger.Errorf("%s error reading message: %s", lmp, err)
+			}
+			return
+		}
+
+		req := wsRequest{Command: string(msg)}
+		if strings.HasPrefix(strings.TrimSpace(req.Command), "{") {
+			if err := json.Unmarshal(msg, &req); err != nil {
+				ws.writeJSON(wsResponse{Type: "error", Error: err.Error()})
+				continue
+			}
+		}
+		if strings.TrimSpace(req.Command) == "" {
+			ws.writeJSON(wsResponse{Type: "error", ID: req.ID, Error: "empty command"})
+			continue
+		}
+		logger.Infof("%s message received: '%s'", lmp, req.Command)
+
+		h.q.execute(req.Command, bufio.NewWriter(wsOutput{ws: ws, id: req.ID}), h.c, owner, lmp)
+		ws.writeJSON(wsResponse{Type: "done", ID: req.ID})
+	}
+}
+
+// wsOutput sends everything written to it as command output to a WebSocket client.
+type wsOutput struct {
+	ws *wsConn
+	id string
+}
+
+func (o wsOutput) Write(b []byte) (int, error) {
+	if err := o.ws.writeJSON(wsResponse{Type: "output", ID: o.id, Output: string(b)}); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// webHandler returns the handler serving the web UI, the WebSocket API and the health endpoints. The /health endpoint
+// predates /readyz and is kept as an alias.
+func webHandler(c *ip.Client, q *jobQueue, d *daemon) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/ws", newWsHub(c, q, conf.wsOrigins))
+	mux.Handle("/healthz", d.healthHandler(c, q, false))
+	mux.Handle("/readyz", d.healthHandler(c, q, true))
+	mux.Handle("/health", d.healthHandler(c, q, true))
+	newWebUI(c, q, conf.wsOrigins).register(mux)
+
+	return mux
+}
+
+// launchWebServer serves the web UI, the WebSocket API and the health endpoints using the given listener.
+func launchWebServer(c *ip.Client, q *jobQueue, l net.Listener, d *daemon) {
+	logger.Infof("[Web server] listening on %s...", l.Addr().String())
+	d.serveWeb(l, webHandler(c, q, d))
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wsTestClient performs the opening handshake on the given server and returns the connection.
+func wsTestClient(t *testing.T, srv *httptest.Server) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := "GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgradeWebSocket() status = %d; want %d", res.StatusCode, http.StatusSwitchingProtocols)
+	}
+	// The example from RFC 6455.
+	if got, want := res.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Fatalf("upgradeWebSocket() accept = %s; want %s", got, want)
+	}
+
+	return conn, r
+}
+
+// wsTestWrite sends a masked text frame, as clients must.
+func wsTestWrite(t *testing.T, conn net.Conn, msg string) {
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame := append([]byte{0x80 | wsText, 0x80 | byte(len(msg))}, mask...)
+	for i := 0; i < len(msg); i++ {
+		frame = append(frame, msg[i]^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wsTestRead reads a single unmasked frame sent by the server.
+func wsTestRead(t *testing.T, r *bufio.Reader) wsResponse {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		t.Fatal(err)
+	}
+	l := int(hdr[1] & 0x7F)
+	if l == 126 {
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			t.Fatal(err)
+		}
+		l = int(binary.BigEndian.Uint16(ext))
+	}
+	payload := make([]byte, l)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var res wsResponse
+	if err := json.Unmarshal(payload, &res); err != nil {
+		t.Fatalf("wsResponse unmarshal err = %s; payload %s", err, payload)
+	}
+
+	return res
+}
+
+func TestWsHub_command(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newWsHub(c, newJobQueue(0), nil))
+	defer srv.Close()
+
+	conn, r := wsTestClient(t, srv)
+	defer conn.Close()
+
+	wsTestWrite(t, conn, `{"id":"1","command":"help help"}`)
+	res := wsTestRead(t, r)
+	if res.Type != "output" || res.ID != "1" || !strings.Contains(res.Output, `"help"`) {
+		t.Errorf("ServeHTTP() got = %+v; want help output for ID 1", res)
+	}
+	if res = wsTestRead(t, r); res.Type != "done" || res.ID != "1" {
+		t.Errorf("ServeHTTP() got = %+v; want done for ID 1", res)
+	}
+
+	wsTestWrite(t, conn, "  ")
+	if res = wsTestRead(t, r); res.Type != "error" {
+		t.Errorf("ServeHTTP() got = %+v; want error", res)
+	}
+}
+
+func TestWsHub_event(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newWsHub(c, newJobQueue(0), nil)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	conn, r := wsTestClient(t, srv)
+	defer conn.Close()
+
+	// Make sure the client has been registered before queueing the event.
+	wsTestWrite(t, conn, "help help")
+	wsTestRead(t, r)
+	wsTestRead(t, r)
+
+	h.queue(&ip.GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_StoreFull, TransactionID: 7}})
+	res := wsTestRead(t, r)
+	if res.Type != "event" || res.Event == nil || res.Event.Code != "0x400a" || res.Event.TransactionID != 7 {
+		t.Errorf("queue() got = %+v; want event 0x400a", res)
+	}
+}
+
+func TestWsHub_origin(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newWsHub(c, newJobQueue(0), []string{"https://allowed.example"}))
+	defer srv.Close()
+
+	for origin, want := range map[string]int{
+		"":                        http.StatusSwitchingProtocols,
+		srv.URL:                   http.StatusSwitchingProtocols,
+		"https://allowed.example": http.StatusSwitchingProtocols,
+		"https://evil.example":    http.StatusForbidden,
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Errorf("upgradeWebSocket() origin %q status = %d; want %d", origin, res.StatusCode, want)
+		}
+	}
+}
+
+func TestWsHub_broadcast(t *testing.T) {
+	srv, conn := net.Pipe()
+	defer conn.Close()
+	h := &wsHub{clients: map[*wsConn]chan wsResponse{
+		{conn: srv, rw: bufio.NewReadWriter(bufio.NewReader(srv), bufio.NewWriter(srv))}: make(chan wsResponse),
+	}}
+
+	// Nobody empties the send queue of the client: it is disconnected rather than blocking the broadcast.
+	h.broadcast(wsResponse{Type: "event"})
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("broadcast() read error = %v; want EOF for the slow client", err)
+	}
+}
+
+func TestWebUI_captures(t *testing.T) {
+	c, err := ip.NewClient(ip.DefaultVendor, ip.DefaultIpAddress, ip.DefaultPort, "testér", "", ip.LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ui := newWebUI(c, newJobQueue(0), []string{"https://example.com"})
+	mux := http.NewServeMux()
+	ui.register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for i := 0; i < webGallerySize+2; i++ {
+		ui.addCapture([]byte{0xFF, 0xD8, byte(i)})
+	}
+
+	res, err := http.Get(srv.URL + "/api/captures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []webCapture
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(list) != webGallerySize || list[0].ID != webGallerySize+2 || list[len(list)-1].ID != 3 {
+		t.Errorf("listCaptures() got = %d captures from %d to %d; want %d from %d to 3", len(list), list[0].ID, list[len(list)-1].ID, webGallerySize, webGallerySize+2)
+	}
+
+	res, err = http.Get(srv.URL + "/api/captures/14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.Header.Get("Content-Type") != "image/jpeg" || len(img) != 3 || img[2] != 13 {
+		t.Errorf("getCapture() got = %s %v; want image/jpeg [255 216 13]", res.Header.Get("Content-Type"), img)
+	}
+
+	for _, path := range []string{"/api/captures/1", "/api/captures/x"} {
+		if res, err = http.Get(srv.URL + path); err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("getCapture() %s status = %d; want %d", path, res.StatusCode, http.StatusNotFound)
+		}
+	}
+
+	if res, err = http.Get(srv.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), "ptpip remote") {
+		t.Errorf("register() index got = %.50s; want the web UI", page)
+	}
+
+	if res, err = http.Get(srv.URL + "/api/capture"); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("capture() GET status = %d; want %d", res.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/capture", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://evil.example")
+	if res, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("capture() cross-origin POST status = %d; want %d", res.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestObjectContentType(t *testing.T) {
+	for name, want := range map[string]string{"DSCF0001.MOV": "video/quicktime", "clip.mp4": "video/mp4", "DSCF0001.JPG": "image/jpeg", "AUTPRINT.MRK": ""} {
+		if got := objectContentType(name); got != want {
+			t.Errorf("objectContentType(%s) got = %q; want %q", name, got, want)
+		}
+	}
+}
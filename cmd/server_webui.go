@@ -0,0 +1,534 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"github.com/malc0mn/ptp-ip/streamer"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webGallerySize is the number of capture previews kept in memory for the gallery of the web UI.
+const webGallerySize = 12
+
+//go:embed webui
+var webUIFiles embed.FS
+
+// webCapture is a single capture preview shown in the gallery of the web UI.
+type webCapture struct {
+	ID       int       `json:"id"`
+	Captured time.Time `json:"captured"`
+	data     []byte
+}
+
+// webProperty describes a single device property for the property controls of the web UI.
+type webProperty struct {
+	Name     string      `json:"name"`
+	Code     string      `json:"code"`
+	Value    string      `json:"value,omitempty"`
+	Raw      string      `json:"raw,omitempty"`
+	Writable bool        `json:"writable"`
+	Options  []webOption `json:"options,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// webObject describes a single folder or object on the camera for the gallery browser of the web UI.
+type webObject struct {
+	Handle   string    `json:"handle"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Folder   bool      `json:"folder"`
+	Kind     string    `json:"kind"`
+	Size     uint32    `json:"size"`
+	Captured time.Time `json:"captured"`
+}
+
+type webMetadata struct {
+	Camera      string `json:"camera"`
+	Lens        string `json:"lens"`
+	Exposure    string `json:"exposure"`
+	ISO         string `json:"iso"`
+	FocalLength string `json:"focalLength"`
+}
+
+type webOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// webUI serves the single page remote control UI together with the small JSON API it uses next to the WebSocket API.
+type webUI struct {
+	c        *ip.Client
+	q        *jobQueue
+	origins  []string
+	tree     *ip.ObjectTree
+	captures []webCapture
+	nextID   int
+	// viewers is the number of clients watching the liveview. The liveview is enabled for the first viewer and disabled
+	// again when the last one leaves, unless it was enabled using the liveview command.
+	viewers int
+	owned   bool
+	mu      sync.Mutex
+}
+
+// newWebUI returns the web UI for the given client. Browsers on other origins than the given ones are not allowed to
+// capture, see originAllowed.
+func newWebUI(c *ip.Client, q *jobQueue, origins []string) *webUI {
+	return &webUI{c: c, q: q, origins: origins, tree: c.ObjectTree(ip.AllStorages), nextID: 1}
+}
+
+// register adds the UI and its API endpoints to the given mux.
+func (ui *webUI) register(mux *http.ServeMux) {
+	files, err := fs.Sub(webUIFiles, "webui")
+	if err != nil {
+		panic(err)
+	}
+
+	mux.Handle("/", http.FileServer(http.FS(files)))
+	mux.HandleFunc("/liveview", ui.liveview)
+	mux.HandleFunc("/api/capture", ui.capture)
+	mux.HandleFunc("/api/captures", ui.listCaptures)
+	mux.HandleFunc("/api/captures/", ui.getCapture)
+	mux.HandleFunc("/api/properties", ui.properties)
+	mux.HandleFunc("/api/objects", ui.listObjects)
+	mux.HandleFunc("/api/thumbs/", ui.getThumb)
+	mux.HandleFunc("/api/metadata/", ui.getMetadata)
+	mux.HandleFunc("/api/stream/", ui.streamObject)
+}
+
+// capture releases the shutter and adds the preview returned by the camera, if any, to the gallery. Browsers send a
+// cross-origin form POST without asking, so the origin is checked to keep another web site from releasing the shutter.
+func (ui *webUI) capture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !originAllowed(r, ui.origins) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	var (
+		img []byte
+		err error
+	)
+	if !ui.submit(w, r, func() {
+		img, err = ui.c.InitiateCapture()
+	}) {
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	res := struct {
+		ID int `json:"id,omitempty"`
+	}{}
+	if len(img) > 0 {
+		res.ID = ui.addCapture(img)
+	}
+	// The capture has been stored on the card, so the folders must be read again.
+	ui.tree.Reset()
+
+	writeJSON(w, res)
+}
+
+func (ui *webUI) addCapture(img []byte) int {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	id := ui.nextID
+	ui.nextID++
+	ui.captures = append(ui.captures, webCapture{ID: id, Captured: time.Now(), data: img})
+	if len(ui.captures) > webGallerySize {
+		ui.captures = ui.captures[len(ui.captures)-webGallerySize:]
+	}
+
+	return id
+}
+
+// listCaptures returns the captures in the gallery, most recent first.
+func (ui *webUI) listCaptures(w http.ResponseWriter, _ *http.Request) {
+	ui.mu.Lock()
+	list := make([]webCapture, len(ui.captures))
+	for i, c := range ui.captures {
+		list[len(list)-1-i] = c
+	}
+	ui.mu.Unlock()
+
+	writeJSON(w, list)
+}
+
+// getCapture serves the preview of a single capture as found at /api/captures/{id}.
+func (ui *webUI) getCapture(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/captures/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ui.mu.Lock()
+	var img []byte
+	for _, c := range ui.captures {
+		if c.ID == id {
+			img = c.data
+		}
+	}
+	ui.mu.Unlock()
+
+	if img == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(img)
+}
+
+// listObjects returns the contents of the folder on the camera given by the path query parameter, the root folder by
+// default. The folders are read from the camera when first browsed, use the refresh query parameter to read them again.
+func (ui *webUI) listObjects(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("refresh") {
+		ui.tree.Reset()
+	}
+
+	var (
+		children []*ip.ObjectNode
+		err      error
+	)
+	if !ui.submit(w, r, func() {
+		var n *ip.ObjectNode
+		if n, err = ui.tree.Lookup(r.URL.Query().Get("path")); err == nil {
+			children, err = n.Children()
+		}
+	}) {
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	list := make([]webObject, 0, len(children))
+	for _, n := range children {
+		list = append(list, webObject{
+			Handle:   ptpfmt.ConvertToHexString(n.Handle),
+			Name:     n.Name(),
+			Path:     n.Path(),
+			Folder:   n.IsFolder(),
+			Kind:     string(ip.KindOfObject(n.Info)),
+			Size:     n.Info.ObjectCompressedSize,
+			Captured: n.Info.CaptureDate,
+		})
+	}
+
+	writeJSON(w, list)
+}
+
+// getThumb serves the thumbnail of the object found at /api/thumbs/{handle}.
+func (ui *webUI) getThumb(w http.ResponseWriter, r *http.Request) {
+	h, err := parseObjectHandle(strings.TrimPrefix(r.URL.Path, "/api/thumbs/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var thumb []byte
+	if !ui.submit(w, r, func() {
+		thumb, err = ui.c.GetThumb(h)
+	}) {
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(thumb)
+}
+
+// getMetadata returns the EXIF metadata of the image found at /api/metadata/{handle}, reading only the start of the
+// image from the camera.
+func (ui *webUI) getMetadata(w http.ResponseWriter, r *http.Request) {
+	h, err := parseObjectHandle(strings.TrimPrefix(r.URL.Path, "/api/metadata/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var md *ip.ObjectMetadata
+	if !ui.submit(w, r, func() {
+		md, err = ui.c.GetObjectMetadata(h)
+	}) {
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	f := formatMetadata(md)
+	writeJSON(w, webMetadata{Camera: f[0], Lens: f[1], Exposure: f[2], ISO: f[3], FocalLength: f[4]})
+}
+
+// streamObject serves the data of the object found at /api/stream/{handle}, honouring Range requests so that a video
+// can be played and scrubbed in the browser without downloading it first. Only the requested ranges are read from the
+// camera.
+func (ui *webUI) streamObject(w http.ResponseWriter, r *http.Request) {
+	h, err := parseObjectHandle(strings.TrimPrefix(r.URL.Path, "/api/stream/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var or *ip.ObjectReader
+	if !ui.submit(w, r, func() {
+		or, err = ui.c.OpenObject(h)
+	}) {
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	name := or.Info().Filename
+	if ct := objectContentType(name); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	http.ServeContent(w, r, name, or.Info().ModificationDate, &queuedReadSeeker{rs: or, q: ui.q, owner: webOwner(r)})
+}
+
+// videoContentTypes holds the content types of the video formats used by cameras, which are missing from the types
+// known to the mime package on most systems.
+var videoContentTypes = map[string]string{
+	".avi": "video/x-msvideo",
+	".m4v": "video/mp4",
+	".mov": "video/quicktime",
+	".mp4": "video/mp4",
+	".mts": "video/mp2t",
+}
+
+// objectContentType returns the content type of an object based on the extension of its file name. An empty string
+// is returned for unknown extensions, leaving it up to http.ServeContent to detect the content type.
+func objectContentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ct, ok := videoContentTypes[ext]; ok {
+		return ct
+	}
+
+	return mime.TypeByExtension(ext)
+}
+
+// queuedReadSeeker reads from rs using the job queue, so that reading an object from the camera does not interfere
+// with the commands of other clients.
+type queuedReadSeeker struct {
+	rs    io.ReadSeeker
+	q     *jobQueue
+	owner string
+}
+
+func (qr *queuedReadSeeker) Read(p []byte) (n int, err error) {
+	if qerr := qr.q.submit(qr.owner, func() {
+		n, err = qr.rs.Read(p)
+	}); qerr != nil {
+		return 0, qerr
+	}
+
+	return n, err
+}
+
+func (qr *queuedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return qr.rs.Seek(offset, whence)
+}
+
+// properties describes the properties that can be addressed using their unified field name. Changing a property is
+// done using the set command over the WebSocket API.
+func (ui *webUI) properties(w http.ResponseWriter, r *http.Request) {
+	var props []webProperty
+	if !ui.submit(w, r, func() {
+		props = ui.describeProperties()
+	}) {
+		return
+	}
+
+	writeJSON(w, props)
+}
+
+// describeProperties describes the properties that can be addressed using their unified field name.
+func (ui *webUI) describeProperties() []webProperty {
+	v := ui.c.ResponderVendor()
+
+	props := make([]webProperty, 0, len(ptpfmt.UnifiedFieldNames))
+	for _, name := range ptpfmt.UnifiedFieldNames {
+		p := webProperty{Name: name}
+
+		cod, err := ptpfmt.PropNameToDevicePropCode(v, name)
+		if err != nil {
+			continue
+		}
+		p.Code = ptpfmt.ConvertToHexString(cod)
+
+		dpd, err := ui.c.GetDevicePropertyDescription(cod)
+		if err != nil || dpd == nil {
+			if err == nil {
+				err = fmt.Errorf("cannot describe property %#x", cod)
+			}
+			p.Error = err.Error()
+			props = append(props, p)
+			continue
+		}
+
+		cur := dpd.CurrentValueAsInt64()
+		p.Value = ptpfmt.DevicePropValAsString(v, cod, cur)
+		p.Raw = ptpfmt.ConvertToHexString(cur)
+		p.Writable = dpd.GetSet == ptp.DPD_GetSet
+		if form, ok := dpd.Form.(*ptp.EnumerationForm); ok {
+			for _, val := range form.SupportedValuesAsInt64Array() {
+				p.Options = append(p.Options, webOption{
+					Value: ptpfmt.ConvertToHexString(val),
+					Label: ptpfmt.DevicePropValAsString(v, cod, val),
+				})
+			}
+		}
+
+		props = append(props, p)
+	}
+
+	return props
+}
+
+// liveview serves the liveview frames as an MJPEG stream until the client goes away or the liveview is disabled.
+func (ui *webUI) liveview(w http.ResponseWriter, r *http.Request) {
+	lmp := "[Web UI]"
+	if err := ui.addViewer(webOwner(r)); err != nil {
+		logger.Errorf("%s liveview error: %s", lmp, err)
+		writeJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+	defer ui.removeViewer()
+
+	sub := ui.c.SubscribeLiveview(1, ip.DropOldest)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+streamer.MJPEGBoundary)
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case frame, ok := <-sub.Frames():
+			if !ok {
+				return
+			}
+			_, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", streamer.MJPEGBoundary, len(frame.Data))
+			if err == nil {
+				_, err = w.Write(frame.Data)
+			}
+			if err == nil {
+				_, err = w.Write([]byte("\r\n"))
+			}
+			frame.Release()
+			if err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func (ui *webUI) addViewer(owner string) error {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.viewers == 0 && lvStreams == nil {
+		var err error
+		if qerr := ui.q.submit(owner, func() {
+			err = ui.c.ToggleLiveView(true)
+		}); qerr != nil {
+			return qerr
+		}
+		if err != nil {
+			return err
+		}
+		ui.owned = true
+	}
+	ui.viewers++
+
+	return nil
+}
+
+func (ui *webUI) removeViewer() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.viewers--
+	if ui.viewers == 0 && ui.owned {
+		ui.owned = false
+		var err error
+		if qerr := ui.q.submit(webUIOwner, func() {
+			err = ui.c.ToggleLiveView(false)
+		}); qerr != nil {
+			err = qerr
+		}
+		if err != nil {
+			logger.Errorf("[Web UI] liveview error: %s", err)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Errorf("[Web UI] error writing response: %s", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+// webUIOwner identifies the web UI itself as a client of the job queue, for the jobs it does not do on behalf of a
+// request.
+const webUIOwner = "web"
+
+// submit executes the work on behalf of the client of the request using the job queue. It replies with an error and
+// returns false when the work timed out waiting for its turn.
+func (ui *webUI) submit(w http.ResponseWriter, r *http.Request, run func()) bool {
+	if err := ui.q.submit(webOwner(r), run); err != nil {
+		writeJSONError(w, err, http.StatusServiceUnavailable)
+		return false
+	}
+
+	return true
+}
+
+// webOwner identifies the client of the job queue a request is handled for.
+func webOwner(r *http.Request) string {
+	return webUIOwner + " " + r.RemoteAddr
+}
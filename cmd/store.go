@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileStore is where the download and sync commands write the downloaded objects to: the local disk, S3-compatible
+// object storage or an SFTP server. Paths are relative to the root of the storage and use the separator of the OS.
+type fileStore interface {
+	// stat returns the size of the file at the given path, or an error wrapping fs.ErrNotExist when there is none.
+	stat(p string) (int64, error)
+	// read returns the content of the file at the given path.
+	read(p string) ([]byte, error)
+	// write writes the file at the given path, creating the directories leading up to it when needed. An interrupted
+	// write must not leave a partial file behind under the path, since it would look complete to the next sync.
+	write(p string, data []byte) error
+	// location returns where the file at the given path is stored: an absolute path for the local disk, a URL
+	// otherwise.
+	location(p string) string
+}
+
+// openStore returns the store for the directory argument of the download commands. A URL using the s3 or sftp
+// scheme selects the store of that name, anything else is a directory on the local disk, which is created when it
+// does not exist.
+func openStore(dir string) (fileStore, error) {
+	if scheme, _, found := strings.Cut(dir, "://"); found {
+		switch scheme {
+		case "s3":
+			return newS3Store(dir)
+		case "sftp":
+			return newSFTPStore(dir)
+		}
+		return nil, fmt.Errorf("unsupported storage %s, must be a directory or an s3:// or sftp:// URL", dir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return localStore{dir: dir}, nil
+}
+
+// localStore stores the files in a directory on the local disk.
+type localStore struct {
+	dir string
+}
+
+func (s localStore) path(p string) string {
+	return filepath.Join(s.dir, p)
+}
+
+func (s localStore) stat(p string) (int64, error) {
+	fi, err := os.Stat(s.path(p))
+	if err != nil {
+		return 0, err
+	}
+
+	return fi.Size(), nil
+}
+
+func (s localStore) read(p string) ([]byte, error) {
+	return os.ReadFile(s.path(p))
+}
+
+// write writes to a temporary file first, which is renamed once complete.
+func (s localStore) write(p string, data []byte) error {
+	path := s.path(p)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".part", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".part", path)
+}
+
+func (s localStore) location(p string) string {
+	path := s.path(p)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return path
+}
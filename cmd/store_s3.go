@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// s3DefaultRegion is the region used when none is configured.
+	s3DefaultRegion = "us-east-1"
+	// s3Timeout is the time a single request to the object storage may take.
+	s3Timeout = 5 * time.Minute
+)
+
+// s3Store stores the files in a bucket of S3-compatible object storage, such as Amazon S3 or MinIO, using the URL
+// s3://bucket/prefix. The credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and optionally the
+// AWS_SESSION_TOKEN environment variables. The region is read from AWS_REGION or the region query parameter of the
+// URL. The endpoint defaults to the one of Amazon S3 for the region and is set using AWS_ENDPOINT_URL or the endpoint
+// query parameter of the URL, e.g. s3://photos/studio?endpoint=http://localhost:9000 for MinIO. Objects are addressed
+// in path style, which all S3-compatible object storage supports.
+type s3Store struct {
+	endpoint     string
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+func newS3Store(rawURL string) (*s3Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid storage %s: missing bucket", rawURL)
+	}
+
+	s := &s3Store{
+		endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+		bucket:       u.Host,
+		prefix:       strings.Trim(u.Path, "/"),
+		region:       os.Getenv("AWS_REGION"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: s3Timeout},
+	}
+	q := u.Query()
+	if v := q.Get("region"); v != "" {
+		s.region = v
+	}
+	if s.region == "" {
+		s.region = s3DefaultRegion
+	}
+	if v := q.Get("endpoint"); v != "" {
+		s.endpoint = v
+	}
+	if s.endpoint == "" {
+		s.endpoint = "https://s3." + s.region + ".amazonaws.com"
+	}
+	s.endpoint = strings.TrimSuffix(s.endpoint, "/")
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("the s3 storage requires the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables")
+	}
+
+	return s, nil
+}
+
+// key returns the key of the object holding the file at the given path.
+func (s *s3Store) key(p string) string {
+	return path.Join(s.prefix, filepath.ToSlash(p))
+}
+
+func (s *s3Store) stat(p string) (int64, error) {
+	res, err := s.do(http.MethodHead, p, nil)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return res.ContentLength, nil
+}
+
+func (s *s3Store) read(p string) ([]byte, error) {
+	res, err := s.do(http.MethodGet, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
+}
+
+// write uploads the file in a single request. Object storage only makes an object visible once it is complete, so an
+// interrupted upload does not leave a partial file behind.
+func (s *s3Store) write(p string, data []byte) error {
+	res, err := s.do(http.MethodPut, p, data)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (s *s3Store) location(p string) string {
+	return "s3://" + s.bucket + "/" + s.key(p)
+}
+
+// do sends a signed request for the object holding the file at the given path. An error wrapping fs.ErrNotExist is
+// returned when the object does not exist, an error holding the status for any other unsuccessful response.
+func (s *s3Store) do(method, p string, body []byte) (*http.Response, error) {
+	uri := "/" + s3Escape(s.bucket, false) + "/" + s3Escape(s.key(p), true)
+	req, err := http.NewRequest(method, s.endpoint+uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	sum := sha256.Sum256(body)
+	req.Header.Set("x-amz-content-sha256", hex.EncodeToString(sum[:]))
+	if s.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.sessionToken)
+	}
+	signS3Request(req, uri, s.region, s.accessKey, s.secretKey, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", s.location(p), fs.ErrNotExist)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+
+	return nil, fmt.Errorf("%s %s: %s %s", method, s.location(p), res.Status, bytes.TrimSpace(msg))
+}
+
+// signS3Request adds the x-amz-date and the Authorization headers to the request using AWS Signature Version 4. The
+// x-amz-content-sha256 header must have been set. The canonical URI is passed as is, since the escaping of the URL of
+// the request does not match the one S3 expects. The host, the range and all x-amz headers are signed.
+func signS3Request(req *http.Request, uri, region, accessKey, secretKey string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n" + uri + "\n" + s3CanonicalQuery(req.URL.Query()) + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + req.Header.Get("x-amz-content-sha256"))
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+",SignedHeaders="+signed+",Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// s3CanonicalQuery returns the query parameters sorted by name and escaped the way S3 expects.
+func s3CanonicalQuery(q url.Values) string {
+	var params []string
+	for name, values := range q {
+		for _, v := range values {
+			params = append(params, s3Escape(name, false)+"="+s3Escape(v, false))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// s3Escape escapes all characters but the unreserved characters of RFC 3986 and, when keepSlash is true, the slash.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) != -1 || c == '/' && keepSlash {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
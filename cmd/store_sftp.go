@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sftpClient is the OpenSSH client used by the sftp storage.
+const sftpClient = "sftp"
+
+// sftpStore stores the files on an SFTP server using the URL sftp://user@host:port/path, the path being absolute.
+// The transfers are done by the OpenSSH sftp client in batch mode, which means that it authenticates using the SSH
+// keys and the SSH config of the user: there is no way to enter a password.
+type sftpStore struct {
+	// target is the destination passed to the sftp client, i.e. user@host.
+	target string
+	port   string
+	dir    string
+}
+
+func newSFTPStore(rawURL string) (*sftpStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid storage %s: missing host", rawURL)
+	}
+	if _, err := exec.LookPath(sftpClient); err != nil {
+		return nil, fmt.Errorf("the sftp storage requires the OpenSSH %s client: %w", sftpClient, err)
+	}
+
+	s := &sftpStore{target: u.Hostname(), port: u.Port(), dir: path.Clean("/" + u.Path)}
+	if u.User != nil {
+		s.target = u.User.Username() + "@" + s.target
+	}
+
+	// Create the directory, ignoring the errors for the parts that exist.
+	if _, err := s.batch(sftpMkdirs(s.dir)...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// path returns the path on the server of the file at the given path.
+func (s *sftpStore) path(p string) string {
+	return path.Join(s.dir, filepath.ToSlash(p))
+}
+
+func (s *sftpStore) stat(p string) (int64, error) {
+	out, err := s.batch("ls -ln " + sftpQuote(s.path(p)))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return 0, fmt.Errorf("%s: %w", s.location(p), fs.ErrNotExist)
+		}
+		return 0, err
+	}
+
+	return parseSFTPSize(out)
+}
+
+func (s *sftpStore) read(p string) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "ptpip-sftp-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if _, err := s.batch("get " + sftpQuote(s.path(p)) + " " + sftpQuote(tmp.Name())); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, fmt.Errorf("%s: %w", s.location(p), fs.ErrNotExist)
+		}
+		return nil, err
+	}
+
+	return os.ReadFile(tmp.Name())
+}
+
+// write uploads the file to a temporary file first, which is renamed once complete.
+func (s *sftpStore) write(p string, data []byte) error {
+	tmp, err := os.CreateTemp("", "ptpip-sftp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	remote := s.path(p)
+	var cmds []string
+	if dir := path.Dir(remote); dir != s.dir {
+		cmds = sftpMkdirs(dir)
+	}
+	cmds = append(cmds,
+		"put "+sftpQuote(tmp.Name())+" "+sftpQuote(remote+".part"),
+		"rename "+sftpQuote(remote+".part")+" "+sftpQuote(remote),
+	)
+	_, err = s.batch(cmds...)
+
+	return err
+}
+
+func (s *sftpStore) location(p string) string {
+	host := s.target
+	if s.port != "" {
+		host += ":" + s.port
+	}
+
+	return "sftp://" + host + s.path(p)
+}
+
+// batch runs the commands using the sftp client in batch mode, returning the output. The client stops at the first
+// command failing, unless it is prefixed with a dash.
+func (s *sftpStore) batch(cmds ...string) (string, error) {
+	args := []string{"-q", "-b", "-"}
+	if s.port != "" {
+		args = append(args, "-P", s.port)
+	}
+	args = append(args, s.target)
+
+	cmd := exec.Command(sftpClient, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(cmds, "\n") + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w: %s", sftpClient, s.target, err, bytes.TrimSpace(out))
+	}
+
+	return string(out), nil
+}
+
+// sftpMkdirs returns the commands creating the directory and its parents, ignoring the errors for the ones that exist.
+func sftpMkdirs(dir string) []string {
+	var cmds []string
+	for d := dir; d != "/" && d != "."; d = path.Dir(d) {
+		cmds = append([]string{"-mkdir " + sftpQuote(d)}, cmds...)
+	}
+
+	return cmds
+}
+
+// sftpQuote quotes the path for the sftp client.
+func sftpQuote(p string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p) + `"`
+}
+
+// parseSFTPSize returns the size of the file listed by the ls -ln command of the sftp client. The commands echoed by
+// the client in batch mode are skipped.
+func parseSFTPSize(out string) (int64, error) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || strings.HasPrefix(line, "sftp>") {
+			continue
+		}
+		if size, err := strconv.ParseInt(fields[4], 10, 64); err == nil {
+			return size, nil
+		}
+	}
+
+	return 0, errors.New("unexpected sftp ls output: " + strings.TrimSpace(out))
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color/palette"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	termImageAuto   termImageProtocol = "auto"
+	termImageSixel  termImageProtocol = "sixel"
+	termImageITerm2 termImageProtocol = "iterm2"
+	termImageKitty  termImageProtocol = "kitty"
+
+	// previewFlag is the argument making a command render its image in the terminal.
+	previewFlag = "--preview"
+	// termImageMaxSize is the maximum width and height in pixels of an image rendered in the terminal, larger images are
+	// scaled down.
+	termImageMaxSize = 640
+	// kittyChunkSize is the maximum size of the base64 encoded data sent in a single kitty graphics escape sequence.
+	kittyChunkSize = 4096
+)
+
+var (
+	termImageProtocolUnknown     = errors.New("unknown terminal image protocol")
+	termImageProtocolUnsupported = errors.New("the terminal does not seem to support images, use the -preview-protocol flag to select a protocol")
+
+	previewProtocol = termImageAuto
+)
+
+// termImageProtocol is the protocol used to render images in the terminal.
+type termImageProtocol string
+
+// Set implements the flag.Value interface.
+func (tp *termImageProtocol) Set(s string) error {
+	switch termImageProtocol(s) {
+	case termImageAuto, termImageSixel, termImageITerm2, termImageKitty:
+		*tp = termImageProtocol(s)
+	default:
+		return termImageProtocolUnknown
+	}
+
+	return nil
+}
+
+// String implements the flag.Value interface.
+func (tp *termImageProtocol) String() string {
+	return string(*tp)
+}
+
+// detectTermImageProtocol guesses the image protocol supported by the terminal using the environment variables. Most
+// of these are passed on by SSH or set by the terminal itself, so detection also works over SSH. An empty protocol is
+// returned when the terminal is not known to support images.
+func detectTermImageProtocol(getenv func(string) string) termImageProtocol {
+	term, prog := getenv("TERM"), getenv("TERM_PROGRAM")
+
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", prog == "ghostty":
+		return termImageKitty
+	case prog == "iTerm.app", getenv("LC_TERMINAL") == "iTerm2", prog == "WezTerm":
+		return termImageITerm2
+	case strings.Contains(term, "sixel"), strings.HasPrefix(term, "foot"), strings.HasPrefix(term, "mlterm"),
+		term == "yaft-256color", prog == "contour":
+		return termImageSixel
+	}
+
+	return ""
+}
+
+// hasPreviewFlag removes the --preview argument from the command arguments and returns true when it was present.
+func hasPreviewFlag(f []string) ([]string, bool) {
+	return hasFlag(f, previewFlag)
+}
+
+// termImage returns the escape sequences rendering the JPEG or PNG image data in the terminal, using the protocol set
+// with the -preview-protocol flag.
+func termImage(data []byte) (string, error) {
+	proto := previewProtocol
+	if proto == termImageAuto {
+		if proto = detectTermImageProtocol(os.Getenv); proto == "" {
+			return "", termImageProtocolUnsupported
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := writeTermImage(&b, proto, fitImage(img, termImageMaxSize)); err != nil {
+		return "", err
+	}
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
+
+// fitImage scales the image down to fit within a square of the given size, keeping the aspect ratio.
+func fitImage(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	if w > h {
+		w, h = size, h*size/w
+	} else {
+		w, h = w*size/h, size
+	}
+	// Extremely narrow images keep at least one pixel.
+	if w == 0 {
+		w = 1
+	}
+	if h == 0 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	return dst
+}
+
+// writeTermImage writes the escape sequences rendering the image in the terminal using the given protocol to w.
+func writeTermImage(w io.Writer, proto termImageProtocol, img image.Image) error {
+	switch proto {
+	case termImageSixel:
+		return writeSixel(w, img)
+	case termImageITerm2:
+		return writeITerm2(w, img)
+	case termImageKitty:
+		return writeKitty(w, img)
+	}
+
+	return termImageProtocolUnknown
+}
+
+// writeSixel writes the image using the DEC sixel graphics format. The image is dithered to the 216 colours of the web
+// safe palette, since sixel terminals support 256 colour registers at most.
+func writeSixel(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette.WebSafe)
+	draw.FloydSteinberg.Draw(p, p.Rect, img, b.Min)
+	width, height := p.Rect.Dx(), p.Rect.Dy()
+
+	bw := bufio.NewWriter(w)
+	// Enter sixel mode using a 1:1 pixel aspect ratio and set the size of the image.
+	fmt.Fprintf(bw, "\x1bP0;1;0q\"1;1;%d;%d", width, height)
+	for i, c := range p.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	// Each sixel holds a column of six pixels, so the image is written in bands of six rows. Each colour used in a band
+	// is drawn in a separate pass over the band.
+	for y := 0; y < height; y += 6 {
+		used := make(map[uint8]bool)
+		var colours []uint8
+		for x := 0; x < width; x++ {
+			for r := 0; r < 6 && y+r < height; r++ {
+				if ci := p.ColorIndexAt(x, y+r); !used[ci] {
+					used[ci] = true
+					colours = append(colours, ci)
+				}
+			}
+		}
+
+		for i, ci := range colours {
+			if i > 0 {
+				// Return to the start of the band.
+				bw.WriteByte('$')
+			}
+			fmt.Fprintf(bw, "#%d", ci)
+
+			var (
+				prev byte
+				run  int
+			)
+			for x := 0; x <= width; x++ {
+				var s byte
+				if x < width {
+					bits := 0
+					for r := 0; r < 6 && y+r < height; r++ {
+						if p.ColorIndexAt(x, y+r) == ci {
+							bits |= 1 << r
+						}
+					}
+					s = byte(63 + bits)
+				}
+				if s == prev {
+					run++
+					continue
+				}
+				writeSixelRun(bw, prev, run)
+				prev, run = s, 1
+			}
+		}
+		// Move on to the next band.
+		bw.WriteByte('-')
+	}
+	bw.WriteString("\x1b\\")
+
+	return bw.Flush()
+}
+
+// writeSixelRun writes a sixel repeated run times, using the repeat introducer when that is shorter.
+func writeSixelRun(w *bufio.Writer, s byte, run int) {
+	switch {
+	case run == 0:
+	case run > 3:
+		fmt.Fprintf(w, "!%d%c", run, s)
+	default:
+		for i := 0; i < run; i++ {
+			w.WriteByte(s)
+		}
+	}
+}
+
+// writeITerm2 writes the image using the inline images protocol of iTerm2, which WezTerm supports as well.
+func writeITerm2(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a", buf.Len(), base64.StdEncoding.EncodeToString(buf.Bytes()))
+
+	return err
+}
+
+// writeKitty writes the image as PNG data using the kitty graphics protocol, which requires the data to be sent in
+// chunks.
+func writeKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data
+		if len(chunk) > kittyChunkSize {
+			chunk = chunk[:kittyChunkSize]
+		}
+		data = data[len(chunk):]
+
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		ctrl := fmt.Sprintf("m=%d", more)
+		if first {
+			// Transmit and display the image in PNG format.
+			ctrl = "a=T,f=100," + ctrl
+		}
+		if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", ctrl, chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / w), B: uint8(y * 255 / h), A: 0xff})
+		}
+	}
+
+	return img
+}
+
+func TestTermImageProtocol_Set(t *testing.T) {
+	var tp termImageProtocol
+	if err := tp.Set("kitty"); err != nil || tp != termImageKitty {
+		t.Errorf("Set() got = %s, %v; want %s, <nil>", tp, err, termImageKitty)
+	}
+	if err := tp.Set("ascii"); err != termImageProtocolUnknown {
+		t.Errorf("Set() error = %v; want %s", err, termImageProtocolUnknown)
+	}
+}
+
+func TestDetectTermImageProtocol(t *testing.T) {
+	check := []struct {
+		env  map[string]string
+		want termImageProtocol
+	}{
+		{map[string]string{"TERM": "xterm-kitty"}, termImageKitty},
+		{map[string]string{"TERM": "xterm-256color", "KITTY_WINDOW_ID": "1"}, termImageKitty},
+		{map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app"}, termImageITerm2},
+		{map[string]string{"TERM": "xterm-256color", "LC_TERMINAL": "iTerm2"}, termImageITerm2},
+		{map[string]string{"TERM": "foot"}, termImageSixel},
+		{map[string]string{"TERM": "xterm-256color"}, ""},
+		{map[string]string{}, ""},
+	}
+
+	for _, tt := range check {
+		got := detectTermImageProtocol(func(k string) string { return tt.env[k] })
+		if got != tt.want {
+			t.Errorf("detectTermImageProtocol() env %v got = '%s'; want '%s'", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestHasPreviewFlag(t *testing.T) {
+	f, got := hasPreviewFlag([]string{"2", "--preview", "/tmp/img.jpg"})
+	if !got || strings.Join(f, " ") != "2 /tmp/img.jpg" {
+		t.Errorf("hasPreviewFlag() got = %v, %t; want [2 /tmp/img.jpg], true", f, got)
+	}
+
+	f, got = hasPreviewFlag([]string{"iso"})
+	if got || len(f) != 1 {
+		t.Errorf("hasPreviewFlag() got = %v, %t; want [iso], false", f, got)
+	}
+}
+
+func TestFitImage(t *testing.T) {
+	check := []struct {
+		w, h         int
+		wantW, wantH int
+	}{
+		{160, 120, 160, 120},
+		{1280, 960, 640, 480},
+		{960, 1280, 480, 640},
+		{2000, 2, 640, 1},
+	}
+
+	for _, tt := range check {
+		got := fitImage(testImage(tt.w, tt.h), termImageMaxSize).Bounds()
+		if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+			t.Errorf("fitImage() %dx%d got = %dx%d; want %dx%d", tt.w, tt.h, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestWriteSixel(t *testing.T) {
+	var b strings.Builder
+	if err := writeSixel(&b, testImage(16, 8)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := b.String()
+	if !strings.HasPrefix(got, "\x1bP0;1;0q\"1;1;16;8#0;2;") || !strings.HasSuffix(got, "\x1b\\") {
+		t.Errorf("writeSixel() got = %q; want a sixel sequence of 16x8 pixels", got)
+	}
+	// Two bands of six rows.
+	if n := strings.Count(got, "-"); n != 2 {
+		t.Errorf("writeSixel() bands = %d; want 2", n)
+	}
+}
+
+func TestWriteSixelRun(t *testing.T) {
+	var buf bytes.Buffer
+	check := map[int]string{0: "", 1: "~", 3: "~~~", 4: "!4~", 120: "!120~"}
+
+	for run, want := range check {
+		buf.Reset()
+		w := bufio.NewWriter(&buf)
+		writeSixelRun(w, '~', run)
+		w.Flush()
+		if got := buf.String(); got != want {
+			t.Errorf("writeSixelRun() run %d got = %q; want %q", run, got, want)
+		}
+	}
+}
+
+func TestWriteKitty(t *testing.T) {
+	var b strings.Builder
+	// A noisy image to get a PNG larger than a single chunk.
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	if err := writeKitty(&b, img); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := strings.Split(strings.TrimSuffix(b.String(), "\x1b\\"), "\x1b\\")
+	if len(chunks) < 2 {
+		t.Fatalf("writeKitty() chunks = %d; want at least 2", len(chunks))
+	}
+	if !strings.HasPrefix(chunks[0], "\x1b_Ga=T,f=100,m=1;") {
+		t.Errorf("writeKitty() first chunk starts with %q; want \\x1b_Ga=T,f=100,m=1;", chunks[0][:20])
+	}
+	if last := chunks[len(chunks)-1]; !strings.HasPrefix(last, "\x1b_Gm=0;") {
+		t.Errorf("writeKitty() last chunk starts with %q; want \\x1b_Gm=0;", last[:10])
+	}
+}
+
+func TestTermImage(t *testing.T) {
+	defer func() { previewProtocol = termImageAuto }()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(8, 8)); err != nil {
+		t.Fatal(err)
+	}
+
+	previewProtocol = termImageITerm2
+	got, err := termImage(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "\x1b]1337;File=inline=1;") || !strings.HasSuffix(got, "\a\n") {
+		t.Errorf("termImage() got = %q; want an iTerm2 inline image", got)
+	}
+
+	if _, err := termImage([]byte("not an image")); err == nil {
+		t.Errorf("termImage() error = <nil>; want error")
+	}
+}
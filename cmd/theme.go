@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/viewfinder"
+	"golang.org/x/image/font/basicfont"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// themeKeys lists the viewfinder theme settings that can be changed, both in the config file and at runtime.
+var themeKeys = []string{"colour", "warning", "highlight", "inactive", "text_face", "glyphs", "scale"}
+
+// themeSetting holds the viewfinder theme, which can be changed while the live view is running. The generation is
+// incremented on each change so the live view knows when to rebuild the viewfinder.
+type themeSetting struct {
+	theme viewfinder.Theme
+	gen   uint64
+	mu    sync.Mutex
+}
+
+var vfTheme = &themeSetting{theme: viewfinder.DefaultTheme()}
+
+// get returns the current theme and its generation.
+func (s *themeSetting) get() (viewfinder.Theme, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.theme, s.gen
+}
+
+// set changes a single theme setting, key being one of themeKeys.
+func (s *themeSetting) set(key, val string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.theme
+	var err error
+	switch key {
+	case "colour":
+		t.Colour, err = parseColour(val)
+	case "warning":
+		t.Warning, err = parseColour(val)
+	case "highlight":
+		t.Highlight, err = parseColour(val)
+	case "inactive":
+		t.Inactive, err = parseColour(val)
+	case "text_face":
+		t.Text, err = faceByName(val)
+	case "glyphs":
+		t.Glyphs, err = faceByName(val)
+	case "scale":
+		t.Scale, err = strconv.Atoi(val)
+		if err == nil && (t.Scale < 1 || t.Scale > 8) {
+			err = errors.New("scale must lie between 1 and 8")
+		}
+	default:
+		err = fmt.Errorf("unknown theme setting %s, must be one of %s", key, strings.Join(themeKeys, ", "))
+	}
+	if err != nil {
+		return err
+	}
+
+	s.theme = t
+	s.gen++
+
+	return nil
+}
+
+// reset restores the default theme.
+func (s *themeSetting) reset() {
+	s.mu.Lock()
+	s.theme = viewfinder.DefaultTheme()
+	s.gen++
+	s.mu.Unlock()
+}
+
+// settings returns all theme settings as key/value pairs, in the order of themeKeys.
+func (s *themeSetting) settings() [][2]string {
+	t, _ := s.get()
+
+	return [][2]string{
+		{"colour", formatColour(t.Colour)},
+		{"warning", formatColour(t.Warning)},
+		{"highlight", formatColour(t.Highlight)},
+		{"inactive", formatColour(t.Inactive)},
+		{"text_face", viewfinder.FaceName(t.Text)},
+		{"glyphs", viewfinder.FaceName(t.Glyphs)},
+		{"scale", strconv.Itoa(t.Scale)},
+	}
+}
+
+// String lists all theme settings in the same format as the config file uses.
+func (s *themeSetting) String() string {
+	var res string
+	for _, kv := range s.settings() {
+		res += kv[0] + " = " + kv[1] + "\n"
+	}
+
+	return res
+}
+
+// parseColour parses a colour in the #rrggbb hexadecimal notation. The leading # is optional.
+func parseColour(s string) (color.RGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %s, use the #rrggbb notation", s)
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+func formatColour(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func faceByName(name string) (*basicfont.Face, error) {
+	if f, ok := viewfinder.FaceByName(name); ok {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("unknown font face %s, must be one of %s", name, strings.Join(viewfinder.FaceNames(), ", "))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/viewfinder"
+	"golang.org/x/image/font/basicfont"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestThemeSetting_set(t *testing.T) {
+	s := &themeSetting{theme: viewfinder.DefaultTheme()}
+
+	check := map[string]string{
+		"colour":    "#00ff00",
+		"inactive":  "404040",
+		"text_face": "7x13",
+		"glyphs":    "vfglyphs6x13",
+		"scale":     "3",
+	}
+	for key, val := range check {
+		if err := s.set(key, val); err != nil {
+			t.Errorf("set(%s, %s) err = %s; want <nil>", key, val, err)
+		}
+	}
+
+	got, gen := s.get()
+	if gen != uint64(len(check)) {
+		t.Errorf("set() gen = %d; want %d", gen, len(check))
+	}
+	if want := (color.RGBA{G: 255, A: 255}); got.Colour != want {
+		t.Errorf("set() Colour = %v; want %v", got.Colour, want)
+	}
+	if want := (color.RGBA{R: 64, G: 64, B: 64, A: 255}); got.Inactive != want {
+		t.Errorf("set() Inactive = %v; want %v", got.Inactive, want)
+	}
+	if got.Text != basicfont.Face7x13 || got.Glyphs != viewfinder.VFGlyphs6x13 {
+		t.Error("set() faces not set")
+	}
+	if got.Scale != 3 {
+		t.Errorf("set() Scale = %d; want 3", got.Scale)
+	}
+	if !strings.Contains(s.String(), "colour = #00ff00\n") {
+		t.Errorf("String() got = %s; want it to contain colour = #00ff00", s.String())
+	}
+
+	s.reset()
+	if got, _ := s.get(); got != viewfinder.DefaultTheme() {
+		t.Errorf("reset() got = %v; want %v", got, viewfinder.DefaultTheme())
+	}
+}
+
+func TestThemeSetting_setInvalid(t *testing.T) {
+	s := &themeSetting{theme: viewfinder.DefaultTheme()}
+
+	check := [][2]string{
+		{"colour", "#00ff"},
+		{"warning", "red"},
+		{"text_face", "does-not-exist"},
+		{"scale", "0"},
+		{"scale", "big"},
+		{"nope", "1"},
+	}
+	for _, c := range check {
+		if err := s.set(c[0], c[1]); err == nil {
+			t.Errorf("set(%s, %s) err = <nil>; want error", c[0], c[1])
+		}
+	}
+	if _, gen := s.get(); gen != 0 {
+		t.Errorf("set() gen = %d; want 0", gen)
+	}
+}
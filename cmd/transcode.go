@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// previewSize is the default size of the longest side of the images resized by the resize transcoder.
+const previewSize = 1920
+
+var (
+	transcodersMu sync.RWMutex
+	// transcoders maps the name of each transcoder to the transcoder, which the download and sync commands apply to the
+	// objects when asked to using the transcode argument.
+	transcoders = make(map[string]transcoder)
+	// execTranscoders maps the name of each transcoder defined in the config file to its shell command. Guarded by
+	// transcodersMu.
+	execTranscoders = make(map[string]string)
+)
+
+func init() {
+	registerTranscoder("resize", jpegResizer{})
+}
+
+// transcodeFile is an object that has been downloaded but not stored yet.
+type transcodeFile struct {
+	// Name is the file name the object is stored under. Its extension follows the format of the data.
+	Name   string
+	Data   []byte
+	Object ip.Object
+	// Vendor is the vendor of the camera the object was downloaded from.
+	Vendor string
+}
+
+// transcoder converts a downloaded object before it is stored, e.g. to resize it or to convert it to another format.
+type transcoder interface {
+	// transcode returns the converted file, or the file as is when the transcoder does not apply to it. The argument is
+	// the text following the colon in transcode=name:arg, which is empty when there is none.
+	transcode(f transcodeFile, arg string) (transcodeFile, error)
+}
+
+// registerTranscoder adds a transcoder that can be applied to the objects downloaded using transcode=name.
+func registerTranscoder(name string, t transcoder) {
+	transcodersMu.Lock()
+	defer transcodersMu.Unlock()
+	if t == nil {
+		panic("cmd: registerTranscoder transcoder is nil")
+	}
+	if _, dup := transcoders[name]; dup {
+		panic("cmd: registerTranscoder called twice for transcoder " + name)
+	}
+
+	transcoders[name] = t
+}
+
+// checkExecTranscoder returns an error when the transcoder running a shell command cannot be defined.
+func checkExecTranscoder(name, command string) error {
+	if name == "" || strings.ContainsAny(name, ":,") || strings.TrimSpace(command) == "" {
+		return fmt.Errorf("invalid transcoder %q: a name without colons or commas and a command are required", name)
+	}
+
+	transcodersMu.RLock()
+	defer transcodersMu.RUnlock()
+	if _, dup := transcoders[name]; dup && execTranscoders[name] == "" {
+		return fmt.Errorf("%s is a built-in transcoder, it cannot be redefined", name)
+	}
+
+	return nil
+}
+
+// setExecTranscoders replaces all transcoders running a shell command by the given ones, which must have been checked
+// using checkExecTranscoder.
+func setExecTranscoders(defs map[string]string) {
+	transcodersMu.Lock()
+	defer transcodersMu.Unlock()
+
+	for name := range execTranscoders {
+		if _, ok := defs[name]; !ok {
+			delete(transcoders, name)
+			delete(execTranscoders, name)
+		}
+	}
+	for name, command := range defs {
+		execTranscoders[name] = command
+		transcoders[name] = execTranscoder(command)
+	}
+}
+
+// transcoderNames returns the names of all registered transcoders, sorted alphabetically.
+func transcoderNames() []string {
+	transcodersMu.RLock()
+	defer transcodersMu.RUnlock()
+
+	names := make([]string, 0, len(transcoders))
+	for name := range transcoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// execTranscoderValues returns the transcoders defined in the config file as config values, sorted by name.
+func execTranscoderValues() []configValue {
+	var values []configValue
+	for _, name := range transcoderNames() {
+		transcodersMu.RLock()
+		command, ok := execTranscoders[name]
+		transcodersMu.RUnlock()
+		if ok {
+			values = append(values, configValue{key: name, value: command, quote: true})
+		}
+	}
+
+	return values
+}
+
+// transcodeStep is a transcoder applied to the downloaded objects together with its argument.
+type transcodeStep struct {
+	name string
+	arg  string
+	t    transcoder
+}
+
+// transcodeSteps holds the transcoders applied to each downloaded object, in order.
+type transcodeSteps []transcodeStep
+
+// extractTranscoding removes the transcode=name[:arg][,name[:arg]...] argument from args, returning the transcoders to
+// apply and the remaining arguments.
+func extractTranscoding(args []string) (transcodeSteps, []string, error) {
+	var (
+		steps transcodeSteps
+		rest  []string
+	)
+	for _, arg := range args {
+		val, found := strings.CutPrefix(arg, "transcode=")
+		if !found {
+			rest = append(rest, arg)
+			continue
+		}
+		for _, s := range strings.Split(val, ",") {
+			name, targ, _ := strings.Cut(s, ":")
+			transcodersMu.RLock()
+			t, ok := transcoders[name]
+			transcodersMu.RUnlock()
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown transcoder %s, must be one of %s", name, strings.Join(transcoderNames(), ", "))
+			}
+			steps = append(steps, transcodeStep{name: name, arg: targ, t: t})
+		}
+	}
+
+	return steps, rest, nil
+}
+
+// apply runs the transcoders in order, each one receiving the file returned by the previous one. The name of the
+// failing transcoder is included in the error.
+func (ts transcodeSteps) apply(f transcodeFile) (transcodeFile, error) {
+	for _, s := range ts {
+		var err error
+		if f, err = s.t.transcode(f, s.arg); err != nil {
+			return f, fmt.Errorf("transcoder %s failed: %w", s.name, err)
+		}
+	}
+
+	return f, nil
+}
+
+// helpAddTranscoding returns the help text of the transcode argument of the download commands.
+func helpAddTranscoding() string {
+	return "\t- transcode=name[:arg]: converts the objects before storing them, e.g. transcode=resize:1024 to store JPEG images resized to 1024 pixels for quick previews. Separate several transcoders by commas to apply them in order. The built-in resize transcoder and the ones defined in the transcoders section of the config file are available. An object failing to transcode is stored as is\n"
+}
+
+// jpegResizer is the built-in resize transcoder, which scales JPEG images down to fit within a square of the size given
+// as its argument, defaulting to previewSize. The EXIF metadata is not kept.
+type jpegResizer struct{}
+
+func (jpegResizer) transcode(f transcodeFile, arg string) (transcodeFile, error) {
+	size := previewSize
+	if arg != "" {
+		var err error
+		if size, err = strconv.Atoi(arg); err != nil || size <= 0 {
+			return f, fmt.Errorf("invalid size %s", arg)
+		}
+	}
+	if http.DetectContentType(f.Data) != "image/jpeg" {
+		return f, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(f.Data))
+	if err != nil {
+		return f, err
+	}
+	b := img.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return f, nil
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, fitImage(img, size), &jpeg.Options{Quality: 85}); err != nil {
+		return f, err
+	}
+	f.Data = buf.Bytes()
+
+	return f, nil
+}
+
+// execTranscoder is a transcoder defined in the config file, running a shell command. The command receives the path of
+// a temporary copy of the object as its first argument and the details of the object in the environment, see
+// downloadHookEnv, and writes the converted file to stdout. When it writes nothing, the object is stored as is. The
+// extension of the file name is changed to match the format of the output, when it is an image format known to
+// imageExtensions.
+type execTranscoder string
+
+func (e execTranscoder) transcode(f transcodeFile, _ string) (transcodeFile, error) {
+	dir, err := os.MkdirTemp("", exe+"-transcode-")
+	if err != nil {
+		return f, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, f.Name)
+	if err := os.WriteFile(path, f.Data, 0644); err != nil {
+		return f, err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		// Use %PTPIP_FILE% to get the path.
+		cmd = exec.Command("cmd", "/C", string(e))
+	} else {
+		// The second argument becomes $0, making the path available as $1.
+		cmd = exec.Command("sh", "-c", string(e), exe+"-transcode", path)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), downloadHookEnv(downloadedFile{Path: path, Object: f.Object, Vendor: f.Vendor})...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return f, fmt.Errorf("%s: %s", err, msg)
+		}
+		return f, err
+	}
+	if stdout.Len() == 0 {
+		return f, nil
+	}
+
+	f.Data = stdout.Bytes()
+	f.Name = renameForFormat(f.Name, f.Data)
+
+	return f, nil
+}
+
+// imageExtensions maps the content types detected by http.DetectContentType to the file extensions of the format, the
+// first one being used when changing the extension of a file.
+var imageExtensions = map[string][]string{
+	"image/jpeg": {".jpg", ".jpeg"},
+	"image/png":  {".png"},
+	"image/gif":  {".gif"},
+	"image/webp": {".webp"},
+	"image/bmp":  {".bmp"},
+}
+
+// renameForFormat returns the file name with its extension changed to match the format of the data. The name is
+// returned as is when it already has a matching extension or when the format is unknown. An upper case extension is
+// replaced by an upper case one, e.g. DSCF0001.HEIC becomes DSCF0001.JPG.
+func renameForFormat(name string, data []byte) string {
+	exts, ok := imageExtensions[http.DetectContentType(data)]
+	if !ok {
+		return name
+	}
+	ext := filepath.Ext(name)
+	for _, e := range exts {
+		if strings.EqualFold(ext, e) {
+			return name
+		}
+	}
+
+	want := exts[0]
+	if ext != "" && ext == strings.ToUpper(ext) {
+		want = strings.ToUpper(want)
+	}
+
+	return strings.TrimSuffix(name, ext) + want
+}
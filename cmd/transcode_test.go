@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"image"
+	"image/jpeg"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testJPEG returns a JPEG image of the given size.
+func testJPEG(t *testing.T, w, h int) []byte {
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func testTranscodeFile(name string, data []byte) transcodeFile {
+	return transcodeFile{
+		Name:   name,
+		Data:   data,
+		Object: ip.Object{Handle: 1, Info: &ptp.ObjectInfo{Filename: name, ObjectFormat: ptp.OFC_EXIF_JPEG}},
+		Vendor: "fuji",
+	}
+}
+
+func TestExtractTranscoding(t *testing.T) {
+	steps, rest, err := extractTranscoding([]string{"format=jpeg", "transcode=resize:1024,resize", "xmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].name != "resize" || steps[0].arg != "1024" || steps[1].arg != "" {
+		t.Errorf("extractTranscoding() steps = %+v; want resize:1024 and resize", steps)
+	}
+	if strings.Join(rest, " ") != "format=jpeg xmp" {
+		t.Errorf("extractTranscoding() rest = %v; want [format=jpeg xmp]", rest)
+	}
+
+	if _, _, err := extractTranscoding([]string{"transcode=nope"}); err == nil {
+		t.Error("extractTranscoding() error = <nil>; want error for unknown transcoder")
+	}
+}
+
+func TestJpegResizer_transcode(t *testing.T) {
+	f := testTranscodeFile("DSCF0001.JPG", testJPEG(t, 400, 200))
+
+	got, err := jpegResizer{}.transcode(f, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(got.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 || got.Name != "DSCF0001.JPG" {
+		t.Errorf("transcode() got = %s %dx%d; want DSCF0001.JPG 100x50", got.Name, b.Dx(), b.Dy())
+	}
+
+	// Small images and other formats are left alone.
+	if got, err := (jpegResizer{}).transcode(f, ""); err != nil || !bytes.Equal(got.Data, f.Data) {
+		t.Errorf("transcode() got = %d bytes, %v; want the image as is", len(got.Data), err)
+	}
+	raw := testTranscodeFile("DSCF0001.RAF", []byte("FUJIFILMCCD-RAW"))
+	if got, err := (jpegResizer{}).transcode(raw, "100"); err != nil || !bytes.Equal(got.Data, raw.Data) {
+		t.Errorf("transcode() got = %q, %v; want the RAW file as is", got.Data, err)
+	}
+
+	if _, err := (jpegResizer{}).transcode(f, "big"); err == nil {
+		t.Error("transcode() error = <nil>; want error for invalid size")
+	}
+}
+
+func TestExecTranscoder_transcode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands require sh")
+	}
+	f := testTranscodeFile("DSCF0001.HEIC", []byte("heic"))
+
+	// Write a PNG signature when the temporary copy of the object is found.
+	got, err := execTranscoder(`test "$(cat "$1")" = heic && test "$PTPIP_VENDOR" = fuji && printf '\211PNG\r\n\032\n'`).transcode(f, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "DSCF0001.PNG" || !bytes.HasPrefix(got.Data, []byte("\x89PNG")) {
+		t.Errorf("transcode() got = %s %q; want DSCF0001.PNG holding the output", got.Name, got.Data)
+	}
+
+	if got, err := execTranscoder("true").transcode(f, ""); err != nil || got.Name != f.Name || string(got.Data) != "heic" {
+		t.Errorf("transcode() got = %s %q, %v; want the file as is", got.Name, got.Data, err)
+	}
+
+	_, err = execTranscoder("echo unsupported >&2; exit 2").transcode(f, "")
+	if err == nil || err.Error() != "exit status 2: unsupported" {
+		t.Errorf("transcode() error = %v; want exit status 2: unsupported", err)
+	}
+}
+
+func TestSetExecTranscoders(t *testing.T) {
+	defer setExecTranscoders(nil)
+
+	if err := checkExecTranscoder("resize", "cat"); err == nil {
+		t.Error("checkExecTranscoder() error = <nil>; want error when redefining a built-in transcoder")
+	}
+	if err := checkExecTranscoder("a:b", "cat"); err == nil {
+		t.Error("checkExecTranscoder() error = <nil>; want error for a name holding a colon")
+	}
+
+	setExecTranscoders(map[string]string{"test": "cat"})
+	if _, ok := transcoders["test"]; !ok {
+		t.Error("setExecTranscoders() transcoder test not defined")
+	}
+	if values := execTranscoderValues(); len(values) != 1 || values[0].key != "test" || values[0].value != "cat" {
+		t.Errorf("execTranscoderValues() got = %+v; want test = cat", values)
+	}
+
+	setExecTranscoders(nil)
+	if _, ok := transcoders["test"]; ok {
+		t.Error("setExecTranscoders() transcoder test still defined")
+	}
+	if _, ok := transcoders["resize"]; !ok {
+		t.Error("setExecTranscoders() built-in transcoder resize removed")
+	}
+}
+
+func TestRenameForFormat(t *testing.T) {
+	jpg, png := []byte("\xff\xd8\xff\xe0"), []byte("\x89PNG\r\n\x1a\n")
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"DSCF0001.HEIC", jpg, "DSCF0001.JPG"},
+		{"img_0001.heic", jpg, "img_0001.jpg"},
+		{"DSCF0001.JPEG", jpg, "DSCF0001.JPEG"},
+		{"DSCF0001.JPG", png, "DSCF0001.PNG"},
+		{"DSCF0001.RAF", []byte("FUJIFILMCCD-RAW"), "DSCF0001.RAF"},
+	} {
+		if got := renameForFormat(tc.name, tc.data); got != tc.want {
+			t.Errorf("renameForFormat(%s) got = %s; want %s", tc.name, got, tc.want)
+		}
+	}
+}
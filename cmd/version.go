@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	gitCommit = "unknown"
+	// buildTags holds the build tags the binary has been compiled with, each file requiring a tag adds it here.
+	buildTags []string
+)
+
+// buildInfo returns the git commit and the build time set using -ldflags. When they were not set, the VCS information
+// embedded by the go command is used instead, if any.
+func buildInfo() (string, string) {
+	commit, built := gitCommit, buildTime
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && commit == "unknown":
+				commit = s.Value
+			case s.Key == "vcs.time" && built == "unknown":
+				built = s.Value
+			}
+		}
+	}
+
+	return commit, built
+}
+
+// printVersion writes the version info to w.
+func printVersion(w io.Writer) {
+	commit, built := buildInfo()
+	tags := "none"
+	if len(buildTags) > 0 {
+		tags = strings.Join(buildTags, ", ")
+	}
+
+	fmt.Fprintf(w, "%s version %s\n", exe, version)
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "  built:\t%s\n", built)
+	fmt.Fprintf(tw, "  commit:\t%s\n", commit)
+	fmt.Fprintf(tw, "  go:\t%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(tw, "  vendors:\t%s\n", strings.Join(ip.SupportedVendors(), ", "))
+	fmt.Fprintf(tw, "  build tags:\t%s\n", tags)
+	tw.Flush()
+}
+
+// printCapabilities writes what this binary can do to w: the capabilities per vendor, the available commands and the
+// supported formats.
+func printCapabilities(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Vendors:")
+	for _, v := range ip.SupportedVendors() {
+		var caps []string
+		for _, c := range ip.VendorCapabilities(v) {
+			caps = append(caps, string(c))
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", v, strings.Join(caps, ", "))
+	}
+
+	lv := []string{"http", "rtsp", "record"}
+	if webcamDriver != "" {
+		lv = append(lv, "webcam ("+webcamDriver+")")
+	}
+	if hasBuildTag("with_lv") {
+		lv = append([]string{"window"}, lv...)
+	}
+	fmt.Fprintln(tw, "Features:")
+	fmt.Fprintf(tw, "  liveview\t%s\n", strings.Join(lv, ", "))
+	fmt.Fprintf(tw, "  jpeg decoders\t%s\n", strings.Join(frameDecoderNames(), ", "))
+	fmt.Fprintf(tw, "  config formats\t%s\n", strings.Join([]string{formatINI, formatTOML, formatYAML}, ", "))
+	fmt.Fprintf(tw, "  log formats\t%s\n", strings.Join([]string{string(logFormatText), string(logFormatJSON)}, ", "))
+	fmt.Fprintln(tw, "  server\tsocket, web UI, WebSocket API, health endpoints")
+	tw.Flush()
+
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintf(w, "  %s\n", strings.Join(commandNames(), ", "))
+}
+
+// hasBuildTag returns true when the binary has been compiled using the given build tag.
+func hasBuildTag(tag string) bool {
+	for _, t := range buildTags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintVersion(t *testing.T) {
+	defer func(v, c string) { version, gitCommit = v, c }(version, gitCommit)
+	version, gitCommit = "1.2.3", "abc1234"
+
+	var buf bytes.Buffer
+	printVersion(&buf)
+
+	got := buf.String()
+	for _, want := range []string{"version 1.2.3", "commit:     abc1234", "vendors:    canon, fuji, generic, nikon, sony"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("printVersion() got = %q; want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestPrintCapabilities(t *testing.T) {
+	var buf bytes.Buffer
+	printCapabilities(&buf)
+
+	got := buf.String()
+	for _, want := range []string{"fuji     device-info", "log formats     text, json", "capture, conformance, describe"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("printCapabilities() got = %q; want it to contain %q", got, want)
+		}
+	}
+}
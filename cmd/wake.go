@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// responderWakeTimeout is the time the camera gets to bring up its network and the PTP/IP service after having
+	// been woken up.
+	responderWakeTimeout = 30 * time.Second
+	responderWakePoll    = time.Second
+)
+
+// bleWrite is a single step of the Bluetooth LE wake sequence: the value is written to the characteristic with the
+// given handle.
+type bleWrite struct {
+	handle uint16
+	value  []byte
+}
+
+// parseBleWake parses a wake sequence in the format "handle:value,handle:value" where the handle is a number and the
+// value is hex encoded, e.g. "0x0012:0100,0x0015:01". The writes are done in the given order.
+func parseBleWake(s string) ([]bleWrite, error) {
+	var writes []bleWrite
+	for _, step := range strings.Split(s, ",") {
+		step = strings.TrimSpace(step)
+		h, v, ok := strings.Cut(step, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid wake step %q: want handle:value", step)
+		}
+		handle, err := strconv.ParseUint(h, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wake step %q: handle %w", step, err)
+		}
+		value, err := hex.DecodeString(strings.TrimPrefix(v, "0x"))
+		if err != nil || len(value) == 0 {
+			return nil, fmt.Errorf("invalid wake step %q: value must be hex encoded", step)
+		}
+		writes = append(writes, bleWrite{handle: uint16(handle), value: value})
+	}
+
+	return writes, nil
+}
+
+// wakeCamera wakes up the camera over Bluetooth LE, when a Bluetooth address has been configured, and waits for the
+// responder to accept connections. Cameras such as the Nikon and Fuji bodies only start their WiFi network and PTP/IP
+// service after their smartphone app has performed a wake sequence over Bluetooth LE. The sequence is camera model
+// dependent and must be configured, see parseBleWake.
+func wakeCamera(cam *camera, c *ip.Client) error {
+	if cam.bleAddr == "" {
+		return nil
+	}
+	if cam.bleWake == "" {
+		return fmt.Errorf("no wake sequence configured for Bluetooth address %s", cam.bleAddr)
+	}
+
+	writes, err := parseBleWake(cam.bleWake)
+	if err != nil {
+		return err
+	}
+	c.Infof("Waking up responder with Bluetooth address %s", cam.bleAddr)
+	if err := bleWake(cam.bleAddr, writes); err != nil {
+		return err
+	}
+
+	return waitForResponder(c.CommandDataAddress(), responderWakeTimeout)
+}
+
+// waitForResponder waits until the given address accepts TCP connections or the timeout expires.
+func waitForResponder(address string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, responderWakePoll)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("responder did not come up within %s: %w", timeout, err)
+		}
+		time.Sleep(responderWakePoll)
+	}
+}
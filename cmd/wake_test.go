@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseBleWake(t *testing.T) {
+	got, err := parseBleWake("0x0012:0100, 21:0xff")
+	if err != nil {
+		t.Fatalf("parseBleWake() error = %s; want <nil>", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("parseBleWake() got = %d writes; want 2", len(got))
+	}
+	if got[0].handle != 0x12 || !bytes.Equal(got[0].value, []byte{0x01, 0x00}) {
+		t.Errorf("parseBleWake() got = %#04x:%x; want 0x12:0100", got[0].handle, got[0].value)
+	}
+	if got[1].handle != 21 || !bytes.Equal(got[1].value, []byte{0xff}) {
+		t.Errorf("parseBleWake() got = %d:%x; want 21:ff", got[1].handle, got[1].value)
+	}
+
+	for _, s := range []string{"", "0x0012", "0x10000:01", "0x0012:zz", "0x0012:"} {
+		if _, err := parseBleWake(s); err == nil {
+			t.Errorf("parseBleWake(%q) error = <nil>; want error", s)
+		}
+	}
+}
+
+func TestWaitForResponder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	if err := waitForResponder(addr, time.Second); err != nil {
+		t.Errorf("waitForResponder() error = %s; want <nil>", err)
+	}
+
+	ln.Close()
+	if err := waitForResponder(addr, 0); err == nil {
+		t.Errorf("waitForResponder() error = <nil>; want error")
+	}
+}
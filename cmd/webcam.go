@@ -0,0 +1,97 @@
+package main
+
+import (
+	"image"
+	"io"
+	"sync"
+)
+
+// webcamSink is a virtual webcam device the live view frames are fed to, so that the camera can be used as a webcam in
+// video calls. The sinks are platform specific, see openWebcam.
+type webcamSink interface {
+	io.Closer
+	// writeFrame hands the raw JPEG data of a single live view frame to the device.
+	writeFrame(data []byte) error
+}
+
+// webcam feeds the live view frames to a webcamSink until it is closed.
+type webcam struct {
+	sink   webcamSink
+	frames <-chan []byte
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newWebcam returns a webcam feeding the given frames to the sink once run is called.
+func newWebcam(sink webcamSink, frames <-chan []byte) *webcam {
+	return &webcam{
+		sink:   sink,
+		frames: frames,
+		done:   make(chan struct{}),
+	}
+}
+
+// run writes the frames to the sink until the webcam is closed, the live view is disabled or writing a frame fails.
+// The sink is closed when run returns.
+func (w *webcam) run() error {
+	defer func() {
+		w.sink.Close()
+		// Keep draining the frames so that the subscription can end when the live view is disabled.
+		go func() {
+			for range w.frames {
+			}
+		}()
+	}()
+
+	for {
+		select {
+		case <-w.done:
+			return nil
+		case data, ok := <-w.frames:
+			if !ok {
+				return nil
+			}
+			if err := w.sink.writeFrame(data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close stops feeding the frames to the sink.
+func (w *webcam) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+
+	return nil
+}
+
+// decodeRGB24 decodes the JPEG frame using the live view decoder and returns its pixels as packed 24-bit RGB, or BGR
+// when bgr is true, as most virtual webcam drivers expect raw frames.
+func decodeRGB24(data []byte, bgr bool) ([]byte, image.Rectangle, error) {
+	img, err := lvDecoder.decoder().decode(data)
+	if err != nil {
+		return nil, image.Rectangle{}, err
+	}
+
+	return rgb24(img, bgr), img.Rect, nil
+}
+
+// rgb24 drops the alpha channel of the image, optionally swapping the red and blue channels.
+func rgb24(img *image.RGBA, bgr bool) []byte {
+	b := img.Rect
+	pix := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y) : img.PixOffset(b.Min.X, y)+b.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			if bgr {
+				pix = append(pix, row[i+2], row[i+1], row[i])
+				continue
+			}
+			pix = append(pix, row[i:i+3]...)
+		}
+	}
+
+	return pix
+}
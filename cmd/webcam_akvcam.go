@@ -0,0 +1,86 @@
+// +build darwin windows,!with_softcam
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// webcamDriver is the virtual webcam driver used on this platform.
+const webcamDriver = "akvirtualcamera"
+
+// akvcamManager is the command line tool of akvirtualcamera used to stream to its virtual cameras.
+const akvcamManager = "AkVCamManager"
+
+// akvcamSink streams the frames to a virtual camera created using akvirtualcamera, which provides a CoreMediaIO plugin
+// on macOS and a DirectShow filter on Windows. The device is the ID of the virtual camera, e.g. AkVCamVideoDevice0.
+// The frames are decoded and piped as raw RGB24 to AkVCamManager, which is started using the size of the first frame.
+type akvcamSink struct {
+	device string
+	size   image.Rectangle
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+}
+
+// openWebcam checks that the frames can be decoded and that akvirtualcamera is installed. The frame rate is limited by
+// the consumers of the device.
+func openWebcam(device string, _ float64) (webcamSink, error) {
+	if lvDecoder == noneFrameDecoder {
+		return nil, errFrameDecodingDisabled
+	}
+	if _, err := exec.LookPath(akvcamManager); err != nil {
+		return nil, fmt.Errorf("akvirtualcamera does not seem to be installed: %w", err)
+	}
+
+	return &akvcamSink{device: device}, nil
+}
+
+func (s *akvcamSink) writeFrame(data []byte) error {
+	pix, r, err := decodeRGB24(data, false)
+	if err != nil {
+		return err
+	}
+
+	if s.cmd == nil {
+		if err := s.start(r); err != nil {
+			return err
+		}
+	}
+
+	// The stream has a fixed size, so drop the frames that do not fit it, e.g. when the camera changes the aspect ratio.
+	if r != s.size {
+		return nil
+	}
+
+	_, err = s.stdin.Write(pix)
+
+	return err
+}
+
+// start starts streaming frames of the given size to the virtual camera.
+func (s *akvcamSink) start(r image.Rectangle) error {
+	cmd := exec.Command(akvcamManager, "stream", s.device, "RGB24", strconv.Itoa(r.Dx()), strconv.Itoa(r.Dy()))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting %s: %w", akvcamManager, err)
+	}
+	s.cmd, s.stdin, s.size = cmd, stdin, r
+
+	return nil
+}
+
+func (s *akvcamSink) Close() error {
+	if s.cmd == nil {
+		return nil
+	}
+	s.stdin.Close()
+
+	return s.cmd.Wait()
+}
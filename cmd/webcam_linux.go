@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// webcamDriver is the virtual webcam driver used on this platform.
+const webcamDriver = "v4l2loopback"
+
+// V4L2 constants, see linux/videodev2.h.
+const (
+	v4l2BufTypeVideoOutput = 2
+	v4l2FieldNone          = 1
+	v4l2ColorspaceJPEG     = 7
+	v4l2PixFmtMJPEG        = 'M' | 'J'<<8 | 'P'<<16 | 'G'<<24
+)
+
+// v4l2PixFormat mirrors struct v4l2_pix_format.
+type v4l2PixFormat struct {
+	width        uint32
+	height       uint32
+	pixelFormat  uint32
+	field        uint32
+	bytesPerLine uint32
+	sizeImage    uint32
+	colorspace   uint32
+	priv         uint32
+	flags        uint32
+	encoding     uint32
+	quantization uint32
+	xferFunc     uint32
+}
+
+// v4l2Format mirrors struct v4l2_format. The union holding the format is 200 bytes long and pointer aligned.
+type v4l2Format struct {
+	typ uint32
+	fmt struct {
+		_   [0]uintptr
+		pix v4l2PixFormat
+		_   [200 - unsafe.Sizeof(v4l2PixFormat{})]byte
+	}
+}
+
+// vidiocSFmt is the VIDIOC_S_FMT ioctl request: _IOWR('V', 5, struct v4l2_format).
+const vidiocSFmt = 3<<30 | unsafe.Sizeof(v4l2Format{})<<16 | 'V'<<8 | 5
+
+// v4l2Sink writes the frames to a v4l2loopback output device, e.g. /dev/video10. The frames are written as MJPEG, so
+// they are passed through without decoding them. The format of the device is set using the size of the first frame.
+type v4l2Sink struct {
+	f *os.File
+	// size is the maximum frame size accepted by the device.
+	size int
+}
+
+// openWebcam opens the v4l2loopback device. The frame rate is limited by the consumers of the device.
+func openWebcam(device string, _ float64) (webcamSink, error) {
+	f, err := os.OpenFile(device, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening webcam device: %w", err)
+	}
+
+	return &v4l2Sink{f: f}, nil
+}
+
+func (s *v4l2Sink) writeFrame(data []byte) error {
+	if s.size == 0 {
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("reading the frame size: %w", err)
+		}
+		if err := s.setFormat(cfg.Width, cfg.Height); err != nil {
+			return err
+		}
+	}
+
+	// The device would truncate the frame, so drop it instead.
+	if len(data) > s.size {
+		return nil
+	}
+
+	_, err := s.f.Write(data)
+
+	return err
+}
+
+// setFormat sets the MJPEG output format of the given size on the device.
+func (s *v4l2Sink) setFormat(width, height int) error {
+	f := v4l2Format{typ: v4l2BufTypeVideoOutput}
+	f.fmt.pix = v4l2PixFormat{
+		width:       uint32(width),
+		height:      uint32(height),
+		pixelFormat: v4l2PixFmtMJPEG,
+		field:       v4l2FieldNone,
+		// A JPEG frame is never larger than the raw YUYV frame.
+		sizeImage:  uint32(width * height * 2),
+		colorspace: v4l2ColorspaceJPEG,
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, s.f.Fd(), vidiocSFmt, uintptr(unsafe.Pointer(&f))); errno != 0 {
+		return fmt.Errorf("setting the webcam format: %w", errno)
+	}
+	// The driver returns the frame size it accepts.
+	s.size = int(f.fmt.pix.sizeImage)
+
+	return nil
+}
+
+func (s *v4l2Sink) Close() error {
+	return s.f.Close()
+}
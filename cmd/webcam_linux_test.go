@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestV4l2Format_size(t *testing.T) {
+	// The union is pointer aligned, which adds padding after the type on 64-bit platforms.
+	want := uintptr(204)
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		want = 208
+	}
+	if got := unsafe.Sizeof(v4l2Format{}); got != want {
+		t.Errorf("Sizeof(v4l2Format{}) got = %d; want %d", got, want)
+	}
+	if got, want := unsafe.Offsetof(v4l2Format{}.fmt), want-200; got != want {
+		t.Errorf("Offsetof(v4l2Format{}.fmt) got = %d; want %d", got, want)
+	}
+	if req := uintptr(0xc0d05605); want == 208 && vidiocSFmt != req {
+		t.Errorf("vidiocSFmt got = %#x; want %#x", vidiocSFmt, req)
+	}
+}
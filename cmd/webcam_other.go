@@ -0,0 +1,12 @@
+// +build !linux,!darwin,!windows
+
+package main
+
+import "errors"
+
+// webcamDriver is empty as no virtual webcam driver is supported on this platform.
+const webcamDriver = ""
+
+func openWebcam(_ string, _ float64) (webcamSink, error) {
+	return nil, errors.New("virtual webcams are only supported on Linux, macOS and Windows")
+}
@@ -0,0 +1,83 @@
+// +build windows,with_softcam
+
+package main
+
+/*
+#cgo LDFLAGS: -lsoftcam
+
+// softcam.h can only be included from C++, so the functions used are declared here.
+typedef void *scCamera;
+extern scCamera scCreateCamera(int width, int height, float framerate);
+extern void scDeleteCamera(scCamera camera);
+extern void scSendFrame(scCamera camera, const void *image_bits);
+*/
+import "C"
+
+import (
+	"errors"
+	"image"
+	"unsafe"
+)
+
+// webcamDriver is the virtual webcam driver used on this platform.
+const webcamDriver = "softcam"
+
+// softcamFps is the frame rate the softcam camera is created with when the frame rate is not limited.
+const softcamFps = 60
+
+func init() {
+	buildTags = append(buildTags, "with_softcam")
+}
+
+// softcamSink sends the frames to the DirectShow virtual camera provided by softcam. The softcam DLL must be registered
+// using regsvr32 for the camera to show up in other applications. Softcam provides a single camera, so the device is
+// ignored. The camera is created using the size of the first frame.
+type softcamSink struct {
+	fps  float64
+	size image.Rectangle
+	cam  C.scCamera
+}
+
+func openWebcam(_ string, fps float64) (webcamSink, error) {
+	if lvDecoder == noneFrameDecoder {
+		return nil, errFrameDecodingDisabled
+	}
+	if fps == 0 {
+		fps = softcamFps
+	}
+
+	return &softcamSink{fps: fps}, nil
+}
+
+func (s *softcamSink) writeFrame(data []byte) error {
+	// Softcam expects 24-bit BGR.
+	pix, r, err := decodeRGB24(data, true)
+	if err != nil {
+		return err
+	}
+
+	if s.cam == nil {
+		if s.cam = C.scCreateCamera(C.int(r.Dx()), C.int(r.Dy()), C.float(s.fps)); s.cam == nil {
+			return errors.New("softcam: creating the camera failed, is it in use by another process?")
+		}
+		s.size = r
+	}
+
+	// The camera has a fixed size, so drop the frames that do not fit it.
+	if r != s.size {
+		return nil
+	}
+
+	C.scSendFrame(s.cam, unsafe.Pointer(&pix[0]))
+
+	return nil
+}
+
+func (s *softcamSink) Close() error {
+	if s.cam != nil {
+		C.scDeleteCamera(s.cam)
+		s.cam = nil
+	}
+
+	return nil
+}
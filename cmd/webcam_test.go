@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRgb24(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 1, G: 2, B: 3, A: 255})
+	img.Set(1, 0, color.RGBA{R: 4, G: 5, B: 6, A: 255})
+
+	if got, want := rgb24(img, false), []byte{1, 2, 3, 4, 5, 6}; !bytes.Equal(got, want) {
+		t.Errorf("rgb24() got = %v; want %v", got, want)
+	}
+	if got, want := rgb24(img, true), []byte{3, 2, 1, 6, 5, 4}; !bytes.Equal(got, want) {
+		t.Errorf("rgb24() got = %v; want %v", got, want)
+	}
+
+	sub := img.SubImage(image.Rect(1, 0, 2, 1)).(*image.RGBA)
+	if got, want := rgb24(sub, false), []byte{4, 5, 6}; !bytes.Equal(got, want) {
+		t.Errorf("rgb24() got = %v; want %v", got, want)
+	}
+}
+
+type mockWebcamSink struct {
+	frames [][]byte
+	fail   bool
+	closed bool
+}
+
+func (m *mockWebcamSink) writeFrame(data []byte) error {
+	if m.fail {
+		return errors.New("write failed")
+	}
+	m.frames = append(m.frames, data)
+	return nil
+}
+
+func (m *mockWebcamSink) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestWebcam_run(t *testing.T) {
+	frames := make(chan []byte, 2)
+	frames <- []byte{1}
+	frames <- []byte{2}
+	close(frames)
+
+	sink := &mockWebcamSink{}
+	if err := newWebcam(sink, frames).run(); err != nil {
+		t.Errorf("run() err = %s; want <nil>", err)
+	}
+	if got := len(sink.frames); got != 2 {
+		t.Errorf("run() got = %d frames; want 2", got)
+	}
+	if !sink.closed {
+		t.Errorf("run() did not close the sink")
+	}
+
+	frames = make(chan []byte, 1)
+	frames <- []byte{1}
+	sink = &mockWebcamSink{fail: true}
+	if err := newWebcam(sink, frames).run(); err == nil {
+		t.Errorf("run() err = <nil>; want error")
+	}
+	close(frames)
+
+	sink = &mockWebcamSink{}
+	w := newWebcam(sink, make(chan []byte))
+	w.Close()
+	if err := w.run(); err != nil || !sink.closed {
+		t.Errorf("run() got = %v, closed %t; want <nil>, closed true", err, sink.closed)
+	}
+}
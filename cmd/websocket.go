@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// WebSocket opcodes as defined by RFC 6455.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+const (
+	// wsAcceptGUID is appended to the key sent by the client to compute the accept header of the handshake.
+	wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// wsMaxMessageSize limits the size of the messages we accept: commands are short.
+	wsMaxMessageSize = 64 * 1024
+)
+
+var (
+	wsNotUpgrade   = errors.New("not a websocket upgrade request")
+	wsBadOrigin    = errors.New("websocket origin not allowed")
+	wsTooLarge     = errors.New("websocket message too large")
+	wsNotMasked    = errors.New("websocket client frame not masked")
+	wsProtocolFail = errors.New("websocket protocol error")
+)
+
+// wsConn is a minimal server side WebSocket connection, supporting just what the server mode needs: text and binary
+// messages, fragmentation and the ping, pong and close control frames. Writes are safe for concurrent use.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+// upgradeWebSocket performs the opening handshake and hijacks the underlying connection. Browsers on other origins than
+// the ones allowed are refused, see originAllowed, so that another web site cannot use the WebSocket of a visitor.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, origins []string) (*wsConn, error) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	if r.Method != http.MethodGet || key == "" || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, wsNotUpgrade.Error(), http.StatusBadRequest)
+		return nil, wsNotUpgrade
+	}
+	if !originAllowed(r, origins) {
+		http.Error(w, wsBadOrigin.Error(), http.StatusForbidden)
+		return nil, fmt.Errorf("%w: %s", wsBadOrigin, r.Header.Get("Origin"))
+	}
+
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// originAllowed checks if the request may open a WebSocket or capture using the API of the web UI. Requests without an
+// Origin header are not sent by a browser and are allowed, as are the ones sent by a page served by the same host, such
+// as the web UI. Other origins, e.g. "https://example.com", must be in the allowed list, "*" allowing all of them.
+func originAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerContains checks if the comma separated header values contain the given token, ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// readMessage returns the next text or binary message, answering pings along the way. io.EOF is returned when the
+// client closes the connection.
+func (ws *wsConn) readMessage() (byte, []byte, error) {
+	var (
+		op  byte
+		msg []byte
+	)
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := ws.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			ws.writeFrame(wsClose, payload)
+			return 0, nil, io.EOF
+		case wsText, wsBinary:
+			if msg != nil {
+				return 0, nil, wsProtocolFail
+			}
+			op = opcode
+		case wsContinuation:
+			if op == 0 {
+				return 0, nil, wsProtocolFail
+			}
+		default:
+			return 0, nil, wsProtocolFail
+		}
+
+		if len(msg)+len(payload) > wsMaxMessageSize {
+			return 0, nil, wsTooLarge
+		}
+		msg = append(msg, payload...)
+		if fin {
+			if msg == nil {
+				msg = []byte{}
+			}
+			return op, msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame and unmasks its payload.
+func (ws *wsConn) readFrame() (bool, byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(ws.rw, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := hdr[0]&0x80 != 0
+	opcode := hdr[0] & 0x0F
+	if hdr[1]&0x80 == 0 {
+		return false, 0, nil, wsNotMasked
+	}
+
+	l := uint64(hdr[1] & 0x7F)
+	switch l {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		l = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		l = binary.BigEndian.Uint64(ext[:])
+	}
+	if l > wsMaxMessageSize {
+		return false, 0, nil, wsTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, l)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked, unfragmented frame as servers must.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	hdr := []byte{0x80 | opcode}
+	switch l := len(payload); {
+	case l < 126:
+		hdr = append(hdr, byte(l))
+	case l <= 0xFFFF:
+		hdr = append(hdr, 126, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(l))
+	default:
+		hdr = append(hdr, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(l))
+	}
+
+	if _, err := ws.rw.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := ws.rw.Write(payload); err != nil {
+		return err
+	}
+
+	return ws.rw.Flush()
+}
+
+// writeJSON sends v as a JSON encoded text message.
+func (ws *wsConn) writeJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return ws.writeFrame(wsText, b)
+}
+
+func (ws *wsConn) Close() error {
+	return ws.conn.Close()
+}
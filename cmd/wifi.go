@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"path"
+	"strings"
+)
+
+// parseNmcliSSIDs parses the terse output of nmcli listing the SSIDs of the WiFi networks in range. Hidden networks
+// and duplicates are left out.
+func parseNmcliSSIDs(out string) []string {
+	var (
+		ssids []string
+		seen  = make(map[string]bool)
+	)
+	for _, l := range strings.Split(out, "\n") {
+		// In terse mode, nmcli escapes colons and backslashes.
+		ssid := strings.NewReplacer(`\:`, ":", `\\`, `\`).Replace(strings.TrimSpace(l))
+		if ssid == "" || ssid == "--" || seen[ssid] {
+			continue
+		}
+		seen[ssid] = true
+		ssids = append(ssids, ssid)
+	}
+
+	return ssids
+}
+
+// matchSSID returns the first SSID matching the given shell pattern, e.g. "FUJIFILM-X-T1-*".
+func matchSSID(pattern string, ssids []string) (string, error) {
+	for _, ssid := range ssids {
+		ok, err := path.Match(pattern, ssid)
+		if err != nil {
+			return "", fmt.Errorf("invalid SSID pattern %q: %w", pattern, err)
+		}
+		if ok {
+			return ssid, nil
+		}
+	}
+
+	return "", fmt.Errorf("no WiFi network matching %q found", pattern)
+}
+
+// joinCameraNetwork joins the WiFi network hosted by the camera, when an SSID pattern has been configured, and
+// discovers the camera on it. Cameras such as the Fuji bodies host their own network, which the host must join
+// before the camera can be reached.
+func joinCameraNetwork(cam *camera) error {
+	if cam.wifiSSID == "" {
+		return nil
+	}
+
+	ssids, err := wifiScan()
+	if err != nil {
+		return err
+	}
+	ssid, err := matchSSID(cam.wifiSSID, ssids)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Joining WiFi network %s", ssid)
+	if err := wifiConnect(ssid, cam.wifiPassword); err != nil {
+		return err
+	}
+
+	list, err := ip.Discover(ip.DefaultDiscoveryTimeout)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		logger.Warnf("No camera discovered on WiFi network %s, using host %s", ssid, cam.host)
+		return nil
+	}
+	cam.useDiscovered(list[0])
+
+	return nil
+}
+
+// useDiscovered applies the address of a discovered responder to the camera. The vendor is only changed when it has
+// not been set or is detected automatically, the port only when the camera does not use separate ports.
+func (cam *camera) useDiscovered(r ip.DiscoveredResponder) {
+	logger.Infof("Discovered %s at %s", r.FriendlyName, r.IpAddress)
+	cam.host = r.IpAddress
+	if r.Vendor != "" && (cam.vendor == ip.DefaultVendor || cam.vendor == ip.AutoVendor) {
+		cam.vendor = r.Vendor
+	}
+	if r.Port != 0 && cam.cport == 0 && cam.eport == 0 {
+		cam.port = uint16Value(r.Port)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// wifiScan returns the SSIDs of the WiFi networks in range using NetworkManager.
+func wifiScan() ([]string, error) {
+	out, err := exec.Command("nmcli", "-t", "-f", "SSID", "device", "wifi", "list", "--rescan", "yes").Output()
+	if err != nil {
+		return nil, fmt.Errorf("scanning WiFi networks: %w", err)
+	}
+
+	return parseNmcliSSIDs(string(out)), nil
+}
+
+// wifiConnect joins the WiFi network with the given SSID using NetworkManager. The password is only used when not
+// empty, most cameras host an open network.
+func wifiConnect(ssid, password string) error {
+	args := []string{"device", "wifi", "connect", ssid}
+	if password != "" {
+		args = append(args, "password", password)
+	}
+
+	if out, err := exec.Command("nmcli", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("joining WiFi network %s: %w: %s", ssid, err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
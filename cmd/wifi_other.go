@@ -0,0 +1,15 @@
+// +build !linux
+
+package main
+
+import "errors"
+
+var errNoWifi = errors.New("joining the WiFi network of the camera is only supported on Linux")
+
+func wifiScan() ([]string, error) {
+	return nil, errNoWifi
+}
+
+func wifiConnect(_, _ string) error {
+	return errNoWifi
+}
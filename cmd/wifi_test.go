@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"reflect"
+	"testing"
+)
+
+func TestParseNmcliSSIDs(t *testing.T) {
+	got := parseNmcliSSIDs("FUJIFILM-X-T1-ABCD\nhome\\:net\n--\n\nFUJIFILM-X-T1-ABCD\n")
+	want := []string{"FUJIFILM-X-T1-ABCD", "home:net"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNmcliSSIDs() got = %v; want %v", got, want)
+	}
+}
+
+func TestMatchSSID(t *testing.T) {
+	ssids := []string{"home", "FUJIFILM-X-T1-ABCD"}
+
+	got, err := matchSSID("FUJIFILM-X-T1-*", ssids)
+	if err != nil || got != "FUJIFILM-X-T1-ABCD" {
+		t.Errorf("matchSSID() got = %s, %v; want FUJIFILM-X-T1-ABCD, <nil>", got, err)
+	}
+
+	for _, p := range []string{"NIKON*", "[home"} {
+		if _, err := matchSSID(p, ssids); err == nil {
+			t.Errorf("matchSSID(%q) error = <nil>; want error", p)
+		}
+	}
+}
+
+func TestCamera_useDiscovered(t *testing.T) {
+	cam := &camera{vendor: ip.AutoVendor, host: ip.DefaultIpAddress, port: uint16Value(ip.DefaultPort)}
+	cam.useDiscovered(ip.DiscoveredResponder{FriendlyName: "X-T1", Vendor: "fuji", IpAddress: "192.168.0.1"})
+	if cam.host != "192.168.0.1" || cam.vendor != "fuji" || cam.port != uint16Value(ip.DefaultPort) {
+		t.Errorf("useDiscovered() got = %s %s %d; want 192.168.0.1 fuji %d", cam.host, cam.vendor, cam.port, ip.DefaultPort)
+	}
+
+	cam = &camera{vendor: "nikon", cport: 15740, eport: 15741}
+	cam.useDiscovered(ip.DiscoveredResponder{Vendor: "canon", IpAddress: "192.168.1.1", Port: 15750})
+	if cam.host != "192.168.1.1" || cam.vendor != "nikon" || cam.port != 0 {
+		t.Errorf("useDiscovered() got = %s %s %d; want 192.168.1.1 nikon 0", cam.host, cam.vendor, cam.port)
+	}
+}
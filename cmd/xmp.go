@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// xmpNamespace is the XML namespace of the camera state written to XMP sidecars.
+	xmpNamespace = "https://github.com/malc0mn/ptp-ip/ns/1.0/"
+	// maxCaptureStates is the number of capture states kept for each camera, the oldest ones being forgotten first.
+	maxCaptureStates = 1000
+)
+
+// captureState is the state of the camera at the time an object was captured, formatted for humans.
+type captureState struct {
+	FilmSimulation string
+	WhiteBalance   string
+	// FocusPoint is the column and row of the focus point, e.g. 4x5.
+	FocusPoint string
+}
+
+// captureStateRecorder records the state of the camera each time it reports an object being added, which happens
+// right after it captured an image.
+type captureStateRecorder struct {
+	c      ip.ClientAPI
+	mu     sync.Mutex
+	states map[ptp.ObjectHandle]captureState
+	order  []ptp.ObjectHandle
+}
+
+// captureRecorders maps each ip.ClientAPI to its *captureStateRecorder.
+var captureRecorders sync.Map
+
+// recordCaptureStates starts recording the state of the camera for each object it captures, for the XMP sidecars
+// written by the download commands. The returned function stops recording.
+func recordCaptureStates(c ip.ClientAPI) func() {
+	r := &captureStateRecorder{c: c, states: make(map[ptp.ObjectHandle]captureState)}
+	captureRecorders.Store(c, r)
+
+	remove := c.OnEvent(func(p ip.EventPacket) {
+		if p.GetEventCode() != ptp.EC_ObjectAdded {
+			return
+		}
+		go r.record(ptp.ObjectHandle(p.GetParameter1()))
+	})
+
+	return func() {
+		remove()
+		captureRecorders.Delete(c)
+	}
+}
+
+// recordedCaptureState returns the state of the camera recorded when it captured the object. False is returned when
+// no state was recorded, e.g. because the object was captured before connecting to the camera.
+func recordedCaptureState(c ip.ClientAPI, h ptp.ObjectHandle) (captureState, bool) {
+	v, ok := captureRecorders.Load(c)
+	if !ok {
+		return captureState{}, false
+	}
+	r := v.(*captureStateRecorder)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.states[h]
+
+	return s, ok
+}
+
+// record reads the state of the camera for the object. Properties the camera does not support are left empty.
+func (r *captureStateRecorder) record(h ptp.ObjectHandle) {
+	vendor := r.c.ResponderVendor()
+	prop := func(code ptp.DevicePropCode) string {
+		v, err := r.c.GetDevicePropertyValue(code)
+		if err != nil {
+			return ""
+		}
+		return ptpfmt.DevicePropValAsString(vendor, code, int64(v))
+	}
+
+	var s captureState
+	if code, err := ptpfmt.PropNameToDevicePropCode(vendor, ptpfmt.PRP_WhiteBalance); err == nil {
+		s.WhiteBalance = prop(code)
+	}
+	if vendor == ptp.VE_FujiPhotoFilmCoLtd {
+		s.FilmSimulation = prop(ip.DPC_Fuji_FilmSimulation)
+		s.FocusPoint = prop(ip.DPC_Fuji_FocusMeteringMode)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.states[h]; !ok {
+		r.order = append(r.order, h)
+	}
+	r.states[h] = s
+	if len(r.order) > maxCaptureStates {
+		delete(r.states, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// extractSidecarFlag removes the xmp argument from args, reporting whether it was present.
+func extractSidecarFlag(args []string) (bool, []string) {
+	var (
+		found bool
+		rest  []string
+	)
+	for _, arg := range args {
+		if arg == "xmp" {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	return found, rest
+}
+
+// helpAddSidecar returns the help text of the xmp argument of the download commands.
+func helpAddSidecar() string {
+	return "\t- xmp: writes an XMP sidecar next to each RAW file, e.g. DSCF0001.xmp, holding the film simulation, white balance and focus point of the camera when the image was captured, as far as they were recorded while connected to the camera, and the GPS position of the image\n"
+}
+
+// sidecarPath returns the path of the XMP sidecar of the file at the given path, which replaces its extension, e.g.
+// DSCF0001.xmp for DSCF0001.RAF.
+func sidecarPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp"
+}
+
+// writeSidecar writes the XMP sidecar of a downloaded RAW file to the storage, holding the camera state recorded when it
+// was captured and the film simulation and the location read from the data of the file. No sidecar is written when
+// there is nothing to put in it. The path of the sidecar is returned, or an empty string when none was written.
+func writeSidecar(c ip.ClientAPI, store fileStore, path string, obj ip.Object, data []byte) (string, error) {
+	state, _ := recordedCaptureState(c, obj.Handle)
+	md, err := ip.ReadMetadata(bytes.NewReader(data))
+	if err != nil {
+		md = &ip.ObjectMetadata{}
+	}
+	if state.FilmSimulation == "" {
+		state.FilmSimulation = ptpfmt.FujiFilmSimulationAsString(md.FilmSimulation)
+	}
+
+	xmp := marshalSidecar(state, md.GPS)
+	if xmp == nil {
+		return "", nil
+	}
+	sidecar := sidecarPath(path)
+	if err := store.write(sidecar, xmp); err != nil {
+		return "", err
+	}
+
+	return sidecar, nil
+}
+
+// marshalSidecar returns the XMP packet holding the camera state and the location, nil being returned when both are
+// empty. The location uses the properties of the EXIF schema, so that photo editors show it on a map.
+func marshalSidecar(state captureState, gps *ip.GPSPosition) []byte {
+	var attrs [][2]string
+	for _, a := range [][2]string{
+		{"ptpip:FilmSimulation", state.FilmSimulation},
+		{"ptpip:WhiteBalance", state.WhiteBalance},
+		{"ptpip:FocusPoint", state.FocusPoint},
+	} {
+		if a[1] != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	if gps != nil {
+		altRef := "0"
+		if gps.Altitude < 0 {
+			altRef = "1"
+		}
+		attrs = append(attrs,
+			[2]string{"exif:GPSLatitude", xmpCoordinate(gps.Latitude, "N", "S")},
+			[2]string{"exif:GPSLongitude", xmpCoordinate(gps.Longitude, "E", "W")},
+			[2]string{"exif:GPSAltitudeRef", altRef},
+			[2]string{"exif:GPSAltitude", fmt.Sprintf("%d/100", int64(math.Round(math.Abs(gps.Altitude)*100)))},
+		)
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	b.WriteString("    xmlns:exif=\"http://ns.adobe.com/exif/1.0/\"\n")
+	b.WriteString("    xmlns:ptpip=\"" + xmpNamespace + "\"")
+	for _, a := range attrs {
+		b.WriteString("\n    " + a[0] + "=\"")
+		xml.EscapeText(&b, []byte(a[1]))
+		b.WriteString("\"")
+	}
+	b.WriteString("/>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>\n")
+
+	return b.Bytes()
+}
+
+// xmpCoordinate formats a latitude or longitude in degrees the way the EXIF schema of XMP does, e.g. 51,30.6N.
+func xmpCoordinate(deg float64, pos, neg string) string {
+	ref := pos
+	if deg < 0 {
+		ref, deg = neg, -deg
+	}
+	d := math.Floor(deg)
+
+	return fmt.Sprintf("%d,%.6f%s", int(d), (deg-d)*60, ref)
+}
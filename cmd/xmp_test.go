@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCaptureStateRecorder_record(t *testing.T) {
+	c := newMockClient(ptp.VE_FujiPhotoFilmCoLtd)
+	c.props[ptp.DPC_WhiteBalance] = uint32(ptp.WB_Automatic)
+	c.props[ip.DPC_Fuji_FilmSimulation] = uint32(ip.FS_Fuji_ClassicChrome)
+	c.props[ip.DPC_Fuji_FocusMeteringMode] = 0x0405
+
+	r := &captureStateRecorder{c: c, states: make(map[ptp.ObjectHandle]captureState)}
+	captureRecorders.Store(c, r)
+	defer captureRecorders.Delete(c)
+	r.record(0x1a)
+
+	want := captureState{FilmSimulation: "Classic Chrome", WhiteBalance: "automatic", FocusPoint: "4x5"}
+	if got, ok := recordedCaptureState(c, 0x1a); !ok || got != want {
+		t.Errorf("recordedCaptureState() got = %+v, %v; want %+v, true", got, ok, want)
+	}
+	if _, ok := recordedCaptureState(c, 0x1b); ok {
+		t.Errorf("recordedCaptureState() ok = true; want false")
+	}
+
+	for h := ptp.ObjectHandle(0x100); h < 0x100+maxCaptureStates; h++ {
+		r.record(h)
+	}
+	if _, ok := recordedCaptureState(c, 0x1a); ok || len(r.states) != maxCaptureStates {
+		t.Errorf("record() kept %d states; want %d, forgetting the oldest", len(r.states), maxCaptureStates)
+	}
+}
+
+func TestMarshalSidecar(t *testing.T) {
+	if got := marshalSidecar(captureState{}, nil); got != nil {
+		t.Errorf("marshalSidecar() got = %s; want <nil>", got)
+	}
+
+	got := string(marshalSidecar(
+		captureState{FilmSimulation: "PRO Neg. Hi", WhiteBalance: "<custom>"},
+		&ip.GPSPosition{Latitude: 51.51, Longitude: -0.125, Altitude: -2.5},
+	))
+	for _, want := range []string{
+		`ptpip:FilmSimulation="PRO Neg. Hi"`,
+		`ptpip:WhiteBalance="&lt;custom&gt;"`,
+		`exif:GPSLatitude="51,30.600000N"`,
+		`exif:GPSLongitude="0,7.500000W"`,
+		`exif:GPSAltitudeRef="1"`,
+		`exif:GPSAltitude="250/100"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("marshalSidecar() got = %s; want it to contain %s", got, want)
+		}
+	}
+	if strings.Contains(got, "FocusPoint") {
+		t.Errorf("marshalSidecar() got = %s; want no empty properties", got)
+	}
+}
+
+func TestWriteSidecar(t *testing.T) {
+	c := newMockClient(ptp.VE_FujiPhotoFilmCoLtd)
+	path := filepath.Join(t.TempDir(), "DSCF0001.RAF")
+
+	sidecar, err := writeSidecar(c, localStore{}, path, ip.Object{Handle: 0x1a}, []byte("not an image"))
+	if err != nil || sidecar != "" {
+		t.Errorf("writeSidecar() got = %s, %v; want no sidecar", sidecar, err)
+	}
+
+	r := &captureStateRecorder{c: c, states: map[ptp.ObjectHandle]captureState{0x1a: {WhiteBalance: "daylight"}}}
+	captureRecorders.Store(c, r)
+	defer captureRecorders.Delete(c)
+
+	sidecar, err = writeSidecar(c, localStore{}, path, ip.Object{Handle: 0x1a}, []byte("not an image"))
+	if want := strings.TrimSuffix(path, ".RAF") + ".xmp"; err != nil || sidecar != want {
+		t.Fatalf("writeSidecar() got = %s, %v; want %s, <nil>", sidecar, err, want)
+	}
+	if data, err := os.ReadFile(sidecar); err != nil || !strings.Contains(string(data), `ptpip:WhiteBalance="daylight"`) {
+		t.Errorf("writeSidecar() wrote %s, %v; want the white balance", data, err)
+	}
+}
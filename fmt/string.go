@@ -55,21 +55,89 @@ func DevicePropCodeAsString(code ptp.DevicePropCode) string {
 	return res
 }
 
+// EventCodeAsString returns the event code as string for the given vendor. When the event code is unknown, it returns
+// an empty string.
+func EventCodeAsString(vendor ptp.VendorExtension, code ptp.EventCode) string {
+	switch vendor {
+	case ptp.VE_FujiPhotoFilmCoLtd:
+		return FujiEventCodeAsString(code)
+	default:
+		return GenericEventCodeAsString(code)
+	}
+}
+
 // PropNameToDevicePropCode converts a string to a device property code.
 func PropNameToDevicePropCode(vendor ptp.VendorExtension, param string) (ptp.DevicePropCode, error) {
 	switch vendor {
+	case ptp.VE_CanonInc:
+		return CanonPropToDevicePropCode(param)
 	case ptp.VE_FujiPhotoFilmCoLtd:
 		return FujiPropToDevicePropCode(param)
+	case ptp.VE_SonyCorporation:
+		return SonyPropToDevicePropCode(param)
 	default:
 		return GenericPropToDevicePropCode(param)
 	}
 }
 
+// devicePropValFormatters holds the function formatting device property values for each vendor having its own device
+// properties or encoding standard device properties differently.
+var devicePropValFormatters = map[ptp.VendorExtension]func(ptp.DevicePropCode, int64) string{
+	ptp.VE_CanonInc:           CanonDevicePropValueAsString,
+	ptp.VE_FujiPhotoFilmCoLtd: FujiDevicePropValueAsString,
+	ptp.VE_NikonCorporation:   NikonDevicePropValueAsString,
+	ptp.VE_SonyCorporation:    SonyDevicePropValueAsString,
+}
+
+// DevicePropValAsString returns the device property value as string for the given vendor. Values the vendor does not
+// format are formatted as standard device property values using DevicePropValueAsString. When the value is unknown, it
+// returns an empty string.
 func DevicePropValAsString(vendor ptp.VendorExtension, code ptp.DevicePropCode, v int64) string {
-	switch vendor {
-	case ptp.VE_FujiPhotoFilmCoLtd:
-		return FujiDevicePropValueAsString(code, v)
-	default:
-		return DevicePropValueAsString(code, v)
+	if f, ok := devicePropValFormatters[vendor]; ok {
+		if s := f(code, v); s != "" {
+			return s
+		}
 	}
+
+	return DevicePropValueAsString(code, v)
+}
+
+// DevicePropValFromString is the reverse of DevicePropValAsString: it returns the value out of the given values that is
+// formatted as s. The comparison ignores case and spaces so that "classic chrome" matches "Classic Chrome". Values the
+// fmt package cannot format are matched using their hexadecimal notation. When no value matches, the error lists the
+// allowed values.
+func DevicePropValFromString(vendor ptp.VendorExtension, code ptp.DevicePropCode, s string, values []int64) (int64, error) {
+	want := normalisePropVal(code, s)
+	allowed := make([]string, 0, len(values))
+	for _, v := range values {
+		str := DevicePropValAsString(vendor, code, v)
+		if str == "" {
+			str = ConvertToHexString(v)
+		}
+		if normalisePropVal(code, str) == want {
+			return v, nil
+		}
+		allowed = append(allowed, str)
+	}
+
+	if len(allowed) == 0 {
+		return 0, fmt.Errorf("invalid value '%s': the allowed values are unknown", s)
+	}
+
+	return 0, fmt.Errorf("invalid value '%s', must be one of: %s", s, strings.Join(allowed, ", "))
+}
+
+// normalisePropVal prepares a formatted value for comparison, dropping the parts that are optional when typing a value.
+func normalisePropVal(code ptp.DevicePropCode, s string) string {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	s = strings.TrimPrefix(s, "+")
+
+	if code == ptp.DPC_FNumber {
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "f/"), "f")
+		if f, err := strconv.ParseFloat(s, 32); err == nil {
+			s = fmt.Sprintf("%.1f", f)
+		}
+	}
+
+	return s
 }
@@ -0,0 +1,162 @@
+package fmt
+
+import (
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strconv"
+)
+
+// canonApertures holds the F-number scaled by 10 of each value of ip.DPC_Canon_EOS_Aperture.
+var canonApertures = map[uint32]uint16{
+	0x08: 10, 0x0b: 11, 0x0c: 12, 0x0d: 12, 0x10: 14, 0x13: 16, 0x14: 18, 0x15: 18, 0x18: 20, 0x1b: 22, 0x1c: 25,
+	0x1d: 25, 0x20: 28, 0x23: 32, 0x24: 35, 0x25: 35, 0x28: 40, 0x2b: 45, 0x2c: 45, 0x2d: 50, 0x30: 56, 0x33: 63,
+	0x34: 67, 0x35: 71, 0x38: 80, 0x3b: 90, 0x3c: 95, 0x3d: 100, 0x40: 110, 0x43: 130, 0x44: 130, 0x45: 140,
+	0x48: 160, 0x4b: 180, 0x4c: 190, 0x4d: 200, 0x50: 220, 0x53: 250, 0x54: 270, 0x55: 290, 0x58: 320, 0x5b: 360,
+	0x5c: 380, 0x5d: 400, 0x60: 450, 0x63: 510, 0x64: 540, 0x65: 570, 0x68: 640, 0x6b: 720, 0x6c: 760, 0x6d: 800,
+	0x70: 910,
+}
+
+// canonShutterSpeeds holds the shutter speed of each value of ip.DPC_Canon_EOS_ShutterSpeed in the same format as
+// ExposureTimeAsString.
+var canonShutterSpeeds = map[uint32]string{
+	0x0c: "bulb", 0x10: "30s", 0x13: "25s", 0x14: "20s", 0x15: "20s", 0x18: "15s", 0x1b: "13s", 0x1c: "10s",
+	0x1d: "10s", 0x20: "8s", 0x23: "6s", 0x24: "6s", 0x25: "5s", 0x28: "4s", 0x2b: "3.2s", 0x2c: "3s", 0x2d: "2.5s",
+	0x30: "2s", 0x33: "1.6s", 0x34: "1.5s", 0x35: "1.3s", 0x38: "1s", 0x3b: "0.8s", 0x3c: "0.7s", 0x3d: "0.6s",
+	0x40: "0.5s", 0x43: "0.4s", 0x44: "0.3s", 0x45: "0.3s", 0x48: "1/4", 0x4b: "1/5", 0x4c: "1/6", 0x4d: "1/6",
+	0x50: "1/8", 0x53: "1/10", 0x54: "1/10", 0x55: "1/13", 0x58: "1/15", 0x5b: "1/20", 0x5c: "1/20", 0x5d: "1/25",
+	0x60: "1/30", 0x63: "1/40", 0x64: "1/45", 0x65: "1/50", 0x68: "1/60", 0x6b: "1/80", 0x6c: "1/90", 0x6d: "1/100",
+	0x70: "1/125", 0x73: "1/160", 0x74: "1/180", 0x75: "1/200", 0x78: "1/250", 0x7b: "1/320", 0x7c: "1/350",
+	0x7d: "1/400", 0x80: "1/500", 0x83: "1/640", 0x84: "1/750", 0x85: "1/800", 0x88: "1/1000", 0x8b: "1/1250",
+	0x8c: "1/1500", 0x8d: "1/1600", 0x90: "1/2000", 0x93: "1/2500", 0x94: "1/3000", 0x95: "1/3200", 0x98: "1/4000",
+	0x9b: "1/5000", 0x9c: "1/6000", 0x9d: "1/6400", 0xa0: "1/8000",
+}
+
+// canonISOSpeeds holds the ISO of each value of ip.DPC_Canon_EOS_ISOSpeed.
+var canonISOSpeeds = map[uint32]uint32{
+	0x28: 6, 0x30: 12, 0x38: 25, 0x40: 50, 0x43: 64, 0x45: 80, 0x48: 100, 0x4b: 125, 0x4d: 160, 0x50: 200,
+	0x53: 250, 0x55: 320, 0x58: 400, 0x5b: 500, 0x5d: 640, 0x60: 800, 0x63: 1000, 0x65: 1250, 0x68: 1600,
+	0x6b: 2000, 0x6d: 2500, 0x70: 3200, 0x73: 4000, 0x75: 5000, 0x78: 6400, 0x7b: 8000, 0x7d: 10000, 0x80: 12800,
+	0x83: 16000, 0x85: 20000, 0x88: 25600, 0x8b: 32000, 0x8d: 40000, 0x90: 51200, 0x98: 102400, 0xa0: 204800,
+	0xa8: 409600, 0xb0: 819200,
+}
+
+func CanonDevicePropCodeAsString(code ptp.DevicePropCode) string {
+	switch code {
+	case ip.DPC_Canon_EOS_Aperture:
+		return "F-number"
+	case ip.DPC_Canon_EOS_ShutterSpeed:
+		return "shutter speed"
+	case ip.DPC_Canon_EOS_ISOSpeed:
+		return "ISO"
+	case ip.DPC_Canon_EOS_ExpCompensation:
+		return "exposure bias compensation"
+	case ip.DPC_Canon_EOS_EVFOutputDevice:
+		return "EVF output device"
+	default:
+		return GenericDevicePropCodeAsString(code)
+	}
+}
+
+// CanonPropToDevicePropCode converts a standardised property string to a valid ptp.DevicePropertyCode.
+func CanonPropToDevicePropCode(field string) (ptp.DevicePropCode, error) {
+	switch field {
+	case PRP_Exposure:
+		return ip.DPC_Canon_EOS_ShutterSpeed, nil
+	case PRP_ExpBias:
+		return ip.DPC_Canon_EOS_ExpCompensation, nil
+	case PRP_ISO:
+		return ip.DPC_Canon_EOS_ISOSpeed, nil
+	default:
+		return GenericPropToDevicePropCode(field)
+	}
+}
+
+func CanonDevicePropValueAsString(code ptp.DevicePropCode, v int64) string {
+	switch code {
+	case ip.DPC_Canon_EOS_Aperture:
+		return CanonApertureAsString(uint32(v))
+	case ip.DPC_Canon_EOS_ShutterSpeed:
+		return CanonShutterSpeedAsString(uint32(v))
+	case ip.DPC_Canon_EOS_ISOSpeed:
+		return CanonISOSpeedAsString(uint32(v))
+	case ip.DPC_Canon_EOS_ExpCompensation:
+		return CanonExpCompensationAsString(int8(v))
+	case ip.DPC_Canon_EOS_EVFOutputDevice:
+		return CanonEVFOutputDeviceAsString(ip.CanonEVFOutputDevice(v))
+	default:
+		return DevicePropValueAsString(code, v)
+	}
+}
+
+func CanonApertureAsString(ap uint32) string {
+	if ap == 0 {
+		return "automatic"
+	}
+	if fn, ok := canonApertures[ap]; ok {
+		return fmt.Sprintf("f/%.1f", float32(fn)/10)
+	}
+
+	return ""
+}
+
+func CanonShutterSpeedAsString(ss uint32) string {
+	if ss == 0 {
+		return "automatic"
+	}
+
+	return canonShutterSpeeds[ss]
+}
+
+func CanonISOSpeedAsString(iso uint32) string {
+	if iso == 0 {
+		return "auto"
+	}
+	if s, ok := canonISOSpeeds[iso]; ok {
+		return strconv.FormatUint(uint64(s), 10)
+	}
+
+	return ""
+}
+
+// CanonExpCompensationAsString formats the exposure compensation, which is expressed in steps of 1/8 stop, in the same
+// way as ExposureBiasCompensationAsString. Canon also supports half stops, e.g. "1 1/2".
+func CanonExpCompensationAsString(ec int8) string {
+	sign := ""
+	if ec < 0 {
+		sign = "-"
+		ec = -ec
+	}
+
+	i := ec / 8
+	frac := ""
+	switch ec % 8 {
+	case 0:
+		return sign + strconv.Itoa(int(i))
+	case 3:
+		frac = "1/3"
+	case 4:
+		frac = "1/2"
+	case 5:
+		frac = "2/3"
+	default:
+		return ""
+	}
+
+	if i == 0 {
+		return sign + frac
+	}
+
+	return fmt.Sprintf("%s%d %s", sign, i, frac)
+}
+
+func CanonEVFOutputDeviceAsString(od ip.CanonEVFOutputDevice) string {
+	switch od {
+	case ip.EVF_Canon_TFT:
+		return "TFT"
+	case ip.EVF_Canon_PC:
+		return "PC"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,79 @@
+package fmt
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"testing"
+)
+
+func TestCanonDevicePropCodeAsString(t *testing.T) {
+	check := map[ptp.DevicePropCode]string{
+		ip.DPC_Canon_EOS_Aperture:        "F-number",
+		ip.DPC_Canon_EOS_ShutterSpeed:    "shutter speed",
+		ip.DPC_Canon_EOS_ISOSpeed:        "ISO",
+		ip.DPC_Canon_EOS_ExpCompensation: "exposure bias compensation",
+		ip.DPC_Canon_EOS_EVFOutputDevice: "EVF output device",
+		ptp.DPC_WhiteBalance:             "white balance",
+		ptp.DevicePropCode(0):            "",
+	}
+
+	for code, want := range check {
+		got := CanonDevicePropCodeAsString(code)
+		if got != want {
+			t.Errorf("CanonDevicePropCodeAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestCanonPropToDevicePropCode(t *testing.T) {
+	check := map[string]ptp.DevicePropCode{
+		PRP_Exposure:     ip.DPC_Canon_EOS_ShutterSpeed,
+		PRP_ExpBias:      ip.DPC_Canon_EOS_ExpCompensation,
+		PRP_ISO:          ip.DPC_Canon_EOS_ISOSpeed,
+		PRP_WhiteBalance: ptp.DPC_WhiteBalance,
+	}
+
+	for prop, want := range check {
+		got, err := CanonPropToDevicePropCode(prop)
+		if err != nil {
+			t.Errorf("CanonPropToDevicePropCode() error = %s, want <nil>", err)
+		}
+		if got != want {
+			t.Errorf("CanonPropToDevicePropCode() return = '%#x', want '%#x'", got, want)
+		}
+	}
+}
+
+func TestCanonDevicePropValueAsString(t *testing.T) {
+	check := []struct {
+		code ptp.DevicePropCode
+		v    int64
+		want string
+	}{
+		{ip.DPC_Canon_EOS_Aperture, 0x00, "automatic"},
+		{ip.DPC_Canon_EOS_Aperture, 0x20, "f/2.8"},
+		{ip.DPC_Canon_EOS_Aperture, 0x40, "f/11.0"},
+		{ip.DPC_Canon_EOS_Aperture, 0x01, ""},
+		{ip.DPC_Canon_EOS_ShutterSpeed, 0x0c, "bulb"},
+		{ip.DPC_Canon_EOS_ShutterSpeed, 0x38, "1s"},
+		{ip.DPC_Canon_EOS_ShutterSpeed, 0x78, "1/250"},
+		{ip.DPC_Canon_EOS_ISOSpeed, 0x00, "auto"},
+		{ip.DPC_Canon_EOS_ISOSpeed, 0x48, "100"},
+		{ip.DPC_Canon_EOS_ISOSpeed, 0x70, "3200"},
+		{ip.DPC_Canon_EOS_ExpCompensation, 0x00, "0"},
+		{ip.DPC_Canon_EOS_ExpCompensation, 0x0b, "1 1/3"},
+		{ip.DPC_Canon_EOS_ExpCompensation, 0x04, "1/2"},
+		{ip.DPC_Canon_EOS_ExpCompensation, 0xfd, "-1/3"},
+		{ip.DPC_Canon_EOS_ExpCompensation, 0xeb, "-2 2/3"},
+		{ip.DPC_Canon_EOS_ExpCompensation, 0x01, ""},
+		{ip.DPC_Canon_EOS_EVFOutputDevice, int64(ip.EVF_Canon_PC), "PC"},
+		{ptp.DPC_WhiteBalance, int64(ptp.WB_Daylight), "daylight"},
+	}
+
+	for _, tt := range check {
+		got := CanonDevicePropValueAsString(tt.code, tt.v)
+		if got != tt.want {
+			t.Errorf("CanonDevicePropValueAsString() %#x return = '%s', want '%s'", tt.v, got, tt.want)
+		}
+	}
+}
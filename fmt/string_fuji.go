@@ -46,6 +46,17 @@ func FujiDevicePropCodeAsString(code ptp.DevicePropCode) string {
 	}
 }
 
+func FujiEventCodeAsString(code ptp.EventCode) string {
+	switch code {
+	case ip.EC_Fuji_PreviewAvailable:
+		return "preview available"
+	case ip.EC_Fuji_ObjectAdded:
+		return "object added"
+	default:
+		return GenericEventCodeAsString(code)
+	}
+}
+
 // FujiPropToDevicePropCode converts a standardised property string to a valid ptp.DevicePropertyCode.
 func FujiPropToDevicePropCode(field string) (ptp.DevicePropCode, error) {
 	switch field {
@@ -36,6 +36,22 @@ func TestFujiDevicePropCodeAsString(t *testing.T) {
 	}
 }
 
+func TestFujiEventCodeAsString(t *testing.T) {
+	check := map[ptp.EventCode]string{
+		ip.EC_Fuji_PreviewAvailable: "preview available",
+		ip.EC_Fuji_ObjectAdded:      "object added",
+		ptp.EC_CaptureComplete:      "capture complete",
+		ptp.EventCode(0):            "",
+	}
+
+	for code, want := range check {
+		got := FujiEventCodeAsString(code)
+		if got != want {
+			t.Errorf("FujiEventCodeAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
 func TestFujiPropToDevicePropCode(t *testing.T) {
 	check := map[string]ptp.DevicePropCode{
 		PRP_Effect:            ip.DPC_Fuji_FilmSimulation,
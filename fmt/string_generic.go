@@ -102,6 +102,42 @@ func GenericPropToDevicePropCode(field string) (ptp.DevicePropCode, error) {
 	}
 }
 
+// GenericEventCodeAsString returns the EventCode as string. When the EventCode is unknown, it returns an empty string.
+func GenericEventCodeAsString(code ptp.EventCode) string {
+	switch code {
+	case ptp.EC_CancelTransaction:
+		return "cancel transaction"
+	case ptp.EC_ObjectAdded:
+		return "object added"
+	case ptp.EC_ObjectRemoved:
+		return "object removed"
+	case ptp.EC_StoreAdded:
+		return "store added"
+	case ptp.EC_StoreRemoved:
+		return "store removed"
+	case ptp.EC_DevicePropChanged:
+		return "device property changed"
+	case ptp.EC_ObjectInfoChanged:
+		return "object info changed"
+	case ptp.EC_DeviceInfoChanged:
+		return "device info changed"
+	case ptp.EC_RequestObjectTransfer:
+		return "request object transfer"
+	case ptp.EC_StoreFull:
+		return "store full"
+	case ptp.EC_DeviceReset:
+		return "device reset"
+	case ptp.EC_StorageInfoChanged:
+		return "storage info changed"
+	case ptp.EC_CaptureComplete:
+		return "capture complete"
+	case ptp.EC_UnreportedStatus:
+		return "unreported status"
+	default:
+		return ""
+	}
+}
+
 func FormFlagAsString(flag ptp.DevicePropFormFlag) string {
 	switch flag {
 	case ptp.DPF_FormFlag_None:
@@ -190,11 +226,100 @@ func DevicePropValueAsString(code ptp.DevicePropCode, v int64) string {
 		return StillCaptureModeAsString(ptp.StillCaptureMode(v))
 	case ptp.DPC_WhiteBalance:
 		return WhiteBalanceAsString(ptp.WhiteBalance(v))
+	case ptp.DPC_FocalLength:
+		return FocalLengthAsString(uint32(v))
+	case ptp.DPC_FocusDistance:
+		return FocusDistanceAsString(uint16(v))
+	case ptp.DPC_ExposureTime:
+		return ExposureTimeAsString(uint32(v))
+	case ptp.DPC_ExposureIndex:
+		return ExposureIndexAsString(uint16(v))
+	case ptp.DPC_CaptureDelay:
+		return CaptureDelayAsString(uint32(v))
+	case ptp.DPC_DigitalZoom:
+		return DigitalZoomAsString(uint8(v))
+	case ptp.DPC_BurstInterval, ptp.DPC_TimelapseInterval:
+		return MillisecondsAsString(uint32(v))
 	default:
 		return ""
 	}
 }
 
+// CaptureDelayAsString formats the capture delay in milliseconds, returning "off" when there is no delay.
+func CaptureDelayAsString(cd uint32) string {
+	if cd == 0 {
+		return "off"
+	}
+
+	return MillisecondsAsString(cd)
+}
+
+// DigitalZoomAsString formats the digital zoom ratio which is scaled by a factor of 10, e.g. 20 is returned as "2.0x".
+func DigitalZoomAsString(dz uint8) string {
+	return fmt.Sprintf("%.1fx", float32(dz)/10)
+}
+
+// ExposureIndexAsString formats the ISO setting. A value of 0xffff corresponds to automatic ISO.
+func ExposureIndexAsString(edx uint16) string {
+	if edx == 0xffff {
+		return "auto"
+	}
+
+	return strconv.FormatUint(uint64(edx), 10)
+}
+
+// ExposureTimeAsString formats the shutter speed, which is expressed in seconds scaled by 10000, as a fraction of a
+// second, e.g. "1/250", or as a number of seconds, e.g. "2.5s".
+func ExposureTimeAsString(et uint32) string {
+	switch {
+	case et == 0:
+		return ""
+	case et >= 10000:
+		return strconv.FormatFloat(float64(et)/10000, 'f', -1, 64) + "s"
+	}
+
+	return fmt.Sprintf("1/%d", int(math.Round(10000/float64(et))))
+}
+
+// fractionAsSeconds formats a shutter speed expressed as a fraction of seconds in the same way as ExposureTimeAsString,
+// except that speeds from 0.3 seconds up to one second are formatted in seconds as well, e.g. "0.8s".
+func fractionAsSeconds(num, den uint32) string {
+	switch {
+	case num == 0 || den == 0:
+		return ""
+	case num == 1 && den > 1:
+		return fmt.Sprintf("1/%d", den)
+	case float64(num)/float64(den) >= 0.3:
+		return strconv.FormatFloat(math.Round(float64(num)/float64(den)*10)/10, 'f', -1, 64) + "s"
+	}
+
+	return fmt.Sprintf("1/%d", int(math.Round(float64(den)/float64(num))))
+}
+
+// FocalLengthAsString formats the 35mm equivalent focal length, which is expressed in millimeters scaled by 100.
+func FocalLengthAsString(fl uint32) string {
+	return strconv.FormatFloat(float64(fl)/100, 'f', -1, 64) + "mm"
+}
+
+// FocusDistanceAsString formats the focus distance, which is expressed in millimeters. A value of 0xffff corresponds to
+// a distance greater than 655 meters.
+func FocusDistanceAsString(fd uint16) string {
+	if fd == 0xffff {
+		return "infinity"
+	}
+
+	return strconv.FormatFloat(float64(fd)/1000, 'f', -1, 64) + "m"
+}
+
+// MillisecondsAsString formats a duration in milliseconds, using seconds when possible.
+func MillisecondsAsString(ms uint32) string {
+	if ms != 0 && ms%1000 == 0 {
+		return fmt.Sprintf("%ds", ms/1000)
+	}
+
+	return fmt.Sprintf("%dms", ms)
+}
+
 func FNumberAsString(fn uint16) string {
 	if fn == 0xffff {
 		return "automatic"
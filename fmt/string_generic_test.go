@@ -50,6 +50,33 @@ func TestGenericDevicePropCodeAsString(t *testing.T) {
 	}
 }
 
+func TestGenericEventCodeAsString(t *testing.T) {
+	check := map[ptp.EventCode]string{
+		ptp.EC_CancelTransaction:     "cancel transaction",
+		ptp.EC_ObjectAdded:           "object added",
+		ptp.EC_ObjectRemoved:         "object removed",
+		ptp.EC_StoreAdded:            "store added",
+		ptp.EC_StoreRemoved:          "store removed",
+		ptp.EC_DevicePropChanged:     "device property changed",
+		ptp.EC_ObjectInfoChanged:     "object info changed",
+		ptp.EC_DeviceInfoChanged:     "device info changed",
+		ptp.EC_RequestObjectTransfer: "request object transfer",
+		ptp.EC_StoreFull:             "store full",
+		ptp.EC_DeviceReset:           "device reset",
+		ptp.EC_StorageInfoChanged:    "storage info changed",
+		ptp.EC_CaptureComplete:       "capture complete",
+		ptp.EC_UnreportedStatus:      "unreported status",
+		ptp.EC_Undefined:             "",
+	}
+
+	for code, want := range check {
+		got := GenericEventCodeAsString(code)
+		if got != want {
+			t.Errorf("GenericEventCodeAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
 func TestPropToDevicePropCode(t *testing.T) {
 	check := map[string]ptp.DevicePropCode{
 		PRP_Delay:             ptp.DPC_CaptureDelay,
@@ -216,6 +243,48 @@ var modes = map[ptp.DevicePropCode]map[int64]string{
 		int64(ptp.SCM_Timelapse):       "timelapse",
 		int64(ptp.StillCaptureMode(4)): "",
 	},
+	ptp.DPC_CaptureDelay: {
+		int64(0):     "off",
+		int64(500):   "500ms",
+		int64(2000):  "2s",
+		int64(10000): "10s",
+	},
+	ptp.DPC_DigitalZoom: {
+		int64(10): "1.0x",
+		int64(25): "2.5x",
+	},
+	ptp.DPC_ExposureIndex: {
+		int64(100):    "100",
+		int64(6400):   "6400",
+		int64(0xffff): "auto",
+	},
+	ptp.DPC_ExposureTime: {
+		int64(0):      "",
+		int64(1):      "1/10000",
+		int64(40):     "1/250",
+		int64(333):    "1/30",
+		int64(3333):   "1/3",
+		int64(10000):  "1s",
+		int64(25000):  "2.5s",
+		int64(300000): "30s",
+	},
+	ptp.DPC_FocalLength: {
+		int64(2300): "23mm",
+		int64(3550): "35.5mm",
+	},
+	ptp.DPC_FocusDistance: {
+		int64(500):    "0.5m",
+		int64(2000):   "2m",
+		int64(0xffff): "infinity",
+	},
+	ptp.DPC_BurstInterval: {
+		int64(250):  "250ms",
+		int64(1000): "1s",
+	},
+	ptp.DPC_TimelapseInterval: {
+		int64(0):     "0ms",
+		int64(60000): "60s",
+	},
 	ptp.DPC_WhiteBalance: {
 		int64(ptp.WB_Undefined):        "undefined",
 		int64(ptp.WB_Manual):           "manual",
@@ -357,3 +426,66 @@ func TestWhiteBalanceAsString(t *testing.T) {
 		}
 	}
 }
+
+func TestCaptureDelayAsString(t *testing.T) {
+	for cd, want := range modes[ptp.DPC_CaptureDelay] {
+		got := CaptureDelayAsString(uint32(cd))
+		if got != want {
+			t.Errorf("CaptureDelayAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestDigitalZoomAsString(t *testing.T) {
+	for dz, want := range modes[ptp.DPC_DigitalZoom] {
+		got := DigitalZoomAsString(uint8(dz))
+		if got != want {
+			t.Errorf("DigitalZoomAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestExposureIndexAsString(t *testing.T) {
+	for edx, want := range modes[ptp.DPC_ExposureIndex] {
+		got := ExposureIndexAsString(uint16(edx))
+		if got != want {
+			t.Errorf("ExposureIndexAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestExposureTimeAsString(t *testing.T) {
+	for et, want := range modes[ptp.DPC_ExposureTime] {
+		got := ExposureTimeAsString(uint32(et))
+		if got != want {
+			t.Errorf("ExposureTimeAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestFocalLengthAsString(t *testing.T) {
+	for fl, want := range modes[ptp.DPC_FocalLength] {
+		got := FocalLengthAsString(uint32(fl))
+		if got != want {
+			t.Errorf("FocalLengthAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestFocusDistanceAsString(t *testing.T) {
+	for fd, want := range modes[ptp.DPC_FocusDistance] {
+		got := FocusDistanceAsString(uint16(fd))
+		if got != want {
+			t.Errorf("FocusDistanceAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestMillisecondsAsString(t *testing.T) {
+	for ms, want := range modes[ptp.DPC_BurstInterval] {
+		got := MillisecondsAsString(uint32(ms))
+		if got != want {
+			t.Errorf("MillisecondsAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package fmt
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strconv"
+)
+
+func NikonDevicePropCodeAsString(code ptp.DevicePropCode) string {
+	switch code {
+	case ip.DPC_Nikon_ExposureTime:
+		return "shutter speed"
+	case ip.DPC_Nikon_LiveViewImageZoomRatio:
+		return "liveview zoom ratio"
+	default:
+		return GenericDevicePropCodeAsString(code)
+	}
+}
+
+func NikonDevicePropValueAsString(code ptp.DevicePropCode, v int64) string {
+	switch code {
+	case ip.DPC_Nikon_ExposureTime:
+		return NikonExposureTimeAsString(uint32(v))
+	case ip.DPC_Nikon_LiveViewImageZoomRatio:
+		return NikonLiveViewZoomRatioAsString(uint8(v))
+	default:
+		return DevicePropValueAsString(code, v)
+	}
+}
+
+// NikonExposureTimeAsString formats the shutter speed, which is expressed as a fraction of seconds, in the same way as
+// ExposureTimeAsString.
+func NikonExposureTimeAsString(et uint32) string {
+	if et == 0xffffffff {
+		return "bulb"
+	}
+
+	return fractionAsSeconds(et>>16, et&0xffff)
+}
+
+// NikonLiveViewZoomRatioAsString formats the liveview magnification, where 0 is the entire frame.
+func NikonLiveViewZoomRatioAsString(zr uint8) string {
+	if zr == 0 {
+		return "off"
+	}
+
+	return strconv.Itoa(int(zr))
+}
@@ -0,0 +1,48 @@
+package fmt
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"testing"
+)
+
+func TestNikonDevicePropCodeAsString(t *testing.T) {
+	check := map[ptp.DevicePropCode]string{
+		ip.DPC_Nikon_ExposureTime:           "shutter speed",
+		ip.DPC_Nikon_LiveViewImageZoomRatio: "liveview zoom ratio",
+		ptp.DPC_FNumber:                     "F-number",
+		ptp.DevicePropCode(0):               "",
+	}
+
+	for code, want := range check {
+		got := NikonDevicePropCodeAsString(code)
+		if got != want {
+			t.Errorf("NikonDevicePropCodeAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestNikonDevicePropValueAsString(t *testing.T) {
+	check := []struct {
+		code ptp.DevicePropCode
+		v    int64
+		want string
+	}{
+		{ip.DPC_Nikon_ExposureTime, 0xffffffff, "bulb"},
+		{ip.DPC_Nikon_ExposureTime, 0x000100fa, "1/250"},
+		{ip.DPC_Nikon_ExposureTime, 0x000a000d, "0.8s"},
+		{ip.DPC_Nikon_ExposureTime, 0x000a0fa0, "1/400"},
+		{ip.DPC_Nikon_ExposureTime, 0x000d000a, "1.3s"},
+		{ip.DPC_Nikon_ExposureTime, 0x001e0001, "30s"},
+		{ip.DPC_Nikon_LiveViewImageZoomRatio, 0, "off"},
+		{ip.DPC_Nikon_LiveViewImageZoomRatio, 5, "5"},
+		{ptp.DPC_FNumber, 560, "f/5.6"},
+	}
+
+	for _, tt := range check {
+		got := NikonDevicePropValueAsString(tt.code, tt.v)
+		if got != tt.want {
+			t.Errorf("NikonDevicePropValueAsString() %#x return = '%s', want '%s'", tt.v, got, tt.want)
+		}
+	}
+}
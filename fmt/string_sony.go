@@ -0,0 +1,64 @@
+package fmt
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"strconv"
+)
+
+func SonyDevicePropCodeAsString(code ptp.DevicePropCode) string {
+	switch code {
+	case ip.DPC_Sony_ShutterSpeed:
+		return "shutter speed"
+	case ip.DPC_Sony_ISO:
+		return "ISO"
+	default:
+		return GenericDevicePropCodeAsString(code)
+	}
+}
+
+// SonyPropToDevicePropCode converts a standardised property string to a valid ptp.DevicePropertyCode.
+func SonyPropToDevicePropCode(field string) (ptp.DevicePropCode, error) {
+	switch field {
+	case PRP_Exposure:
+		return ip.DPC_Sony_ShutterSpeed, nil
+	case PRP_ISO:
+		return ip.DPC_Sony_ISO, nil
+	default:
+		return GenericPropToDevicePropCode(field)
+	}
+}
+
+func SonyDevicePropValueAsString(code ptp.DevicePropCode, v int64) string {
+	switch code {
+	case ip.DPC_Sony_ShutterSpeed:
+		return SonyShutterSpeedAsString(uint32(v))
+	case ip.DPC_Sony_ISO:
+		return SonyISOAsString(uint32(v))
+	default:
+		return DevicePropValueAsString(code, v)
+	}
+}
+
+// SonyShutterSpeedAsString formats the shutter speed, which is expressed as a fraction of seconds, in the same way as
+// ExposureTimeAsString.
+func SonyShutterSpeedAsString(ss uint32) string {
+	if ss == 0 {
+		return "bulb"
+	}
+
+	return fractionAsSeconds(ss>>16, ss&0xffff)
+}
+
+// SonyISOAsString formats the ISO, adding "multi frame NR" when multi frame noise reduction is enabled.
+func SonyISOAsString(iso uint32) string {
+	s := "auto"
+	if v := iso & 0x00ffffff; v != 0x00ffffff {
+		s = strconv.FormatUint(uint64(v), 10)
+	}
+	if iso>>24 != 0 {
+		s += " multi frame NR"
+	}
+
+	return s
+}
@@ -0,0 +1,64 @@
+package fmt
+
+import (
+	"github.com/malc0mn/ptp-ip/ip"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"testing"
+)
+
+func TestSonyDevicePropCodeAsString(t *testing.T) {
+	check := map[ptp.DevicePropCode]string{
+		ip.DPC_Sony_ShutterSpeed: "shutter speed",
+		ip.DPC_Sony_ISO:          "ISO",
+		ptp.DPC_FNumber:          "F-number",
+		ptp.DevicePropCode(0):    "",
+	}
+
+	for code, want := range check {
+		got := SonyDevicePropCodeAsString(code)
+		if got != want {
+			t.Errorf("SonyDevicePropCodeAsString() return = '%s', want '%s'", got, want)
+		}
+	}
+}
+
+func TestSonyPropToDevicePropCode(t *testing.T) {
+	check := map[string]ptp.DevicePropCode{
+		PRP_Exposure: ip.DPC_Sony_ShutterSpeed,
+		PRP_ISO:      ip.DPC_Sony_ISO,
+		PRP_ExpBias:  ptp.DPC_ExposureBiasCompensation,
+	}
+
+	for prop, want := range check {
+		got, err := SonyPropToDevicePropCode(prop)
+		if err != nil {
+			t.Errorf("SonyPropToDevicePropCode() error = %s, want <nil>", err)
+		}
+		if got != want {
+			t.Errorf("SonyPropToDevicePropCode() return = '%#x', want '%#x'", got, want)
+		}
+	}
+}
+
+func TestSonyDevicePropValueAsString(t *testing.T) {
+	check := []struct {
+		code ptp.DevicePropCode
+		v    int64
+		want string
+	}{
+		{ip.DPC_Sony_ShutterSpeed, 0, "bulb"},
+		{ip.DPC_Sony_ShutterSpeed, 0x00010fa0, "1/4000"},
+		{ip.DPC_Sony_ShutterSpeed, 0x0019000a, "2.5s"},
+		{ip.DPC_Sony_ISO, 0x00ffffff, "auto"},
+		{ip.DPC_Sony_ISO, 0x00000190, "400"},
+		{ip.DPC_Sony_ISO, 0x01000c80, "3200 multi frame NR"},
+		{ptp.DPC_ExposureBiasCompensation, 667, "2/3"},
+	}
+
+	for _, tt := range check {
+		got := SonyDevicePropValueAsString(tt.code, tt.v)
+		if got != tt.want {
+			t.Errorf("SonyDevicePropValueAsString() %#x return = '%s', want '%s'", tt.v, got, tt.want)
+		}
+	}
+}
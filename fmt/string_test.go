@@ -62,6 +62,26 @@ func TestDevicePropCodeAsString(t *testing.T) {
 	}
 }
 
+func TestEventCodeAsString(t *testing.T) {
+	want := "preview available"
+	got := EventCodeAsString(ptp.VE_FujiPhotoFilmCoLtd, ip.EC_Fuji_PreviewAvailable)
+	if got != want {
+		t.Errorf("EventCodeAsString() got = %s; want %s", got, want)
+	}
+
+	want = ""
+	got = EventCodeAsString(ptp.VE_MicrosoftCorporation, ip.EC_Fuji_PreviewAvailable)
+	if got != want {
+		t.Errorf("EventCodeAsString() got = %s; want %s", got, want)
+	}
+
+	want = "object added"
+	got = EventCodeAsString(ptp.VE_MicrosoftCorporation, ptp.EC_ObjectAdded)
+	if got != want {
+		t.Errorf("EventCodeAsString() got = %s; want %s", got, want)
+	}
+}
+
 func TestPropNameToDevicePropCode(t *testing.T) {
 	want := ip.DPC_Fuji_ExposureIndex
 	got, err := PropNameToDevicePropCode(ptp.VE_FujiPhotoFilmCoLtd, "iso")
@@ -105,4 +125,56 @@ func TestDevicePropValAsString(t *testing.T) {
 	if got != want {
 		t.Errorf("DevicePropValAsString() got = %s; want %s", got, want)
 	}
+
+	// Standard properties Fuji does not format itself fall back to the generic formatting.
+	want = "1/250"
+	got = DevicePropValAsString(ptp.VE_FujiPhotoFilmCoLtd, ptp.DPC_ExposureTime, 40)
+
+	if got != want {
+		t.Errorf("DevicePropValAsString() got = %s; want %s", got, want)
+	}
+}
+
+func TestDevicePropValFromString(t *testing.T) {
+	check := []struct {
+		vendor ptp.VendorExtension
+		code   ptp.DevicePropCode
+		in     string
+		values []int64
+		want   int64
+	}{
+		{ptp.VE_FujiPhotoFilmCoLtd, ip.DPC_Fuji_FilmSimulation, "classic chrome", []int64{1, 3, 11}, 11},
+		{ptp.VE_FujiPhotoFilmCoLtd, ip.DPC_Fuji_FilmSimulation, "Pro Neg. Hi", []int64{1, 6}, 6},
+		{ptp.VE_FujiPhotoFilmCoLtd, ip.DPC_Fuji_ExposureIndex, "h12800", []int64{0x00000190, 0x40003200}, 0x40003200},
+		{ptp.VE_FujiPhotoFilmCoLtd, ip.DPC_Fuji_ExposureIndex, "400", []int64{0x00000190, 0x40003200}, 0x00000190},
+		{ptp.VendorExtension(0), ptp.DPC_WhiteBalance, "Daylight", []int64{2, 4, 6}, 4},
+		{ptp.VendorExtension(0), ptp.DPC_FNumber, "5.6", []int64{400, 560}, 560},
+		{ptp.VendorExtension(0), ptp.DPC_FNumber, "f/4", []int64{400, 560}, 400},
+		{ptp.VendorExtension(0), ptp.DPC_ExposureBiasCompensation, "+1 1/3", []int64{-1333, 0, 1333}, 1333},
+		{ptp.VendorExtension(0), ptp.DPC_ExposureBiasCompensation, "-1/3", []int64{-333, 0, 333}, -333},
+		{ptp.VendorExtension(0), ptp.DPC_ExposureTime, "1/500", []int64{10, 20}, 20},
+		{ptp.VendorExtension(0), ptp.DPC_Contrast, "0xa", []int64{0xa, 0x14}, 0xa},
+	}
+
+	for _, tt := range check {
+		got, err := DevicePropValFromString(tt.vendor, tt.code, tt.in, tt.values)
+		if err != nil {
+			t.Errorf("DevicePropValFromString() error = %s; want <nil>", err)
+		}
+		if got != tt.want {
+			t.Errorf("DevicePropValFromString() got = %#x; want %#x", got, tt.want)
+		}
+	}
+
+	_, err := DevicePropValFromString(ptp.VendorExtension(0), ptp.DPC_WhiteBalance, "shade", []int64{2, 4})
+	want := "invalid value 'shade', must be one of: automatic, daylight"
+	if err == nil || err.Error() != want {
+		t.Errorf("DevicePropValFromString() error = %v; want %s", err, want)
+	}
+
+	_, err = DevicePropValFromString(ptp.VendorExtension(0), ptp.DPC_WhiteBalance, "shade", nil)
+	want = "invalid value 'shade': the allowed values are unknown"
+	if err == nil || err.Error() != want {
+		t.Errorf("DevicePropValFromString() error = %v; want %s", err, want)
+	}
 }
@@ -0,0 +1,109 @@
+package ip
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultBurstGap is the maximum time between two shots of a burst used by GroupBursts when no gap is given. Even slow
+// bursts shoot a few frames per second.
+const DefaultBurstGap = time.Second
+
+// Burst is a sequence of shots captured in a single burst, each shot holding the objects captured at once, such as a
+// RAW file and a JPEG image when shooting RAW+JPEG. A single shot is a burst of one shot.
+type Burst struct {
+	Shots [][]Object
+}
+
+// Objects returns the objects of all shots in the order they were captured.
+func (b Burst) Objects() []Object {
+	var objs []Object
+	for _, shot := range b.Shots {
+		objs = append(objs, shot...)
+	}
+
+	return objs
+}
+
+// Start returns the capture date of the first shot of the burst.
+func (b Burst) Start() time.Time {
+	return b.Shots[0][0].Info.CaptureDate
+}
+
+// GroupBursts groups the images into bursts, ordered by capture date. Consecutive shots belong to the same burst when
+// the camera numbered them as a sequence using the SequenceNumber of their ObjectInfo dataset, or when they were
+// captured no more than gap apart. The objects of a single shot are recognised by their file name, which only differs
+// in its extension. Objects other than images, such as videos, always form a burst of their own.
+func GroupBursts(objs []Object, gap time.Duration) []Burst {
+	if gap <= 0 {
+		gap = DefaultBurstGap
+	}
+
+	var (
+		shots [][]Object
+		index = make(map[string]int)
+	)
+	for _, obj := range objs {
+		key := shotKey(obj)
+		if i, ok := index[key]; ok && key != "" {
+			shots[i] = append(shots[i], obj)
+			continue
+		}
+		index[key] = len(shots)
+		shots = append(shots, []Object{obj})
+	}
+	sort.SliceStable(shots, func(i, j int) bool {
+		return shots[i][0].Info.CaptureDate.Before(shots[j][0].Info.CaptureDate)
+	})
+
+	var bursts []Burst
+	for i, shot := range shots {
+		if i > 0 && sameBurst(shots[i-1][0], shot[0], gap) {
+			b := &bursts[len(bursts)-1]
+			b.Shots = append(b.Shots, shot)
+			continue
+		}
+		bursts = append(bursts, Burst{Shots: [][]Object{shot}})
+	}
+
+	return bursts
+}
+
+// shotKey returns the key identifying the shot the object belongs to: the parent folder and the file name without its
+// extension. An empty key is returned for objects that are not images.
+func shotKey(obj Object) string {
+	switch KindOfObject(obj.Info) {
+	case ObjectKindJPEG, ObjectKindRAW:
+	default:
+		return ""
+	}
+
+	name := strings.ToUpper(obj.Info.Filename)
+
+	return fmt.Sprintf("%x/%s", obj.Info.ParentObject, strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// sameBurst reports whether cur was captured in the same burst as prev, which is the shot captured right before it.
+func sameBurst(prev, cur Object, gap time.Duration) bool {
+	if shotKey(prev) == "" || shotKey(cur) == "" {
+		return false
+	}
+
+	p, c := prev.Info.SequenceNumber, cur.Info.SequenceNumber
+	switch {
+	case c == 1:
+		return false
+	case p > 0 && c == p+1:
+		return true
+	}
+
+	pd, cd := prev.Info.CaptureDate, cur.Info.CaptureDate
+	if pd.IsZero() || cd.IsZero() {
+		return false
+	}
+
+	return cd.Sub(pd) <= gap
+}
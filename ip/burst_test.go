@@ -0,0 +1,57 @@
+package ip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestGroupBursts(t *testing.T) {
+	start := time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local)
+	obj := func(h ptp.ObjectHandle, name string, offset time.Duration, seq uint32) Object {
+		return Object{Handle: h, Info: &ptp.ObjectInfo{Filename: name, CaptureDate: start.Add(offset), SequenceNumber: seq}}
+	}
+	objs := []Object{
+		// A burst of three RAW+JPEG shots.
+		obj(1, "DSCF0001.JPG", 0, 0),
+		obj(2, "DSCF0001.RAF", 0, 0),
+		obj(3, "DSCF0002.JPG", 200*time.Millisecond, 0),
+		obj(4, "DSCF0002.RAF", 200*time.Millisecond, 0),
+		obj(5, "DSCF0003.JPG", 400*time.Millisecond, 0),
+		obj(6, "DSCF0003.RAF", 400*time.Millisecond, 0),
+		// A video captured right after the burst.
+		obj(7, "DSCF0004.MOV", 500*time.Millisecond, 0),
+		// A single shot.
+		obj(8, "DSCF0005.JPG", time.Minute, 0),
+		// A slow sequence numbered by the camera followed by a new sequence.
+		obj(9, "DSCF0006.JPG", 2*time.Minute, 1),
+		obj(10, "DSCF0007.JPG", 2*time.Minute+3*time.Second, 2),
+		obj(11, "DSCF0008.JPG", 2*time.Minute+3500*time.Millisecond, 1),
+	}
+
+	got := GroupBursts(objs, 0)
+	want := [][]ptp.ObjectHandle{{1, 2, 3, 4, 5, 6}, {7}, {8}, {9, 10}, {11}}
+	if len(got) != len(want) {
+		t.Fatalf("GroupBursts() got %d bursts; want %d", len(got), len(want))
+	}
+	for i, b := range got {
+		var handles []ptp.ObjectHandle
+		for _, obj := range b.Objects() {
+			handles = append(handles, obj.Handle)
+		}
+		if len(handles) != len(want[i]) {
+			t.Errorf("GroupBursts() burst %d = %v; want %v", i, handles, want[i])
+			continue
+		}
+		for j := range handles {
+			if handles[j] != want[i][j] {
+				t.Errorf("GroupBursts() burst %d = %v; want %v", i, handles, want[i])
+				break
+			}
+		}
+	}
+	if len(got[0].Shots) != 3 || !got[0].Start().Equal(start) {
+		t.Errorf("GroupBursts() first burst has %d shots starting %s; want 3 starting %s", len(got[0].Shots), got[0].Start(), start)
+	}
+}
@@ -0,0 +1,44 @@
+package ip
+
+// Capability is a feature of the client that depends on the vendor of the responder.
+type Capability string
+
+const (
+	CapDeviceInfo          Capability = "device-info"
+	CapDeviceState         Capability = "device-state"
+	CapPropertyDescription Capability = "property-description"
+	CapSetProperty         Capability = "set-property"
+	CapCapture             Capability = "capture"
+	CapObjects             Capability = "objects"
+	CapLiveview            Capability = "liveview"
+	CapLiveviewZoom        Capability = "liveview-zoom"
+	// CapChangeClient means the responder pairs with a single initiator and can be made to accept another one.
+	CapChangeClient Capability = "change-client"
+	// CapEventPolling means the client polls the responder for events that are not reported on the event connection.
+	CapEventPolling Capability = "event-polling"
+)
+
+// SupportedVendors returns the vendors that have vendor specific support, using the names accepted by NewClient. Any
+// other vendor is handled as "generic".
+func SupportedVendors() []string {
+	return []string{"canon", "fuji", "generic", "nikon", "sony"}
+}
+
+// VendorCapabilities returns the capabilities of the client for the given vendor. This must be kept in sync with
+// loadVendorExtensions().
+func VendorCapabilities(vendor string) []Capability {
+	caps := []Capability{CapDeviceInfo, CapObjects, CapCapture}
+
+	switch vendor {
+	case "canon":
+		caps = append(caps, CapLiveview)
+	case "fuji":
+		caps = append(caps, CapDeviceState, CapPropertyDescription, CapSetProperty, CapLiveview, CapChangeClient)
+	case "nikon":
+		caps = append(caps, CapLiveview, CapLiveviewZoom, CapEventPolling)
+	case "sony":
+		caps = append(caps, CapLiveview, CapEventPolling)
+	}
+
+	return caps
+}
@@ -0,0 +1,24 @@
+package ip
+
+import "testing"
+
+func TestVendorCapabilities(t *testing.T) {
+	has := func(caps []Capability, want Capability) bool {
+		for _, c := range caps {
+			if c == want {
+				return true
+			}
+		}
+		return false
+	}
+
+	if got := VendorCapabilities("fuji"); !has(got, CapCapture) || !has(got, CapLiveview) {
+		t.Errorf("VendorCapabilities() fuji got = %v; want %s and %s", got, CapCapture, CapLiveview)
+	}
+	if got := VendorCapabilities("nikon"); !has(got, CapLiveviewZoom) {
+		t.Errorf("VendorCapabilities() nikon got = %v; want %s", got, CapLiveviewZoom)
+	}
+	if got := VendorCapabilities("unknown"); len(got) != 3 || !has(got, CapCapture) || has(got, CapLiveview) {
+		t.Errorf("VendorCapabilities() unknown got = %v; want %s, %s and %s only", got, CapDeviceInfo, CapObjects, CapCapture)
+	}
+}
@@ -0,0 +1,106 @@
+package ip
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// ClientAPI is the public surface of the Client used to talk to a camera. Applications can accept a ClientAPI instead
+// of a *Client, allowing them to replace the camera with a mock in their unit tests without having to run a mock
+// responder on a network listener.
+// The low level methods used to send and receive individual packets are not part of the interface. To mock only part
+// of the interface, embed ClientAPI in the mock struct and implement the methods needed by the test.
+type ClientAPI interface {
+	Logger
+
+	// Connection.
+	Dial() error
+	DialWithStreamer() error
+	ChangeClient(timeout time.Duration) error
+	ResetConnection() error
+	Probe(ctx context.Context) (time.Duration, error)
+	CloseSession() error
+	Shutdown() error
+	Close() error
+	ConnectionNumber() uint32
+	SessionId() ptp.SessionID
+	TransactionId() ptp.TransactionID
+	Network() string
+	CommandDataAddress() string
+	EventAddress() string
+	StreamerAddress() string
+
+	// Initiator and Responder.
+	InitiatorFriendlyName() string
+	InitiatorGUID() uuid.UUID
+	InitiatorGUIDAsString() string
+	ResponderFriendlyName() string
+	ResponderGUID() uuid.UUID
+	ResponderGUIDAsString() string
+	ResponderVendor() ptp.VendorExtension
+
+	// Operations.
+	GetDeviceInfo() (interface{}, error)
+	CachedDeviceInfo() interface{}
+	GetDeviceState() (interface{}, error)
+	OperationRequestRaw(code ptp.OperationCode, params []uint32) ([]byte, error)
+	OperationRequestDataRaw(code ptp.OperationCode, params []uint32) ([]byte, error)
+	OperationRequestData(code ptp.OperationCode, params []uint32) ([]byte, error)
+	SendData(code ptp.OperationCode, params []uint32, dataSend []byte, len uint64) ([]byte, error)
+	SendDataContext(ctx context.Context, code ptp.OperationCode, params []uint32, dataSend []byte, len uint64) ([]byte, error)
+	ResponseCode(p []byte) (ptp.OperationResponseCode, bool)
+	DumpRawPacket(raw []byte) string
+	SendRawPacket(ctx context.Context, conn string, data []byte) ([][]byte, error)
+	CheckConformance() []ConformanceResult
+
+	// Properties.
+	GetDevicePropertyDescription(code ptp.DevicePropCode) (*ptp.DevicePropDesc, error)
+	CachedDevicePropertyDescription(code ptp.DevicePropCode) *ptp.DevicePropDesc
+	GetDevicePropertyValue(code ptp.DevicePropCode) (uint32, error)
+	GetDevicePropertyValues(codes []ptp.DevicePropCode) ([]uint32, error)
+	SetDeviceProperty(code ptp.DevicePropCode, val uint32) error
+	WatchProperties(codes []ptp.DevicePropCode, interval time.Duration, f func(PropertyChange)) func()
+
+	// Capture, storage and objects.
+	InitiateCapture() ([]byte, error)
+	GetStorageIDs() ([]ptp.StorageID, error)
+	GetStorageInfo(sid ptp.StorageID) (*ptp.StorageInfo, error)
+	GetStorages() ([]Storage, error)
+	GetObjectHandles(sid ptp.StorageID, ofc ptp.ObjectFormatCode, parent ptp.ObjectHandle) ([]ptp.ObjectHandle, error)
+	GetNumObjects(sid ptp.StorageID, ofc ptp.ObjectFormatCode, parent ptp.ObjectHandle) (int, error)
+	GetObjectInfo(h ptp.ObjectHandle) (*ptp.ObjectInfo, error)
+	GetObjectInfos(handles []ptp.ObjectHandle) ([]*ptp.ObjectInfo, error)
+	GetObject(h ptp.ObjectHandle) ([]byte, error)
+	GetObjectTo(h ptp.ObjectHandle, w io.Writer) (int64, error)
+	GetThumb(h ptp.ObjectHandle) ([]byte, error)
+	GetObjectMetadata(h ptp.ObjectHandle) (*ObjectMetadata, error)
+	SetObjectProtection(h ptp.ObjectHandle, status ptp.ProtectionStatus) error
+	SetObjectRating(h ptp.ObjectHandle, rating int) error
+	ObjectPath(h ptp.ObjectHandle) (string, error)
+	SendObject(sid ptp.StorageID, parent ptp.ObjectHandle, oi *ptp.ObjectInfo, data []byte) (ptp.ObjectHandle, error)
+	GetPrintOrder() (*PrintOrder, error)
+	SetPrintOrder(po *PrintOrder) error
+	FindObjects(f ObjectFilter) ([]Object, error)
+	ObjectTree(sid ptp.StorageID) *ObjectTree
+	DownloadObjects(objs []Object, skip func(Object) bool, write func(Object, []byte) error, progress func(DownloadProgress)) error
+	SetTransferRateLimit(bytesPerSecond int64)
+	TransferRateLimit() int64
+
+	// Events.
+	OnEvent(f func(EventPacket)) func()
+	WaitForEvent(ctx context.Context, codes ...ptp.EventCode) (EventPacket, error)
+
+	// Liveview.
+	ToggleLiveView(en bool) error
+	SetLiveviewZoom(level int) (int, error)
+	OnLiveviewFrame(f func(frame []byte, meta FrameMeta))
+	SubscribeLiveview(buffer int, policy DropPolicy) *FrameSubscription
+	LiveviewFrames(buffer int) <-chan LiveviewFrame
+	LiveviewSnapshot() (LiveviewFrame, error)
+}
+
+var _ ClientAPI = (*Client)(nil)
@@ -0,0 +1,275 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// ConformanceStatus is the outcome of a single conformance check.
+type ConformanceStatus int
+
+const (
+	CS_Pass ConformanceStatus = iota
+	CS_Fail
+	CS_Skip
+)
+
+func (s ConformanceStatus) String() string {
+	switch s {
+	case CS_Pass:
+		return "PASS"
+	case CS_Fail:
+		return "FAIL"
+	case CS_Skip:
+		return "SKIP"
+	}
+
+	return fmt.Sprintf("ConformanceStatus(%d)", int(s))
+}
+
+// ConformanceResult holds the outcome of a conformance check run by CheckConformance.
+type ConformanceResult struct {
+	// Group is the part of the protocol the check covers: init, probe, data or cancel.
+	Group  string
+	Name   string
+	Status ConformanceStatus
+	// Detail explains the outcome, e.g. what the Responder answered.
+	Detail string
+}
+
+const (
+	// conformanceFriendlyName is the friendly name used by the extra Initiators connecting during the init checks.
+	conformanceFriendlyName = "PTP/IP conformance check"
+	// unsupportedOperation is an operation code reserved by the PTP standard, which no Responder can support.
+	unsupportedOperation ptp.OperationCode = 0x1FFF
+	// unsupportedProperty is a device property code reserved by the PTP standard, which no Responder can support.
+	unsupportedProperty ptp.DevicePropCode = 0x5FFF
+)
+
+// conformanceCheck is a single check of the conformance suite.
+type conformanceCheck struct {
+	group string
+	name  string
+	// standard indicates that the check sends standard PTP/IP packets, which is skipped for vendors using their own
+	// packet layout.
+	standard bool
+	run      func(c *Client) (ConformanceStatus, string)
+}
+
+var conformanceChecks = []conformanceCheck{
+	{"init", "a second Initiator is answered", true, checkSecondInitiator},
+	{"init", "an unsupported protocol version is answered", true, checkUnsupportedProtocolVersion},
+	{"init", "an event connection with an unknown connection number is rejected", true, checkUnknownConnectionNumber},
+	{"probe", "a probe request is answered", true, checkProbe},
+	{"data", "a data-in phase is returned", false, checkDataIn},
+	{"data", "an unsupported operation is rejected", false, checkUnsupportedOperation},
+	{"data", "an unsupported property is rejected without data phase", false, checkUnsupportedProperty},
+	{"data", "an empty data-out phase is answered", false, checkEmptyDataOut},
+	{"cancel", "a cancelled data-out phase is ended", true, checkCancelDataOut},
+}
+
+// CheckConformance runs a battery of protocol checks against the connected Responder and returns the outcome of each
+// check. The checks cover the init handshake, probe handling, data phase edge cases and cancelling a transaction. They
+// are meant for camera firmware developers and for validating new vendor extensions: the init checks open extra
+// connections to the Responder, which might briefly disturb a camera that only allows a single Initiator. Checks
+// sending standard PTP/IP packets are skipped for vendors using their own packet layout.
+func (c *Client) CheckConformance() []ConformanceResult {
+	standard := c.ResponderVendor() != ptp.VE_FujiPhotoFilmCoLtd
+
+	res := make([]ConformanceResult, 0, len(conformanceChecks))
+	for _, check := range conformanceChecks {
+		r := ConformanceResult{Group: check.group, Name: check.name}
+		if check.standard && !standard {
+			r.Status, r.Detail = CS_Skip, fmt.Sprintf("%s uses its own packet layout", c.ResponderVendor())
+		} else {
+			r.Status, r.Detail = check.run(c)
+		}
+		res = append(res, r)
+	}
+
+	return res
+}
+
+// rawHandshake opens a new connection to the Responder at address, sends p and returns the packet the Responder
+// answers with. No packet and no error is returned when the Responder closes the connection without answering.
+func (c *Client) rawHandshake(address string, p PacketOut) (PacketIn, error) {
+	conn, err := net.DialTimeout(c.Network(), address, DefaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := c.sendPacket(cmdDataConnection, conn, p); err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(DefaultReadTimeout))
+	res, _, err := c.readResponse(conn, nil)
+	if err == io.EOF {
+		return nil, nil
+	}
+
+	return res, err
+}
+
+// handshakeOutcome describes the answer to an init command request. Both accepting and refusing the request are
+// valid answers, not answering at all is not.
+func handshakeOutcome(res PacketIn, err error) (ConformanceStatus, string) {
+	switch pkt := res.(type) {
+	case *InitFailPacket:
+		return CS_Pass, "refused: " + pkt.ReasonAsError().Error()
+	case *InitCommandAckPacket:
+		return CS_Pass, fmt.Sprintf("accepted as connection %d using protocol version %#08x", pkt.ConnectionNumber, pkt.ResponderProtocolVersion)
+	case nil:
+		if err != nil {
+			return CS_Fail, err.Error()
+		}
+		return CS_Fail, "connection closed without answer"
+	}
+
+	return CS_Fail, fmt.Sprintf("unexpected %T", res)
+}
+
+// checkSecondInitiator sends an init command request using a new GUID while the session of the client is open.
+func checkSecondInitiator(c *Client) (ConformanceStatus, string) {
+	return handshakeOutcome(c.rawHandshake(c.CommandDataAddress(), NewInitCommandRequestPacket(uuid.New(), conformanceFriendlyName)))
+}
+
+// checkUnsupportedProtocolVersion sends an init command request using a protocol version that does not exist.
+func checkUnsupportedProtocolVersion(c *Client) (ConformanceStatus, string) {
+	return handshakeOutcome(c.rawHandshake(c.CommandDataAddress(), NewInitCommandRequestPacketWithVersion(uuid.New(), conformanceFriendlyName, ProtocolVersion(0xFFFF0000))))
+}
+
+// checkUnknownConnectionNumber sends an init event request using a connection number that was never handed out.
+func checkUnknownConnectionNumber(c *Client) (ConformanceStatus, string) {
+	res, err := c.rawHandshake(c.EventAddress(), NewInitEventRequestPacket(c.ConnectionNumber()+0x1000))
+	switch pkt := res.(type) {
+	case *InitFailPacket:
+		return CS_Pass, "refused: " + pkt.ReasonAsError().Error()
+	case *InitEventAckPacket:
+		return CS_Fail, "accepted"
+	case nil:
+		if err != nil {
+			return CS_Fail, err.Error()
+		}
+		return CS_Pass, "connection closed"
+	}
+
+	return CS_Fail, fmt.Sprintf("unexpected %T", res)
+}
+
+// checkProbe probes the Responder on the event connection of the client.
+func checkProbe(c *Client) (ConformanceStatus, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReadTimeout)
+	defer cancel()
+
+	rtt, err := c.Probe(ctx)
+	if err != nil {
+		return CS_Fail, err.Error()
+	}
+
+	return CS_Pass, fmt.Sprintf("answered in %s", rtt.Round(time.Millisecond))
+}
+
+// checkDataIn requests the storage IDs, which every Responder must support and which are returned in a data phase.
+func checkDataIn(c *Client) (ConformanceStatus, string) {
+	sids, err := c.GetStorageIDs()
+	if err != nil {
+		return CS_Fail, "GetStorageIDs: " + err.Error()
+	}
+
+	return CS_Pass, fmt.Sprintf("GetStorageIDs returned %d stores", len(sids))
+}
+
+// expectResponseCode requests the operation, which must be answered by the given response code without data phase.
+func expectResponseCode(c *Client, code ptp.OperationCode, params []uint32, want ptp.OperationResponseCode) (ConformanceStatus, string) {
+	raw, err := c.OperationRequestRaw(code, params)
+	if err != nil {
+		return CS_Fail, err.Error()
+	}
+	rc, ok := c.ResponseCode(raw)
+	if !ok {
+		return CS_Fail, "answered with a data phase instead of an operation response"
+	}
+	if rc != want {
+		return CS_Fail, fmt.Sprintf("answered %#04x, want %#04x", uint16(rc), uint16(want))
+	}
+
+	return CS_Pass, ptp.ResponseCodeAsError(rc).Error()
+}
+
+// checkUnsupportedOperation requests an operation that does not exist, which must be answered with
+// ptp.RC_OperationNotSupported.
+func checkUnsupportedOperation(c *Client) (ConformanceStatus, string) {
+	return expectResponseCode(c, unsupportedOperation, nil, ptp.RC_OperationNotSupported)
+}
+
+// checkUnsupportedProperty reads a device property that does not exist, which must be answered with
+// ptp.RC_DevicePropNotSupported and no data phase.
+func checkUnsupportedProperty(c *Client) (ConformanceStatus, string) {
+	return expectResponseCode(c, ptp.OC_GetDevicePropValue, []uint32{uint32(unsupportedProperty)}, ptp.RC_DevicePropNotSupported)
+}
+
+// checkEmptyDataOut sets a device property that does not exist using an empty data-out phase. The Responder must wait
+// for the end of the data phase and then answer, which must not be ptp.RC_OK.
+func checkEmptyDataOut(c *Client) (ConformanceStatus, string) {
+	raw, err := c.SendData(ptp.OC_SetDevicePropValue, []uint32{uint32(unsupportedProperty)}, nil, 0)
+	if err != nil {
+		return CS_Fail, err.Error()
+	}
+	rc, ok := c.ResponseCode(raw)
+	if !ok {
+		return CS_Fail, "no operation response"
+	}
+	if rc == ptp.RC_OK {
+		return CS_Fail, "answered OK"
+	}
+
+	return CS_Pass, ptp.ResponseCodeAsError(rc).Error()
+}
+
+// checkCancelDataOut cancels a data-out phase right after it started. The Responder must end the transaction, after
+// which the session must still be usable.
+func checkCancelDataOut(c *Client) (ConformanceStatus, string) {
+	resCh := make(chan []byte, 2)
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
+		return CS_Fail, err.Error()
+	}
+	defer c.unsubscribe(tid)
+
+	or, err := newOperationRequest(ptp.OC_SetDevicePropValue, tid, []uint32{uint32(unsupportedProperty)}, DP_DataOut)
+	if err != nil {
+		return CS_Fail, err.Error()
+	}
+	for _, p := range []PacketOut{
+		&OperationRequestPacket{DataPhaseInfo: DP_DataOut, OperationRequest: or},
+		&StartDataPacket{TransactionId: tid, TotalDataLength: 4},
+		&CancelPacket{TransactionId: tid},
+	} {
+		if err := c.SendPacketToCmdDataConn(p); err != nil {
+			return CS_Fail, err.Error()
+		}
+	}
+
+	raw, err := c.WaitForRawPacketFromCommandDataSubscriber(resCh)
+	if err != nil {
+		return CS_Fail, "transaction not ended: " + err.Error()
+	}
+	detail := "ended by a cancel packet"
+	if _, err := dataOutResponse(raw); err == nil {
+		rc, _ := c.ResponseCode(raw)
+		detail = fmt.Sprintf("ended by response %#04x", uint16(rc))
+	}
+
+	if _, err := c.GetStorageIDs(); err != nil {
+		return CS_Fail, detail + ", session unusable afterwards: " + err.Error()
+	}
+
+	return CS_Pass, detail
+}
@@ -0,0 +1,40 @@
+package ip
+
+import (
+	"testing"
+)
+
+func TestConformanceStatus_String(t *testing.T) {
+	check := map[ConformanceStatus]string{
+		CS_Pass:              "PASS",
+		CS_Fail:              "FAIL",
+		CS_Skip:              "SKIP",
+		ConformanceStatus(7): "ConformanceStatus(7)",
+	}
+	for s, want := range check {
+		if got := s.String(); got != want {
+			t.Errorf("String() got = %s; want %s", got, want)
+		}
+	}
+}
+
+func TestClient_CheckConformance(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, startFaultyVirtualCamera(t, responderFaults{}), "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	res := c.CheckConformance()
+	if len(res) != len(conformanceChecks) {
+		t.Fatalf("CheckConformance() got %d results; want %d", len(res), len(conformanceChecks))
+	}
+	for _, r := range res {
+		if r.Status != CS_Pass {
+			t.Errorf("CheckConformance() %s: %s got = %s (%s); want %s", r.Group, r.Name, r.Status, r.Detail, CS_Pass)
+		}
+	}
+}
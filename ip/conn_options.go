@@ -0,0 +1,105 @@
+package ip
+
+import (
+	"bufio"
+	"io"
+	"net"
+)
+
+// ConnOptions tunes one of the TCP connections with the Responder. Zero values keep the defaults of the operating
+// system.
+type ConnOptions struct {
+	// ReadBuffer and WriteBuffer set the size of the socket receive and send buffers, i.e. SO_RCVBUF and SO_SNDBUF. A
+	// large receive buffer lets the Responder keep sending while the previous packet is being processed, which helps
+	// the streamer and large data phases over a WiFi link.
+	ReadBuffer  int
+	WriteBuffer int
+	// Delay enables Nagle's algorithm. PTP/IP asks to disable it, which is why TCP_NODELAY is set unless Delay is true.
+	Delay bool
+	// AppReadBuffer is the size of the buffer the packets are read through. Each packet takes at least two reads, one
+	// for the length and one for the rest of the packet, so without a buffer every small packet costs two system
+	// calls. Zero reads from the connection directly.
+	AppReadBuffer int
+}
+
+var (
+	// DefaultCommandDataConnOptions are used for the command/data connection unless set using
+	// SetCommandDataConnOptions.
+	DefaultCommandDataConnOptions = ConnOptions{AppReadBuffer: 64 << 10}
+	// DefaultEventConnOptions are used for the event connection unless set using SetEventConnOptions. Events are few
+	// and small, so they are read from the connection directly.
+	DefaultEventConnOptions = ConnOptions{}
+	// DefaultStreamerConnOptions are used for the streamer connection unless set using SetStreamerConnOptions. Liveview
+	// frames are large and keep coming, hence the large socket receive buffer.
+	DefaultStreamerConnOptions = ConnOptions{ReadBuffer: 1 << 20, AppReadBuffer: 256 << 10}
+)
+
+// connOptions holds the ConnOptions of each connection type.
+type connOptions map[connectionType]ConnOptions
+
+func defaultConnOptions() connOptions {
+	return connOptions{
+		cmdDataConnection: DefaultCommandDataConnOptions,
+		eventConnection:   DefaultEventConnOptions,
+		streamConnection:  DefaultStreamerConnOptions,
+	}
+}
+
+func (o connOptions) clone() connOptions {
+	cl := make(connOptions, len(o))
+	for t, opts := range o {
+		cl[t] = opts
+	}
+
+	return cl
+}
+
+// SetCommandDataConnOptions sets the options of the command/data connection. Call it before calling Dial.
+func (c *Client) SetCommandDataConnOptions(o ConnOptions) {
+	c.connOptions[cmdDataConnection] = o
+}
+
+// SetEventConnOptions sets the options of the event connection. Call it before calling Dial.
+func (c *Client) SetEventConnOptions(o ConnOptions) {
+	c.connOptions[eventConnection] = o
+}
+
+// SetStreamerConnOptions sets the options of the streamer connection. Call it before opening the streamer connection,
+// i.e. before calling DialWithStreamer or enabling the liveview. They are applied again when reconnecting.
+func (c *Client) SetStreamerConnOptions(o ConnOptions) {
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
+	c.connOptions[streamConnection] = o
+}
+
+// applyConnOptions applies the options to the TCP connection and returns the reader the packets are to be read from.
+func (c *Client) applyConnOptions(t connectionType, conn *net.TCPConn, o ConnOptions) io.Reader {
+	lgr := c.channelLogger(t)
+
+	if err := conn.SetNoDelay(!o.Delay); err != nil {
+		lgr.Warnf("TCP_NODELAY not set to %t for %s connection: %s", !o.Delay, t, err)
+	} else {
+		lgr.Debugf("TCP_NODELAY set to %t for %s connection", !o.Delay, t)
+	}
+
+	if o.ReadBuffer > 0 {
+		if err := conn.SetReadBuffer(o.ReadBuffer); err != nil {
+			lgr.Warnf("SO_RCVBUF not set for %s connection: %s", t, err)
+		} else {
+			lgr.Debugf("SO_RCVBUF set to %d bytes for %s connection", o.ReadBuffer, t)
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := conn.SetWriteBuffer(o.WriteBuffer); err != nil {
+			lgr.Warnf("SO_SNDBUF not set for %s connection: %s", t, err)
+		} else {
+			lgr.Debugf("SO_SNDBUF set to %d bytes for %s connection", o.WriteBuffer, t)
+		}
+	}
+
+	if o.AppReadBuffer > 0 {
+		return bufio.NewReaderSize(conn, o.AppReadBuffer)
+	}
+
+	return conn
+}
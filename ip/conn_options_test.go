@@ -0,0 +1,114 @@
+package ip
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+// tcpPair returns both ends of a TCP connection over the loopback interface.
+func tcpPair(tb testing.TB) (*net.TCPConn, *net.TCPConn) {
+	ln, err := net.Listen("tcp", address+":0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return client.(*net.TCPConn), server.(*net.TCPConn)
+}
+
+func TestClient_applyConnOptions(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _ := tcpPair(t)
+
+	r := c.applyConnOptions(streamConnection, conn, c.connOptions[streamConnection])
+	if br, ok := r.(*bufio.Reader); !ok || br.Size() != DefaultStreamerConnOptions.AppReadBuffer {
+		t.Errorf("applyConnOptions() got = %T; want *bufio.Reader of %d bytes", r, DefaultStreamerConnOptions.AppReadBuffer)
+	}
+
+	c.SetEventConnOptions(ConnOptions{ReadBuffer: 4096, Delay: true})
+	if r := c.applyConnOptions(eventConnection, conn, c.connOptions[eventConnection]); r != conn {
+		t.Errorf("applyConnOptions() got = %T; want the connection itself", r)
+	}
+}
+
+func TestClient_connOptionsDial(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetCommandDataConnOptions(ConnOptions{AppReadBuffer: 4096})
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if br, ok := c.cmdDataReader.(*bufio.Reader); !ok || br.Size() != 4096 {
+		t.Errorf("Dial() command/data reader = %T; want *bufio.Reader of 4096 bytes", c.cmdDataReader)
+	}
+	if c.eventReader != c.eventConn {
+		t.Errorf("Dial() event reader = %T; want the connection itself", c.eventReader)
+	}
+	if _, err := c.GetDeviceInfo(); err != nil {
+		t.Errorf("GetDeviceInfo() error = %s; want <nil>", err)
+	}
+}
+
+// BenchmarkConnOptions_readRawResponse reads small responses, as received when polling a property, from a TCP
+// connection with and without an application side read buffer.
+func BenchmarkConnOptions_readRawResponse(b *testing.B) {
+	p := []byte{0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x01, 0x20, 0x03, 0x00, 0x00, 0x00}
+
+	for _, bc := range []struct {
+		name string
+		o    ConnOptions
+	}{
+		{"direct", ConnOptions{}},
+		{"buffered", DefaultCommandDataConnOptions},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c, err := NewClient(DefaultVendor, address, okPort, "bènch", "", LevelSilent)
+			if err != nil {
+				b.Fatal(err)
+			}
+			conn, server := tcpPair(b)
+			r := c.applyConnOptions(cmdDataConnection, conn, bc.o)
+
+			go func() {
+				batch := make([]byte, 0, len(p)*64)
+				for i := 0; i < 64; i++ {
+					batch = append(batch, p...)
+				}
+				for sent := 0; sent < b.N; sent += 64 {
+					if _, err := server.Write(batch); err != nil {
+						return
+					}
+				}
+			}()
+
+			b.SetBytes(int64(len(p)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.readRawResponse(r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
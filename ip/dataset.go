@@ -0,0 +1,38 @@
+package ip
+
+import (
+	"errors"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// ErrUnknownDataset is returned by DecodeDataset for operations of which the dataset cannot be decoded.
+var ErrUnknownDataset = errors.New("unknown dataset")
+
+// DecodeDataset decodes the payload of the data-in phase of a standard operation into the matching dataset:
+//   - GetDeviceInfo: *ptp.DeviceInfo
+//   - GetStorageIDs: []ptp.StorageID
+//   - GetStorageInfo: *ptp.StorageInfo
+//   - GetObjectHandles: []ptp.ObjectHandle
+//   - GetObjectInfo: *ptp.ObjectInfo
+//   - GetDevicePropDesc: *ptp.DevicePropDesc
+//
+// ErrUnknownDataset is returned for all other operations, which includes the vendor-extended ones.
+func DecodeDataset(code ptp.OperationCode, data []byte) (interface{}, error) {
+	switch code {
+	case ptp.OC_GetDeviceInfo:
+		return parseDeviceInfo(data)
+	case ptp.OC_GetStorageIDs:
+		return parseStorageIDs(data)
+	case ptp.OC_GetStorageInfo:
+		return parseStorageInfo(data)
+	case ptp.OC_GetObjectHandles:
+		return parseObjectHandles(data)
+	case ptp.OC_GetObjectInfo:
+		return parseObjectInfo(data)
+	case ptp.OC_GetDevicePropDesc:
+		return parseDevicePropDesc(data)
+	}
+
+	return nil, ErrUnknownDataset
+}
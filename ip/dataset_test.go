@@ -0,0 +1,35 @@
+package ip
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestDecodeDataset(t *testing.T) {
+	got, err := DecodeDataset(ptp.OC_GetStorageIDs, []byte{0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00})
+	if err != nil {
+		t.Fatalf("DecodeDataset() error = %s; want <nil>", err)
+	}
+	if want := []ptp.StorageID{0x00010001}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeDataset() got = %v; want %v", got, want)
+	}
+
+	got, err = DecodeDataset(ptp.OC_GetDevicePropDesc, []byte{0x01, 0x50, 0x02, 0x00, 0x00, 0x64, 0x32, 0x01, 0x00, 0x64, 0x01})
+	if err != nil {
+		t.Fatalf("DecodeDataset() error = %s; want <nil>", err)
+	}
+	if dpd, ok := got.(*ptp.DevicePropDesc); !ok || dpd.DevicePropertyCode != ptp.DPC_BatteryLevel || dpd.CurrentValueAsInt64() != 50 || dpd.FormFlag != ptp.DPF_FormFlag_Range {
+		t.Errorf("DecodeDataset() got = %#v; want battery level 50 with a range form", got)
+	}
+
+	if _, err := DecodeDataset(ptp.OC_GetObjectHandles, []byte{0x02, 0x00, 0x00, 0x00}); err == nil {
+		t.Errorf("DecodeDataset() error = <nil>; want error")
+	}
+
+	if _, err := DecodeDataset(ptp.OperationCode(0x902b), nil); !errors.Is(err, ErrUnknownDataset) {
+		t.Errorf("DecodeDataset() error = %v; want %s", err, ErrUnknownDataset)
+	}
+}
@@ -0,0 +1,223 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// vendorNames holds the names the supported vendors use in the friendly name and the manufacturer of their devices.
+var vendorNames = []struct {
+	name   string
+	vendor ptp.VendorExtension
+}{
+	{"canon", ptp.VE_CanonInc},
+	{"fuji", ptp.VE_FujiPhotoFilmCoLtd},
+	{"nikon", ptp.VE_NikonCorporation},
+	{"sony", ptp.VE_SonyCorporation},
+}
+
+// vendorFromName returns the supported vendor mentioned in the given friendly name or manufacturer. Zero is returned
+// when no supported vendor is mentioned.
+func vendorFromName(name string) ptp.VendorExtension {
+	name = strings.ToLower(name)
+	for _, vn := range vendorNames {
+		if strings.Contains(name, vn.name) {
+			return vn.vendor
+		}
+	}
+
+	return 0
+}
+
+// vendorFromDeviceInfo returns the supported vendor of the device described by di. Many devices report the Microsoft
+// vendor extension ID because they speak MTP, in which case the manufacturer is used instead. Zero is returned when the
+// device is not made by a supported vendor.
+func vendorFromDeviceInfo(di *ptp.DeviceInfo) ptp.VendorExtension {
+	for _, vn := range vendorNames {
+		if ptp.VendorExtension(di.VendorExtensionID) == vn.vendor {
+			return vn.vendor
+		}
+	}
+
+	return vendorFromName(di.Manufacturer)
+}
+
+// parseDeviceInfo parses the DeviceInfo dataset as returned by the GetDeviceInfo operation.
+func parseDeviceInfo(data []byte) (*ptp.DeviceInfo, error) {
+	r := bytes.NewReader(data)
+	di := &ptp.DeviceInfo{}
+
+	for _, v := range []interface{}{&di.StandardVersion, &di.VendorExtensionID, &di.VendorExtensionVersion} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("reading device info: %s", err)
+		}
+	}
+
+	var err error
+	if di.VendorExtensionDesc, err = readPTPString(r); err != nil {
+		return nil, fmt.Errorf("reading device info: %s", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &di.FunctionalMode); err != nil {
+		return nil, fmt.Errorf("reading device info: %s", err)
+	}
+
+	// Each array is preceded by the number of elements it holds.
+	for _, alloc := range []func(n uint32) interface{}{
+		func(n uint32) interface{} {
+			di.OperationsSupported = make([]ptp.OperationCode, n)
+			return di.OperationsSupported
+		},
+		func(n uint32) interface{} {
+			di.EventsSupported = make([]ptp.EventCode, n)
+			return di.EventsSupported
+		},
+		func(n uint32) interface{} {
+			di.DevicePropertiesSupported = make([]ptp.DevicePropCode, n)
+			return di.DevicePropertiesSupported
+		},
+		func(n uint32) interface{} {
+			di.CaptureFormats = make([]ptp.ObjectFormatCode, n)
+			return di.CaptureFormats
+		},
+		func(n uint32) interface{} {
+			di.ImageFormats = make([]ptp.ObjectFormatCode, n)
+			return di.ImageFormats
+		},
+	} {
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("reading device info: %s", err)
+		}
+		if int64(n)*2 > int64(r.Len()) {
+			return nil, fmt.Errorf("reading device info: array of %d elements exceeds the data", n)
+		}
+		if err := binary.Read(r, binary.LittleEndian, alloc(n)); err != nil {
+			return nil, fmt.Errorf("reading device info: %s", err)
+		}
+	}
+
+	for _, s := range []*string{&di.Manufacturer, &di.Model, &di.DeviceVersion, &di.SerialNumber} {
+		if *s, err = readPTPString(r); err != nil {
+			return nil, fmt.Errorf("reading device info: %s", err)
+		}
+	}
+
+	return di, nil
+}
+
+// dialAndDetectVendor initialises the command/data and event connections for a Responder of which the vendor is not
+// known. The standard PTP/IP handshake is used to learn the friendly name of the Responder and, when that does not
+// reveal the vendor, the device info is requested. All of this happens before the listeners are started so that the
+// vendor extensions can safely be replaced by the ones matching the detected vendor.
+// Fuji uses its own handshake, so the connections are set up again when a Fuji Responder is detected. When the
+// standard handshake fails, the Responder is assumed to be a Fuji as well.
+func (c *Client) dialAndDetectVendor() error {
+	c.vendorExtensions.cmdDataInit = genericCommandDataHandshake
+	if err := c.initCommandDataConn(); err != nil {
+		// There is no point in retrying when the Responder cannot be reached.
+		if c.CommandDataConn == nil {
+			return err
+		}
+		c.Infof("Standard PTP/IP handshake failed, retrying as Fuji: %s", err)
+		return c.redialAsVendor(ptp.VE_FujiPhotoFilmCoLtd)
+	}
+
+	v := vendorFromName(c.ResponderFriendlyName())
+	if v != ptp.VE_FujiPhotoFilmCoLtd {
+		if err := c.vendorExtensions.eventInit(c); err != nil {
+			c.closeDetectionConns()
+			return fmt.Errorf("event connection error: %w", err)
+		}
+		if v == 0 {
+			v = c.vendorFromResponderDeviceInfo()
+		}
+	}
+
+	if v == ptp.VE_FujiPhotoFilmCoLtd {
+		c.closeDetectionConns()
+		return c.redialAsVendor(v)
+	}
+
+	c.useVendor(v)
+	c.startResponseListener()
+
+	return c.startEventListener()
+}
+
+// vendorFromResponderDeviceInfo requests the device info directly on the command/data connection, which is only
+// possible as long as the response listener has not been started, and returns the vendor it describes. Zero is returned
+// when the device info cannot be obtained.
+func (c *Client) vendorFromResponderDeviceInfo() ptp.VendorExtension {
+	err := c.SendPacketToCmdDataConn(&OperationRequestPacket{
+		DataPhaseInfo:    DP_NoDataOrDataIn,
+		OperationRequest: ptp.GetDeviceInfo(c.incrementTransactionId()),
+	})
+	if err != nil {
+		c.Warnf("Unable to request the device info to detect the vendor: %s", err)
+		return 0
+	}
+
+	data, err := collectData(c.waitForRawFromCmdDataConn)
+	if err != nil {
+		c.Warnf("Unable to get the device info to detect the vendor: %s", err)
+		return 0
+	}
+
+	di, err := parseDeviceInfo(data)
+	if err != nil {
+		c.Warnf("Unable to detect the vendor: %s", err)
+		return 0
+	}
+
+	return vendorFromDeviceInfo(di)
+}
+
+// redialAsVendor initialises the command/data and event connections using the vendor extensions of the given vendor.
+func (c *Client) redialAsVendor(v ptp.VendorExtension) error {
+	c.useVendor(v)
+
+	c.resetTransactionId()
+
+	if err := c.initCommandDataConn(); err != nil {
+		return err
+	}
+
+	return c.initEventConn()
+}
+
+// useVendor loads the vendor extensions of the given vendor. Vendor detection is disabled from then on, so that dialing
+// again after closing the client uses the same vendor.
+func (c *Client) useVendor(v ptp.VendorExtension) {
+	c.detectVendor = false
+	c.responder.Vendor = v
+	c.loadVendorExtensions()
+
+	// The log entries are tagged with the vendor when setting the logger.
+	if fl, ok := c.Logger.(*fieldLogger); ok {
+		var fields []Field
+		for _, f := range fl.fields {
+			if f.Key != FieldVendor {
+				fields = append(fields, f)
+			}
+		}
+		c.Logger = WithFields(fl.next, append(fields, Field{FieldVendor, v.String()})...)
+	}
+
+	c.Infof("Using the %s vendor extensions.", v)
+}
+
+// closeDetectionConns closes the connections set up to detect the vendor.
+func (c *Client) closeDetectionConns() {
+	if c.eventConn != nil {
+		c.eventConn.Close()
+		c.eventConn = nil
+	}
+	if c.CommandDataConn != nil {
+		c.CommandDataConn.Close()
+		c.CommandDataConn = nil
+	}
+}
@@ -0,0 +1,96 @@
+package ip
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestVendorFromDeviceInfo(t *testing.T) {
+	check := []struct {
+		di   ptp.DeviceInfo
+		want ptp.VendorExtension
+	}{
+		{ptp.DeviceInfo{VendorExtensionID: uint32(ptp.VE_NikonCorporation)}, ptp.VE_NikonCorporation},
+		{ptp.DeviceInfo{VendorExtensionID: uint32(ptp.VE_MicrosoftCorporation), Manufacturer: "Canon Inc."}, ptp.VE_CanonInc},
+		{ptp.DeviceInfo{VendorExtensionID: uint32(ptp.VE_MicrosoftCorporation), Manufacturer: "FUJIFILM"}, ptp.VE_FujiPhotoFilmCoLtd},
+		{ptp.DeviceInfo{Manufacturer: "Sony Corporation"}, ptp.VE_SonyCorporation},
+		{ptp.DeviceInfo{VendorExtensionID: uint32(ptp.VE_PENTAXCorporation), Manufacturer: "RICOH IMAGING"}, 0},
+	}
+
+	for _, tt := range check {
+		if got := vendorFromDeviceInfo(&tt.di); got != tt.want {
+			t.Errorf("vendorFromDeviceInfo() %+v got = %s; want %s", tt.di, got, tt.want)
+		}
+	}
+}
+
+func TestParseDeviceInfo(t *testing.T) {
+	data := []byte{
+		0x64, 0x00, // StandardVersion
+		0x06, 0x00, 0x00, 0x00, // VendorExtensionID
+		0x64, 0x00, // VendorExtensionVersion
+		0x03, 0x6d, 0x00, 0x73, 0x00, 0x00, 0x00, // VendorExtensionDesc
+		0x00, 0x00, // FunctionalMode
+		0x02, 0x00, 0x00, 0x00, 0x01, 0x10, 0x02, 0x10, // OperationsSupported
+		0x01, 0x00, 0x00, 0x00, 0x02, 0x40, // EventsSupported
+		0x00, 0x00, 0x00, 0x00, // DevicePropertiesSupported
+		0x00, 0x00, 0x00, 0x00, // CaptureFormats
+		0x01, 0x00, 0x00, 0x00, 0x01, 0x38, // ImageFormats
+		0x06, 0x4e, 0x00, 0x69, 0x00, 0x6b, 0x00, 0x6f, 0x00, 0x6e, 0x00, 0x00, 0x00, // Manufacturer
+		0x02, 0x5a, 0x00, 0x00, 0x00, // Model
+	}
+
+	got, err := parseDeviceInfo(data)
+	if err != nil {
+		t.Fatalf("parseDeviceInfo() error = %s; want <nil>", err)
+	}
+	want := &ptp.DeviceInfo{
+		StandardVersion:           100,
+		VendorExtensionID:         uint32(ptp.VE_MicrosoftCorporation),
+		VendorExtensionVersion:    100,
+		VendorExtensionDesc:       "ms",
+		OperationsSupported:       []ptp.OperationCode{ptp.OC_GetDeviceInfo, ptp.OC_OpenSession},
+		EventsSupported:           []ptp.EventCode{ptp.EC_ObjectAdded},
+		DevicePropertiesSupported: []ptp.DevicePropCode{},
+		CaptureFormats:            []ptp.ObjectFormatCode{},
+		ImageFormats:              []ptp.ObjectFormatCode{ptp.OFC_EXIF_JPEG},
+		Manufacturer:              "Nikon",
+		Model:                     "Z",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDeviceInfo() got = %+v; want %+v", got, want)
+	}
+
+	// The number of operations exceeds the data.
+	data[17] = 0xff
+	if _, err := parseDeviceInfo(data); err == nil {
+		t.Errorf("parseDeviceInfo() error = <nil>; want error")
+	}
+}
+
+func TestClient_Dial_autoVendor(t *testing.T) {
+	check := []struct {
+		port uint16
+		want ptp.VendorExtension
+	}{
+		{okPort, 0},
+		{fujiCmdPort, ptp.VE_FujiPhotoFilmCoLtd},
+	}
+
+	for _, tt := range check {
+		c, err := NewClient(AutoVendor, address, tt.port, "testèr", "", logLevel)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Dial(); err != nil {
+			t.Errorf("Dial() err = %s; want <nil>", err)
+		}
+		if got := c.ResponderVendor(); got != tt.want {
+			t.Errorf("ResponderVendor() got = %s; want %s", got, tt.want)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,571 @@
+package ip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDiscoveryTimeout is the time Discover listens for responses when no timeout is given.
+	DefaultDiscoveryTimeout = 3 * time.Second
+
+	mdnsAddress    = "224.0.0.251:5353"
+	mdnsPTPService = "_ptp._tcp.local."
+	ssdpAddress    = "239.255.255.250:1900"
+)
+
+// DiscoveryMethod indicates how a responder was found.
+type DiscoveryMethod string
+
+const (
+	DiscoveryMDNS      DiscoveryMethod = "mdns"
+	DiscoverySSDP      DiscoveryMethod = "ssdp"
+	DiscoveryBroadcast DiscoveryMethod = "broadcast"
+)
+
+// ssdpSearchTargets are the SSDP search targets of devices offering PTP/IP. The first one is used by cameras
+// supporting MTP/PTP over IP as found in Windows, the others are vendor specific.
+var ssdpSearchTargets = []string{
+	"urn:microsoft-com:device:mtp:1",
+	"urn:schemas-canon-com:service:ICPO-SmartPhoneEOSSystemService:1",
+	"urn:schemas-sony-com:service:ScalarWebAPI:1",
+}
+
+// vendorBroadcast describes a UDP port on which cameras of a vendor broadcast their presence.
+type vendorBroadcast struct {
+	vendor string
+	port   int
+	parse  func([]byte) (DiscoveredResponder, bool)
+}
+
+// vendorBroadcasts lists the vendor specific broadcasts Discover listens for.
+var vendorBroadcasts = []vendorBroadcast{
+	{vendor: "fuji", port: 51562, parse: parseFujiBroadcast},
+}
+
+// DiscoveredResponder is a responder found by Discover.
+type DiscoveredResponder struct {
+	FriendlyName string `json:"name"`
+	// Vendor is the vendor as expected by NewClient, e.g. "fuji". It is empty when the vendor could not be determined.
+	Vendor    string          `json:"vendor"`
+	GUID      string          `json:"guid"`
+	IpAddress string          `json:"address"`
+	Port      uint16          `json:"port"`
+	Method    DiscoveryMethod `json:"method"`
+}
+
+// merge completes the fields of r that are empty with those of o.
+func (r *DiscoveredResponder) merge(o DiscoveredResponder) {
+	if r.FriendlyName == "" {
+		r.FriendlyName = o.FriendlyName
+	}
+	if r.Vendor == "" {
+		r.Vendor = o.Vendor
+	}
+	if r.GUID == "" {
+		r.GUID = o.GUID
+	}
+	if r.Port == 0 {
+		r.Port = o.Port
+	}
+}
+
+// Discover looks for responders on the local network using mDNS, SSDP and the vendor specific broadcasts for the given
+// time. Responders found using several methods are only returned once, ordered by IP address. An error is returned
+// only when none of the methods could be used.
+func Discover(timeout time.Duration) ([]DiscoveredResponder, error) {
+	if timeout <= 0 {
+		timeout = DefaultDiscoveryTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	methods := []func(time.Time, chan<- DiscoveredResponder) error{discoverMDNS, discoverSSDP}
+	for _, vb := range vendorBroadcasts {
+		vb := vb
+		methods = append(methods, func(deadline time.Time, found chan<- DiscoveredResponder) error {
+			return listenVendorBroadcast(vb, deadline, found)
+		})
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	found := make(chan DiscoveredResponder)
+	for _, m := range methods {
+		wg.Add(1)
+		go func(m func(time.Time, chan<- DiscoveredResponder) error) {
+			defer wg.Done()
+			if err := m(deadline, found); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(m)
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	byAddr := make(map[string]*DiscoveredResponder)
+	for r := range found {
+		if prev, ok := byAddr[r.IpAddress]; ok {
+			prev.merge(r)
+			continue
+		}
+		r := r
+		byAddr[r.IpAddress] = &r
+	}
+
+	if len(errs) == len(methods) {
+		return nil, fmt.Errorf("discovery failed: %w", errors.Join(errs...))
+	}
+
+	res := make([]DiscoveredResponder, 0, len(byAddr))
+	for _, r := range byAddr {
+		if r.Port == 0 {
+			r.Port = DefaultPort
+		}
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := net.ParseIP(res[i].IpAddress), net.ParseIP(res[j].IpAddress)
+		if a == nil || b == nil {
+			return res[i].IpAddress < res[j].IpAddress
+		}
+		return bytes.Compare(a.To16(), b.To16()) < 0
+	})
+
+	return res, nil
+}
+
+// readUDP reads packets from conn until the deadline and calls f for each of them.
+func readUDP(conn net.PacketConn, deadline time.Time, f func([]byte, net.Addr)) {
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 9000)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		f(buf[:n], addr)
+	}
+}
+
+// discoverMDNS sends a DNS-SD query for the PTP service. The query is sent from a random port, making it a so called
+// legacy unicast query to which the responders answer directly.
+func discoverMDNS(deadline time.Time, found chan<- DiscoveredResponder) error {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return fmt.Errorf("mdns: %w", err)
+	}
+	defer conn.Close()
+
+	addr, err := net.ResolveUDPAddr("udp4", mdnsAddress)
+	if err != nil {
+		return fmt.Errorf("mdns: %w", err)
+	}
+	if _, err := conn.WriteTo(mdnsQuery(mdnsPTPService), addr); err != nil {
+		return fmt.Errorf("mdns: %w", err)
+	}
+
+	readUDP(conn, deadline, func(b []byte, from net.Addr) {
+		for _, r := range parseMDNSResponse(b, udpIP(from)) {
+			found <- r
+		}
+	})
+
+	return nil
+}
+
+// discoverSSDP sends an SSDP M-SEARCH request for each search target and fetches the device description of the devices
+// answering to find out their vendor and name.
+func discoverSSDP(deadline time.Time, found chan<- DiscoveredResponder) error {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return fmt.Errorf("ssdp: %w", err)
+	}
+	defer conn.Close()
+
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return fmt.Errorf("ssdp: %w", err)
+	}
+	mx := int(time.Until(deadline).Seconds())
+	if mx < 1 {
+		mx = 1
+	}
+	for _, st := range ssdpSearchTargets {
+		if _, err := conn.WriteTo(ssdpSearch(st, mx), addr); err != nil {
+			return fmt.Errorf("ssdp: %w", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	seen := make(map[string]bool)
+	readUDP(conn, deadline, func(b []byte, from net.Addr) {
+		r, location, ok := parseSSDPResponse(b, udpIP(from))
+		if !ok || seen[r.IpAddress+location] {
+			return
+		}
+		seen[r.IpAddress+location] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if location != "" {
+				if dd, err := fetchDeviceDescription(location, deadline); err == nil {
+					r.merge(dd)
+				}
+			}
+			found <- r
+		}()
+	})
+	wg.Wait()
+
+	return nil
+}
+
+// listenVendorBroadcast listens for the broadcasts some cameras send out to find a computer to connect to.
+func listenVendorBroadcast(vb vendorBroadcast, deadline time.Time, found chan<- DiscoveredResponder) error {
+	conn, err := net.ListenPacket("udp4", ":"+strconv.Itoa(vb.port))
+	if err != nil {
+		return fmt.Errorf("%s broadcast: %w", vb.vendor, err)
+	}
+	defer conn.Close()
+
+	readUDP(conn, deadline, func(b []byte, from net.Addr) {
+		if r, ok := vb.parse(b); ok {
+			r.Vendor = vb.vendor
+			r.IpAddress = udpIP(from)
+			r.Method = DiscoveryBroadcast
+			found <- r
+		}
+	})
+
+	return nil
+}
+
+func udpIP(addr net.Addr) string {
+	if a, ok := addr.(*net.UDPAddr); ok {
+		return a.IP.String()
+	}
+
+	return ""
+}
+
+// mdnsQuery returns a DNS query message asking for the PTR records of the given service.
+func mdnsQuery(service string) []byte {
+	var b bytes.Buffer
+	// Header: ID, flags, one question and no records.
+	binary.Write(&b, binary.BigEndian, [6]uint16{0, 0, 1, 0, 0, 0})
+	writeDNSName(&b, service)
+	// Type PTR, class IN.
+	binary.Write(&b, binary.BigEndian, [2]uint16{dnsTypePTR, 1})
+
+	return b.Bytes()
+}
+
+func writeDNSName(b *bytes.Buffer, name string) {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b.WriteByte(byte(len(label)))
+		b.WriteString(label)
+	}
+	b.WriteByte(0)
+}
+
+const (
+	dnsTypeA   uint16 = 1
+	dnsTypePTR uint16 = 12
+	dnsTypeTXT uint16 = 16
+	dnsTypeSRV uint16 = 33
+)
+
+// dnsRecord holds the parts of a resource record that are of interest for discovery.
+type dnsRecord struct {
+	name   string
+	typ    uint16
+	target string // PTR and SRV target
+	port   uint16 // SRV only
+	txt    []string
+	ip     net.IP
+}
+
+// parseMDNSResponse extracts the PTP services from a DNS response. The address of the sender is used when the response
+// does not include the address of the host offering the service.
+func parseMDNSResponse(b []byte, from string) []DiscoveredResponder {
+	records, err := parseDNSMessage(b)
+	if err != nil {
+		return nil
+	}
+
+	var res []DiscoveredResponder
+	for _, ptr := range records {
+		if ptr.typ != dnsTypePTR || !strings.EqualFold(ptr.name, mdnsPTPService) {
+			continue
+		}
+
+		r := DiscoveredResponder{
+			FriendlyName: strings.TrimSuffix(strings.TrimSuffix(ptr.target, "."+mdnsPTPService), "."),
+			IpAddress:    from,
+			Method:       DiscoveryMDNS,
+		}
+		for _, rec := range records {
+			if !strings.EqualFold(rec.name, ptr.target) {
+				continue
+			}
+			switch rec.typ {
+			case dnsTypeSRV:
+				r.Port = rec.port
+				for _, a := range records {
+					if a.typ == dnsTypeA && strings.EqualFold(a.name, rec.target) {
+						r.IpAddress = a.ip.String()
+					}
+				}
+			case dnsTypeTXT:
+				for _, kv := range rec.txt {
+					k, v, _ := strings.Cut(kv, "=")
+					switch strings.ToLower(k) {
+					case "guid", "uuid":
+						r.GUID = v
+					case "mfg", "manufacturer", "vendor":
+						r.Vendor = vendorFromManufacturer(v)
+					}
+				}
+			}
+		}
+		res = append(res, r)
+	}
+
+	return res
+}
+
+// parseDNSMessage returns all the resource records in a DNS message.
+func parseDNSMessage(b []byte) ([]dnsRecord, error) {
+	if len(b) < 12 {
+		return nil, InvalidPacketError
+	}
+	qd := binary.BigEndian.Uint16(b[4:])
+	rr := int(binary.BigEndian.Uint16(b[6:])) + int(binary.BigEndian.Uint16(b[8:])) + int(binary.BigEndian.Uint16(b[10:]))
+
+	off := 12
+	for i := 0; i < int(qd); i++ {
+		_, n, err := readDNSName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		off = n + 4
+	}
+
+	var records []dnsRecord
+	for i := 0; i < rr; i++ {
+		name, n, err := readDNSName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		if n+10 > len(b) {
+			return nil, InvalidPacketError
+		}
+		rec := dnsRecord{name: name, typ: binary.BigEndian.Uint16(b[n:])}
+		l := int(binary.BigEndian.Uint16(b[n+8:]))
+		start := n + 10
+		if start+l > len(b) {
+			return nil, InvalidPacketError
+		}
+		data := b[start : start+l]
+
+		switch rec.typ {
+		case dnsTypeA:
+			if l == 4 {
+				rec.ip = net.IP(data)
+			}
+		case dnsTypePTR:
+			rec.target, _, err = readDNSName(b, start)
+		case dnsTypeSRV:
+			if l < 7 {
+				return nil, InvalidPacketError
+			}
+			rec.port = binary.BigEndian.Uint16(data[4:])
+			rec.target, _, err = readDNSName(b, start+6)
+		case dnsTypeTXT:
+			for j := 0; j < len(data); {
+				sl := int(data[j])
+				if j+1+sl > len(data) {
+					break
+				}
+				rec.txt = append(rec.txt, string(data[j+1:j+1+sl]))
+				j += 1 + sl
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+		off = start + l
+	}
+
+	return records, nil
+}
+
+// readDNSName reads a possibly compressed domain name starting at off. It returns the name with a trailing dot and the
+// offset following the name.
+func readDNSName(b []byte, off int) (string, int, error) {
+	var (
+		labels []string
+		next   = -1
+	)
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, InvalidPacketError
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case l&0xC0 == 0xC0:
+			if off+1 >= len(b) || jumps > 10 {
+				return "", 0, InvalidPacketError
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+l > len(b) {
+				return "", 0, InvalidPacketError
+			}
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+// ssdpSearch returns an SSDP M-SEARCH request for the given search target.
+func ssdpSearch(st string, mx int) []byte {
+	return []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddress + "\r\n" +
+		`MAN: "ssdp:discover"` + "\r\n" +
+		"MX: " + strconv.Itoa(mx) + "\r\n" +
+		"ST: " + st + "\r\n\r\n")
+}
+
+// parseSSDPResponse parses the response to an M-SEARCH request. It returns the responder and the URL of its device
+// description.
+func parseSSDPResponse(b []byte, from string) (DiscoveredResponder, string, bool) {
+	r := DiscoveredResponder{IpAddress: from, Method: DiscoverySSDP}
+
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	status, err := tp.ReadLine()
+	if err != nil || !strings.HasPrefix(status, "HTTP/1.1 200") {
+		return r, "", false
+	}
+	h, err := tp.ReadMIMEHeader()
+	if err != nil && len(h) == 0 {
+		return r, "", false
+	}
+
+	if usn := h.Get("USN"); strings.HasPrefix(usn, "uuid:") {
+		r.GUID, _, _ = strings.Cut(strings.TrimPrefix(usn, "uuid:"), "::")
+	}
+	r.Vendor = vendorFromManufacturer(h.Get("ST") + " " + h.Get("SERVER"))
+
+	return r, h.Get("LOCATION"), true
+}
+
+// deviceDescription is the part of a UPnP device description that is of interest for discovery.
+type deviceDescription struct {
+	Device struct {
+		FriendlyName string `xml:"friendlyName"`
+		Manufacturer string `xml:"manufacturer"`
+		UDN          string `xml:"UDN"`
+	} `xml:"device"`
+}
+
+// fetchDeviceDescription fetches the UPnP device description found at the location of an SSDP response.
+func fetchDeviceDescription(location string, deadline time.Time) (DiscoveredResponder, error) {
+	client := http.Client{Timeout: time.Until(deadline)}
+	res, err := client.Get(location)
+	if err != nil {
+		return DiscoveredResponder{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return DiscoveredResponder{}, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return parseDeviceDescription(res.Body)
+}
+
+func parseDeviceDescription(r io.Reader) (DiscoveredResponder, error) {
+	var dd deviceDescription
+	if err := xml.NewDecoder(r).Decode(&dd); err != nil {
+		return DiscoveredResponder{}, err
+	}
+
+	return DiscoveredResponder{
+		FriendlyName: dd.Device.FriendlyName,
+		Vendor:       vendorFromManufacturer(dd.Device.Manufacturer),
+		GUID:         strings.TrimPrefix(dd.Device.UDN, "uuid:"),
+	}, nil
+}
+
+// parseFujiBroadcast parses the message Fuji cameras broadcast when looking for a computer to save images to, e.g.:
+//
+//	DISCOVERY * HTTP/1.1
+//	HOST: 192.168.1.255
+//	MX: 5
+//	SERVICE: PCSS/1.0
+//	CAMERANAME: X-T1
+func parseFujiBroadcast(b []byte) (DiscoveredResponder, bool) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(b)))
+	status, err := tp.ReadLine()
+	if err != nil || !strings.HasPrefix(status, "DISCOVERY ") {
+		return DiscoveredResponder{}, false
+	}
+	h, _ := tp.ReadMIMEHeader()
+
+	return DiscoveredResponder{FriendlyName: h.Get("CAMERANAME")}, true
+}
+
+// vendorFromManufacturer returns the vendor as expected by NewClient for a manufacturer name, or an empty string when
+// the manufacturer is not known.
+func vendorFromManufacturer(m string) string {
+	m = strings.ToLower(m)
+	for _, v := range []struct{ name, vendor string }{
+		{"fuji", "fuji"},
+		{"canon", "canon"},
+		{"nikon", "nikon"},
+		{"sony", "sony"},
+		{"panasonic", "panasonic"},
+		{"pentax", "pentax"},
+		{"ricoh", "pentax"},
+		{"kodak", "kodak"},
+		{"samsung", "samsung"},
+	} {
+		if strings.Contains(m, v.name) {
+			return v.vendor
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,159 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestMdnsQuery(t *testing.T) {
+	got := mdnsQuery(mdnsPTPService)
+	want := []byte{
+		0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+		4, '_', 'p', 't', 'p', 4, '_', 't', 'c', 'p', 5, 'l', 'o', 'c', 'a', 'l', 0,
+		0, 12, 0, 1,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("mdnsQuery() got = %v; want %v", got, want)
+	}
+}
+
+// mdnsResponse builds the answer of a camera named X-T3 to the PTP service query, using name compression like real
+// responders do.
+func mdnsResponse() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, [6]uint16{0, 0x8400, 0, 1, 0, 3})
+
+	record := func(name func(), typ uint16, data []byte) {
+		name()
+		binary.Write(&b, binary.BigEndian, []uint16{typ, 0x8001, 0, 120, uint16(len(data))})
+		b.Write(data)
+	}
+	service := func() { writeDNSName(&b, mdnsPTPService) }
+	// The service name is the first name in the message, at offset 12.
+	instance := func() { b.Write([]byte{4, 'X', '-', 'T', '3', 0xC0, 12}) }
+	host := func() { writeDNSName(&b, "xt3.local.") }
+
+	record(service, dnsTypePTR, []byte{4, 'X', '-', 'T', '3', 0xC0, 12})
+	record(instance, dnsTypeSRV, append([]byte{0, 0, 0, 0, 0x3d, 0x7c}, 3, 'x', 't', '3', 5, 'l', 'o', 'c', 'a', 'l', 0))
+	txt := "guid=0f5b3c3a-7c0b-4f5c-9c61-7b3a1d4c1a2b"
+	mfg := "mfg=FUJIFILM"
+	record(instance, dnsTypeTXT, append(append([]byte{byte(len(txt))}, txt...), append([]byte{byte(len(mfg))}, mfg...)...))
+	record(host, dnsTypeA, []byte{192, 168, 0, 1})
+
+	return b.Bytes()
+}
+
+func TestParseMDNSResponse(t *testing.T) {
+	got := parseMDNSResponse(mdnsResponse(), "10.0.0.1")
+	want := DiscoveredResponder{
+		FriendlyName: "X-T3",
+		Vendor:       "fuji",
+		GUID:         "0f5b3c3a-7c0b-4f5c-9c61-7b3a1d4c1a2b",
+		IpAddress:    "192.168.0.1",
+		Port:         15740,
+		Method:       DiscoveryMDNS,
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("parseMDNSResponse() got = %+v; want [%+v]", got, want)
+	}
+
+	if got := parseMDNSResponse(mdnsResponse()[:40], "10.0.0.1"); got != nil {
+		t.Errorf("parseMDNSResponse() got = %+v; want <nil> for a truncated message", got)
+	}
+}
+
+func TestReadDNSName_loop(t *testing.T) {
+	// A pointer pointing to itself must not hang.
+	_, _, err := readDNSName([]byte{0xC0, 0}, 0)
+	if err != InvalidPacketError {
+		t.Errorf("readDNSName() error = %v; want %v", err, InvalidPacketError)
+	}
+}
+
+func TestParseSSDPResponse(t *testing.T) {
+	res := "HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=1800\r\n" +
+		"LOCATION: http://192.168.1.20:49152/upnp/CameraDevDesc.xml\r\n" +
+		"SERVER: Camera OS/1.0 UPnP/1.0 Canon Device Discovery/1.0\r\n" +
+		"ST: urn:schemas-canon-com:service:ICPO-SmartPhoneEOSSystemService:1\r\n" +
+		"USN: uuid:00000000-0000-0000-0001-60128B7C5F28::urn:schemas-canon-com:service:ICPO-SmartPhoneEOSSystemService:1\r\n\r\n"
+
+	got, location, ok := parseSSDPResponse([]byte(res), "192.168.1.20")
+	if !ok {
+		t.Fatal("parseSSDPResponse() ok = false; want true")
+	}
+	want := DiscoveredResponder{
+		Vendor:    "canon",
+		GUID:      "00000000-0000-0000-0001-60128B7C5F28",
+		IpAddress: "192.168.1.20",
+		Method:    DiscoverySSDP,
+	}
+	if got != want {
+		t.Errorf("parseSSDPResponse() got = %+v; want %+v", got, want)
+	}
+	if want := "http://192.168.1.20:49152/upnp/CameraDevDesc.xml"; location != want {
+		t.Errorf("parseSSDPResponse() location = %s; want %s", location, want)
+	}
+
+	if _, _, ok := parseSSDPResponse([]byte(ssdpSearch("ssdp:all", 3)), "192.168.1.21"); ok {
+		t.Error("parseSSDPResponse() ok = true; want false for a search request")
+	}
+}
+
+func TestParseDeviceDescription(t *testing.T) {
+	xml := `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <friendlyName>Canon EOS R6</friendlyName>
+    <manufacturer>Canon Inc.</manufacturer>
+    <UDN>uuid:00000000-0000-0000-0001-60128B7C5F28</UDN>
+  </device>
+</root>`
+
+	got, err := parseDeviceDescription(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("parseDeviceDescription() error = %s; want <nil>", err)
+	}
+	want := DiscoveredResponder{FriendlyName: "Canon EOS R6", Vendor: "canon", GUID: "00000000-0000-0000-0001-60128B7C5F28"}
+	if got != want {
+		t.Errorf("parseDeviceDescription() got = %+v; want %+v", got, want)
+	}
+}
+
+func TestParseFujiBroadcast(t *testing.T) {
+	msg := "DISCOVERY * HTTP/1.1\r\nHOST: 192.168.1.255\r\nMX: 5\r\nSERVICE: PCSS/1.0\r\nCAMERANAME: X-T1\r\n\r\n"
+	got, ok := parseFujiBroadcast([]byte(msg))
+	if !ok || got.FriendlyName != "X-T1" {
+		t.Errorf("parseFujiBroadcast() got = %+v, %v; want X-T1, true", got, ok)
+	}
+
+	if _, ok := parseFujiBroadcast([]byte("NOTIFY * HTTP/1.1\r\n\r\n")); ok {
+		t.Error("parseFujiBroadcast() ok = true; want false")
+	}
+}
+
+func TestDiscoveredResponder_merge(t *testing.T) {
+	r := DiscoveredResponder{GUID: "abc", IpAddress: "192.168.0.1", Method: DiscoverySSDP}
+	r.merge(DiscoveredResponder{FriendlyName: "X-T3", Vendor: "fuji", GUID: "def", Port: 15740})
+
+	want := DiscoveredResponder{FriendlyName: "X-T3", Vendor: "fuji", GUID: "abc", IpAddress: "192.168.0.1", Port: 15740, Method: DiscoverySSDP}
+	if r != want {
+		t.Errorf("merge() got = %+v; want %+v", r, want)
+	}
+}
+
+func TestVendorFromManufacturer(t *testing.T) {
+	check := map[string]string{
+		"FUJIFILM":              "fuji",
+		"Nikon Corporation":     "nikon",
+		"RICOH IMAGING COMPANY": "pentax",
+		"Acme":                  "",
+	}
+	for m, want := range check {
+		if got := vendorFromManufacturer(m); got != want {
+			t.Errorf("vendorFromManufacturer(%s) got = %s; want %s", m, got, want)
+		}
+	}
+}
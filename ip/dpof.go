@@ -0,0 +1,249 @@
+package ip
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+const (
+	// DPOFPath is the path of the DPOF print order file on a memory card.
+	DPOFPath = "/" + dpofFolder + "/" + dpofFile
+	// dpofFolder is the folder holding the DPOF files.
+	dpofFolder = "MISC"
+	// dpofFile is the file name of the DPOF print order file.
+	dpofFile = "AUTPRINT.MRK"
+	// dpofRevision is the DPOF version written to the header of the print order file.
+	dpofRevision = "01.10"
+	// dpofDateTimeFormat is the layout of the creation date in the header of the print order file.
+	dpofDateTimeFormat = "2006:01:02:15:04:05"
+)
+
+// PrintType is the type of a print job in a DPOF print order.
+type PrintType string
+
+const (
+	// PT_Standard prints each image on its own.
+	PT_Standard PrintType = "STD"
+	// PT_Index prints an index sheet holding many images.
+	PT_Index PrintType = "IDX"
+)
+
+// PrintOrder is the Digital Print Order Format information stored on a memory card, which printing kiosks and printers
+// use to print the selected images without further input.
+type PrintOrder struct {
+	// Creator is the application or device that created the print order.
+	Creator string
+	// Created is the time the print order was created, the current time being used when writing a print order with a
+	// zero time.
+	Created time.Time
+	Jobs    []PrintJob
+}
+
+// PrintJob requests prints of a single image.
+type PrintJob struct {
+	Type     PrintType
+	Quantity int
+	// Path is the slash separated path of the image on the memory card, e.g. /DCIM/100_FUJI/DSCF0001.JPG.
+	Path string
+	// Format is the DPOF image format of the image, e.g. EXIF2 -J for EXIF/JPEG, which is used when empty.
+	Format string
+}
+
+// ParsePrintOrder parses the contents of a DPOF print order file. Only standard and index print jobs of a single image
+// are supported, the other lines of a job are ignored.
+func ParsePrintOrder(data []byte) (*PrintOrder, error) {
+	po := &PrintOrder{}
+
+	var (
+		section string
+		job     *PrintJob
+	)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.ToUpper(strings.Trim(line, "[]"))
+			if job != nil {
+				po.Jobs = append(po.Jobs, *job)
+				job = nil
+			}
+			if section == "JOB" {
+				job = &PrintJob{Type: PT_Standard, Quantity: 1}
+			}
+			continue
+		}
+
+		key, val, found := strings.Cut(strings.Trim(line, "<>"), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid print order line %d: %s", n, line)
+		}
+		key, val = strings.Join(strings.Fields(key), " "), strings.Trim(strings.TrimSpace(val), `"`)
+
+		switch {
+		case section == "HDR" && key == "GEN CRT":
+			po.Creator = val
+		case section == "HDR" && key == "GEN DTM":
+			po.Created, _ = time.ParseInLocation(dpofDateTimeFormat, val, time.Local)
+		case job == nil:
+			// Lines of unsupported sections are ignored.
+		case key == "PRT TYP":
+			job.Type = PrintType(val)
+		case key == "PRT QTY":
+			q, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid print quantity on line %d: %s", n, val)
+			}
+			job.Quantity = q
+		case key == "IMG FMT":
+			job.Format = val
+		case key == "IMG SRC":
+			// The paths are relative to the folder holding the print order file.
+			job.Path = path.Join("/"+dpofFolder, val)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if job != nil {
+		po.Jobs = append(po.Jobs, *job)
+	}
+
+	return po, nil
+}
+
+// Marshal returns the contents of the DPOF print order file.
+func (po *PrintOrder) Marshal() []byte {
+	created := po.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	creator := po.Creator
+	if creator == "" {
+		creator = "ptp-ip"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "[HDR]\r\nGEN REV = %s\r\nGEN CRT = %q\r\nGEN DTM = %s\r\n", dpofRevision, creator, created.Format(dpofDateTimeFormat))
+	for i, job := range po.Jobs {
+		format := job.Format
+		if format == "" {
+			format = "EXIF2 -J"
+		}
+		src := relativePath("/"+dpofFolder, job.Path)
+		fmt.Fprintf(&b, "\r\n[JOB]\r\nPRT PID = %03d\r\nPRT TYP = %s\r\nPRT QTY = %03d\r\nIMG FMT = %s\r\n<IMG SRC = %q>\r\n", i+1, job.Type, job.Quantity, format, src)
+	}
+
+	return b.Bytes()
+}
+
+// relativePath returns the path of target relative to the folder dir, both being absolute slash separated paths.
+func relativePath(dir, target string) string {
+	d := strings.Split(strings.Trim(path.Clean(dir), "/"), "/")
+	t := strings.Split(strings.Trim(path.Clean(target), "/"), "/")
+
+	i := 0
+	for i < len(d) && i < len(t) && d[i] == t[i] {
+		i++
+	}
+
+	return strings.Repeat("../", len(d)-i) + strings.Join(t[i:], "/")
+}
+
+// GetPrintOrder reads the DPOF print order from the memory card. An empty print order is returned when the card holds
+// none.
+func (c *Client) GetPrintOrder() (*PrintOrder, error) {
+	n, err := c.ObjectTree(AllStorages).Lookup(DPOFPath)
+	if errors.Is(err, ErrNoSuchObject) {
+		return &PrintOrder{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.GetObject(n.Handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePrintOrder(data)
+}
+
+// SetPrintOrder writes the DPOF print order to the memory card, replacing the existing one. The print order file is
+// deleted when there are no print jobs. The MISC folder is created when needed, in the store holding the DCIM folder.
+func (c *Client) SetPrintOrder(po *PrintOrder) error {
+	tree := c.ObjectTree(AllStorages)
+
+	old, err := tree.Lookup(DPOFPath)
+	switch {
+	case err == nil:
+		if err := c.DeleteObject(old.Handle); err != nil {
+			return fmt.Errorf("deleting the print order: %w", err)
+		}
+	case !errors.Is(err, ErrNoSuchObject):
+		return err
+	}
+	if len(po.Jobs) == 0 {
+		return nil
+	}
+
+	folder, err := tree.Lookup("/" + dpofFolder)
+	if errors.Is(err, ErrNoSuchObject) {
+		folder, err = c.createDPOFFolder(tree)
+	}
+	if err != nil {
+		return err
+	}
+
+	data := po.Marshal()
+	_, err = c.SendObject(folder.Info.StorageID, folder.Handle, &ptp.ObjectInfo{
+		StorageID:            folder.Info.StorageID,
+		ObjectFormat:         ptp.OFC_DPOF,
+		ObjectCompressedSize: uint32(len(data)),
+		ParentObject:         folder.Handle,
+		Filename:             dpofFile,
+	}, data)
+
+	return err
+}
+
+// createDPOFFolder creates the folder holding the DPOF files in the root of the store holding the DCIM folder, or in
+// the first store when there is none.
+func (c *Client) createDPOFFolder(tree *ObjectTree) (*ObjectNode, error) {
+	var sid ptp.StorageID
+	if dcim, err := tree.Lookup("/DCIM"); err == nil {
+		sid = dcim.Info.StorageID
+	} else {
+		sids, err := c.GetStorageIDs()
+		if err != nil {
+			return nil, err
+		}
+		if len(sids) == 0 {
+			return nil, errors.New("the camera has no stores")
+		}
+		sid = sids[0]
+	}
+
+	oi := &ptp.ObjectInfo{
+		StorageID:       sid,
+		ObjectFormat:    ptp.OFC_Association,
+		AssociationType: ptp.AT_GenericFolder,
+		Filename:        dpofFolder,
+	}
+	h, err := c.SendObject(sid, RootObjects, oi, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating the %s folder: %w", dpofFolder, err)
+	}
+
+	return &ObjectNode{Object: Object{Handle: h, Info: oi}, Parent: tree.Root(), tree: tree}, nil
+}
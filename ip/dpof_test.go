@@ -0,0 +1,59 @@
+package ip
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParsePrintOrder(t *testing.T) {
+	data := []byte("[HDR]\r\nGEN REV = 01.10\r\nGEN CRT = \"X-T1\"\r\nGEN DTM = 2021:03:14:15:30:45\r\n\r\n" +
+		"[JOB]\r\nPRT PID = 001\r\nPRT TYP = STD\r\nPRT QTY = 002\r\nIMG FMT = EXIF2 -J\r\n<IMG SRC = \"../DCIM/100_FUJI/DSCF0001.JPG\">\r\nCFG DSC = \"YES\" -DTM\r\n\r\n" +
+		"[JOB]\r\nPRT PID = 002\r\nPRT TYP = IDX\r\n<IMG SRC = \"../DCIM/100_FUJI/DSCF0002.JPG\">\r\n")
+
+	po, err := ParsePrintOrder(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if po.Creator != "X-T1" {
+		t.Errorf("ParsePrintOrder() Creator = %s; want X-T1", po.Creator)
+	}
+	if want := time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local); !po.Created.Equal(want) {
+		t.Errorf("ParsePrintOrder() Created = %s; want %s", po.Created, want)
+	}
+	want := []PrintJob{
+		{Type: PT_Standard, Quantity: 2, Path: "/DCIM/100_FUJI/DSCF0001.JPG", Format: "EXIF2 -J"},
+		{Type: PT_Index, Quantity: 1, Path: "/DCIM/100_FUJI/DSCF0002.JPG"},
+	}
+	if len(po.Jobs) != len(want) {
+		t.Fatalf("ParsePrintOrder() got %d jobs; want %d", len(po.Jobs), len(want))
+	}
+	for i, job := range po.Jobs {
+		if job != want[i] {
+			t.Errorf("ParsePrintOrder() job %d = %+v; want %+v", i, job, want[i])
+		}
+	}
+
+	if _, err := ParsePrintOrder([]byte("[JOB]\r\nPRT QTY = two\r\n")); err == nil {
+		t.Error("ParsePrintOrder() error = <nil>; want error")
+	}
+}
+
+func TestPrintOrder_Marshal(t *testing.T) {
+	po := &PrintOrder{
+		Created: time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local),
+		Jobs:    []PrintJob{{Type: PT_Standard, Quantity: 3, Path: "/DCIM/100_FUJI/DSCF0001.JPG"}},
+	}
+
+	got := po.Marshal()
+	for _, want := range []string{"GEN CRT = \"ptp-ip\"\r\n", "GEN DTM = 2021:03:14:15:30:45\r\n", "PRT QTY = 003\r\n", "IMG FMT = EXIF2 -J\r\n", "<IMG SRC = \"../DCIM/100_FUJI/DSCF0001.JPG\">\r\n"} {
+		if !bytes.Contains(got, []byte(want)) {
+			t.Errorf("Marshal() got = %q; want it to contain %q", got, want)
+		}
+	}
+
+	parsed, err := ParsePrintOrder(got)
+	if err != nil || len(parsed.Jobs) != 1 || parsed.Jobs[0].Path != po.Jobs[0].Path || parsed.Jobs[0].Quantity != 3 {
+		t.Errorf("ParsePrintOrder(Marshal()) got = %+v, %v; want the marshalled print order", parsed, err)
+	}
+}
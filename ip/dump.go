@@ -0,0 +1,235 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/malc0mn/ptp-ip/ip/internal"
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// dumpMaxBytes is the maximum number of bytes of a single field included in a packet dump, so that dumping a data
+// packet holding an image does not flood the log.
+const dumpMaxBytes = 256
+
+var packetTypeNames = map[PacketType]string{
+	PKT_InitCommandRequest: "InitCommandRequest",
+	PKT_InitCommandAck:     "InitCommandAck",
+	PKT_InitEventRequest:   "InitEventRequest",
+	PKT_InitEventAck:       "InitEventAck",
+	PKT_InitFail:           "InitFail",
+	PKT_OperationRequest:   "OperationRequest",
+	PKT_OperationResponse:  "OperationResponse",
+	PKT_Event:              "Event",
+	PKT_StartData:          "StartData",
+	PKT_Data:               "Data",
+	PKT_Cancel:             "Cancel",
+	PKT_EndData:            "EndData",
+	PKT_ProbeRequest:       "ProbeRequest",
+	PKT_ProbeResponse:      "ProbeResponse",
+}
+
+// packetTypeName returns the name of the packet type as used in the PTP/IP specification.
+func packetTypeName(pt PacketType) string {
+	if n, ok := packetTypeNames[pt]; ok {
+		return n
+	}
+
+	return "unknown"
+}
+
+// DumpPacket renders the packet as an annotated field by field dump, intended for debugging and reverse engineering
+// purposes. Operation and response codes are annotated with their names and byte fields are added as a hexadecimal
+// dump.
+func DumpPacket(p Packet) string {
+	if p == nil {
+		return "<nil>\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%T", p)
+	if pt := p.PacketType(); pt != PKT_Invalid {
+		fmt.Fprintf(&b, " (%s)", packetTypeName(pt))
+	}
+	b.WriteString("\n")
+	dumpFields(&b, reflect.Indirect(reflect.ValueOf(p)), "  ")
+
+	return b.String()
+}
+
+// dumpFields writes the exported fields of the struct v to b, flattening embedded structs.
+func dumpFields(b *strings.Builder, v reflect.Value, indent string) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		f, fv := v.Type().Field(i), v.Field(i)
+		// The SessionID is not transferred by PTP/IP.
+		if !f.IsExported() || f.Type == reflect.TypeOf(ptp.SessionID(0)) {
+			continue
+		}
+		if f.Anonymous && fv.Kind() == reflect.Struct {
+			dumpFields(b, fv, indent)
+			continue
+		}
+		if fv.Kind() == reflect.Struct && f.Type != reflect.TypeOf(uuid.UUID{}) {
+			fmt.Fprintf(b, "%s%s:\n", indent, f.Name)
+			dumpFields(b, fv, indent+"  ")
+			continue
+		}
+
+		fmt.Fprintf(b, "%s%s: %s\n", indent, f.Name, dumpValue(fv, indent+"  "))
+	}
+}
+
+// dumpValue formats a single field value.
+func dumpValue(v reflect.Value, indent string) string {
+	switch val := v.Interface().(type) {
+	case ptp.OperationCode:
+		if oi, ok := ptp.OperationCodeInfo(val); ok {
+			return fmt.Sprintf("%#04x (%s)", uint16(val), oi.Name)
+		}
+		return fmt.Sprintf("%#04x", uint16(val))
+	case ptp.OperationResponseCode:
+		if s := val.String(); !strings.HasPrefix(s, "0x") {
+			return fmt.Sprintf("%#04x (%s)", uint16(val), s)
+		}
+		return fmt.Sprintf("%#04x", uint16(val))
+	case PacketType:
+		return fmt.Sprintf("%#x (%s)", uint32(val), packetTypeName(val))
+	case DataPhase:
+		switch val {
+		case DP_NoDataOrDataIn:
+			return fmt.Sprintf("%d (no data or data in)", val)
+		case DP_DataOut:
+			return fmt.Sprintf("%d (data out)", val)
+		}
+		return fmt.Sprintf("%d (unknown)", val)
+	case uuid.UUID:
+		return val.String()
+	case []byte:
+		return dumpBytes(val, indent)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d (%#x)", v.Int(), v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d (%#x)", v.Uint(), v.Uint())
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return "<nil>"
+		}
+		return dumpValue(v.Elem(), indent)
+	}
+
+	return fmt.Sprintf("%#x", v.Interface())
+}
+
+// dumpBytes returns the hexadecimal dump of the first dumpMaxBytes bytes of b, each line prefixed with indent.
+func dumpBytes(b []byte, indent string) string {
+	if len(b) == 0 {
+		return "none"
+	}
+
+	l, more := len(b), ""
+	if l > dumpMaxBytes {
+		more = fmt.Sprintf("\n%s... %d more bytes", indent, l-dumpMaxBytes)
+		b = b[:dumpMaxBytes]
+	}
+	lines := strings.Split(strings.TrimSuffix(hex.Dump(b), "\n"), "\n")
+
+	return fmt.Sprintf("%d bytes\n%s%s%s", l, indent, strings.Join(lines, "\n"+indent), more)
+}
+
+// GenericDumpRawPacket renders a full raw packet as received from the Responder, including the header, as an
+// annotated field by field dump. Packets that cannot be parsed are returned as a hexadecimal dump.
+func GenericDumpRawPacket(raw []byte) string {
+	if len(raw) < HeaderSize {
+		return hex.Dump(raw)
+	}
+
+	var h Header
+	binary.Read(bytes.NewReader(raw), binary.LittleEndian, &h)
+	p, err := NewPacketInFromPacketType(h.PacketType)
+	if err != nil {
+		return fmt.Sprintf("unknown packet type %#x\n%s", uint32(h.PacketType), hex.Dump(raw))
+	}
+
+	return dumpRawPacketAs(raw, HeaderSize, p)
+}
+
+// FujiDumpRawPacket renders a full raw packet as received from a Fuji Responder as an annotated field by field dump.
+// Fuji does not send a packet type, so the packet is taken to be an event when the code is an event code, a data packet
+// when the data phase is DP_DataOut and an operation response otherwise.
+func FujiDumpRawPacket(raw []byte) string {
+	if len(raw) < 12 {
+		return hex.Dump(raw)
+	}
+
+	var p PacketIn = &FujiOperationResponsePacket{}
+	code := binary.LittleEndian.Uint16(raw[6:8])
+	switch {
+	case code&0x7000 == 0x4000:
+		p = &FujiEventPacket{}
+	case DataPhase(binary.LittleEndian.Uint16(raw[4:6])) == DP_DataOut:
+		return fmt.Sprintf(
+			"data for operation %s, transaction %d\n  Data: %s\n",
+			ptp.OperationCodeName(ptp.OperationCode(code)), binary.LittleEndian.Uint32(raw[8:12]),
+			dumpBytes(raw[12:], "    "),
+		)
+	}
+
+	return dumpRawPacketAs(raw, 4, p)
+}
+
+// dumpRawPacketAs unmarshals the raw packet, skipping the header of hl bytes, into p and returns the dump of p followed by
+// the dump of any remaining bytes.
+func dumpRawPacketAs(raw []byte, hl int, p PacketIn) string {
+	l := len(raw) - hl
+	xs, err := internal.UnmarshalLittleEndian(bytes.NewReader(raw[hl:]), p, l, l-p.TotalFixedFieldSize())
+	if err != nil && len(xs) == 0 {
+		return fmt.Sprintf("%s  invalid packet: %s\n%s", DumpPacket(p), err, hex.Dump(raw))
+	}
+
+	res := DumpPacket(p)
+	if len(xs) > 0 {
+		res += "  Remaining: " + dumpBytes(xs, "    ") + "\n"
+	}
+
+	return res
+}
+
+// packetDump renders an outgoing packet only when it is formatted, so that logging a dump does not cost anything when
+// the log level discards it.
+type packetDump struct {
+	p Packet
+}
+
+func (pd packetDump) String() string {
+	return strings.TrimSuffix(DumpPacket(pd.p), "\n")
+}
+
+// rawPacketDump renders a raw inbound packet only when it is formatted.
+type rawPacketDump struct {
+	raw  []byte
+	dump func([]byte) string
+}
+
+func (rd rawPacketDump) String() string {
+	return strings.TrimSuffix(rd.dump(rd.raw), "\n")
+}
+
+// DumpRawPacket renders a full raw packet as returned by OperationRequestRaw() as an annotated field by field dump,
+// taking the vendor specific packet layout into account.
+func (c *Client) DumpRawPacket(raw []byte) string {
+	return c.vendorExtensions.dumpRawPacket(raw)
+}
@@ -0,0 +1,93 @@
+package ip
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestDumpPacket(t *testing.T) {
+	guid, _ := uuid.Parse("cf2ad5a3-7c6c-4d4d-a2b7-d3d5b8c0e1f2")
+	check := []struct {
+		p    Packet
+		want string
+	}{
+		{
+			&OperationRequestPacket{
+				DataPhaseInfo: DP_NoDataOrDataIn,
+				OperationRequest: ptp.OperationRequest{
+					OperationCode: ptp.OC_GetDevicePropDesc,
+					TransactionID: 4,
+					Parameters:    []uint32{0x5003},
+				},
+			},
+			"*ip.OperationRequestPacket (OperationRequest)\n" +
+				"  DataPhaseInfo: 1 (no data or data in)\n" +
+				"  OperationCode: 0x1014 (GetDevicePropDesc)\n" +
+				"  TransactionID: 4 (0x4)\n" +
+				"  Parameters: [0x5003]\n",
+		},
+		{
+			NewInitCommandRequestPacket(guid, "tèster"),
+			"*ip.GenericInitCommandRequestPacket (InitCommandRequest)\n" +
+				"  GUID: cf2ad5a3-7c6c-4d4d-a2b7-d3d5b8c0e1f2\n" +
+				"  FriendlyName: \"tèster\"\n" +
+				"  ProtocolVersion: 65536 (0x10000)\n",
+		},
+		{
+			&EndDataPacket{TransactionId: 2, DataPayload: []byte{0x01, 0x02}},
+			"*ip.EndDataPacket (EndData)\n" +
+				"  TransactionId: 2 (0x2)\n" +
+				"  DataPayload: 2 bytes\n" +
+				"    00000000  01 02                                             |..|\n",
+		},
+		{nil, "<nil>\n"},
+	}
+
+	for _, tt := range check {
+		if got := DumpPacket(tt.p); got != tt.want {
+			t.Errorf("DumpPacket() got = %q; want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDumpPacket_truncated(t *testing.T) {
+	got := DumpPacket(&EndDataPacket{DataPayload: make([]byte, dumpMaxBytes+10)})
+	if !strings.Contains(got, "DataPayload: 266 bytes\n") || !strings.HasSuffix(got, "... 10 more bytes\n") {
+		t.Errorf("DumpPacket() got = %s; want 266 bytes truncated to %d", got, dumpMaxBytes)
+	}
+}
+
+func TestGenericDumpRawPacket(t *testing.T) {
+	got := GenericDumpRawPacket([]byte{0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x0c, 0x20, 0x03, 0x00, 0x00, 0x00})
+	want := "*ip.OperationResponsePacket (OperationResponse)\n" +
+		"  ResponseCode: 0x200c (Store_Full)\n" +
+		"  TransactionID: 3 (0x3)\n" +
+		"  Parameters: []\n"
+	if got != want {
+		t.Errorf("GenericDumpRawPacket() got = %q; want %q", got, want)
+	}
+
+	got = GenericDumpRawPacket([]byte{0x08, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00})
+	if !strings.HasPrefix(got, "unknown packet type 0xff\n00000000  08 00 00 00 ff") {
+		t.Errorf("GenericDumpRawPacket() got = %q; want a hex dump for an unknown packet type", got)
+	}
+}
+
+func TestFujiDumpRawPacket(t *testing.T) {
+	got := FujiDumpRawPacket([]byte{0x0c, 0x00, 0x00, 0x00, 0x03, 0x00, 0x01, 0x20, 0x05, 0x00, 0x00, 0x00})
+	want := "*ip.FujiOperationResponsePacket\n" +
+		"  DataPhase: 3 (0x3)\n" +
+		"  OperationResponseCode: 0x2001 (OK)\n" +
+		"  TransactionID: 5 (0x5)\n"
+	if got != want {
+		t.Errorf("FujiDumpRawPacket() got = %q; want %q", got, want)
+	}
+
+	got = FujiDumpRawPacket([]byte{0x10, 0x00, 0x00, 0x00, 0x02, 0x00, 0x14, 0x10, 0x05, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04})
+	if !strings.HasPrefix(got, "data for operation GetDevicePropDesc, transaction 5\n  Data: 4 bytes\n") {
+		t.Errorf("FujiDumpRawPacket() got = %q; want a data packet dump", got)
+	}
+}
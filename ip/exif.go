@@ -0,0 +1,432 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ObjectMetadata holds the EXIF metadata of an image that is most useful when browsing the images on a camera. The
+// fields missing from the image are left at their zero value.
+type ObjectMetadata struct {
+	Make      string
+	Model     string
+	LensModel string
+	// Captured is the time the image was taken in the local time of the camera.
+	Captured time.Time
+	// ExposureTime is the shutter speed in seconds.
+	ExposureTime float64
+	FNumber      float64
+	ISO          int
+	// FocalLength is the focal length of the lens in millimetres.
+	FocalLength float64
+	// Orientation is the EXIF orientation of the image, 1 being upright.
+	Orientation int
+	// FilmSimulation is the film simulation of an image taken by a Fujifilm camera, read from its maker note. It is
+	// zero for other cameras and for the film simulations not known to FujiFilmSimulation.
+	FilmSimulation FujiFilmSimulation
+	// GPS is the location the image was taken at, nil when the image holds no GPS data.
+	GPS *GPSPosition
+}
+
+// GPSPosition is a location read from the GPS IFD of an image.
+type GPSPosition struct {
+	// Latitude is the latitude in degrees, negative values being south of the equator.
+	Latitude float64
+	// Longitude is the longitude in degrees, negative values being west of Greenwich.
+	Longitude float64
+	// Altitude is the altitude in metres, negative values being below sea level.
+	Altitude float64
+}
+
+const (
+	// exifMaxEntries is the maximum number of entries read from an IFD, guarding against corrupt data.
+	exifMaxEntries = 1000
+	// exifMaxSegments is the maximum number of JPEG segments skipped looking for the EXIF segment.
+	exifMaxSegments = 64
+	// exifDateTimeFormat is the layout of the EXIF DateTime tags.
+	exifDateTimeFormat = "2006:01:02 15:04:05"
+	// rafJPEGOffset is the offset of the offset of the embedded JPEG image in a Fujifilm RAF file, which holds the
+	// EXIF metadata.
+	rafJPEGOffset = 84
+)
+
+// EXIF tags of IFD0.
+const (
+	exifTagMake        = 0x010F
+	exifTagModel       = 0x0110
+	exifTagOrientation = 0x0112
+	exifTagDateTime    = 0x0132
+	exifTagExifIFD     = 0x8769
+	exifTagGPSIFD      = 0x8825
+)
+
+// EXIF tags of the GPS IFD.
+const (
+	gpsTagLatitudeRef  = 0x0001
+	gpsTagLatitude     = 0x0002
+	gpsTagLongitudeRef = 0x0003
+	gpsTagLongitude    = 0x0004
+	gpsTagAltitudeRef  = 0x0005
+	gpsTagAltitude     = 0x0006
+)
+
+// EXIF tags of the Exif IFD.
+const (
+	exifTagExposureTime     = 0x829A
+	exifTagFNumber          = 0x829D
+	exifTagISO              = 0x8827
+	exifTagDateTimeOriginal = 0x9003
+	exifTagFocalLength      = 0x920A
+	exifTagLensModel        = 0xA434
+	exifTagMakerNote        = 0x927C
+)
+
+// Tags of the Fujifilm maker note.
+const (
+	fujiTagSaturation = 0x1003
+	fujiTagFilmMode   = 0x1401
+)
+
+// fujiFilmModes maps the FilmMode tag of the Fujifilm maker note to the film simulations.
+var fujiFilmModes = map[uint32]FujiFilmSimulation{
+	0x000: FS_Fuji_Provia,
+	0x120: FS_Fuji_Astia,
+	0x200: FS_Fuji_Velvia,
+	0x400: FS_Fuji_Velvia,
+	0x500: FS_Fuji_ProNegStandard,
+	0x501: FS_Fuji_ProNegHigh,
+	0x600: FS_Fuji_ClassicChrome,
+	0x700: FS_Fuji_ETERNA,
+}
+
+// fujiMonochromeModes maps the Saturation tag of the Fujifilm maker note to the monochrome film simulations, which have
+// no FilmMode tag.
+var fujiMonochromeModes = map[uint32]FujiFilmSimulation{
+	0x300: FS_Fuji_Monochrome,
+	0x301: FS_Fuji_MonochromeRFilter,
+	0x302: FS_Fuji_MonochromeYeFilter,
+	0x303: FS_Fuji_MonochromeGFilter,
+	0x310: FS_Fuji_Sepia,
+	0x500: FS_Fuji_ACROS,
+	0x501: FS_Fuji_ACROSR,
+	0x502: FS_Fuji_ACROSYe,
+	0x503: FS_Fuji_ACROSG,
+}
+
+// EXIF field types.
+const (
+	exifByte      = 1
+	exifASCII     = 2
+	exifShort     = 3
+	exifLong      = 4
+	exifRational  = 5
+	exifUndefined = 7
+)
+
+// errNoExif is returned when an image holds no EXIF metadata.
+var errNoExif = errors.New("no EXIF metadata found")
+
+// ReadMetadata reads the EXIF metadata from a JPEG image, a TIFF based RAW file such as DNG, NEF, CR2 or ARW, or a
+// Fujifilm RAF file. Only the parts of r holding the metadata are read.
+func ReadMetadata(r io.ReaderAt) (*ObjectMetadata, error) {
+	magic := make([]byte, 16)
+	if _, err := r.ReadAt(magic, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		return readJPEGMetadata(r, 0)
+	case bytes.HasPrefix(magic, []byte("FUJIFILMCCD-RAW")):
+		b := make([]byte, 4)
+		if _, err := r.ReadAt(b, rafJPEGOffset); err != nil {
+			return nil, fmt.Errorf("reading RAF header: %w", err)
+		}
+		return readJPEGMetadata(r, int64(binary.BigEndian.Uint32(b)))
+	case bytes.HasPrefix(magic, []byte("II")) || bytes.HasPrefix(magic, []byte("MM")):
+		return readTIFFMetadata(r, 0)
+	}
+
+	return nil, errNoExif
+}
+
+// readJPEGMetadata looks for the APP1 segment holding the EXIF metadata of the JPEG image starting at off.
+func readJPEGMetadata(r io.ReaderAt, off int64) (*ObjectMetadata, error) {
+	// Skip the start of image marker.
+	off += 2
+	hdr, id := make([]byte, 4), make([]byte, 6)
+	for i := 0; i < exifMaxSegments; i++ {
+		if _, err := r.ReadAt(hdr[:2], off); err != nil {
+			return nil, fmt.Errorf("reading JPEG segment: %w", err)
+		}
+		if hdr[0] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG segment marker %#x at offset %d", hdr[0], off)
+		}
+		if hdr[1] == 0xDA || hdr[1] == 0xD9 {
+			// The image data starts or the image ends without EXIF metadata.
+			return nil, errNoExif
+		}
+
+		if _, err := r.ReadAt(hdr[2:], off+2); err != nil {
+			return nil, fmt.Errorf("reading JPEG segment: %w", err)
+		}
+		if hdr[1] == 0xE1 {
+			if _, err := r.ReadAt(id, off+4); err == nil && string(id) == "Exif\x00\x00" {
+				return readTIFFMetadata(r, off+10)
+			}
+		}
+		off += 2 + int64(binary.BigEndian.Uint16(hdr[2:4]))
+	}
+
+	return nil, errNoExif
+}
+
+// tiffReader reads the IFDs of a TIFF structure starting at base.
+type tiffReader struct {
+	r    io.ReaderAt
+	base int64
+	bo   binary.ByteOrder
+}
+
+// ifdEntry is an entry of an IFD, the value holding the value itself when it fits in four bytes or its offset
+// otherwise.
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// readTIFFMetadata reads the metadata from the TIFF structure starting at base.
+func readTIFFMetadata(r io.ReaderAt, base int64) (*ObjectMetadata, error) {
+	hdr := make([]byte, 8)
+	if _, err := r.ReadAt(hdr, base); err != nil {
+		return nil, fmt.Errorf("reading TIFF header: %w", err)
+	}
+
+	t := &tiffReader{r: r, base: base}
+	switch string(hdr[:2]) {
+	case "II":
+		t.bo = binary.LittleEndian
+	case "MM":
+		t.bo = binary.BigEndian
+	default:
+		return nil, errNoExif
+	}
+
+	ifd0, err := t.readIFD(t.bo.Uint32(hdr[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	md := &ObjectMetadata{}
+	md.Make = t.string(ifd0[exifTagMake])
+	md.Model = t.string(ifd0[exifTagModel])
+	md.Orientation = int(t.uint(ifd0[exifTagOrientation]))
+	md.Captured = t.dateTime(ifd0[exifTagDateTime])
+
+	if e, ok := ifd0[exifTagExifIFD]; ok {
+		exif, err := t.readIFD(uint32(t.uint(e)))
+		if err != nil {
+			return nil, err
+		}
+		md.ExposureTime = t.rational(exif[exifTagExposureTime])
+		md.FNumber = t.rational(exif[exifTagFNumber])
+		md.ISO = int(t.uint(exif[exifTagISO]))
+		md.FocalLength = t.rational(exif[exifTagFocalLength])
+		md.LensModel = t.string(exif[exifTagLensModel])
+		if captured := t.dateTime(exif[exifTagDateTimeOriginal]); !captured.IsZero() {
+			md.Captured = captured
+		}
+		if e, ok := exif[exifTagMakerNote]; ok {
+			md.FilmSimulation = t.fujiFilmSimulation(e)
+		}
+	}
+	if e, ok := ifd0[exifTagGPSIFD]; ok {
+		gps, err := t.readIFD(uint32(t.uint(e)))
+		if err != nil {
+			return nil, err
+		}
+		md.GPS = t.gpsPosition(gps)
+	}
+
+	return md, nil
+}
+
+// fujiFilmSimulation returns the film simulation stored in a Fujifilm maker note, or zero when the maker note is not
+// one of Fujifilm. The maker note is a little endian IFD, its offsets being relative to the start of the maker note.
+func (t *tiffReader) fujiFilmSimulation(e ifdEntry) FujiFilmSimulation {
+	if e.typ != exifUndefined || e.count < 12 {
+		return 0
+	}
+	start := t.base + int64(t.bo.Uint32(e.value))
+	hdr := make([]byte, 12)
+	if _, err := t.r.ReadAt(hdr, start); err != nil || string(hdr[:8]) != "FUJIFILM" {
+		return 0
+	}
+
+	mn := &tiffReader{r: t.r, base: start, bo: binary.LittleEndian}
+	ifd, err := mn.readIFD(mn.bo.Uint32(hdr[8:]))
+	if err != nil {
+		return 0
+	}
+	if e, ok := ifd[fujiTagFilmMode]; ok {
+		return fujiFilmModes[mn.uint(e)]
+	}
+
+	return fujiMonochromeModes[mn.uint(ifd[fujiTagSaturation])]
+}
+
+// gpsPosition returns the position held by the GPS IFD, or nil when it does not hold a latitude and a longitude.
+func (t *tiffReader) gpsPosition(gps map[uint16]ifdEntry) *GPSPosition {
+	lat, lon := t.rationals(gps[gpsTagLatitude]), t.rationals(gps[gpsTagLongitude])
+	if len(lat) != 3 || len(lon) != 3 {
+		return nil
+	}
+
+	pos := &GPSPosition{
+		Latitude:  lat[0] + lat[1]/60 + lat[2]/3600,
+		Longitude: lon[0] + lon[1]/60 + lon[2]/3600,
+	}
+	if t.string(gps[gpsTagLatitudeRef]) == "S" {
+		pos.Latitude = -pos.Latitude
+	}
+	if t.string(gps[gpsTagLongitudeRef]) == "W" {
+		pos.Longitude = -pos.Longitude
+	}
+	if alt := t.rationals(gps[gpsTagAltitude]); len(alt) == 1 {
+		pos.Altitude = alt[0]
+		// An altitude reference of 1 means below sea level.
+		if ref := t.data(gps[gpsTagAltitudeRef]); gps[gpsTagAltitudeRef].typ == exifByte && len(ref) == 1 && ref[0] == 1 {
+			pos.Altitude = -pos.Altitude
+		}
+	}
+
+	return pos
+}
+
+// readIFD reads the entries of the IFD at the given offset from the start of the TIFF structure.
+func (t *tiffReader) readIFD(off uint32) (map[uint16]ifdEntry, error) {
+	b := make([]byte, 2)
+	if _, err := t.r.ReadAt(b, t.base+int64(off)); err != nil {
+		return nil, fmt.Errorf("reading IFD: %w", err)
+	}
+	n := int(t.bo.Uint16(b))
+	if n > exifMaxEntries {
+		return nil, fmt.Errorf("invalid IFD holding %d entries", n)
+	}
+
+	b = make([]byte, n*12)
+	if _, err := t.r.ReadAt(b, t.base+int64(off)+2); err != nil {
+		return nil, fmt.Errorf("reading IFD: %w", err)
+	}
+
+	entries := make(map[uint16]ifdEntry, n)
+	for i := 0; i < n; i++ {
+		e := b[i*12 : (i+1)*12]
+		entries[t.bo.Uint16(e)] = ifdEntry{typ: t.bo.Uint16(e[2:]), count: t.bo.Uint32(e[4:]), value: e[8:12]}
+	}
+
+	return entries, nil
+}
+
+// data returns the value of the entry, reading it from its offset when it does not fit in the entry itself.
+func (t *tiffReader) data(e ifdEntry) []byte {
+	var size uint32
+	switch e.typ {
+	case exifByte, exifASCII, exifUndefined:
+		size = 1
+	case exifShort:
+		size = 2
+	case exifLong:
+		size = 4
+	case exifRational:
+		size = 8
+	default:
+		return nil
+	}
+	if e.count == 0 || e.count > 1<<16 {
+		return nil
+	}
+
+	l := size * e.count
+	if l <= 4 {
+		return e.value[:l]
+	}
+
+	b := make([]byte, l)
+	if _, err := t.r.ReadAt(b, t.base+int64(t.bo.Uint32(e.value))); err != nil {
+		return nil
+	}
+
+	return b
+}
+
+// string returns the value of an ASCII entry.
+func (t *tiffReader) string(e ifdEntry) string {
+	if e.typ != exifASCII {
+		return ""
+	}
+	b, _, _ := bytes.Cut(t.data(e), []byte{0})
+
+	return strings.TrimSpace(string(b))
+}
+
+// uint returns the value of a SHORT or LONG entry.
+func (t *tiffReader) uint(e ifdEntry) uint32 {
+	b := t.data(e)
+	switch {
+	case e.typ == exifShort && len(b) >= 2:
+		return uint32(t.bo.Uint16(b))
+	case e.typ == exifLong && len(b) >= 4:
+		return t.bo.Uint32(b)
+	}
+
+	return 0
+}
+
+// rational returns the value of a RATIONAL entry.
+func (t *tiffReader) rational(e ifdEntry) float64 {
+	b := t.data(e)
+	if e.typ != exifRational || len(b) < 8 {
+		return 0
+	}
+	num, den := t.bo.Uint32(b), t.bo.Uint32(b[4:])
+	if den == 0 {
+		return 0
+	}
+
+	return float64(num) / float64(den)
+}
+
+// rationals returns the values of a RATIONAL entry holding several values.
+func (t *tiffReader) rationals(e ifdEntry) []float64 {
+	b := t.data(e)
+	if e.typ != exifRational || len(b) < 8 {
+		return nil
+	}
+
+	vals := make([]float64, len(b)/8)
+	for i := range vals {
+		num, den := t.bo.Uint32(b[i*8:]), t.bo.Uint32(b[i*8+4:])
+		if den != 0 {
+			vals[i] = float64(num) / float64(den)
+		}
+	}
+
+	return vals
+}
+
+// dateTime returns the value of a DateTime entry, which is local time of the camera.
+func (t *tiffReader) dateTime(e ifdEntry) time.Time {
+	dt, err := time.ParseInLocation(exifDateTimeFormat, t.string(e), time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return dt
+}
@@ -0,0 +1,207 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// tiffEntry is an IFD entry used to build test data, the value being placed after the IFDs when it does not fit in the
+// entry.
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// exifTIFF builds a TIFF structure holding an IFD0, an Exif IFD and a GPS IFD.
+func exifTIFF(bo binary.ByteOrder) []byte {
+	rational := func(num, den uint32) []byte {
+		b := make([]byte, 8)
+		bo.PutUint32(b, num)
+		bo.PutUint32(b[4:], den)
+		return b
+	}
+	short := func(v uint16) []byte {
+		b := make([]byte, 2)
+		bo.PutUint16(b, v)
+		return b
+	}
+	ascii := func(s string) []byte {
+		return append([]byte(s), 0)
+	}
+
+	ifd0 := []tiffEntry{
+		{exifTagMake, exifASCII, 9, ascii("FUJIFILM")},
+		{exifTagModel, exifASCII, 5, ascii("X-T1")},
+		{exifTagOrientation, exifShort, 1, short(6)},
+		{exifTagExifIFD, exifLong, 1, nil},
+		{exifTagGPSIFD, exifLong, 1, nil},
+	}
+	exif := []tiffEntry{
+		{exifTagExposureTime, exifRational, 1, rational(1, 250)},
+		{exifTagFNumber, exifRational, 1, rational(56, 10)},
+		{exifTagISO, exifShort, 1, short(400)},
+		{exifTagDateTimeOriginal, exifASCII, 20, ascii("2021:03:14 15:30:45")},
+		{exifTagFocalLength, exifRational, 1, rational(35, 1)},
+		{exifTagLensModel, exifASCII, 13, ascii("XF35mmF1.4 R")},
+		{exifTagMakerNote, exifUndefined, uint32(len(fujiMakerNote())), fujiMakerNote()},
+	}
+
+	gps := []tiffEntry{
+		{gpsTagLatitudeRef, exifASCII, 2, ascii("N")},
+		{gpsTagLatitude, exifRational, 3, append(append(rational(51, 1), rational(30, 1)...), rational(36, 1)...)},
+		{gpsTagLongitudeRef, exifASCII, 2, ascii("W")},
+		{gpsTagLongitude, exifRational, 3, append(append(rational(0, 1), rational(7, 1)...), rational(30, 1)...)},
+		{gpsTagAltitudeRef, exifByte, 1, []byte{1}},
+		{gpsTagAltitude, exifRational, 1, rational(25, 10)},
+	}
+
+	ifdSize := func(entries []tiffEntry) int {
+		return 2 + 12*len(entries) + 4
+	}
+	exifOff := 8 + ifdSize(ifd0)
+	gpsOff := exifOff + ifdSize(exif)
+	dataOff := gpsOff + ifdSize(gps)
+
+	var b, data bytes.Buffer
+	if bo == binary.LittleEndian {
+		b.WriteString("II")
+	} else {
+		b.WriteString("MM")
+	}
+	binary.Write(&b, bo, uint16(42))
+	binary.Write(&b, bo, uint32(8))
+	for _, entries := range [][]tiffEntry{ifd0, exif, gps} {
+		binary.Write(&b, bo, uint16(len(entries)))
+		for _, e := range entries {
+			binary.Write(&b, bo, e.tag)
+			binary.Write(&b, bo, e.typ)
+			binary.Write(&b, bo, e.count)
+			switch {
+			case e.tag == exifTagExifIFD:
+				binary.Write(&b, bo, uint32(exifOff))
+			case e.tag == exifTagGPSIFD:
+				binary.Write(&b, bo, uint32(gpsOff))
+			case len(e.value) <= 4:
+				b.Write(append(e.value, make([]byte, 4-len(e.value))...))
+			default:
+				binary.Write(&b, bo, uint32(dataOff+data.Len()))
+				data.Write(e.value)
+			}
+		}
+		binary.Write(&b, bo, uint32(0))
+	}
+	b.Write(data.Bytes())
+
+	return b.Bytes()
+}
+
+// fujiMakerNote builds a Fujifilm maker note holding the Classic Chrome film simulation. It is little endian whatever
+// the byte order of the TIFF structure holding it.
+func fujiMakerNote() []byte {
+	b := append([]byte("FUJIFILM"), 12, 0, 0, 0)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, fujiTagFilmMode)
+	b = binary.LittleEndian.AppendUint16(b, exifShort)
+	b = binary.LittleEndian.AppendUint32(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, 0x600)
+
+	return binary.LittleEndian.AppendUint32(b, 0)
+}
+
+// exifJPEG builds a JPEG image holding the given TIFF structure in its APP1 segment, preceded by an APP0 segment.
+func exifJPEG(tiff []byte) []byte {
+	b := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00}
+	b = append(b, 0xFF, 0xE1)
+	b = binary.BigEndian.AppendUint16(b, uint16(2+6+len(tiff)))
+	b = append(b, "Exif\x00\x00"...)
+	b = append(b, tiff...)
+
+	return append(b, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func TestReadMetadata(t *testing.T) {
+	raf := make([]byte, 100)
+	copy(raf, "FUJIFILMCCD-RAW 0201FF383501")
+	binary.BigEndian.PutUint32(raf[rafJPEGOffset:], uint32(len(raf)))
+	raf = append(raf, exifJPEG(exifTIFF(binary.BigEndian))...)
+
+	want := ObjectMetadata{
+		Make:           "FUJIFILM",
+		Model:          "X-T1",
+		LensModel:      "XF35mmF1.4 R",
+		Captured:       time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local),
+		ExposureTime:   0.004,
+		FNumber:        5.6,
+		ISO:            400,
+		FocalLength:    35,
+		Orientation:    6,
+		FilmSimulation: FS_Fuji_ClassicChrome,
+	}
+	wantGPS := GPSPosition{Latitude: 51.51, Longitude: -0.125, Altitude: -2.5}
+	for name, data := range map[string][]byte{
+		"tiff": exifTIFF(binary.LittleEndian),
+		"jpeg": exifJPEG(exifTIFF(binary.LittleEndian)),
+		"raf":  raf,
+	} {
+		got, err := ReadMetadata(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("ReadMetadata() %s error = %s; want <nil>", name, err)
+			continue
+		}
+		if !got.Captured.Equal(want.Captured) {
+			t.Errorf("ReadMetadata() %s Captured = %s; want %s", name, got.Captured, want.Captured)
+		}
+		got.Captured = want.Captured
+		if got.GPS == nil || *got.GPS != wantGPS {
+			t.Errorf("ReadMetadata() %s GPS = %+v; want %+v", name, got.GPS, wantGPS)
+		}
+		got.GPS = nil
+		if *got != want {
+			t.Errorf("ReadMetadata() %s got = %+v; want %+v", name, *got, want)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"no exif": {0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02},
+		"unknown": []byte("RIFF0000AVI LIST"),
+	} {
+		if _, err := ReadMetadata(bytes.NewReader(data)); err != errNoExif {
+			t.Errorf("ReadMetadata() %s error = %v; want %s", name, err, errNoExif)
+		}
+	}
+}
+
+func TestClient_GetObjectMetadata(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A large file with the metadata at the start, as is the case for RAW files.
+	obj := append(exifTIFF(binary.LittleEndian), make([]byte, 4*partialChunkSize)...)
+	var requests int
+	c.vendorExtensions.getPartialObject = func(_ *Client, h ptp.ObjectHandle, offset, max uint32) ([]byte, error) {
+		requests++
+		if int(offset) >= len(obj) {
+			return nil, nil
+		}
+		end := int(offset + max)
+		if end > len(obj) {
+			end = len(obj)
+		}
+		return obj[offset:end], nil
+	}
+
+	md, err := c.GetObjectMetadata(0x1a)
+	if err != nil || md.Model != "X-T1" {
+		t.Errorf("GetObjectMetadata() got = %+v, %v; want model X-T1, <nil>", md, err)
+	}
+	if requests != 1 {
+		t.Errorf("GetObjectMetadata() requests = %d; want 1", requests)
+	}
+}
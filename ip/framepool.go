@@ -0,0 +1,77 @@
+package ip
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// frameBufferPool holds the buffers used to read liveview frames from the streamer connection. At 30 frames per second
+// allocating a fresh slice for each frame of up to a megabyte puts a lot of pressure on the garbage collector.
+var frameBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &frameBuffer{}
+	},
+}
+
+// frameBuffer is a reference counted buffer holding a single raw liveview packet. It is returned to the pool once all
+// consumers of the frame have released it. A consumer that never releases the frame simply keeps the buffer out of the
+// pool: it will be garbage collected like any other slice.
+type frameBuffer struct {
+	b    []byte
+	refs int32
+}
+
+// getFrameBuffer returns a buffer of length n from the pool holding a single reference.
+func getFrameBuffer(n int) *frameBuffer {
+	fb := frameBufferPool.Get().(*frameBuffer)
+	if cap(fb.b) < n {
+		fb.b = make([]byte, n)
+	}
+	fb.b = fb.b[:n]
+	fb.refs = 1
+
+	return fb
+}
+
+func (fb *frameBuffer) retain() {
+	if fb != nil {
+		atomic.AddInt32(&fb.refs, 1)
+	}
+}
+
+func (fb *frameBuffer) release() {
+	if fb != nil && atomic.AddInt32(&fb.refs, -1) == 0 {
+		frameBufferPool.Put(fb)
+	}
+}
+
+// readFrameBuffer reads a single length prefixed packet from r into a pooled buffer. The buffer includes the length
+// field, just like the data returned by readRawResponse.
+func readFrameBuffer(r io.Reader) (*frameBuffer, error) {
+	fb := getFrameBuffer(4)
+	if _, err := io.ReadFull(r, fb.b); err != nil {
+		fb.release()
+		return nil, err
+	}
+
+	l := int(binary.LittleEndian.Uint32(fb.b))
+	if l < 4 {
+		fb.release()
+		return nil, ReadResponseError
+	}
+	if cap(fb.b) < l {
+		b := make([]byte, l)
+		copy(b, fb.b)
+		fb.b = b
+	}
+	fb.b = fb.b[:l]
+
+	if _, err := io.ReadFull(r, fb.b[4:]); err != nil {
+		fb.release()
+		return nil, err
+	}
+
+	return fb, nil
+}
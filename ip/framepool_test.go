@@ -0,0 +1,127 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testStreamPacket returns a length prefixed packet with a payload of n bytes.
+func testStreamPacket(n int) []byte {
+	p := make([]byte, 4+n)
+	binary.LittleEndian.PutUint32(p, uint32(len(p)))
+	for i := 4; i < len(p); i++ {
+		p[i] = byte(i)
+	}
+
+	return p
+}
+
+// repeatReader endlessly returns the same packet.
+type repeatReader struct {
+	p   []byte
+	off int
+}
+
+func (r *repeatReader) Read(b []byte) (int, error) {
+	n := copy(b, r.p[r.off:])
+	r.off = (r.off + n) % len(r.p)
+
+	return n, nil
+}
+
+func TestReadFrameBuffer(t *testing.T) {
+	want := testStreamPacket(100)
+	fb, err := readFrameBuffer(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fb.b, want) {
+		t.Errorf("readFrameBuffer() got = %#v; want %#v", fb.b, want)
+	}
+	fb.release()
+
+	if _, err := readFrameBuffer(bytes.NewReader([]byte{0x02, 0x00, 0x00, 0x00})); err != ReadResponseError {
+		t.Errorf("readFrameBuffer() error = %v; want %v", err, ReadResponseError)
+	}
+	if _, err := readFrameBuffer(bytes.NewReader(want[:50])); err == nil {
+		t.Error("readFrameBuffer() error = nil; want error")
+	}
+}
+
+func TestFrameBuffer_release(t *testing.T) {
+	fb := getFrameBuffer(10)
+	fb.retain()
+	fb.release()
+	if fb.refs != 1 {
+		t.Errorf("release() refs = %d; want 1", fb.refs)
+	}
+	fb.release()
+	if fb.refs != 0 {
+		t.Errorf("release() refs = %d; want 0", fb.refs)
+	}
+
+	// Releasing a frame that is not backed by a pooled buffer is a no-op.
+	LiveviewFrame{}.Release()
+}
+
+func TestClient_publishBufferedFrame(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := c.SubscribeLiveview(1, DropNewest)
+	fb := getFrameBuffer(4)
+	c.publishBufferedFrame(fb, fb.b, FrameMeta{})
+	// The subscription and the most recent frame kept for snapshots hold a reference.
+	if fb.refs != 2 {
+		t.Errorf("publishBufferedFrame() refs = %d; want 2", fb.refs)
+	}
+
+	f := <-sub.Frames()
+	f.Release()
+	if fb.refs != 1 {
+		t.Errorf("Release() refs = %d; want 1", fb.refs)
+	}
+
+	c.closeFrameSubscriptions()
+	if fb.refs != 0 {
+		t.Errorf("closeFrameSubscriptions() refs = %d; want 0", fb.refs)
+	}
+}
+
+// benchmarkStream simulates reading 30 frames, so one second of liveview at 30 fps, of 500KB each.
+func benchmarkStream(b *testing.B, read func(r *repeatReader) error) {
+	r := &repeatReader{p: testStreamPacket(500 * 1024)}
+	b.SetBytes(int64(len(r.p)) * 30)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for f := 0; f < 30; f++ {
+			if err := read(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkReadRawResponse(b *testing.B) {
+	c := &Client{}
+	benchmarkStream(b, func(r *repeatReader) error {
+		_, err := c.readRawResponse(r)
+		return err
+	})
+}
+
+func BenchmarkReadFrameBuffer(b *testing.B) {
+	benchmarkStream(b, func(r *repeatReader) error {
+		fb, err := readFrameBuffer(r)
+		if err != nil {
+			return err
+		}
+		fb.release()
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package ip
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// InitiatorFilter is the filtering mechanism a Responder, such as a virtual camera or a bridge, can use to deny
+// certain Initiators as described for the InitCommandRequestPacket. Initiators are allowed by GUID or by friendly name.
+// An empty filter allows any Initiator. An InitiatorFilter is safe for concurrent use so that the allow list can be
+// changed while connections are being accepted.
+type InitiatorFilter struct {
+	mu    sync.RWMutex
+	guids map[uuid.UUID]bool
+	names map[string]bool
+}
+
+// NewInitiatorFilter creates a new InitiatorFilter allowing the given Initiators. Each entry that is a valid GUID is
+// matched against the Initiator GUID, all other entries are matched against the Initiator friendly name.
+func NewInitiatorFilter(allowed ...string) *InitiatorFilter {
+	f := &InitiatorFilter{
+		guids: make(map[uuid.UUID]bool),
+		names: make(map[string]bool),
+	}
+
+	for _, a := range allowed {
+		if id, err := uuid.Parse(a); err == nil {
+			f.AllowGUID(id)
+			continue
+		}
+		f.AllowFriendlyName(a)
+	}
+
+	return f
+}
+
+// AllowGUID adds the Initiator with the given GUID to the allow list.
+func (f *InitiatorFilter) AllowGUID(id uuid.UUID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.guids[id] = true
+}
+
+// AllowFriendlyName adds the Initiator with the given friendly name to the allow list.
+func (f *InitiatorFilter) AllowFriendlyName(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.names[name] = true
+}
+
+// Allowed returns true when the Initiator identified by the given GUID or friendly name is on the allow list or when
+// the allow list is empty.
+func (f *InitiatorFilter) Allowed(id uuid.UUID, name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if len(f.guids) == 0 && len(f.names) == 0 {
+		return true
+	}
+
+	return f.guids[id] || f.names[name]
+}
+
+// Check returns the InitFailPacket the Responder must send in reply to the given InitCommandRequestPacket when the
+// Initiator is not allowed. Nil is returned when the Initiator is allowed. After sending the InitFailPacket, the
+// Responder SHALL close the connection.
+func (f *InitiatorFilter) Check(icrp InitCommandRequestPacket) *InitFailPacket {
+	if f.Allowed(icrp.GetGUID(), icrp.GetFriendlyName()) {
+		return nil
+	}
+
+	return &InitFailPacket{
+		Reason: FR_FailRejectedInitiator,
+	}
+}
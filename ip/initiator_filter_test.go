@@ -0,0 +1,125 @@
+package ip
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestInitiatorFilter_Allowed(t *testing.T) {
+	id, _ := uuid.Parse("67bace55-e7a4-4fbc-8e31-5122ee73a17c")
+	other, _ := uuid.Parse(MockResponderGUID)
+
+	check := []struct {
+		allowed []string
+		id      uuid.UUID
+		name    string
+		want    bool
+	}{
+		{nil, other, "anyone", true},
+		{[]string{"testèr"}, other, "testèr", true},
+		{[]string{"testèr"}, other, "tester", false},
+		{[]string{id.String()}, id, "anyone", true},
+		{[]string{id.String()}, other, "anyone", false},
+		{[]string{id.String(), "testèr"}, other, "testèr", true},
+	}
+
+	for _, tt := range check {
+		f := NewInitiatorFilter(tt.allowed...)
+		if got := f.Allowed(tt.id, tt.name); got != tt.want {
+			t.Errorf("Allowed() %v %s got = %t; want %t", tt.allowed, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInitiatorFilter_Check(t *testing.T) {
+	f := NewInitiatorFilter("testèr")
+
+	if got := f.Check(&GenericInitCommandRequestPacket{FriendlyName: "testèr"}); got != nil {
+		t.Errorf("Check() got = %v; want <nil>", got)
+	}
+
+	got := f.Check(NewFujiInitCommandRequestPacket(uuid.New(), "tester"))
+	if got == nil || got.Reason != FR_FailRejectedInitiator {
+		t.Errorf("Check() got = %v; want %v", got, &InitFailPacket{Reason: FR_FailRejectedInitiator})
+	}
+}
+
+func TestInitiatorFilter_dial(t *testing.T) {
+	ln, err := net.Listen("tcp", address+":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f := NewInitiatorFilter("67bace55-e7a4-4fbc-8e31-5122ee73a17c")
+	go func() {
+		evtChan := make(chan uint32, 10)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handleFilteredGenericMessages(f)(conn, evtChan, "[Mocked filtering responder]")
+		}
+	}()
+
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+	check := []struct {
+		guid string
+		want bool
+	}{
+		{"67bace55-e7a4-4fbc-8e31-5122ee73a17c", true},
+		{"", false},
+	}
+
+	for _, tt := range check {
+		c, err := NewClient(DefaultVendor, address, port, "testèr", tt.guid, logLevel)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.Dial()
+		var ife *InitFailError
+		if tt.want && err != nil {
+			t.Errorf("Dial() error = %s; want <nil>", err)
+		}
+		if !tt.want && (!errors.As(err, &ife) || ife.Reason != FR_FailRejectedInitiator) {
+			t.Errorf("Dial() error = %v; want %s", err, (&InitFailPacket{Reason: FR_FailRejectedInitiator}).ReasonAsError())
+		}
+		c.Close()
+	}
+}
+
+// handleFilteredGenericMessages returns a message handler that applies the filter to the InitCommandRequestPacket
+// before handling the messages as a generic responder would.
+func handleFilteredGenericMessages(f *InitiatorFilter) msgHandler {
+	return func(conn net.Conn, evtChan chan uint32, lmp string) {
+		h, pkt, err := readMessage(conn, lmp)
+		if err != nil {
+			conn.Close()
+			return
+		}
+
+		var res PacketIn
+		switch h.PacketType {
+		case PKT_InitCommandRequest:
+			if fail := f.Check(pkt.(InitCommandRequestPacket)); fail != nil {
+				// TCP connections are closed by the Responder on failure!
+				sendMessage(conn, fail, nil, lmp)
+				conn.Close()
+				return
+			}
+			_, res = genericInitCommandRequestResponse(lmp, PV_VersionOnePointZero)
+		case PKT_InitEventRequest:
+			_, res = genericInitEventRequestResponse()
+		}
+		if res != nil {
+			sendMessage(conn, res, nil, lmp)
+		}
+
+		handleGenericMessages(conn, evtChan, lmp)
+	}
+}
@@ -5,54 +5,370 @@ import (
 	"encoding/binary"
 	"github.com/malc0mn/ptp-ip/ptp"
 	"io"
+	"math"
 	"net"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 	"unicode/utf16"
 )
 
-func marshal(s interface{}, bo binary.ByteOrder, b *bytes.Buffer) {
-	// binary.Write() can only cope with fixed length values so we'll need to handle anything else ourselves.
-	if _, hasSession := s.(ptp.Session); binary.Size(s) < 0 || hasSession {
-		v := reflect.Indirect(reflect.ValueOf(s))
+// fieldKind determines how a single field of a struct is put on and taken off the wire.
+type fieldKind uint8
 
-		for i := 0; i < v.NumField(); i++ {
-			// When a dataset has a SessionID, we must skip sending it according to the PTP/IP protocol.
-			if v.Type().Field(i).Name == "SessionID" {
+const (
+	// kindNumber is a boolean, integer or floating point field of a fixed size.
+	kindNumber fieldKind = iota
+	// kindBytes is a byte array or, when marshalling only, a byte slice.
+	kindBytes
+	// kindString is a null terminated UTF-16 string.
+	kindString
+	// kindUint32Slice is a slice of 32 bit values taking up the data that is left when unmarshalling.
+	kindUint32Slice
+	// kindOther is any other field, left to the encoding/binary package.
+	kindOther
+)
+
+// fieldOp describes how to marshal or unmarshal a single field of a struct. Fields of embedded and nested structs are
+// flattened, the index holding the path to the field.
+type fieldOp struct {
+	index []int
+	kind  fieldKind
+	rk    reflect.Kind
+	size  int
+}
+
+// fieldPlan holds the operations to marshal and unmarshal a struct type, so that the struct's fields only need to be
+// inspected once instead of for every packet sent or received.
+type fieldPlan struct {
+	marshal   []fieldOp
+	unmarshal []fieldOp
+	fixedSize int
+	// addressable is true when marshalling needs the struct to be addressable, which is the case for byte arrays and
+	// fields left to the encoding/binary package.
+	addressable bool
+}
+
+var (
+	// fieldPlans caches a *fieldPlan per struct type.
+	fieldPlans  sync.Map
+	sessionType = reflect.TypeOf((*ptp.Session)(nil)).Elem()
+	// scratchPool holds the buffers used to read fields before decoding them.
+	scratchPool = sync.Pool{
+		New: func() interface{} {
+			return &scratch{}
+		},
+	}
+)
+
+// scratch is a reusable buffer to read fields into, large enough to hold any number field.
+type scratch struct {
+	b []byte
+}
+
+func (s *scratch) get(n int) []byte {
+	if cap(s.b) < n {
+		s.b = make([]byte, n)
+	}
+
+	return s.b[:n]
+}
+
+// planFor returns the cached field plan for the struct type t, building it on first use.
+func planFor(t reflect.Type) *fieldPlan {
+	if fp, ok := fieldPlans.Load(t); ok {
+		return fp.(*fieldPlan)
+	}
+
+	fp := &fieldPlan{fixedSize: totalSizeOfFixedFields(reflect.New(t).Interface())}
+	fp.marshal = appendMarshalOps(nil, t, nil)
+	fp.unmarshal = appendUnmarshalOps(nil, t, nil)
+	for _, op := range fp.marshal {
+		if op.kind == kindOther || op.kind == kindBytes && op.size > 0 {
+			fp.addressable = true
+		}
+	}
+	actual, _ := fieldPlans.LoadOrStore(t, fp)
+
+	return actual.(*fieldPlan)
+}
+
+// fieldIndex returns a new index for field i of the struct found at index.
+func fieldIndex(index []int, i int) []int {
+	return append(append(make([]int, 0, len(index)+1), index...), i)
+}
+
+// numberSize returns the size of a number field on the wire, 0 when the kind is not a number.
+func numberSize(k reflect.Kind) int {
+	switch k {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return 8
+	}
+
+	return 0
+}
+
+// appendMarshalOps appends the operations to marshal the struct type t. binary.Write() can only cope with fixed length
+// values, so structs holding anything else are marshalled field by field, just like structs holding a SessionID.
+func appendMarshalOps(ops []fieldOp, t reflect.Type, index []int) []fieldOp {
+	fixed := binary.Size(reflect.New(t).Interface()) >= 0 && !reflect.PtrTo(t).Implements(sessionType)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		// When a dataset has a SessionID, we must skip sending it according to the PTP/IP protocol.
+		if !fixed && f.Name == "SessionID" {
+			continue
+		}
+
+		ops = appendFieldOp(ops, f.Type, fieldIndex(index, i), true)
+	}
+
+	return ops
+}
+
+// appendUnmarshalOps appends the operations to unmarshal the struct type t. Slices that are not made up of 32 bit values
+// are left to the caller.
+func appendUnmarshalOps(ops []fieldOp, t reflect.Type, index []int) []fieldOp {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		// When a dataset has a SessionID, we must skip it since the PTP/IP protocol does not send it.
+		if f.Name == "SessionID" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() != reflect.Uint32 {
+			continue
+		}
+
+		ops = appendFieldOp(ops, f.Type, fieldIndex(index, i), false)
+	}
+
+	return ops
+}
+
+func appendFieldOp(ops []fieldOp, t reflect.Type, index []int, marshal bool) []fieldOp {
+	k := t.Kind()
+	switch {
+	case k == reflect.Struct && marshal:
+		return appendMarshalOps(ops, t, index)
+	case k == reflect.Struct:
+		return appendUnmarshalOps(ops, t, index)
+	case numberSize(k) > 0:
+		return append(ops, fieldOp{index: index, kind: kindNumber, rk: k, size: numberSize(k)})
+	case k == reflect.String:
+		return append(ops, fieldOp{index: index, kind: kindString})
+	case k == reflect.Array && t.Elem().Kind() == reflect.Uint8:
+		return append(ops, fieldOp{index: index, kind: kindBytes, size: t.Len()})
+	case k == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && marshal:
+		return append(ops, fieldOp{index: index, kind: kindBytes})
+	case k == reflect.Slice && t.Elem().Kind() == reflect.Uint32:
+		return append(ops, fieldOp{index: index, kind: kindUint32Slice})
+	}
+
+	return append(ops, fieldOp{index: index, kind: kindOther})
+}
+
+// marshalledSize returns the size of the struct v on the wire, or an upper bound thereof when it holds strings, so that
+// the buffer to marshal to can be allocated at once.
+func (fp *fieldPlan) marshalledSize(v reflect.Value) int {
+	n := 0
+	for _, op := range fp.marshal {
+		switch op.kind {
+		case kindNumber, kindBytes:
+			if op.size > 0 {
+				n += op.size
 				continue
 			}
+			n += v.FieldByIndex(op.index).Len()
+		case kindString:
+			// A UTF-16 string never holds more characters than the number of bytes of its UTF-8 encoding.
+			n += v.FieldByIndex(op.index).Len()*2 + 2
+		case kindUint32Slice:
+			n += v.FieldByIndex(op.index).Len() * 4
+		default:
+			if s := binary.Size(v.FieldByIndex(op.index).Addr().Interface()); s > 0 {
+				n += s
+			}
+		}
+	}
+
+	return n
+}
 
-			f := v.Field(i)
-			switch f.Kind() {
-			case reflect.Struct:
-				marshal(f.Addr().Interface(), bo, b)
-			case reflect.String:
-				// TODO: the PTP protocol sets a limit of 255 characters per string including the terminating null
-				//  character. We must still enforce this limit here.
-				// A rune in Go is an alias for uint32 but the PTP protocol expects 2 byte Unicode characters according
-				// to the ISO10646 standard, so we convert them to utf16 (which is uint16) here.
-				binary.Write(b, bo, utf16.Encode([]rune(f.String())))
-				// Strings must be null terminated.
-				binary.Write(b, bo, uint16(0))
-			default:
-				binary.Write(b, bo, f.Addr().Interface())
+// appendNumber appends the number field f to b.
+func appendNumber(b []byte, f reflect.Value, op fieldOp, bo binary.AppendByteOrder) []byte {
+	var u uint64
+	switch op.rk {
+	case reflect.Bool:
+		if f.Bool() {
+			u = 1
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		u = uint64(f.Int())
+	case reflect.Float32:
+		u = uint64(math.Float32bits(float32(f.Float())))
+	case reflect.Float64:
+		u = math.Float64bits(f.Float())
+	default:
+		u = f.Uint()
+	}
+
+	switch op.size {
+	case 1:
+		return append(b, byte(u))
+	case 2:
+		return bo.AppendUint16(b, uint16(u))
+	case 4:
+		return bo.AppendUint32(b, uint32(u))
+	}
+
+	return bo.AppendUint64(b, u)
+}
+
+// setNumber decodes the number field f from b.
+func setNumber(f reflect.Value, b []byte, op fieldOp, bo binary.ByteOrder) {
+	var u uint64
+	switch op.size {
+	case 1:
+		u = uint64(b[0])
+	case 2:
+		u = uint64(bo.Uint16(b))
+	case 4:
+		u = uint64(bo.Uint32(b))
+	default:
+		u = bo.Uint64(b)
+	}
+
+	switch op.rk {
+	case reflect.Bool:
+		f.SetBool(u != 0)
+	case reflect.Int8:
+		f.SetInt(int64(int8(u)))
+	case reflect.Int16:
+		f.SetInt(int64(int16(u)))
+	case reflect.Int32:
+		f.SetInt(int64(int32(u)))
+	case reflect.Int64:
+		f.SetInt(int64(u))
+	case reflect.Float32:
+		f.SetFloat(float64(math.Float32frombits(uint32(u))))
+	case reflect.Float64:
+		f.SetFloat(math.Float64frombits(u))
+	default:
+		f.SetUint(u)
+	}
+}
+
+// appendString appends s as a null terminated string of 2 byte Unicode characters according to the ISO10646 standard.
+// A rune in Go is an alias for uint32, so the string is converted to UTF-16 here.
+// TODO: the PTP protocol sets a limit of 255 characters per string including the terminating null character. We must
+// still enforce this limit here.
+func appendString(b []byte, s string, bo binary.AppendByteOrder) []byte {
+	for _, r := range s {
+		if r1, r2 := utf16.EncodeRune(r); r1 != unicode.ReplacementChar {
+			b = bo.AppendUint16(b, uint16(r1))
+			b = bo.AppendUint16(b, uint16(r2))
+			continue
+		}
+		if r > 0xffff || r >= 0xd800 && r < 0xe000 {
+			r = unicode.ReplacementChar
+		}
+		b = bo.AppendUint16(b, uint16(r))
+	}
+
+	// Strings must be null terminated.
+	return bo.AppendUint16(b, 0)
+}
+
+// decodeString converts the 2 byte Unicode characters in b to a string, dropping the null terminator.
+func decodeString(b []byte, bo binary.ByteOrder) string {
+	n := len(b) / 2
+	if n > 0 {
+		n--
+	}
+
+	var sb strings.Builder
+	sb.Grow(n)
+	for i := 0; i < n; i++ {
+		r := rune(bo.Uint16(b[i*2:]))
+		if utf16.IsSurrogate(r) && i+1 < n {
+			if dr := utf16.DecodeRune(r, rune(bo.Uint16(b[(i+1)*2:]))); dr != unicode.ReplacementChar {
+				sb.WriteRune(dr)
+				i++
+				continue
+			}
+		}
+		if utf16.IsSurrogate(r) {
+			r = unicode.ReplacementChar
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func appendMarshal(b []byte, s interface{}, bo binary.AppendByteOrder) []byte {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		var buf bytes.Buffer
+		binary.Write(&buf, bo.(binary.ByteOrder), s)
+		return append(b, buf.Bytes()...)
+	}
+	fp := planFor(v.Type())
+	if fp.addressable && !v.CanAddr() {
+		av := reflect.New(v.Type()).Elem()
+		av.Set(v)
+		v = av
+	}
+	if b == nil {
+		b = make([]byte, 0, fp.marshalledSize(v))
+	}
+
+	for _, op := range fp.marshal {
+		f := v.FieldByIndex(op.index)
+		switch op.kind {
+		case kindNumber:
+			b = appendNumber(b, f, op, bo)
+		case kindBytes:
+			if f.Kind() == reflect.Array {
+				f = f.Slice(0, op.size)
 			}
+			b = append(b, f.Bytes()...)
+		case kindString:
+			b = appendString(b, f.String(), bo)
+		case kindUint32Slice:
+			for i := 0; i < f.Len(); i++ {
+				b = bo.AppendUint32(b, uint32(f.Index(i).Uint()))
+			}
+		default:
+			var buf bytes.Buffer
+			binary.Write(&buf, bo.(binary.ByteOrder), f.Addr().Interface())
+			b = append(b, buf.Bytes()...)
 		}
-	} else {
-		binary.Write(b, bo, s)
 	}
+
+	return b
 }
 
 // Marshal data to a byte array, Little Endian formant, for transport.
 func MarshalLittleEndian(s interface{}) []byte {
-	var b bytes.Buffer
-	marshal(s, binary.LittleEndian, &b)
+	return appendMarshal(nil, s, binary.LittleEndian)
+}
 
-	return b.Bytes()
+// AppendLittleEndian marshals data, Little Endian format, appending it to b. This allows the caller to reuse a buffer
+// when sending packets.
+func AppendLittleEndian(b []byte, s interface{}) []byte {
+	return appendMarshal(b, s, binary.LittleEndian)
 }
 
-// We always read using reflection to fill each field of s as we go along. This way, we can fill structs like the
+// We always read field by field to fill each field of s as we go along. This way, we can fill structs like the
 // ptp.OperationResponsePacket which does not necessarily receive all parameter fields 'over the wire'. According to the
 // protocol we should, but unfortunately it depends on the vendor's implementation. So we need to make sure this
 // unmarshal function is usable by all future implementations.
@@ -60,31 +376,47 @@ func MarshalLittleEndian(s interface{}) []byte {
 // caller to handle it.
 func unmarshal(r io.Reader, s interface{}, l int, vs int, bo binary.ByteOrder) (int, error) {
 	v := reflect.Indirect(reflect.ValueOf(s))
+	sc := scratchPool.Get().(*scratch)
+	defer scratchPool.Put(sc)
 
-	for i := 0; i < v.NumField(); i++ {
-		// When a dataset has a SessionID, we must skip it since the PTP/IP protocol does not send it.
-		if v.Type().Field(i).Name == "SessionID" {
-			continue
-		}
-
-		f := v.Field(i)
-		switch f.Kind() {
-		case reflect.Struct:
-			var err error
-			l, err = unmarshal(r, f.Addr().Interface(), l, vs, bo)
-			if err != nil {
+	for _, op := range planFor(v.Type()).unmarshal {
+		f := v.FieldByIndex(op.index)
+		switch op.kind {
+		case kindNumber:
+			b := sc.get(op.size)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return 0, err
+			}
+			setNumber(f, b, op, bo)
+			l -= op.size
+		case kindBytes:
+			if _, err := io.ReadFull(r, f.Slice(0, op.size).Bytes()); err != nil {
 				return 0, err
 			}
-		case reflect.String:
+			l -= op.size
+		case kindString:
 			// The PTP protocol expects 2 byte Unicode characters according to the ISO10646 standard, so we convert
 			// them to string here.
-			b := make([]uint16, vs / 2)
-			if err := binary.Read(r, bo, b); err != nil {
+			b := sc.get(vs / 2 * 2)
+			if _, err := io.ReadFull(r, b); err != nil {
 				return 0, err
 			}
-			// The slice operation happening here is to drop the null terminator.
-			f.SetString(string(utf16.Decode(b[:len(b) - 1])))
+			f.SetString(decodeString(b, bo))
 			l -= vs
+		case kindUint32Slice:
+			// Slices of 32 bit values, such as the parameters of an operation response, take up the data that is left
+			// since the number of elements sent depends on the vendor's implementation.
+			n := l / 4
+			b := sc.get(n * 4)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return 0, err
+			}
+			e := reflect.MakeSlice(f.Type(), n, n)
+			for i := 0; i < n; i++ {
+				e.Index(i).SetUint(uint64(bo.Uint32(b[i*4:])))
+			}
+			f.Set(e)
+			l -= n * 4
 		default:
 			if err := binary.Read(r, bo, f.Addr().Interface()); err != nil {
 				return 0, err
@@ -110,32 +442,53 @@ func UnmarshalLittleEndian(r io.Reader, s interface{}, l int, vs int) ([]byte, e
 	left, err := unmarshal(r, s, l, vs, binary.LittleEndian)
 	if left > 0 {
 		xs = make([]byte, left)
-		binary.Read(r, binary.LittleEndian, &xs)
+		io.ReadFull(r, xs)
 	}
 
 	return xs, err
 }
 
+// TotalSizeOfFixedFields returns the size of all fixed size fields of s when put on the wire. The result is cached per
+// type.
 func TotalSizeOfFixedFields(s interface{}) int {
+	t := reflect.TypeOf(s)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return binary.Size(s)
+	}
+
+	return planFor(t).fixedSize
+}
+
+func totalSizeOfFixedFields(s interface{}) int {
 	tfs := binary.Size(s)
 
-	// The SessionID Field is dropped in the PTP/IP implementation.
-	if _, hasSession := s.(ptp.Session); hasSession {
-		tfs -= 4
+	if tfs >= 0 {
+		// The SessionID Field is dropped in the PTP/IP implementation.
+		if _, hasSession := s.(ptp.Session); hasSession {
+			tfs -= 4
+		}
+		return tfs
 	}
 
-	if tfs < 0 {
-		tfs = 0
-		v := reflect.Indirect(reflect.ValueOf(s))
-		for i := 0; i < v.NumField(); i++ {
-			f := v.Field(i)
-			switch f.Kind() {
-			case reflect.String:
-				// Skip string fields, we do not calculate their size.
-				continue
-			default:
-				tfs += binary.Size(f.Addr().Interface())
-			}
+	tfs = 0
+	v := reflect.Indirect(reflect.ValueOf(s))
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Name == "SessionID" {
+			continue
+		}
+
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String, reflect.Slice:
+			// Skip variable sized fields, we do not calculate their size.
+			continue
+		case reflect.Struct:
+			tfs += totalSizeOfFixedFields(f.Addr().Interface())
+		default:
+			tfs += binary.Size(f.Addr().Interface())
 		}
 	}
 
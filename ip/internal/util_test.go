@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+type testHeader struct {
+	Length     uint32
+	PacketType uint32
+}
+
+type testInitPacket struct {
+	GUID            [16]byte
+	FriendlyName    string
+	ProtocolVersion uint32
+}
+
+type testOperationRequestPacket struct {
+	DataPhaseInfo uint32
+	ptp.OperationRequest
+}
+
+type testOperationResponsePacket struct {
+	ptp.OperationResponse
+}
+
+type testEventPacket struct {
+	ptp.Event
+}
+
+type testFixedPacket struct {
+	Flag   bool
+	Signed int16
+	Ratio  float32
+	Codes  [2]uint16
+}
+
+func TestMarshalLittleEndian(t *testing.T) {
+	check := []struct {
+		s    interface{}
+		want []byte
+	}{
+		{uint32(12), []byte{0x0c, 0x00, 0x00, 0x00}},
+		{testHeader{18, 6}, []byte{0x12, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00}},
+		{
+			&testInitPacket{[16]byte{0xff, 0x01}, "tè\U0001F4F7", 0x00010000},
+			[]byte{
+				0xff, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x74, 0x00, 0xe8, 0x00, 0x3d, 0xd8, 0xf7, 0xdc, 0x00, 0x00,
+				0x00, 0x00, 0x01, 0x00,
+			},
+		},
+		{
+			&testOperationRequestPacket{1, ptp.OperationRequest{
+				OperationCode: ptp.OC_GetObjectHandles,
+				SessionID:     0xffffffff,
+				TransactionID: 3,
+				Parameters:    []uint32{0xffffffff, 0x3801},
+			}},
+			[]byte{
+				0x01, 0x00, 0x00, 0x00, 0x07, 0x10, 0x03, 0x00, 0x00, 0x00,
+				0xff, 0xff, 0xff, 0xff, 0x01, 0x38, 0x00, 0x00,
+			},
+		},
+		{
+			&testFixedPacket{true, -2, 1, [2]uint16{0x1001, 0x1002}},
+			[]byte{0x01, 0xfe, 0xff, 0x00, 0x00, 0x80, 0x3f, 0x01, 0x10, 0x02, 0x10},
+		},
+	}
+
+	for _, tt := range check {
+		if got := MarshalLittleEndian(tt.s); !bytes.Equal(got, tt.want) {
+			t.Errorf("MarshalLittleEndian() %T got = %#x; want %#x", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestAppendLittleEndian(t *testing.T) {
+	got := AppendLittleEndian([]byte{0xaa}, &testHeader{8, 13})
+	want := []byte{0xaa, 0x08, 0x00, 0x00, 0x00, 0x0d, 0x00, 0x00, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("AppendLittleEndian() got = %#x; want %#x", got, want)
+	}
+}
+
+func TestUnmarshalLittleEndian(t *testing.T) {
+	raw := []byte{
+		0xff, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x74, 0x00, 0xe8, 0x00, 0x3d, 0xd8, 0xf7, 0xdc, 0x00, 0x00,
+		0x00, 0x00, 0x01, 0x00,
+	}
+	var ip testInitPacket
+	xs, err := UnmarshalLittleEndian(bytes.NewReader(raw), &ip, len(raw), len(raw)-TotalSizeOfFixedFields(&ip))
+	want := testInitPacket{[16]byte{0xff, 0x01}, "tè\U0001F4F7", 0x00010000}
+	if err != nil || xs != nil || ip != want {
+		t.Errorf("UnmarshalLittleEndian() got = %+v, %#x, %v; want %+v, <nil>, <nil>", ip, xs, err, want)
+	}
+
+	raw = []byte{0x0c, 0x20, 0x05, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xab}
+	var orp testOperationResponsePacket
+	xs, err = UnmarshalLittleEndian(bytes.NewReader(raw), &orp, len(raw), 0)
+	wantOr := ptp.OperationResponse{ResponseCode: ptp.RC_StoreFull, TransactionID: 5, Parameters: []uint32{1, 2}}
+	if err != nil || !bytes.Equal(xs, []byte{0xab}) || !reflect.DeepEqual(orp.OperationResponse, wantOr) {
+		t.Errorf("UnmarshalLittleEndian() got = %+v, %#x, %v; want %+v, 0xab, <nil>", orp, xs, err, wantOr)
+	}
+
+	raw = []byte{0x01, 0xfe, 0xff, 0x00, 0x00, 0x80, 0x3f, 0x01, 0x10, 0x02, 0x10}
+	var fp testFixedPacket
+	_, err = UnmarshalLittleEndian(bytes.NewReader(raw), &fp, len(raw), 0)
+	wantFp := testFixedPacket{true, -2, 1, [2]uint16{0x1001, 0x1002}}
+	if err != nil || fp != wantFp {
+		t.Errorf("UnmarshalLittleEndian() got = %+v, %v; want %+v, <nil>", fp, err, wantFp)
+	}
+
+	// The packet is shorter than announced.
+	_, err = UnmarshalLittleEndian(bytes.NewReader(raw[:4]), &fp, len(raw), 0)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("UnmarshalLittleEndian() error = %v; want %s", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestTotalSizeOfFixedFields(t *testing.T) {
+	check := []struct {
+		s    interface{}
+		want int
+	}{
+		{&testHeader{}, 8},
+		{&testInitPacket{}, 20},
+		{&testOperationRequestPacket{}, 10},
+		{&testEventPacket{}, 6},
+		{&testFixedPacket{}, 11},
+	}
+
+	for _, tt := range check {
+		if got := TotalSizeOfFixedFields(tt.s); got != tt.want {
+			t.Errorf("TotalSizeOfFixedFields() %T got = %d; want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkMarshalLittleEndian(b *testing.B) {
+	p := &testOperationRequestPacket{1, ptp.OperationRequest{
+		OperationCode: ptp.OC_GetDevicePropValue,
+		TransactionID: 3,
+		Parameters:    []uint32{0x5003},
+	}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MarshalLittleEndian(p)
+	}
+}
+
+func BenchmarkAppendLittleEndian(b *testing.B) {
+	p := &testOperationRequestPacket{1, ptp.OperationRequest{
+		OperationCode: ptp.OC_GetDevicePropValue,
+		TransactionID: 3,
+		Parameters:    []uint32{0x5003},
+	}}
+	buf := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = AppendLittleEndian(buf[:0], p)
+	}
+}
+
+func BenchmarkUnmarshalLittleEndian(b *testing.B) {
+	var h [8]byte
+	raw := []byte{0x0e, 0x40, 0x03, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00}
+	r := bytes.NewReader(nil)
+	hdr, ep := &testHeader{}, &testEventPacket{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		binary.LittleEndian.PutUint32(h[:], 18)
+		r.Reset(h[:])
+		UnmarshalLittleEndian(r, hdr, len(h), 0)
+
+		r.Reset(raw)
+		UnmarshalLittleEndian(r, ep, len(raw), len(raw)-TotalSizeOfFixedFields(ep))
+	}
+}
@@ -1,17 +1,16 @@
 package ip
 
 import (
-	"bytes"
+	"context"
 	"encoding/binary"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,27 +19,58 @@ import (
 )
 
 const (
-	DefaultVendor         string         = "generic"
-	DefaultDialTimeout                   = 3 * time.Second
-	DefaultReadTimeout                   = 5 * time.Second
-	DefaultPort           uint16         = 15740
-	DefaultIpAddress      string         = "192.168.0.1"
-	InitiatorFriendlyName string         = "Golang PTP/IP client"
-	cmdDataConnection     connectionType = "cmd"
-	eventConnection       connectionType = "event"
-	streamConnection      connectionType = "stream"
+	DefaultVendor              string         = "generic"
+	AutoVendor                 string         = "auto"
+	DefaultDialTimeout                        = 3 * time.Second
+	DefaultReadTimeout                        = 5 * time.Second
+	DefaultPollInterval                       = 500 * time.Millisecond
+	DefaultStreamBackoff                      = 250 * time.Millisecond
+	DefaultChangeClientTimeout                = 30 * time.Second
+	DefaultDataChunkSize                      = 1 << 20
+	MaxStreamBackoff                          = 30 * time.Second
+	DefaultPort                uint16         = 15740
+	DefaultIpAddress           string         = "192.168.0.1"
+	InitiatorFriendlyName      string         = "Golang PTP/IP client"
+	cmdDataConnection          connectionType = "cmd"
+	eventConnection            connectionType = "event"
+	streamConnection           connectionType = "stream"
 )
 
 var (
-	BytesWrittenMismatch = "bytes written mismatch: written %d wanted %d"
-	ConnectionLostError  = errors.New("connection lost")
-	ReadResponseError    = errors.New("unable to read response packet")
-	WaitForResponseError = errors.New("timeout reached when waiting for response")
-	WaitForEventError    = errors.New("timeout reached when waiting for event")
-	InvalidPacketError   = errors.New("invalid packet")
-	NotConnectedError    = errors.New("not connected")
+	BytesWrittenMismatch   = "bytes written mismatch: written %d wanted %d"
+	ConnectionLostError    = errors.New("connection lost")
+	ReadResponseError      = errors.New("unable to read response packet")
+	WaitForResponseError   = errors.New("timeout reached when waiting for response")
+	WaitForEventError      = errors.New("timeout reached when waiting for event")
+	TransactionCancelled   = errors.New("transaction cancelled by responder")
+	InvalidPacketError     = errors.New("invalid packet")
+	NotConnectedError      = errors.New("not connected")
+	NoNativeZoomError      = errors.New("native liveview zoom not supported")
+	NoLiveviewFrameError   = errors.New("no liveview frame received")
+	TooManyParametersError = errors.New("too many operation parameters")
+	DataPhaseMismatchError = errors.New("the data phase does not match the operation")
+	TruncatedDataError     = errors.New("data phase shorter than announced")
 )
 
+// CaptureError is returned by InitiateCapture when the capture fails. Released indicates whether the shutter had been
+// released when the failure occurred, in which case the image has most likely been taken but could not be retrieved.
+type CaptureError struct {
+	Released bool
+	Err      error
+}
+
+func (e *CaptureError) Error() string {
+	if e.Released {
+		return "capture failed after releasing the shutter: " + e.Err.Error()
+	}
+
+	return "capture failed: " + e.Err.Error()
+}
+
+func (e *CaptureError) Unwrap() error {
+	return e.Err
+}
+
 type connectionType string
 
 // Initiator holds the identity of "ourselves".
@@ -152,30 +182,92 @@ func NewResponder(vendor string, ip string, cport uint16, eport uint16, sport ui
 //   - the command/data channel connection
 //   - the event channel connection
 //   - the streamer channel connection
+//   - the readers the packets are read from and the options of each connection
 //   - the initiator info, i.e. us
 //   - the responder info, i.e. camera
 //   - the loaded vendor extensions
+//   - the application version reported to vendors requiring one
+//   - the size of the chunks the data-out phase is split into
+//   - the number of transactions kept in flight by the bulk methods
+//   - the session ID and the IDs of the other sessions opened with the responder
+//   - whether the vendor of the responder must be detected when dialing
+//   - the number of times the connections have been closed
+//   - a wait group tracking the listeners on the command/data and event connections and the event poller
+//   - the transaction timeout after which the reaper ends transactions the Responder did not answer
 //   - an async event channel receiving events from the Responder's event connection
+//   - an async channel receiving the object handles the Responder requests us to transfer
+//   - an async channel receiving the refreshed device info when the Responder reports its capabilities have changed
+//   - the last known device info and device property descriptions
+//   - a channel to request the event listener to stop, as well as the event poller for vendors that do not report all
+//     events on the event connection
+//   - the event handlers receiving a copy of each event
+//   - whether the event listener is running, when it last received a packet and when the last probe was answered
 //   - an async streamer channel receiving raw image data from the Responder's streaming connection if there is one
+//   - an async channel reporting the streamer connection being lost and restored
 //   - a channel to request the streamer to close down
+//   - a channel to request the liveview poller to stop for vendors delivering frames over the command/data connection
+//   - the liveview frame broadcaster feeding the registered frame callbacks and subscriptions
+//   - the recorder writing the exchanged packets when recording a test fixture
+//   - the taps handing the received packets to SendRawPacket when raw packets are enabled
 //   - a logger
 type Client struct {
-	connectionNumber uint32
-	transactionId    ptp.TransactionID
-	transactionIdMu  sync.Mutex
-	CommandDataConn  net.Conn
-	eventConn        net.Conn
-	streamConn       net.Conn
-	initiator        *Initiator
-	responder        *Responder
-	vendorExtensions *VendorExtensions
-	cmdDataChan      chan []byte
-	cmdDataSubs      map[ptp.TransactionID]chan<- []byte
-	cmdDataSubsMu    sync.Mutex
-	EventChan        chan EventPacket
-	EventPayloadChan chan EventParameters
-	StreamChan       chan []byte
-	closeStreamChan  chan struct{}
+	connectionNumber   uint32
+	transactionId      ptp.TransactionID
+	transactionIdMu    sync.Mutex
+	CommandDataConn    net.Conn
+	eventConn          net.Conn
+	streamConn         net.Conn
+	cmdDataReader      io.Reader
+	eventReader        io.Reader
+	streamReader       io.Reader
+	connOptions        connOptions
+	initiator          *Initiator
+	responder          *Responder
+	vendorExtensions   *VendorExtensions
+	appVersion         uint32
+	dataChunkSize      int
+	pipelineDepth      int
+	transferLimit      *rateLimiter
+	sessionId          ptp.SessionID
+	sessionIds         *sessionIds
+	detectVendor       bool
+	cmdDataChan        chan []byte
+	cmdDataSubs        map[ptp.TransactionID]*subscription
+	cmdDataSubsMu      sync.Mutex
+	cmdDataSubsEnded   sync.Cond
+	closeMu            sync.Mutex
+	closeCount         atomic.Uint64
+	transactionTimeout time.Duration
+	probeOnTimeout     bool
+	closeReaper        chan struct{}
+	reaped             reapedTransactions
+	listeners          sync.WaitGroup
+	EventChan          chan EventPacket
+	EventPayloadChan   chan EventParameters
+	ObjectTransferChan chan ptp.ObjectHandle
+	DeviceInfoChan     chan interface{}
+	deviceInfo         interface{}
+	propDescs          map[ptp.DevicePropCode]*ptp.DevicePropDesc
+	cacheMu            sync.Mutex
+	closeEventPoll     chan struct{}
+	probeResponses     chan struct{}
+	eventListening     atomic.Bool
+	lastEventPacket    atomic.Int64
+	lastProbe          atomic.Int64
+	eventHandlers      []*eventHandler
+	eventHandlersMu    sync.Mutex
+	StreamChan         chan []byte
+	StreamEventChan    chan StreamEvent
+	closeStreamChan    chan struct{}
+	streamBackoff      time.Duration
+	streamMu           sync.Mutex
+	closeLiveviewPoll  chan struct{}
+	liveviewPollDone   chan struct{}
+	frames             *frameBroadcaster
+	tracer             Tracer
+	txSpans            transactionSpans
+	recorder           *exchangeRecorder
+	rawTaps            map[connectionType]*rawTap
 	Logger
 }
 
@@ -204,6 +296,13 @@ func (c *Client) incrementTransactionId() ptp.TransactionID {
 	return tid
 }
 
+// resetTransactionId resets the transaction ID for a new connection.
+func (c *Client) resetTransactionId() {
+	c.transactionIdMu.Lock()
+	c.transactionId = 0
+	c.transactionIdMu.Unlock()
+}
+
 // Network returns a fixed value: "tcp".
 func (c *Client) Network() string {
 	return c.responder.Network()
@@ -234,6 +333,18 @@ func (c *Client) InitiatorFriendlyName() string {
 	return c.initiator.FriendlyName
 }
 
+// EventListenerRunning returns true while the event listener is receiving packets from the event connection. It stops
+// when the event connection is lost or closed.
+func (c *Client) EventListenerRunning() bool {
+	return c.eventListening.Load()
+}
+
+// LastEventPacket returns the time the last packet was received on the event connection, probe requests and responses
+// included, or the zero time when none was received yet.
+func (c *Client) LastEventPacket() time.Time {
+	return unixNanoTime(c.lastEventPacket.Load())
+}
+
 // ResponderVendor returns the vendor code from the responder.
 func (c *Client) ResponderVendor() ptp.VendorExtension {
 	return c.responder.Vendor
@@ -274,14 +385,38 @@ func (c *Client) SetStreamerPort(port uint16) {
 	c.responder.StreamerPort = port
 }
 
-// SetLogger allows setting a custom logger. This defaults to the Go log package.
+// SetAppVersion sets the application version reported to the Responder when the vendor requires one, which is only the
+// case for Fuji. The Responder refuses the connection when it requires a newer version. By default, the version the
+// Responder requires is reported.
+func (c *Client) SetAppVersion(v uint32) {
+	c.appVersion = v
+}
+
+// SetLogger allows setting a custom logger. This defaults to the Go log package. The log entries of the client are
+// tagged with the vendor of the responder and, where applicable, the channel and the transaction ID they relate to. Use a
+// StructuredLogger to receive these as fields.
 func (c *Client) SetLogger(log Logger) {
-	c.Logger = log
+	c.Logger = WithFields(log, Field{FieldVendor, c.ResponderVendor().String()})
+}
+
+// channelLogger returns the client logger tagging log entries with the given channel and any additional fields.
+func (c *Client) channelLogger(ct connectionType, fields ...Field) Logger {
+	return WithFields(c.Logger, append([]Field{{FieldChannel, ct}}, fields...)...)
+}
+
+// transactionLogger returns the client logger tagging log entries with the given channel and transaction ID.
+func (c *Client) transactionLogger(ct connectionType, tid ptp.TransactionID) Logger {
+	return c.channelLogger(ct, Field{FieldTransactionId, tid})
 }
 
 // Dial will initialise the command/data and Event connections.
-func (c *Client) Dial() error {
-	var err error
+func (c *Client) Dial() (err error) {
+	end := c.startSpan(SpanDial)
+	defer func() { end(err) }()
+
+	if c.detectVendor {
+		return c.dialAndDetectVendor()
+	}
 
 	err = c.initCommandDataConn()
 	if err != nil {
@@ -314,8 +449,78 @@ func (c *Client) DialWithStreamer() error {
 	return nil
 }
 
-// Close closes all open connections for the client.
+// ChangeClient dials the Responder when it refuses to accept the Initiator because it is paired with another
+// Initiator, waiting at most timeout for the user to allow the change on the Responder. Call it instead of Dial.
+func (c *Client) ChangeClient(timeout time.Duration) error {
+	return c.vendorExtensions.changeClient(c, timeout)
+}
+
+// ResetConnection closes the session and all connections with the Responder and sets them up again, including the
+// streamer connection when it was open. Use it to recover when the Responder stops responding to operation requests.
+func (c *Client) ResetConnection() error {
+	return c.vendorExtensions.resetConnection(c)
+}
+
+// resetConnection tears down the session and all connections with the Responder and calls dial to set them up again.
+// The cached device info and device property descriptions are discarded since the Responder might have been
+// reconfigured in the meantime.
+func (c *Client) resetConnection(dial func() error) error {
+	streaming := c.streamConn != nil
+	if err := c.Shutdown(); err != nil {
+		return err
+	}
+	// Transactions that did not complete would clash with the transactions on the new connection.
+	c.dropSubscriptions()
+	c.resetTransactionId()
+	c.invalidateCache()
+
+	if err := dial(); err != nil {
+		return err
+	}
+	if streaming {
+		return c.initStreamConn()
+	}
+
+	return nil
+}
+
+// CloseSession closes the session with the Responder, if the vendor requires one, so that the Responder knows the
+// Initiator is going away. The connections remain open: call Close afterwards.
+func (c *Client) CloseSession() error {
+	if c.CommandDataConn == nil {
+		return NotConnectedError
+	}
+
+	return c.vendorExtensions.closeSession(c)
+}
+
+// Shutdown gracefully ends the connection with the Responder: the session is closed first, including any vendor
+// specific teardown, so that the Responder does not hold on to a stale session which would block reconnecting. All
+// connections are closed afterwards, even when closing the session failed. Shutdown returns once the listeners have
+// stopped, EventPayloadChan being closed by then.
+func (c *Client) Shutdown() error {
+	// The response listener closes the client when the connection drops, see closeLostConnection.
+	c.closeMu.Lock()
+	connected := c.CommandDataConn != nil
+	c.closeMu.Unlock()
+
+	if connected {
+		if err := c.CloseSession(); err != nil {
+			c.Warnf("Unable to close the session: %s", err)
+		}
+	}
+
+	return c.Close()
+}
+
+// Close closes all open connections for the client without closing the session first. Use Shutdown to end the
+// connection gracefully.
 func (c *Client) Close() error {
+	// The response listener closes the client when the connection drops, which might coincide with closing it
+	// ourselves.
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+
 	var err error
 
 	// streamConn must be closed first so we can do it cleanly, otherwise the camera might terminate it for us causing
@@ -327,97 +532,154 @@ func (c *Client) Close() error {
 		}
 	}
 
-	// TODO: add a closeEventConn() method so we can properly shut down the event channel like we do with the streamer.
-	if c.eventConn != nil {
-		err = c.closeEventConn()
-		if err != nil {
-			return err
-		}
+	// The liveview poller uses the command/data connection so it must be stopped before closing that connection.
+	c.stopLiveviewPoller()
+	c.stopReaper()
+	c.closeCount.Add(1)
+
+	// The listeners read the connections and send to the event channels, so the sockets are closed first to make them
+	// stop. The connections and the channels are only cleared once they have.
+	events := c.eventConn != nil
+	if events {
+		c.stopEventListener()
+		err = c.eventConn.Close()
 	}
-
-	// if c.eventConn != nil {
-	// 	err = c.eventConn.Close()
-	// 	c.eventConn = nil
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// }
-
 	if c.CommandDataConn != nil {
-		err = c.CommandDataConn.Close()
-		c.CommandDataConn = nil
-		if err != nil {
-			return err
+		// No responses will be received for the transactions still in flight.
+		c.endTransactionSpans(NotConnectedError)
+
+		if cerr := c.CommandDataConn.Close(); err == nil {
+			err = cerr
 		}
 	}
+	c.listeners.Wait()
 
-	return nil
+	if events {
+		c.closeEventConn()
+	}
+	c.CommandDataConn = nil
+
+	return err
+}
+
+// closeLostConnection closes the client after the response listener lost the command/data connection, unless the
+// connections have been closed since the listener started, as counted by closeCount. Close waits for the listeners to
+// stop, so the response listener runs this in a goroutine of its own.
+func (c *Client) closeLostConnection(count uint64) {
+	c.closeMu.Lock()
+	lost := c.closeCount.Load() == count
+	c.closeMu.Unlock()
+
+	if lost {
+		c.Close()
+	}
 }
 
 // SendPacketToCmdDataConn sends a packet to the command/data connection.
 func (c *Client) SendPacketToCmdDataConn(p PacketOut) error {
-	return c.sendPacket(c.CommandDataConn, p)
+	err := c.sendPacket(cmdDataConnection, c.CommandDataConn, p)
+	if tid, ok := packetTransactionId(p); ok && err != nil {
+		c.endTransactionSpan(tid, err)
+	}
+
+	return err
 }
 
 // SendPacketToEventConn sends a packet to the Event connection.
 func (c *Client) SendPacketToEventConn(p PacketOut) error {
-	return c.sendPacket(c.eventConn, p)
+	return c.sendPacket(eventConnection, c.eventConn, p)
+}
+
+// sendBufferPool holds the buffers used to send the header of outgoing packets.
+var sendBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &sendBuffer{}
+	},
+}
+
+// sendBuffer holds the header of an outgoing packet and the vector used to write the header and the payload to the
+// connection using a single system call, without copying the payload.
+type sendBuffer struct {
+	header []byte
+	vec    [2][]byte
+	bufs   net.Buffers
+}
+
+func (sb *sendBuffer) release() {
+	// Do not keep the payload around.
+	sb.vec = [2][]byte{}
+	sb.bufs = nil
+	sendBufferPool.Put(sb)
 }
 
-// We write directly to the connection here without using bufio. The Payload() method and marshaling functions are
-// already writing to a bytes buffer before we write to the connection.
-// We write directly to the connection here without using bufio. The Payload() method and marshaling functions are
-// already writing to a bytes buffer before we write to the connection.
-func (c *Client) sendPacket(w io.Writer, p PacketOut) error {
+// We write directly to the connection here without using bufio. The header and the payload are written using a vectored
+// write, which results in a single writev system call when w is a TCP connection.
+func (c *Client) sendPacket(ct connectionType, w io.Writer, p PacketOut) error {
 	if w == nil {
 		return NotConnectedError
 	}
 	if p == nil {
 		return InvalidPacketError
 	}
-	c.Debugf("[sendPacket] sending %T", p)
+	lgr := c.channelLogger(ct)
+	if tid, ok := packetTransactionId(p); ok {
+		lgr = c.transactionLogger(ct, tid)
+	}
+	lgr.Debugf("[sendPacket] sending %T", p)
+	lgr.Debugf("[sendPacket] packet dump:\n%s", packetDump{p})
 
 	pl := p.Payload()
+	c.traceRequest(p, pl)
 	pll := len(pl)
-	var headerPayload []byte
+	buf := sendBufferPool.Get().(*sendBuffer)
+	defer buf.release()
 	// An invalid packet type means it does not adhere to the PTP/IP standard, so we only send the length field here.
 	if p.PacketType() == PKT_Invalid {
 		// Send length only. The length must include the size of the length field, so we add 4 bytes for that!
-		if _, err := w.Write(internal.MarshalLittleEndian(uint32(pll + 4))); err != nil {
-			return err
-		}
+		buf.header = binary.LittleEndian.AppendUint32(buf.header[:0], uint32(pll+4))
 	} else {
 		// The packet length MUST include the header, so we add 8 bytes for that!
-		h := internal.MarshalLittleEndian(Header{uint32(pll + HeaderSize), p.PacketType()})
-		for i := 0; i < len(h); i++ {
-			headerPayload = append(headerPayload, h[i])
-		}
+		buf.header = binary.LittleEndian.AppendUint32(buf.header[:0], uint32(pll+HeaderSize))
+		buf.header = binary.LittleEndian.AppendUint32(buf.header, uint32(p.PacketType()))
 	}
-	// Send payload.
+	// Send payload. Packets without a payload, such as the ProbeResponsePacket, consist of the header only.
 	if pll == 0 {
-		c.Debugf("[sendPacket] packet has no payload")
-		return nil
-	}
-	for i := 0; i < len(pl); i++ {
-		headerPayload = append(headerPayload, pl[i])
+		lgr.Debugf("[sendPacket] packet has no payload")
 	}
-	pll = len(headerPayload)
-	n, err := w.Write(headerPayload)
+	buf.vec = [2][]byte{buf.header, pl}
+	buf.bufs = buf.vec[:]
+	pll += len(buf.header)
+	n, err := buf.bufs.WriteTo(w)
 	if err != nil {
 		return err
 	}
-	if n != pll {
+	if int(n) != pll {
 		return fmt.Errorf(BytesWrittenMismatch, n, pll)
 	}
-	// c.Debugf("[sendPacket] header %d payload bytes written %d", headerPayloadLen, n)
+	c.recordPacket(ct, buf.header, pl)
 
-	var dataString string
-	for i := 0; i < len(pl); i++ {
-		dataString += fmt.Sprintf("%x ", pl[i])
+	return nil
+}
+
+// packetTransactionId returns the transaction ID of an outgoing packet. Returns false when the packet is not part of a
+// transaction.
+func packetTransactionId(p PacketOut) (ptp.TransactionID, bool) {
+	switch pkt := p.(type) {
+	case *OperationRequestPacket:
+		return pkt.TransactionID, true
+	case *FujiOperationRequestPacket:
+		return pkt.TransactionID, true
+	case *StartDataPacket:
+		return pkt.TransactionId, true
+	case *DataPacket:
+		return pkt.TransactionId, true
+	case *EndDataPacket:
+		return pkt.TransactionId, true
+	case *CancelPacket:
+		return pkt.TransactionId, true
 	}
-	// c.Debugf("Send Packet: \n\r%s", hex.Dump(headerPayload))
 
-	return nil
+	return 0, false
 }
 
 // readRawFromCmdDataConn reads raw data from the command/data connection with a read timout of 30 seconds.
@@ -426,7 +688,7 @@ func (c *Client) readRawFromCmdDataConn() ([]byte, error) {
 		return nil, fmt.Errorf("connection lost")
 	}
 	c.CommandDataConn.SetReadDeadline(time.Now().Add(DefaultReadTimeout))
-	return c.readRawResponse(c.CommandDataConn)
+	return c.readRawResponse(c.cmdDataReader)
 }
 
 // waitForRawFromCmdDataConn waits 30 seconds for a packet on the command/data connection.
@@ -446,9 +708,10 @@ func (c *Client) waitForRawFromCmdDataConn() ([]byte, error) {
 			res, err = c.readRawFromCmdDataConn()
 			if err != io.EOF || res != nil {
 				wait = false
+			} else {
+				// Nothing received yet, only pause when polling again.
+				time.Sleep(20 * time.Millisecond)
 			}
-
-			time.Sleep(20 * time.Millisecond)
 		}
 	}
 	if err != nil {
@@ -458,91 +721,95 @@ func (c *Client) waitForRawFromCmdDataConn() ([]byte, error) {
 	return res, nil
 }
 
-// readPacketFromCmdDataConn reads a packet from the command/data connection with a read timout of 30 seconds.
+// readPacketFromCmdDataConn reads a packet from the command/data connection, setting the given read deadline.
 // When expecting a specific packet, you can pass it in, otherwise pass nil.
 // The byte array that is returned will contain any excess data that was not unmarshalled, empty otherwise.
-func (c *Client) readPacketFromCmdDataConn(p PacketIn) (PacketIn, []byte, error) {
+func (c *Client) readPacketFromCmdDataConn(deadline time.Time, p PacketIn) (PacketIn, []byte, error) {
 	if c.CommandDataConn == nil {
 		return nil, nil, ConnectionLostError
 	}
-	c.CommandDataConn.SetReadDeadline(time.Now().Add(DefaultReadTimeout))
-	return c.readResponse(c.CommandDataConn, p)
+	c.CommandDataConn.SetReadDeadline(deadline)
+	return c.readResponse(c.cmdDataReader, p)
 }
 
-// waitForPacketFromCmdDataConn waits 30 seconds for a packet on the command/data connection.
+// waitForPacketFromCmdDataConn waits DefaultReadTimeout for a packet on the command/data connection.
 // This function will return a packet satisfying PacketIn together with any excess data that was not unmarshalled as a
 // byte array. The excess data will be empty if there was none.
 func (c *Client) waitForPacketFromCmdDataConn(p PacketIn) (PacketIn, []byte, error) {
-	var (
-		res PacketIn
-		xs  []byte
-		err error
-	)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReadTimeout)
+	defer cancel()
 
-	for wait, timeout := true, time.After(DefaultReadTimeout); wait; {
-		select {
-		case <-timeout:
-			wait = false
-			err = WaitForResponseError
-		default:
-			res, xs, err = c.readPacketFromCmdDataConn(p)
-			if err != io.EOF || res != nil {
-				wait = false
-			}
-			time.Sleep(20 * time.Millisecond)
-		}
-	}
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return res, xs, nil
+	return c.waitForPacketFromCmdDataConnContext(ctx, p)
 }
 
-// readPacketFromEventConn reads a packet from the Event connection.
+// readPacketFromEventConn reads a packet from the Event connection, setting the given read deadline.
 // The byte array that is returned will contain any excess data that was not unmarshalled, empty otherwise.
-func (c *Client) readPacketFromEventConn(p PacketIn) (PacketIn, []byte, error) {
+func (c *Client) readPacketFromEventConn(deadline time.Time, p PacketIn) (PacketIn, []byte, error) {
 	if c.eventConn == nil {
 		return nil, nil, ConnectionLostError
 	}
-	c.eventConn.SetReadDeadline(time.Now().Add(DefaultReadTimeout))
-	return c.readResponse(c.eventConn, p)
+	c.eventConn.SetReadDeadline(deadline)
+	return c.readResponse(c.eventReader, p)
 }
 
-// waitForPacketFromEventConn waits for a packet on the Event connection.
+// waitForPacketFromEventConn waits DefaultReadTimeout for a packet on the Event connection.
 // This function will return a packet satisfying EventPacket together with any excess data that was not unmarshalled as
-// a byte array. The excess data will be empty if there was none.
+// a byte array. The excess data will be empty if there was none. A ProbeRequestPacket is returned instead when the
+// Responder probes us.
 func (c *Client) waitForPacketFromEventConn(p EventPacket) (PacketIn, []byte, error) {
-	var (
-		res PacketIn
-		xs  []byte
-		err error
-	)
-
-	for wait, timeout := true, time.After(DefaultReadTimeout); wait; {
-		select {
-		case <-timeout:
-			wait = false
-			err = WaitForEventError
-		default:
-			res, xs, err = c.readPacketFromEventConn(p)
-			if err != io.EOF || res != nil {
-				wait = false
-			}
-			time.Sleep(20 * time.Millisecond)
-		}
-	}
-	if err != nil {
-		return nil, nil, err
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReadTimeout)
+	defer cancel()
 
-	return res, xs, nil
+	return c.waitForPacketFromEventConnContext(ctx, p)
 }
 
 // ReadRawFromStreamConn reads raw data from the streamer connection with a read timout of 30 seconds.
 func (c *Client) ReadRawFromStreamConn() ([]byte, error) {
 	c.CommandDataConn.SetReadDeadline(time.Now().Add(DefaultReadTimeout))
-	return c.readRawResponse(c.streamConn)
+	return c.readRawResponse(c.streamReader)
+}
+
+// readFrameFromStreamConn reads raw data from the streamer connection into a pooled buffer which must be released when
+// no longer needed.
+func (c *Client) readFrameFromStreamConn() (*frameBuffer, error) {
+	c.streamMu.Lock()
+	conn, r := c.streamConn, c.streamReader
+	c.streamMu.Unlock()
+
+	if conn == nil {
+		return nil, NotConnectedError
+	}
+
+	return readFrameBuffer(r)
+}
+
+// headerPool holds the buffers used to read the header of inbound packets.
+var headerPool = sync.Pool{
+	New: func() interface{} {
+		return new([HeaderSize]byte)
+	},
+}
+
+// readHeader reads the header of an inbound packet from r. When lengthOnly is true, only the length field is read,
+// leaving the packet type empty.
+func readHeader(r io.Reader, lengthOnly bool) (Header, error) {
+	b := headerPool.Get().(*[HeaderSize]byte)
+	defer headerPool.Put(b)
+
+	n := HeaderSize
+	if lengthOnly {
+		n = 4
+	}
+	if _, err := io.ReadFull(r, b[:n]); err != nil {
+		return Header{}, err
+	}
+
+	h := Header{Length: binary.LittleEndian.Uint32(b[:4])}
+	if !lengthOnly {
+		h.PacketType = PacketType(binary.LittleEndian.Uint32(b[4:]))
+	}
+
+	return h, nil
 }
 
 // TODO: this must be refactored to work like the events: continuously read and push to a channel in such a way that we
@@ -550,21 +817,17 @@ func (c *Client) ReadRawFromStreamConn() ([]byte, error) {
 //	do not mix up packets (use transaction ID properly) like what's happening now with liveview polling the camera state
 //	every second.
 func (c *Client) readResponse(r io.Reader, p PacketIn) (PacketIn, []byte, error) {
-	var err error
-	var h Header
 	var hl int
 
 	// An invalid packet type means it does not adhere to the PTP/IP standard, so we only read the length field here.
-	if p != nil && p.PacketType() == PKT_Invalid {
-		var l uint32
-		if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
-			return nil, nil, err
-		}
-		hl = int(l) - 4
+	lengthOnly := p != nil && p.PacketType() == PKT_Invalid
+	h, err := readHeader(r, lengthOnly)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lengthOnly {
+		hl = int(h.Length) - 4
 	} else {
-		if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
-			return nil, nil, err
-		}
 
 		if h.Length == 0 {
 			return nil, nil, ReadResponseError
@@ -572,7 +835,13 @@ func (c *Client) readResponse(r io.Reader, p PacketIn) (PacketIn, []byte, error)
 		hl = int(h.Length) - HeaderSize
 	}
 
-	if p == nil {
+	// The Responder can probe us at any time to check if we are still active, so a probe request may arrive instead of
+	// the packet we expect. The same goes for the response to a probe request sent using Probe().
+	if h.PacketType == PKT_ProbeRequest {
+		p = new(ProbeRequestPacket)
+	} else if h.PacketType == PKT_ProbeResponse {
+		p = new(ProbeResponsePacket)
+	} else if p == nil {
 		if p, err = NewPacketInFromPacketType(h.PacketType); err != nil {
 			return nil, nil, err
 		}
@@ -598,48 +867,40 @@ func (c *Client) readResponse(r io.Reader, p PacketIn) (PacketIn, []byte, error)
 // The reading approach taken here is so that we can return the full raw data but still reliably read the complete
 // expected data length.
 func (c *Client) readRawResponse(r io.Reader) ([]byte, error) {
-	l := make([]byte, 4)
-	if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
+	h, err := readHeader(r, true)
+	if err != nil {
 		return nil, err
 	}
-	len := binary.LittleEndian.Uint32(l)
-	b := make([]byte, int(len)-4)
-	if err := binary.Read(r, binary.LittleEndian, &b); err != nil {
-		return nil, err
+	if h.Length < 4 {
+		return nil, ReadResponseError
 	}
-
-	return append(l, b...), nil
-}
-
-// subscribe registers a channel to receive responses for a specific transaction ID.
-func (c *Client) subscribe(tid ptp.TransactionID, ch chan<- []byte) error {
-	c.cmdDataSubsMu.Lock()
-	defer c.cmdDataSubsMu.Unlock()
-
-	if _, ok := c.cmdDataSubs[tid]; ok {
-		return fmt.Errorf("attempt to double subscribe transaction id %d", tid)
+	// Allocate the full packet at once and read straight into it to avoid copying large data phases around.
+	b := make([]byte, h.Length)
+	binary.LittleEndian.PutUint32(b, h.Length)
+	if _, err := io.ReadFull(r, b[4:]); err != nil {
+		return nil, err
 	}
-	c.cmdDataSubs[tid] = ch
 
-	return nil
+	return b, nil
 }
 
-// unsubscribe removes a subscription for a given transaction ID and closes the corresponding channel.
-func (c *Client) unsubscribe(tid ptp.TransactionID) {
-	c.cmdDataSubsMu.Lock()
-	if ch, ok := c.cmdDataSubs[tid]; ok {
-		close(ch)
-		delete(c.cmdDataSubs, tid)
-	}
-	c.cmdDataSubsMu.Unlock()
+// startResponseListener starts the response listener, keeping track of it so that ResetConnection can wait for it to
+// stop.
+func (c *Client) startResponseListener() {
+	c.listeners.Add(1)
+	go c.responseListener()
+	c.startReaper()
 }
 
 // responseListener listens on the Command/Data connection for incoming packets and publishes them to a registered
 // subscriber based on the transaction ID of the packet.
 func (c *Client) responseListener() {
+	defer c.listeners.Done()
+	count := c.closeCount.Load()
 	c.cmdDataChan = make(chan []byte, 10)
 	lmp := "[responseListener]"
-	c.Debugf("%s subscribing response listener to command/data connection...", lmp)
+	lgr := c.channelLogger(cmdDataConnection)
+	lgr.Debugf("%s subscribing response listener to command/data connection...", lmp)
 	for {
 		p, err := c.waitForRawFromCmdDataConn()
 
@@ -647,27 +908,33 @@ func (c *Client) responseListener() {
 			tid, err := c.vendorExtensions.extractTransactionId(p, cmdDataConnection)
 			if err != nil {
 				// fmt.Printf("Error extract\n")
-				c.Error(err)
+				lgr.Error(err)
 				continue
 			}
-			c.Debugf("%s publishing new response with length '%d' for transaction ID '%d'...", lmp, binary.LittleEndian.Uint32(p[0:4]), tid)
-			c.Debugf("HEX dump: %s", hex.Dump(p))
-			c.cmdDataSubsMu.Lock()
-			c.cmdDataSubs[tid] <- p
-			c.cmdDataSubsMu.Unlock()
+			c.traceResponse(tid, p)
+			tlgr := c.transactionLogger(cmdDataConnection, tid)
+			tlgr.Debugf("%s publishing new response with length '%d' for transaction ID '%d'...", lmp, binary.LittleEndian.Uint32(p[0:4]), tid)
+			tlgr.Debugf("%s packet dump:\n%s", lmp, rawPacketDump{p, c.vendorExtensions.dumpRawPacket})
+			_, _, final := c.vendorExtensions.inspectResponse(p)
+			if !c.publish(tid, p, final) {
+				tlgr.Warnf("%s no subscriber for transaction ID '%d', dropping response", lmp, tid)
+			}
 			continue
-		} else if err == WaitForResponseError || strings.Contains(err.Error(), "i/o timeout") {
+		} else if IsTimeout(err) {
 			continue
 		}
 		// fmt.Printf("%s message listener stopped: %s\n", lmp, err)
-		c.Errorf("%s message listener stopped: %s", lmp, err)
-		c.Close()
+		lgr.Errorf("%s message listener stopped: %s", lmp, err)
+		// No more responses will arrive, so the subscribers must stop waiting.
+		c.dropSubscriptions()
+		go c.closeLostConnection(count)
 		return
 	}
 }
 
-func (c *Client) initCommandDataConn() error {
-	var err error
+func (c *Client) initCommandDataConn() (err error) {
+	end := c.startSpan(SpanInitCommandData)
+	defer func() { end(err) }()
 
 	c.CommandDataConn, err = internal.RetryDialer(c.Network(), c.CommandDataAddress(), DefaultDialTimeout)
 	if err != nil {
@@ -677,91 +944,269 @@ func (c *Client) initCommandDataConn() error {
 	c.configureTcpConn(cmdDataConnection)
 
 	if err := c.vendorExtensions.cmdDataInit(c); err != nil {
-		return fmt.Errorf("command data connection: %s", err)
+		return fmt.Errorf("command data connection: %w", err)
 	}
 
 	return nil
 }
 
-// WaitForRawPacketFromCommandDataSubscriber waits 30 seconds for a packet to be sent to a command/data channel
-// subscriber registered using the subscribe method.
+// WaitForRawPacketFromCommandDataSubscriber waits DefaultReadTimeout for a packet to be sent to a command/data channel
+// subscriber registered using the subscribe method. A TimeoutError is returned when no packet arrives in time.
 func (c *Client) WaitForRawPacketFromCommandDataSubscriber(ch <-chan []byte) ([]byte, error) {
-	var (
-		res []byte
-		err error
-	)
-
-	for wait, timeout := true, time.After(DefaultReadTimeout); wait; {
-		select {
-		case <-timeout:
-			wait = false
-			err = WaitForResponseError
-		case res = <-ch:
-			wait = false
-		}
-	}
-	if err != nil {
-		return nil, err
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReadTimeout)
+	defer cancel()
 
-	return res, nil
+	return c.WaitForRawPacketFromCommandDataSubscriberContext(ctx, ch)
 }
 
-// WaitForPacketFromCommandDataSubscriber waits 30 seconds for a packet to be sent to a command/data channel subscriber
-// registered using the subscribe method.
+// WaitForPacketFromCommandDataSubscriber waits DefaultReadTimeout for a packet to be sent to a command/data channel
+// subscriber registered using the subscribe method. A TimeoutError is returned when no packet arrives in time.
 // This function will return a packet satisfying PacketIn together with any excess data that was not unmarshalled as a
 // byte array. The excess data will be empty if there was none.
 func (c *Client) WaitForPacketFromCommandDataSubscriber(ch <-chan []byte, p PacketIn) (PacketIn, []byte, error) {
-	res, err := c.WaitForRawPacketFromCommandDataSubscriber(ch)
-	if err != nil {
-		return nil, nil, err
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReadTimeout)
+	defer cancel()
 
-	return c.readResponse(bytes.NewReader(res), p)
+	return c.WaitForPacketFromCommandDataSubscriberContext(ctx, ch, p)
 }
 
 func (c *Client) newCmdDataInitPacket() InitCommandRequestPacket {
 	return c.vendorExtensions.newCmdDataInitPacket(c.InitiatorGUID(), c.InitiatorFriendlyName())
 }
 
-func (c *Client) initEventConn() error {
+func (c *Client) initEventConn() (err error) {
+	end := c.startSpan(SpanInitEvent)
+	defer func() { end(err) }()
+
 	if err := c.vendorExtensions.eventInit(c); err != nil {
-		return fmt.Errorf("event connection error: %s", err)
+		return fmt.Errorf("event connection error: %w", err)
 	}
+
+	return c.startEventListener()
+}
+
+// startEventListener starts listening for events on the initialised event connection and starts polling for events
+// when the vendor requires it.
+func (c *Client) startEventListener() error {
 	lmp := "[eventListener]"
 	c.EventChan = make(chan EventPacket, 20)
 	c.EventPayloadChan = make(chan EventParameters, 20)
+	c.ObjectTransferChan = make(chan ptp.ObjectHandle, 20)
+	c.DeviceInfoChan = make(chan interface{}, 5)
+	c.probeResponses = make(chan struct{}, 1)
+	c.closeEventPoll = make(chan struct{})
+	stop := c.closeEventPoll
+	c.listeners.Add(1)
+	c.eventListening.Store(true)
 	go func() {
-		c.Debugf("%s subscribing event listener to event connection...", lmp)
+		defer c.listeners.Done()
+		defer c.eventListening.Store(false)
+		lgr := c.channelLogger(eventConnection)
+		lgr.Debugf("%s subscribing event listener to event connection...", lmp)
 		for {
 			p := c.vendorExtensions.newEventPacket()
-			_, payload, err := c.waitForPacketFromEventConn(p)
+			res, payload, err := c.waitForPacketFromEventConn(p)
+			if err == nil {
+				c.lastEventPacket.Store(time.Now().UnixNano())
+			}
 			payloadStruct := EventParameters{
 				Parameter1: payload,
 			}
+			// The Responder closes the session when we do not respond to its probe request immediately.
+			if _, ok := res.(*ProbeRequestPacket); ok && err == nil {
+				lgr.Debugf("%s responding to probe request", lmp)
+				if err := c.SendPacketToEventConn(&ProbeResponsePacket{}); err != nil {
+					lgr.Warnf("%s unable to respond to probe request: %s", lmp, err)
+				}
+				continue
+			}
+			if _, ok := res.(*ProbeResponsePacket); ok && err == nil {
+				select {
+				case c.probeResponses <- struct{}{}:
+				default:
+				}
+				continue
+			}
 			if err == nil {
-				// c.Debugf("%s hex dump : %s", lmp, hex.Dump(payload))
-				c.EventChan <- p
-				c.EventPayloadChan <- payloadStruct
+				if ep, ok := p.(*GenericEventPacket); ok {
+					ep.setParameters(payload)
+				}
+				if !c.dispatchEvent(p, payloadStruct, stop) {
+					return
+				}
 				continue
-			} else if err == WaitForEventError || strings.Contains(err.Error(), "i/o timeout") {
+			} else if IsTimeout(err) {
 				continue
 			}
-			c.Errorf("%s message listener stopped: %s", lmp, err)
+			lgr.Errorf("%s message listener stopped: %s", lmp, err)
 			return
 		}
 	}()
 
-	return nil
+	return c.vendorExtensions.pollEvents(c)
+}
+
+// dispatchEvent hands an event received from the Responder, either on the event connection or by polling, to the event
+// handlers. The event is published to the event channels unless the client handles it itself, see handleEvent. Returns
+// false when the event listener is being stopped while waiting for the event channels to have room.
+func (c *Client) dispatchEvent(p EventPacket, params EventParameters, stop <-chan struct{}) bool {
+	c.notifyEventHandlers(p)
+	if c.handleEvent(p) {
+		return true
+	}
+
+	select {
+	case c.EventChan <- p:
+	case <-stop:
+		return false
+	}
+	select {
+	case c.EventPayloadChan <- params:
+	case <-stop:
+		return false
+	}
+
+	return true
+}
+
+// dispatchPolledEvents dispatches the events reported by an event poller like the events received on the event
+// connection, see dispatchEvent. Returns false when the poller is being stopped.
+func (c *Client) dispatchPolledEvents(evts []EventPacket, stop <-chan struct{}) bool {
+	for _, evt := range evts {
+		var params EventParameters
+		if ep, ok := evt.(*GenericEventPacket); ok {
+			params.Parameter1 = ep.Parameter1
+		}
+		if !c.dispatchEvent(evt, params, stop) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// startEventPoller runs the event poller of a vendor that does not report all events on the event connection, keeping
+// track of it like the listeners since it sends to the event channels as well. The poller must return once the stop
+// channel is closed.
+func (c *Client) startEventPoller(poll func(stop <-chan struct{})) {
+	stop := c.closeEventPoll
+	c.listeners.Add(1)
+	go func() {
+		defer c.listeners.Done()
+		poll(stop)
+	}()
+}
+
+// eventHandler wraps a function registered using OnEvent so that it can be identified when it is removed again.
+type eventHandler struct {
+	f func(EventPacket)
+}
+
+// OnEvent registers a function that will be called for each event received from the Responder, including the events
+// the client handles itself. Unlike the EventChan, which is meant for a single consumer, any number of functions can be
+// registered. The function is called from the event listener so it must return quickly: any lengthy processing, such
+// as sending an operation request to the Responder, must be done elsewhere.
+// Calling the function returned removes the event handler again.
+func (c *Client) OnEvent(f func(EventPacket)) func() {
+	h := &eventHandler{f: f}
+
+	c.eventHandlersMu.Lock()
+	c.eventHandlers = append(c.eventHandlers, h)
+	c.eventHandlersMu.Unlock()
+
+	return func() {
+		c.eventHandlersMu.Lock()
+		defer c.eventHandlersMu.Unlock()
+
+		for i, e := range c.eventHandlers {
+			if e == h {
+				// Never modify the slice in place: notifyEventHandlers might be iterating over it.
+				c.eventHandlers = append(c.eventHandlers[:i:i], c.eventHandlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) notifyEventHandlers(p EventPacket) {
+	c.eventHandlersMu.Lock()
+	handlers := c.eventHandlers
+	c.eventHandlersMu.Unlock()
+
+	for _, h := range handlers {
+		h.f(p)
+	}
+}
+
+// handleEvent handles the events that require action from the client itself instead of from a consumer of the event
+// channel. It returns true when the event was handled.
+func (c *Client) handleEvent(p EventPacket) bool {
+	switch p.GetEventCode() {
+	case ptp.EC_CancelTransaction:
+		c.cancelTransaction(p.GetTransactionID())
+	case ptp.EC_RequestObjectTransfer:
+		h := ptp.ObjectHandle(p.GetParameter1())
+		select {
+		case c.ObjectTransferChan <- h:
+		default:
+			c.Warnf("[eventListener] object transfer channel full, dropping transfer request for handle %#x", h)
+		}
+	case ptp.EC_DeviceInfoChanged:
+		// Refreshing requires a round trip on the command/data connection so we must not block the event listener.
+		go c.refreshDeviceInfo()
+	case ptp.EC_DevicePropChanged:
+		c.forgetDevicePropertyDescription(ptp.DevicePropCode(p.GetParameter1()))
+		return false
+	default:
+		return false
+	}
+
+	return true
+}
+
+// refreshDeviceInfo invalidates all cached information, requests the device info again and publishes it to the
+// DeviceInfoChan.
+func (c *Client) refreshDeviceInfo() {
+	c.Info("[eventListener] device info changed, refreshing...")
+	c.invalidateCache()
+
+	di, err := c.GetDeviceInfo()
+	if err != nil {
+		c.Errorf("[eventListener] unable to refresh device info: %s", err)
+		return
+	}
+
+	select {
+	case c.DeviceInfoChan <- di:
+	default:
+		c.Warn("[eventListener] device info channel full, dropping refreshed device info")
+	}
+}
+
+// cancelTransaction aborts the in-flight transaction with the given ID by publishing a cancel packet to its subscriber.
+// The subscriber is then expected to stop waiting for further data and return TransactionCancelled.
+func (c *Client) cancelTransaction(tid ptp.TransactionID) {
+	lgr := c.transactionLogger(eventConnection, tid)
+
+	cp := &CancelPacket{TransactionId: tid}
+	pl := cp.Payload()
+	raw := append(internal.MarshalLittleEndian(Header{uint32(len(pl) + HeaderSize), cp.PacketType()}), pl...)
+	if !c.publish(tid, raw, false) {
+		lgr.Debugf("[eventListener] no transaction in flight with ID '%d' to cancel", tid)
+		return
+	}
+	c.endTransactionSpan(tid, TransactionCancelled)
+	lgr.Infof("[eventListener] transaction with ID '%d' cancelled by responder", tid)
 }
 
 func (c *Client) newEventInitPacket() InitEventRequestPacket {
 	return c.vendorExtensions.newEventInitPacket(c.connectionNumber)
 }
 
-func (c *Client) initStreamConn() error {
+func (c *Client) initStreamConn() (err error) {
 	if c.streamConn == nil {
-		var err error
+		end := c.startSpan(SpanInitStream)
+		defer func() { end(err) }()
 
 		c.streamConn, err = internal.RetryDialer(c.Network(), c.StreamerAddress(), DefaultDialTimeout)
 		if err != nil {
@@ -771,6 +1216,7 @@ func (c *Client) initStreamConn() error {
 		c.configureTcpConn(streamConnection)
 
 		c.StreamChan = make(chan []byte, 50)
+		c.StreamEventChan = make(chan StreamEvent, 5)
 		c.closeStreamChan = make(chan struct{})
 
 		return c.vendorExtensions.processStreamData(c)
@@ -783,6 +1229,10 @@ func (c *Client) closeStreamConn() error {
 	if c.StreamChan != nil {
 		close(c.closeStreamChan)
 	}
+	c.closeFrameSubscriptions()
+
+	c.streamMu.Lock()
+	defer c.streamMu.Unlock()
 
 	err := c.streamConn.Close()
 	c.streamConn = nil
@@ -790,15 +1240,101 @@ func (c *Client) closeStreamConn() error {
 	return err
 }
 
-func (c *Client) closeEventConn() error {
+// SetStreamReconnectBackoff sets the time to wait before the first attempt to reconnect a lost streamer connection.
+// The wait time doubles after each failed attempt up to MaxStreamBackoff. Set to 0 to disable reconnecting.
+func (c *Client) SetStreamReconnectBackoff(d time.Duration) {
+	c.streamMu.Lock()
+	c.streamBackoff = d
+	c.streamMu.Unlock()
+}
+
+// reconnectStream is to be called by the stream listeners when reading from the streamer connection fails, which
+// happens when the Responder goes to sleep or the WiFi connection has a hiccup. It keeps trying to open a new streamer
+// connection using an exponential backoff until it succeeds or the streamer connection is closed by us. The frame
+// subscriptions are left untouched, so consumers will simply receive frames again once the connection is restored.
+// Returns false when the streamer connection has been closed or reconnecting is disabled.
+func (c *Client) reconnectStream(cause error) bool {
+	lmp := "[reconnectStream]"
+	lgr := c.channelLogger(streamConnection)
+
+	c.streamMu.Lock()
+	backoff := c.streamBackoff
+	if c.streamConn != nil {
+		c.streamConn.Close()
+	}
+	c.streamMu.Unlock()
+
+	select {
+	case <-c.closeStreamChan:
+		return false
+	default:
+	}
+
+	lgr.Warnf("%s streamer connection lost: %s", lmp, cause)
+	c.sendStreamEvent(StreamEvent{Type: StreamLost, Err: cause})
+	if backoff <= 0 {
+		return false
+	}
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.closeStreamChan:
+			return false
+		case <-time.After(backoff):
+		}
+
+		conn, err := net.DialTimeout(c.Network(), c.StreamerAddress(), DefaultDialTimeout)
+		if err != nil {
+			lgr.Debugf("%s attempt %d failed: %s", lmp, attempt, err)
+			if backoff *= 2; backoff > MaxStreamBackoff {
+				backoff = MaxStreamBackoff
+			}
+			continue
+		}
+
+		c.streamMu.Lock()
+		select {
+		case <-c.closeStreamChan:
+			c.streamMu.Unlock()
+			conn.Close()
+			return false
+		default:
+		}
+		c.streamConn = conn
+		c.configureTcpConn(streamConnection)
+		c.streamMu.Unlock()
+
+		lgr.Infof("%s streamer connection restored after %d attempt(s)", lmp, attempt)
+		c.sendStreamEvent(StreamEvent{Type: StreamResumed, Attempts: attempt})
+
+		return true
+	}
+}
+
+// sendStreamEvent notifies the consumers of StreamEventChan without ever blocking the stream listener.
+func (c *Client) sendStreamEvent(e StreamEvent) {
+	select {
+	case c.StreamEventChan <- e:
+	default:
+		c.Debugf("[sendStreamEvent] stream event channel full, dropping event %s", e.Type)
+	}
+}
+
+// stopEventListener asks the event listener and the event poller to stop.
+func (c *Client) stopEventListener() {
+	if c.closeEventPoll != nil {
+		close(c.closeEventPoll)
+		c.closeEventPoll = nil
+	}
+}
+
+// closeEventConn clears the event connection after its socket has been closed and the listeners have stopped, closing
+// the event payload channel since nothing sends to it anymore.
+func (c *Client) closeEventConn() {
 	if c.EventChan != nil {
 		close(c.EventPayloadChan)
 	}
-
-	err := c.eventConn.Close()
 	c.eventConn = nil
-
-	return err
 }
 
 func (c *Client) configureTcpConn(t connectionType) {
@@ -813,25 +1349,31 @@ func (c *Client) configureTcpConn(t connectionType) {
 		conn = c.streamConn
 	}
 
+	lgr := c.channelLogger(t)
+
 	// The PTP/IP protocol specifically asks to enable keep alive.
 	if err := conn.(*net.TCPConn).SetKeepAlive(true); err != nil {
-		c.Warnf("TCP_KEEPALIVE not enabled for %s connection: %s", t, err)
+		lgr.Warnf("TCP_KEEPALIVE not enabled for %s connection: %s", t, err)
 	} else {
-		c.Debugf("TCP_KEEPALIVE enabled for %s connection", t)
+		lgr.Debugf("TCP_KEEPALIVE enabled for %s connection", t)
 	}
 
-	// The PTP/IP protocol specifically asks to disable Nagle's algorithm. TCP_NODELAY SHOULD be enabled by default in
-	// golang but there's no harm in making sure since performance here is negligible.
-	if err := conn.(*net.TCPConn).SetNoDelay(true); err != nil {
-		c.Warnf("TCP_NODELAY not enabled for %s connection: %s", t, err)
-	} else {
-		c.Debugf("TCP_NODELAY enabled for %s connection", t)
+	// The PTP/IP protocol specifically asks to disable Nagle's algorithm, which the options do unless told otherwise.
+	r := c.rawTapReader(t, c.recordingReader(t, c.applyConnOptions(t, conn.(*net.TCPConn), c.connOptions[t])))
+	switch t {
+	case cmdDataConnection:
+		c.cmdDataReader = r
+	case eventConnection:
+		c.eventReader = r
+	case streamConnection:
+		c.streamReader = r
 	}
 }
 
 // NewClient creates a new PTP/IP client.
 // Passing an empty string to friendlyName will use the default friendly name.
 // Passing an empty string as guid will generate a random V4 UUID upon initialisation.
+// Passing AutoVendor as vendor will detect the vendor of the Responder when dialing.
 func NewClient(vendor string, ip string, port uint16, friendlyName string, guid string, logLevel LogLevel) (*Client, error) {
 	i, err := NewInitiator(friendlyName, guid)
 	if err != nil {
@@ -839,11 +1381,21 @@ func NewClient(vendor string, ip string, port uint16, friendlyName string, guid
 	}
 
 	c := &Client{
-		initiator:   i,
-		responder:   NewResponder(vendor, ip, port, port, port),
-		cmdDataSubs: make(map[ptp.TransactionID]chan<- []byte),
-		Logger:      NewLogger(logLevel, os.Stderr, "", log.LstdFlags),
+		initiator:     i,
+		responder:     NewResponder(vendor, ip, port, port, port),
+		cmdDataSubs:   make(map[ptp.TransactionID]*subscription),
+		propDescs:     make(map[ptp.DevicePropCode]*ptp.DevicePropDesc),
+		frames:        newFrameBroadcaster(),
+		streamBackoff: DefaultStreamBackoff,
+		dataChunkSize: DefaultDataChunkSize,
+		connOptions:   defaultConnOptions(),
+		tracer:        noopTracer{},
+		sessionId:     DefaultSessionId,
+		sessionIds:    &sessionIds{last: DefaultSessionId},
+		detectVendor:  vendor == AutoVendor,
 	}
+	c.cmdDataSubsEnded.L = &c.cmdDataSubsMu
+	c.SetLogger(NewLogger(logLevel, os.Stderr, "", log.LstdFlags))
 
 	c.loadVendorExtensions()
 
@@ -853,7 +1405,25 @@ func NewClient(vendor string, ip string, port uint16, friendlyName string, guid
 // GetDeviceInfo requests the Responder's device information. The data that should be returned is clearly specified by
 // the PTP/IP protocol but will, alas, greatly differ from vendor to vendor.
 func (c *Client) GetDeviceInfo() (interface{}, error) {
-	return c.vendorExtensions.getDeviceInfo(c)
+	di, err := c.vendorExtensions.getDeviceInfo(c)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cacheMu.Lock()
+	c.deviceInfo = di
+	c.cacheMu.Unlock()
+
+	return di, nil
+}
+
+// CachedDeviceInfo returns the device information received by the last call to GetDeviceInfo. It returns nil when
+// GetDeviceInfo has not been called yet or when the Responder reported its capabilities have changed since.
+func (c *Client) CachedDeviceInfo() interface{} {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	return c.deviceInfo
 }
 
 // GetDeviceState requests the Responder's device status. This is not part of the PTP/IP specification but is
@@ -864,7 +1434,39 @@ func (c *Client) GetDeviceState() (interface{}, error) {
 
 // GetDevicePropertyDescription gets the description of the given device property.
 func (c *Client) GetDevicePropertyDescription(code ptp.DevicePropCode) (*ptp.DevicePropDesc, error) {
-	return c.vendorExtensions.getDevicePropertyDesc(c, code)
+	dpd, err := c.vendorExtensions.getDevicePropertyDesc(c, code)
+	if err == nil && dpd != nil {
+		c.cacheMu.Lock()
+		c.propDescs[code] = dpd
+		c.cacheMu.Unlock()
+	}
+
+	return dpd, err
+}
+
+// CachedDevicePropertyDescription returns the description received by the last call to GetDevicePropertyDescription
+// for the given device property. It returns nil when the property has not been described yet or when the Responder
+// reported a change to the property or to its own capabilities since.
+func (c *Client) CachedDevicePropertyDescription(code ptp.DevicePropCode) *ptp.DevicePropDesc {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	return c.propDescs[code]
+}
+
+// forgetDevicePropertyDescription removes the given device property from the cache.
+func (c *Client) forgetDevicePropertyDescription(code ptp.DevicePropCode) {
+	c.cacheMu.Lock()
+	delete(c.propDescs, code)
+	c.cacheMu.Unlock()
+}
+
+// invalidateCache clears the cached device info and all cached device property descriptions.
+func (c *Client) invalidateCache() {
+	c.cacheMu.Lock()
+	c.deviceInfo = nil
+	c.propDescs = make(map[ptp.DevicePropCode]*ptp.DevicePropDesc)
+	c.cacheMu.Unlock()
 }
 
 // GetDevicePropertyValue gets the value of the given device property.
@@ -874,6 +1476,8 @@ func (c *Client) GetDevicePropertyValue(code ptp.DevicePropCode) (uint32, error)
 
 // SetDeviceProperty sets the given device property to the specified value.
 func (c *Client) SetDeviceProperty(code ptp.DevicePropCode, val uint32) error {
+	c.forgetDevicePropertyDescription(code)
+
 	return c.vendorExtensions.setDeviceProperty(c, code, val)
 }
 
@@ -883,27 +1487,61 @@ func (c *Client) OperationRequestRaw(code ptp.OperationCode, params []uint32) ([
 	return c.vendorExtensions.operationRequestRaw(c, code, params)
 }
 
+// ResponseCode returns the response code held by a full raw packet as returned by OperationRequestRaw(). False is
+// returned when the packet is not an operation response.
+func (c *Client) ResponseCode(p []byte) (ptp.OperationResponseCode, bool) {
+	_, rc, final := c.vendorExtensions.inspectResponse(p)
+	return rc, final
+}
+
 func (c *Client) SendData(code ptp.OperationCode, params []uint32, dataSend []byte, len uint64) ([]byte, error) {
-	return c.vendorExtensions.sendData(c, code, params, dataSend, len)
+	return c.vendorExtensions.sendData(context.Background(), c, code, params, dataSend, len)
+}
+
+// SendDataContext works like SendData but the transfer is cancelled when ctx is done before all data has been sent.
+// The data is sent in chunks of the size set using SetDataChunkSize, so that a large upload, such as SendObject or a
+// firmware update, can be cancelled cleanly in between two chunks. The error returned then wraps ctx.Err().
+func (c *Client) SendDataContext(ctx context.Context, code ptp.OperationCode, params []uint32, dataSend []byte, len uint64) ([]byte, error) {
+	return c.vendorExtensions.sendData(ctx, c, code, params, dataSend, len)
+}
+
+// SetDataChunkSize sets the size of the data packets the data-out phase is split into. A size of zero sends all data
+// in a single packet, which makes it impossible to cancel the transfer.
+func (c *Client) SetDataChunkSize(size int) {
+	c.dataChunkSize = size
 }
 
 func (c *Client) OperationRequestDataRaw(code ptp.OperationCode, params []uint32) ([]byte, error) {
 	return c.vendorExtensions.operationDataRequestRaw(c, code, params)
 }
 
+// OperationRequestData sends an operation request expecting a data-in phase and returns the payload of the data
+// packets, without any packet headers, once the Responder answered with ptp.RC_OK. Use DecodeDataset to decode the
+// payload of standard operations.
+func (c *Client) OperationRequestData(code ptp.OperationCode, params []uint32) ([]byte, error) {
+	return c.vendorExtensions.operationRequestData(c, code, params)
+}
+
 // InitiateCapture releases the shutter and captures an image. If the responder supports it, a preview of the captured
-// image is returned as a byte array.
+// image is returned as a byte array. When the capture fails, the error returned is a *CaptureError.
 func (c *Client) InitiateCapture() ([]byte, error) {
-	return c.vendorExtensions.initiateCapture(c)
+	img, err := c.vendorExtensions.initiateCapture(c)
+	if err != nil {
+		var ce *CaptureError
+		if !errors.As(err, &ce) {
+			err = &CaptureError{Err: err}
+		}
+	}
+
+	return img, err
 }
 
 // ToggleLiveView opens or closes the streamer connection on the camera, if it has one, and initiates or closes the
-// StreamChan on the client.
-// StreamChan will receive raw image data that can be processed by the client.
+// StreamChan on the client. Vendors without a streamer connection have their liveview frames polled over the
+// command/data connection instead, which is transparent to the consumers of the frames.
+// StreamChan will receive raw image data that can be processed by the client. Alternatively, use OnLiveviewFrame,
+// SubscribeLiveview or LiveviewFrames to receive the frames together with their metadata: these allow any number of
+// consumers to process the frames independently of each other.
 func (c *Client) ToggleLiveView(en bool) error {
-	if en {
-		return c.initStreamConn()
-	}
-
-	return c.closeStreamConn()
+	return c.vendorExtensions.toggleLiveView(c, en)
 }
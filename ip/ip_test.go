@@ -2,8 +2,15 @@ package ip
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/malc0mn/ptp-ip/ptp"
@@ -229,7 +236,7 @@ func TestClient_sendPacket(t *testing.T) {
 	want := "[00101010 00000000 00000000 00000000 00000001 00000000 00000000 00000000 11100100 01100010 10110101 10010000 10110101 00010110 01000111 01001010 10011101 10111000 10100100 01100101 10110011 01110000 11111010 10111101 01110111 00000000 01110010 00000000 01101001 00000000 01110100 00000000 11101000 00000000 01110010 00000000 00000000 00000000 00000000 00000000 00000001 00000000]"
 
 	var buf bytes.Buffer
-	c.sendPacket(&buf, p)
+	c.sendPacket(cmdDataConnection, &buf, p)
 	got := fmt.Sprintf("%.8b", buf.Bytes())
 
 	if got != want {
@@ -297,6 +304,99 @@ func TestClient_readResponse(t *testing.T) {
 	}
 }
 
+func TestClient_readResponseOperationResponse(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "wrîter", "5f3a1b0e-2a3c-4c5d-9e8f-0a1b2c3d4e5f", logLevel)
+	if err != nil {
+		t.Errorf("readResponse() err = %s; want <nil>", err)
+	}
+
+	// An operation response holding two of the five parameters.
+	b := bytes.NewBuffer([]byte{0x16, 0x0, 0x0, 0x0, 0x7, 0x0, 0x0, 0x0, 0x1, 0x20, 0x5, 0x0, 0x0, 0x0, 0x10, 0x0, 0x0, 0x0, 0x20, 0x0, 0x0, 0x0})
+	rp, xs, err := c.readResponse(b, nil)
+	if err != nil {
+		t.Fatalf("readResponse() error = %s; want <nil>", err)
+	}
+	if len(xs) > 0 {
+		t.Errorf("readResponse() excess bytes = %d; want 0", len(xs))
+	}
+
+	ores := rp.(*OperationResponsePacket)
+	if ores.ResponseCode != ptp.RC_OK || ores.TransactionID != 5 {
+		t.Errorf("readResponse() ResponseCode, TransactionID = %s, %d; want %s, 5", ores.ResponseCode, ores.TransactionID, ptp.RC_OK)
+	}
+	if got := fmt.Sprintf("%#x", ores.Parameters); got != "[0x10 0x20]" {
+		t.Errorf("readResponse() Parameters = %s; want [0x10 0x20]", got)
+	}
+}
+
+func TestNewOperationRequest(t *testing.T) {
+	params := []uint32{1, 2, 3}
+	got, err := newOperationRequest(ptp.OC_GetObjectHandles, 3, params, DP_NoDataOrDataIn)
+	if err != nil {
+		t.Fatalf("newOperationRequest() error = %s; want <nil>", err)
+	}
+	params[0] = 9
+	if got.OperationCode != ptp.OC_GetObjectHandles || got.TransactionID != 3 || fmt.Sprint(got.Parameters) != "[1 2 3]" {
+		t.Errorf("newOperationRequest() got = %v; want code %#x, tid 3 and parameters [1 2 3]", got, ptp.OC_GetObjectHandles)
+	}
+
+	check := []struct {
+		code   ptp.OperationCode
+		params []uint32
+		dp     DataPhase
+		want   error
+	}{
+		{ptp.OC_GetObjectHandles, []uint32{1, 2, 3, 4}, DP_NoDataOrDataIn, TooManyParametersError},
+		{ptp.OC_SetDevicePropValue, []uint32{0x5005}, DP_NoDataOrDataIn, DataPhaseMismatchError},
+		{ptp.OC_GetObject, []uint32{1}, DP_DataOut, DataPhaseMismatchError},
+		{ptp.OC_SetDevicePropValue, []uint32{0x5005}, DP_DataOut, nil},
+		// Vendor-extended operations are only limited by the maximum number of parameters.
+		{ptp.OperationCode(0x9001), []uint32{1, 2, 3, 4, 5}, DP_DataOut, nil},
+		{ptp.OperationCode(0x9001), []uint32{1, 2, 3, 4, 5, 6}, DP_NoDataOrDataIn, TooManyParametersError},
+	}
+
+	for _, tt := range check {
+		_, err := newOperationRequest(tt.code, 3, tt.params, tt.dp)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("newOperationRequest() %#x error = %v; want %v", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestClient_readResponseProbeRequest(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "writèr", "d6555687-a599-44b8-a4af-279d599a92f6", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	sendAnyPacket(&b, &ProbeRequestPacket{}, nil, "[ip_test]")
+
+	rp, _, err := c.readResponse(&b, c.vendorExtensions.newEventPacket())
+	if err != nil {
+		t.Errorf("readResponse() error = %s; want <nil>", err)
+	}
+	if _, ok := rp.(*ProbeRequestPacket); !ok {
+		t.Errorf("readResponse() PaketType = %T; want *ip.ProbeRequestPacket", rp)
+	}
+}
+
+func TestClient_ResponseCode(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, ok := c.ResponseCode([]byte{0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x0c, 0x20, 0x01, 0x00, 0x00, 0x00})
+	if !ok || rc != ptp.RC_StoreFull {
+		t.Errorf("ResponseCode() got = %s, %t; want %s, true", rc, ok, ptp.RC_StoreFull)
+	}
+
+	if _, ok := c.ResponseCode([]byte{0x0c, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}); ok {
+		t.Errorf("ResponseCode() ok = true; want false for a start data packet")
+	}
+}
+
 func TestClient_readRawResponse(t *testing.T) {
 	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "wrîter", "617b38ef-b6e6-4ef6-b2ad-ea51cecdbbd3", logLevel)
 	if err != nil {
@@ -377,8 +477,178 @@ func TestClient_subscribe(t *testing.T) {
 	if !ok {
 		t.Errorf("subscribe() got = %#v; want true", got)
 	}
-	if got != ch {
-		t.Errorf("subscribe() got = %#v; want %#v", got, ch)
+	if got.ch != ch {
+		t.Errorf("subscribe() got = %#v; want %#v", got.ch, ch)
+	}
+}
+
+func TestClient_handleEventCancelTransaction(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "b3ca53e9-bb61-4c85-9fcd-3b446a9e81e6", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tid := ptp.TransactionID(12)
+	ch := make(chan []byte, 2)
+	if err := c.subscribe(tid, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	evt := &GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_CancelTransaction, TransactionID: tid}}
+	if !c.handleEvent(evt) {
+		t.Errorf("handleEvent() return = false; want true")
+	}
+
+	got := <-ch
+	want := []byte{0x0c, 0x00, 0x00, 0x00, 0x0b, 0x00, 0x00, 0x00, 0x0c, 0x00, 0x00, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("handleEvent() published = %#v; want %#v", got, want)
+	}
+}
+
+func TestClient_handleEventRequestObjectTransfer(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "b3ca53e9-bb61-4c85-9fcd-3b446a9e81e6", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ObjectTransferChan = make(chan ptp.ObjectHandle, 1)
+
+	evt := &GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_RequestObjectTransfer}}
+	evt.setParameters([]byte{0x2a, 0x00, 0x00, 0x00})
+	if !c.handleEvent(evt) {
+		t.Errorf("handleEvent() return = false; want true")
+	}
+
+	got := <-c.ObjectTransferChan
+	want := ptp.ObjectHandle(42)
+	if got != want {
+		t.Errorf("handleEvent() handle = %d; want %d", got, want)
+	}
+
+	if c.handleEvent(&GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_ObjectAdded}}) {
+		t.Errorf("handleEvent() return = true; want false")
+	}
+}
+
+func TestClient_handleEventDevicePropChanged(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "b3ca53e9-bb61-4c85-9fcd-3b446a9e81e6", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.propDescs[ptp.DPC_FNumber] = &ptp.DevicePropDesc{DevicePropertyCode: ptp.DPC_FNumber}
+	c.propDescs[ptp.DPC_WhiteBalance] = &ptp.DevicePropDesc{DevicePropertyCode: ptp.DPC_WhiteBalance}
+
+	evt := &GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_DevicePropChanged}}
+	evt.setParameters([]byte{0x07, 0x50, 0x00, 0x00})
+	if c.handleEvent(evt) {
+		t.Errorf("handleEvent() return = true; want false")
+	}
+
+	if got := c.CachedDevicePropertyDescription(ptp.DPC_FNumber); got != nil {
+		t.Errorf("CachedDevicePropertyDescription() got = %#v; want <nil>", got)
+	}
+	if got := c.CachedDevicePropertyDescription(ptp.DPC_WhiteBalance); got == nil {
+		t.Errorf("CachedDevicePropertyDescription() got = <nil>; want %#x", ptp.DPC_WhiteBalance)
+	}
+}
+
+func TestClient_dispatchPolledEvents(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.EventChan = make(chan EventPacket, 1)
+	c.EventPayloadChan = make(chan EventParameters, 1)
+	c.propDescs[ptp.DPC_FNumber] = &ptp.DevicePropDesc{DevicePropertyCode: ptp.DPC_FNumber}
+
+	evts := sonyChangedPropertyEvents(map[ptp.DevicePropCode][]byte{ptp.DPC_FNumber: {0x01}}, map[ptp.DevicePropCode][]byte{ptp.DPC_FNumber: {0x02}})
+	if !c.dispatchPolledEvents(evts, make(chan struct{})) {
+		t.Fatal("dispatchPolledEvents() return = false; want true")
+	}
+
+	if got := c.CachedDevicePropertyDescription(ptp.DPC_FNumber); got != nil {
+		t.Errorf("CachedDevicePropertyDescription() got = %#v; want <nil>", got)
+	}
+	if got := <-c.EventChan; got.GetEventCode() != ptp.EC_DevicePropChanged {
+		t.Errorf("EventChan got = %#x; want %#x", got.GetEventCode(), ptp.EC_DevicePropChanged)
+	}
+	if got := <-c.EventPayloadChan; !bytes.Equal(got.Parameter1, []byte{0x07, 0x50, 0x00, 0x00}) {
+		t.Errorf("EventPayloadChan got = %#x; want %#x", got.Parameter1, []byte{0x07, 0x50, 0x00, 0x00})
+	}
+
+	// A full event channel does not keep the poller from stopping.
+	stop := make(chan struct{})
+	close(stop)
+	c.EventChan <- evts[0]
+	if c.dispatchPolledEvents(evts, stop) {
+		t.Error("dispatchPolledEvents() return = true; want false when stopped")
+	}
+}
+
+func TestClient_OnEvent(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		got    []ptp.EventCode
+		remove []func()
+	)
+	for i := 0; i < 2; i++ {
+		remove = append(remove, c.OnEvent(func(p EventPacket) {
+			got = append(got, p.GetEventCode())
+		}))
+	}
+
+	c.notifyEventHandlers(&GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_DevicePropChanged}})
+	if len(got) != 2 || got[0] != ptp.EC_DevicePropChanged || got[1] != ptp.EC_DevicePropChanged {
+		t.Errorf("OnEvent() got = %#x; want two times %#x", got, ptp.EC_DevicePropChanged)
+	}
+
+	remove[0]()
+	remove[0]()
+	got = nil
+	c.notifyEventHandlers(&GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_ObjectAdded}})
+	if len(got) != 1 || got[0] != ptp.EC_ObjectAdded {
+		t.Errorf("OnEvent() got = %#x; want one time %#x after removing a handler", got, ptp.EC_ObjectAdded)
+	}
+}
+
+func TestClient_handleEventDeviceInfoChanged(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "tèster", "558acd44-f794-4b26-9129-d460b2a29e8d", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.propDescs[ptp.DPC_FNumber] = &ptp.DevicePropDesc{DevicePropertyCode: ptp.DPC_FNumber}
+
+	if !c.handleEvent(&GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_DeviceInfoChanged}}) {
+		t.Errorf("handleEvent() return = false; want true")
+	}
+
+	select {
+	case got := <-c.DeviceInfoChan:
+		if got == nil {
+			t.Errorf("handleEvent() device info = %v; want *ip.OperationResponsePacket", got)
+		}
+	case <-time.After(DefaultReadTimeout):
+		t.Fatal("handleEvent() timeout waiting for refreshed device info")
+	}
+
+	if got := c.CachedDevicePropertyDescription(ptp.DPC_FNumber); got != nil {
+		t.Errorf("CachedDevicePropertyDescription() got = %#v; want <nil>", got)
+	}
+	if got := c.CachedDeviceInfo(); got == nil {
+		t.Errorf("CachedDeviceInfo() got = <nil>; want *ip.OperationResponsePacket")
 	}
 }
 
@@ -418,6 +688,67 @@ func TestClient_initEventConnFail(t *testing.T) {
 	}
 }
 
+func TestClient_probeRequest(t *testing.T) {
+	ln, err := net.Listen("tcp", address+":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// The Responder probes us as soon as the event connection is established.
+	probed := make(chan PacketType, 1)
+	go func() {
+		evtChan := make(chan uint32, 10)
+		lmp := "[Mocked probing responder]"
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				h, _, err := readMessage(conn, lmp)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				if h.PacketType == PKT_InitCommandRequest {
+					_, res := genericInitCommandRequestResponse(lmp, PV_VersionOnePointZero)
+					sendMessage(conn, res, nil, lmp)
+					handleGenericMessages(conn, evtChan, lmp)
+					return
+				}
+
+				defer conn.Close()
+				_, res := genericInitEventRequestResponse()
+				sendMessage(conn, res, nil, lmp)
+				sendMessage(conn, &ProbeRequestPacket{}, nil, lmp)
+				if h, _, err = readMessage(conn, lmp); err == nil {
+					probed <- h.PacketType
+				}
+			}(conn)
+		}
+	}()
+
+	c, err := NewClient(DefaultVendor, address, uint16(ln.Addr().(*net.TCPAddr).Port), "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-probed:
+		if got != PKT_ProbeResponse {
+			t.Errorf("probe response PacketType = %#x; want %#x", got, PKT_ProbeResponse)
+		}
+	case <-time.After(DefaultReadTimeout):
+		t.Errorf("probe response not received")
+	}
+}
+
 func TestClient_Dial(t *testing.T) {
 	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "7e5ac7d3-46b7-4c50-b0d9-ba56c0e599f0", logLevel)
 	defer c.Close()
@@ -442,6 +773,94 @@ func TestClient_Dial(t *testing.T) {
 	}
 }
 
+func TestClient_ChangeClient(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ChangeClient(time.Second); err == nil {
+		t.Errorf("ChangeClient() err = <nil>; want command not supported")
+	}
+}
+
+func TestClient_ResetConnection(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "0f3c6a1e-54d6-4d2b-9a57-3f3a9c1d2e4b", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	// A transaction that never completed.
+	if err := c.subscribe(c.TransactionId()+1, make(chan []byte)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ResetConnection(); err != nil {
+		t.Fatalf("ResetConnection() err = %s; want <nil>", err)
+	}
+	if got, want := c.TransactionId(), ptp.TransactionID(1); got != want {
+		t.Errorf("TransactionId() got = %d; want %d", got, want)
+	}
+	if _, err := c.GetDeviceInfo(); err != nil {
+		t.Errorf("GetDeviceInfo() err = %s; want <nil>", err)
+	}
+}
+
+func TestClient_CloseSession(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "bbd5a8d7-a2b8-4d10-9b1e-3d4f0f4d2b61", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.CloseSession(); err != NotConnectedError {
+		t.Errorf("CloseSession() err = %v; want %s", err, NotConnectedError)
+	}
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CloseSession(); err != nil {
+		t.Errorf("CloseSession() err = %s; want <nil>", err)
+	}
+}
+
+func TestClient_Shutdown(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "testèr", "5c1f3e2a-8d47-4b6e-a1c9-7e2d4f6b8a13", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Shutdown(); err != nil {
+		t.Errorf("Shutdown() err = %s; want <nil>", err)
+	}
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Shutdown(); err != nil {
+		t.Errorf("Shutdown() err = %s; want <nil>", err)
+	}
+	if c.CommandDataConn != nil {
+		t.Errorf("Shutdown() CommandDataConn = %v; want <nil>", c.CommandDataConn)
+	}
+	if c.EventListenerRunning() {
+		t.Error("Shutdown() event listener still running; want it stopped")
+	}
+	select {
+	case _, ok := <-c.EventPayloadChan:
+		if ok {
+			t.Error("Shutdown() EventPayloadChan open; want it closed")
+		}
+	default:
+		t.Error("Shutdown() EventPayloadChan open; want it closed")
+	}
+}
+
 func TestClient_GetDeviceInfo(t *testing.T) {
 	c, err := NewClient(DefaultVendor, address, okPort, "tèster", "558acd44-f794-4b26-9129-d460b2a29e8d", logLevel)
 	defer c.Close()
@@ -462,3 +881,372 @@ func TestClient_GetDeviceInfo(t *testing.T) {
 		t.Errorf("GetDeviceInfo() got = %v; want *ip.OperationResponsePacket", got)
 	}
 }
+
+func TestClient_reconnectStream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+
+	c, err := NewClient(DefaultVendor, "127.0.0.1", port, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetStreamReconnectBackoff(time.Millisecond)
+	c.StreamEventChan = make(chan StreamEvent, 5)
+	c.closeStreamChan = make(chan struct{})
+
+	if !c.reconnectStream(io.EOF) {
+		t.Fatal("reconnectStream() got = false; want true")
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if e := <-c.StreamEventChan; e.Type != StreamLost || e.Err != io.EOF {
+		t.Errorf("reconnectStream() event = %v; want %s with error %v", e, StreamLost, io.EOF)
+	}
+	if e := <-c.StreamEventChan; e.Type != StreamResumed || e.Attempts != 1 {
+		t.Errorf("reconnectStream() event = %v; want %s after 1 attempt", e, StreamResumed)
+	}
+	if c.streamConn == nil {
+		t.Error("reconnectStream() streamConn = nil; want connection")
+	}
+
+	// Reconnecting must stop once the streamer is closed, even when the Responder cannot be reached.
+	ln.Close()
+	done := make(chan bool)
+	go func() {
+		done <- c.reconnectStream(io.EOF)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(c.closeStreamChan)
+	select {
+	case got := <-done:
+		if got {
+			t.Error("reconnectStream() got = true; want false")
+		}
+	case <-time.After(time.Second):
+		t.Error("reconnectStream() did not return after closing the streamer")
+	}
+
+	c.SetStreamReconnectBackoff(0)
+	c.closeStreamChan = make(chan struct{})
+	if c.reconnectStream(io.EOF) {
+		t.Error("reconnectStream() got = true with reconnecting disabled; want false")
+	}
+}
+
+func TestCaptureError_Error(t *testing.T) {
+	err := error(&CaptureError{Err: NotConnectedError})
+	want := "capture failed: not connected"
+	if got := err.Error(); got != want {
+		t.Errorf("CaptureError.Error() got = %s; want %s", got, want)
+	}
+	if !errors.Is(err, NotConnectedError) {
+		t.Errorf("errors.Is() got = false; want true")
+	}
+
+	err = &CaptureError{Released: true, Err: WaitForEventError}
+	want = "capture failed after releasing the shutter: timeout reached when waiting for event"
+	if got := err.Error(); got != want {
+		t.Errorf("CaptureError.Error() got = %s; want %s", got, want)
+	}
+}
+
+// newDataOutResponder starts a responder recording the type and the payload size, excluding the transaction ID, of each
+// packet of the data-out phase it receives. The transaction is ended with an operation response after receiving the EndDataPacket or the CancelPacket.
+func newDataOutResponder(t *testing.T) (uint16, <-chan [2]int) {
+	ln, err := net.Listen("tcp", address+":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan [2]int, 10)
+	go func() {
+		evtChan := make(chan uint32, 10)
+		lmp := "[Mocked data-out responder]"
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				h, _, err := readMessage(conn, lmp)
+				if err != nil {
+					return
+				}
+				if h.PacketType == PKT_InitEventRequest {
+					_, res := genericInitEventRequestResponse()
+					sendMessage(conn, res, nil, lmp)
+					handleGenericMessages(conn, evtChan, lmp)
+					return
+				}
+
+				_, res := genericInitCommandRequestResponse(lmp, PV_VersionOnePointZero)
+				sendMessage(conn, res, nil, lmp)
+				for {
+					_, b, err := readMessageRaw(conn, lmp)
+					if err != nil {
+						return
+					}
+					pt := PacketType(binary.LittleEndian.Uint32(b[0:4]))
+					if pt == PKT_OperationRequest {
+						continue
+					}
+					received <- [2]int{int(pt), len(b) - 8}
+
+					rc := ptp.RC_OK
+					switch pt {
+					case PKT_Cancel:
+						rc = ptp.RC_TransactionCancelled
+					case PKT_EndData:
+					default:
+						continue
+					}
+					sendMessage(conn, &OperationResponsePacket{
+						OperationResponse: ptp.OperationResponse{
+							ResponseCode:  rc,
+							TransactionID: ptp.TransactionID(binary.LittleEndian.Uint32(b[4:8])),
+						},
+					}, nil, lmp)
+				}
+			}(conn)
+		}
+	}()
+
+	return uint16(ln.Addr().(*net.TCPAddr).Port), received
+}
+
+func TestClient_SendDataContext(t *testing.T) {
+	port, received := newDataOutResponder(t)
+
+	c, err := NewClient(DefaultVendor, address, port, "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetDataChunkSize(4)
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	res, err := c.SendDataContext(context.Background(), ptp.OC_SendObject, nil, data, uint64(len(data)))
+	if err != nil {
+		t.Errorf("SendDataContext() err = %s; want <nil>", err)
+	}
+	if rc, _ := c.ResponseCode(res); rc != ptp.RC_OK {
+		t.Errorf("SendDataContext() response code = %#x; want %#x", rc, ptp.RC_OK)
+	}
+
+	want := [][2]int{{int(PKT_StartData), 8}, {int(PKT_Data), 4}, {int(PKT_Data), 4}, {int(PKT_EndData), 2}}
+	for _, w := range want {
+		if got := <-received; got != w {
+			t.Errorf("SendDataContext() packet type and payload size = %v; want %v", got, w)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.SendDataContext(ctx, ptp.OC_SendObject, nil, data, uint64(len(data))); !errors.Is(err, context.Canceled) {
+		t.Errorf("SendDataContext() err = %v; want %s", err, context.Canceled)
+	}
+
+	want = [][2]int{{int(PKT_StartData), 8}, {int(PKT_Cancel), 0}}
+	for _, w := range want {
+		if got := <-received; got != w {
+			t.Errorf("SendDataContext() packet type and payload size = %v; want %v", got, w)
+		}
+	}
+}
+
+func TestClient_InitiateCapture(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.InitiateCapture()
+	var ce *CaptureError
+	if !errors.As(err, &ce) {
+		t.Fatalf("InitiateCapture() error = %v; want *CaptureError", err)
+	}
+	if ce.Released {
+		t.Errorf("InitiateCapture() Released = true; want false")
+	}
+}
+
+func TestClient_InitiateCapture_virtualCamera(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, virtualPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	want, err := os.ReadFile("testdata/preview.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		got, err := c.InitiateCapture()
+		if err != nil {
+			t.Fatalf("InitiateCapture() error = %s; want <nil>", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("InitiateCapture() got %d bytes; want %d bytes", len(got), len(want))
+		}
+	}
+}
+
+func TestClient_responderFaults(t *testing.T) {
+	dial := func(t *testing.T, port uint16) *Client {
+		c, err := NewClient(DefaultVendor, address, port, "testér", "", logLevel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		if err := c.Dial(); err != nil {
+			t.Fatalf("Dial() error = %s; want <nil>", err)
+		}
+		return c
+	}
+
+	t.Run("InitFail", func(t *testing.T) {
+		t.Parallel()
+		port := startFaultyVirtualCamera(t, responderFaults{InitFail: 1})
+		c, err := NewClient(DefaultVendor, address, port, "testér", "", logLevel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Dial(); err == nil {
+			t.Errorf("Dial() error = <nil>; want InitFail error")
+		}
+		c.Close()
+		dial(t, port)
+	})
+
+	t.Run("DropResponses", func(t *testing.T) {
+		t.Parallel()
+		c := dial(t, startFaultyVirtualCamera(t, responderFaults{DropResponses: 1}))
+		if _, err := c.GetObject(1); !IsTimeout(err) {
+			t.Errorf("GetObject() error = %v; want timeout", err)
+		}
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		t.Parallel()
+		delay := 200 * time.Millisecond
+		c := dial(t, startFaultyVirtualCamera(t, responderFaults{Delay: delay}))
+		start := time.Now()
+		if _, err := c.InitiateCapture(); err != nil {
+			t.Fatalf("InitiateCapture() error = %s; want <nil>", err)
+		}
+		if got := time.Since(start); got < 2*delay {
+			t.Errorf("InitiateCapture() took %s; want at least %s", got, 2*delay)
+		}
+	})
+
+	t.Run("TruncateData", func(t *testing.T) {
+		t.Parallel()
+		c := dial(t, startFaultyVirtualCamera(t, responderFaults{TruncateData: 10}))
+		_, err := c.InitiateCapture()
+		var ce *CaptureError
+		if !errors.As(err, &ce) || !ce.Released || !errors.Is(err, TruncatedDataError) {
+			t.Errorf("InitiateCapture() error = %v; want released *CaptureError wrapping %s", err, TruncatedDataError)
+		}
+	})
+
+	t.Run("ResetAfter", func(t *testing.T) {
+		t.Parallel()
+		c := dial(t, startFaultyVirtualCamera(t, responderFaults{ResetAfter: 100}))
+		if _, err := c.InitiateCapture(); err == nil {
+			t.Errorf("InitiateCapture() error = <nil>; want error")
+		}
+	})
+}
+
+func BenchmarkClient_sendPacket(b *testing.B) {
+	c, err := NewClient(DefaultVendor, address, okPort, "bènch", "", LevelSilent)
+	if err != nil {
+		b.Fatal(err)
+	}
+	p := &OperationRequestPacket{
+		DataPhaseInfo: DP_NoDataOrDataIn,
+		OperationRequest: ptp.OperationRequest{
+			OperationCode: ptp.OC_GetDevicePropValue,
+			TransactionID: 3,
+			Parameters:    []uint32{uint32(ptp.DPC_BatteryLevel)},
+		},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := c.sendPacket(cmdDataConnection, io.Discard, p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_sendPacket_data(b *testing.B) {
+	c, err := NewClient(DefaultVendor, address, okPort, "bènch", "", LevelSilent)
+	if err != nil {
+		b.Fatal(err)
+	}
+	p := &EndDataPacket{TransactionId: 3, DataPayload: make([]byte, 64*1024)}
+	b.SetBytes(int64(len(p.DataPayload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := c.sendPacket(cmdDataConnection, io.Discard, p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_readResponse(b *testing.B) {
+	c, err := NewClient(DefaultVendor, address, okPort, "bènch", "", LevelSilent)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// A DevicePropChanged event.
+	r := &repeatReader{p: []byte{
+		0x12, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x06, 0x40, 0xff, 0xff, 0xff, 0xff, 0x01, 0x50, 0x00, 0x00,
+	}}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := c.readResponse(r, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_readRawResponse(b *testing.B) {
+	c := &Client{}
+	// The response to GetDevicePropValue for the battery level, as read when polling a property.
+	r := &repeatReader{p: []byte{
+		0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x01, 0x20, 0x03, 0x00, 0x00, 0x00,
+	}}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := c.readRawResponse(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+package ip
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// FrameMeta holds the information that accompanies a single liveview frame.
+type FrameMeta struct {
+	// Sequence is incremented for each frame received on the streamer connection, starting at 1.
+	Sequence uint64
+	// Received is the time at which the frame was read from the streamer connection.
+	Received time.Time
+	// Counter is the frame counter as reported by the Responder. Not all vendors provide one.
+	Counter uint32
+	// Skipped is the number of frames sent by the Responder since the previous frame that never reached us. It is
+	// derived from Counter and will always be 0 when the vendor does not provide a counter.
+	Skipped int
+	// Header holds the raw vendor specific header that preceded the JPEG data, excluding the packet length.
+	Header []byte
+}
+
+// LiveviewFrame is a single liveview JPEG frame together with its metadata.
+type LiveviewFrame struct {
+	Data []byte
+	Meta FrameMeta
+	buf  *frameBuffer
+}
+
+// Release hands the memory backing the frame back to the client so it can be reused for the frames to come, which
+// reduces the pressure on the garbage collector considerably. Call Release at most once and only when done with the
+// frame: the Data and Meta.Header slices must not be used afterwards. Not releasing a frame is perfectly safe, the
+// memory will be garbage collected instead.
+func (f LiveviewFrame) Release() {
+	f.buf.release()
+}
+
+// StreamEventType defines the state changes of the streamer connection reported on the StreamEventChan.
+type StreamEventType int
+
+const (
+	// StreamLost is sent when reading from the streamer connection fails. Reconnecting is attempted right away.
+	StreamLost StreamEventType = iota
+	// StreamResumed is sent when the streamer connection has been restored after it was lost.
+	StreamResumed
+)
+
+func (t StreamEventType) String() string {
+	switch t {
+	case StreamLost:
+		return "stream lost"
+	case StreamResumed:
+		return "stream resumed"
+	}
+
+	return "unknown stream event"
+}
+
+// StreamEvent reports a state change of the streamer connection.
+type StreamEvent struct {
+	Type StreamEventType
+	// Attempts holds the number of attempts it took to restore the connection for a StreamResumed event.
+	Attempts int
+	// Err holds the error that caused the connection to be lost for a StreamLost event.
+	Err error
+}
+
+// DropPolicy defines what happens when a frame is published while the buffer of a FrameSubscription is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the frame being published, keeping the frames already buffered.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest buffered frame to make room for the frame being published. This is what a
+	// consumer displaying the frames wants: it will always get the most recent frame.
+	DropOldest
+	// Block waits until the consumer has made room in the buffer. Use with care: a slow consumer will delay the
+	// frames for all other consumers and ultimately stall the streamer connection.
+	Block
+)
+
+// FrameSubscription is a single consumer of the liveview frames. Each subscription has its own buffer and DropPolicy
+// so that a slow consumer does not affect the others, unless it uses the Block policy.
+type FrameSubscription struct {
+	ch      chan LiveviewFrame
+	policy  DropPolicy
+	dropped uint64
+	skipped uint64
+	minGap  time.Duration
+	every   uint64
+	count   uint64
+	last    time.Time
+	done    chan struct{}
+	closed  bool
+	once    sync.Once
+	mu      sync.Mutex
+	b       *frameBroadcaster
+}
+
+// Frames returns the channel receiving the frames. The channel is closed when the subscription is closed or when the
+// liveview is disabled.
+func (s *FrameSubscription) Frames() <-chan LiveviewFrame {
+	return s.ch
+}
+
+// Dropped returns the number of frames that were dropped for this subscription because its buffer was full.
+func (s *FrameSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Skipped returns the number of frames that were skipped for this subscription due to SetMaxFPS or SetDecimation.
+func (s *FrameSubscription) Skipped() uint64 {
+	return atomic.LoadUint64(&s.skipped)
+}
+
+// SetMaxFPS limits the number of frames delivered to this subscription per second. Excess frames are skipped before
+// they reach the consumer, saving the CPU cycles needed to decode them. Use this for consumers that do not need the
+// full frame rate, such as a histogram. Set to 0 to disable.
+func (s *FrameSubscription) SetMaxFPS(fps float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.minGap = 0
+	if fps > 0 {
+		s.minGap = time.Duration(float64(time.Second) / fps)
+	}
+}
+
+// SetDecimation only delivers every nth frame to this subscription. Set to 0 or 1 to deliver all frames.
+func (s *FrameSubscription) SetDecimation(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.every = 0
+	if n > 1 {
+		s.every = uint64(n)
+	}
+	s.count = 0
+}
+
+// Close unsubscribes from the liveview frames and closes the frames channel. It is safe to call Close multiple times.
+func (s *FrameSubscription) Close() {
+	s.b.unsubscribe(s)
+	s.close()
+}
+
+func (s *FrameSubscription) close() {
+	s.once.Do(func() {
+		// Unblock a pending delivery first, otherwise we might never get hold of the lock.
+		close(s.done)
+
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// deliver hands the frame to the consumer, applying the drop policy when the buffer is full. It returns false when the
+// frame did not make it to the consumer.
+func (s *FrameSubscription) deliver(f LiveviewFrame) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.skip(f.Meta.Received) {
+		return false
+	}
+
+	select {
+	case s.ch <- f:
+		return true
+	default:
+	}
+
+	switch s.policy {
+	case DropOldest:
+		select {
+		case old := <-s.ch:
+			old.Release()
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+		}
+		select {
+		case s.ch <- f:
+			return true
+		default:
+		}
+	case Block:
+		select {
+		case s.ch <- f:
+			return true
+		case <-s.done:
+			return false
+		}
+	}
+
+	atomic.AddUint64(&s.dropped, 1)
+
+	return false
+}
+
+// skip returns true when the frame must not be delivered to honour the maximum frame rate or decimation.
+func (s *FrameSubscription) skip(received time.Time) bool {
+	if s.every > 0 {
+		s.count++
+		if s.count%s.every != 1 {
+			atomic.AddUint64(&s.skipped, 1)
+			return true
+		}
+	}
+
+	if s.minGap > 0 {
+		if !s.last.IsZero() && received.Sub(s.last) < s.minGap {
+			atomic.AddUint64(&s.skipped, 1)
+			return true
+		}
+		s.last = received
+	}
+
+	return false
+}
+
+// frameBroadcaster fans out the frames received on the single streamer connection to any number of consumers.
+type frameBroadcaster struct {
+	handlers []func([]byte, FrameMeta)
+	subs     map[*FrameSubscription]struct{}
+	seq      uint64
+	latest   LiveviewFrame
+	mu       sync.Mutex
+}
+
+func newFrameBroadcaster() *frameBroadcaster {
+	return &frameBroadcaster{
+		subs: make(map[*FrameSubscription]struct{}),
+	}
+}
+
+func (b *frameBroadcaster) subscribe(buffer int, policy DropPolicy) *FrameSubscription {
+	s := &FrameSubscription{
+		ch:     make(chan LiveviewFrame, buffer),
+		policy: policy,
+		done:   make(chan struct{}),
+		b:      b,
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+func (b *frameBroadcaster) unsubscribe(s *FrameSubscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+func (b *frameBroadcaster) addHandler(f func([]byte, FrameMeta)) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, f)
+	b.mu.Unlock()
+}
+
+// publish fills in the sequence number and the time received when they have not been set by the caller and hands the
+// frame to all subscriptions and handlers. Each subscription receiving the frame holds a reference to buf, which may
+// be nil when the frame is not backed by a pooled buffer. The meta data as sent to the consumers is returned.
+func (b *frameBroadcaster) publish(buf *frameBuffer, frame []byte, meta FrameMeta) FrameMeta {
+	b.mu.Lock()
+	b.seq++
+	if meta.Sequence == 0 {
+		meta.Sequence = b.seq
+	}
+	if meta.Received.IsZero() {
+		meta.Received = time.Now()
+	}
+	handlers := b.handlers
+	subs := make([]*FrameSubscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	f := LiveviewFrame{Data: frame, Meta: meta, buf: buf}
+	// Hold on to the most recent frame for snapshots.
+	buf.retain()
+	prev := b.latest
+	b.latest = f
+	b.mu.Unlock()
+	prev.Release()
+
+	for _, s := range subs {
+		buf.retain()
+		if !s.deliver(f) {
+			buf.release()
+		}
+	}
+	for _, h := range handlers {
+		h(frame, meta)
+	}
+
+	return meta
+}
+
+// snapshot returns a copy of the most recent frame.
+func (b *frameBroadcaster) snapshot() (LiveviewFrame, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.latest.Data == nil {
+		return LiveviewFrame{}, false
+	}
+
+	f := LiveviewFrame{Data: make([]byte, len(b.latest.Data)), Meta: b.latest.Meta}
+	copy(f.Data, b.latest.Data)
+	f.Meta.Header = append([]byte(nil), b.latest.Meta.Header...)
+
+	return f, true
+}
+
+// closeAll closes all subscriptions, forgets the most recent frame and resets the sequence number.
+func (b *frameBroadcaster) closeAll() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[*FrameSubscription]struct{})
+	b.seq = 0
+	latest := b.latest
+	b.latest = LiveviewFrame{}
+	b.mu.Unlock()
+
+	latest.Release()
+
+	for s := range subs {
+		s.close()
+	}
+}
+
+// OnLiveviewFrame registers a function that will be called for each liveview frame received on the streamer
+// connection. The function is called from the stream listener so it must return quickly: any lengthy processing must
+// be done elsewhere or frames will be delayed for all consumers. The frame data must not be modified nor used after the
+// function returns since its memory will be reused: make a copy when the data is needed later on.
+func (c *Client) OnLiveviewFrame(f func(frame []byte, meta FrameMeta)) {
+	c.frames.addHandler(f)
+}
+
+// SubscribeLiveview returns a new subscription receiving each liveview frame received on the streamer connection.
+// The buffer size and DropPolicy determine how the subscription copes with frames coming in faster than it can
+// process them. The subscription is closed when the liveview is disabled, so a new call is required after enabling
+// it again. Call Close on the subscription when no longer interested in the frames.
+func (c *Client) SubscribeLiveview(buffer int, policy DropPolicy) *FrameSubscription {
+	return c.frames.subscribe(buffer, policy)
+}
+
+// LiveviewFrames returns a channel that will receive each liveview frame received on the streamer connection. When the
+// channel is full, new frames are dropped until the consumer catches up. The channel is closed when the liveview is
+// disabled, so a new call is required after enabling it again. Use SubscribeLiveview for more control.
+func (c *Client) LiveviewFrames(buffer int) <-chan LiveviewFrame {
+	return c.SubscribeLiveview(buffer, DropNewest).Frames()
+}
+
+// LiveviewSnapshot returns a copy of the most recent liveview frame, which is safe to keep around. This does not
+// trigger the shutter in any way. NoLiveviewFrameError is returned when the liveview is disabled or no frame has been
+// received yet.
+func (c *Client) LiveviewSnapshot() (LiveviewFrame, error) {
+	if f, ok := c.frames.snapshot(); ok {
+		return f, nil
+	}
+
+	return LiveviewFrame{}, NoLiveviewFrameError
+}
+
+// SetLiveviewZoom drives the native liveview magnification of the camera, which gives a far more detailed view than
+// cropping the liveview frames. The level starts at 0 for no magnification and is clamped to the highest level the
+// camera supports, which is returned. The magnification a level stands for is vendor specific. When the vendor has no
+// known way of magnifying the liveview, NoNativeZoomError is returned.
+func (c *Client) SetLiveviewZoom(level int) (int, error) {
+	if level < 0 {
+		level = 0
+	}
+
+	return c.vendorExtensions.setLiveviewZoom(c, level)
+}
+
+// publishFrame hands a liveview frame over to all registered consumers. Vendor implementations processing the stream
+// data must use this method, or publishBufferedFrame, to deliver the frames. The sequence number and the time received
+// will be set when they have not been filled in by the caller.
+func (c *Client) publishFrame(frame []byte, meta FrameMeta) {
+	c.publishBufferedFrame(nil, frame, meta)
+}
+
+// publishBufferedFrame hands a liveview frame backed by a pooled buffer over to all registered consumers. The frame
+// and the meta data header must be slices of buf. The reference held by the caller is released, so buf must not be
+// used after calling this method.
+func (c *Client) publishBufferedFrame(buf *frameBuffer, frame []byte, meta FrameMeta) {
+	meta = c.frames.publish(buf, frame, meta)
+
+	select {
+	case c.StreamChan <- frame:
+		// There is no way of knowing when the receiving end is done with the frame, so it never goes back to the
+		// pool: take a reference that will never be released.
+		buf.retain()
+	default:
+		c.Debugf("[publishFrame] stream channel full, dropping frame %d", meta.Sequence)
+	}
+
+	buf.release()
+}
+
+// LiveviewPollInterval is the time to wait between two requests for a liveview frame for vendors that deliver them over
+// the command/data connection, limiting the liveview to about 25 frames per second. This leaves room on the
+// command/data connection for the other operations.
+const LiveviewPollInterval = 40 * time.Millisecond
+
+// liveviewPoller requests a single liveview frame from the Responder. It returns a nil frame when the Responder has no
+// new frame available yet.
+type liveviewPoller func(*Client) ([]byte, FrameMeta, error)
+
+// startLiveviewPoller is used by vendors delivering the liveview frames over the command/data connection rather than a
+// separate streamer connection. It calls poll at a LiveviewPollInterval and publishes the frames it returns, so the
+// consumers cannot tell the difference. Polling stops when stopLiveviewPoller is called or when the Responder tells us
+// it does not support the operation. Calling this method while the poller is running does nothing.
+func (c *Client) startLiveviewPoller(lmp string, poll liveviewPoller) {
+	if c.closeLiveviewPoll != nil {
+		return
+	}
+
+	c.StreamChan = make(chan []byte, 50)
+	c.StreamEventChan = make(chan StreamEvent, 5)
+	stop, done := make(chan struct{}), make(chan struct{})
+	c.closeLiveviewPoll = stop
+	c.liveviewPollDone = done
+
+	go func() {
+		defer close(done)
+		c.Infof("%s polling for liveview frames...", lmp)
+		for {
+			select {
+			case <-stop:
+				c.Infof("%s stopping liveview poller.", lmp)
+				close(c.StreamChan)
+				c.StreamChan = nil
+				return
+			case <-time.After(LiveviewPollInterval):
+				frame, meta, err := poll(c)
+				if err != nil {
+					var ore *ptp.OperationResponseError
+					if errors.As(err, &ore) && ore.Code == ptp.RC_OperationNotSupported {
+						c.Warnf("%s %s, no liveview available.", lmp, err)
+						// Wait for the poller to be stopped to close down cleanly.
+						<-stop
+						continue
+					}
+					c.Debugf("%s %s", lmp, err)
+					continue
+				}
+				if frame == nil {
+					continue
+				}
+				c.Debugf("%s Frame length %d", lmp, len(frame))
+
+				c.publishFrame(frame, meta)
+			}
+		}
+	}()
+}
+
+// stopLiveviewPoller stops the liveview poller and closes all frame subscriptions. It waits for a poll in progress to
+// finish, so that the poller is done with StreamChan before a new one can be started. It does nothing when the poller
+// is not running.
+func (c *Client) stopLiveviewPoller() {
+	if c.closeLiveviewPoll == nil {
+		return
+	}
+
+	close(c.closeLiveviewPoll)
+	<-c.liveviewPollDone
+	c.closeLiveviewPoll = nil
+	c.liveviewPollDone = nil
+	c.closeFrameSubscriptions()
+}
+
+// closeFrameSubscriptions closes all subscriptions handed out by SubscribeLiveview and LiveviewFrames.
+func (c *Client) closeFrameSubscriptions() {
+	c.frames.closeAll()
+}
@@ -0,0 +1,315 @@
+package ip
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestClient_OnLiveviewFrame(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		got  []byte
+		meta FrameMeta
+	)
+	c.OnLiveviewFrame(func(frame []byte, m FrameMeta) {
+		got = frame
+		meta = m
+	})
+
+	want := []byte{0xff, 0xd8, 0xff, 0xd9}
+	c.publishFrame(want, FrameMeta{})
+	c.publishFrame(want, FrameMeta{})
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("OnLiveviewFrame() frame = %#v; want %#v", got, want)
+	}
+	if meta.Sequence != 2 {
+		t.Errorf("OnLiveviewFrame() Sequence = %d; want 2", meta.Sequence)
+	}
+	if meta.Received.IsZero() {
+		t.Error("OnLiveviewFrame() Received is zero; want current time")
+	}
+}
+
+func TestClient_LiveviewFrames(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := c.LiveviewFrames(1)
+	c.publishFrame([]byte{0x01}, FrameMeta{})
+	// The channel is full, so this one must be dropped.
+	c.publishFrame([]byte{0x02}, FrameMeta{})
+
+	got := <-ch
+	if !bytes.Equal(got.Data, []byte{0x01}) {
+		t.Errorf("LiveviewFrames() frame = %#v; want %#v", got.Data, []byte{0x01})
+	}
+	if got.Meta.Sequence != 1 {
+		t.Errorf("LiveviewFrames() Sequence = %d; want 1", got.Meta.Sequence)
+	}
+
+	c.closeFrameSubscriptions()
+	if _, ok := <-ch; ok {
+		t.Error("LiveviewFrames() channel still open; want closed")
+	}
+}
+
+func TestClient_SubscribeLiveview(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newest := c.SubscribeLiveview(1, DropNewest)
+	oldest := c.SubscribeLiveview(1, DropOldest)
+	c.publishFrame([]byte{0x01}, FrameMeta{})
+	c.publishFrame([]byte{0x02}, FrameMeta{})
+
+	if got := <-newest.Frames(); !bytes.Equal(got.Data, []byte{0x01}) {
+		t.Errorf("SubscribeLiveview() DropNewest frame = %#v; want %#v", got.Data, []byte{0x01})
+	}
+	if got := <-oldest.Frames(); !bytes.Equal(got.Data, []byte{0x02}) {
+		t.Errorf("SubscribeLiveview() DropOldest frame = %#v; want %#v", got.Data, []byte{0x02})
+	}
+	if got := newest.Dropped(); got != 1 {
+		t.Errorf("Dropped() got = %d; want 1", got)
+	}
+	if got := oldest.Dropped(); got != 1 {
+		t.Errorf("Dropped() got = %d; want 1", got)
+	}
+
+	newest.Close()
+	newest.Close()
+	if _, ok := <-newest.Frames(); ok {
+		t.Error("Close() channel still open; want closed")
+	}
+	c.publishFrame([]byte{0x03}, FrameMeta{})
+	if got := <-oldest.Frames(); !bytes.Equal(got.Data, []byte{0x03}) {
+		t.Errorf("SubscribeLiveview() frame after Close() = %#v; want %#v", got.Data, []byte{0x03})
+	}
+}
+
+func TestClient_SubscribeLiveviewBlock(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := c.SubscribeLiveview(1, Block)
+	c.publishFrame([]byte{0x01}, FrameMeta{})
+
+	done := make(chan struct{})
+	go func() {
+		c.publishFrame([]byte{0x02}, FrameMeta{})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("publishFrame() returned; want it to block until the consumer catches up")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-sub.Frames()
+	if got := <-sub.Frames(); !bytes.Equal(got.Data, []byte{0x02}) {
+		t.Errorf("SubscribeLiveview() Block frame = %#v; want %#v", got.Data, []byte{0x02})
+	}
+	<-done
+	if got := sub.Dropped(); got != 0 {
+		t.Errorf("Dropped() got = %d; want 0", got)
+	}
+
+	// Closing the subscription must release a blocked publisher.
+	c.publishFrame([]byte{0x03}, FrameMeta{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		sub.Close()
+	}()
+	c.publishFrame([]byte{0x04}, FrameMeta{})
+}
+
+func TestFrameSubscription_SetMaxFPS(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := c.SubscribeLiveview(10, DropNewest)
+	sub.SetMaxFPS(5)
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		// 20 frames per second.
+		c.publishFrame([]byte{byte(i)}, FrameMeta{Received: start.Add(time.Duration(i) * 50 * time.Millisecond)})
+	}
+	sub.Close()
+
+	var got []byte
+	for f := range sub.Frames() {
+		got = append(got, f.Data...)
+	}
+	if want := []byte{0, 4, 8}; !bytes.Equal(got, want) {
+		t.Errorf("SetMaxFPS() frames = %#v; want %#v", got, want)
+	}
+	if got := sub.Skipped(); got != 7 {
+		t.Errorf("Skipped() got = %d; want 7", got)
+	}
+}
+
+func TestFrameSubscription_SetDecimation(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := c.SubscribeLiveview(10, DropNewest)
+	sub.SetDecimation(3)
+	for i := 0; i < 7; i++ {
+		c.publishFrame([]byte{byte(i)}, FrameMeta{})
+	}
+	sub.Close()
+
+	var got []byte
+	for f := range sub.Frames() {
+		got = append(got, f.Data...)
+	}
+	if want := []byte{0, 3, 6}; !bytes.Equal(got, want) {
+		t.Errorf("SetDecimation() frames = %#v; want %#v", got, want)
+	}
+}
+
+func TestClient_SetLiveviewZoom(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.SetLiveviewZoom(2); err != NoNativeZoomError {
+		t.Errorf("SetLiveviewZoom() error = %v; want %v", err, NoNativeZoomError)
+	}
+}
+
+func TestClient_LiveviewSnapshot(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.LiveviewSnapshot(); err != NoLiveviewFrameError {
+		t.Errorf("LiveviewSnapshot() error = %v; want %v", err, NoLiveviewFrameError)
+	}
+
+	c.publishFrame([]byte{0x01}, FrameMeta{})
+	frame := []byte{0x02}
+	c.publishFrame(frame, FrameMeta{})
+
+	got, err := c.LiveviewSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data, frame) {
+		t.Errorf("LiveviewSnapshot() frame = %#v; want %#v", got.Data, frame)
+	}
+	if got.Meta.Sequence != 2 {
+		t.Errorf("LiveviewSnapshot() Sequence = %d; want 2", got.Meta.Sequence)
+	}
+	// The snapshot must be a copy.
+	frame[0] = 0x03
+	if got.Data[0] != 0x02 {
+		t.Error("LiveviewSnapshot() returned the original frame data; want a copy")
+	}
+
+	c.closeFrameSubscriptions()
+	if _, err := c.LiveviewSnapshot(); err != NoLiveviewFrameError {
+		t.Errorf("LiveviewSnapshot() error = %v; want %v", err, NoLiveviewFrameError)
+	}
+}
+
+func TestClient_startLiveviewPoller(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{0xff, 0xd8, 0xff, 0xd9}
+	var polls int
+	c.startLiveviewPoller("[testPoller]", func(_ *Client) ([]byte, FrameMeta, error) {
+		// Only every other poll returns a frame, just like a Responder that has no new frame ready yet.
+		if polls++; polls%2 == 1 {
+			return nil, FrameMeta{}, nil
+		}
+		return want, FrameMeta{}, nil
+	})
+	sub := c.SubscribeLiveview(1, DropNewest)
+
+	select {
+	case f := <-sub.Frames():
+		if !bytes.Equal(f.Data, want) {
+			t.Errorf("startLiveviewPoller() frame = %#v; want %#v", f.Data, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("startLiveviewPoller() no frame received")
+	}
+
+	c.stopLiveviewPoller()
+	// The subscription must be closed, possibly after delivering a frame that was still buffered.
+	for range sub.Frames() {
+	}
+	if c.closeLiveviewPoll != nil {
+		t.Error("stopLiveviewPoller() poller still running")
+	}
+	// Stopping again must not panic.
+	c.stopLiveviewPoller()
+}
+
+func TestClient_stopLiveviewPoller(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	polling, release := make(chan struct{}, 1), make(chan struct{})
+	c.startLiveviewPoller("[testPoller]", func(_ *Client) ([]byte, FrameMeta, error) {
+		select {
+		case polling <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil, FrameMeta{}, nil
+	})
+	<-polling
+
+	// A poll in progress must finish before stopLiveviewPoller returns.
+	stopped := make(chan struct{})
+	go func() {
+		c.stopLiveviewPoller()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("stopLiveviewPoller() returned while polling")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	if c.StreamChan != nil {
+		t.Error("stopLiveviewPoller() StreamChan not cleared")
+	}
+
+	// Restarting right away must leave the new poller untouched.
+	c.startLiveviewPoller("[testPoller]", func(_ *Client) ([]byte, FrameMeta, error) {
+		return nil, FrameMeta{}, nil
+	})
+	time.Sleep(2 * LiveviewPollInterval)
+	if c.StreamChan == nil {
+		t.Error("startLiveviewPoller() StreamChan cleared by the previous poller")
+	}
+	c.stopLiveviewPoller()
+}
@@ -1,9 +1,17 @@
 package ip
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -50,6 +58,70 @@ func (l *LogLevel) String() string {
 	return ""
 }
 
+// Enabled returns true when log entries of the given severity are output at this log level. Fatal entries are always
+// output.
+func (l LogLevel) Enabled(s Severity) bool {
+	switch s {
+	case SeverityDebug:
+		return l >= LevelDebug
+	case SeverityInfo:
+		return l >= LevelVeryVerbose
+	case SeverityWarn:
+		return l >= LevelVerbose
+	case SeverityError:
+		return l > LevelSilent
+	}
+
+	return true
+}
+
+const (
+	SeverityDebug Severity = iota
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityFatal
+)
+
+// Severity is the severity of a single log entry.
+type Severity byte
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityDebug:
+		return "debug"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarn:
+		return "warn"
+	case SeverityError:
+		return "error"
+	case SeverityFatal:
+		return "fatal"
+	}
+
+	return "unknown"
+}
+
+// Field is a key value pair added to a log entry. The client tags its log entries with the fields below, so that
+// diagnostics can easily be filtered.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+const (
+	// FieldVendor holds the vendor of the responder, e.g. "fuji".
+	FieldVendor = "vendor"
+	// FieldChannel holds the connection a log entry relates to: "cmd", "event" or "stream".
+	FieldChannel = "channel"
+	// FieldTransactionId holds the ID of the transaction a log entry relates to.
+	FieldTransactionId = "tid"
+	// FieldSession holds the ID of the session a log entry relates to. It is only added for the sessions opened using
+	// Client.NewSession().
+	FieldSession = "session"
+)
+
 // Logger is the interface allowing you to create a custom logger.
 type Logger interface {
 	Debug(v ...interface{})
@@ -69,92 +141,291 @@ type Logger interface {
 	Warnln(v ...interface{})
 }
 
-// StdLogger is the standard logger, a wrapper around the golang log package.
-type StdLogger struct {
-	level LogLevel
-	*log.Logger
+// StructuredLogger is a Logger that also accepts fields for every log entry. Custom loggers implementing this interface
+// receive the fields the client tags its log entries with as is, plain Loggers receive them appended to the message as
+// key=value pairs.
+type StructuredLogger interface {
+	Logger
+	// Enabled returns true when log entries of the given severity are output, allowing to skip formatting messages
+	// that will be discarded anyway.
+	Enabled(s Severity) bool
+	// Log outputs a single log entry. A fatal entry does not exit, this is left to the Fatal methods.
+	Log(s Severity, msg string, fields ...Field)
 }
 
-func (sl *StdLogger) Debug(v ...interface{}) {
-	if sl.level >= LevelDebug {
-		log.Print(v...)
-	}
+// logMethods implements the methods of the Logger interface on top of a StructuredLogger, so that a structured logger
+// only needs to implement Enabled() and Log().
+type logMethods struct {
+	sl StructuredLogger
 }
 
-func (sl *StdLogger) Debugf(format string, v ...interface{}) {
-	if sl.level >= LevelDebug {
-		log.Printf(format, v...)
+func (lm logMethods) print(s Severity, v ...interface{}) {
+	if lm.sl.Enabled(s) {
+		lm.sl.Log(s, fmt.Sprint(v...))
 	}
 }
 
-func (sl *StdLogger) Debugln(v ...interface{}) {
-	if sl.level >= LevelDebug {
-		log.Println(v...)
+func (lm logMethods) printf(s Severity, format string, v ...interface{}) {
+	if lm.sl.Enabled(s) {
+		lm.sl.Log(s, fmt.Sprintf(format, v...))
 	}
 }
 
-func (sl *StdLogger) Error(v ...interface{}) {
-	if sl.level > LevelSilent {
-		log.Print(v...)
+func (lm logMethods) println(s Severity, v ...interface{}) {
+	if lm.sl.Enabled(s) {
+		lm.sl.Log(s, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 	}
 }
 
-func (sl *StdLogger) Errorf(format string, v ...interface{}) {
-	if sl.level > LevelSilent {
-		log.Printf(format, v...)
-	}
+func (lm logMethods) Debug(v ...interface{}) {
+	lm.print(SeverityDebug, v...)
 }
 
-func (sl *StdLogger) Errorln(v ...interface{}) {
-	if sl.level > LevelSilent {
-		log.Println(v...)
-	}
+func (lm logMethods) Debugf(format string, v ...interface{}) {
+	lm.printf(SeverityDebug, format, v...)
 }
 
-func (sl *StdLogger) Info(v ...interface{}) {
-	if sl.level >= LevelVeryVerbose {
-		log.Print(v...)
-	}
+func (lm logMethods) Debugln(v ...interface{}) {
+	lm.println(SeverityDebug, v...)
 }
 
-func (sl *StdLogger) Infof(format string, v ...interface{}) {
-	if sl.level >= LevelVeryVerbose {
-		log.Printf(format, v...)
-	}
+func (lm logMethods) Error(v ...interface{}) {
+	lm.print(SeverityError, v...)
 }
 
-func (sl *StdLogger) Infoln(v ...interface{}) {
-	if sl.level >= LevelVeryVerbose {
-		log.Println(v...)
-	}
+func (lm logMethods) Errorf(format string, v ...interface{}) {
+	lm.printf(SeverityError, format, v...)
 }
 
-func (sl *StdLogger) Warn(v ...interface{}) {
-	if sl.level >= LevelVerbose {
-		log.Print(v...)
-	}
+func (lm logMethods) Errorln(v ...interface{}) {
+	lm.println(SeverityError, v...)
 }
 
-func (sl *StdLogger) Warnf(format string, v ...interface{}) {
-	if sl.level >= LevelVerbose {
-		log.Printf(format, v...)
-	}
+func (lm logMethods) Fatal(v ...interface{}) {
+	lm.print(SeverityFatal, v...)
+	os.Exit(1)
+}
+
+func (lm logMethods) Fatalf(format string, v ...interface{}) {
+	lm.printf(SeverityFatal, format, v...)
+	os.Exit(1)
+}
+
+func (lm logMethods) Fatalln(v ...interface{}) {
+	lm.println(SeverityFatal, v...)
+	os.Exit(1)
+}
+
+func (lm logMethods) Info(v ...interface{}) {
+	lm.print(SeverityInfo, v...)
+}
+
+func (lm logMethods) Infof(format string, v ...interface{}) {
+	lm.printf(SeverityInfo, format, v...)
+}
+
+func (lm logMethods) Infoln(v ...interface{}) {
+	lm.println(SeverityInfo, v...)
+}
+
+func (lm logMethods) Warn(v ...interface{}) {
+	lm.print(SeverityWarn, v...)
+}
+
+func (lm logMethods) Warnf(format string, v ...interface{}) {
+	lm.printf(SeverityWarn, format, v...)
+}
+
+func (lm logMethods) Warnln(v ...interface{}) {
+	lm.println(SeverityWarn, v...)
+}
+
+// StdLogger is the standard logger, a wrapper around the golang log package. Fields are appended to the message as
+// key=value pairs.
+type StdLogger struct {
+	logMethods
+	level LogLevel
+	*log.Logger
+}
+
+func (sl *StdLogger) Enabled(s Severity) bool {
+	return sl.level.Enabled(s)
 }
 
-func (sl *StdLogger) Warnln(v ...interface{}) {
-	if sl.level >= LevelVerbose {
-		log.Println(v...)
+func (sl *StdLogger) Log(s Severity, msg string, fields ...Field) {
+	if sl.Enabled(s) {
+		sl.Logger.Output(4, appendFields(msg, fields))
 	}
 }
 
+// Fatal is defined here since the embedded log.Logger also has a Fatal method.
+func (sl *StdLogger) Fatal(v ...interface{}) {
+	sl.logMethods.Fatal(v...)
+}
+
+// Fatalf is defined here since the embedded log.Logger also has a Fatalf method.
+func (sl *StdLogger) Fatalf(format string, v ...interface{}) {
+	sl.logMethods.Fatalf(format, v...)
+}
+
+// Fatalln is defined here since the embedded log.Logger also has a Fatalln method.
+func (sl *StdLogger) Fatalln(v ...interface{}) {
+	sl.logMethods.Fatalln(v...)
+}
+
 // NewLogger creates a new StdLogger. The out variable sets the destination to which log data will be written.
 // The level determines the type of log messages being output.
 // The prefix appears at the beginning of each generated log line, or after the log header if the log.Lmsgprefix flag is
 // provided.
 // The flag argument defines the logging properties.
 func NewLogger(level LogLevel, out io.Writer, prefix string, flag int) Logger {
-	return &StdLogger{
+	sl := &StdLogger{
 		level:  level,
 		Logger: log.New(out, prefix, flag),
 	}
+	sl.logMethods = logMethods{sl}
+
+	return sl
+}
+
+// JSONLogger writes every log entry as a single line JSON object holding the time, the severity, the message and the
+// fields of the entry, e.g.:
+//
+//	{"time":"2020-06-12T21:03:52.144+02:00","level":"debug","msg":"sending packet","vendor":"fuji","channel":"cmd"}
+type JSONLogger struct {
+	logMethods
+	level LogLevel
+	mu    sync.Mutex
+	out   io.Writer
+}
+
+func (jl *JSONLogger) Enabled(s Severity) bool {
+	return jl.level.Enabled(s)
+}
+
+func (jl *JSONLogger) Log(s Severity, msg string, fields ...Field) {
+	if !jl.Enabled(s) {
+		return
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSONValue(&b, time.Now().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(&b, s.String())
+	b.WriteString(`,"msg":`)
+	writeJSONValue(&b, msg)
+	for _, f := range fields {
+		switch f.Key {
+		case "time", "level", "msg":
+			continue
+		}
+		b.WriteByte(',')
+		writeJSONValue(&b, f.Key)
+		b.WriteByte(':')
+		writeJSONValue(&b, f.Value)
+	}
+	b.WriteString("}\n")
+
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	jl.out.Write(b.Bytes())
+}
+
+// writeJSONValue writes v as JSON. Errors are written as their message and values that cannot be marshaled are
+// written as a string.
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		j, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(j)
+}
+
+// NewJSONLogger creates a new JSONLogger writing to out. The level determines the type of log messages being output.
+func NewJSONLogger(level LogLevel, out io.Writer) Logger {
+	jl := &JSONLogger{
+		level: level,
+		out:   out,
+	}
+	jl.logMethods = logMethods{jl}
+
+	return jl
+}
+
+// fieldLogger adds fields to every log entry before passing it on to the wrapped logger.
+type fieldLogger struct {
+	logMethods
+	next   Logger
+	fields []Field
+}
+
+func (fl *fieldLogger) Enabled(s Severity) bool {
+	if sl, ok := fl.next.(StructuredLogger); ok {
+		return sl.Enabled(s)
+	}
+
+	return true
+}
+
+func (fl *fieldLogger) Log(s Severity, msg string, fields ...Field) {
+	all := make([]Field, 0, len(fl.fields)+len(fields))
+	all = append(append(all, fl.fields...), fields...)
+
+	if sl, ok := fl.next.(StructuredLogger); ok {
+		sl.Log(s, msg, all...)
+		return
+	}
+
+	msg = appendFields(msg, all)
+	switch s {
+	case SeverityDebug:
+		fl.next.Debug(msg)
+	case SeverityInfo:
+		fl.next.Info(msg)
+	case SeverityWarn:
+		fl.next.Warn(msg)
+	case SeverityError, SeverityFatal:
+		// Exiting is left to the Fatal methods.
+		fl.next.Error(msg)
+	}
+}
+
+// WithFields returns a Logger adding the given fields to every log entry. When l is a StructuredLogger, the fields are
+// passed on as is, otherwise they are appended to the message as key=value pairs.
+func WithFields(l Logger, fields ...Field) Logger {
+	if len(fields) == 0 {
+		return l
+	}
+	if fl, ok := l.(*fieldLogger); ok {
+		l = fl.next
+		fields = append(append([]Field{}, fl.fields...), fields...)
+	}
+
+	fl := &fieldLogger{next: l, fields: fields}
+	fl.logMethods = logMethods{fl}
+
+	return fl
+}
+
+// appendFields appends the fields to msg as key=value pairs. Values holding spaces or quotes are quoted.
+func appendFields(msg string, fields []Field) string {
+	if len(fields) == 0 {
+		return msg
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, f := range fields {
+		v := fmt.Sprint(f.Value)
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(" " + f.Key + "=" + v)
+	}
+
+	return b.String()
 }
@@ -0,0 +1,121 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestLogLevel_Enabled(t *testing.T) {
+	check := []struct {
+		level LogLevel
+		sev   Severity
+		want  bool
+	}{
+		{LevelSilent, SeverityError, false},
+		{LevelSilent, SeverityFatal, true},
+		{LevelVerbose, SeverityWarn, true},
+		{LevelVerbose, SeverityInfo, false},
+		{LevelVeryVerbose, SeverityInfo, true},
+		{LevelVeryVerbose, SeverityDebug, false},
+		{LevelDebug, SeverityDebug, true},
+	}
+
+	for _, tt := range check {
+		if got := tt.level.Enabled(tt.sev); got != tt.want {
+			t.Errorf("Enabled() level %d severity %s got = %t; want %t", tt.level, tt.sev, got, tt.want)
+		}
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	lgr := NewLogger(LevelVerbose, &buf, "", 0)
+
+	lgr.Info("not logged")
+	lgr.Warnf("logged %d", 1)
+	lgr.(StructuredLogger).Log(SeverityError, "logged", Field{"tid", 2}, Field{"msg", "with space"})
+
+	want := "logged 1\nlogged tid=2 msg=\"with space\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("NewLogger() got = %q; want %q", got, want)
+	}
+}
+
+func TestNewJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	lgr := NewJSONLogger(LevelVeryVerbose, &buf)
+
+	lgr.Debug("not logged")
+	WithFields(lgr, Field{FieldVendor, "fuji"}, Field{FieldChannel, cmdDataConnection}).Infof("sending %s", "packet")
+	lgr.(StructuredLogger).Log(SeverityError, "failed", Field{"error", errors.New("boom")}, Field{"level", "ignored"})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("NewJSONLogger() got %d lines; want 2", len(lines))
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"level": "info", "msg": "sending packet", "vendor": "fuji", "channel": "cmd"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("NewJSONLogger() %s got = %v; want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["time"]; !ok {
+		t.Error("NewJSONLogger() time missing")
+	}
+
+	got = nil
+	if err := json.Unmarshal(lines[1], &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["level"] != "error" || got["error"] != "boom" {
+		t.Errorf("NewJSONLogger() got = %v; want level error and error boom", got)
+	}
+}
+
+// plainLogger is a Logger that does not implement StructuredLogger.
+type plainLogger struct {
+	Logger
+	buf *bytes.Buffer
+}
+
+func (pl plainLogger) Debug(v ...interface{}) {
+	pl.buf.WriteString(v[0].(string))
+}
+
+func TestWithFields(t *testing.T) {
+	var buf bytes.Buffer
+	lgr := WithFields(WithFields(plainLogger{buf: &buf}, Field{FieldVendor, "fuji"}), Field{FieldTransactionId, 3})
+
+	lgr.Debugf("sending %s", "packet")
+
+	want := "sending packet vendor=fuji tid=3"
+	if got := buf.String(); got != want {
+		t.Errorf("WithFields() got = %q; want %q", got, want)
+	}
+}
+
+func TestClient_SetLogger(t *testing.T) {
+	c, err := NewClient("fuji", address, 15740, "testér", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	c.SetLogger(NewJSONLogger(LevelDebug, &buf))
+	c.transactionLogger(cmdDataConnection, 7).Debug("test")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got[FieldVendor] != "fuji" || got[FieldChannel] != "cmd" || got[FieldTransactionId] != float64(7) {
+		t.Errorf("SetLogger() got = %v; want vendor fuji, channel cmd and tid 7", got)
+	}
+}
@@ -13,6 +13,7 @@ import (
 func handleFujiMessages(conn net.Conn, evtChan chan uint32, lmp string) {
 	// NO defer conn.Close() here since we need to mock a real Fuji responder and thus need to keep the connections open
 	// when established and continuously listen for messages in a loop.
+	var setProp uint16
 	for {
 		l, raw, err := readMessageRaw(conn, lmp)
 		if err == io.EOF {
@@ -59,9 +60,13 @@ func handleFujiMessages(conn net.Conn, evtChan chan uint32, lmp string) {
 			msg, resp = fujiInitiateOpenCaptureResponse(raw[4:8])
 		case constructPacketType(ptp.OC_OpenSession):
 			msg, resp = fujiOpenSessionResponse(raw[4:8])
+		case constructPacketType(ptp.OC_CloseSession):
+			msg, resp = fujiCloseSessionResponse(raw[4:8])
+		case constructPacketType(ptp.OC_SetDevicePropValue):
+			setProp = binary.LittleEndian.Uint16(raw[8:10])
 		case constructPacketTypeWithDataPhase(ptp.OC_SetDevicePropValue, DP_DataOut):
 			// SetDevicePropValue involves two messages, only the second one needs a response from us!
-			msg, resp = fujiSetDevicePropValue(raw[4:8])
+			msg, resp = fujiSetDevicePropValue(raw[4:8], setProp, binary.LittleEndian.Uint32(raw[8:12]))
 		}
 
 		if resp != nil {
@@ -235,7 +240,17 @@ func fujiOpenSessionResponse(tid []byte) (string, *FujiOperationResponsePacket)
 		fujiEndOfDataPacket(tid)
 }
 
-func fujiSetDevicePropValue(tid []byte) (string, *FujiOperationResponsePacket) {
+func fujiCloseSessionResponse(tid []byte) (string, *FujiOperationResponsePacket) {
+	return "CloseSession",
+		fujiEndOfDataPacket(tid)
+}
+
+func fujiSetDevicePropValue(tid []byte, prop uint16, val uint32) (string, *FujiOperationResponsePacket) {
+	// Like the camera, refuse app versions it does not know about.
+	if prop == uint16(DPC_Fuji_AppVersion) && val > PM_Fuji_AppVersion {
+		return "SetDevicePropValue", fujiOperationResponsePacket(DP_Unknown, ptp.RC_InvalidDevicePropValue, tid)
+	}
+
 	return "SetDevicePropValue",
 		fujiEndOfDataPacket(tid)
 }
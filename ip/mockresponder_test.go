@@ -22,6 +22,7 @@ var (
 	fujiCmdPort uint16 = 55740
 	fujiEvtPort uint16 = 55741
 	failPort    uint16 = 25740
+	virtualPort uint16 = 35740
 	logLevel           = LevelSilent
 	lgr         Logger
 )
@@ -38,6 +39,7 @@ func TestMain(m *testing.M) {
 	newLocalOkResponder(DefaultVendor, address, []uint16{okPort})
 	newLocalOkResponder("fuji", address, []uint16{fujiCmdPort, fujiEvtPort})
 	newLocalFailResponder(address, failPort)
+	newLocalVirtualCamera(address, virtualPort, "testdata/preview.jpg")
 	os.Exit(m.Run())
 }
 
@@ -165,6 +167,10 @@ func sendMessage(w io.Writer, pkt Packet, extra []byte, lmp string) {
 }
 
 func alwaysFailMessage(conn net.Conn, _ chan uint32, lmp string) {
+	alwaysFailMessageWithReason(conn, FR_FailRejectedInitiator, lmp)
+}
+
+func alwaysFailMessageWithReason(conn net.Conn, reason FailReason, lmp string) {
 	// TCP connections are closed by the Responder on failure!
 	defer conn.Close()
 	if _, pkt, _ := readMessage(conn, lmp); pkt == nil {
@@ -172,7 +178,7 @@ func alwaysFailMessage(conn net.Conn, _ chan uint32, lmp string) {
 	}
 
 	sendMessage(conn, &InitFailPacket{
-		Reason: FR_FailRejectedInitiator,
+		Reason: reason,
 	}, nil, lmp)
 }
 
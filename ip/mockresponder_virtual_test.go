@@ -0,0 +1,479 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/malc0mn/ptp-ip/ptp"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// responderFaults configures the failures injected by the virtual camera, so that the way the client copes with a
+// misbehaving Responder can be tested deterministically. The zero value injects no failures.
+type responderFaults struct {
+	// InitFail is the number of init command requests answered with an InitFail packet before the connection is
+	// accepted.
+	InitFail int
+	// DropResponses is the number of operation requests for which no operation response is sent.
+	DropResponses int
+	// Delay is the time waited before sending each operation response.
+	Delay time.Duration
+	// TruncateData is the number of bytes left out at the end of each data phase, the full length being announced in
+	// the StartData packet nonetheless.
+	TruncateData int
+	// ResetAfter resets the TCP connection after sending this many bytes of a data phase. Zero disables the reset.
+	ResetAfter int
+	// Latency is the round trip time of the connection: each operation request is answered this long after it was
+	// received, without holding up the requests following it. The connection is not reset when combined with
+	// ResetAfter.
+	Latency time.Duration
+}
+
+// virtualCamera simulates a camera that captures the same image each time it receives an InitiateCapture operation
+// request. Captures add a new object holding the image, which is announced on the event connection using the
+// ptp.EC_ObjectAdded event.
+type virtualCamera struct {
+	img    []byte
+	faults responderFaults
+
+	mu        sync.Mutex
+	last      uint32
+	initFails int
+	dropped   int
+	// pending is the number of requests waiting for their answer and mostPending the highest number seen, see
+	// responderFaults.Latency.
+	pending     int
+	mostPending int
+	// sessions holds the IDs of the sessions opened using ptp.OC_OpenSession.
+	sessions map[ptp.SessionID]bool
+	// iso is the current value of ptp.DPC_ExposureIndex, the only device property the camera has.
+	iso uint16
+
+	// writeMu serialises the delayed answers, see responderFaults.Latency.
+	writeMu sync.Mutex
+}
+
+// newLocalVirtualCamera runs a generic responder simulating a camera that captures the given image. The command and
+// the event connection are both made on the given port.
+func newLocalVirtualCamera(address string, port uint16, image string) {
+	img, err := os.ReadFile(image)
+	if err != nil {
+		lgr.Errorf("[Mocked virtual camera] error reading %s: %s", image, err)
+		return
+	}
+
+	vc := &virtualCamera{img: img}
+	runResponder(ptp.VendorExtension(0), address, []uint16{port}, []msgHandler{vc.handleMessages}, "[Mocked virtual camera]")
+}
+
+// startFaultyVirtualCamera runs a virtual camera injecting the given faults on a free port, which is returned. Each
+// test gets its own camera so that the faults do not leak into other tests. The camera stops when the test ends.
+func startFaultyVirtualCamera(t testing.TB, faults responderFaults) uint16 {
+	return startVirtualCamera(t, &virtualCamera{faults: faults})
+}
+
+// startVirtualCamera runs the given virtual camera on a free port, which is returned, e.g. to start with objects that
+// have already been captured. The camera stops when the test ends.
+func startVirtualCamera(t testing.TB, vc *virtualCamera) uint16 {
+	img, err := os.ReadFile("testdata/preview.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vc.img = img
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:0", address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	lmp := fmt.Sprintf("[Mocked virtual camera %s]", t.Name())
+	evtChan := make(chan uint32, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go vc.handleMessages(conn, evtChan, lmp)
+		}
+	}()
+
+	return uint16(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// dialVirtualCamera runs the given virtual camera and returns a client connected to it. The client is closed when the
+// test ends.
+func dialVirtualCamera(t testing.TB, vc *virtualCamera) *Client {
+	c, err := NewClient(DefaultVendor, address, startVirtualCamera(t, vc), "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Dial(); err != nil {
+		t.Fatalf("Dial() error = %s; want <nil>", err)
+	}
+
+	return c
+}
+
+// handleMessages answers the generic operation requests. The evtChan is used to hand the handles of the captured
+// objects from the command connection to the event connection.
+func (vc *virtualCamera) handleMessages(conn net.Conn, evtChan chan uint32, lmp string) {
+	// dataOut holds the operation request of which the data-out phase is in progress and data the payload received
+	// so far.
+	var dataOut, data []byte
+	for {
+		_, raw, err := readMessageRaw(conn, lmp)
+		if err != nil {
+			conn.Close()
+			break
+		}
+		if len(raw) < 4 {
+			continue
+		}
+
+		switch PacketType(binary.LittleEndian.Uint32(raw[0:4])) {
+		case PKT_InitCommandRequest:
+			if vc.failInit() {
+				lgr.Infof("%s injecting InitFail", lmp)
+				sendMessage(conn, &InitFailPacket{Reason: FR_FailBusy}, nil, lmp)
+				conn.Close()
+				return
+			}
+			_, res := genericInitCommandRequestResponse(lmp, PV_VersionOnePointZero)
+			sendMessage(conn, res, nil, lmp)
+		case PKT_InitEventRequest:
+			// The generic responders always hand out connection number 1.
+			if len(raw) < 8 || binary.LittleEndian.Uint32(raw[4:8]) != 1 {
+				sendMessage(conn, &InitFailPacket{Reason: FR_FailRejectedInitiator}, nil, lmp)
+				conn.Close()
+				return
+			}
+			sendMessage(conn, &InitEventAckPacket{}, nil, lmp)
+			virtualCameraEvents(conn, evtChan, lmp)
+			return
+		case PKT_OperationRequest:
+			if len(raw) < 14 {
+				continue
+			}
+			if DataPhase(binary.LittleEndian.Uint32(raw[4:8])) == DP_DataOut {
+				dataOut, data = raw, nil
+				continue
+			}
+			if !vc.answer(conn, raw, nil, evtChan, lmp) {
+				return
+			}
+		case PKT_StartData:
+		case PKT_Data:
+			if len(raw) > 8 {
+				data = append(data, raw[8:]...)
+			}
+		case PKT_EndData:
+			if len(raw) > 8 {
+				data = append(data, raw[8:]...)
+			}
+			if dataOut != nil {
+				vc.answer(conn, dataOut, data, evtChan, lmp)
+				dataOut = nil
+			}
+		case PKT_Cancel:
+			if dataOut != nil {
+				sendMessage(conn, &OperationResponsePacket{OperationResponse: ptp.OperationResponse{
+					ResponseCode:  ptp.RC_TransactionCancelled,
+					TransactionID: ptp.TransactionID(binary.LittleEndian.Uint32(dataOut[10:14])),
+				}}, nil, lmp)
+				dataOut = nil
+			}
+		default:
+			lgr.Errorf("%s unknown packet type %#x", lmp, raw[0:4])
+		}
+	}
+}
+
+// handleOperationRequest answers a single operation request, returning false when the connection has been reset. The
+// data holds the payload of the data-out phase, if any.
+func (vc *virtualCamera) handleOperationRequest(conn net.Conn, raw, data []byte, evtChan chan uint32, lmp string) bool {
+	code := ptp.OperationCode(binary.LittleEndian.Uint16(raw[8:10]))
+	tid := ptp.TransactionID(binary.LittleEndian.Uint32(raw[10:14]))
+
+	rc := ptp.RC_OK
+	switch code {
+	case ptp.OC_InitiateCapture:
+		vc.mu.Lock()
+		vc.last++
+		h := vc.last
+		vc.mu.Unlock()
+		lgr.Infof("%s captured object %#x", lmp, h)
+		evtChan <- h
+	case ptp.OC_GetObject:
+		vc.mu.Lock()
+		last := vc.last
+		vc.mu.Unlock()
+		if len(raw) < 18 || binary.LittleEndian.Uint32(raw[14:18]) == 0 || binary.LittleEndian.Uint32(raw[14:18]) > last {
+			rc = ptp.RC_InvalidObjectHandle
+			break
+		}
+		if !vc.sendData(conn, tid, lmp) {
+			return false
+		}
+	case ptp.OC_GetObjectInfo:
+		vc.mu.Lock()
+		last := vc.last
+		vc.mu.Unlock()
+		if len(raw) < 18 || binary.LittleEndian.Uint32(raw[14:18]) == 0 || binary.LittleEndian.Uint32(raw[14:18]) > last {
+			rc = ptp.RC_InvalidObjectHandle
+			break
+		}
+		h := binary.LittleEndian.Uint32(raw[14:18])
+		data := marshalObjectInfo(&ptp.ObjectInfo{
+			StorageID:            0x00010001,
+			ObjectFormat:         ptp.OFC_EXIF_JPEG,
+			ObjectCompressedSize: uint32(len(vc.img)),
+			Filename:             fmt.Sprintf("DSCF%04d.JPG", h),
+		})
+		sendMessage(conn, &StartDataPacket{TransactionId: tid, TotalDataLength: uint64(len(data))}, nil, lmp)
+		sendMessage(conn, &EndDataPacket{TransactionId: tid, DataPayload: data}, nil, lmp)
+	case ptp.OC_GetStorageIDs:
+		sendMessage(conn, &StartDataPacket{TransactionId: tid, TotalDataLength: 8}, nil, lmp)
+		sendMessage(conn, &EndDataPacket{TransactionId: tid, DataPayload: []byte{0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00}}, nil, lmp)
+	case ptp.OC_GetDevicePropDesc, ptp.OC_GetDevicePropValue, ptp.OC_SetDevicePropValue:
+		if len(raw) < 18 || ptp.DevicePropCode(binary.LittleEndian.Uint32(raw[14:18])) != ptp.DPC_ExposureIndex {
+			rc = ptp.RC_DevicePropNotSupported
+			break
+		}
+		rc = vc.exposureIndex(conn, code, tid, data, lmp)
+	case ptp.OC_OpenSession:
+		if len(raw) < 18 {
+			rc = ptp.RC_ParameterNotSupported
+			break
+		}
+		rc = vc.openSession(ptp.SessionID(binary.LittleEndian.Uint32(raw[14:18])))
+	case ptp.OC_CloseSession:
+	default:
+		rc = ptp.RC_OperationNotSupported
+	}
+
+	if vc.dropResponse() {
+		lgr.Infof("%s dropping response to transaction %d", lmp, tid)
+		return true
+	}
+	time.Sleep(vc.faults.Delay)
+	sendMessage(conn, &OperationResponsePacket{
+		OperationResponse: ptp.OperationResponse{ResponseCode: rc, TransactionID: tid},
+	}, nil, lmp)
+
+	return true
+}
+
+// answer answers the operation request, returning false when the connection has been reset. When the camera has a
+// latency, the request is answered later on and true is returned.
+func (vc *virtualCamera) answer(conn net.Conn, raw, data []byte, evtChan chan uint32, lmp string) bool {
+	if vc.faults.Latency > 0 {
+		vc.answerLater(conn, raw, data, evtChan, lmp)
+		return true
+	}
+
+	return vc.handleOperationRequest(conn, raw, data, evtChan, lmp)
+}
+
+// answerLater answers the operation request once the latency has passed, while the next requests are being read. The
+// packets answering a request are written at once so that they are not interleaved with the ones of other requests.
+func (vc *virtualCamera) answerLater(conn net.Conn, raw, data []byte, evtChan chan uint32, lmp string) {
+	received := time.Now()
+	vc.mu.Lock()
+	vc.pending++
+	if vc.pending > vc.mostPending {
+		vc.mostPending = vc.pending
+	}
+	vc.mu.Unlock()
+	go func() {
+		lc := &latencyConn{Conn: conn}
+		vc.handleOperationRequest(lc, raw, data, evtChan, lmp)
+		time.Sleep(time.Until(received.Add(vc.faults.Latency)))
+
+		vc.writeMu.Lock()
+		defer vc.writeMu.Unlock()
+		vc.mu.Lock()
+		vc.pending--
+		vc.mu.Unlock()
+		conn.Write(lc.buf.Bytes())
+	}()
+}
+
+// virtualCameraISOs are the values ptp.DPC_ExposureIndex accepts.
+var virtualCameraISOs = []uint16{100, 200, 400}
+
+// exposureIndex answers the operation requests for ptp.DPC_ExposureIndex, returning the response code to send.
+func (vc *virtualCamera) exposureIndex(conn net.Conn, code ptp.OperationCode, tid ptp.TransactionID, data []byte, lmp string) ptp.OperationResponseCode {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	var payload []byte
+	switch code {
+	case ptp.OC_GetDevicePropDesc:
+		payload = binary.LittleEndian.AppendUint16(payload, uint16(ptp.DPC_ExposureIndex))
+		payload = binary.LittleEndian.AppendUint16(payload, uint16(ptp.DTC_UINT16))
+		payload = append(payload, uint8(ptp.DPD_GetSet))
+		payload = binary.LittleEndian.AppendUint16(payload, virtualCameraISOs[0])
+		payload = binary.LittleEndian.AppendUint16(payload, vc.iso)
+		payload = append(payload, uint8(ptp.DPF_FormFlag_Enum))
+		payload = binary.LittleEndian.AppendUint16(payload, uint16(len(virtualCameraISOs)))
+		for _, iso := range virtualCameraISOs {
+			payload = binary.LittleEndian.AppendUint16(payload, iso)
+		}
+	case ptp.OC_GetDevicePropValue:
+		payload = binary.LittleEndian.AppendUint16(payload, vc.iso)
+	case ptp.OC_SetDevicePropValue:
+		if len(data) != 2 {
+			return ptp.RC_InvalidDevicePropFormat
+		}
+		iso := binary.LittleEndian.Uint16(data)
+		for _, v := range virtualCameraISOs {
+			if v == iso {
+				vc.iso = iso
+				return ptp.RC_OK
+			}
+		}
+		return ptp.RC_InvalidDevicePropValue
+	}
+	sendMessage(conn, &StartDataPacket{TransactionId: tid, TotalDataLength: uint64(len(payload))}, nil, lmp)
+	sendMessage(conn, &EndDataPacket{TransactionId: tid, DataPayload: payload}, nil, lmp)
+
+	return ptp.RC_OK
+}
+
+// openSession opens the session with the given ID, returning the response code to answer ptp.OC_OpenSession with.
+func (vc *virtualCamera) openSession(sid ptp.SessionID) ptp.OperationResponseCode {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	switch {
+	case sid == 0:
+		return ptp.RC_InvalidParameter
+	case vc.sessions[sid]:
+		return ptp.RC_SessionAlreadyOpen
+	}
+	if vc.sessions == nil {
+		vc.sessions = make(map[ptp.SessionID]bool)
+	}
+	vc.sessions[sid] = true
+
+	return ptp.RC_OK
+}
+
+// sessionOpen reports whether the session with the given ID has been opened.
+func (vc *virtualCamera) sessionOpen(sid ptp.SessionID) bool {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	return vc.sessions[sid]
+}
+
+// mostPendingRequests returns the highest number of requests that were waiting for their answer at the same time.
+func (vc *virtualCamera) mostPendingRequests() int {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	return vc.mostPending
+}
+
+// latencyConn collects the packets written to the connection so that they can be sent later on.
+type latencyConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (lc *latencyConn) Write(b []byte) (int, error) {
+	return lc.buf.Write(b)
+}
+
+// sendData sends the image as the data phase of the given transaction, returning false when the connection has been
+// reset halfway.
+func (vc *virtualCamera) sendData(conn net.Conn, tid ptp.TransactionID, lmp string) bool {
+	data := vc.img
+	if t := vc.faults.TruncateData; t > 0 && t <= len(data) {
+		data = data[:len(data)-t]
+	}
+
+	sendMessage(conn, &StartDataPacket{TransactionId: tid, TotalDataLength: uint64(len(vc.img))}, nil, lmp)
+	if r := vc.faults.ResetAfter; r > 0 && r < len(data) {
+		sendMessage(conn, &DataPacket{TransactionId: tid, DataPayload: data[:r]}, nil, lmp)
+		lgr.Infof("%s resetting connection after %d bytes", lmp, r)
+		if tc, ok := conn.(*net.TCPConn); ok {
+			// A zero linger time makes closing the connection send a RST instead of a FIN.
+			tc.SetLinger(0)
+		}
+		conn.Close()
+		return false
+	}
+	sendMessage(conn, &EndDataPacket{TransactionId: tid, DataPayload: data}, nil, lmp)
+
+	return true
+}
+
+// failInit reports whether the next init command request must be answered with an InitFail packet.
+func (vc *virtualCamera) failInit() bool {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if vc.initFails < vc.faults.InitFail {
+		vc.initFails++
+		return true
+	}
+
+	return false
+}
+
+// dropResponse reports whether the next operation response must be dropped.
+func (vc *virtualCamera) dropResponse() bool {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if vc.dropped < vc.faults.DropResponses {
+		vc.dropped++
+		return true
+	}
+
+	return false
+}
+
+// virtualCameraEvents sends an ObjectAdded event for each object handle received on evtChan until the Initiator closes
+// the event connection. Probe requests sent by the Initiator are answered in the meantime.
+func virtualCameraEvents(conn net.Conn, evtChan chan uint32, lmp string) {
+	defer conn.Close()
+
+	var mu sync.Mutex
+	go func() {
+		for {
+			_, raw, err := readMessageRaw(conn, lmp)
+			if err != nil {
+				return
+			}
+			if len(raw) >= 4 && PacketType(binary.LittleEndian.Uint32(raw[0:4])) == PKT_ProbeRequest {
+				mu.Lock()
+				sendMessage(conn, &ProbeResponsePacket{}, nil, lmp)
+				mu.Unlock()
+			}
+		}
+	}()
+
+	for h := range evtChan {
+		p := make([]byte, 4)
+		binary.LittleEndian.PutUint32(p, h)
+		mu.Lock()
+		err := sendAnyPacket(conn, &GenericEventPacket{Event: ptp.Event{
+			EventCode:     ptp.EC_ObjectAdded,
+			TransactionID: 0xFFFFFFFF,
+			Parameter1:    p,
+		}}, nil, lmp)
+		mu.Unlock()
+		if err != nil {
+			lgr.Errorf("%s error sending event: %s", lmp, err)
+			return
+		}
+	}
+}
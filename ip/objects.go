@@ -0,0 +1,620 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+const (
+	// AllStorages is the StorageID to pass to GetObjectHandles to list the objects of all stores.
+	AllStorages ptp.StorageID = 0xFFFFFFFF
+	// RootObjects is the parent ObjectHandle to pass to GetObjectHandles or GetNumObjects to only include the objects in
+	// the root of a store.
+	RootObjects ptp.ObjectHandle = 0xFFFFFFFF
+	// ptpDateTimeFormat is the layout of the DateTime strings used in PTP datasets. Tenths of seconds and a time zone
+	// may follow.
+	ptpDateTimeFormat = "20060102T150405"
+)
+
+// ObjectKind is a rough classification of an object used to filter objects without having to know the vendor specific
+// object format codes.
+type ObjectKind string
+
+const (
+	ObjectKindJPEG   ObjectKind = "jpeg"
+	ObjectKindRAW    ObjectKind = "raw"
+	ObjectKindVideo  ObjectKind = "video"
+	ObjectKindFolder ObjectKind = "folder"
+	ObjectKindOther  ObjectKind = "other"
+)
+
+var (
+	rawExtensions   = []string{".3fr", ".arw", ".cr2", ".cr3", ".crw", ".dng", ".erf", ".iiq", ".mrw", ".nef", ".nrw", ".orf", ".pef", ".raf", ".rw2", ".sr2", ".srf", ".srw", ".x3f"}
+	videoExtensions = []string{".avi", ".m4v", ".mov", ".mp4", ".mpg", ".mts"}
+)
+
+// KindOfObject classifies the object using its object format code and, since RAW files and most videos use vendor
+// specific or undefined format codes, the extension of its file name.
+func KindOfObject(oi *ptp.ObjectInfo) ObjectKind {
+	switch oi.ObjectFormat {
+	case ptp.OFC_Association:
+		return ObjectKindFolder
+	case ptp.OFC_EXIF_JPEG, ptp.OFC_JFIF:
+		return ObjectKindJPEG
+	case ptp.OFC_AVI, ptp.OFC_MPEG, ptp.OFC_ASF:
+		return ObjectKindVideo
+	}
+
+	ext := strings.ToLower(filepath.Ext(oi.Filename))
+	switch {
+	case ext == ".jpg" || ext == ".jpeg":
+		return ObjectKindJPEG
+	case containsString(rawExtensions, ext):
+		return ObjectKindRAW
+	case containsString(videoExtensions, ext):
+		return ObjectKindVideo
+	}
+
+	return ObjectKindOther
+}
+
+// ObjectFormatOf returns the object format code to use for a file with the given name when sending it to the
+// Responder, based on its extension. OFC_Undefined is returned for unknown extensions, which includes RAW files.
+func ObjectFormatOf(filename string) ptp.ObjectFormatCode {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg":
+		return ptp.OFC_EXIF_JPEG
+	case ".tif", ".tiff":
+		return ptp.OFC_TIFF
+	case ".png":
+		return ptp.OFC_PNG
+	case ".gif":
+		return ptp.OFC_GIF
+	case ".bmp":
+		return ptp.OFC_BMP
+	case ".avi":
+		return ptp.OFC_AVI
+	case ".mpg", ".mpeg":
+		return ptp.OFC_MPEG
+	case ".mp3":
+		return ptp.OFC_MP3
+	case ".wav":
+		return ptp.OFC_WAV
+	case ".txt":
+		return ptp.OFC_Text
+	case ".htm", ".html":
+		return ptp.OFC_HTML
+	case ".mrk":
+		return ptp.OFC_DPOF
+	}
+
+	return ptp.OFC_Undefined
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ObjectFilter selects objects based on their ObjectInfo dataset and their handle. The zero value matches all objects
+// except folders.
+type ObjectFilter struct {
+	// Since and Until limit the capture date of the objects, both being inclusive. A zero time is not used.
+	Since time.Time
+	Until time.Time
+	// Kinds limits the objects to the given kinds.
+	Kinds []ObjectKind
+	// FirstHandle and LastHandle limit the object handles, both being inclusive. A zero LastHandle is not used.
+	FirstHandle ptp.ObjectHandle
+	LastHandle  ptp.ObjectHandle
+}
+
+// MatchHandle reports whether the handle is within the handle range of the filter. Use it to skip requesting the
+// ObjectInfo dataset for objects that will not match anyway.
+func (f ObjectFilter) MatchHandle(h ptp.ObjectHandle) bool {
+	return h >= f.FirstHandle && (f.LastHandle == 0 || h <= f.LastHandle)
+}
+
+// Match reports whether the object matches all criteria of the filter.
+func (f ObjectFilter) Match(h ptp.ObjectHandle, oi *ptp.ObjectInfo) bool {
+	if !f.MatchHandle(h) {
+		return false
+	}
+
+	kind := KindOfObject(oi)
+	if kind == ObjectKindFolder {
+		return false
+	}
+	if len(f.Kinds) > 0 {
+		found := false
+		for _, k := range f.Kinds {
+			found = found || k == kind
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !f.Since.IsZero() && oi.CaptureDate.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && oi.CaptureDate.After(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// Object is an object on the Responder together with its ObjectInfo dataset.
+type Object struct {
+	Handle ptp.ObjectHandle
+	Info   *ptp.ObjectInfo
+}
+
+// GetObjectHandles returns the handles of the objects in the given store, AllStorages listing the objects of all
+// stores. Passing a format code or a parent object limits the list as described for ptp.GetObjectHandles.
+func (c *Client) GetObjectHandles(sid ptp.StorageID, ofc ptp.ObjectFormatCode, parent ptp.ObjectHandle) ([]ptp.ObjectHandle, error) {
+	return c.vendorExtensions.getObjectHandles(c, sid, ofc, parent)
+}
+
+// GetNumObjects returns the number of objects GetObjectHandles would return for the same arguments without listing
+// them, e.g. to show the total of a listing or a download upfront. When the Responder does not support the
+// GetNumObjects operation, the handles are counted instead.
+func (c *Client) GetNumObjects(sid ptp.StorageID, ofc ptp.ObjectFormatCode, parent ptp.ObjectHandle) (int, error) {
+	n, err := c.vendorExtensions.getNumObjects(c, sid, ofc, parent)
+	var ore *ptp.OperationResponseError
+	if errors.As(err, &ore) && ore.Code == ptp.RC_OperationNotSupported {
+		handles, err := c.GetObjectHandles(sid, ofc, parent)
+		return len(handles), err
+	}
+
+	return n, err
+}
+
+// GetObjectInfo returns the ObjectInfo dataset for the given object.
+func (c *Client) GetObjectInfo(h ptp.ObjectHandle) (*ptp.ObjectInfo, error) {
+	return c.vendorExtensions.getObjectInfo(c, h)
+}
+
+// GetObject retrieves the data of the given object.
+func (c *Client) GetObject(h ptp.ObjectHandle) ([]byte, error) {
+	return c.vendorExtensions.getObject(c, h)
+}
+
+// SetObjectProtection write-protects the given object when status is ptp.PS_ReadOnly, so that it cannot be deleted on
+// the camera nor by the Initiator, or removes the protection using ptp.PS_NoProtection. The protection is stored on the
+// memory card, making it a portable way to flag the selected images.
+func (c *Client) SetObjectProtection(h ptp.ObjectHandle, status ptp.ProtectionStatus) error {
+	return c.vendorExtensions.setObjectProtection(c, h, status)
+}
+
+// SetObjectRating sets the rating of the given object, ranging from 0 for unrated to MTPMaxRating, using the MTP
+// OPC_MTP_Rating object property. Only Responders speaking MTP support it, an error wrapping a
+// ptp.OperationResponseError holding ptp.RC_OperationNotSupported being returned by the others.
+func (c *Client) SetObjectRating(h ptp.ObjectHandle, rating int) error {
+	if rating < 0 || rating > MTPMaxRating {
+		return fmt.Errorf("invalid rating %d, must be between 0 and %d", rating, MTPMaxRating)
+	}
+
+	if err := MTPSetObjectPropUint16(c, h, OPC_MTP_Rating, uint16(rating)); err != nil {
+		return fmt.Errorf("setting the rating of object %#x: %w", h, err)
+	}
+
+	return nil
+}
+
+// SendObject stores data as a new object in the given store and folder, RootObjects being the root of the store,
+// using the ObjectInfo dataset to describe it. The handle of the new object is returned.
+func (c *Client) SendObject(sid ptp.StorageID, parent ptp.ObjectHandle, oi *ptp.ObjectInfo, data []byte) (ptp.ObjectHandle, error) {
+	params, err := c.sendDataAndGetParameters(ptp.OC_SendObjectInfo, []uint32{uint32(sid), uint32(parent)}, marshalObjectInfo(oi))
+	if err != nil {
+		return 0, fmt.Errorf("sending object info: %w", err)
+	}
+	// The store, the parent and the handle of the new object are returned.
+	if len(params) < 3 {
+		return 0, errors.New("the response does not hold the handle of the new object")
+	}
+
+	// An association, such as a folder, has no data.
+	if oi.ObjectFormat != ptp.OFC_Association {
+		if _, err := c.sendDataAndGetParameters(ptp.OC_SendObject, nil, data); err != nil {
+			return 0, fmt.Errorf("sending object: %w", err)
+		}
+	}
+
+	return ptp.ObjectHandle(params[2]), nil
+}
+
+// DeleteObject deletes the given object from the Responder.
+func (c *Client) DeleteObject(h ptp.ObjectHandle) error {
+	_, err := GenericOperationRequestAndGetParameters(c, ptp.OC_DeleteObject, []uint32{uint32(h)})
+
+	return err
+}
+
+// ObjectPath returns the slash separated path of file names leading to the given object, e.g. /DCIM/100_FUJI/DSCF0001.JPG,
+// requesting the ObjectInfo dataset of the object and of each folder holding it.
+func (c *Client) ObjectPath(h ptp.ObjectHandle) (string, error) {
+	var names []string
+	seen := make(map[ptp.ObjectHandle]bool)
+	for h != 0 && h != RootObjects {
+		if seen[h] {
+			return "", fmt.Errorf("object %#x is its own parent", h)
+		}
+		seen[h] = true
+
+		oi, err := c.GetObjectInfo(h)
+		if err != nil {
+			return "", err
+		}
+		names = append([]string{oi.Filename}, names...)
+		h = oi.ParentObject
+	}
+
+	return "/" + strings.Join(names, "/"), nil
+}
+
+// GetObjectTo writes the data of the given object to w as it arrives, rather than collecting it in memory first, and
+// returns the number of bytes written. This is the preferred way to download large objects, especially when the
+// Responder does not announce their size upfront.
+func (c *Client) GetObjectTo(h ptp.ObjectHandle, w io.Writer) (int64, error) {
+	return GenericOperationRequestAndStreamData(c, ptp.OC_GetObject, []uint32{uint32(h)}, c.transferWriter(w))
+}
+
+// GetThumb retrieves the thumbnail of the given object, which usually is a small JPEG image.
+func (c *Client) GetThumb(h ptp.ObjectHandle) ([]byte, error) {
+	return c.vendorExtensions.getThumb(c, h)
+}
+
+// FindObjects returns the objects of all stores matching the filter, in the order of their handles.
+func (c *Client) FindObjects(f ObjectFilter) ([]Object, error) {
+	handles, err := c.GetObjectHandles(AllStorages, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []ptp.ObjectHandle
+	for _, h := range handles {
+		if f.MatchHandle(h) {
+			matched = append(matched, h)
+		}
+	}
+	infos, err := c.GetObjectInfos(matched)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []Object
+	for i, h := range matched {
+		if f.Match(h, infos[i]) {
+			objs = append(objs, Object{Handle: h, Info: infos[i]})
+		}
+	}
+
+	return objs, nil
+}
+
+// DownloadProgress is passed to the progress function of DownloadObjects for every object processed.
+type DownloadProgress struct {
+	// Index is the position of the object in the list being downloaded, starting from 1.
+	Index  int
+	Total  int
+	Object Object
+	// Bytes is the number of bytes written, which is zero when the object was skipped.
+	Bytes   int
+	Skipped bool
+	Err     error
+	// Rate is the average number of bytes per second retrieved since the download started.
+	Rate float64
+	// ETA is the estimated time left to retrieve the remaining objects, based on their ObjectCompressedSize and the
+	// current rate. It assumes none of the remaining objects is skipped and is zero when it cannot be estimated.
+	ETA time.Duration
+}
+
+// DownloadObjects retrieves the given objects one by one, passing the data of each object to the write function.
+// Objects for which skip returns true are not retrieved, e.g. because they were downloaded before. Skip and progress
+// may be nil. When retrieving or writing an object fails, the download continues with the next object and the error is
+// reported to the progress function. The error returned is the first error that occurred.
+func (c *Client) DownloadObjects(objs []Object, skip func(Object) bool, write func(Object, []byte) error, progress func(DownloadProgress)) error {
+	var remaining int64
+	for _, obj := range objs {
+		remaining += objectSize(obj)
+	}
+
+	var first error
+	var received int64
+	start := time.Now()
+	for i, obj := range objs {
+		p := DownloadProgress{Index: i + 1, Total: len(objs), Object: obj}
+
+		if skip != nil && skip(obj) {
+			p.Skipped = true
+		} else {
+			data, err := c.GetObject(obj.Handle)
+			if err == nil {
+				err = write(obj, data)
+			}
+			if err != nil {
+				p.Err = fmt.Errorf("object %#x: %s", obj.Handle, err)
+				if first == nil {
+					first = p.Err
+				}
+			} else {
+				p.Bytes = len(data)
+			}
+		}
+
+		received += int64(p.Bytes)
+		remaining -= objectSize(obj)
+		if elapsed := time.Since(start).Seconds(); elapsed > 0 && received > 0 {
+			p.Rate = float64(received) / elapsed
+			p.ETA = time.Duration(float64(remaining) / p.Rate * float64(time.Second))
+		}
+
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	return first
+}
+
+// objectSize returns the ObjectCompressedSize of the object, which is zero when the ObjectInfo is unknown.
+func objectSize(obj Object) int64 {
+	if obj.Info == nil {
+		return 0
+	}
+
+	return int64(obj.Info.ObjectCompressedSize)
+}
+
+// GenericGetObjectHandles requests the list of object handles from the Responder.
+func GenericGetObjectHandles(c *Client, sid ptp.StorageID, ofc ptp.ObjectFormatCode, parent ptp.ObjectHandle) ([]ptp.ObjectHandle, error) {
+	data, err := GenericOperationRequestAndGetData(c, ptp.OC_GetObjectHandles, []uint32{uint32(sid), uint32(ofc), uint32(parent)})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseObjectHandles(data)
+}
+
+// GenericGetNumObjects requests the number of objects from the Responder.
+func GenericGetNumObjects(c *Client, sid ptp.StorageID, ofc ptp.ObjectFormatCode, parent ptp.ObjectHandle) (int, error) {
+	params, err := GenericOperationRequestAndGetParameters(c, ptp.OC_GetNumObjects, []uint32{uint32(sid), uint32(ofc), uint32(parent)})
+	if err != nil {
+		return 0, err
+	}
+	if len(params) < 1 {
+		return 0, errors.New("the response does not hold the number of objects")
+	}
+
+	return int(params[0]), nil
+}
+
+// GenericSetObjectProtection sets the protection status of the given object using ptp.OC_SetObjectProtection.
+func GenericSetObjectProtection(c *Client, h ptp.ObjectHandle, status ptp.ProtectionStatus) error {
+	_, err := GenericOperationRequestAndGetParameters(c, ptp.OC_SetObjectProtection, []uint32{uint32(h), uint32(status)})
+
+	return err
+}
+
+// GenericGetObjectInfo requests the ObjectInfo dataset of the given object from the Responder.
+func GenericGetObjectInfo(c *Client, h ptp.ObjectHandle) (*ptp.ObjectInfo, error) {
+	data, err := GenericOperationRequestAndGetData(c, ptp.OC_GetObjectInfo, []uint32{uint32(h)})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseObjectInfo(data)
+}
+
+// GenericGetObject requests the data of the given object from the Responder, honouring the transfer rate limit.
+func GenericGetObject(c *Client, h ptp.ObjectHandle) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := GenericOperationRequestAndStreamData(c, ptp.OC_GetObject, []uint32{uint32(h)}, c.transferWriter(&buf)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GenericGetThumb requests the thumbnail of the given object from the Responder.
+func GenericGetThumb(c *Client, h ptp.ObjectHandle) ([]byte, error) {
+	return GenericOperationRequestAndGetData(c, ptp.OC_GetThumb, []uint32{uint32(h)})
+}
+
+// parseObjectHandles reads the array of object handles returned by the GetObjectHandles operation: the number of
+// elements followed by the elements themselves.
+func parseObjectHandles(data []byte) ([]ptp.ObjectHandle, error) {
+	r := bytes.NewReader(data)
+
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("reading object handle count: %s", err)
+	}
+	if int(n) > r.Len()/4 {
+		return nil, fmt.Errorf("expected %d object handles, got %d bytes", n, r.Len())
+	}
+
+	handles := make([]ptp.ObjectHandle, n)
+	if err := binary.Read(r, binary.LittleEndian, handles); err != nil {
+		return nil, err
+	}
+
+	return handles, nil
+}
+
+// parseObjectInfo reads the ObjectInfo dataset returned by the GetObjectInfo operation.
+func parseObjectInfo(data []byte) (*ptp.ObjectInfo, error) {
+	r := bytes.NewReader(data)
+	oi := &ptp.ObjectInfo{}
+
+	var assocDesc uint32
+	for _, v := range []interface{}{
+		&oi.StorageID,
+		&oi.ObjectFormat,
+		&oi.ProtectionStatus,
+		&oi.ObjectCompressedSize,
+		&oi.ThumbFormat,
+		&oi.ThumbCompressedSize,
+		&oi.ThumbPixWidth,
+		&oi.ThumbPixHeight,
+		&oi.ImagePixWidth,
+		&oi.ImagePixHeight,
+		&oi.ImageBitDepth,
+		&oi.ParentObject,
+		&oi.AssociationType,
+		&assocDesc,
+		&oi.SequenceNumber,
+	} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("reading object info: %s", err)
+		}
+	}
+	oi.AssociationDesc = ptp.AssociationDesc(assocDesc)
+
+	var (
+		capture, modification string
+		err                   error
+	)
+	for _, s := range []*string{&oi.Filename, &capture, &modification, &oi.Keywords} {
+		if *s, err = readPTPString(r); err != nil {
+			return nil, fmt.Errorf("reading object info: %s", err)
+		}
+	}
+
+	if oi.CaptureDate, err = parsePTPDateTime(capture); err != nil {
+		return nil, err
+	}
+	if oi.ModificationDate, err = parsePTPDateTime(modification); err != nil {
+		return nil, err
+	}
+
+	return oi, nil
+}
+
+// marshalObjectInfo converts the ObjectInfo dataset to the data sent using ptp.OC_SendObjectInfo.
+func marshalObjectInfo(oi *ptp.ObjectInfo) []byte {
+	var b bytes.Buffer
+	for _, v := range []interface{}{
+		oi.StorageID,
+		oi.ObjectFormat,
+		oi.ProtectionStatus,
+		oi.ObjectCompressedSize,
+		oi.ThumbFormat,
+		oi.ThumbCompressedSize,
+		oi.ThumbPixWidth,
+		oi.ThumbPixHeight,
+		oi.ImagePixWidth,
+		oi.ImagePixHeight,
+		oi.ImageBitDepth,
+		oi.ParentObject,
+		oi.AssociationType,
+		uint32(oi.AssociationDesc),
+		oi.SequenceNumber,
+	} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	for _, s := range []string{oi.Filename, formatPTPDateTime(oi.CaptureDate), formatPTPDateTime(oi.ModificationDate), oi.Keywords} {
+		writePTPString(&b, s)
+	}
+
+	return b.Bytes()
+}
+
+// writePTPString writes s as a PTP string, an empty string being written as a single zero byte.
+func writePTPString(b *bytes.Buffer, s string) {
+	if s == "" {
+		b.WriteByte(0)
+		return
+	}
+
+	chars := append(utf16.Encode([]rune(s)), 0)
+	b.WriteByte(byte(len(chars)))
+	binary.Write(b, binary.LittleEndian, chars)
+}
+
+// readPTPString reads a PTP string: the number of characters, including the null terminator, followed by the UTF-16
+// characters. A missing string at the end of a dataset is considered to be empty.
+func readPTPString(r io.Reader) (string, error) {
+	var n uint8
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		if err == io.EOF {
+			return "", nil
+		}
+		return "", err
+	}
+	if n == 0 {
+		return "", nil
+	}
+
+	chars := make([]uint16, n)
+	if err := binary.Read(r, binary.LittleEndian, chars); err != nil {
+		return "", err
+	}
+	if chars[n-1] == 0 {
+		chars = chars[:n-1]
+	}
+
+	return string(utf16.Decode(chars)), nil
+}
+
+// formatPTPDateTime formats t as a PTP DateTime string in local time, a zero time resulting in an empty string.
+func formatPTPDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Local().Format(ptpDateTimeFormat)
+}
+
+// parsePTPDateTime parses a PTP DateTime string such as "20210314T153045.5+0100". Without a time zone, the time is
+// considered to be local time since that is what the camera clock is set to. An empty string results in a zero time.
+func parsePTPDateTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if len(s) < len(ptpDateTimeFormat) {
+		return time.Time{}, errors.New("invalid date time " + s)
+	}
+
+	dt, rest := s[:len(ptpDateTimeFormat)], s[len(ptpDateTimeFormat):]
+
+	var tenths time.Duration
+	if strings.HasPrefix(rest, ".") && len(rest) >= 2 && rest[1] >= '0' && rest[1] <= '9' {
+		tenths = time.Duration(rest[1]-'0') * 100 * time.Millisecond
+		rest = rest[2:]
+	}
+
+	var (
+		t   time.Time
+		err error
+	)
+	switch {
+	case rest == "":
+		t, err = time.ParseInLocation(ptpDateTimeFormat, dt, time.Local)
+	case rest == "Z":
+		t, err = time.Parse(ptpDateTimeFormat, dt)
+	default:
+		t, err = time.Parse(ptpDateTimeFormat+"-0700", dt+rest)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date time %s", s)
+	}
+
+	return t.Add(tenths), nil
+}
@@ -0,0 +1,334 @@
+package ip
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func ptpString(s string) []byte {
+	if s == "" {
+		return []byte{0}
+	}
+
+	chars := append(utf16.Encode([]rune(s)), 0)
+	b := []byte{byte(len(chars))}
+	for _, c := range chars {
+		b = append(b, byte(c), byte(c>>8))
+	}
+
+	return b
+}
+
+func objectInfoData(ofc ptp.ObjectFormatCode, name, captured string) []byte {
+	var b bytes.Buffer
+	for _, v := range []interface{}{
+		uint32(0x10001), uint16(ofc), uint16(0), uint32(4096), uint16(ptp.OFC_JFIF), uint32(512), uint32(160),
+		uint32(120), uint32(6000), uint32(4000), uint32(24), uint32(0x20), uint16(0), uint32(0), uint32(0),
+	} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.Write(ptpString(name))
+	b.Write(ptpString(captured))
+	b.Write(ptpString(""))
+	b.Write(ptpString(""))
+
+	return b.Bytes()
+}
+
+func TestParseObjectHandles(t *testing.T) {
+	got, err := parseObjectHandles([]byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x2a, 0x00, 0x01, 0x00})
+	if err != nil {
+		t.Fatalf("parseObjectHandles() error = %s; want <nil>", err)
+	}
+	want := []ptp.ObjectHandle{0x1, 0x1002a}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("parseObjectHandles() got = %v; want %v", got, want)
+	}
+
+	if _, err := parseObjectHandles([]byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}); err == nil {
+		t.Errorf("parseObjectHandles() error = <nil>; want error")
+	}
+}
+
+func TestParseObjectInfo(t *testing.T) {
+	got, err := parseObjectInfo(objectInfoData(ptp.OFC_EXIF_JPEG, "DSCF0001.JPG", "20210314T153045.5Z"))
+	if err != nil {
+		t.Fatalf("parseObjectInfo() error = %s; want <nil>", err)
+	}
+
+	if got.StorageID != 0x10001 || got.ObjectFormat != ptp.OFC_EXIF_JPEG || got.ObjectCompressedSize != 4096 {
+		t.Errorf("parseObjectInfo() got = %+v; want storage 0x10001, format %#x and size 4096", got, ptp.OFC_EXIF_JPEG)
+	}
+	if got.ImagePixWidth != 6000 || got.ImagePixHeight != 4000 || got.ParentObject != 0x20 {
+		t.Errorf("parseObjectInfo() got = %+v; want 6000x4000 with parent 0x20", got)
+	}
+	if got.Filename != "DSCF0001.JPG" {
+		t.Errorf("parseObjectInfo() Filename = %s; want DSCF0001.JPG", got.Filename)
+	}
+	want := time.Date(2021, 3, 14, 15, 30, 45, 500000000, time.UTC)
+	if !got.CaptureDate.Equal(want) {
+		t.Errorf("parseObjectInfo() CaptureDate = %s; want %s", got.CaptureDate, want)
+	}
+	if !got.ModificationDate.IsZero() {
+		t.Errorf("parseObjectInfo() ModificationDate = %s; want zero time", got.ModificationDate)
+	}
+
+	if _, err := parseObjectInfo([]byte{0x01, 0x00}); err == nil {
+		t.Errorf("parseObjectInfo() error = <nil>; want error")
+	}
+}
+
+func TestMarshalObjectInfo(t *testing.T) {
+	oi := &ptp.ObjectInfo{
+		StorageID:            0x10001,
+		ObjectFormat:         ptp.OFC_DPOF,
+		ObjectCompressedSize: 512,
+		ParentObject:         0x30,
+		Filename:             "AUTPRINT.MRK",
+		CaptureDate:          time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local),
+	}
+
+	got, err := parseObjectInfo(marshalObjectInfo(oi))
+	if err != nil {
+		t.Fatalf("parseObjectInfo(marshalObjectInfo()) error = %s; want <nil>", err)
+	}
+	if got.StorageID != oi.StorageID || got.ObjectFormat != oi.ObjectFormat || got.ObjectCompressedSize != 512 || got.ParentObject != 0x30 {
+		t.Errorf("parseObjectInfo(marshalObjectInfo()) got = %+v; want %+v", got, oi)
+	}
+	if got.Filename != oi.Filename || !got.CaptureDate.Equal(oi.CaptureDate) || !got.ModificationDate.IsZero() {
+		t.Errorf("parseObjectInfo(marshalObjectInfo()) got = %+v; want %+v", got, oi)
+	}
+}
+
+func TestParsePTPDateTime(t *testing.T) {
+	check := map[string]time.Time{
+		"20210314T153045":        time.Date(2021, 3, 14, 15, 30, 45, 0, time.Local),
+		"20210314T153045Z":       time.Date(2021, 3, 14, 15, 30, 45, 0, time.UTC),
+		"20210314T153045.2+0100": time.Date(2021, 3, 14, 14, 30, 45, 200000000, time.UTC),
+	}
+
+	for in, want := range check {
+		got, err := parsePTPDateTime(in)
+		if err != nil {
+			t.Errorf("parsePTPDateTime() error = %s; want <nil>", err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("parsePTPDateTime() got = %s; want %s", got, want)
+		}
+	}
+
+	if _, err := parsePTPDateTime("2021-03-14"); err == nil {
+		t.Errorf("parsePTPDateTime() error = <nil>; want error")
+	}
+}
+
+func TestKindOfObject(t *testing.T) {
+	check := []struct {
+		ofc  ptp.ObjectFormatCode
+		name string
+		want ObjectKind
+	}{
+		{ptp.OFC_EXIF_JPEG, "DSCF0001.JPG", ObjectKindJPEG},
+		{ptp.OFC_Association, "100_FUJI", ObjectKindFolder},
+		{ptp.ObjectFormatCode(0xb103), "DSCF0001.RAF", ObjectKindRAW},
+		{ptp.OFC_Undefined, "DSC_0001.NEF", ObjectKindRAW},
+		{ptp.OFC_Undefined, "DSCF0002.MOV", ObjectKindVideo},
+		{ptp.OFC_Text, "README.TXT", ObjectKindOther},
+	}
+
+	for _, tt := range check {
+		if got := KindOfObject(&ptp.ObjectInfo{ObjectFormat: tt.ofc, Filename: tt.name}); got != tt.want {
+			t.Errorf("KindOfObject() got = %s; want %s", got, tt.want)
+		}
+	}
+}
+
+func TestObjectFormatOf(t *testing.T) {
+	check := map[string]ptp.ObjectFormatCode{
+		"DSCF0001.JPG": ptp.OFC_EXIF_JPEG,
+		"scan.tiff":    ptp.OFC_TIFF,
+		"AUTPRINT.MRK": ptp.OFC_DPOF,
+		"DSCF0001.RAF": ptp.OFC_Undefined,
+		"README":       ptp.OFC_Undefined,
+	}
+	for name, want := range check {
+		if got := ObjectFormatOf(name); got != want {
+			t.Errorf("ObjectFormatOf() %s got = %#x; want %#x", name, got, want)
+		}
+	}
+}
+
+func TestObjectFilter_Match(t *testing.T) {
+	jpg := &ptp.ObjectInfo{ObjectFormat: ptp.OFC_EXIF_JPEG, Filename: "DSCF0001.JPG", CaptureDate: time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)}
+	raw := &ptp.ObjectInfo{ObjectFormat: ptp.OFC_Undefined, Filename: "DSCF0001.RAF", CaptureDate: time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)}
+	dir := &ptp.ObjectInfo{ObjectFormat: ptp.OFC_Association, Filename: "100_FUJI"}
+
+	check := []struct {
+		f    ObjectFilter
+		h    ptp.ObjectHandle
+		oi   *ptp.ObjectInfo
+		want bool
+	}{
+		{ObjectFilter{}, 1, jpg, true},
+		{ObjectFilter{}, 1, dir, false},
+		{ObjectFilter{Kinds: []ObjectKind{ObjectKindRAW}}, 1, jpg, false},
+		{ObjectFilter{Kinds: []ObjectKind{ObjectKindRAW, ObjectKindJPEG}}, 1, jpg, true},
+		{ObjectFilter{Since: raw.CaptureDate}, 1, jpg, false},
+		{ObjectFilter{Since: raw.CaptureDate}, 1, raw, true},
+		{ObjectFilter{Until: jpg.CaptureDate}, 1, raw, false},
+		{ObjectFilter{FirstHandle: 2, LastHandle: 4}, 1, jpg, false},
+		{ObjectFilter{FirstHandle: 2, LastHandle: 4}, 4, jpg, true},
+		{ObjectFilter{FirstHandle: 2}, 5, jpg, true},
+	}
+
+	for i, tt := range check {
+		if got := tt.f.Match(tt.h, tt.oi); got != tt.want {
+			t.Errorf("ObjectFilter.Match() %d got = %v; want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestClient_GetNumObjects(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.vendorExtensions.getNumObjects = func(_ *Client, sid ptp.StorageID, _ ptp.ObjectFormatCode, _ ptp.ObjectHandle) (int, error) {
+		if sid == AllStorages {
+			return 0, ptp.ResponseCodeAsError(ptp.RC_OperationNotSupported)
+		}
+		return 5, nil
+	}
+	c.vendorExtensions.getObjectHandles = func(_ *Client, _ ptp.StorageID, _ ptp.ObjectFormatCode, _ ptp.ObjectHandle) ([]ptp.ObjectHandle, error) {
+		return []ptp.ObjectHandle{1, 2, 3}, nil
+	}
+
+	if got, err := c.GetNumObjects(0x00010001, 0, 0); got != 5 || err != nil {
+		t.Errorf("GetNumObjects() got = %d, %v; want 5, <nil>", got, err)
+	}
+	// The handles are counted when the Responder does not support the operation.
+	if got, err := c.GetNumObjects(AllStorages, 0, 0); got != 3 || err != nil {
+		t.Errorf("GetNumObjects() got = %d, %v; want 3, <nil>", got, err)
+	}
+}
+
+func TestClient_SetObjectRating(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		gotCode   ptp.OperationCode
+		gotParams []uint32
+		gotData   []byte
+		rc        = ptp.RC_OK
+	)
+	c.vendorExtensions.sendData = func(_ context.Context, _ *Client, code ptp.OperationCode, params []uint32, data []byte, _ uint64) ([]byte, error) {
+		gotCode, gotParams, gotData = code, params, data
+		return rawPackets(&OperationResponsePacket{OperationResponse: ptp.OperationResponse{ResponseCode: rc, TransactionID: 1}})()
+	}
+
+	if err := c.SetObjectRating(0x20, 75); err != nil {
+		t.Fatalf("SetObjectRating() error = %v; want <nil>", err)
+	}
+	if gotCode != OC_MTP_SetObjectPropValue {
+		t.Errorf("SetObjectRating() code = %#x; want %#x", gotCode, OC_MTP_SetObjectPropValue)
+	}
+	if len(gotParams) != 2 || gotParams[0] != 0x20 || gotParams[1] != uint32(OPC_MTP_Rating) {
+		t.Errorf("SetObjectRating() params = %#x; want [0x20 %#x]", gotParams, OPC_MTP_Rating)
+	}
+	if want := []byte{75, 0}; !bytes.Equal(gotData, want) {
+		t.Errorf("SetObjectRating() data = %#x; want %#x", gotData, want)
+	}
+
+	gotCode = 0
+	if err := c.SetObjectRating(0x20, MTPMaxRating+1); err == nil || gotCode != 0 {
+		t.Errorf("SetObjectRating() error = %v; want error without sending data", err)
+	}
+
+	rc = ptp.RC_OperationNotSupported
+	var ore *ptp.OperationResponseError
+	if err := c.SetObjectRating(0x20, 50); !errors.As(err, &ore) || ore.Code != rc {
+		t.Errorf("SetObjectRating() error = %v; want %s", err, ptp.ResponseCodeAsError(rc))
+	}
+}
+
+func TestClient_DownloadObjects(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.vendorExtensions.getObject = func(_ *Client, h ptp.ObjectHandle) ([]byte, error) {
+		if h == 3 {
+			return nil, ptp.ResponseCodeAsError(ptp.RC_InvalidObjectHandle)
+		}
+		return []byte{byte(h), 0x00}, nil
+	}
+
+	objs := []Object{{Handle: 1}, {Handle: 2}, {Handle: 3}}
+	written := make(map[ptp.ObjectHandle][]byte)
+	var progress []DownloadProgress
+
+	err = c.DownloadObjects(objs,
+		func(o Object) bool { return o.Handle == 2 },
+		func(o Object, data []byte) error {
+			written[o.Handle] = data
+			return nil
+		},
+		func(p DownloadProgress) { progress = append(progress, p) },
+	)
+	if err == nil {
+		t.Fatalf("DownloadObjects() error = <nil>; want error for object 0x3")
+	}
+
+	if len(written) != 1 || !bytes.Equal(written[1], []byte{0x01, 0x00}) {
+		t.Errorf("DownloadObjects() written = %v; want only object 0x1", written)
+	}
+	if len(progress) != 3 {
+		t.Fatalf("DownloadObjects() progress calls = %d; want 3", len(progress))
+	}
+	if p := progress[0]; p.Index != 1 || p.Total != 3 || p.Bytes != 2 || p.Skipped || p.Err != nil {
+		t.Errorf("DownloadObjects() progress = %+v; want object 1 of 3 with 2 bytes", p)
+	}
+	if !progress[1].Skipped {
+		t.Errorf("DownloadObjects() Skipped = false; want true")
+	}
+	if progress[2].Err == nil {
+		t.Errorf("DownloadObjects() Err = <nil>; want error")
+	}
+}
+
+func TestClient_DownloadObjects_rate(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.vendorExtensions.getObject = func(_ *Client, h ptp.ObjectHandle) ([]byte, error) {
+		time.Sleep(10 * time.Millisecond)
+		return make([]byte, 1000), nil
+	}
+
+	objs := []Object{
+		{Handle: 1, Info: &ptp.ObjectInfo{ObjectCompressedSize: 1000}},
+		{Handle: 2, Info: &ptp.ObjectInfo{ObjectCompressedSize: 1000}},
+	}
+	var progress []DownloadProgress
+	c.DownloadObjects(objs, nil,
+		func(Object, []byte) error { return nil },
+		func(p DownloadProgress) { progress = append(progress, p) },
+	)
+
+	if p := progress[0]; p.Rate <= 0 || p.ETA <= 0 {
+		t.Errorf("DownloadObjects() progress = %+v; want a rate and an ETA", p)
+	}
+	if p := progress[1]; p.Rate <= 0 || p.ETA != 0 {
+		t.Errorf("DownloadObjects() progress = %+v; want a rate and no ETA", p)
+	}
+}
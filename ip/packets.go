@@ -1,6 +1,7 @@
 package ip
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 
@@ -224,6 +225,17 @@ func (ifp *InitFailPacket) TotalFixedFieldSize() int {
 	return internal.TotalSizeOfFixedFields(ifp)
 }
 
+// InitFailError is returned by InitFailPacket.ReasonAsError so that the reason can be retrieved from the error using
+// errors.As.
+type InitFailError struct {
+	Reason FailReason
+	msg    string
+}
+
+func (e *InitFailError) Error() string {
+	return e.msg
+}
+
 func (ifp *InitFailPacket) ReasonAsError() error {
 	var msg string
 	switch ifp.Reason {
@@ -242,7 +254,7 @@ func (ifp *InitFailPacket) ReasonAsError() error {
 		msg = fmt.Sprintf("unknown failure reason returned %#x", ifp.Reason)
 	}
 
-	return errors.New(msg)
+	return &InitFailError{Reason: ifp.Reason, msg: msg}
 }
 
 // OperationRequestPacket is used to transport operation requests. PTP-IP Operation Request Packets are issued by the
@@ -265,7 +277,12 @@ func (orp *OperationRequestPacket) PacketType() PacketType {
 }
 
 func (orp *OperationRequestPacket) Payload() []byte {
-	return internal.MarshalLittleEndian(orp)
+	// The unused parameters are sent as well, making up the full operation dataset.
+	p := *orp
+	p.Parameters = make([]uint32, ptp.MaxOperationParameters)
+	copy(p.Parameters, orp.Parameters)
+
+	return internal.MarshalLittleEndian(&p)
 }
 
 // OperationResponsePacket is used to transport Operation Responses by the Responder and are transported to the
@@ -286,6 +303,8 @@ func (orp *OperationResponsePacket) TotalFixedFieldSize() int {
 type EventPacket interface {
 	PacketIn
 	GetEventCode() ptp.EventCode
+	GetTransactionID() ptp.TransactionID
+	GetParameter1() uint32
 }
 
 type EventParameters struct {
@@ -310,6 +329,27 @@ func (ep *GenericEventPacket) GetEventCode() ptp.EventCode {
 	return ep.EventCode
 }
 
+func (ep *GenericEventPacket) GetTransactionID() ptp.TransactionID {
+	return ep.TransactionID
+}
+
+func (ep *GenericEventPacket) GetParameter1() uint32 {
+	p := make([]byte, 4)
+	copy(p, ep.Parameter1)
+	return binary.LittleEndian.Uint32(p)
+}
+
+// setParameters fills the event parameters from the excess data that remains after unmarshalling the packet. The
+// parameters are byte slices which the unmarshaller does not fill, so they always end up in the excess data.
+func (ep *GenericEventPacket) setParameters(xs []byte) {
+	for i, p := range []*[]byte{&ep.Parameter1, &ep.Parameter2, &ep.Parameter3} {
+		if len(xs) < (i+1)*4 {
+			break
+		}
+		*p = xs[i*4 : (i+1)*4]
+	}
+}
+
 func NewEventPacket() EventPacket {
 	return &GenericEventPacket{}
 }
@@ -319,10 +359,14 @@ func NewEventPacket() EventPacket {
 // Command/Data TCP connection.
 type StartDataPacket struct {
 	TransactionId ptp.TransactionID
-	// A value of 0xFFFFFFFFFFFFFFFF indicates that the size of the data is not known at the beginning of the data phase.
+	// A value of UnknownDataLength indicates that the size of the data is not known at the beginning of the data phase.
 	TotalDataLength uint64
 }
 
+// UnknownDataLength is the TotalDataLength of a StartDataPacket when the size of the data is not known at the beginning
+// of the data phase. The data phase then only ends when the EndDataPacket arrives.
+const UnknownDataLength uint64 = 0xFFFFFFFFFFFFFFFF
+
 func (sdp *StartDataPacket) PacketType() PacketType {
 	return PKT_StartData
 }
@@ -344,7 +388,7 @@ func (sdp *StartDataPacket) TotalFixedFieldSize() int {
 // mechanism MAY be utilized to allow for a simple data transfer cancelling mechanism. No error checking is required.
 type DataPacket struct {
 	TransactionId ptp.TransactionID
-	DataPayload   interface{}
+	DataPayload   []byte
 }
 
 func (dp *DataPacket) PacketType() PacketType {
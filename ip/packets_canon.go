@@ -0,0 +1,116 @@
+package ip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+type CanonEVFOutputDevice uint32
+
+const (
+	// OC_Canon_EOS_SetDevicePropValueEx sets the value of a device property. Canon EOS bodies do not support the
+	// standard ptp.OC_SetDevicePropValue operation.
+	OC_Canon_EOS_SetDevicePropValueEx ptp.OperationCode = 0x9110
+	// OC_Canon_EOS_GetViewFinderData returns the current liveview frame. Canon EOS bodies have no streamer connection
+	// so this operation needs to be polled. The first parameter holds the maximum size of the data to be returned.
+	OC_Canon_EOS_GetViewFinderData ptp.OperationCode = 0x9153
+
+	// DPC_Canon_EOS_EVFOutputDevice determines where the liveview is displayed. It must be set to EVF_Canon_PC for
+	// OC_Canon_EOS_GetViewFinderData to return any frames.
+	DPC_Canon_EOS_EVFOutputDevice ptp.DevicePropCode = 0xD1B0
+	// DPC_Canon_EOS_Aperture holds the aperture using Canon's own encoding in steps of 1/8 stop, where 0x08 is f/1.0.
+	DPC_Canon_EOS_Aperture ptp.DevicePropCode = 0xD101
+	// DPC_Canon_EOS_ShutterSpeed holds the shutter speed using Canon's own encoding in steps of 1/8 stop, where 0x38 is
+	// one second.
+	DPC_Canon_EOS_ShutterSpeed ptp.DevicePropCode = 0xD102
+	// DPC_Canon_EOS_ISOSpeed holds the ISO using Canon's own encoding in steps of 1/8 stop, where 0x48 is ISO 100.
+	DPC_Canon_EOS_ISOSpeed ptp.DevicePropCode = 0xD103
+	// DPC_Canon_EOS_ExpCompensation holds the exposure compensation as a signed byte in steps of 1/8 stop.
+	DPC_Canon_EOS_ExpCompensation ptp.DevicePropCode = 0xD104
+
+	EVF_Canon_TFT CanonEVFOutputDevice = 0x00000001
+	EVF_Canon_PC  CanonEVFOutputDevice = 0x00000002
+
+	// RC_Canon_NotReady is returned by OC_Canon_EOS_GetViewFinderData when no new liveview frame is available yet.
+	RC_Canon_NotReady ptp.OperationResponseCode = 0xA102
+)
+
+func init() {
+	ptp.RegisterResponseCode(RC_Canon_NotReady, "Canon_NotReady", "not ready")
+}
+
+const (
+	// canonMaxViewfinderData is passed as the maximum data size to OC_Canon_EOS_GetViewFinderData.
+	canonMaxViewfinderData = 0x00200000
+	// canonViewfinderHeaderSize is the size of the header preceding each block of viewfinder data.
+	canonViewfinderHeaderSize = 8
+)
+
+// CanonToggleLiveView directs the liveview to the Initiator and starts polling for liveview frames using
+// OC_Canon_EOS_GetViewFinderData. Disabling the liveview stops the poller and directs the liveview back to the screen
+// of the camera.
+func CanonToggleLiveView(c *Client, en bool) error {
+	if !en {
+		c.stopLiveviewPoller()
+		return canonSetEVFOutputDevice(c, EVF_Canon_TFT)
+	}
+
+	if err := canonSetEVFOutputDevice(c, EVF_Canon_PC); err != nil {
+		return err
+	}
+	c.startLiveviewPoller("[canonLiveviewPoller]", CanonGetViewfinderData)
+
+	return nil
+}
+
+// CanonGetViewfinderData requests the current liveview frame using OC_Canon_EOS_GetViewFinderData. A nil frame is
+// returned when the Responder has no new frame available yet.
+func CanonGetViewfinderData(c *Client) ([]byte, FrameMeta, error) {
+	data, err := GenericOperationRequestAndGetData(c, OC_Canon_EOS_GetViewFinderData, []uint32{canonMaxViewfinderData})
+	if err != nil {
+		var ore *ptp.OperationResponseError
+		if errors.As(err, &ore) && ore.Code == RC_Canon_NotReady {
+			return nil, FrameMeta{}, nil
+		}
+		return nil, FrameMeta{}, err
+	}
+
+	return canonReadViewfinderData(data)
+}
+
+// canonSetEVFOutputDevice sets DPC_Canon_EOS_EVFOutputDevice using OC_Canon_EOS_SetDevicePropValueEx. The data holds
+// its own size followed by the property code and the value, all of them as an uint32.
+func canonSetEVFOutputDevice(c *Client, dev CanonEVFOutputDevice) error {
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data, uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[4:], uint32(DPC_Canon_EOS_EVFOutputDevice))
+	binary.LittleEndian.PutUint32(data[8:], uint32(dev))
+
+	_, err := c.sendDataAndGetParameters(OC_Canon_EOS_SetDevicePropValueEx, nil, data)
+
+	return err
+}
+
+// canonReadViewfinderData extracts the JPEG image from the data returned by OC_Canon_EOS_GetViewFinderData. The data
+// consists of one or more blocks, each starting with the block length and the block type as an uint32, the length
+// including this header. Only the blocks of type 1, 9 and 11 hold a JPEG image, the others hold information such as the
+// position of the focus frame and are skipped.
+func canonReadViewfinderData(data []byte) ([]byte, FrameMeta, error) {
+	for off := 0; off+canonViewfinderHeaderSize <= len(data); {
+		l := int(binary.LittleEndian.Uint32(data[off:]))
+		if l < canonViewfinderHeaderSize || off+l > len(data) {
+			return nil, FrameMeta{}, fmt.Errorf("invalid viewfinder data block length %d at offset %d", l, off)
+		}
+
+		switch binary.LittleEndian.Uint32(data[off+4:]) {
+		case 1, 9, 11:
+			return data[off+canonViewfinderHeaderSize : off+l], FrameMeta{Header: data[off : off+canonViewfinderHeaderSize]}, nil
+		}
+		off += l
+	}
+
+	return nil, FrameMeta{}, errors.New("viewfinder data holds no JPEG image")
+}
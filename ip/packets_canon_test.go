@@ -0,0 +1,36 @@
+package ip
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCanonReadViewfinderData(t *testing.T) {
+	data := []byte{
+		0x0c, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04,
+		0x0c, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0xff, 0xd8, 0xff, 0xd9,
+	}
+
+	img, meta, err := canonReadViewfinderData(data)
+	if err != nil {
+		t.Fatalf("canonReadViewfinderData() err = %s; want <nil>", err)
+	}
+	if want := []byte{0xff, 0xd8, 0xff, 0xd9}; !bytes.Equal(img, want) {
+		t.Errorf("canonReadViewfinderData() img = %#x; want %#x", img, want)
+	}
+	if want := data[12:20]; !bytes.Equal(meta.Header, want) {
+		t.Errorf("canonReadViewfinderData() Header = %#x; want %#x", meta.Header, want)
+	}
+}
+
+func TestCanonReadViewfinderData_invalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x0c, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04},
+		{0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0xff, 0xd8},
+		{0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00},
+	} {
+		if _, _, err := canonReadViewfinderData(data); err == nil {
+			t.Errorf("canonReadViewfinderData(%#x) err = <nil>; want error", data)
+		}
+	}
+}
@@ -369,7 +369,7 @@ func (forp *FujiOperationResponsePacket) WasSuccessful(rc ptp.OperationResponseC
 
 // ReasonAsError returns an error based on the operation response code.
 func (forp *FujiOperationResponsePacket) ReasonAsError() error {
-	return ptp.OperationResponseCodeAsError(forp.OperationResponseCode)
+	return ptp.ResponseCodeAsError(forp.OperationResponseCode)
 }
 
 // FujiEventPacket is the Fuji version of the PTP/IP EventPacket which again deviates from the standard. 'Over the wire'
@@ -417,6 +417,14 @@ func (fep *FujiEventPacket) GetEventCode() ptp.EventCode {
 	return fep.EventCode
 }
 
+func (fep *FujiEventPacket) GetTransactionID() ptp.TransactionID {
+	return fep.TransactionID
+}
+
+func (fep *FujiEventPacket) GetParameter1() uint32 {
+	return fep.Parameter1
+}
+
 func (fep *FujiEventPacket) PacketType() PacketType {
 	return PKT_Invalid
 }
@@ -425,9 +433,9 @@ func (fep *FujiEventPacket) TotalFixedFieldSize() int {
 	return internal.TotalSizeOfFixedFields(fep)
 }
 
-// func NewFujiEventPacket() EventPacket {
-// 	return &FujiEventPacket{}
-// }
+func NewFujiEventPacket() EventPacket {
+	return &FujiEventPacket{}
+}
 
 // FujiExtractTransactionId extracts the transaction ID from a full raw inbound packet. This packet must include the
 // full header containing length and packet type.
@@ -467,9 +475,10 @@ func FujiExtractTransactionId(p []byte, ct connectionType) (ptp.TransactionID, e
 //   4. We will wait for 30 seconds for an acknowledgement from the Responder which means the user has pressed the 'OK'
 //      button on the camera.
 //   5. Next we will request the value of device property DPC_Fuji_AppVersion which holds the current minimal
-//      application version supported by the Responder and we will simply acknowledge it by setting it to the same
-//      value.
-//      This way we will always support any future versions as required by the firmware; unless of course a newer init
+//      application version supported by the Responder and we will acknowledge it by setting it to the application
+//      version of the Initiator, see fujiNegotiateAppVersion().
+//      Unless an application version is set using Client.SetAppVersion(), we report the version the Responder requires
+//      so that we will always support any future versions as required by the firmware; unless of course a newer init
 //      sequence should be required.
 //   6. Finally, we send the operation request OC_InitiateOpenCapture which makes the Responder hand over control to the
 //      Initiator. This also opens up the event connection port 55741 used by Fuji so we can connect to it and complete
@@ -477,11 +486,15 @@ func FujiExtractTransactionId(p []byte, ct connectionType) (ptp.TransactionID, e
 func FujiInitCommandDataConn(c *Client) error {
 	// The first part of the sequence is according to the PTP/IP standard, save for the different packet format.
 	if err := GenericInitCommandDataConn(c); err != nil {
+		var ife *InitFailError
+		if errors.As(err, &ife) && ife.Reason == FR_Fuji_InvalidParameter {
+			return fmt.Errorf("%w: protocol version %#x was refused, the camera firmware might require a newer protocol version", err, uint32(c.newCmdDataInitPacket().GetProtocolVersion()))
+		}
 		return err
 	}
 
 	c.Info("Opening a session...")
-	if err := FujiSendOperationRequestIgnoreResponse(c, ptp.OC_OpenSession, 0x00000001, 0); err != nil {
+	if err := FujiSendOperationRequestIgnoreResponse(c, ptp.OC_OpenSession, uint32(c.sessionId), 0); err != nil {
 		return err
 	}
 
@@ -491,13 +504,7 @@ func FujiInitCommandDataConn(c *Client) error {
 		return err
 	}
 
-	c.Info("Getting current minimum application version...")
-	val, err := FujiGetDevicePropertyValue(c, DPC_Fuji_AppVersion)
-	if err != nil {
-		return err
-	}
-	c.Infof("Acknowledging current minimal application version as communicated by the %s: %#x", c.ResponderFriendlyName(), val)
-	if err := FujiSetDeviceProperty(c, DPC_Fuji_AppVersion, val); err != nil {
+	if err := fujiNegotiateAppVersion(c); err != nil {
 		return err
 	}
 
@@ -509,10 +516,109 @@ func FujiInitCommandDataConn(c *Client) error {
 	return nil
 }
 
+// FujiAppVersionError is returned when the camera firmware requires a newer application version than the one set
+// using Client.SetAppVersion().
+type FujiAppVersionError struct {
+	Required uint32
+	Offered  uint32
+}
+
+func (e *FujiAppVersionError) Error() string {
+	return fmt.Sprintf("fuji: the camera firmware requires app version %s or newer, got %s", formatFujiAppVersion(e.Required), formatFujiAppVersion(e.Offered))
+}
+
+// formatFujiAppVersion formats an application version as used by DPC_Fuji_AppVersion: the major version is held by the
+// 16 MSBs and the minor version by the 16 LSBs.
+func formatFujiAppVersion(v uint32) string {
+	return fmt.Sprintf("%d.%d", v>>16, v&0xFFFF)
+}
+
+// fujiNegotiateAppVersion requests the minimal application version the Responder accepts and acknowledges it by
+// reporting the application version of the Initiator:
+//   - when no application version was set using Client.SetAppVersion(), the one required by the Responder is reported.
+//   - when the application version set is older than the one required, a FujiAppVersionError is returned.
+//   - when the application version set is newer than the one required but the Responder refuses it, the one required
+//     is reported instead.
+func fujiNegotiateAppVersion(c *Client) error {
+	c.Info("Getting current minimum application version...")
+	req, err := FujiGetDevicePropertyValue(c, DPC_Fuji_AppVersion)
+	if err != nil {
+		return err
+	}
+	if req > PM_Fuji_AppVersion {
+		c.Warnf("The %s requires app version %s, which is newer than version %s this client is known to work with.", c.ResponderFriendlyName(), formatFujiAppVersion(req), formatFujiAppVersion(PM_Fuji_AppVersion))
+	}
+
+	offer := c.appVersion
+	if offer == 0 {
+		offer = req
+	}
+	if offer < req {
+		return &FujiAppVersionError{Required: req, Offered: offer}
+	}
+
+	c.Infof("Reporting app version %s to the %s, which requires version %s.", formatFujiAppVersion(offer), c.ResponderFriendlyName(), formatFujiAppVersion(req))
+	err = FujiSetDeviceProperty(c, DPC_Fuji_AppVersion, offer)
+	if err != nil && offer != req {
+		c.Infof("The %s refused app version %s (%s), falling back to version %s.", c.ResponderFriendlyName(), formatFujiAppVersion(offer), err, formatFujiAppVersion(req))
+		err = FujiSetDeviceProperty(c, DPC_Fuji_AppVersion, req)
+	}
+	if err != nil {
+		return fmt.Errorf("fuji: app version not accepted: %w", err)
+	}
+
+	return nil
+}
+
+// FujiCloseSession closes the session opened by FujiInitCommandDataConn, which makes the Responder end the remote
+// control mode right away instead of waiting for the connection to time out.
+func FujiCloseSession(c *Client) error {
+	return FujiSendOperationRequestIgnoreResponse(c, ptp.OC_CloseSession, PM_Fuji_NoParam, 0)
+}
+
+// FujiNewSession always fails: the camera only accepts a single Initiator, which makes any additional connection fail
+// with FR_Fuji_DeviceBusy.
+func FujiNewSession(_ *Client) (*Client, error) {
+	return nil, errors.New("fuji: the camera supports a single session only")
+}
+
+// fujiChangeClientInterval is the time to wait between the connection attempts of FujiChangeClient.
+var fujiChangeClientInterval = time.Second
+
+// FujiChangeClient keeps dialing the Responder for as long as it refuses the Initiator with FR_Fuji_DeviceBusy, waiting
+// at most timeout for the user to act on the camera. This is the case when:
+//   - the Responder stores the friendly name of another Initiator: the user must select 'change' on the camera, after
+//     which it will prompt to accept the new Initiator.
+//   - the Responder timed out waiting for a connection and displays 'not found': the user must select 'retry' on the
+//     camera.
+func FujiChangeClient(c *Client, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.Dial()
+
+		var ife *InitFailError
+		if !errors.As(err, &ife) || ife.Reason != FR_Fuji_DeviceBusy || time.Now().After(deadline) {
+			return err
+		}
+
+		c.Infof("The camera refuses the connection: select 'change' or 'retry' on the camera to accept %s.", c.InitiatorFriendlyName())
+		time.Sleep(fujiChangeClientInterval)
+	}
+}
+
+// FujiResetConnection closes the session and all connections and sets them up again using FujiChangeClient, so that
+// the user can allow the new connection on the camera when it refuses it.
+func FujiResetConnection(c *Client) error {
+	return c.resetConnection(func() error {
+		return FujiChangeClient(c, DefaultChangeClientTimeout)
+	})
+}
+
 // FujiProcessStreamData reads raw image data from the incoming stream and sends them to the streamer channel.
 func FujiProcessStreamData(c *Client) error {
 	go func() {
 		c.Info("[fujiStreamListener] subscribing stream listener to streamer connection...")
+		var prev *FrameMeta
 		for {
 			select {
 			case <-c.closeStreamChan:
@@ -521,20 +627,28 @@ func FujiProcessStreamData(c *Client) error {
 				c.StreamChan = nil
 				return
 			default:
-				if data, err := c.ReadRawFromStreamConn(); err == nil {
-					// As always, packet length first.
-					l := binary.LittleEndian.Uint32(data[:4])
-					c.Debugf("[fujiStreamListener] Packet length %d", l)
-
-					// Four bytes always zero followed by what is clearly a counter which resets on 0xff, so one byte
-					// only.
-					count := binary.LittleEndian.Uint16(append(data[8:9], 0))
-					c.Debugf("[fujiStreamListener] Image number %d", count)
-
-					// Unknown what the next 9 bytes are, but they always END in two bytes with unknown significance
-					// (seen 0xff, 0xff as well as 0x5e, 0x49 and 0x4b, 0xbf) after which the image data begins, filling
-					// the rest of the packet.
-					c.StreamChan <- data[18:]
+				if buf, err := c.readFrameFromStreamConn(); err == nil {
+					data := buf.b
+					meta, img, err := fujiReadFrameHeader(data)
+					if err != nil {
+						buf.release()
+						c.Warnf("[fujiStreamListener] %s", err)
+						continue
+					}
+					if prev != nil {
+						meta.Skipped = fujiSkippedFrames(prev.Counter, meta.Counter)
+					}
+					prev = &meta
+					c.Debugf("[fujiStreamListener] Packet length %d, image number %d", len(data), meta.Counter)
+
+					c.publishBufferedFrame(buf, img, meta)
+				} else {
+					// The frame counter cannot be trusted to tell how many frames were skipped across a reconnect.
+					prev = nil
+					if !c.reconnectStream(err) {
+						// Wait for the streamer to be closed down.
+						<-c.closeStreamChan
+					}
 				}
 			}
 		}
@@ -543,12 +657,50 @@ func FujiProcessStreamData(c *Client) error {
 	return nil
 }
 
+// fujiReadFrameHeader splits a raw liveview packet in its metadata and the JPEG image data. The packet is laid out as
+// follows:
+//   - the packet length (4 bytes)
+//   - four bytes always set to zero
+//   - a frame counter which resets on 0xff, so one byte only
+//   - nine bytes of which the meaning is unknown, but they always END in two bytes with unknown significance (seen
+//     0xff, 0xff as well as 0x5e, 0x49 and 0x4b, 0xbf)
+//   - the JPEG image data, filling the rest of the packet
+// Should the image data not start where it is expected, we look for the JPEG start of image marker instead.
+func fujiReadFrameHeader(data []byte) (FrameMeta, []byte, error) {
+	const imgOffset = 18
+
+	if len(data) < imgOffset+2 {
+		return FrameMeta{}, nil, fmt.Errorf("liveview packet too small: got length %d", len(data))
+	}
+
+	offset := imgOffset
+	if data[offset] != 0xff || data[offset+1] != 0xd8 {
+		i := bytes.Index(data[8:], []byte{0xff, 0xd8})
+		if i == -1 {
+			return FrameMeta{}, nil, errors.New("liveview packet holds no JPEG image")
+		}
+		offset = i + 8
+	}
+
+	meta := FrameMeta{
+		Counter: uint32(data[8]),
+		Header:  data[4:offset],
+	}
+
+	return meta, data[offset:], nil
+}
+
+// fujiSkippedFrames returns the number of frames missed between two consecutive frame counters, taking into account
+// that the counter rolls over after 0xff.
+func fujiSkippedFrames(prev, cur uint32) int {
+	return int(uint8(cur-prev) - 1)
+}
+
 // FujiSetDeviceProperty sets a device property to the given value.
 func FujiSetDeviceProperty(c *Client, code ptp.DevicePropCode, val uint32) error {
-	tid := c.incrementTransactionId()
-
 	resCh := make(chan []byte, 2)
-	if err := c.subscribe(tid, resCh); err != nil {
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
 		return err
 	}
 	defer c.unsubscribe(tid)
@@ -598,7 +750,7 @@ func FujiGetDevicePropertyValue(c *Client, dpc ptp.DevicePropCode) (uint32, erro
 }
 
 // FujiSendOperationRequest sends an operation request to the camera and returns a channel that will receive the
-// response messages as a raw byte array.
+// response messages as a raw byte array. The channel is owned by the client: do not close it.
 // If a parameter is not required, simply pass in PM_Fuji_NoParam!
 func FujiSendOperationRequest(c *Client, code ptp.OperationCode, param uint32) (chan []byte, error) {
 	resCh := make(chan []byte, 2)
@@ -612,18 +764,23 @@ func FujiSendOperationRequest(c *Client, code ptp.OperationCode, param uint32) (
 // the given response channel has been subscribed to.
 // If a parameter is not required, simply pass in PM_Fuji_NoParam!
 func FujiSendOperationRequestWithChan(c *Client, code ptp.OperationCode, param uint32, resCh chan []byte) (ptp.TransactionID, error) {
-	tid := c.incrementTransactionId()
-
-	if err := c.subscribe(tid, resCh); err != nil {
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
 		return 0, err
 	}
 
-	return tid, c.SendPacketToCmdDataConn(&FujiOperationRequestPacket{
+	err = c.SendPacketToCmdDataConn(&FujiOperationRequestPacket{
 		DataPhaseInfo: uint16(DP_NoDataOrDataIn),
 		OperationCode: code,
 		TransactionID: tid,
 		Parameter1:    param,
 	})
+	if err != nil {
+		// The transaction never started, so it must not keep others from starting.
+		c.unsubscribe(tid)
+	}
+
+	return tid, err
 }
 
 // FujiSendOperationRequestIgnoreResponse sends an operation request to the camera. If a parameter is not required,
@@ -643,10 +800,12 @@ func FujiSendOperationRequestIgnoreResponse(c *Client, code ptp.OperationCode, p
 // The byte array being returned may contain excess dat that could not be unmarshalled. This will often be the case so
 // check this data to see if it is not nil and handle it accordingly.
 func FujiSendOperationRequestAndGetResponse(c *Client, code ptp.OperationCode, param uint32, pSize int) (uint32, []byte, error) {
-	resCh, err := FujiSendOperationRequest(c, code, param)
+	resCh := make(chan []byte, 2)
+	tid, err := FujiSendOperationRequestWithChan(c, code, param, resCh)
 	if err != nil {
 		return 0, nil, err
 	}
+	defer c.unsubscribe(tid)
 
 	p := new(FujiOperationResponsePacket)
 	_, xs, err := c.WaitForPacketFromCommandDataSubscriber(resCh, p)
@@ -738,6 +897,30 @@ func FujiSendOperationRequestAndGetRawResponse(c *Client, code ptp.OperationCode
 	return raw, err
 }
 
+// FujiOperationRequestAndGetData wraps FujiSendOperationRequestAndGetRawResponse and returns the payload of the data
+// packets sent by the Responder. An error is returned when the Responder does not answer with ptp.RC_OK.
+func FujiOperationRequestAndGetData(c *Client, code ptp.OperationCode, params []uint32) ([]byte, error) {
+	raw, err := FujiSendOperationRequestAndGetRawResponse(c, code, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for _, r := range raw {
+		size, rc, final := FujiInspectResponse(r)
+		if !final {
+			data = append(data, r[len(r)-size:]...)
+			continue
+		}
+		if rc != ptp.RC_OK {
+			return nil, ptp.ResponseCodeAsError(rc)
+		}
+		return data, nil
+	}
+
+	return nil, ReadResponseError
+}
+
 // FujiGetDevicePropDesc retrieves the description for the given device property code. Beware that this method can
 // return no error and at the same time return nil for *ptp.DevicePropDesc! This means that the requested device
 // property cannot be described: the camera gave a response but returned no property data.
@@ -920,6 +1103,7 @@ func FujiGetDeviceState(c *Client) (interface{}, error) {
 // from the camera in order for the ptp.EC_CaptureComplete to be sent out.
 // Failing to do this, will not allow the client to release the shutter again. The operation request will be accepted
 // but no further actions will be taken by the camera.
+// Errors occurring once the shutter has been released are returned as a *CaptureError.
 func FujiInitiateCapture(c *Client) ([]byte, error) {
 	c.Infof("Releasing %s shutter...", c.ResponderFriendlyName())
 	if err := FujiSendOperationRequestIgnoreResponse(c, ptp.OC_InitiateCapture, PM_Fuji_NoParam, 0); err != nil {
@@ -932,7 +1116,7 @@ func FujiInitiateCapture(c *Client) ([]byte, error) {
 		select {
 		case msg := <-c.EventChan:
 			if msg.GetEventCode() != ec {
-				return nil, fmt.Errorf(invalidEvent, ec, msg.GetEventCode())
+				return nil, &CaptureError{Released: true, Err: fmt.Errorf(invalidEvent, ec, msg.GetEventCode())}
 			}
 			var txt string
 			var extra string
@@ -941,28 +1125,28 @@ func FujiInitiateCapture(c *Client) ([]byte, error) {
 				txt = "object added"
 			case EC_Fuji_PreviewAvailable:
 				txt = "preview available"
-				// pvSize = int(msg.(*FujiEventPacket).Parameter2)
+				pvSize = int(msg.(*FujiEventPacket).Parameter2)
 				extra = fmt.Sprintf(": preview size is %d bytes", pvSize)
 			}
 			c.Debugf("Received %s event (%#x)%s.", txt, msg.GetEventCode(), extra)
 		case <-time.After(DefaultReadTimeout):
-			return nil, WaitForEventError
+			return nil, &CaptureError{Released: true, Err: &TimeoutError{Connection: string(eventConnection), Waited: DefaultReadTimeout, Err: WaitForEventError}}
 		}
 	}
 
 	raw, err := FujiSendOperationRequestAndGetRawResponse(c, OC_Fuji_GetCapturePreview, nil)
 	if err != nil {
-		return nil, err
+		return nil, &CaptureError{Released: true, Err: err}
 	}
 
 	select {
 	case msg := <-c.EventChan:
 		if msg.GetEventCode() != ptp.EC_CaptureComplete {
-			return nil, fmt.Errorf("invalid event received, expected '%#x' got '%#x'", ptp.EC_CaptureComplete, msg.GetEventCode())
+			return nil, &CaptureError{Released: true, Err: fmt.Errorf("invalid event received, expected '%#x' got '%#x'", ptp.EC_CaptureComplete, msg.GetEventCode())}
 		}
 		c.Debugf("Received capture complete event (%#x).", msg.GetEventCode())
 	case <-time.After(DefaultReadTimeout):
-		return nil, WaitForEventError
+		return nil, &CaptureError{Released: true, Err: &TimeoutError{Connection: string(eventConnection), Waited: DefaultReadTimeout, Err: WaitForEventError}}
 	}
 
 	var img []byte
@@ -972,7 +1156,7 @@ func FujiInitiateCapture(c *Client) ([]byte, error) {
 		case code == uint16(ptp.RC_OK):
 			break
 		case code != uint16(OC_Fuji_GetCapturePreview):
-			return nil, errors.New("failed reading image data")
+			return nil, &CaptureError{Released: true, Err: errors.New("failed reading image data")}
 		}
 		img = append(img, pkt[12:]...)
 	}
@@ -2,12 +2,15 @@ package ip
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/malc0mn/ptp-ip/ptp"
 	"io/ioutil"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewFujiInitCommandRequestPacket(t *testing.T) {
@@ -88,6 +91,105 @@ func TestFujiInitCommandDataConn(t *testing.T) {
 	}
 }
 
+func TestFujiInitCommandDataConn_appVersion(t *testing.T) {
+	check := []struct {
+		v       uint32
+		wantErr bool
+	}{
+		{0, false},
+		{PM_Fuji_AppVersion, false},
+		// The camera refuses the newer version, so we fall back to the one it requires.
+		{0x00030000, false},
+		{0x00010005, true},
+	}
+
+	for _, tt := range check {
+		c, err := NewClient("fuji", address, fujiCmdPort, "testèr", "67bace55-e7a4-4fbc-8e31-5122ee73a17c", logLevel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetAppVersion(tt.v)
+
+		err = c.initCommandDataConn()
+		var ave *FujiAppVersionError
+		if got := errors.As(err, &ave); got != tt.wantErr {
+			t.Errorf("FujiInitCommandDataConn() app version %#x error = %v; want FujiAppVersionError %v", tt.v, err, tt.wantErr)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("FujiInitCommandDataConn() app version %#x error = %s; want <nil>", tt.v, err)
+		}
+		c.Close()
+	}
+}
+
+func TestFujiAppVersionError_Error(t *testing.T) {
+	got := (&FujiAppVersionError{Required: 0x00020001, Offered: 0x00010005}).Error()
+	want := "fuji: the camera firmware requires app version 2.1 or newer, got 1.5"
+	if got != want {
+		t.Errorf("Error() got = %s; want %s", got, want)
+	}
+}
+
+func TestFujiCloseSession(t *testing.T) {
+	c, err := NewClient("fuji", address, fujiCmdPort, "testèr", "9a4e7c2b-3f1d-4e8a-b6c5-2d7f1e9a0c34", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := c.TransactionId() + 1
+	if err := FujiCloseSession(c); err != nil {
+		t.Errorf("FujiCloseSession() error = %s; want <nil>", err)
+	}
+	if got := c.TransactionId(); got != want {
+		t.Errorf("TransactionId() got = %#x; want %#x", got, want)
+	}
+}
+
+func TestFujiChangeClient(t *testing.T) {
+	ln, err := net.Listen("tcp", address+":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// The camera refuses the first two connections until the user selects 'change'.
+	go func() {
+		evtChan := make(chan uint32, 10)
+		for refused := 0; ; refused++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if refused < 2 {
+				alwaysFailMessageWithReason(conn, FR_Fuji_DeviceBusy, "[Mocked busy fuji responder]")
+				continue
+			}
+			go handleFujiMessages(conn, evtChan, "[Mocked busy fuji responder]")
+		}
+	}()
+
+	defer func(d time.Duration) { fujiChangeClientInterval = d }(fujiChangeClientInterval)
+	fujiChangeClientInterval = 10 * time.Millisecond
+
+	c, err := NewClient("fuji", address, uint16(ln.Addr().(*net.TCPAddr).Port), "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.ChangeClient(0); err == nil {
+		t.Errorf("ChangeClient() error = <nil>; want %s", (&InitFailPacket{Reason: FR_Fuji_DeviceBusy}).ReasonAsError())
+	}
+	if err := c.ChangeClient(time.Second); err != nil {
+		t.Errorf("ChangeClient() error = %s; want <nil>", err)
+	}
+}
+
 func TestFujiSetDeviceProperty(t *testing.T) {
 	c, err := NewClient("fuji", address, fujiCmdPort, "testèr", "67bace55-e7a4-4fbc-8e31-5122ee73a17c", logLevel)
 	defer c.Close()
@@ -161,8 +263,8 @@ func TestFujiSendOperationRequest(t *testing.T) {
 	}
 
 	// We use close session here because our fuji mock will not respond to it.
-	resCh, err := FujiSendOperationRequest(c, ptp.OC_CloseSession, PM_Fuji_NoParam)
-	defer close(resCh)
+	// The channel is owned by the client, which closes it when the connection is closed.
+	_, err = FujiSendOperationRequest(c, ptp.OC_CloseSession, PM_Fuji_NoParam)
 	if err != nil {
 		t.Errorf("FujiSendOperationRequest() error = %s; want <nil>", err)
 	}
@@ -225,6 +327,29 @@ func TestFujiSendOperationRequestAndGetRawResponse(t *testing.T) {
 	}
 }
 
+func TestFujiOperationRequestAndGetData(t *testing.T) {
+	c, err := NewClient("fuji", address, fujiCmdPort, "testèr", "67bace55-e7a4-4fbc-8e31-5122ee73a17c", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FujiOperationRequestAndGetData(c, ptp.OC_GetDevicePropDesc, []uint32{uint32(DPC_Fuji_FilmSimulation)})
+	if err != nil {
+		t.Errorf("FujiOperationRequestAndGetData() error = %s; want <nil>", err)
+	}
+
+	want := []byte{0x01, 0xd0, 0x04, 0x00, 0x01, 0x01, 0x00, 0x01, 0x00, 0x02, 0x0b, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04, 0x00, 0x05, 0x00, 0x06, 0x00, 0x07, 0x00, 0x08, 0x00, 0x09, 0x00, 0x0a, 0x00, 0x0b, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("FujiOperationRequestAndGetData() got = %#v; want %#v", got, want)
+	}
+}
+
 func TestFujiGetDevicePropertyDesc(t *testing.T) {
 	c, err := NewClient("fuji", address, fujiCmdPort, "testèr", "67bace55-e7a4-4fbc-8e31-5122ee73a17c", logLevel)
 	defer c.Close()
@@ -513,3 +638,51 @@ func TestFujiInitiateCapture(t *testing.T) {
 		t.Errorf("FujiInitiateCapture() imgdata = %#v; want %#v", got, want)
 	}
 }
+
+func TestFujiReadFrameHeader(t *testing.T) {
+	img := []byte{0xff, 0xd8, 0xff, 0xe0, 0xff, 0xd9}
+	hdr := []byte{0x00, 0x00, 0x00, 0x00, 0x2a, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x5e, 0x49}
+	data := append(append([]byte{0x18, 0x00, 0x00, 0x00}, hdr...), img...)
+
+	meta, got, err := fujiReadFrameHeader(data)
+	if err != nil {
+		t.Fatalf("fujiReadFrameHeader() err = %s; want <nil>", err)
+	}
+	if !bytes.Equal(got, img) {
+		t.Errorf("fujiReadFrameHeader() img = %#v; want %#v", got, img)
+	}
+	if meta.Counter != 0x2a {
+		t.Errorf("fujiReadFrameHeader() Counter = %d; want %d", meta.Counter, 0x2a)
+	}
+	if !bytes.Equal(meta.Header, hdr) {
+		t.Errorf("fujiReadFrameHeader() Header = %#v; want %#v", meta.Header, hdr)
+	}
+
+	// A longer header must be detected by looking for the JPEG start of image marker.
+	data = append(append([]byte{0x1a, 0x00, 0x00, 0x00}, append(hdr, 0x00, 0x00)...), img...)
+	if _, got, _ = fujiReadFrameHeader(data); !bytes.Equal(got, img) {
+		t.Errorf("fujiReadFrameHeader() img = %#v; want %#v", got, img)
+	}
+
+	if _, _, err = fujiReadFrameHeader(data[:10]); err == nil {
+		t.Error("fujiReadFrameHeader() err = <nil>; want error")
+	}
+}
+
+func TestFujiSkippedFrames(t *testing.T) {
+	check := []struct {
+		prev, cur uint32
+		want      int
+	}{
+		{1, 2, 0},
+		{1, 4, 2},
+		{0xfe, 0x00, 1},
+		{0xff, 0x00, 0},
+	}
+
+	for _, tt := range check {
+		if got := fujiSkippedFrames(tt.prev, tt.cur); got != tt.want {
+			t.Errorf("fujiSkippedFrames(%#x, %#x) return = %d; want %d", tt.prev, tt.cur, got, tt.want)
+		}
+	}
+}
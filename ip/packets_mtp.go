@@ -0,0 +1,33 @@
+package ip
+
+import (
+	"encoding/binary"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// MTPObjectPropCode identifies an object property as defined by the Media Transfer Protocol, which extends PTP with
+// properties describing the objects on the Responder beyond their ObjectInfo dataset.
+type MTPObjectPropCode uint16
+
+const (
+	// OC_MTP_SetObjectPropValue sets the value of an object property. The first parameter holds the handle of the
+	// object, the second one the object property code, the value being sent in the data phase.
+	OC_MTP_SetObjectPropValue ptp.OperationCode = 0x9804
+
+	// OPC_MTP_Rating holds the rating of an object as an uint16 ranging from 0 for unrated to 100.
+	OPC_MTP_Rating MTPObjectPropCode = 0xDC8A
+)
+
+// MTPMaxRating is the highest rating OPC_MTP_Rating can hold.
+const MTPMaxRating = 100
+
+// MTPSetObjectPropUint16 sets an object property of type uint16 using OC_MTP_SetObjectPropValue.
+func MTPSetObjectPropUint16(c *Client, h ptp.ObjectHandle, code MTPObjectPropCode, val uint16) error {
+	data := make([]byte, 2)
+	binary.LittleEndian.PutUint16(data, val)
+
+	_, err := c.sendDataAndGetParameters(OC_MTP_SetObjectPropValue, []uint32{uint32(h), uint32(code)}, data)
+
+	return err
+}
@@ -0,0 +1,220 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+const (
+	// OC_Nikon_CheckEvent returns the list of events that have been queued by the Responder since the last call. Nikon
+	// bodies do not reliably push all events over the event connection, so this operation needs to be polled.
+	OC_Nikon_CheckEvent ptp.OperationCode = 0x90C7
+	// OC_Nikon_StartLiveView switches the Responder to liveview mode. The Responder will report ptp.RC_DeviceBusy for
+	// a short while after this operation, until the mirror is up and the sensor is ready.
+	OC_Nikon_StartLiveView ptp.OperationCode = 0x9201
+	// OC_Nikon_EndLiveView ends the liveview mode.
+	OC_Nikon_EndLiveView ptp.OperationCode = 0x9202
+	// OC_Nikon_GetLiveViewImage returns the current liveview frame preceded by a header describing it. Nikon bodies
+	// have no streamer connection so this operation needs to be polled.
+	OC_Nikon_GetLiveViewImage ptp.OperationCode = 0x9203
+)
+
+const (
+	// RC_Nikon_NotLiveView is returned by OC_Nikon_GetLiveViewImage when the Responder is not in liveview mode.
+	RC_Nikon_NotLiveView ptp.OperationResponseCode = 0xA00B
+)
+
+func init() {
+	ptp.RegisterResponseCode(RC_Nikon_NotLiveView, "Nikon_NotLiveView", "not in liveview mode")
+}
+
+const (
+	// DPC_Nikon_LiveViewImageZoomRatio holds the liveview magnification as a single byte ranging from 0, the entire
+	// frame, to 5, which shows the sensor pixels at 100%.
+	DPC_Nikon_LiveViewImageZoomRatio ptp.DevicePropCode = 0xD1A3
+	// DPC_Nikon_ExposureTime holds the shutter speed as a fraction of seconds: the numerator is stored in the most
+	// significant 16 bits and the denominator in the least significant 16 bits. A value of 0xFFFFFFFF means bulb.
+	DPC_Nikon_ExposureTime ptp.DevicePropCode = 0xD100
+)
+
+// nikonMaxLiveviewZoom is the highest value DPC_Nikon_LiveViewImageZoomRatio accepts.
+const nikonMaxLiveviewZoom = 5
+
+// NikonPollEvents polls the Responder using OC_Nikon_CheckEvent at a DefaultPollInterval and dispatches all events it
+// reports like the events received on the event connection. Polling stops when the event connection is closed or when
+// the Responder tells us it does not support the operation.
+func NikonPollEvents(c *Client) error {
+	c.startEventPoller(func(stop <-chan struct{}) {
+		lmp := "[nikonEventPoller]"
+		c.Debugf("%s polling for unreported events...", lmp)
+		for {
+			select {
+			case <-stop:
+				c.Debugf("%s stopping event poller.", lmp)
+				return
+			case <-time.After(DefaultPollInterval):
+				data, err := GenericOperationRequestAndGetData(c, OC_Nikon_CheckEvent, nil)
+				if err != nil {
+					var ore *ptp.OperationResponseError
+					if errors.As(err, &ore) && ore.Code == ptp.RC_OperationNotSupported {
+						c.Warnf("%s %s, stopping event poller.", lmp, err)
+						return
+					}
+					c.Debugf("%s %s", lmp, err)
+					continue
+				}
+
+				evts, err := nikonReadEvents(data)
+				if err != nil {
+					c.Errorf("%s %s", lmp, err)
+					continue
+				}
+
+				if !c.dispatchPolledEvents(evts, stop) {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// nikonReadEvents parses the data returned by OC_Nikon_CheckEvent. The data starts with the number of events as an
+// uint16 followed by an uint16 event code and a single uint32 parameter for each event.
+func nikonReadEvents(data []byte) ([]EventPacket, error) {
+	r := bytes.NewReader(data)
+
+	var num uint16
+	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+		return nil, err
+	}
+
+	evts := make([]EventPacket, num)
+	for i := 0; i < int(num); i++ {
+		var code ptp.EventCode
+		if err := binary.Read(r, binary.LittleEndian, &code); err != nil {
+			return nil, err
+		}
+
+		param := make([]byte, 4)
+		if err := binary.Read(r, binary.LittleEndian, param); err != nil {
+			return nil, err
+		}
+
+		evts[i] = &GenericEventPacket{
+			Event: ptp.Event{
+				EventCode:     code,
+				TransactionID: 0xFFFFFFFF,
+				Parameter1:    param,
+			},
+		}
+	}
+
+	return evts, nil
+}
+
+// NikonSetLiveviewZoom sets the liveview magnification using DPC_Nikon_LiveViewImageZoomRatio.
+func NikonSetLiveviewZoom(c *Client, level int) (int, error) {
+	if level > nikonMaxLiveviewZoom {
+		level = nikonMaxLiveviewZoom
+	}
+
+	_, err := c.sendDataAndGetParameters(ptp.OC_SetDevicePropValue, []uint32{uint32(DPC_Nikon_LiveViewImageZoomRatio)}, []byte{uint8(level)})
+
+	return level, err
+}
+
+// NikonLiveviewInfo holds the information decoded from the header preceding each liveview frame returned by
+// OC_Nikon_GetLiveViewImage. All sizes and coordinates are expressed in pixels. The coordinates of the display area and
+// the AF frame are relative to the whole image.
+type NikonLiveviewInfo struct {
+	// ImageWidth and ImageHeight hold the size of the JPEG image.
+	ImageWidth  uint16
+	ImageHeight uint16
+	// WholeWidth and WholeHeight hold the size of the whole liveview image, which is larger than the JPEG image when
+	// the liveview is magnified.
+	WholeWidth  uint16
+	WholeHeight uint16
+	// DisplayWidth and DisplayHeight hold the size of the area of the whole image that is displayed.
+	DisplayWidth  uint16
+	DisplayHeight uint16
+	// DisplayX and DisplayY hold the center of the displayed area.
+	DisplayX uint16
+	DisplayY uint16
+	// AFWidth and AFHeight hold the size of the AF frame.
+	AFWidth  uint16
+	AFHeight uint16
+	// AFX and AFY hold the center of the AF frame.
+	AFX uint16
+	AFY uint16
+}
+
+// nikonLiveviewInfoSize is the size of the part of the liveview header that is decoded into a NikonLiveviewInfo. The
+// full header is larger and its size varies with the model.
+const nikonLiveviewInfoSize = 24
+
+// NikonReadLiveviewInfo decodes the liveview header as found in the FrameMeta of the frames published for Nikon bodies.
+// Unlike the rest of the protocol, the header fields are big endian.
+func NikonReadLiveviewInfo(header []byte) (*NikonLiveviewInfo, error) {
+	if len(header) < nikonLiveviewInfoSize {
+		return nil, fmt.Errorf("liveview header too small: got length %d", len(header))
+	}
+
+	info := &NikonLiveviewInfo{}
+	if err := binary.Read(bytes.NewReader(header), binary.BigEndian, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// NikonToggleLiveView starts or ends the liveview mode using OC_Nikon_StartLiveView and OC_Nikon_EndLiveView and starts
+// or stops polling for liveview frames accordingly.
+func NikonToggleLiveView(c *Client, en bool) error {
+	if !en {
+		c.stopLiveviewPoller()
+		_, err := GenericOperationRequestAndGetData(c, OC_Nikon_EndLiveView, nil)
+		return err
+	}
+
+	if _, err := GenericOperationRequestAndGetData(c, OC_Nikon_StartLiveView, nil); err != nil {
+		return err
+	}
+	c.startLiveviewPoller("[nikonLiveviewPoller]", NikonGetLiveViewImage)
+
+	return nil
+}
+
+// NikonGetLiveViewImage requests the current liveview frame using OC_Nikon_GetLiveViewImage. A nil frame is returned
+// while the Responder is still preparing the liveview.
+func NikonGetLiveViewImage(c *Client) ([]byte, FrameMeta, error) {
+	data, err := GenericOperationRequestAndGetData(c, OC_Nikon_GetLiveViewImage, nil)
+	if err != nil {
+		var ore *ptp.OperationResponseError
+		if errors.As(err, &ore) && ore.Code == ptp.RC_DeviceBusy {
+			return nil, FrameMeta{}, nil
+		}
+		return nil, FrameMeta{}, err
+	}
+
+	return nikonReadLiveviewData(data)
+}
+
+// nikonReadLiveviewData splits the data returned by OC_Nikon_GetLiveViewImage in the header and the JPEG image data.
+// The size of the header depends on the model, so the image data is found by looking for the JPEG start of image
+// marker. As the header might hold the bytes of that marker by coincidence, the marker must be followed by the start of
+// the next JPEG marker.
+func nikonReadLiveviewData(data []byte) ([]byte, FrameMeta, error) {
+	i := bytes.Index(data, []byte{0xff, 0xd8, 0xff})
+	if i == -1 {
+		return nil, FrameMeta{}, errors.New("liveview data holds no JPEG image")
+	}
+
+	return data[i:], FrameMeta{Header: data[:i]}, nil
+}
@@ -0,0 +1,116 @@
+package ip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestNikonReadEvents(t *testing.T) {
+	data := []byte{
+		0x02, 0x00,
+		0x02, 0x40, 0x2a, 0x00, 0x01, 0x00,
+		0x06, 0x40, 0x07, 0x50, 0x00, 0x00,
+	}
+
+	got, err := nikonReadEvents(data)
+	if err != nil {
+		t.Fatalf("nikonReadEvents() err = %s; want <nil>", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("nikonReadEvents() length = %d; want 2", len(got))
+	}
+
+	want := []struct {
+		code  ptp.EventCode
+		param []byte
+	}{
+		{ptp.EC_ObjectAdded, []byte{0x2a, 0x00, 0x01, 0x00}},
+		{ptp.EC_DevicePropChanged, []byte{0x07, 0x50, 0x00, 0x00}},
+	}
+	for i, w := range want {
+		if got[i].GetEventCode() != w.code {
+			t.Errorf("nikonReadEvents() EventCode = %#x; want %#x", got[i].GetEventCode(), w.code)
+		}
+		p := got[i].(*GenericEventPacket).Parameter1
+		if string(p) != string(w.param) {
+			t.Errorf("nikonReadEvents() Parameter1 = %#x; want %#x", p, w.param)
+		}
+	}
+}
+
+func TestNikonReadEvents_truncated(t *testing.T) {
+	if _, err := nikonReadEvents([]byte{0x01, 0x00, 0x02, 0x40}); err == nil {
+		t.Error("nikonReadEvents() err = <nil>; want error")
+	}
+}
+
+func TestNikonReadLiveviewData(t *testing.T) {
+	header := []byte{
+		0x02, 0x80, 0x01, 0xe0, 0x17, 0x00, 0x0f, 0x50, 0x0b, 0x80, 0x07, 0xa8,
+		0x0b, 0x80, 0x07, 0xa8, 0x01, 0x00, 0x00, 0xc0, 0x05, 0xc0, 0x03, 0xd4,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	img := []byte{0xff, 0xd8, 0xff, 0xd9}
+
+	got, meta, err := nikonReadLiveviewData(append(append([]byte{}, header...), img...))
+	if err != nil {
+		t.Fatalf("nikonReadLiveviewData() err = %s; want <nil>", err)
+	}
+	if !bytes.Equal(got, img) {
+		t.Errorf("nikonReadLiveviewData() img = %#x; want %#x", got, img)
+	}
+	if !bytes.Equal(meta.Header, header) {
+		t.Errorf("nikonReadLiveviewData() Header = %#x; want %#x", meta.Header, header)
+	}
+
+	info, err := NikonReadLiveviewInfo(meta.Header)
+	if err != nil {
+		t.Fatalf("NikonReadLiveviewInfo() err = %s; want <nil>", err)
+	}
+	want := NikonLiveviewInfo{
+		ImageWidth: 640, ImageHeight: 480,
+		WholeWidth: 5888, WholeHeight: 3920,
+		DisplayWidth: 2944, DisplayHeight: 1960,
+		DisplayX: 2944, DisplayY: 1960,
+		AFWidth: 256, AFHeight: 192,
+		AFX: 1472, AFY: 980,
+	}
+	if *info != want {
+		t.Errorf("NikonReadLiveviewInfo() got = %+v; want %+v", *info, want)
+	}
+}
+
+func TestNikonReadLiveviewData_invalid(t *testing.T) {
+	if _, _, err := nikonReadLiveviewData([]byte{0x01, 0x02, 0x03}); err == nil {
+		t.Error("nikonReadLiveviewData() err = <nil>; want error")
+	}
+	if _, err := NikonReadLiveviewInfo([]byte{0x02, 0x80}); err == nil {
+		t.Error("NikonReadLiveviewInfo() err = <nil>; want error")
+	}
+}
+
+func TestNikonSetLiveviewZoom(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := ptp.RC_OK
+	c.vendorExtensions.sendData = func(_ context.Context, _ *Client, _ ptp.OperationCode, _ []uint32, _ []byte, _ uint64) ([]byte, error) {
+		return rawPackets(&OperationResponsePacket{OperationResponse: ptp.OperationResponse{ResponseCode: rc, TransactionID: 1}})()
+	}
+
+	if got, err := NikonSetLiveviewZoom(c, nikonMaxLiveviewZoom+1); got != nikonMaxLiveviewZoom || err != nil {
+		t.Errorf("NikonSetLiveviewZoom() got = %d, %v; want %d, <nil>", got, err, nikonMaxLiveviewZoom)
+	}
+
+	// The zoom is not set when the Responder refuses it, e.g. because the liveview is not active.
+	rc = RC_Nikon_NotLiveView
+	var ore *ptp.OperationResponseError
+	if _, err := NikonSetLiveviewZoom(c, 2); !errors.As(err, &ore) || ore.Code != rc {
+		t.Errorf("NikonSetLiveviewZoom() error = %v; want %s", err, ptp.ResponseCodeAsError(rc))
+	}
+}
@@ -0,0 +1,244 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+const (
+	// OC_Sony_GetAllDevicePropData returns the description of all device properties in a single data phase. Sony
+	// bodies do not send a DevicePropChanged event over the event connection for every property that changes, so this
+	// operation needs to be polled to detect the changes.
+	OC_Sony_GetAllDevicePropData ptp.OperationCode = 0x9209
+)
+
+const (
+	// DPC_Sony_ShutterSpeed holds the shutter speed as a fraction of seconds: the numerator is stored in the most
+	// significant 16 bits and the denominator in the least significant 16 bits. A value of 0 means bulb.
+	DPC_Sony_ShutterSpeed ptp.DevicePropCode = 0xD20D
+	// DPC_Sony_ISO holds the ISO in the least significant 24 bits, where 0xFFFFFF means automatic ISO. The most
+	// significant byte enables multi frame noise reduction.
+	DPC_Sony_ISO ptp.DevicePropCode = 0xD21E
+)
+
+const (
+	// OH_Sony_Liveview is the object handle to pass to ptp.OC_GetObject to retrieve the current liveview frame. Sony
+	// bodies have no streamer connection so this object needs to be polled.
+	OH_Sony_Liveview ptp.ObjectHandle = 0xFFFFC002
+)
+
+// SonyPollEvents polls the Responder using OC_Sony_GetAllDevicePropData at a DefaultPollInterval and compares the
+// current value of each property to the value returned by the previous poll. A ptp.EC_DevicePropChanged event is
+// dispatched like the events received on the event connection for each property that has changed. Polling stops when
+// the event connection is closed or when the Responder tells us it does not support the operation.
+func SonyPollEvents(c *Client) error {
+	c.startEventPoller(func(stop <-chan struct{}) {
+		lmp := "[sonyEventPoller]"
+		c.Debugf("%s polling for unreported property changes...", lmp)
+		var prev map[ptp.DevicePropCode][]byte
+		for {
+			select {
+			case <-stop:
+				c.Debugf("%s stopping event poller.", lmp)
+				return
+			case <-time.After(DefaultPollInterval):
+				data, err := GenericOperationRequestAndGetData(c, OC_Sony_GetAllDevicePropData, nil)
+				if err != nil {
+					var ore *ptp.OperationResponseError
+					if errors.As(err, &ore) && ore.Code == ptp.RC_OperationNotSupported {
+						c.Warnf("%s %s, stopping event poller.", lmp, err)
+						return
+					}
+					c.Debugf("%s %s", lmp, err)
+					continue
+				}
+
+				list, err := sonyReadAllDevicePropData(data)
+				if err != nil {
+					c.Errorf("%s %s", lmp, err)
+					continue
+				}
+
+				cur := make(map[ptp.DevicePropCode][]byte, len(list))
+				for _, dpd := range list {
+					cur[dpd.DevicePropertyCode] = dpd.CurrentValue
+				}
+
+				evts := sonyChangedPropertyEvents(prev, cur)
+				prev = cur
+
+				if !c.dispatchPolledEvents(evts, stop) {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// sonyChangedPropertyEvents returns a ptp.EC_DevicePropChanged event for each property in cur whose value differs from
+// the one in prev. When prev is nil, this is the first poll and no events are returned.
+func sonyChangedPropertyEvents(prev, cur map[ptp.DevicePropCode][]byte) []EventPacket {
+	if prev == nil {
+		return nil
+	}
+
+	var evts []EventPacket
+	for code, val := range cur {
+		if old, ok := prev[code]; ok && bytes.Equal(old, val) {
+			continue
+		}
+
+		param := make([]byte, 4)
+		binary.LittleEndian.PutUint32(param, uint32(code))
+		evts = append(evts, &GenericEventPacket{
+			Event: ptp.Event{
+				EventCode:     ptp.EC_DevicePropChanged,
+				TransactionID: 0xFFFFFFFF,
+				Parameter1:    param,
+			},
+		})
+	}
+
+	return evts
+}
+
+// sonyReadAllDevicePropData parses the data returned by OC_Sony_GetAllDevicePropData. The data starts with the number
+// of properties as an uint64 followed by the property descriptions.
+func sonyReadAllDevicePropData(data []byte) ([]*ptp.DevicePropDesc, error) {
+	r := bytes.NewReader(data)
+
+	var num uint64
+	if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+		return nil, err
+	}
+
+	var list []*ptp.DevicePropDesc
+	for i := uint64(0); i < num; i++ {
+		dpd, err := sonyReadDevicePropDesc(r)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, dpd)
+	}
+
+	return list, nil
+}
+
+// sonyReadDevicePropDesc reads a single Sony device property description. It follows the PTP specification with the
+// exception of an additional byte right after the GetSet field indicating if the property is currently enabled.
+func sonyReadDevicePropDesc(r io.Reader) (*ptp.DevicePropDesc, error) {
+	dpd := new(ptp.DevicePropDesc)
+	if err := binary.Read(r, binary.LittleEndian, &dpd.DevicePropertyCode); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &dpd.DataType); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &dpd.GetSet); err != nil {
+		return nil, err
+	}
+
+	// Skip the 'is enabled' field.
+	var enabled uint8
+	if err := binary.Read(r, binary.LittleEndian, &enabled); err != nil {
+		return nil, err
+	}
+
+	if err := readDevicePropValues(r, dpd); err != nil {
+		return nil, err
+	}
+
+	return dpd, nil
+}
+
+// SonyLiveviewInfo holds the information decoded from the header preceding each liveview frame returned for
+// OH_Sony_Liveview. All offsets are relative to the start of the data.
+type SonyLiveviewInfo struct {
+	ImageOffset uint32
+	ImageSize   uint32
+	// FrameInfoOffset and FrameInfoSize locate the frame information, such as the position of the focus frames. Both
+	// are 0 when the Responder sends no frame information.
+	FrameInfoOffset uint32
+	FrameInfoSize   uint32
+	// FrameInfo holds the raw frame information. It is nil when the frame information is not part of the header.
+	FrameInfo []byte
+}
+
+// sonyLiveviewHeaderSize is the size of the fixed part of the liveview header.
+const sonyLiveviewHeaderSize = 16
+
+// SonyReadLiveviewInfo decodes the liveview header as found in the FrameMeta of the frames published for Sony bodies.
+func SonyReadLiveviewInfo(header []byte) (*SonyLiveviewInfo, error) {
+	if len(header) < sonyLiveviewHeaderSize {
+		return nil, fmt.Errorf("liveview header too small: got length %d", len(header))
+	}
+
+	info := &SonyLiveviewInfo{
+		ImageOffset:     binary.LittleEndian.Uint32(header),
+		ImageSize:       binary.LittleEndian.Uint32(header[4:]),
+		FrameInfoOffset: binary.LittleEndian.Uint32(header[8:]),
+		FrameInfoSize:   binary.LittleEndian.Uint32(header[12:]),
+	}
+	if info.FrameInfoSize > 0 && uint64(info.FrameInfoOffset)+uint64(info.FrameInfoSize) <= uint64(len(header)) {
+		info.FrameInfo = header[info.FrameInfoOffset : info.FrameInfoOffset+info.FrameInfoSize]
+	}
+
+	return info, nil
+}
+
+// SonyToggleLiveView starts or stops polling for liveview frames. Sony bodies do not need to be told to start the
+// liveview: the liveview object is available as soon as the session has been opened.
+func SonyToggleLiveView(c *Client, en bool) error {
+	if !en {
+		c.stopLiveviewPoller()
+		return nil
+	}
+
+	c.startLiveviewPoller("[sonyLiveviewPoller]", SonyGetLiveviewImage)
+
+	return nil
+}
+
+// SonyGetLiveviewImage requests the current liveview frame using ptp.OC_GetObject for OH_Sony_Liveview. A nil frame is
+// returned when the Responder has no frame available yet, which it reports using ptp.RC_AccessDenied.
+func SonyGetLiveviewImage(c *Client) ([]byte, FrameMeta, error) {
+	data, err := GenericOperationRequestAndGetData(c, ptp.OC_GetObject, []uint32{uint32(OH_Sony_Liveview)})
+	if err != nil {
+		var ore *ptp.OperationResponseError
+		if errors.As(err, &ore) && ore.Code == ptp.RC_AccessDenied {
+			return nil, FrameMeta{}, nil
+		}
+		return nil, FrameMeta{}, err
+	}
+
+	return sonyReadLiveviewData(data)
+}
+
+// sonyReadLiveviewData extracts the JPEG image from the liveview data using the offset and size found in its header.
+// Everything preceding the image is handed over as the frame header.
+func sonyReadLiveviewData(data []byte) ([]byte, FrameMeta, error) {
+	info, err := SonyReadLiveviewInfo(data)
+	if err != nil {
+		return nil, FrameMeta{}, err
+	}
+
+	start, end := uint64(info.ImageOffset), uint64(info.ImageOffset)+uint64(info.ImageSize)
+	if start < sonyLiveviewHeaderSize || end > uint64(len(data)) || info.ImageSize < 2 {
+		return nil, FrameMeta{}, fmt.Errorf("invalid liveview image offset %d and size %d for length %d", start, info.ImageSize, len(data))
+	}
+
+	img := data[start:end]
+	if img[0] != 0xff || img[1] != 0xd8 {
+		return nil, FrameMeta{}, fmt.Errorf("liveview data holds no JPEG image at offset %d", start)
+	}
+
+	return img, FrameMeta{Header: data[:start]}, nil
+}
@@ -0,0 +1,117 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestSonyReadAllDevicePropData(t *testing.T) {
+	data := []byte{
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// FNumber, uint16, get/set, enabled, factory 280, current 560, enum of 2 values.
+		0x07, 0x50, 0x04, 0x00, 0x01, 0x01, 0x18, 0x01, 0x30, 0x02, 0x02, 0x02, 0x00, 0x18, 0x01, 0x30, 0x02,
+		// Artist, string, get only, enabled, empty factory value, "Hi", no form.
+		0x1e, 0x50, 0xff, 0xff, 0x00, 0x01, 0x00, 0x02, 0x48, 0x00, 0x69, 0x00, 0x00,
+	}
+
+	got, err := sonyReadAllDevicePropData(data)
+	if err != nil {
+		t.Fatalf("sonyReadAllDevicePropData() err = %s; want <nil>", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("sonyReadAllDevicePropData() length = %d; want 2", len(got))
+	}
+
+	if got[0].DevicePropertyCode != ptp.DPC_FNumber {
+		t.Errorf("sonyReadAllDevicePropData() DevicePropertyCode = %#x; want %#x", got[0].DevicePropertyCode, ptp.DPC_FNumber)
+	}
+	if got[0].CurrentValueAsInt64() != 560 {
+		t.Errorf("sonyReadAllDevicePropData() CurrentValue = %d; want 560", got[0].CurrentValueAsInt64())
+	}
+	if form, ok := got[0].Form.(*ptp.EnumerationForm); !ok || form.NumberOfValues != 2 {
+		t.Errorf("sonyReadAllDevicePropData() Form = %#v; want enumeration form with 2 values", got[0].Form)
+	}
+
+	if got[1].DevicePropertyCode != ptp.DPC_Artist {
+		t.Errorf("sonyReadAllDevicePropData() DevicePropertyCode = %#x; want %#x", got[1].DevicePropertyCode, ptp.DPC_Artist)
+	}
+	want := "\x02H\x00i\x00"
+	if string(got[1].CurrentValue) != want {
+		t.Errorf("sonyReadAllDevicePropData() CurrentValue = %#x; want %#x", got[1].CurrentValue, want)
+	}
+}
+
+func TestSonyChangedPropertyEvents(t *testing.T) {
+	cur := map[ptp.DevicePropCode][]byte{
+		ptp.DPC_FNumber:      {0x30, 0x02},
+		ptp.DPC_ExposureTime: {0x01, 0x00, 0x00, 0x00},
+	}
+
+	if got := sonyChangedPropertyEvents(nil, cur); got != nil {
+		t.Errorf("sonyChangedPropertyEvents() return = %v; want <nil>", got)
+	}
+
+	prev := map[ptp.DevicePropCode][]byte{
+		ptp.DPC_FNumber:      {0x18, 0x01},
+		ptp.DPC_ExposureTime: {0x01, 0x00, 0x00, 0x00},
+	}
+
+	got := sonyChangedPropertyEvents(prev, cur)
+	if len(got) != 1 {
+		t.Fatalf("sonyChangedPropertyEvents() length = %d; want 1", len(got))
+	}
+	if got[0].GetEventCode() != ptp.EC_DevicePropChanged {
+		t.Errorf("sonyChangedPropertyEvents() EventCode = %#x; want %#x", got[0].GetEventCode(), ptp.EC_DevicePropChanged)
+	}
+	code := ptp.DevicePropCode(binary.LittleEndian.Uint32(got[0].(*GenericEventPacket).Parameter1))
+	if code != ptp.DPC_FNumber {
+		t.Errorf("sonyChangedPropertyEvents() Parameter1 = %#x; want %#x", code, ptp.DPC_FNumber)
+	}
+}
+
+func TestSonyReadLiveviewData(t *testing.T) {
+	data := []byte{
+		0x18, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00,
+		0x10, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		0xff, 0xd8, 0xff, 0xd9, 0x00, 0x00,
+	}
+
+	img, meta, err := sonyReadLiveviewData(data)
+	if err != nil {
+		t.Fatalf("sonyReadLiveviewData() err = %s; want <nil>", err)
+	}
+	if want := []byte{0xff, 0xd8, 0xff, 0xd9}; !bytes.Equal(img, want) {
+		t.Errorf("sonyReadLiveviewData() img = %#x; want %#x", img, want)
+	}
+	if want := data[:24]; !bytes.Equal(meta.Header, want) {
+		t.Errorf("sonyReadLiveviewData() Header = %#x; want %#x", meta.Header, want)
+	}
+
+	info, err := SonyReadLiveviewInfo(meta.Header)
+	if err != nil {
+		t.Fatalf("SonyReadLiveviewInfo() err = %s; want <nil>", err)
+	}
+	if info.ImageOffset != 24 || info.ImageSize != 4 || info.FrameInfoOffset != 16 || info.FrameInfoSize != 8 {
+		t.Errorf("SonyReadLiveviewInfo() got = %+v; want offsets 24, 16 and sizes 4, 8", info)
+	}
+	if want := data[16:24]; !bytes.Equal(info.FrameInfo, want) {
+		t.Errorf("SonyReadLiveviewInfo() FrameInfo = %#x; want %#x", info.FrameInfo, want)
+	}
+}
+
+func TestSonyReadLiveviewData_invalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x10, 0x00, 0x00, 0x00},
+		{0x10, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xd8},
+		{0x10, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02},
+		{0x04, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xd8},
+	} {
+		if _, _, err := sonyReadLiveviewData(data); err == nil {
+			t.Errorf("sonyReadLiveviewData(%#x) err = <nil>; want error", data)
+		}
+	}
+}
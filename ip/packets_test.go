@@ -1,6 +1,7 @@
 package ip
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/malc0mn/ptp-ip/ptp"
@@ -73,10 +74,15 @@ func TestInitFailPacket_ReasonAsError(t *testing.T) {
 		ifp := InitFailPacket{
 			Reason: reason,
 		}
-		got := ifp.ReasonAsError().Error()
+		err := ifp.ReasonAsError()
+		got := err.Error()
 		if got != want {
 			t.Errorf("ReasonAsError() Reason = '%s'; want '%s'", got, want)
 		}
+		var ife *InitFailError
+		if !errors.As(err, &ife) || ife.Reason != reason {
+			t.Errorf("ReasonAsError() errors.As() = %v; want InitFailError with reason %#x", ife, reason)
+		}
 	}
 }
 
@@ -193,3 +199,19 @@ func TestOperationRequestPacket_Payload(t *testing.T) {
 		t.Errorf("payload() buffer = %s; want %s", got, want)
 	}
 }
+
+func TestOperationRequestPacket_PayloadParameters(t *testing.T) {
+	oreq := &OperationRequestPacket{
+		DataPhaseInfo:    DP_NoDataOrDataIn,
+		OperationRequest: ptp.GetObjectInfo(0x1a),
+	}
+
+	got := fmt.Sprintf("%#x", oreq.Payload())
+	want := "0x010000000810000000001a00000000000000000000000000000000000000"
+	if got != want {
+		t.Errorf("payload() buffer = %s; want %s", got, want)
+	}
+	if len(oreq.Parameters) != 1 {
+		t.Errorf("payload() Parameters = %v; want the request left untouched", oreq.Parameters)
+	}
+}
@@ -0,0 +1,174 @@
+package ip
+
+import (
+	"errors"
+	"io"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+const (
+	// partialChunkSize is the number of bytes requested at once using ptp.OC_GetPartialObject when reading metadata.
+	partialChunkSize = 64 * 1024
+	// objectReaderChunkSize is the number of bytes requested at once by an ObjectReader, which is larger than
+	// partialChunkSize since the data is usually read sequentially, e.g. when playing a video.
+	objectReaderChunkSize = 512 * 1024
+	// partialMaxChunks is the number of chunks a partialObjectReader keeps.
+	partialMaxChunks = 16
+)
+
+// GetPartialObject retrieves up to max bytes of the data of the given object, starting at offset. Fewer bytes are
+// returned at the end of the object.
+func (c *Client) GetPartialObject(h ptp.ObjectHandle, offset, max uint32) ([]byte, error) {
+	return c.vendorExtensions.getPartialObject(c, h, offset, max)
+}
+
+// GetObjectMetadata reads the EXIF metadata of the given JPEG image or RAW file. Only the parts of the object holding
+// the metadata are requested from the Responder, which usually amounts to a few tens of kilobytes even for large RAW
+// files.
+func (c *Client) GetObjectMetadata(h ptp.ObjectHandle) (*ObjectMetadata, error) {
+	return ReadMetadata(&partialObjectReader{c: c, h: h, chunkSize: partialChunkSize})
+}
+
+// OpenObject returns a reader for the data of the given object, which only requests the parts of the object that are
+// read, e.g. to play a video without downloading it first. Since the offset passed to ptp.OC_GetPartialObject is an
+// uint32, only the first 4 GB of an object can be read.
+func (c *Client) OpenObject(h ptp.ObjectHandle) (*ObjectReader, error) {
+	oi, err := c.GetObjectInfo(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectReader{
+		pr:   partialObjectReader{c: c, h: h, chunkSize: objectReaderChunkSize},
+		info: oi,
+		size: int64(oi.ObjectCompressedSize),
+	}, nil
+}
+
+// ObjectReader reads the data of an object using ptp.OC_GetPartialObject. It implements io.ReadSeeker and io.ReaderAt,
+// making it suitable for http.ServeContent. An ObjectReader is not safe for concurrent use.
+type ObjectReader struct {
+	pr   partialObjectReader
+	info *ptp.ObjectInfo
+	size int64
+	off  int64
+}
+
+// Info returns the ObjectInfo dataset of the object.
+func (r *ObjectReader) Info() *ptp.ObjectInfo {
+	return r.info
+}
+
+// Size returns the size of the object in bytes.
+func (r *ObjectReader) Size() int64 {
+	return r.size
+}
+
+// Read implements io.Reader.
+func (r *ObjectReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *ObjectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if rest := r.size - off; int64(len(p)) > rest {
+		n, err := r.pr.ReadAt(p[:rest], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+
+	return r.pr.ReadAt(p, off)
+}
+
+// Seek implements io.Seeker.
+func (r *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.off = offset
+
+	return offset, nil
+}
+
+// GenericGetPartialObject requests part of the data of the given object from the Responder.
+func GenericGetPartialObject(c *Client, h ptp.ObjectHandle, offset, max uint32) ([]byte, error) {
+	return GenericOperationRequestAndGetData(c, ptp.OC_GetPartialObject, []uint32{uint32(h), offset, max})
+}
+
+// partialObjectReader reads an object using ptp.OC_GetPartialObject. The data is requested in chunks of chunkSize,
+// which are kept so that reading small fields close to each other, such as the entries of an IFD, results in a single
+// request.
+type partialObjectReader struct {
+	c         *Client
+	h         ptp.ObjectHandle
+	chunkSize int64
+	chunks    map[int64][]byte
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *partialObjectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	n := 0
+	for n < len(p) {
+		start := (off + int64(n)) / r.chunkSize * r.chunkSize
+		chunk, err := r.chunk(start)
+		if err != nil {
+			return n, err
+		}
+		pos := int(off + int64(n) - start)
+		if pos >= len(chunk) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], chunk[pos:])
+		if int64(len(chunk)) < r.chunkSize && n < len(p) {
+			return n, io.EOF
+		}
+	}
+
+	return n, nil
+}
+
+// chunk returns the chunk starting at the given offset, requesting it from the Responder when needed.
+func (r *partialObjectReader) chunk(start int64) ([]byte, error) {
+	if b, ok := r.chunks[start]; ok {
+		return b, nil
+	}
+	if start > 0xFFFFFFFF {
+		return nil, io.EOF
+	}
+
+	b, err := r.c.GetPartialObject(r.h, uint32(start), uint32(r.chunkSize))
+	if err != nil {
+		return nil, err
+	}
+	if r.chunks == nil || len(r.chunks) >= partialMaxChunks {
+		r.chunks = make(map[int64][]byte)
+	}
+	r.chunks[start] = b
+
+	return b, nil
+}
@@ -0,0 +1,65 @@
+package ip
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestObjectReader(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := make([]byte, objectReaderChunkSize*2+100)
+	for i := range obj {
+		obj[i] = byte(i)
+	}
+	c.vendorExtensions.getObjectInfo = func(_ *Client, _ ptp.ObjectHandle) (*ptp.ObjectInfo, error) {
+		return &ptp.ObjectInfo{Filename: "DSCF0001.MOV", ObjectCompressedSize: uint32(len(obj))}, nil
+	}
+	var requests []uint32
+	c.vendorExtensions.getPartialObject = func(_ *Client, _ ptp.ObjectHandle, offset, max uint32) ([]byte, error) {
+		requests = append(requests, offset)
+		end := int(offset + max)
+		if end > len(obj) {
+			end = len(obj)
+		}
+		return obj[offset:end], nil
+	}
+
+	r, err := c.OpenObject(0x1a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(obj)) || r.Info().Filename != "DSCF0001.MOV" {
+		t.Errorf("OpenObject() got size %d and file name %s; want %d and DSCF0001.MOV", r.Size(), r.Info().Filename, len(obj))
+	}
+
+	// Only the chunk holding the end of the object is requested.
+	if pos, err := r.Seek(-50, io.SeekEnd); pos != int64(len(obj)-50) || err != nil {
+		t.Errorf("Seek() got = %d, %v; want %d, <nil>", pos, err, len(obj)-50)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, obj[len(obj)-50:]) {
+		t.Errorf("ReadAll() got %d bytes, %v; want the last 50 bytes, <nil>", len(got), err)
+	}
+	if len(requests) != 1 || requests[0] != objectReaderChunkSize*2 {
+		t.Errorf("ReadAll() requested offsets %v; want [%d]", requests, objectReaderChunkSize*2)
+	}
+
+	// Reading across chunks.
+	b := make([]byte, 200)
+	if n, err := r.ReadAt(b, objectReaderChunkSize-100); n != 200 || err != nil || !bytes.Equal(b, obj[objectReaderChunkSize-100:objectReaderChunkSize+100]) {
+		t.Errorf("ReadAt() got = %d, %v; want 200, <nil> and the matching data", n, err)
+	}
+
+	if n, err := r.ReadAt(b, int64(len(obj)-10)); n != 10 || err != io.EOF {
+		t.Errorf("ReadAt() got = %d, %v; want 10, EOF", n, err)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek() error = <nil>; want error")
+	}
+}
@@ -0,0 +1,110 @@
+package ip
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// SetPipelineDepth sets the number of transactions the bulk methods, such as GetObjectInfos and
+// GetDevicePropertyValues, keep in flight on the command/data connection. The responses are matched to their requests
+// by transaction ID, so that the round trip time is paid once for every depth transactions instead of once for each
+// transaction. This considerably speeds up scanning a memory card holding many objects.
+// Pipelining is off by default, i.e. a depth of 1: most Responders handle a single transaction at a time and fail or
+// stop responding when receiving the next operation request early. Only raise the depth for Responders known to
+// tolerate it. The depth limits all transactions, so those of the event and liveview pollers count as well.
+func (c *Client) SetPipelineDepth(depth int) {
+	if depth < 1 {
+		depth = 1
+	}
+	c.pipelineDepth = depth
+}
+
+// PipelineDepth returns the number of transactions the bulk methods keep in flight.
+func (c *Client) PipelineDepth() int {
+	if c.pipelineDepth < 1 {
+		return 1
+	}
+
+	return c.pipelineDepth
+}
+
+// pipeline calls f for the indexes 0 to n-1, keeping at most PipelineDepth calls in flight. When pipelining is off,
+// the calls are made in order and the first error stops the remaining calls. Otherwise, all calls are made and the
+// error returned is the one of the lowest index.
+func (c *Client) pipeline(n int, f func(i int) error) error {
+	depth := c.PipelineDepth()
+	if depth == 1 {
+		for i := 0; i < n; i++ {
+			if err := f(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, depth)
+		errs = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetObjectInfos returns the ObjectInfo datasets of the given objects in the same order, pipelining the requests when
+// enabled using SetPipelineDepth.
+func (c *Client) GetObjectInfos(handles []ptp.ObjectHandle) ([]*ptp.ObjectInfo, error) {
+	infos := make([]*ptp.ObjectInfo, len(handles))
+	err := c.pipeline(len(handles), func(i int) error {
+		oi, err := c.GetObjectInfo(handles[i])
+		if err != nil {
+			return fmt.Errorf("object %#x: %w", handles[i], err)
+		}
+		infos[i] = oi
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// GetDevicePropertyValues returns the values of the given device properties in the same order, pipelining the requests
+// when enabled using SetPipelineDepth.
+func (c *Client) GetDevicePropertyValues(codes []ptp.DevicePropCode) ([]uint32, error) {
+	vals := make([]uint32, len(codes))
+	err := c.pipeline(len(codes), func(i int) error {
+		v, err := c.GetDevicePropertyValue(codes[i])
+		if err != nil {
+			return fmt.Errorf("property %#x: %w", codes[i], err)
+		}
+		vals[i] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
@@ -0,0 +1,160 @@
+package ip
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestClient_SetPipelineDepth(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.PipelineDepth(); got != 1 {
+		t.Errorf("PipelineDepth() got = %d; want 1", got)
+	}
+	c.SetPipelineDepth(4)
+	if got := c.PipelineDepth(); got != 4 {
+		t.Errorf("PipelineDepth() got = %d; want 4", got)
+	}
+	c.SetPipelineDepth(-1)
+	if got := c.PipelineDepth(); got != 1 {
+		t.Errorf("PipelineDepth() got = %d; want 1", got)
+	}
+}
+
+func TestClient_GetObjectInfos(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu             sync.Mutex
+		inFlight, most int
+	)
+	c.vendorExtensions.getObjectInfo = func(_ *Client, h ptp.ObjectHandle) (*ptp.ObjectInfo, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > most {
+			most = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		if h == 0x0D {
+			return nil, ptp.ResponseCodeAsError(ptp.RC_InvalidObjectHandle)
+		}
+		return &ptp.ObjectInfo{ObjectCompressedSize: uint32(h)}, nil
+	}
+
+	handles := []ptp.ObjectHandle{1, 2, 3, 4, 5, 6, 7, 8}
+	for _, depth := range []int{1, 3} {
+		most = 0
+		c.SetPipelineDepth(depth)
+
+		got, err := c.GetObjectInfos(handles)
+		if err != nil {
+			t.Fatalf("GetObjectInfos() error = %s; want <nil>", err)
+		}
+		for i, oi := range got {
+			if oi.ObjectCompressedSize != uint32(handles[i]) {
+				t.Errorf("GetObjectInfos() got = %d at %d; want %d", oi.ObjectCompressedSize, i, handles[i])
+			}
+		}
+		if most != depth {
+			t.Errorf("GetObjectInfos() transactions in flight = %d; want %d", most, depth)
+		}
+	}
+
+	_, err = c.GetObjectInfos([]ptp.ObjectHandle{1, 0x0D, 2})
+	if want := ptp.ResponseCodeAsError(ptp.RC_InvalidObjectHandle); err == nil || err.Error() != "object 0xd: "+want.Error() {
+		t.Errorf("GetObjectInfos() error = %v; want object 0xd: %s", err, want)
+	}
+}
+
+// dialLatentCamera dials a virtual camera holding the given number of objects and answering each operation request
+// after the given latency.
+func dialLatentCamera(t testing.TB, objects uint32, latency time.Duration) *Client {
+	port := startVirtualCamera(t, &virtualCamera{last: objects, faults: responderFaults{Latency: latency}})
+	c, err := NewClient(DefaultVendor, address, port, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Dial(); err != nil {
+		t.Fatalf("Dial() error = %s; want <nil>", err)
+	}
+
+	return c
+}
+
+func TestClient_GetObjectInfos_responder(t *testing.T) {
+	const latency = 20 * time.Millisecond
+	c := dialLatentCamera(t, 16, latency)
+
+	handles := make([]ptp.ObjectHandle, 16)
+	for i := range handles {
+		handles[i] = ptp.ObjectHandle(i + 1)
+	}
+	took := make(map[int]time.Duration)
+	for _, depth := range []int{1, 8} {
+		c.SetPipelineDepth(depth)
+		start := time.Now()
+		got, err := c.GetObjectInfos(handles)
+		took[depth] = time.Since(start)
+		if err != nil {
+			t.Fatalf("GetObjectInfos() error = %s; want <nil>", err)
+		}
+		for i, oi := range got {
+			if want := fmt.Sprintf("DSCF%04d.JPG", handles[i]); oi.Filename != want {
+				t.Errorf("GetObjectInfos() got = %s at %d; want %s", oi.Filename, i, want)
+			}
+		}
+	}
+	if took[1] < time.Duration(len(handles))*latency {
+		t.Errorf("GetObjectInfos() took %s without pipelining; want at least %s", took[1], time.Duration(len(handles))*latency)
+	}
+	if took[8] > took[1]/2 {
+		t.Errorf("GetObjectInfos() took %s at depth 8; want less than half of the %s taken without pipelining", took[8], took[1])
+	}
+
+	c.SetPipelineDepth(8)
+	_, err := c.GetObjectInfos([]ptp.ObjectHandle{1, 0x20, 2})
+	var ore *ptp.OperationResponseError
+	if !errors.As(err, &ore) || ore.Code != ptp.RC_InvalidObjectHandle {
+		t.Errorf("GetObjectInfos() error = %v; want %s wrapped", err, ptp.ResponseCodeAsError(ptp.RC_InvalidObjectHandle))
+	}
+}
+
+func BenchmarkClient_GetObjectInfos(b *testing.B) {
+	const objects = 32
+	handles := make([]ptp.ObjectHandle, objects)
+	for i := range handles {
+		handles[i] = ptp.ObjectHandle(i + 1)
+	}
+
+	for _, depth := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			c := dialLatentCamera(b, objects, 2*time.Millisecond)
+			c.SetPipelineDepth(depth)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.GetObjectInfos(handles); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package ip
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// RawPacketsDisabledError is returned by SendRawPacket when raw packets were not enabled using EnableRawPackets.
+var RawPacketsDisabledError = errors.New("raw packets not enabled")
+
+// rawPacket is a packet of which the contents are given as is, only the length field being prepended when sending it.
+type rawPacket struct {
+	Data []byte
+}
+
+func (rp *rawPacket) PacketType() PacketType {
+	return PKT_Invalid
+}
+
+func (rp *rawPacket) Payload() []byte {
+	return rp.Data
+}
+
+// rawTap receives the raw bytes read from a connection and hands the packets to the waiter of SendRawPacket, if any.
+// Both the standard and the Fuji packets start with a length field that includes the size of the length field itself.
+type rawTap struct {
+	mu  sync.Mutex
+	buf []byte
+	ch  chan []byte
+}
+
+func (rt *rawTap) Write(b []byte) (int, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.buf = append(rt.buf, b...)
+	for len(rt.buf) >= 4 {
+		l := int(binary.LittleEndian.Uint32(rt.buf[0:4]))
+		if l < 4 {
+			// Not a valid packet, so there is no way to find the start of the next one.
+			l = len(rt.buf)
+		}
+		if len(rt.buf) < l {
+			break
+		}
+		if rt.ch != nil {
+			p := make([]byte, l)
+			copy(p, rt.buf[:l])
+			select {
+			case rt.ch <- p:
+			default:
+			}
+		}
+		rt.buf = rt.buf[l:]
+	}
+	// Do not hold on to the backing array of a large packet.
+	if len(rt.buf) == 0 {
+		rt.buf = nil
+	}
+
+	return len(b), nil
+}
+
+// tap starts handing the packets to a new channel, which is closed again by calling the function returned.
+func (rt *rawTap) tap() (<-chan []byte, func()) {
+	ch := make(chan []byte, 20)
+	rt.mu.Lock()
+	rt.ch = ch
+	rt.mu.Unlock()
+
+	return ch, func() {
+		rt.mu.Lock()
+		rt.ch = nil
+		rt.mu.Unlock()
+	}
+}
+
+// EnableRawPackets allows sending raw packets using SendRawPacket, which is meant for reverse engineering undocumented
+// vendor operations. It taps the command/data and the event connection, so it must be called before calling Dial().
+func (c *Client) EnableRawPackets() {
+	c.rawTaps = map[connectionType]*rawTap{
+		cmdDataConnection: {},
+		eventConnection:   {},
+	}
+}
+
+// rawTapReader returns a reader handing each packet read from r to the tap of the connection when raw packets have
+// been enabled.
+func (c *Client) rawTapReader(t connectionType, r io.Reader) io.Reader {
+	rt, ok := c.rawTaps[t]
+	if !ok {
+		return r
+	}
+
+	return io.TeeReader(r, rt)
+}
+
+// SendRawPacket writes data as a single packet to the command/data or the event connection, which are named "cmd" and
+// "event" as in a recording, after prepending the length field. The data is not validated in any way, so it must hold
+// the packet type, or the data phase and the operation code for Fuji, followed by the fields of the packet. All
+// packets the Responder sends on the same connection are returned until ctx is done, including the packets belonging
+// to other transactions. The listeners of the client still process the packets as usual: a packet they do not
+// understand might end the session. EnableRawPackets must have been called before dialing.
+func (c *Client) SendRawPacket(ctx context.Context, conn string, data []byte) ([][]byte, error) {
+	ct := connectionType(conn)
+	var w io.Writer
+	switch ct {
+	case cmdDataConnection:
+		w = c.CommandDataConn
+	case eventConnection:
+		w = c.eventConn
+	default:
+		return nil, fmt.Errorf("unknown connection %q, must be cmd or event", conn)
+	}
+
+	rt, ok := c.rawTaps[ct]
+	if !ok {
+		return nil, RawPacketsDisabledError
+	}
+	ch, stop := rt.tap()
+	defer stop()
+
+	if err := c.sendPacket(ct, w, &rawPacket{Data: data}); err != nil {
+		return nil, err
+	}
+
+	var res [][]byte
+	for {
+		select {
+		case p := <-ch:
+			res = append(res, p)
+		case <-ctx.Done():
+			return res, nil
+		}
+	}
+}
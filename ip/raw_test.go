@@ -0,0 +1,47 @@
+package ip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClient_SendRawPacket(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, startFaultyVirtualCamera(t, responderFaults{}), "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.EnableRawPackets()
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	got, err := c.SendRawPacket(ctx, "event", []byte{0x0d, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatalf("SendRawPacket() error = %s; want <nil>", err)
+	}
+	want := []byte{0x08, 0x00, 0x00, 0x00, 0x0e, 0x00, 0x00, 0x00}
+	if len(got) != 1 || !bytes.Equal(got[0], want) {
+		t.Errorf("SendRawPacket() got = %#v; want [%#v]", got, want)
+	}
+
+	if _, err := c.SendRawPacket(ctx, "stream", nil); err == nil {
+		t.Errorf("SendRawPacket() error = <nil>; want error")
+	}
+}
+
+func TestClient_SendRawPacket_disabled(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, DefaultPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.SendRawPacket(context.Background(), "cmd", []byte{0x01}); !errors.Is(err, RawPacketsDisabledError) {
+		t.Errorf("SendRawPacket() error = %v; want %s", err, RawPacketsDisabledError)
+	}
+}
@@ -0,0 +1,194 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// minReaperInterval is the shortest interval at which the reaper looks for transactions that timed out.
+const minReaperInterval = 10 * time.Millisecond
+
+// TransactionTimeoutError is returned to the waiter of a transaction that was reaped because the Responder did not send
+// any packet for it within the transaction timeout set using SetTransactionTimeout. It wraps WaitForResponseError so
+// that errors.Is keeps working for timeouts.
+type TransactionTimeoutError struct {
+	TransactionID ptp.TransactionID
+	// Idle is the time that passed without receiving a packet for the transaction.
+	Idle time.Duration
+	// Probed tells whether the Responder was probed after reaping the transaction, in which case Alive tells whether it
+	// answered the probe.
+	Probed bool
+	Alive  bool
+}
+
+func (e *TransactionTimeoutError) Error() string {
+	msg := fmt.Sprintf("transaction %d: no response within %s", e.TransactionID, e.Idle.Round(time.Millisecond))
+	switch {
+	case e.Probed && e.Alive:
+		msg += ", the responder answered a probe"
+	case e.Probed:
+		msg += ", the responder did not answer a probe"
+	}
+
+	return msg
+}
+
+func (e *TransactionTimeoutError) Unwrap() error {
+	return WaitForResponseError
+}
+
+// Timeout always returns true so that IsTimeout reports a reaped transaction as a timeout.
+func (e *TransactionTimeoutError) Timeout() bool {
+	return true
+}
+
+// reapedTransactions holds the errors of the reaped transactions, keyed by the channel of their subscription, until the
+// waiter picks them up.
+type reapedTransactions struct {
+	mu   sync.Mutex
+	errs map[uintptr]reapedTransaction
+}
+
+type reapedTransaction struct {
+	err *TransactionTimeoutError
+	at  time.Time
+}
+
+// channelKey returns the key identifying a channel regardless of its direction.
+func channelKey(ch interface{}) uintptr {
+	return reflect.ValueOf(ch).Pointer()
+}
+
+// add stores the error of a reaped transaction.
+func (rt *reapedTransactions) add(ch chan<- []byte, err *TransactionTimeoutError, at time.Time) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.errs == nil {
+		rt.errs = make(map[uintptr]reapedTransaction)
+	}
+	rt.errs[channelKey(ch)] = reapedTransaction{err: err, at: at}
+}
+
+// take returns and forgets the error of the reaped transaction of which ch is the channel.
+func (rt *reapedTransactions) take(ch <-chan []byte) (*TransactionTimeoutError, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	r, ok := rt.errs[channelKey(ch)]
+	if ok {
+		delete(rt.errs, channelKey(ch))
+	}
+
+	return r.err, ok
+}
+
+// prune forgets the errors that were not picked up by their waiter since before.
+func (rt *reapedTransactions) prune(before time.Time) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for k, r := range rt.errs {
+		if r.at.Before(before) {
+			delete(rt.errs, k)
+		}
+	}
+}
+
+// SetTransactionTimeout enables the transaction reaper: a transaction for which the Responder did not send any packet
+// within timeout is ended and its subscription removed, the waiter receiving a *TransactionTimeoutError. This protects
+// waiters that do not use a deadline of their own and cleans up after waiters that gave up without unsubscribing. When
+// probe is true, the Responder is probed on the event connection after reaping a transaction to tell a slow Responder
+// apart from a dead one, which only works for Responders supporting probes. A timeout of zero disables the reaper, which
+// is the default. The timeout is applied when calling Dial().
+func (c *Client) SetTransactionTimeout(timeout time.Duration, probe bool) {
+	c.transactionTimeout = timeout
+	c.probeOnTimeout = probe
+}
+
+// startReaper starts the reaper when a transaction timeout has been set. The reaper stops when the client is closed.
+func (c *Client) startReaper() {
+	if c.transactionTimeout <= 0 || c.closeReaper != nil {
+		return
+	}
+
+	interval := c.transactionTimeout / 4
+	if interval < minReaperInterval {
+		interval = minReaperInterval
+	}
+	stop := make(chan struct{})
+	c.closeReaper = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				c.reapTransactions(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopReaper stops the reaper when it is running.
+func (c *Client) stopReaper() {
+	if c.closeReaper != nil {
+		close(c.closeReaper)
+		c.closeReaper = nil
+	}
+}
+
+// reapTransactions ends the transactions for which no packet arrived within the transaction timeout.
+func (c *Client) reapTransactions(now time.Time) {
+	// A waiter blocked on its channel picks up the error right away, so any error still there is not waited for.
+	c.reaped.prune(now.Add(-c.transactionTimeout))
+
+	var reaped []*subscription
+	c.cmdDataSubsMu.Lock()
+	for tid, s := range c.cmdDataSubs {
+		if idle, open := s.idle(now); open && idle >= c.transactionTimeout {
+			reaped = append(reaped, s)
+			delete(c.cmdDataSubs, tid)
+		}
+	}
+	c.cmdDataSubsEnded.Broadcast()
+	c.cmdDataSubsMu.Unlock()
+	if len(reaped) == 0 {
+		return
+	}
+
+	var probed, alive bool
+	if c.probeOnTimeout {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultReadTimeout)
+		_, err := c.Probe(ctx)
+		cancel()
+		probed, alive = true, err == nil
+	}
+
+	for _, s := range reaped {
+		idle, _ := s.idle(now)
+		err := &TransactionTimeoutError{TransactionID: s.tid, Idle: idle, Probed: probed, Alive: alive}
+		c.transactionLogger(cmdDataConnection, s.tid).Warnf("[reaper] %s", err)
+		// The error must be known before the channel is closed.
+		c.reaped.add(s.ch, err, now)
+		s.drop(subscriptionReaped)
+		c.endTransactionSpan(s.tid, err)
+	}
+}
+
+// subscriptionError returns the error to return to a waiter of which the subscription channel has been closed: the
+// *TransactionTimeoutError when the transaction was reaped and ConnectionLostError otherwise.
+func (c *Client) subscriptionError(ch <-chan []byte) error {
+	if err, ok := c.reaped.take(ch); ok {
+		return err
+	}
+
+	return ConnectionLostError
+}
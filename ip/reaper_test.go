@@ -0,0 +1,64 @@
+package ip
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransactionTimeoutError_Error(t *testing.T) {
+	check := []struct {
+		err  *TransactionTimeoutError
+		want string
+	}{
+		{&TransactionTimeoutError{TransactionID: 3, Idle: time.Second}, "transaction 3: no response within 1s"},
+		{&TransactionTimeoutError{TransactionID: 3, Idle: time.Second, Probed: true, Alive: true}, "transaction 3: no response within 1s, the responder answered a probe"},
+		{&TransactionTimeoutError{TransactionID: 3, Idle: time.Second, Probed: true}, "transaction 3: no response within 1s, the responder did not answer a probe"},
+	}
+
+	for _, tt := range check {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() got = %s; want %s", got, tt.want)
+		}
+		if !errors.Is(tt.err, WaitForResponseError) || !IsTimeout(tt.err) {
+			t.Errorf("Error() %v does not report a timeout", tt.err)
+		}
+	}
+}
+
+func TestClient_SetTransactionTimeout(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, startFaultyVirtualCamera(t, responderFaults{DropResponses: 1}), "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetTransactionTimeout(200*time.Millisecond, true)
+	if err := c.Dial(); err != nil {
+		t.Fatalf("Dial() error = %s; want <nil>", err)
+	}
+
+	start := time.Now()
+	_, err = c.GetObject(1)
+	var te *TransactionTimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("GetObject() error = %v; want *TransactionTimeoutError", err)
+	}
+	if !te.Probed || !te.Alive {
+		t.Errorf("GetObject() error = %v; want the responder to answer the probe", err)
+	}
+	if got := time.Since(start); got >= DefaultReadTimeout {
+		t.Errorf("GetObject() took %s; want the transaction to be reaped before %s", got, DefaultReadTimeout)
+	}
+
+	c.cmdDataSubsMu.Lock()
+	subs := len(c.cmdDataSubs)
+	c.cmdDataSubsMu.Unlock()
+	if subs != 0 {
+		t.Errorf("reapTransactions() left %d subscriptions; want 0", subs)
+	}
+
+	// Transactions the Responder answers are not affected.
+	if _, err := c.GetStorageIDs(); err != nil {
+		t.Errorf("GetStorageIDs() error = %s; want <nil>", err)
+	}
+}
@@ -0,0 +1,179 @@
+package ip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// RecordedPacket is a single packet exchanged with a Responder.
+type RecordedPacket struct {
+	// Connection is the connection the packet was exchanged on: cmd or event.
+	Connection string
+	// Inbound is true for packets sent by the Responder and false for packets sent by the Initiator.
+	Inbound bool
+	// Data holds the full packet, including the header.
+	Data []byte
+}
+
+// Recording holds the packets exchanged with a Responder on the command/data and the event connection, in the order in
+// which they were sent or received.
+type Recording struct {
+	// Vendor is the vendor of the Responder as accepted by NewClient().
+	Vendor  string
+	Packets []RecordedPacket
+}
+
+// ReadRecording reads a recording as written by a client using SetRecorder(). The recording is a text format holding
+// one directive per line:
+//   - '# comment' is ignored, just like empty lines
+//   - 'vendor fuji' gives the vendor of the Responder as accepted by NewClient()
+//   - 'cmd> hex' is a packet sent by the Initiator on the command/data connection
+//   - 'cmd< hex' is a packet received from the Responder on the command/data connection
+//   - 'event> hex' and 'event< hex' are the same for the event connection
+//
+// The hexadecimal packet data holds the full packet, including the header, and may contain spaces to make it readable.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+
+	s := bufio.NewScanner(r)
+	// A packet holding a full device info dataset easily exceeds the default maximum line length of the scanner.
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for l := 1; s.Scan(); l++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		dir, rest, _ := strings.Cut(line, " ")
+		if dir == "vendor" {
+			if rec.Vendor != "" {
+				return nil, fmt.Errorf("line %d: vendor already given", l)
+			}
+			rec.Vendor = strings.TrimSpace(rest)
+			continue
+		}
+
+		p := RecordedPacket{}
+		switch {
+		case strings.HasSuffix(dir, ">"):
+			p.Connection = strings.TrimSuffix(dir, ">")
+		case strings.HasSuffix(dir, "<"):
+			p.Connection, p.Inbound = strings.TrimSuffix(dir, "<"), true
+		default:
+			return nil, fmt.Errorf("line %d: unknown directive %q", l, dir)
+		}
+		if p.Connection != string(cmdDataConnection) && p.Connection != string(eventConnection) {
+			return nil, fmt.Errorf("line %d: unknown connection %q", l, p.Connection)
+		}
+
+		var err error
+		if p.Data, err = hex.DecodeString(strings.ReplaceAll(rest, " ", "")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", l, err)
+		}
+		if len(p.Data) < 4 || int(binary.LittleEndian.Uint32(p.Data[0:4])) != len(p.Data) {
+			return nil, fmt.Errorf("line %d: packet length does not match the data", l)
+		}
+		rec.Packets = append(rec.Packets, p)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if rec.Vendor == "" {
+		return nil, errors.New("recording has no vendor")
+	}
+
+	return rec, nil
+}
+
+// exchangeRecorder writes the packets exchanged with the Responder in the format read by ReadRecording().
+type exchangeRecorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	vendor string
+	header bool
+}
+
+// record writes a single packet, of which the parts are concatenated.
+func (er *exchangeRecorder) record(ct connectionType, inbound bool, parts ...[]byte) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	if !er.header {
+		fmt.Fprintf(er.w, "vendor %s\n", er.vendor)
+		er.header = true
+	}
+
+	dir := ">"
+	if inbound {
+		dir = "<"
+	}
+	var data []byte
+	for _, p := range parts {
+		data = append(data, p...)
+	}
+	fmt.Fprintf(er.w, "%s%s %x\n", ct, dir, data)
+}
+
+// packetSplitter receives the raw bytes read from a connection and records them packet by packet. Both the standard
+// and the Fuji packets start with a length field that includes the size of the length field itself.
+type packetSplitter struct {
+	ct  connectionType
+	rec *exchangeRecorder
+	buf []byte
+}
+
+func (ps *packetSplitter) Write(b []byte) (int, error) {
+	ps.buf = append(ps.buf, b...)
+	for len(ps.buf) >= 4 {
+		l := int(binary.LittleEndian.Uint32(ps.buf[0:4]))
+		if l < 4 {
+			// Not a valid packet, so there is no way to find the start of the next one.
+			ps.rec.record(ps.ct, true, ps.buf)
+			ps.buf = nil
+			break
+		}
+		if len(ps.buf) < l {
+			break
+		}
+		ps.rec.record(ps.ct, true, ps.buf[:l])
+		ps.buf = ps.buf[l:]
+	}
+
+	return len(b), nil
+}
+
+// SetRecorder writes all packets exchanged with the Responder on the command/data and the event connection to w, in the
+// format read by ReadRecording(). This allows recording the exchanges with a real camera to use them as a test fixture.
+// The stream connection is not recorded. SetRecorder must be called before calling Dial() and the client must be
+// created using an explicit vendor. Pass nil to disable recording, which is the default.
+func (c *Client) SetRecorder(w io.Writer) {
+	if w == nil {
+		c.recorder = nil
+		return
+	}
+	c.recorder = &exchangeRecorder{w: w, vendor: c.ResponderVendor().String()}
+}
+
+// recordingReader returns a reader recording each packet read from r when a recorder has been set. The stream
+// connection is never recorded.
+func (c *Client) recordingReader(t connectionType, r io.Reader) io.Reader {
+	if c.recorder == nil || t == streamConnection {
+		return r
+	}
+
+	return io.TeeReader(r, &packetSplitter{ct: t, rec: c.recorder})
+}
+
+// recordPacket records a packet sent to the Responder when a recorder has been set.
+func (c *Client) recordPacket(t connectionType, header, payload []byte) {
+	if c.recorder == nil || (t != cmdDataConnection && t != eventConnection) {
+		return
+	}
+	c.recorder.record(t, false, header, payload)
+}
@@ -0,0 +1,99 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestReadRecording(t *testing.T) {
+	rec, err := ReadRecording(strings.NewReader("# comment\n\nvendor fuji\ncmd> 08000000 01000000\nevent< 0c00000003000120 01000000\n"))
+	if err != nil {
+		t.Fatalf("ReadRecording() error = %s; want <nil>", err)
+	}
+	if rec.Vendor != "fuji" {
+		t.Errorf("ReadRecording() Vendor = %s; want fuji", rec.Vendor)
+	}
+	want := []RecordedPacket{
+		{Connection: "cmd", Data: []byte{0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}},
+		{Connection: "event", Inbound: true, Data: []byte{0x0c, 0x00, 0x00, 0x00, 0x03, 0x00, 0x01, 0x20, 0x01, 0x00, 0x00, 0x00}},
+	}
+	if len(rec.Packets) != len(want) {
+		t.Fatalf("ReadRecording() got %d packets; want %d", len(rec.Packets), len(want))
+	}
+	for i, p := range rec.Packets {
+		if p.Connection != want[i].Connection || p.Inbound != want[i].Inbound || !bytes.Equal(p.Data, want[i].Data) {
+			t.Errorf("ReadRecording() packet %d got = %+v; want %+v", i, p, want[i])
+		}
+	}
+
+	check := map[string]string{
+		"cmd> 08000000 01000000\n":                 "recording has no vendor",
+		"vendor fuji\nvendor generic\n":            "line 2: vendor already given",
+		"vendor fuji\nstream< 08000000 01000000\n": "line 2: unknown connection \"stream\"",
+		"vendor fuji\ncmd 08000000 01000000\n":     "line 2: unknown directive \"cmd\"",
+		"vendor fuji\ncmd> 0900000001000000\n":     "line 2: packet length does not match the data",
+		"vendor fuji\ncmd> 08000000 0100000\n":     "line 2: encoding/hex: odd length hex string",
+	}
+	for in, want := range check {
+		if _, err := ReadRecording(strings.NewReader(in)); err == nil || err.Error() != want {
+			t.Errorf("ReadRecording() error = %v; want %s", err, want)
+		}
+	}
+}
+
+func TestClient_SetRecorder(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, virtualPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	c.SetRecorder(&buf)
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetStorageIDs()
+	c.Close()
+	if err != nil {
+		t.Fatalf("GetStorageIDs() error = %s; want <nil>", err)
+	}
+
+	rec, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatalf("ReadRecording() error = %s; want <nil>", err)
+	}
+	if rec.Vendor != "generic" {
+		t.Errorf("ReadRecording() Vendor = %s; want generic", rec.Vendor)
+	}
+
+	want := []struct {
+		conn    string
+		inbound bool
+		pt      PacketType
+	}{
+		{"cmd", false, PKT_InitCommandRequest},
+		{"cmd", true, PKT_InitCommandAck},
+		{"event", false, PKT_InitEventRequest},
+		{"event", true, PKT_InitEventAck},
+		{"cmd", false, PKT_OperationRequest},
+		{"cmd", true, PKT_StartData},
+		{"cmd", true, PKT_EndData},
+		{"cmd", true, PKT_OperationResponse},
+	}
+	if len(rec.Packets) != len(want) {
+		t.Fatalf("SetRecorder() recorded %d packets; want %d", len(rec.Packets), len(want))
+	}
+	for i, p := range rec.Packets {
+		pt := PacketType(binary.LittleEndian.Uint32(p.Data[4:8]))
+		if p.Connection != want[i].conn || p.Inbound != want[i].inbound || pt != want[i].pt {
+			t.Errorf("SetRecorder() packet %d got = %s %t %s; want %s %t %s", i, p.Connection, p.Inbound, packetTypeName(pt), want[i].conn, want[i].inbound, packetTypeName(want[i].pt))
+		}
+	}
+	if code := ptp.OperationCode(binary.LittleEndian.Uint16(rec.Packets[4].Data[12:14])); code != ptp.OC_GetStorageIDs {
+		t.Errorf("SetRecorder() operation got = %#04x; want %#04x", code, ptp.OC_GetStorageIDs)
+	}
+}
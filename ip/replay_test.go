@@ -0,0 +1,167 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the recordings")
+
+// requestTransactionId returns the operation code and the transaction ID of an operation request sent by the Initiator.
+// The operation code is zero for the packets of a data-out phase. Returns false for the packets of the init handshake.
+func requestTransactionId(c *Client, raw []byte) (ptp.OperationCode, ptp.TransactionID, bool) {
+	if len(raw) < 12 {
+		return 0, 0, false
+	}
+
+	switch PacketType(binary.LittleEndian.Uint32(raw[4:8])) {
+	case PKT_InitCommandRequest, PKT_InitEventRequest, PKT_ProbeRequest, PKT_ProbeResponse:
+		return 0, 0, false
+	case PKT_OperationRequest:
+		if len(raw) < 18 {
+			return 0, 0, false
+		}
+		return ptp.OperationCode(binary.LittleEndian.Uint16(raw[12:14])), ptp.TransactionID(binary.LittleEndian.Uint32(raw[14:18])), true
+	case PKT_StartData, PKT_Data, PKT_EndData, PKT_Cancel:
+		return 0, ptp.TransactionID(binary.LittleEndian.Uint32(raw[8:12])), true
+	}
+
+	if c.ResponderVendor() == ptp.VE_FujiPhotoFilmCoLtd {
+		// Fuji sends the data phase and the operation code in place of the packet type.
+		if DataPhase(binary.LittleEndian.Uint16(raw[4:6])) == DP_DataOut {
+			return 0, ptp.TransactionID(binary.LittleEndian.Uint32(raw[8:12])), true
+		}
+		return ptp.OperationCode(binary.LittleEndian.Uint16(raw[6:8])), ptp.TransactionID(binary.LittleEndian.Uint32(raw[8:12])), true
+	}
+
+	return 0, 0, false
+}
+
+// replay feeds the packets the Responder sent in the recording through the parsing and dispatch code used by the
+// listeners of a client for the vendor of the recording, and returns a transcript of the outcome. The packets sent by
+// the Initiator are used to check that each response is dispatched to the transaction it belongs to.
+func replay(t *testing.T, rec *Recording) string {
+	c, err := NewClient(rec.Vendor, address, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		b   strings.Builder
+		tid ptp.TransactionID
+		// handshake is true until the Initiator sends its first operation request.
+		handshake = true
+	)
+	for i, p := range rec.Packets {
+		dir := "<"
+		if !p.Inbound {
+			dir = ">"
+		}
+		fmt.Fprintf(&b, "#%d %s%s ", i, p.Connection, dir)
+
+		switch {
+		case !p.Inbound:
+			code, id, ok := requestTransactionId(c, p.Data)
+			if !ok {
+				fmt.Fprintf(&b, "%s\n", packetTypeName(PacketType(binary.LittleEndian.Uint32(p.Data[4:8]))))
+				continue
+			}
+			handshake = false
+			if code == 0 {
+				fmt.Fprintf(&b, "data for transaction %d\n", id)
+			} else {
+				fmt.Fprintf(&b, "operation %s, transaction %d\n", ptp.OperationCodeName(code), id)
+			}
+			tid = id
+		case p.Connection == string(eventConnection):
+			ep := c.vendorExtensions.newEventPacket()
+			if ep.PacketType() != PKT_Invalid && PacketType(binary.LittleEndian.Uint32(p.Data[4:8])) != ep.PacketType() {
+				// Part of the init handshake or a probe.
+				ep = nil
+			}
+			res, payload, err := c.readResponse(bytes.NewReader(p.Data), ep)
+			if err != nil {
+				t.Errorf("packet %d: readResponse() error = %s; want <nil>", i, err)
+				b.WriteString("invalid\n")
+				continue
+			}
+			if gep, ok := res.(*GenericEventPacket); ok {
+				gep.setParameters(payload)
+			}
+			b.WriteString(DumpPacket(res))
+		case handshake:
+			res, _, err := c.readResponse(bytes.NewReader(p.Data), nil)
+			if err != nil {
+				t.Errorf("packet %d: readResponse() error = %s; want <nil>", i, err)
+				b.WriteString("invalid\n")
+				continue
+			}
+			b.WriteString(DumpPacket(res))
+		default:
+			got, err := c.vendorExtensions.extractTransactionId(p.Data, cmdDataConnection)
+			if err != nil || got != tid {
+				t.Errorf("packet %d: extractTransactionId() got = %d, %v; want %d, <nil>", i, got, err, tid)
+			}
+			if size, rc, final := c.vendorExtensions.inspectResponse(p.Data); final {
+				fmt.Fprintf(&b, "transaction %d ended with %#04x\n", got, uint16(rc))
+			} else {
+				fmt.Fprintf(&b, "transaction %d, %d bytes of data\n", got, size)
+			}
+			b.WriteString(c.DumpRawPacket(p.Data))
+		}
+	}
+
+	return b.String()
+}
+
+// TestRecordings replays the recorded exchanges found in testdata/recordings and compares the outcome with the golden
+// file next to each recording. Run the tests using the -update flag to write the golden files after adding a recording
+// or changing the parsing code on purpose.
+func TestRecordings(t *testing.T) {
+	files, err := filepath.Glob("testdata/recordings/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no recordings found")
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			rec, err := ReadRecording(f)
+			if err != nil {
+				t.Fatalf("ReadRecording() error = %s; want <nil>", err)
+			}
+
+			got := replay(t, rec)
+			golden := strings.TrimSuffix(file, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("replay() got = %s; want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package ip
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// DefaultSessionId is the ID of the session opened by a client created using NewClient.
+const DefaultSessionId ptp.SessionID = 0x00000001
+
+// sessionIds hands out the IDs of the sessions opened with the same Responder.
+type sessionIds struct {
+	mu   sync.Mutex
+	last ptp.SessionID
+}
+
+// next returns the next free session ID in a thread safe way.
+func (s *sessionIds) next() ptp.SessionID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last++
+	// A SessionID of zero is reserved for operations that do not occur within a session.
+	if s.last == 0 {
+		s.last = DefaultSessionId
+	}
+
+	return s.last
+}
+
+// SessionId returns the ID of the session the client has opened with the Responder.
+func (c *Client) SessionId() ptp.SessionID {
+	return c.sessionId
+}
+
+// NewSession opens an additional session with the Responder, where the Responder allows it, and returns a new client
+// for it. PTP/IP does not transfer the session ID with each operation, so the new session uses command/data and event
+// connections of its own and has its own transaction ID space. This allows, for example, a long-running download to
+// run in a separate session next to the one used to control the camera.
+// The new client does not open a streamer connection. It must be closed separately, preferably using Shutdown.
+func (c *Client) NewSession() (*Client, error) {
+	return c.vendorExtensions.newSession(c)
+}
+
+// newSessionClient returns a client to the same Responder using the same Initiator identity, logger and tracer, but
+// with a new session ID.
+func (c *Client) newSessionClient() *Client {
+	r := *c.responder
+	s := &Client{
+		initiator:     c.initiator,
+		responder:     &r,
+		appVersion:    c.appVersion,
+		sessionId:     c.sessionIds.next(),
+		sessionIds:    c.sessionIds,
+		cmdDataSubs:   make(map[ptp.TransactionID]*subscription),
+		propDescs:     make(map[ptp.DevicePropCode]*ptp.DevicePropDesc),
+		frames:        newFrameBroadcaster(),
+		streamBackoff: c.streamBackoff,
+		dataChunkSize: c.dataChunkSize,
+		pipelineDepth: c.pipelineDepth,
+		transferLimit: c.transferLimit,
+		connOptions:   c.connOptions.clone(),
+		tracer:        c.tracer,
+		detectVendor:  c.detectVendor,
+	}
+	s.cmdDataSubsEnded.L = &s.cmdDataSubsMu
+	s.SetTransactionTimeout(c.transactionTimeout, c.probeOnTimeout)
+	s.Logger = WithFields(c.Logger, Field{FieldSession, s.sessionId})
+
+	s.loadVendorExtensions()
+
+	return s
+}
+
+// GenericNewSession dials the Responder for a new session and opens it using ptp.OC_OpenSession. An error is returned
+// when the Responder refuses the connection or the session, e.g. with ptp.RC_SessionAlreadyOpen.
+func GenericNewSession(c *Client) (*Client, error) {
+	s := c.newSessionClient()
+	if err := s.Dial(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if _, err := GenericOperationRequestAndGetParameters(s, ptp.OC_OpenSession, []uint32{uint32(s.sessionId)}); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("unable to open session %d: %w", s.sessionId, err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,100 @@
+package ip
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestSessionIds_next(t *testing.T) {
+	s := &sessionIds{last: DefaultSessionId}
+	if got, want := s.next(), ptp.SessionID(2); got != want {
+		t.Errorf("next() got = %d; want %d", got, want)
+	}
+
+	s.last = 0xFFFFFFFF
+	if got := s.next(); got != DefaultSessionId {
+		t.Errorf("next() got = %d; want %d", got, DefaultSessionId)
+	}
+}
+
+func TestClient_NewSession(t *testing.T) {
+	vc := &virtualCamera{}
+	c, err := NewClient(DefaultVendor, address, startVirtualCamera(t, vc), "testèr", "2b7e1c4d-9a3f-4e6b-8d21-c5f0a7e3b915", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	// The transaction ID a new session starts from after dialing, which is then used to open the session.
+	want := c.TransactionId() + 1
+	if _, err := c.GetDeviceInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	tid := c.TransactionId()
+
+	s, err := c.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession() err = %s; want <nil>", err)
+	}
+	defer s.Close()
+
+	if got, want := s.SessionId(), ptp.SessionID(2); got != want {
+		t.Errorf("SessionId() got = %d; want %d", got, want)
+	}
+	if !vc.sessionOpen(2) {
+		t.Errorf("NewSession() session 2 not opened on the Responder; want it opened")
+	}
+	if s.CommandDataConn == c.CommandDataConn {
+		t.Errorf("NewSession() CommandDataConn is shared; want a connection of its own")
+	}
+	if got := s.TransactionId(); got != want {
+		t.Errorf("TransactionId() got = %d; want %d", got, want)
+	}
+	if got := c.TransactionId(); got != tid {
+		t.Errorf("TransactionId() got = %d; want %d", got, tid)
+	}
+	if got := s.InitiatorGUID(); got != c.InitiatorGUID() {
+		t.Errorf("InitiatorGUID() got = %s; want %s", got, c.InitiatorGUID())
+	}
+}
+
+func TestClient_NewSession_refused(t *testing.T) {
+	vc := &virtualCamera{sessions: map[ptp.SessionID]bool{2: true}}
+	c, err := NewClient(DefaultVendor, address, startVirtualCamera(t, vc), "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.NewSession()
+	var ore *ptp.OperationResponseError
+	if !errors.As(err, &ore) || ore.Code != ptp.RC_SessionAlreadyOpen || s != nil {
+		t.Errorf("NewSession() got = %v, %v; want <nil>, %s", s, err, ptp.ResponseCodeAsError(ptp.RC_SessionAlreadyOpen))
+	}
+
+	c.responder.CommandDataPort = failPort
+	if s, err := c.NewSession(); err == nil || s != nil {
+		t.Errorf("NewSession() got = %v, %v; want <nil>, error", s, err)
+	}
+}
+
+func TestFujiNewSession(t *testing.T) {
+	c, err := NewClient("fuji", address, fujiCmdPort, "testèr", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := c.NewSession(); err == nil || got != nil {
+		t.Errorf("NewSession() got = %v, %v; want <nil>, error", got, err)
+	}
+}
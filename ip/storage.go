@@ -0,0 +1,118 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// Storage is a store on the Responder together with its StorageInfo dataset.
+type Storage struct {
+	ID   ptp.StorageID
+	Info *ptp.StorageInfo
+}
+
+// GetStorageIDs returns the IDs of the stores of the Responder. Removable media that is not inserted has a StorageID
+// with a logical storage ID, the 16 least significant bits, set to zero.
+func (c *Client) GetStorageIDs() ([]ptp.StorageID, error) {
+	return c.vendorExtensions.getStorageIDs(c)
+}
+
+// GetStorageInfo returns the StorageInfo dataset for the given store.
+func (c *Client) GetStorageInfo(sid ptp.StorageID) (*ptp.StorageInfo, error) {
+	return c.vendorExtensions.getStorageInfo(c, sid)
+}
+
+// GetStorages returns all stores of the Responder together with their StorageInfo dataset. Removable media that is not
+// inserted is skipped.
+func (c *Client) GetStorages() ([]Storage, error) {
+	ids, err := c.GetStorageIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var storages []Storage
+	for _, sid := range ids {
+		if sid&0xFFFF == 0 {
+			continue
+		}
+		si, err := c.GetStorageInfo(sid)
+		if err != nil {
+			return nil, fmt.Errorf("storage %#x: %w", sid, err)
+		}
+		storages = append(storages, Storage{ID: sid, Info: si})
+	}
+
+	return storages, nil
+}
+
+// GenericGetStorageIDs requests the list of storage IDs from the Responder.
+func GenericGetStorageIDs(c *Client) ([]ptp.StorageID, error) {
+	data, err := GenericOperationRequestAndGetData(c, ptp.OC_GetStorageIDs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseStorageIDs(data)
+}
+
+// GenericGetStorageInfo requests the StorageInfo dataset of the given store from the Responder.
+func GenericGetStorageInfo(c *Client, sid ptp.StorageID) (*ptp.StorageInfo, error) {
+	data, err := GenericOperationRequestAndGetData(c, ptp.OC_GetStorageInfo, []uint32{uint32(sid)})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseStorageInfo(data)
+}
+
+// parseStorageIDs reads the array of storage IDs returned by the GetStorageIDs operation: the number of elements
+// followed by the elements themselves.
+func parseStorageIDs(data []byte) ([]ptp.StorageID, error) {
+	r := bytes.NewReader(data)
+
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("reading storage ID count: %s", err)
+	}
+	if int(n) > r.Len()/4 {
+		return nil, fmt.Errorf("expected %d storage IDs, got %d bytes", n, r.Len())
+	}
+
+	ids := make([]ptp.StorageID, n)
+	if err := binary.Read(r, binary.LittleEndian, ids); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// parseStorageInfo reads the StorageInfo dataset returned by the GetStorageInfo operation.
+func parseStorageInfo(data []byte) (*ptp.StorageInfo, error) {
+	r := bytes.NewReader(data)
+	si := &ptp.StorageInfo{}
+
+	for _, v := range []interface{}{
+		&si.StorageType,
+		&si.FilesystemType,
+		&si.AccessCapability,
+		&si.MaxCapacity,
+		&si.FreeSpaceInBytes,
+		&si.FreeSpaceInImages,
+	} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("reading storage info: %s", err)
+		}
+	}
+
+	var err error
+	for _, s := range []*string{&si.StorageDescription, &si.VolumeLabel} {
+		if *s, err = readPTPString(r); err != nil {
+			return nil, fmt.Errorf("reading storage info: %s", err)
+		}
+	}
+
+	return si, nil
+}
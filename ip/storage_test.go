@@ -0,0 +1,57 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestParseStorageIDs(t *testing.T) {
+	got, err := parseStorageIDs([]byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00})
+	if err != nil {
+		t.Fatalf("parseStorageIDs() error = %s; want <nil>", err)
+	}
+	want := []ptp.StorageID{0x10001, 0x20000}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("parseStorageIDs() got = %v; want %v", got, want)
+	}
+
+	if _, err := parseStorageIDs([]byte{0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00}); err == nil {
+		t.Errorf("parseStorageIDs() error = <nil>; want error")
+	}
+}
+
+func TestParseStorageInfo(t *testing.T) {
+	var b bytes.Buffer
+	for _, v := range []interface{}{
+		uint16(ptp.ST_RemovableRAM), uint16(ptp.FT_DCF), uint16(ptp.AC_ReadWrite), uint64(64000000000),
+		uint64(32000000000), uint32(1234),
+	} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.Write(ptpString("SD card"))
+	b.Write(ptpString(""))
+
+	got, err := parseStorageInfo(b.Bytes())
+	if err != nil {
+		t.Fatalf("parseStorageInfo() error = %s; want <nil>", err)
+	}
+	want := &ptp.StorageInfo{
+		StorageType:        ptp.ST_RemovableRAM,
+		FilesystemType:     ptp.FT_DCF,
+		AccessCapability:   ptp.AC_ReadWrite,
+		MaxCapacity:        64000000000,
+		FreeSpaceInBytes:   32000000000,
+		FreeSpaceInImages:  1234,
+		StorageDescription: "SD card",
+	}
+	if *got != *want {
+		t.Errorf("parseStorageInfo() got = %+v; want %+v", got, want)
+	}
+
+	if _, err := parseStorageInfo([]byte{0x04, 0x00}); err == nil {
+		t.Errorf("parseStorageInfo() error = <nil>; want error")
+	}
+}
@@ -0,0 +1,237 @@
+package ip
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// subscriptionEnd tells why no more packets will be published to a subscription.
+type subscriptionEnd int
+
+const (
+	// subscriptionOpen means packets are still being published.
+	subscriptionOpen subscriptionEnd = iota
+	// subscriptionCompleted means the final packet of the transaction has been published.
+	subscriptionCompleted
+	// subscriptionDropped means the connection the packets arrive on dropped.
+	subscriptionDropped
+	// subscriptionReaped means no packet arrived for the transaction within the transaction timeout.
+	subscriptionReaped
+)
+
+// subscription delivers the packets of a single transaction to the channel of the subscriber. The packets are queued so
+// that a subscriber that is slow to read, or that stopped reading, never blocks the response listener and thereby all
+// other transactions. The channel is owned by the subscription: it is closed when the subscriber unsubscribes, when the
+// connection drops or when the transaction is reaped, but not when the transaction completes so that the subscriber can
+// read the final packet at its own pace.
+type subscription struct {
+	tid ptp.TransactionID
+	ch  chan<- []byte
+	// release removes the subscription from the client once all packets of a completed transaction are delivered.
+	release func(*subscription)
+
+	mu    sync.Mutex
+	queue [][]byte
+	end   subscriptionEnd
+	// last is the time the subscription was made or the last packet was published.
+	last time.Time
+	// wake signals the delivery goroutine that the queue or the end changed.
+	wake chan struct{}
+	// stop is closed when the subscriber unsubscribes, dropping the packets that were not delivered yet.
+	stop chan struct{}
+}
+
+// newSubscription returns a subscription delivering the packets of the transaction to ch.
+func newSubscription(tid ptp.TransactionID, ch chan<- []byte, release func(*subscription)) *subscription {
+	s := &subscription{
+		tid:     tid,
+		ch:      ch,
+		release: release,
+		last:    time.Now(),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	go s.deliver()
+
+	return s
+}
+
+// publish queues a packet for delivery to the subscriber. The packet is ignored once the subscription ended. When final
+// is true, the transaction is complete and the subscription ends once the packet has been delivered.
+func (s *subscription) publish(p []byte, final bool) {
+	s.mu.Lock()
+	if s.end == subscriptionOpen {
+		s.queue = append(s.queue, p)
+		s.last = time.Now()
+		if final {
+			s.end = subscriptionCompleted
+		}
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+// drop ends the subscription for the given reason, which is either subscriptionDropped or subscriptionReaped. The
+// queued packets are still delivered, after which the channel is closed.
+func (s *subscription) drop(end subscriptionEnd) {
+	s.mu.Lock()
+	if s.end == subscriptionOpen {
+		s.end = end
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+// idle returns how long ago the last packet was published while the subscription is open.
+func (s *subscription) idle(now time.Time) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return now.Sub(s.last), s.end == subscriptionOpen
+}
+
+// signal wakes the delivery goroutine without blocking.
+func (s *subscription) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends the queued packets to the channel of the subscriber until the subscription ends.
+func (s *subscription) deliver() {
+	for {
+		s.mu.Lock()
+		queue, end := s.queue, s.end
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, p := range queue {
+			// A subscriber that unsubscribed might still be reading, so stopping takes precedence over delivering.
+			select {
+			case <-s.stop:
+				close(s.ch)
+				return
+			default:
+			}
+			select {
+			case s.ch <- p:
+			case <-s.stop:
+				close(s.ch)
+				return
+			}
+		}
+		// More packets might have been queued while delivering.
+		if len(queue) > 0 {
+			continue
+		}
+
+		switch end {
+		case subscriptionCompleted:
+			s.release(s)
+			return
+		case subscriptionDropped, subscriptionReaped:
+			close(s.ch)
+			return
+		}
+
+		select {
+		case <-s.wake:
+		case <-s.stop:
+			close(s.ch)
+			return
+		}
+	}
+}
+
+// subscribe registers a channel to receive responses for a specific transaction ID. The subscription ends by itself
+// once the operation response has been delivered, but the subscriber must call unsubscribe when it stops reading before
+// that. Do not close the channel: it is closed by unsubscribe and when the connection drops.
+func (c *Client) subscribe(tid ptp.TransactionID, ch chan<- []byte) error {
+	c.cmdDataSubsMu.Lock()
+	defer c.cmdDataSubsMu.Unlock()
+
+	return c.addSubscription(tid, ch)
+}
+
+// addSubscription does the work for subscribe. The caller must hold cmdDataSubsMu.
+func (c *Client) addSubscription(tid ptp.TransactionID, ch chan<- []byte) error {
+	if _, ok := c.cmdDataSubs[tid]; ok {
+		return fmt.Errorf("attempt to double subscribe transaction id %d", tid)
+	}
+	c.cmdDataSubs[tid] = newSubscription(tid, ch, c.releaseSubscription)
+
+	return nil
+}
+
+// beginTransaction starts a new transaction by subscribing ch to the next transaction ID, which is returned. Every
+// transaction has a subscription until it ends, so the subscriptions tell how many transactions are in flight. When
+// PipelineDepth transactions are in flight already, beginTransaction waits for one of them to end. This keeps the
+// operations of the user and those of the event and liveview pollers from interleaving on the command/data connection,
+// which most Responders do not tolerate.
+func (c *Client) beginTransaction(ch chan<- []byte) (ptp.TransactionID, error) {
+	c.cmdDataSubsMu.Lock()
+	defer c.cmdDataSubsMu.Unlock()
+
+	for len(c.cmdDataSubs) >= c.PipelineDepth() {
+		c.cmdDataSubsEnded.Wait()
+	}
+	// The transaction ID is only taken once it is this transaction's turn, so the IDs go out in order.
+	tid := c.incrementTransactionId()
+
+	return tid, c.addSubscription(tid, ch)
+}
+
+// unsubscribe removes a subscription for a given transaction ID and closes the corresponding channel. It does nothing
+// when the subscription already ended.
+func (c *Client) unsubscribe(tid ptp.TransactionID) {
+	c.cmdDataSubsMu.Lock()
+	if s, ok := c.cmdDataSubs[tid]; ok {
+		close(s.stop)
+		delete(c.cmdDataSubs, tid)
+		c.cmdDataSubsEnded.Broadcast()
+	}
+	c.cmdDataSubsMu.Unlock()
+	c.endTransactionSpan(tid, nil)
+}
+
+// releaseSubscription removes a subscription of which the transaction completed, unless the transaction ID has been
+// subscribed to again in the meantime.
+func (c *Client) releaseSubscription(s *subscription) {
+	c.cmdDataSubsMu.Lock()
+	if c.cmdDataSubs[s.tid] == s {
+		delete(c.cmdDataSubs, s.tid)
+		c.cmdDataSubsEnded.Broadcast()
+	}
+	c.cmdDataSubsMu.Unlock()
+}
+
+// publish hands a packet received on the command/data connection to the subscriber of the transaction. Returns false
+// when nobody subscribed to the transaction.
+func (c *Client) publish(tid ptp.TransactionID, p []byte, final bool) bool {
+	c.cmdDataSubsMu.Lock()
+	defer c.cmdDataSubsMu.Unlock()
+
+	s, ok := c.cmdDataSubs[tid]
+	if ok {
+		s.publish(p, final)
+	}
+
+	return ok
+}
+
+// dropSubscriptions ends all subscriptions because the command/data connection dropped. The subscribers receive the
+// packets that were queued already, after which their channel is closed.
+func (c *Client) dropSubscriptions() {
+	c.cmdDataSubsMu.Lock()
+	defer c.cmdDataSubsMu.Unlock()
+
+	for tid, s := range c.cmdDataSubs {
+		s.drop(subscriptionDropped)
+		delete(c.cmdDataSubs, tid)
+	}
+	c.cmdDataSubsEnded.Broadcast()
+}
@@ -0,0 +1,139 @@
+package ip
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func newSubscriptionTestClient(t *testing.T) *Client {
+	c, err := NewClient(DefaultVendor, address, failPort, "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestClient_publish(t *testing.T) {
+	c := newSubscriptionTestClient(t)
+
+	if c.publish(7, []byte{0x01}, false) {
+		t.Errorf("publish() got = true; want false without subscriber")
+	}
+
+	tid := ptp.TransactionID(7)
+	ch := make(chan []byte)
+	if err := c.subscribe(tid, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	// Publishing must not block although nobody reads the unbuffered channel yet.
+	want := [][]byte{{0x01}, {0x02}, {0x03}}
+	for i, p := range want {
+		if !c.publish(tid, p, i == len(want)-1) {
+			t.Fatalf("publish() got = false; want true")
+		}
+	}
+	// Packets arriving after the final one are ignored.
+	c.publish(tid, []byte{0x04}, false)
+
+	for _, w := range want {
+		got, err := c.WaitForRawPacketFromCommandDataSubscriber(ch)
+		if err != nil || !bytes.Equal(got, w) {
+			t.Errorf("WaitForRawPacketFromCommandDataSubscriber() got = %#v, %v; want %#v, <nil>", got, err, w)
+		}
+	}
+	select {
+	case got := <-ch:
+		t.Errorf("publish() delivered %#v after the final packet; want nothing", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.cmdDataSubsMu.Lock()
+	_, ok := c.cmdDataSubs[tid]
+	c.cmdDataSubsMu.Unlock()
+	if ok {
+		t.Errorf("publish() kept the subscription of a completed transaction")
+	}
+	// The transaction ID can be used again.
+	if err := c.subscribe(tid, make(chan []byte, 1)); err != nil {
+		t.Errorf("subscribe() error = %s; want <nil>", err)
+	}
+}
+
+func TestClient_unsubscribe(t *testing.T) {
+	c := newSubscriptionTestClient(t)
+
+	tid := ptp.TransactionID(8)
+	ch := make(chan []byte)
+	if err := c.subscribe(tid, ch); err != nil {
+		t.Fatal(err)
+	}
+	c.publish(tid, []byte{0x01}, false)
+	c.unsubscribe(tid)
+
+	// The packet that was never read is dropped.
+	if got, ok := <-ch; ok {
+		t.Errorf("unsubscribe() got = %#v; want closed channel", got)
+	}
+	if c.publish(tid, []byte{0x02}, false) {
+		t.Errorf("publish() got = true; want false after unsubscribe")
+	}
+	// Unsubscribing twice does nothing.
+	c.unsubscribe(tid)
+}
+
+func TestClient_dropSubscriptions(t *testing.T) {
+	c := newSubscriptionTestClient(t)
+
+	ch := make(chan []byte, 1)
+	if err := c.subscribe(9, ch); err != nil {
+		t.Fatal(err)
+	}
+	c.publish(9, []byte{0x01}, false)
+	c.dropSubscriptions()
+
+	if got, err := c.WaitForRawPacketFromCommandDataSubscriber(ch); err != nil || !bytes.Equal(got, []byte{0x01}) {
+		t.Errorf("WaitForRawPacketFromCommandDataSubscriber() got = %#v, %v; want the queued packet", got, err)
+	}
+	if _, err := c.WaitForRawPacketFromCommandDataSubscriber(ch); err != ConnectionLostError {
+		t.Errorf("WaitForRawPacketFromCommandDataSubscriber() error = %v; want %s", err, ConnectionLostError)
+	}
+	c.unsubscribe(9)
+}
+
+func TestClient_beginTransaction(t *testing.T) {
+	vc := &virtualCamera{last: 1, faults: responderFaults{Latency: 10 * time.Millisecond}}
+	c, err := NewClient(DefaultVendor, address, startVirtualCamera(t, vc), "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Dial(); err != nil {
+		t.Fatalf("Dial() error = %s; want <nil>", err)
+	}
+
+	// The virtual camera has no liveview, so the poller keeps polling next to the operations of the user.
+	var polls atomic.Int32
+	c.startLiveviewPoller("[testLiveviewPoller]", func(c *Client) ([]byte, FrameMeta, error) {
+		polls.Add(1)
+		return SonyGetLiveviewImage(c)
+	})
+	for i := 0; i < 15; i++ {
+		if _, err := c.GetObjectInfo(1); err != nil {
+			t.Fatalf("GetObjectInfo() error = %s; want <nil>", err)
+		}
+	}
+	c.stopLiveviewPoller()
+
+	if polls.Load() == 0 {
+		t.Fatal("beginTransaction() the poller did not poll")
+	}
+	if got := vc.mostPendingRequests(); got != 1 {
+		t.Errorf("beginTransaction() transactions in flight = %d; want 1", got)
+	}
+}
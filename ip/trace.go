@@ -0,0 +1,257 @@
+package ip
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+const (
+	// SpanDial is the name of the span covering Dial().
+	SpanDial = "ptpip.Dial"
+	// SpanInitCommandData is the name of the span covering the initialisation of the Command/Data connection.
+	SpanInitCommandData = "ptpip.InitCommandData"
+	// SpanInitEvent is the name of the span covering the initialisation of the Event connection.
+	SpanInitEvent = "ptpip.InitEvent"
+	// SpanInitStream is the name of the span covering the initialisation of the streamer connection.
+	SpanInitStream = "ptpip.InitStream"
+	// SpanTransaction is the name of the span covering a single PTP transaction, from sending the operation request up
+	// to receiving the operation response.
+	SpanTransaction = "ptpip.Transaction"
+
+	// AttrVendor holds the vendor of the responder, e.g. "fuji".
+	AttrVendor = "ptpip.vendor"
+	// AttrOperationCode holds the operation code of a transaction.
+	AttrOperationCode = "ptpip.operation_code"
+	// AttrOperationName holds the name of the operation of a transaction, or the hexadecimal operation code for
+	// vendor-extended operations.
+	AttrOperationName = "ptpip.operation_name"
+	// AttrTransactionId holds the ID of a transaction.
+	AttrTransactionId = "ptpip.transaction_id"
+	// AttrDataSize holds the number of bytes sent or received during the data phase of a transaction.
+	AttrDataSize = "ptpip.data_size"
+	// AttrResponseCode holds the operation response code of a transaction.
+	AttrResponseCode = "ptpip.response_code"
+)
+
+// Attribute is a key value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer creates spans. It follows the OpenTelemetry tracing API, so an OpenTelemetry tracer only needs a thin adapter
+// converting the attributes to be plugged in using Client.SetTracer().
+type Tracer interface {
+	// Start creates a span as a child of the span held by ctx, if any, and returns a context holding the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a single timed operation.
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attrs ...Attribute)
+	// RecordError marks the span as failed with the given error.
+	RecordError(err error)
+	// End completes the span.
+	End()
+}
+
+// noopTracer is the default tracer which does nothing at all.
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(_ ...Attribute) {}
+
+func (noopSpan) RecordError(_ error) {}
+
+func (noopSpan) End() {}
+
+// transactionSpan is the span of a transaction in flight.
+type transactionSpan struct {
+	Span
+	size int
+}
+
+// transactionSpans keeps track of the spans of the transactions in flight.
+type transactionSpans struct {
+	mu    sync.Mutex
+	spans map[ptp.TransactionID]*transactionSpan
+	// ctx is the context of the phase being traced, if any, which is the parent of the transaction spans.
+	ctx context.Context
+}
+
+// parent returns the context to start a new span with. The caller must hold the lock.
+func (ts *transactionSpans) parent() context.Context {
+	if ts.ctx == nil {
+		return context.Background()
+	}
+
+	return ts.ctx
+}
+
+// SetTracer sets the tracer used to create a span for each transaction and for each phase of Dial(). Pass nil to
+// disable tracing, which is the default.
+func (c *Client) SetTracer(t Tracer) {
+	if t == nil {
+		t = noopTracer{}
+	}
+	c.tracer = t
+}
+
+// startSpan starts a span for a phase of the client as a child of the phase currently being traced, if any. Call the
+// returned function, passing the resulting error, to end it.
+func (c *Client) startSpan(name string) func(error) {
+	c.txSpans.mu.Lock()
+	prev := c.txSpans.ctx
+	ctx, span := c.tracer.Start(c.txSpans.parent(), name, Attribute{AttrVendor, c.ResponderVendor().String()})
+	c.txSpans.ctx = ctx
+	c.txSpans.mu.Unlock()
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+
+		c.txSpans.mu.Lock()
+		if c.txSpans.ctx == ctx {
+			c.txSpans.ctx = prev
+		}
+		c.txSpans.mu.Unlock()
+	}
+}
+
+// traceRequest starts the span of a transaction when p is an operation request and adds the size of the data to the
+// span when p is a data packet.
+func (c *Client) traceRequest(p PacketOut, pl []byte) {
+	var (
+		code ptp.OperationCode
+		tid  ptp.TransactionID
+	)
+	switch pkt := p.(type) {
+	case *OperationRequestPacket:
+		code, tid = pkt.OperationCode, pkt.TransactionID
+	case *FujiOperationRequestPacket:
+		code, tid = pkt.OperationCode, pkt.TransactionID
+	case *DataPacket, *EndDataPacket:
+		tid, _ = packetTransactionId(p)
+		c.txSpans.mu.Lock()
+		if ts, ok := c.txSpans.spans[tid]; ok && len(pl) > 4 {
+			// The payload starts with the transaction ID.
+			ts.size += len(pl) - 4
+		}
+		c.txSpans.mu.Unlock()
+		return
+	default:
+		return
+	}
+
+	c.txSpans.mu.Lock()
+	defer c.txSpans.mu.Unlock()
+
+	_, span := c.tracer.Start(c.txSpans.parent(), SpanTransaction,
+		Attribute{AttrVendor, c.ResponderVendor().String()},
+		Attribute{AttrOperationCode, uint16(code)},
+		Attribute{AttrOperationName, ptp.OperationCodeName(code)},
+		Attribute{AttrTransactionId, uint32(tid)},
+	)
+	if c.txSpans.spans == nil {
+		c.txSpans.spans = make(map[ptp.TransactionID]*transactionSpan)
+	}
+	c.txSpans.spans[tid] = &transactionSpan{Span: span}
+}
+
+// traceResponse adds the size of the data to the span of the transaction when p is a data packet and ends the span
+// when p is the operation response.
+func (c *Client) traceResponse(tid ptp.TransactionID, p []byte) {
+	size, rc, final := c.vendorExtensions.inspectResponse(p)
+
+	c.txSpans.mu.Lock()
+	ts, ok := c.txSpans.spans[tid]
+	if !ok {
+		c.txSpans.mu.Unlock()
+		return
+	}
+	ts.size += size
+	if final {
+		delete(c.txSpans.spans, tid)
+	}
+	c.txSpans.mu.Unlock()
+
+	if final {
+		// Some vendors use other response codes than ptp.RC_OK to signal success, so no error is recorded here.
+		ts.SetAttributes(Attribute{AttrDataSize, ts.size}, Attribute{AttrResponseCode, uint16(rc)})
+		ts.End()
+	}
+}
+
+// endTransactionSpan ends the span of a transaction that did not end with an operation response. It does nothing when
+// the span has already ended.
+func (c *Client) endTransactionSpan(tid ptp.TransactionID, err error) {
+	c.txSpans.mu.Lock()
+	ts, ok := c.txSpans.spans[tid]
+	delete(c.txSpans.spans, tid)
+	c.txSpans.mu.Unlock()
+
+	if ok {
+		ts.SetAttributes(Attribute{AttrDataSize, ts.size})
+		if err != nil {
+			ts.RecordError(err)
+		}
+		ts.End()
+	}
+}
+
+// endTransactionSpans ends the spans of all transactions in flight.
+func (c *Client) endTransactionSpans(err error) {
+	c.txSpans.mu.Lock()
+	var tids []ptp.TransactionID
+	for tid := range c.txSpans.spans {
+		tids = append(tids, tid)
+	}
+	c.txSpans.mu.Unlock()
+
+	for _, tid := range tids {
+		c.endTransactionSpan(tid, err)
+	}
+}
+
+// GenericInspectResponse returns the size of the data held by a full raw inbound packet received on the Command/Data
+// connection and, when the packet is the operation response, the response code.
+func GenericInspectResponse(p []byte) (int, ptp.OperationResponseCode, bool) {
+	if len(p) < HeaderSize+4 {
+		return 0, 0, false
+	}
+
+	switch PacketType(binary.LittleEndian.Uint32(p[4:8])) {
+	case PKT_Data, PKT_EndData:
+		return len(p) - HeaderSize - 4, 0, false
+	case PKT_OperationResponse:
+		return 0, ptp.OperationResponseCode(binary.LittleEndian.Uint16(p[8:10])), true
+	}
+
+	return 0, 0, false
+}
+
+// FujiInspectResponse returns the size of the data held by a full raw inbound packet received on the Command/Data
+// connection and, when the packet is the operation response, the response code. Fuji sends the data phase in place of
+// the packet type, followed by the operation code for data packets or the response code for the operation response.
+func FujiInspectResponse(p []byte) (int, ptp.OperationResponseCode, bool) {
+	if len(p) < 12 {
+		return 0, 0, false
+	}
+
+	if DataPhase(binary.LittleEndian.Uint16(p[4:6])) == DP_DataOut {
+		return len(p) - 12, 0, false
+	}
+
+	return 0, ptp.OperationResponseCode(binary.LittleEndian.Uint16(p[6:8])), true
+}
@@ -0,0 +1,165 @@
+package ip
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+type spanKey struct{}
+
+type recordedSpan struct {
+	name   string
+	parent *recordedSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+	mu     *sync.Mutex
+}
+
+func (rs *recordedSpan) SetAttributes(attrs ...Attribute) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for _, a := range attrs {
+		rs.attrs[a.Key] = a.Value
+	}
+}
+
+func (rs *recordedSpan) RecordError(err error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.err = err
+}
+
+func (rs *recordedSpan) End() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.ended = true
+}
+
+// spanRecorder is a Tracer keeping all spans it creates.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (sr *spanRecorder) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*recordedSpan)
+	rs := &recordedSpan{name: name, parent: parent, attrs: make(map[string]interface{}), mu: &sr.mu}
+	rs.SetAttributes(attrs...)
+
+	sr.mu.Lock()
+	sr.spans = append(sr.spans, rs)
+	sr.mu.Unlock()
+
+	return context.WithValue(ctx, spanKey{}, rs), rs
+}
+
+func (sr *spanRecorder) find(name string) []*recordedSpan {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	var res []*recordedSpan
+	for _, rs := range sr.spans {
+		if rs.name == name {
+			res = append(res, rs)
+		}
+	}
+
+	return res
+}
+
+func TestClient_SetTracer(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, okPort, "tèster", "558acd44-f794-4b26-9129-d460b2a29e8d", logLevel)
+	defer c.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sr := &spanRecorder{}
+	c.SetTracer(sr)
+
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetDeviceInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	dial := sr.find(SpanDial)
+	if len(dial) != 1 || !dial[0].ended || dial[0].err != nil {
+		t.Fatalf("SetTracer() dial spans = %v; want one ended span without error", dial)
+	}
+	for _, name := range []string{SpanInitCommandData, SpanInitEvent} {
+		if got := sr.find(name); len(got) != 1 || got[0].parent != dial[0] || !got[0].ended {
+			t.Errorf("SetTracer() %s spans = %v; want one ended child of %s", name, got, SpanDial)
+		}
+	}
+
+	tx := sr.find(SpanTransaction)
+	if len(tx) != 1 {
+		t.Fatalf("SetTracer() transaction spans = %d; want 1", len(tx))
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if !tx[0].ended || tx[0].parent != nil {
+		t.Errorf("SetTracer() transaction span ended = %t, parent = %v; want true, <nil>", tx[0].ended, tx[0].parent)
+	}
+	// The mock responder always answers transaction 2 without setting a response code.
+	want := map[string]interface{}{
+		AttrVendor:        "generic",
+		AttrOperationCode: uint16(ptp.OC_GetDeviceInfo),
+		AttrOperationName: "GetDeviceInfo",
+		AttrTransactionId: uint32(2),
+		AttrResponseCode:  uint16(0),
+		AttrDataSize:      0,
+	}
+	for k, v := range want {
+		if got := tx[0].attrs[k]; got != v {
+			t.Errorf("SetTracer() transaction attribute %s = %v; want %v", k, got, v)
+		}
+	}
+}
+
+func TestGenericInspectResponse(t *testing.T) {
+	check := []struct {
+		p     []byte
+		size  int
+		rc    ptp.OperationResponseCode
+		final bool
+	}{
+		{[]byte{0x0e, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x01, 0x20, 0x01, 0x00, 0x00, 0x00}, 0, ptp.RC_OK, true},
+		{[]byte{0x0f, 0x00, 0x00, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0xaa, 0xbb, 0xcc}, 3, 0, false},
+		{[]byte{0x0c, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}, 0, 0, false},
+		{[]byte{0x04, 0x00}, 0, 0, false},
+	}
+
+	for _, tt := range check {
+		size, rc, final := GenericInspectResponse(tt.p)
+		if size != tt.size || rc != tt.rc || final != tt.final {
+			t.Errorf("GenericInspectResponse() got = %d, %s, %t; want %d, %s, %t", size, rc, final, tt.size, tt.rc, tt.final)
+		}
+	}
+}
+
+func TestFujiInspectResponse(t *testing.T) {
+	check := []struct {
+		p     []byte
+		size  int
+		rc    ptp.OperationResponseCode
+		final bool
+	}{
+		{[]byte{0x0c, 0x00, 0x00, 0x00, 0x03, 0x00, 0x01, 0x20, 0x01, 0x00, 0x00, 0x00}, 0, ptp.RC_OK, true},
+		{[]byte{0x0e, 0x00, 0x00, 0x00, 0x02, 0x00, 0x15, 0x10, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00}, 2, 0, false},
+		{[]byte{0x04, 0x00}, 0, 0, false},
+	}
+
+	for _, tt := range check {
+		size, rc, final := FujiInspectResponse(tt.p)
+		if size != tt.size || rc != tt.rc || final != tt.final {
+			t.Errorf("FujiInspectResponse() got = %d, %s, %t; want %d, %s, %t", size, rc, final, tt.size, tt.rc, tt.final)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ip
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// SetTransferRateLimit limits the throughput of object transfers, i.e. GetObject, GetObjectTo and DownloadObjects, to
+// the given number of bytes per second. Reading from the command/data connection is slowed down so that TCP flow
+// control makes the Responder send slower as well, leaving room on a shared WiFi link for e.g. the liveview stream.
+// Sessions opened using NewSession after setting the limit share it with this client. A limit of zero or less removes
+// the limit.
+func (c *Client) SetTransferRateLimit(bytesPerSecond int64) {
+	if bytesPerSecond <= 0 {
+		c.transferLimit = nil
+		return
+	}
+	c.transferLimit = newRateLimiter(bytesPerSecond)
+}
+
+// TransferRateLimit returns the number of bytes per second object transfers are limited to, zero meaning unlimited.
+func (c *Client) TransferRateLimit() int64 {
+	if c.transferLimit == nil {
+		return 0
+	}
+
+	return int64(c.transferLimit.rate)
+}
+
+// transferWriter returns w wrapped to honour the transfer rate limit. When there is no limit, w is returned as is.
+func (c *Client) transferWriter(w io.Writer) io.Writer {
+	if c.transferLimit == nil {
+		return w
+	}
+
+	return &limitedWriter{w: w, l: c.transferLimit}
+}
+
+// rateLimiter is a token bucket holding at most one second worth of bytes. It starts out empty so that a transfer
+// never exceeds the rate, not even during the first second.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a rateLimiter allowing the given number of bytes per second.
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		rate: float64(bytesPerSecond),
+		last: time.Now(),
+	}
+}
+
+// wait blocks until n bytes may be transferred. Concurrent callers are served in turn: each one takes its bytes from
+// the bucket, going into debt when needed, and sleeps until the debt is paid off.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(d)
+}
+
+// limitedWriter is an io.Writer passing the data to w no faster than the rateLimiter allows.
+type limitedWriter struct {
+	w io.Writer
+	l *rateLimiter
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	lw.l.wait(len(p))
+	return lw.w.Write(p)
+}
@@ -0,0 +1,47 @@
+package ip
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_wait(t *testing.T) {
+	l := newRateLimiter(10000)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.wait(1000)
+	}
+	if got, want := time.Since(start), 250*time.Millisecond; got < want {
+		t.Errorf("wait() took %s; want at least %s", got, want)
+	}
+}
+
+func TestClient_SetTransferRateLimit(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if got := c.transferWriter(&buf); got != &buf {
+		t.Errorf("transferWriter() got = %T; want *bytes.Buffer", got)
+	}
+
+	c.SetTransferRateLimit(2000000)
+	if got, want := c.TransferRateLimit(), int64(2000000); got != want {
+		t.Errorf("TransferRateLimit() got = %d; want %d", got, want)
+	}
+	if _, ok := c.transferWriter(&buf).(*limitedWriter); !ok {
+		t.Errorf("transferWriter() got = %T; want *limitedWriter", c.transferWriter(&buf))
+	}
+	if got := c.newSessionClient().TransferRateLimit(); got != 2000000 {
+		t.Errorf("TransferRateLimit() of new session got = %d; want %d", got, 2000000)
+	}
+
+	c.SetTransferRateLimit(0)
+	if got := c.TransferRateLimit(); got != 0 {
+		t.Errorf("TransferRateLimit() got = %d; want 0", got)
+	}
+}
@@ -0,0 +1,158 @@
+package ip
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// ErrNoSuchObject is returned by ObjectTree.Lookup when there is no object at the given path.
+var ErrNoSuchObject = errors.New("no such file or folder")
+
+// ObjectTree is the folder hierarchy of the objects in a store of the Responder, built from the association objects.
+// The contents of a folder are only requested from the Responder when they are first needed, so that browsing a card
+// holding many images does not require reading the ObjectInfo dataset of each of them upfront. An ObjectTree is safe
+// for concurrent use.
+type ObjectTree struct {
+	c    *Client
+	sid  ptp.StorageID
+	mu   sync.Mutex
+	root *ObjectNode
+}
+
+// ObjectNode is a folder or any other object in an ObjectTree.
+type ObjectNode struct {
+	Object
+	// Parent is the folder holding the object, which is the root node of the tree for the objects in the root of a
+	// store.
+	Parent   *ObjectNode
+	tree     *ObjectTree
+	children []*ObjectNode
+	loaded   bool
+}
+
+// ObjectTree returns the folder hierarchy of the objects in the given store, AllStorages combining the root folders of
+// all stores.
+func (c *Client) ObjectTree(sid ptp.StorageID) *ObjectTree {
+	t := &ObjectTree{c: c, sid: sid}
+	t.root = &ObjectNode{Object: Object{Handle: RootObjects}, tree: t}
+
+	return t
+}
+
+// Root returns the root node of the tree, which holds the objects in the root of the store. It has no ObjectInfo
+// dataset.
+func (t *ObjectTree) Root() *ObjectNode {
+	return t.root
+}
+
+// Lookup returns the node at the given slash separated path of file names, e.g. /DCIM/100_FUJI. The folders along the
+// way are loaded when needed.
+func (t *ObjectTree) Lookup(p string) (*ObjectNode, error) {
+	n := t.root
+	for _, name := range strings.Split(strings.Trim(path.Clean("/"+p), "/"), "/") {
+		if name == "" {
+			continue
+		}
+
+		children, err := n.Children()
+		if err != nil {
+			return nil, err
+		}
+
+		var next *ObjectNode
+		for _, child := range children {
+			if child.Name() == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("%s: %w", path.Join(n.Path(), name), ErrNoSuchObject)
+		}
+		n = next
+	}
+
+	return n, nil
+}
+
+// Reset forgets the contents of all folders, so that they are requested again, e.g. after objects have been added or
+// deleted.
+func (t *ObjectTree) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.root.children, t.root.loaded = nil, false
+}
+
+// IsFolder returns true when the node is the root node or an association, such as a folder.
+func (n *ObjectNode) IsFolder() bool {
+	return n.Info == nil || n.Info.ObjectFormat == ptp.OFC_Association
+}
+
+// Name returns the file name of the object, or / for the root node.
+func (n *ObjectNode) Name() string {
+	if n.Info == nil {
+		return "/"
+	}
+
+	return n.Info.Filename
+}
+
+// Path returns the slash separated path of file names leading to the object.
+func (n *ObjectNode) Path() string {
+	if n.Parent == nil {
+		return "/"
+	}
+
+	return path.Join(n.Parent.Path(), n.Name())
+}
+
+// Children returns the objects in the folder sorted by file name, requesting them from the Responder the first time.
+// Objects that are not a folder have no children.
+func (n *ObjectNode) Children() ([]*ObjectNode, error) {
+	if !n.IsFolder() {
+		return nil, nil
+	}
+
+	n.tree.mu.Lock()
+	defer n.tree.mu.Unlock()
+
+	if n.loaded {
+		return n.children, nil
+	}
+
+	c := n.tree.c
+	handles, err := c.GetObjectHandles(n.tree.sid, 0, n.Handle)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := c.GetObjectInfos(handles)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some Responders ignore the parent object and return all objects, so check the parent of each of them.
+	parent := n.Handle
+	if parent == RootObjects {
+		parent = 0
+	}
+	var children []*ObjectNode
+	for i, h := range handles {
+		if infos[i].ParentObject != parent {
+			continue
+		}
+		children = append(children, &ObjectNode{Object: Object{Handle: h, Info: infos[i]}, Parent: n, tree: n.tree})
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+	n.children, n.loaded = children, true
+
+	return children, nil
+}
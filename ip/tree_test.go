@@ -0,0 +1,68 @@
+package ip
+
+import (
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestObjectTree(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos := map[ptp.ObjectHandle]*ptp.ObjectInfo{
+		1: {Filename: "DCIM", ObjectFormat: ptp.OFC_Association},
+		2: {Filename: "100_FUJI", ObjectFormat: ptp.OFC_Association, ParentObject: 1},
+		3: {Filename: "DSCF0002.JPG", ObjectFormat: ptp.OFC_EXIF_JPEG, ParentObject: 2},
+		4: {Filename: "DSCF0001.JPG", ObjectFormat: ptp.OFC_EXIF_JPEG, ParentObject: 2},
+		5: {Filename: "MISC", ObjectFormat: ptp.OFC_Association},
+	}
+	requests := make(map[ptp.ObjectHandle]int)
+	c.vendorExtensions.getObjectHandles = func(_ *Client, _ ptp.StorageID, _ ptp.ObjectFormatCode, parent ptp.ObjectHandle) ([]ptp.ObjectHandle, error) {
+		requests[parent]++
+		// Return all objects, like Responders ignoring the parent do.
+		return []ptp.ObjectHandle{1, 2, 3, 4, 5}, nil
+	}
+	c.vendorExtensions.getObjectInfo = func(_ *Client, h ptp.ObjectHandle) (*ptp.ObjectInfo, error) {
+		return infos[h], nil
+	}
+
+	tree := c.ObjectTree(AllStorages)
+	root, err := tree.Root().Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root) != 2 || root[0].Name() != "DCIM" || root[1].Name() != "MISC" {
+		t.Errorf("Children() got = %v; want DCIM and MISC", root)
+	}
+
+	n, err := tree.Lookup("/DCIM/100_FUJI")
+	if err != nil {
+		t.Fatalf("Lookup() err = %s; want <nil>", err)
+	}
+	if got, want := n.Path(), "/DCIM/100_FUJI"; got != want {
+		t.Errorf("Path() got = %s; want %s", got, want)
+	}
+	files, _ := n.Children()
+	if len(files) != 2 || files[0].Handle != 4 || files[1].Handle != 3 || files[0].IsFolder() {
+		t.Errorf("Children() got = %v; want DSCF0001.JPG and DSCF0002.JPG", files)
+	}
+
+	// The folders are loaded once.
+	tree.Lookup("DCIM/100_FUJI/DSCF0001.JPG")
+	if requests[RootObjects] != 1 || requests[1] != 1 || requests[2] != 1 {
+		t.Errorf("GetObjectHandles() requests = %v; want one per folder", requests)
+	}
+
+	if _, err := tree.Lookup("/DCIM/101_FUJI"); err == nil {
+		t.Errorf("Lookup() err = <nil>; want error")
+	}
+
+	tree.Reset()
+	tree.Root().Children()
+	if requests[RootObjects] != 2 {
+		t.Errorf("GetObjectHandles() root requests = %d; want 2", requests[RootObjects])
+	}
+}
@@ -1,9 +1,13 @@
 package ip
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/malc0mn/ptp-ip/ip/internal"
@@ -17,12 +21,19 @@ import (
 //  called and not the ones on ip.FujiClient so you would also have to "override" the Dial() as well.
 type VendorExtensions struct {
 	cmdDataInit             func(*Client) error
+	closeSession            func(*Client) error
+	changeClient            func(*Client, time.Duration) error
+	resetConnection         func(*Client) error
+	newSession              func(*Client) (*Client, error)
 	eventInit               func(*Client) error
 	processStreamData       func(*Client) error
+	pollEvents              func(*Client) error
 	newCmdDataInitPacket    func(uuid.UUID, string) InitCommandRequestPacket
 	newEventInitPacket      func(uint32) InitEventRequestPacket
 	newEventPacket          func() EventPacket
 	extractTransactionId    func([]byte, connectionType) (ptp.TransactionID, error)
+	inspectResponse         func([]byte) (int, ptp.OperationResponseCode, bool)
+	dumpRawPacket           func([]byte) string
 	getDeviceInfo           func(*Client) (interface{}, error)
 	getDeviceState          func(*Client) (interface{}, error)
 	getDevicePropertyDesc   func(*Client, ptp.DevicePropCode) (*ptp.DevicePropDesc, error)
@@ -30,19 +41,38 @@ type VendorExtensions struct {
 	setDeviceProperty       func(*Client, ptp.DevicePropCode, uint32) error
 	operationRequestRaw     func(*Client, ptp.OperationCode, []uint32) ([]byte, error)
 	operationDataRequestRaw func(*Client, ptp.OperationCode, []uint32) ([]byte, error)
+	operationRequestData    func(*Client, ptp.OperationCode, []uint32) ([]byte, error)
 	initiateCapture         func(*Client) ([]byte, error)
-	sendData                func(*Client, ptp.OperationCode, []uint32, []byte, uint64) ([]byte, error)
+	getStorageIDs           func(*Client) ([]ptp.StorageID, error)
+	getStorageInfo          func(*Client, ptp.StorageID) (*ptp.StorageInfo, error)
+	getObjectHandles        func(*Client, ptp.StorageID, ptp.ObjectFormatCode, ptp.ObjectHandle) ([]ptp.ObjectHandle, error)
+	getNumObjects           func(*Client, ptp.StorageID, ptp.ObjectFormatCode, ptp.ObjectHandle) (int, error)
+	getObjectInfo           func(*Client, ptp.ObjectHandle) (*ptp.ObjectInfo, error)
+	getObject               func(*Client, ptp.ObjectHandle) ([]byte, error)
+	getPartialObject        func(*Client, ptp.ObjectHandle, uint32, uint32) ([]byte, error)
+	getThumb                func(*Client, ptp.ObjectHandle) ([]byte, error)
+	setObjectProtection     func(*Client, ptp.ObjectHandle, ptp.ProtectionStatus) error
+	sendData                func(context.Context, *Client, ptp.OperationCode, []uint32, []byte, uint64) ([]byte, error)
+	setLiveviewZoom         func(*Client, int) (int, error)
+	toggleLiveView          func(*Client, bool) error
 }
 
 func (c *Client) loadVendorExtensions() {
 	c.vendorExtensions = &VendorExtensions{
 		cmdDataInit:             GenericInitCommandDataConn,
+		closeSession:            GenericCloseSession,
+		changeClient:            GenericChangeClient,
+		resetConnection:         GenericResetConnection,
+		newSession:              GenericNewSession,
 		eventInit:               GenericInitEventConn,
 		processStreamData:       GenericProcessStreamData,
+		pollEvents:              GenericPollEvents,
 		newCmdDataInitPacket:    NewInitCommandRequestPacket,
 		newEventInitPacket:      NewInitEventRequestPacket,
 		newEventPacket:          NewEventPacket,
 		extractTransactionId:    GenericExtractTransactionId,
+		inspectResponse:         GenericInspectResponse,
+		dumpRawPacket:           GenericDumpRawPacket,
 		getDeviceInfo:           GenericGetDeviceInfo,
 		getDeviceState:          GenericGetDeviceState,
 		getDevicePropertyDesc:   GenericGetDevicePropertyDesc,
@@ -50,29 +80,69 @@ func (c *Client) loadVendorExtensions() {
 		setDeviceProperty:       GenericSetDeviceProperty,
 		operationRequestRaw:     GenericOperationRequestRaw,
 		operationDataRequestRaw: GenericOperationDataRequestRaw,
+		operationRequestData:    GenericOperationRequestAndGetData,
 		initiateCapture:         GenericInitiateCapture,
+		getStorageIDs:           GenericGetStorageIDs,
+		getStorageInfo:          GenericGetStorageInfo,
+		getObjectHandles:        GenericGetObjectHandles,
+		getNumObjects:           GenericGetNumObjects,
+		getObjectInfo:           GenericGetObjectInfo,
+		getObject:               GenericGetObject,
+		getPartialObject:        GenericGetPartialObject,
+		getThumb:                GenericGetThumb,
+		setObjectProtection:     GenericSetObjectProtection,
 		sendData:                GenericSendData,
+		setLiveviewZoom:         GenericSetLiveviewZoom,
+		toggleLiveView:          GenericToggleLiveView,
 	}
 
 	switch c.ResponderVendor() {
+	case ptp.VE_CanonInc:
+		c.vendorExtensions.toggleLiveView = CanonToggleLiveView
 	case ptp.VE_FujiPhotoFilmCoLtd:
 		c.vendorExtensions.cmdDataInit = FujiInitCommandDataConn
+		c.vendorExtensions.closeSession = FujiCloseSession
+		c.vendorExtensions.changeClient = FujiChangeClient
+		c.vendorExtensions.resetConnection = FujiResetConnection
+		c.vendorExtensions.newSession = FujiNewSession
 		c.vendorExtensions.processStreamData = FujiProcessStreamData
 		c.vendorExtensions.newCmdDataInitPacket = NewFujiInitCommandRequestPacket
 		c.vendorExtensions.newEventInitPacket = NewFujiInitEventRequestPacket
+		c.vendorExtensions.newEventPacket = NewFujiEventPacket
 		c.vendorExtensions.extractTransactionId = FujiExtractTransactionId
+		c.vendorExtensions.inspectResponse = FujiInspectResponse
+		c.vendorExtensions.dumpRawPacket = FujiDumpRawPacket
 		c.vendorExtensions.getDeviceInfo = FujiGetDeviceInfo
 		c.vendorExtensions.getDeviceState = FujiGetDeviceState
 		c.vendorExtensions.getDevicePropertyDesc = FujiGetDevicePropertyDesc
 		c.vendorExtensions.getDevicePropertyValue = FujiGetDevicePropertyValue
 		c.vendorExtensions.setDeviceProperty = FujiSetDeviceProperty
+		c.vendorExtensions.operationRequestData = FujiOperationRequestAndGetData
 		c.vendorExtensions.initiateCapture = FujiInitiateCapture
+	case ptp.VE_NikonCorporation:
+		c.vendorExtensions.pollEvents = NikonPollEvents
+		c.vendorExtensions.setLiveviewZoom = NikonSetLiveviewZoom
+		c.vendorExtensions.toggleLiveView = NikonToggleLiveView
+	case ptp.VE_SonyCorporation:
+		c.vendorExtensions.pollEvents = SonyPollEvents
+		c.vendorExtensions.toggleLiveView = SonyToggleLiveView
 	}
 }
 
 // GenericInitCommandDataConn initiates the command/data connection. It expects an open TCP connection to the
 // command/data port to be present.
 func GenericInitCommandDataConn(c *Client) error {
+	if err := genericCommandDataHandshake(c); err != nil {
+		return err
+	}
+
+	c.startResponseListener()
+	return nil
+}
+
+// genericCommandDataHandshake sends the init command request and waits for the Responder to acknowledge it. The
+// command/data connection is closed when the Responder does not acknowledge the request.
+func genericCommandDataHandshake(c *Client) error {
 	err := c.SendPacketToCmdDataConn(c.newCmdDataInitPacket())
 	if err != nil {
 		return err
@@ -91,7 +161,6 @@ func GenericInitCommandDataConn(c *Client) error {
 		c.responder.GUID = pkt.ResponderGUID
 		c.responder.FriendlyName = pkt.ResponderFriendlyName
 		c.responder.ProtocolVersion = pkt.ResponderProtocolVersion
-		go c.responseListener()
 		return nil
 	default:
 		err = fmt.Errorf("unexpected packet received %T", res)
@@ -143,23 +212,47 @@ func GenericInitEventConn(c *Client) error {
 	return err
 }
 
+// GenericCloseSession does nothing since GenericInitCommandDataConn does not open a session.
+func GenericCloseSession(_ *Client) error {
+	return nil
+}
+
+// GenericChangeClient returns an error since a standard PTP/IP Responder does not pair with a single Initiator.
+func GenericChangeClient(_ *Client, _ time.Duration) error {
+	return errors.New("command not supported")
+}
+
+// GenericResetConnection closes the session and all connections and dials the Responder again.
+func GenericResetConnection(c *Client) error {
+	return c.resetConnection(c.Dial)
+}
+
 // GenericProcessStreamData does absolutely nothing since the standard PTP/IP protocol does not have a streamer
 // connection.
 func GenericProcessStreamData(_ *Client) error {
 	return nil
 }
 
+// GenericPollEvents does nothing since a standard PTP/IP Responder reports all of its events on the event connection.
+func GenericPollEvents(_ *Client) error {
+	return nil
+}
+
 // GenericExtractTransactionId extracts the transaction ID from a full raw inbound packet. This packet must include the
 // full header containing length and packet type.
 func GenericExtractTransactionId(p []byte, _ connectionType) (ptp.TransactionID, error) {
-	if len(p) < 13 {
-		return 0, fmt.Errorf("packet too small: got length %d", len(p))
+	errFmt := "packet too small: got length %d"
+	if len(p) < HeaderSize+4 {
+		return 0, fmt.Errorf(errFmt, len(p))
 	}
 
 	var data []byte
 	pt := PacketType(binary.LittleEndian.Uint32(p[4:8]))
 	switch pt {
 	case PKT_OperationResponse, PKT_Event:
+		if len(p) < 14 {
+			return 0, fmt.Errorf(errFmt, len(p))
+		}
 		data = p[10:14]
 	case PKT_StartData, PKT_Data, PKT_EndData, PKT_Cancel:
 		data = p[8:12]
@@ -171,15 +264,14 @@ func GenericExtractTransactionId(p []byte, _ connectionType) (ptp.TransactionID,
 
 // Request the Responder's device information.
 func GenericGetDeviceInfo(c *Client) (interface{}, error) {
-	tid := c.incrementTransactionId()
-
 	resCh := make(chan []byte, 2)
-	defer close(resCh)
-	if err := c.subscribe(tid, resCh); err != nil {
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
 		return nil, err
 	}
+	defer c.unsubscribe(tid)
 
-	err := c.SendPacketToCmdDataConn(&OperationRequestPacket{
+	err = c.SendPacketToCmdDataConn(&OperationRequestPacket{
 		DataPhaseInfo:    DP_NoDataOrDataIn,
 		OperationRequest: ptp.GetDeviceInfo(tid),
 	})
@@ -208,50 +300,163 @@ func GenericGetDeviceState(_ *Client) (interface{}, error) {
 	return nil, errors.New("command not supported")
 }
 
-// GenericGetDevicePropertyValue requests the value for the given property from the Responder's.
+// GenericGetDevicePropertyDesc requests the description of the given property from the Responder using
+// ptp.OC_GetDevicePropDesc.
 func GenericGetDevicePropertyDesc(c *Client, dpc ptp.DevicePropCode) (*ptp.DevicePropDesc, error) {
-	return nil, errors.New("command not YET supported")
+	data, err := GenericOperationRequestAndGetData(c, ptp.OC_GetDevicePropDesc, []uint32{uint32(dpc)})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDevicePropDesc(data)
 }
 
-// GenericGetDevicePropertyValue requests the value for the given property from the Responder's.
+// GenericGetDevicePropertyValue requests the value of the given property from the Responder using
+// ptp.OC_GetDevicePropValue. Only values of up to 4 bytes are supported.
 func GenericGetDevicePropertyValue(c *Client, dpc ptp.DevicePropCode) (uint32, error) {
-	return 0, errors.New("command not YET supported")
+	data, err := GenericOperationRequestAndGetData(c, ptp.OC_GetDevicePropValue, []uint32{uint32(dpc)})
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 || len(data) > 4 {
+		return 0, fmt.Errorf("property %#x: value of %d bytes does not fit an uint32", dpc, len(data))
+	}
+
+	v := make([]byte, 4)
+	copy(v, data)
+
+	return binary.LittleEndian.Uint32(v), nil
 }
 
-// GenericSetDeviceProperty sets the value for the given property on the Responder.
+// GenericSetDeviceProperty sets the value of the given property on the Responder using ptp.OC_SetDevicePropValue. The
+// value must be sent using the size of the data type of the property, so the description of the property is requested
+// first.
 func GenericSetDeviceProperty(c *Client, dpc ptp.DevicePropCode, val uint32) error {
-	return errors.New("command not YET supported")
-}
+	dpd, err := GenericGetDevicePropertyDesc(c, dpc)
+	if err != nil {
+		return err
+	}
+	size := dpd.SizeOfValueInBytes()
+	if size == 0 || size > 4 {
+		return fmt.Errorf("property %#x: data type %#x cannot hold an uint32", dpc, dpd.DataType)
+	}
 
-func GenericOperationRequestRaw(c *Client, code ptp.OperationCode, params []uint32) ([]byte, error) {
-	tid := c.incrementTransactionId()
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, val)
+	_, err = c.sendDataAndGetParameters(ptp.OC_SetDevicePropValue, []uint32{uint32(dpc)}, data[:size])
 
-	or := ptp.OperationRequest{
-		OperationCode: code,
-		TransactionID: tid,
+	return err
+}
+
+// parseDevicePropDesc reads the DevicePropDesc dataset returned by the GetDevicePropDesc operation.
+func parseDevicePropDesc(data []byte) (*ptp.DevicePropDesc, error) {
+	dpd := new(ptp.DevicePropDesc)
+	r := bytes.NewReader(data)
+	for _, v := range []interface{}{&dpd.DevicePropertyCode, &dpd.DataType, &dpd.GetSet} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := readDevicePropValues(r, dpd); err != nil {
+		return nil, err
 	}
 
-	if len(params) >= 1 {
-		or.Parameter1 = params[0]
+	return dpd, nil
+}
+
+// readDevicePropValues reads the part of a device property description following the GetSet field: the factory
+// default value, the current value and the form.
+func readDevicePropValues(r io.Reader, dpd *ptp.DevicePropDesc) error {
+	var err error
+	if dpd.FactoryDefaultValue, err = readDevicePropValue(r, dpd); err != nil {
+		return err
+	}
+	if dpd.CurrentValue, err = readDevicePropValue(r, dpd); err != nil {
+		return err
 	}
-	if len(params) >= 2 {
-		or.Parameter2 = params[1]
+
+	if err := binary.Read(r, binary.LittleEndian, &dpd.FormFlag); err != nil {
+		return err
 	}
-	if len(params) >= 3 {
-		or.Parameter3 = params[2]
+
+	switch dpd.FormFlag {
+	case ptp.DPF_FormFlag_Range:
+		form := new(ptp.RangeForm)
+		form.SetDevicePropDesc(dpd)
+
+		if form.MinimumValue, err = readDevicePropValue(r, dpd); err != nil {
+			return err
+		}
+		if form.MaximumValue, err = readDevicePropValue(r, dpd); err != nil {
+			return err
+		}
+		if form.StepSize, err = readDevicePropValue(r, dpd); err != nil {
+			return err
+		}
+
+		dpd.Form = form
+	case ptp.DPF_FormFlag_Enum:
+		form := new(ptp.EnumerationForm)
+		form.SetDevicePropDesc(dpd)
+
+		var num uint16
+		if err := binary.Read(r, binary.LittleEndian, &num); err != nil {
+			return err
+		}
+		form.NumberOfValues = int(num)
+
+		for i := 0; i < form.NumberOfValues; i++ {
+			v, err := readDevicePropValue(r, dpd)
+			if err != nil {
+				return err
+			}
+			form.SupportedValues = append(form.SupportedValues, v)
+		}
+
+		dpd.Form = form
 	}
-	if len(params) >= 4 {
-		or.Parameter4 = params[3]
+
+	return nil
+}
+
+// readDevicePropValue reads a single property value. Strings are returned in their raw form: a single byte holding the
+// number of UTF-16 characters, followed by the characters themselves.
+func readDevicePropValue(r io.Reader, dpd *ptp.DevicePropDesc) ([]byte, error) {
+	if dpd.DataType == ptp.DTC_STR {
+		var l uint8
+		if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		v := make([]byte, int(l)*2)
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+
+		return append([]byte{l}, v...), nil
 	}
-	if len(params) == 5 {
-		or.Parameter5 = params[4]
+
+	v := make([]byte, dpd.SizeOfValueInBytes())
+	if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+		return nil, err
 	}
+
+	return v, nil
+}
+
+func GenericOperationRequestRaw(c *Client, code ptp.OperationCode, params []uint32) ([]byte, error) {
 	resCh := make(chan []byte, 2)
-	if err := c.subscribe(tid, resCh); err != nil {
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
 		return nil, err
 	}
+	defer c.unsubscribe(tid)
 
-	err := c.SendPacketToCmdDataConn(&OperationRequestPacket{
+	or, err := newOperationRequest(code, tid, params, DP_NoDataOrDataIn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.SendPacketToCmdDataConn(&OperationRequestPacket{
 		DataPhaseInfo:    DP_NoDataOrDataIn,
 		OperationRequest: or,
 	})
@@ -275,34 +480,19 @@ func addData(retBuff []byte, buff []byte) {
 }
 
 func GenericOperationDataRequestRaw(c *Client, code ptp.OperationCode, params []uint32) ([]byte, error) {
-	tid := c.incrementTransactionId()
-
-	or := ptp.OperationRequest{
-		OperationCode: code,
-		TransactionID: tid,
+	resCh := make(chan []byte, 2)
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
+		return nil, err
 	}
+	defer c.unsubscribe(tid)
 
-	if len(params) >= 1 {
-		or.Parameter1 = params[0]
-	}
-	if len(params) >= 2 {
-		or.Parameter2 = params[1]
-	}
-	if len(params) >= 3 {
-		or.Parameter3 = params[2]
-	}
-	if len(params) >= 4 {
-		or.Parameter4 = params[3]
-	}
-	if len(params) == 5 {
-		or.Parameter5 = params[4]
-	}
-	resCh := make(chan []byte, 2)
-	if err := c.subscribe(tid, resCh); err != nil {
+	or, err := newOperationRequest(code, tid, params, DP_NoDataOrDataIn)
+	if err != nil {
 		return nil, err
 	}
 
-	err := c.SendPacketToCmdDataConn(&OperationRequestPacket{
+	err = c.SendPacketToCmdDataConn(&OperationRequestPacket{
 		DataPhaseInfo:    DP_NoDataOrDataIn,
 		OperationRequest: or,
 	})
@@ -331,35 +521,38 @@ func GenericOperationDataRequestRaw(c *Client, code ptp.OperationCode, params []
 	return data2, err
 }
 
-func GenericSendData(c *Client, code ptp.OperationCode, params []uint32, dataSend []byte, dataLen uint64) ([]byte, error) {
-	tid := c.incrementTransactionId()
+// GenericSetLiveviewZoom is used for vendors that have no known way of magnifying the liveview on the camera.
+func GenericSetLiveviewZoom(_ *Client, _ int) (int, error) {
+	return 0, NoNativeZoomError
+}
 
-	or := ptp.OperationRequest{
-		OperationCode: code,
-		TransactionID: tid,
+// GenericToggleLiveView opens or closes the streamer connection, which is where most vendors deliver the liveview
+// frames.
+func GenericToggleLiveView(c *Client, en bool) error {
+	if en {
+		return c.initStreamConn()
 	}
 
-	if len(params) >= 1 {
-		or.Parameter1 = params[0]
-	}
-	if len(params) >= 2 {
-		or.Parameter2 = params[1]
-	}
-	if len(params) >= 3 {
-		or.Parameter3 = params[2]
-	}
-	if len(params) >= 4 {
-		or.Parameter4 = params[3]
-	}
-	if len(params) == 5 {
-		or.Parameter5 = params[4]
-	}
+	return c.closeStreamConn()
+}
+
+// GenericSendData sends an operation request followed by a data-out phase holding dataSend. The data is split into
+// DataPackets of the size set using Client.SetDataChunkSize(), the last chunk being sent in the EndDataPacket. When ctx
+// is done before all chunks have been sent, the transaction is cancelled by sending a CancelPacket to the Responder.
+func GenericSendData(ctx context.Context, c *Client, code ptp.OperationCode, params []uint32, dataSend []byte, dataLen uint64) ([]byte, error) {
 	resCh := make(chan []byte, 2)
-	if err := c.subscribe(tid, resCh); err != nil {
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
+		return nil, err
+	}
+	defer c.unsubscribe(tid)
+
+	or, err := newOperationRequest(code, tid, params, DP_DataOut)
+	if err != nil {
 		return nil, err
 	}
 
-	err := c.SendPacketToCmdDataConn(&OperationRequestPacket{
+	err = c.SendPacketToCmdDataConn(&OperationRequestPacket{
 		DataPhaseInfo:    DP_DataOut,
 		OperationRequest: or,
 	})
@@ -377,6 +570,31 @@ func GenericSendData(c *Client, code ptp.OperationCode, params []uint32, dataSen
 		return nil, err
 	}
 
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, c.cancelDataOut(tid, resCh, ctx.Err())
+		case data, ok := <-resCh:
+			if !ok {
+				return nil, c.subscriptionError(resCh)
+			}
+			// The Responder ended the transaction before receiving all data, e.g. by cancelling it.
+			return dataOutResponse(data)
+		default:
+		}
+
+		if c.dataChunkSize <= 0 || len(dataSend) <= c.dataChunkSize {
+			break
+		}
+		if err := c.SendPacketToCmdDataConn(&DataPacket{
+			TransactionId: tid,
+			DataPayload:   dataSend[:c.dataChunkSize],
+		}); err != nil {
+			return nil, err
+		}
+		dataSend = dataSend[c.dataChunkSize:]
+	}
+
 	err = c.SendPacketToCmdDataConn(&EndDataPacket{
 		TransactionId: tid,
 		DataPayload:   dataSend,
@@ -390,9 +608,271 @@ func GenericSendData(c *Client, code ptp.OperationCode, params []uint32, dataSen
 		return nil, err
 	}
 
-	return data, err
+	return dataOutResponse(data)
+}
+
+// dataOutResponse returns the raw response ending a data-out phase, or TransactionCancelled when the Responder
+// cancelled the transaction.
+func dataOutResponse(data []byte) ([]byte, error) {
+	if len(data) >= HeaderSize && PacketType(binary.LittleEndian.Uint32(data[4:8])) == PKT_Cancel {
+		return nil, TransactionCancelled
+	}
+
+	return data, nil
+}
+
+// cancelDataOut cancels the transaction of which the data-out phase is in progress and returns an error wrapping err.
+// The Responder ends the transaction with an operation response, which is awaited so that it does not end up with the
+// next transaction.
+func (c *Client) cancelDataOut(tid ptp.TransactionID, resCh <-chan []byte, err error) error {
+	c.Infof("Cancelling the data-out phase of transaction with ID '%d': %s", tid, err)
+	if err := c.SendPacketToCmdDataConn(&CancelPacket{TransactionId: tid}); err != nil {
+		return err
+	}
+	if _, err := c.WaitForRawPacketFromCommandDataSubscriber(resCh); err != nil {
+		c.Warnf("No response to the cancelled transaction with ID '%d': %s", tid, err)
+	}
+
+	return fmt.Errorf("data-out phase cancelled: %w", err)
+}
+
+// GenericOperationRequestAndGetData sends an operation request that expects a data-in phase and collects the payload of
+// all data packets belonging to the transaction until the operation response arrives. An error is returned when the
+// Responder does not answer with ptp.RC_OK.
+func GenericOperationRequestAndGetData(c *Client, code ptp.OperationCode, params []uint32) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := GenericOperationRequestAndStreamData(c, code, params, &buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GenericOperationRequestAndStreamData sends an operation request that expects a data-in phase and writes the payload
+// of all data packets belonging to the transaction to w as they arrive, until the operation response arrives. The
+// number of bytes written is returned. An error is returned when the Responder does not answer with ptp.RC_OK, in which
+// case part of the data might have been written already.
+func GenericOperationRequestAndStreamData(c *Client, code ptp.OperationCode, params []uint32, w io.Writer) (int64, error) {
+	resCh := make(chan []byte, 10)
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
+		return 0, err
+	}
+	defer c.unsubscribe(tid)
+
+	or, err := newOperationRequest(code, tid, params, DP_NoDataOrDataIn)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.SendPacketToCmdDataConn(&OperationRequestPacket{
+		DataPhaseInfo:    DP_NoDataOrDataIn,
+		OperationRequest: or,
+	}); err != nil {
+		return 0, err
+	}
+
+	return streamData(func() ([]byte, error) {
+		return c.WaitForRawPacketFromCommandDataSubscriber(resCh)
+	}, w)
+}
+
+// GenericOperationRequestAndGetParameters sends an operation request without a data phase and returns the parameters
+// of the operation response, such as the number of objects returned by ptp.OC_GetNumObjects. An error is returned when
+// the Responder does not answer with ptp.RC_OK.
+func GenericOperationRequestAndGetParameters(c *Client, code ptp.OperationCode, params []uint32) ([]uint32, error) {
+	resCh := make(chan []byte, 10)
+	tid, err := c.beginTransaction(resCh)
+	if err != nil {
+		return nil, err
+	}
+	defer c.unsubscribe(tid)
+
+	or, err := newOperationRequest(code, tid, params, DP_NoDataOrDataIn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.SendPacketToCmdDataConn(&OperationRequestPacket{
+		DataPhaseInfo:    DP_NoDataOrDataIn,
+		OperationRequest: or,
+	}); err != nil {
+		return nil, err
+	}
+
+	return responseParameters(func() ([]byte, error) {
+		return c.WaitForRawPacketFromCommandDataSubscriber(resCh)
+	})
 }
 
+// sendDataAndGetParameters works like Client.SendData but returns the parameters of the operation response, returning
+// an error when the Responder does not answer with ptp.RC_OK.
+func (c *Client) sendDataAndGetParameters(code ptp.OperationCode, params []uint32, data []byte) ([]uint32, error) {
+	raw, err := c.SendData(code, params, data, uint64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return responseParameters(func() ([]byte, error) {
+		return raw, nil
+	})
+}
+
+// responseParameters returns the parameters of the operation response returned by next, skipping any data packets
+// preceding it. An error is returned when the Responder does not answer with ptp.RC_OK.
+func responseParameters(next func() ([]byte, error)) ([]uint32, error) {
+	for {
+		raw, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) < HeaderSize+4 {
+			return nil, InvalidPacketError
+		}
+
+		switch pt := PacketType(binary.LittleEndian.Uint32(raw[4:8])); pt {
+		case PKT_StartData, PKT_Data, PKT_EndData:
+			continue
+		case PKT_Cancel:
+			return nil, TransactionCancelled
+		case PKT_OperationResponse:
+			// The response code and the transaction ID precede the parameters.
+			if len(raw) < HeaderSize+6 {
+				return nil, InvalidPacketError
+			}
+			if rc := ptp.OperationResponseCode(binary.LittleEndian.Uint16(raw[8:10])); rc != ptp.RC_OK {
+				return nil, ptp.ResponseCodeAsError(rc)
+			}
+			var params []uint32
+			for p := raw[HeaderSize+6:]; len(p) >= 4; p = p[4:] {
+				params = append(params, binary.LittleEndian.Uint32(p))
+			}
+			return params, nil
+		default:
+			return nil, fmt.Errorf("unexpected packet type %#x", pt)
+		}
+	}
+}
+
+// collectData collects the payload of all data packets returned by next until the operation response arrives. An error
+// is returned when the Responder does not answer with ptp.RC_OK.
+func collectData(next func() ([]byte, error)) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := streamData(next, &buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// maxDataPrealloc is the largest total data length announced by the Responder for which room is made upfront.
+const maxDataPrealloc = 64 << 20
+
+// streamData writes the payload of all data packets returned by next to w until the operation response arrives and
+// returns the number of bytes written. The total data length announced by the Responder is used to make room for the
+// data upfront when w is a *bytes.Buffer: the data phase always lasts until the EndDataPacket, so that data of
+// UnknownDataLength is handled just the same. An error is returned when the Responder does not answer with ptp.RC_OK,
+// and an error wrapping TruncatedDataError when less data arrived than was announced.
+func streamData(next func() ([]byte, error), w io.Writer) (int64, error) {
+	var n int64
+	announced := UnknownDataLength
+	for {
+		raw, err := next()
+		if err != nil {
+			return n, err
+		}
+		if len(raw) < HeaderSize+4 {
+			return n, InvalidPacketError
+		}
+
+		switch pt := PacketType(binary.LittleEndian.Uint32(raw[4:8])); pt {
+		case PKT_StartData:
+			if len(raw) >= HeaderSize+12 {
+				announced = binary.LittleEndian.Uint64(raw[12:20])
+			}
+			if buf, ok := w.(*bytes.Buffer); ok && announced != UnknownDataLength && announced <= maxDataPrealloc {
+				buf.Grow(int(announced))
+			}
+		case PKT_Data, PKT_EndData:
+			m, err := w.Write(raw[12:])
+			n += int64(m)
+			if err != nil {
+				return n, err
+			}
+		case PKT_Cancel:
+			return n, TransactionCancelled
+		case PKT_OperationResponse:
+			if rc := ptp.OperationResponseCode(binary.LittleEndian.Uint16(raw[8:10])); rc != ptp.RC_OK {
+				return n, ptp.ResponseCodeAsError(rc)
+			}
+			if announced != UnknownDataLength && uint64(n) < announced {
+				return n, fmt.Errorf("%w: received %d of %d bytes", TruncatedDataError, n, announced)
+			}
+			return n, nil
+		default:
+			return n, fmt.Errorf("unexpected packet type %#x", pt)
+		}
+	}
+}
+
+// newOperationRequest builds an operation request for the given transaction using the given data phase. For standard
+// operations, the number of parameters and the data phase are validated against ptp.OperationCodeInfo().
+// TooManyParametersError is returned when passing too many parameters and DataPhaseMismatchError when the data phase
+// does not match the operation.
+func newOperationRequest(code ptp.OperationCode, tid ptp.TransactionID, params []uint32, dp DataPhase) (ptp.OperationRequest, error) {
+	if len(params) > ptp.MaxOperationParameters {
+		return ptp.OperationRequest{}, fmt.Errorf("%w: an operation takes at most %d, got %d", TooManyParametersError, ptp.MaxOperationParameters, len(params))
+	}
+
+	if oi, ok := ptp.OperationCodeInfo(code); ok {
+		if len(params) > oi.Parameters {
+			return ptp.OperationRequest{}, fmt.Errorf("%w: %s takes at most %d, got %d", TooManyParametersError, oi.Name, oi.Parameters, len(params))
+		}
+		if (oi.Data == ptp.DD_Out) != (dp == DP_DataOut) {
+			return ptp.OperationRequest{}, fmt.Errorf("%w: %s has data direction %s", DataPhaseMismatchError, oi.Name, oi.Data)
+		}
+	}
+
+	return ptp.OperationRequest{
+		OperationCode: code,
+		TransactionID: tid,
+		Parameters:    append([]uint32(nil), params...),
+	}, nil
+}
+
+// GenericInitiateCapture releases the shutter using the default store and object format of the Responder, as described
+// in the PTP standard, and returns the data of the captured object. The Responder announces the new object using the
+// ptp.EC_ObjectAdded event, which is why the handler is registered before the operation request is sent.
 func GenericInitiateCapture(c *Client) ([]byte, error) {
-	return nil, errors.New("command not YET supported")
+	added := make(chan ptp.ObjectHandle, 1)
+	removeHandler := c.OnEvent(func(p EventPacket) {
+		if p.GetEventCode() != ptp.EC_ObjectAdded {
+			return
+		}
+		select {
+		case added <- ptp.ObjectHandle(p.GetParameter1()):
+		default:
+		}
+	})
+	defer removeHandler()
+
+	c.Infof("Releasing %s shutter...", c.ResponderFriendlyName())
+	if _, err := GenericOperationRequestAndGetParameters(c, ptp.OC_InitiateCapture, []uint32{0, 0}); err != nil {
+		return nil, err
+	}
+
+	var h ptp.ObjectHandle
+	select {
+	case h = <-added:
+		c.Debugf("Received object added event for object %#x.", uint32(h))
+	case <-time.After(DefaultReadTimeout):
+		return nil, &CaptureError{Released: true, Err: &TimeoutError{Connection: string(eventConnection), Waited: DefaultReadTimeout, Err: WaitForEventError}}
+	}
+
+	img, err := c.GetObject(h)
+	if err != nil {
+		return nil, &CaptureError{Released: true, Err: err}
+	}
+
+	return img, nil
 }
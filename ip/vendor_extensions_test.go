@@ -0,0 +1,136 @@
+package ip
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// rawPackets returns a function returning the given packets one by one as they would be received by a subscriber.
+func rawPackets(pkts ...Packet) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(pkts) == 0 {
+			return nil, WaitForResponseError
+		}
+		var b bytes.Buffer
+		sendAnyPacket(&b, pkts[0], nil, "[vendor_extensions_test]")
+		pkts = pkts[1:]
+
+		return b.Bytes(), nil
+	}
+}
+
+func TestStreamData(t *testing.T) {
+	res := func(rc ptp.OperationResponseCode) *OperationResponsePacket {
+		return &OperationResponsePacket{OperationResponse: ptp.OperationResponse{ResponseCode: rc, TransactionID: 1}}
+	}
+
+	check := []struct {
+		pkts    []Packet
+		want    []byte
+		wantErr error
+	}{
+		{
+			[]Packet{&StartDataPacket{1, 3}, &DataPacket{1, []byte{1, 2}}, &EndDataPacket{1, []byte{3}}, res(ptp.RC_OK)},
+			[]byte{1, 2, 3},
+			nil,
+		},
+		{
+			[]Packet{&StartDataPacket{1, UnknownDataLength}, &DataPacket{1, []byte{1}}, &DataPacket{1, []byte{2, 3}}, &EndDataPacket{1, []byte{4}}, res(ptp.RC_OK)},
+			[]byte{1, 2, 3, 4},
+			nil,
+		},
+		{
+			// The Responder announced less data than it sent.
+			[]Packet{&StartDataPacket{1, 1}, &EndDataPacket{1, []byte{1, 2}}, res(ptp.RC_OK)},
+			[]byte{1, 2},
+			nil,
+		},
+		{
+			[]Packet{&StartDataPacket{1, UnknownDataLength}, &DataPacket{1, []byte{1}}, &CancelPacket{1}},
+			[]byte{1},
+			TransactionCancelled,
+		},
+		{
+			[]Packet{&StartDataPacket{1, UnknownDataLength}, &DataPacket{1, []byte{1}}},
+			[]byte{1},
+			WaitForResponseError,
+		},
+	}
+
+	for _, tt := range check {
+		var buf bytes.Buffer
+		n, err := streamData(rawPackets(tt.pkts...), &buf)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("streamData() err = %v; want %v", err, tt.wantErr)
+		}
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("streamData() got = %v; want %v", buf.Bytes(), tt.want)
+		}
+		if n != int64(len(tt.want)) {
+			t.Errorf("streamData() n = %d; want %d", n, len(tt.want))
+		}
+	}
+}
+
+func TestResponseParameters(t *testing.T) {
+	res := func(rc ptp.OperationResponseCode, params ...uint32) *OperationResponsePacket {
+		return &OperationResponsePacket{OperationResponse: ptp.OperationResponse{ResponseCode: rc, TransactionID: 1, Parameters: params}}
+	}
+
+	got, err := responseParameters(rawPackets(res(ptp.RC_OK, 42, 7)))
+	if err != nil || len(got) != 2 || got[0] != 42 || got[1] != 7 {
+		t.Errorf("responseParameters() got = %v, %v; want [42 7], <nil>", got, err)
+	}
+
+	got, err = responseParameters(rawPackets(&StartDataPacket{1, 1}, &EndDataPacket{1, []byte{1}}, res(ptp.RC_OK)))
+	if err != nil || len(got) != 0 {
+		t.Errorf("responseParameters() got = %v, %v; want [], <nil>", got, err)
+	}
+
+	var ore *ptp.OperationResponseError
+	if _, err := responseParameters(rawPackets(res(ptp.RC_OperationNotSupported))); !errors.As(err, &ore) || ore.Code != ptp.RC_OperationNotSupported {
+		t.Errorf("responseParameters() err = %v; want %s", err, ptp.ResponseCodeAsError(ptp.RC_OperationNotSupported))
+	}
+}
+
+func TestCollectData(t *testing.T) {
+	res := &OperationResponsePacket{OperationResponse: ptp.OperationResponse{ResponseCode: ptp.RC_AccessDenied, TransactionID: 1}}
+	got, err := collectData(rawPackets(&StartDataPacket{1, UnknownDataLength}, &EndDataPacket{1, []byte{1}}, res))
+	if err == nil || got != nil {
+		t.Errorf("collectData() got = %v, %v; want <nil>, %s", got, err, ptp.ResponseCodeAsError(ptp.RC_AccessDenied))
+	}
+}
+
+func TestGenericDeviceProperty(t *testing.T) {
+	vc := &virtualCamera{iso: 200}
+	c := dialVirtualCamera(t, vc)
+
+	dpd, err := GenericGetDevicePropertyDesc(c, ptp.DPC_ExposureIndex)
+	if err != nil {
+		t.Fatalf("GenericGetDevicePropertyDesc() error = %s; want <nil>", err)
+	}
+	if dpd.DevicePropertyCode != ptp.DPC_ExposureIndex || dpd.DataType != ptp.DTC_UINT16 || dpd.GetSet != ptp.DPD_GetSet || dpd.CurrentValueAsInt64() != 200 {
+		t.Errorf("GenericGetDevicePropertyDesc() got = %#v; want writable uint16 0x500f with value 200", dpd)
+	}
+	if form, ok := dpd.Form.(*ptp.EnumerationForm); !ok || form.NumberOfValues != 3 || !bytes.Equal(form.SupportedValues[2], []byte{0x90, 0x01}) {
+		t.Errorf("GenericGetDevicePropertyDesc() form = %#v; want 100, 200 and 400", dpd.Form)
+	}
+
+	if err := GenericSetDeviceProperty(c, ptp.DPC_ExposureIndex, 400); err != nil {
+		t.Fatalf("GenericSetDeviceProperty() error = %s; want <nil>", err)
+	}
+	if got, err := GenericGetDevicePropertyValue(c, ptp.DPC_ExposureIndex); err != nil || got != 400 {
+		t.Errorf("GenericGetDevicePropertyValue() got = %d, %v; want 400, <nil>", got, err)
+	}
+
+	var ore *ptp.OperationResponseError
+	if err := GenericSetDeviceProperty(c, ptp.DPC_ExposureIndex, 800); !errors.As(err, &ore) || ore.Code != ptp.RC_InvalidDevicePropValue {
+		t.Errorf("GenericSetDeviceProperty() error = %v; want %s", err, ptp.ResponseCodeAsError(ptp.RC_InvalidDevicePropValue))
+	}
+	if _, err := GenericGetDevicePropertyValue(c, ptp.DPC_FNumber); !errors.As(err, &ore) || ore.Code != ptp.RC_DevicePropNotSupported {
+		t.Errorf("GenericGetDevicePropertyValue() error = %v; want %s", err, ptp.ResponseCodeAsError(ptp.RC_DevicePropNotSupported))
+	}
+}
@@ -0,0 +1,242 @@
+package ip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// TimeoutError is returned when the Responder did not send the packet or event waited for in time. A connection that
+// is closed or broken results in a different error, which tells a slow Responder apart from a dead connection so that
+// the caller can decide whether to wait some more, probe the Responder or reconnect.
+// A TimeoutError wraps WaitForResponseError or WaitForEventError so that errors.Is keeps working for those.
+type TimeoutError struct {
+	// Connection is the connection that was waited on: "cmd" for the command/data connection or "event" for the event
+	// connection.
+	Connection string
+	// Waited is the time spent waiting.
+	Waited time.Duration
+	Err    error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s connection: %s after %s", e.Connection, e.Err, e.Waited.Round(time.Millisecond))
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout always returns true and, together with Temporary, makes TimeoutError satisfy the net.Error interface.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary always returns true: the packet might still arrive.
+func (e *TimeoutError) Temporary() bool {
+	return true
+}
+
+// IsTimeout returns true when err is a TimeoutError or any other error reporting a timeout, such as a read deadline
+// passing on a connection.
+func IsTimeout(err error) bool {
+	var ne interface{ Timeout() bool }
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// waitError returns the error to return when ctx is done while waiting since start: a TimeoutError wrapping
+// timeoutErr when the deadline of ctx passed or the error of ctx when it was cancelled.
+func waitError(ctx context.Context, ct connectionType, timeoutErr error, start time.Time) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{
+			Connection: string(ct),
+			Waited:     time.Since(start),
+			Err:        timeoutErr,
+		}
+	}
+
+	return ctx.Err()
+}
+
+// readDeadline returns the read deadline to set on a connection for a single read while waiting for ctx: the deadline
+// of ctx or DefaultReadTimeout from now, whichever comes first.
+func readDeadline(ctx context.Context) time.Time {
+	d := time.Now().Add(DefaultReadTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(d) {
+		return dl
+	}
+
+	return d
+}
+
+// waitForPacket calls read until it returns a packet or an error, passing it the read deadline to set on conn. Reads
+// timing out before ctx is done are retried. When ctx is done, a pending read is interrupted and the error returned by
+// waitError is returned. The Responder closing the connection results in ConnectionLostError rather than a timeout.
+func waitForPacket(ctx context.Context, ct connectionType, timeoutErr error, conn net.Conn, read func(time.Time) (PacketIn, []byte, error)) (PacketIn, []byte, error) {
+	start := time.Now()
+
+	if conn != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				conn.SetReadDeadline(time.Now())
+			case <-done:
+			}
+		}()
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return nil, nil, waitError(ctx, ct, timeoutErr, start)
+		}
+
+		res, xs, err := read(readDeadline(ctx))
+		switch {
+		case err == io.EOF && res == nil:
+			return nil, nil, ConnectionLostError
+		case err != nil && ctx.Err() != nil:
+			return nil, nil, waitError(ctx, ct, timeoutErr, start)
+		case IsTimeout(err):
+			continue
+		case err != nil:
+			return nil, nil, err
+		}
+
+		return res, xs, nil
+	}
+}
+
+// waitForPacketFromCmdDataConnContext waits for a packet on the command/data connection until ctx is done.
+// This function will return a packet satisfying PacketIn together with any excess data that was not unmarshalled as a
+// byte array. The excess data will be empty if there was none.
+func (c *Client) waitForPacketFromCmdDataConnContext(ctx context.Context, p PacketIn) (PacketIn, []byte, error) {
+	return waitForPacket(ctx, cmdDataConnection, WaitForResponseError, c.CommandDataConn, func(deadline time.Time) (PacketIn, []byte, error) {
+		return c.readPacketFromCmdDataConn(deadline, p)
+	})
+}
+
+// waitForPacketFromEventConnContext waits for a packet on the Event connection until ctx is done.
+// This function will return a packet satisfying EventPacket together with any excess data that was not unmarshalled as
+// a byte array. The excess data will be empty if there was none. A ProbeRequestPacket is returned instead when the
+// Responder probes us.
+func (c *Client) waitForPacketFromEventConnContext(ctx context.Context, p EventPacket) (PacketIn, []byte, error) {
+	return waitForPacket(ctx, eventConnection, WaitForEventError, c.eventConn, func(deadline time.Time) (PacketIn, []byte, error) {
+		return c.readPacketFromEventConn(deadline, p)
+	})
+}
+
+// WaitForRawPacketFromCommandDataSubscriberContext waits for a packet to be sent to a command/data channel subscriber
+// registered using the subscribe method until ctx is done. A TimeoutError is returned when the deadline of ctx passes
+// first, a *TransactionTimeoutError when the transaction was reaped and ConnectionLostError when the subscription ended
+// because the connection dropped.
+func (c *Client) WaitForRawPacketFromCommandDataSubscriberContext(ctx context.Context, ch <-chan []byte) ([]byte, error) {
+	start := time.Now()
+
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			return nil, c.subscriptionError(ch)
+		}
+		return res, nil
+	case <-ctx.Done():
+		return nil, waitError(ctx, cmdDataConnection, WaitForResponseError, start)
+	}
+}
+
+// WaitForPacketFromCommandDataSubscriberContext waits for a packet to be sent to a command/data channel subscriber
+// registered using the subscribe method until ctx is done. A TimeoutError is returned when the deadline of ctx passes
+// first.
+// This function will return a packet satisfying PacketIn together with any excess data that was not unmarshalled as a
+// byte array. The excess data will be empty if there was none.
+func (c *Client) WaitForPacketFromCommandDataSubscriberContext(ctx context.Context, ch <-chan []byte, p PacketIn) (PacketIn, []byte, error) {
+	res, err := c.WaitForRawPacketFromCommandDataSubscriberContext(ctx, ch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.readResponse(bytes.NewReader(res), p)
+}
+
+// WaitForEvent waits until ctx is done for the Responder to send an event with one of the given codes, or any event
+// when no codes are given. A TimeoutError is returned when the deadline of ctx passes first. Only events arriving after
+// calling WaitForEvent are considered and, unlike the EventChan, waiting does not consume the event.
+func (c *Client) WaitForEvent(ctx context.Context, codes ...ptp.EventCode) (EventPacket, error) {
+	start := time.Now()
+
+	ch := make(chan EventPacket, 1)
+	removeHandler := c.OnEvent(func(p EventPacket) {
+		if len(codes) > 0 && !containsEventCode(codes, p.GetEventCode()) {
+			return
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	})
+	defer removeHandler()
+
+	select {
+	case p := <-ch:
+		return p, nil
+	case <-ctx.Done():
+		return nil, waitError(ctx, eventConnection, WaitForEventError, start)
+	}
+}
+
+// Probe sends a probe request to the Responder on the event connection and waits until ctx is done for the probe
+// response, returning the round trip time. The Responder must answer immediately, so a TimeoutError means that the
+// Responder is no longer active. Only Responders using the standard PTP/IP event packets can be probed.
+func (c *Client) Probe(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+
+	// Drop a late response to an earlier probe request.
+	select {
+	case <-c.probeResponses:
+	default:
+	}
+	if err := c.SendPacketToEventConn(&ProbeRequestPacket{}); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-c.probeResponses:
+		c.lastProbe.Store(time.Now().UnixNano())
+		return time.Since(start), nil
+	case <-ctx.Done():
+		return 0, waitError(ctx, eventConnection, WaitForResponseError, start)
+	}
+}
+
+// LastProbe returns the time the Responder last answered a probe request sent using Probe(), or the zero time when it
+// never did.
+func (c *Client) LastProbe() time.Time {
+	return unixNanoTime(c.lastProbe.Load())
+}
+
+// unixNanoTime converts a time stored as nanoseconds since the Unix epoch back to a time, zero meaning the zero time.
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ns)
+}
+
+// containsEventCode returns true when code is one of codes.
+func containsEventCode(codes []ptp.EventCode, code ptp.EventCode) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
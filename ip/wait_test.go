@@ -0,0 +1,154 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestTimeoutError(t *testing.T) {
+	var err error = &TimeoutError{Connection: string(cmdDataConnection), Waited: time.Second, Err: WaitForResponseError}
+
+	if !errors.Is(err, WaitForResponseError) {
+		t.Errorf("errors.Is() got = false; want true")
+	}
+	if _, ok := err.(net.Error); !ok {
+		t.Errorf("TimeoutError is not a net.Error")
+	}
+	if got, want := err.Error(), "cmd connection: timeout reached when waiting for response after 1s"; got != want {
+		t.Errorf("Error() got = %s; want %s", got, want)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	check := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{ConnectionLostError, false},
+		{&TimeoutError{Err: WaitForEventError}, true},
+		{&CaptureError{Err: &TimeoutError{Err: WaitForEventError}}, true},
+		{&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, true},
+	}
+
+	for _, tt := range check {
+		if got := IsTimeout(tt.err); got != tt.want {
+			t.Errorf("IsTimeout(%v) got = %t; want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClient_WaitForRawPacketFromCommandDataSubscriberContext(t *testing.T) {
+	c := &Client{}
+	ch := make(chan []byte, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.WaitForRawPacketFromCommandDataSubscriberContext(ctx, ch)
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.Connection != "cmd" {
+		t.Errorf("WaitForRawPacketFromCommandDataSubscriberContext() error = %v; want *TimeoutError", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.WaitForRawPacketFromCommandDataSubscriberContext(ctx, ch); err != context.Canceled {
+		t.Errorf("WaitForRawPacketFromCommandDataSubscriberContext() error = %v; want %s", err, context.Canceled)
+	}
+
+	ch <- []byte{0x01}
+	if got, err := c.WaitForRawPacketFromCommandDataSubscriberContext(context.Background(), ch); err != nil || len(got) != 1 {
+		t.Errorf("WaitForRawPacketFromCommandDataSubscriberContext() got = %v, %v; want [1], <nil>", got, err)
+	}
+}
+
+func TestClient_waitForPacketFromEventConnContext(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, remote := net.Pipe()
+	defer remote.Close()
+	c.eventConn, c.eventReader = local, local
+
+	// A cancelled context must interrupt the pending read rather than wait for the read deadline.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if _, _, err := c.waitForPacketFromEventConnContext(ctx, nil); err != context.Canceled {
+		t.Errorf("waitForPacketFromEventConnContext() error = %v; want %s", err, context.Canceled)
+	}
+	if got := time.Since(start); got > time.Second {
+		t.Errorf("waitForPacketFromEventConnContext() took %s; want it to return when cancelled", got)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err = c.waitForPacketFromEventConnContext(ctx, nil)
+	if !IsTimeout(err) || !errors.Is(err, WaitForEventError) {
+		t.Errorf("waitForPacketFromEventConnContext() error = %v; want %s", err, WaitForEventError)
+	}
+
+	remote.Close()
+	if _, _, err := c.waitForPacketFromEventConnContext(context.Background(), nil); err != ConnectionLostError {
+		t.Errorf("waitForPacketFromEventConnContext() error = %v; want %s", err, ConnectionLostError)
+	}
+}
+
+func TestClient_WaitForEvent(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.notifyEventHandlers(&GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_DevicePropChanged}})
+		c.notifyEventHandlers(&GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_CaptureComplete}})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p, err := c.WaitForEvent(ctx, ptp.EC_CaptureComplete)
+	if err != nil || p.GetEventCode() != ptp.EC_CaptureComplete {
+		t.Errorf("WaitForEvent() got = %v, %v; want %#x, <nil>", p, err, ptp.EC_CaptureComplete)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitForEvent(ctx); !errors.Is(err, WaitForEventError) {
+		t.Errorf("WaitForEvent() error = %v; want %s", err, WaitForEventError)
+	}
+}
+
+func TestClient_Probe(t *testing.T) {
+	c, err := NewClient(DefaultVendor, address, startFaultyVirtualCamera(t, responderFaults{}), "testér", "", logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !c.LastProbe().IsZero() {
+		t.Errorf("LastProbe() got = %s; want zero time before probing", c.LastProbe())
+	}
+	if _, err := c.Probe(ctx); err != nil {
+		t.Errorf("Probe() error = %s; want <nil>", err)
+	}
+	if c.LastProbe().IsZero() || c.LastEventPacket().IsZero() {
+		t.Errorf("LastProbe() got = %s, LastEventPacket() got = %s; want both set after probing", c.LastProbe(), c.LastEventPacket())
+	}
+	if !c.EventListenerRunning() {
+		t.Error("EventListenerRunning() got = false; want true")
+	}
+}
@@ -0,0 +1,132 @@
+package ip
+
+import (
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+// DefaultWatchInterval is the interval used by WatchProperties when no interval is given.
+const DefaultWatchInterval = time.Second
+
+// PropertyChange is passed to the function given to WatchProperties for every change of a watched device property.
+type PropertyChange struct {
+	Code ptp.DevicePropCode
+	// Old is the previous value of the property and is only valid when Initial is false.
+	Old uint32
+	New uint32
+	// Initial is true when the value is the first value read for the property.
+	Initial bool
+	// Err is set when the value could not be read. It is reported only once until the value can be read again.
+	Err  error
+	Time time.Time
+}
+
+// WatchProperties reads the given device properties every interval and calls f with the initial value of each property
+// and with every change after that. A ptp.EC_DevicePropChanged event for a watched property makes the property being
+// read right away instead of waiting for the next interval. Calling the function returned stops watching; f is never
+// called after it returns.
+func (c *Client) WatchProperties(codes []ptp.DevicePropCode, interval time.Duration, f func(PropertyChange)) func() {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	changed := make(chan ptp.DevicePropCode, len(codes))
+	removeHandler := c.OnEvent(func(p EventPacket) {
+		if p.GetEventCode() != ptp.EC_DevicePropChanged {
+			return
+		}
+		select {
+		case changed <- ptp.DevicePropCode(p.GetParameter1()):
+		default:
+			// The property will be read at the next interval anyway.
+		}
+	})
+
+	w := &propertyWatch{
+		c:      c,
+		f:      f,
+		values: make(map[ptp.DevicePropCode]uint32),
+		failed: make(map[ptp.DevicePropCode]bool),
+	}
+	watched := make(map[ptp.DevicePropCode]bool, len(codes))
+	for _, code := range codes {
+		watched[code] = true
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		readAll := func() bool {
+			for _, code := range codes {
+				select {
+				case <-stop:
+					return false
+				default:
+				}
+				w.read(code)
+			}
+			return true
+		}
+
+		if !readAll() {
+			return
+		}
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				if !readAll() {
+					return
+				}
+			case code := <-changed:
+				if watched[code] {
+					w.read(code)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		removeHandler()
+		select {
+		case <-stop:
+		default:
+			close(stop)
+		}
+		<-done
+	}
+}
+
+// propertyWatch holds the last value read for each property watched by WatchProperties.
+type propertyWatch struct {
+	c      *Client
+	f      func(PropertyChange)
+	values map[ptp.DevicePropCode]uint32
+	failed map[ptp.DevicePropCode]bool
+}
+
+func (w *propertyWatch) read(code ptp.DevicePropCode) {
+	v, err := w.c.GetDevicePropertyValue(code)
+	if err != nil {
+		if !w.failed[code] {
+			w.failed[code] = true
+			w.f(PropertyChange{Code: code, Err: err, Time: time.Now()})
+		}
+		return
+	}
+	w.failed[code] = false
+
+	old, seen := w.values[code]
+	if seen && old == v {
+		return
+	}
+	w.values[code] = v
+	w.f(PropertyChange{Code: code, Old: old, New: v, Initial: !seen, Time: time.Now()})
+}
@@ -0,0 +1,72 @@
+package ip
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/malc0mn/ptp-ip/ptp"
+)
+
+func TestClient_WatchProperties(t *testing.T) {
+	c, err := NewClient(DefaultVendor, DefaultIpAddress, DefaultPort, "", "", LevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu     sync.Mutex
+		values = map[ptp.DevicePropCode]uint32{ptp.DPC_WhiteBalance: 2}
+	)
+	c.vendorExtensions.getDevicePropertyValue = func(_ *Client, code ptp.DevicePropCode) (uint32, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		v, ok := values[code]
+		if !ok {
+			return 0, errors.New("unknown property")
+		}
+		return v, nil
+	}
+
+	changes := make(chan PropertyChange, 10)
+	stop := c.WatchProperties([]ptp.DevicePropCode{ptp.DPC_WhiteBalance, ptp.DPC_FNumber}, time.Hour, func(pc PropertyChange) {
+		changes <- pc
+	})
+
+	next := func() PropertyChange {
+		select {
+		case pc := <-changes:
+			return pc
+		case <-time.After(time.Second):
+			t.Fatal("WatchProperties() no change received")
+		}
+		return PropertyChange{}
+	}
+
+	if pc := next(); pc.Code != ptp.DPC_WhiteBalance || !pc.Initial || pc.New != 2 {
+		t.Errorf("WatchProperties() got = %+v; want initial value 2 for %#x", pc, ptp.DPC_WhiteBalance)
+	}
+	if pc := next(); pc.Code != ptp.DPC_FNumber || pc.Err == nil {
+		t.Errorf("WatchProperties() got = %+v; want error for %#x", pc, ptp.DPC_FNumber)
+	}
+
+	mu.Lock()
+	values[ptp.DPC_WhiteBalance] = 4
+	mu.Unlock()
+	// The interval is an hour, so only the event can trigger reading the property again.
+	c.notifyEventHandlers(&GenericEventPacket{Event: ptp.Event{EventCode: ptp.EC_DevicePropChanged, Parameter1: []byte{0x05, 0x50}}})
+
+	if pc := next(); pc.Code != ptp.DPC_WhiteBalance || pc.Initial || pc.Old != 2 || pc.New != 4 {
+		t.Errorf("WatchProperties() got = %+v; want change from 2 to 4 for %#x", pc, ptp.DPC_WhiteBalance)
+	}
+
+	stop()
+	stop()
+	c.eventHandlersMu.Lock()
+	n := len(c.eventHandlers)
+	c.eventHandlersMu.Unlock()
+	if n != 0 {
+		t.Errorf("WatchProperties() event handlers = %d after stopping; want 0", n)
+	}
+}
@@ -0,0 +1,405 @@
+// The gRPC surface of the ptpip server mode. It exposes the same functionality as the commands accepted on the server
+// socket and the WebSocket API, but with typed messages so clients can be generated for any language.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: proto/ptpip.proto
+
+package ptpipv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Camera_GetDeviceInfo_FullMethodName = "/ptpip.v1.Camera/GetDeviceInfo"
+	Camera_GetProperty_FullMethodName   = "/ptpip.v1.Camera/GetProperty"
+	Camera_SetProperty_FullMethodName   = "/ptpip.v1.Camera/SetProperty"
+	Camera_Capture_FullMethodName       = "/ptpip.v1.Camera/Capture"
+	Camera_ListObjects_FullMethodName   = "/ptpip.v1.Camera/ListObjects"
+	Camera_GetObject_FullMethodName     = "/ptpip.v1.Camera/GetObject"
+	Camera_StreamEvents_FullMethodName  = "/ptpip.v1.Camera/StreamEvents"
+)
+
+// CameraClient is the client API for Camera service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CameraClient interface {
+	// GetDeviceInfo returns the device info as reported by the camera.
+	GetDeviceInfo(ctx context.Context, in *GetDeviceInfoRequest, opts ...grpc.CallOption) (*DeviceInfo, error)
+	// GetProperty returns the description and current value of a single device property.
+	GetProperty(ctx context.Context, in *GetPropertyRequest, opts ...grpc.CallOption) (*Property, error)
+	// SetProperty changes the value of a single device property and returns the property as it is after the change.
+	SetProperty(ctx context.Context, in *SetPropertyRequest, opts ...grpc.CallOption) (*Property, error)
+	// Capture releases the shutter. When the camera supports it, the preview of the captured image is returned.
+	Capture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (*CaptureResponse, error)
+	// ListObjects returns the handles of the objects, i.e. the images and movies, stored on the camera.
+	ListObjects(ctx context.Context, in *ListObjectsRequest, opts ...grpc.CallOption) (*ListObjectsResponse, error)
+	// GetObject streams the data of a single object in chunks.
+	GetObject(ctx context.Context, in *GetObjectRequest, opts ...grpc.CallOption) (Camera_GetObjectClient, error)
+	// StreamEvents streams all events sent by the camera until the client cancels the call. A property change is
+	// followed by the new value of the property.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Camera_StreamEventsClient, error)
+}
+
+type cameraClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCameraClient(cc grpc.ClientConnInterface) CameraClient {
+	return &cameraClient{cc}
+}
+
+func (c *cameraClient) GetDeviceInfo(ctx context.Context, in *GetDeviceInfoRequest, opts ...grpc.CallOption) (*DeviceInfo, error) {
+	out := new(DeviceInfo)
+	err := c.cc.Invoke(ctx, Camera_GetDeviceInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cameraClient) GetProperty(ctx context.Context, in *GetPropertyRequest, opts ...grpc.CallOption) (*Property, error) {
+	out := new(Property)
+	err := c.cc.Invoke(ctx, Camera_GetProperty_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cameraClient) SetProperty(ctx context.Context, in *SetPropertyRequest, opts ...grpc.CallOption) (*Property, error) {
+	out := new(Property)
+	err := c.cc.Invoke(ctx, Camera_SetProperty_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cameraClient) Capture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (*CaptureResponse, error) {
+	out := new(CaptureResponse)
+	err := c.cc.Invoke(ctx, Camera_Capture_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cameraClient) ListObjects(ctx context.Context, in *ListObjectsRequest, opts ...grpc.CallOption) (*ListObjectsResponse, error) {
+	out := new(ListObjectsResponse)
+	err := c.cc.Invoke(ctx, Camera_ListObjects_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cameraClient) GetObject(ctx context.Context, in *GetObjectRequest, opts ...grpc.CallOption) (Camera_GetObjectClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Camera_ServiceDesc.Streams[0], Camera_GetObject_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cameraGetObjectClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Camera_GetObjectClient interface {
+	Recv() (*ObjectChunk, error)
+	grpc.ClientStream
+}
+
+type cameraGetObjectClient struct {
+	grpc.ClientStream
+}
+
+func (x *cameraGetObjectClient) Recv() (*ObjectChunk, error) {
+	m := new(ObjectChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cameraClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Camera_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Camera_ServiceDesc.Streams[1], Camera_StreamEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cameraStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Camera_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type cameraStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *cameraStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CameraServer is the server API for Camera service.
+// All implementations must embed UnimplementedCameraServer
+// for forward compatibility
+type CameraServer interface {
+	// GetDeviceInfo returns the device info as reported by the camera.
+	GetDeviceInfo(context.Context, *GetDeviceInfoRequest) (*DeviceInfo, error)
+	// GetProperty returns the description and current value of a single device property.
+	GetProperty(context.Context, *GetPropertyRequest) (*Property, error)
+	// SetProperty changes the value of a single device property and returns the property as it is after the change.
+	SetProperty(context.Context, *SetPropertyRequest) (*Property, error)
+	// Capture releases the shutter. When the camera supports it, the preview of the captured image is returned.
+	Capture(context.Context, *CaptureRequest) (*CaptureResponse, error)
+	// ListObjects returns the handles of the objects, i.e. the images and movies, stored on the camera.
+	ListObjects(context.Context, *ListObjectsRequest) (*ListObjectsResponse, error)
+	// GetObject streams the data of a single object in chunks.
+	GetObject(*GetObjectRequest, Camera_GetObjectServer) error
+	// StreamEvents streams all events sent by the camera until the client cancels the call. A property change is
+	// followed by the new value of the property.
+	StreamEvents(*StreamEventsRequest, Camera_StreamEventsServer) error
+	mustEmbedUnimplementedCameraServer()
+}
+
+// UnimplementedCameraServer must be embedded to have forward compatible implementations.
+type UnimplementedCameraServer struct {
+}
+
+func (UnimplementedCameraServer) GetDeviceInfo(context.Context, *GetDeviceInfoRequest) (*DeviceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeviceInfo not implemented")
+}
+func (UnimplementedCameraServer) GetProperty(context.Context, *GetPropertyRequest) (*Property, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProperty not implemented")
+}
+func (UnimplementedCameraServer) SetProperty(context.Context, *SetPropertyRequest) (*Property, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetProperty not implemented")
+}
+func (UnimplementedCameraServer) Capture(context.Context, *CaptureRequest) (*CaptureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Capture not implemented")
+}
+func (UnimplementedCameraServer) ListObjects(context.Context, *ListObjectsRequest) (*ListObjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListObjects not implemented")
+}
+func (UnimplementedCameraServer) GetObject(*GetObjectRequest, Camera_GetObjectServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetObject not implemented")
+}
+func (UnimplementedCameraServer) StreamEvents(*StreamEventsRequest, Camera_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedCameraServer) mustEmbedUnimplementedCameraServer() {}
+
+// UnsafeCameraServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CameraServer will
+// result in compilation errors.
+type UnsafeCameraServer interface {
+	mustEmbedUnimplementedCameraServer()
+}
+
+func RegisterCameraServer(s grpc.ServiceRegistrar, srv CameraServer) {
+	s.RegisterService(&Camera_ServiceDesc, srv)
+}
+
+func _Camera_GetDeviceInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDeviceInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CameraServer).GetDeviceInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Camera_GetDeviceInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CameraServer).GetDeviceInfo(ctx, req.(*GetDeviceInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Camera_GetProperty_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPropertyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CameraServer).GetProperty(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Camera_GetProperty_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CameraServer).GetProperty(ctx, req.(*GetPropertyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Camera_SetProperty_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPropertyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CameraServer).SetProperty(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Camera_SetProperty_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CameraServer).SetProperty(ctx, req.(*SetPropertyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Camera_Capture_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CaptureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CameraServer).Capture(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Camera_Capture_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CameraServer).Capture(ctx, req.(*CaptureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Camera_ListObjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListObjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CameraServer).ListObjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Camera_ListObjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CameraServer).ListObjects(ctx, req.(*ListObjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Camera_GetObject_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetObjectRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CameraServer).GetObject(m, &cameraGetObjectServer{stream})
+}
+
+type Camera_GetObjectServer interface {
+	Send(*ObjectChunk) error
+	grpc.ServerStream
+}
+
+type cameraGetObjectServer struct {
+	grpc.ServerStream
+}
+
+func (x *cameraGetObjectServer) Send(m *ObjectChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Camera_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CameraServer).StreamEvents(m, &cameraStreamEventsServer{stream})
+}
+
+type Camera_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type cameraStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *cameraStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Camera_ServiceDesc is the grpc.ServiceDesc for Camera service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Camera_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ptpip.v1.Camera",
+	HandlerType: (*CameraServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetDeviceInfo",
+			Handler:    _Camera_GetDeviceInfo_Handler,
+		},
+		{
+			MethodName: "GetProperty",
+			Handler:    _Camera_GetProperty_Handler,
+		},
+		{
+			MethodName: "SetProperty",
+			Handler:    _Camera_SetProperty_Handler,
+		},
+		{
+			MethodName: "Capture",
+			Handler:    _Camera_Capture_Handler,
+		},
+		{
+			MethodName: "ListObjects",
+			Handler:    _Camera_ListObjects_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetObject",
+			Handler:       _Camera_GetObject_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Camera_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/ptpip.proto",
+}
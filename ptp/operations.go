@@ -1,6 +1,10 @@
 package ptp
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
 
 // The most significant nibble (4 bits) is used to indicate the category of the code and whether the code value is
 // standard or vendor-extended: 0001 = standard, 1001 = vendor-extended.
@@ -59,6 +63,16 @@ const (
 	OC_CopyObject           OperationCode = 0x101A
 	OC_GetPartialObject     OperationCode = 0x101B
 	OC_InitiateOpenCapture  OperationCode = 0x101C
+	// The following operations were added in PTP v1.1.
+	OC_StartEnumHandles       OperationCode = 0x101D
+	OC_EnumHandles            OperationCode = 0x101E
+	OC_StopEnumHandles        OperationCode = 0x101F
+	OC_GetVendorExtensionMaps OperationCode = 0x1020
+	OC_GetVendorDeviceInfo    OperationCode = 0x1021
+	OC_GetResizedImageObject  OperationCode = 0x1022
+	OC_GetFilesystemManifest  OperationCode = 0x1023
+	OC_GetStreamInfo          OperationCode = 0x1024
+	OC_GetStream              OperationCode = 0x1025
 
 	RC_Undefined                             OperationResponseCode = 0x2000
 	RC_OK                                    OperationResponseCode = 0x2001
@@ -93,87 +107,243 @@ const (
 	RC_SessionAlreadyOpen                    OperationResponseCode = 0x201E
 	RC_TransactionCancelled                  OperationResponseCode = 0x201F
 	RC_SpecificationofDestinationUnsupported OperationResponseCode = 0x2020
+	// The following response codes were added in PTP v1.1.
+	RC_InvalidEnumHandle OperationResponseCode = 0x2021
+	RC_NoStreamEnabled   OperationResponseCode = 0x2022
+	RC_InvalidDataSet    OperationResponseCode = 0x2023
+)
+
+// responseCode holds the name of a response code as used in the specification and the error message describing it.
+type responseCode struct {
+	name string
+	msg  string
+}
+
+// responseCodes holds all standard response codes as defined by ISO 15740 and PTP v1.1.
+var responseCodes = map[OperationResponseCode]responseCode{
+	RC_Undefined:                             {"Undefined", "undefined response code"},
+	RC_OK:                                    {"OK", ""},
+	RC_GeneralError:                          {"General_Error", "general error occured"},
+	RC_SessionNotOpen:                        {"Session_Not_Open", "session not open: open a session first"},
+	RC_InvalidTransactionID:                  {"Invalid_TransactionID", "invalid transaction id"},
+	RC_OperationNotSupported:                 {"Operation_Not_Supported", "operation not supported"},
+	RC_ParameterNotSupported:                 {"Parameter_Not_Supported", "paramter not supported"},
+	RC_IncompleteTransfer:                    {"Incomplete_Transfer", "incomplete transfer"},
+	RC_InvalidStorageID:                      {"Invalid_StorageID", "invalid storage id"},
+	RC_InvalidObjectHandle:                   {"Invalid_ObjectHandle", "invalid object handle"},
+	RC_DevicePropNotSupported:                {"DeviceProp_Not_Supported", "device property not supported"},
+	RC_InvalidObjectFormatCode:               {"Invalid_ObjectFormatCode", "invalid object format code"},
+	RC_StoreFull:                             {"Store_Full", "store full"},
+	RC_ObjectWriteProtected:                  {"Object_WriteProtected", "object write protected"},
+	RC_StoreReadOnly:                         {"Store_Read-Only", "store read only"},
+	RC_AccessDenied:                          {"Access_Denied", "access denied"},
+	RC_NoThumbnailPresent:                    {"No_Thumbnail_Present", "no thumbnail present"},
+	RC_SelfTestFailed:                        {"SelfTest_Failed", "self test failed"},
+	RC_PartialDeletion:                       {"Partial_Deletion", "partial deletion"},
+	RC_StoreNotAvailable:                     {"Store_Not_Available", "store not available"},
+	RC_SpecificationByFormatUnsupported:      {"Specification_By_Format_Unsupported", "specification by format unsupported"},
+	RC_NoValidObjectInfo:                     {"No_Valid_ObjectInfo", "no valid object info"},
+	RC_InvalidCodeFormat:                     {"Invalid_Code_Format", "invalid code format"},
+	RC_UnknownVendorCode:                     {"Unknown_Vendor_Code", "unknown vendor code"},
+	RC_CaptureAlreadyTerminated:              {"Capture_Already_Terminated", "capture already terminated"},
+	RC_DeviceBusy:                            {"Device_Busy", "device busy"},
+	RC_InvalidParentObject:                   {"Invalid_ParentObject", "invalid parent object"},
+	RC_InvalidDevicePropFormat:               {"Invalid_DeviceProp_Format", "invalid device property format"},
+	RC_InvalidDevicePropValue:                {"Invalid_DeviceProp_Value", "invalid device property value"},
+	RC_InvalidParameter:                      {"Invalid_Parameter", "invalid parameter"},
+	RC_SessionAlreadyOpen:                    {"Session_Already_Open", "session already open"},
+	RC_TransactionCancelled:                  {"Transaction_Cancelled", "transaction cancelled"},
+	RC_SpecificationofDestinationUnsupported: {"Specification_of_Destination_Unsupported", "specification of destination unsupported"},
+	RC_InvalidEnumHandle:                     {"Invalid_EnumHandle", "invalid enumeration handle"},
+	RC_NoStreamEnabled:                       {"No_Stream_Enabled", "no stream enabled"},
+	RC_InvalidDataSet:                        {"Invalid_Data_Set", "invalid data set"},
+}
+
+var (
+	vendorResponseCodesMu sync.RWMutex
+	// vendorResponseCodes holds the vendor-extended response codes added using RegisterResponseCode.
+	vendorResponseCodes = make(map[OperationResponseCode]responseCode)
 )
 
+// RegisterResponseCode adds a vendor-extended response code, so that String() and ResponseCodeAsError return its name
+// and error message instead of the bare hexadecimal code. It is intended to be called from an init function and panics
+// when the code is a standard response code or has already been registered.
+func RegisterResponseCode(code OperationResponseCode, name, msg string) {
+	vendorResponseCodesMu.Lock()
+	defer vendorResponseCodesMu.Unlock()
+
+	if _, std := responseCodes[code]; std {
+		panic(fmt.Sprintf("ptp: RegisterResponseCode called for standard response code %#x", uint16(code)))
+	}
+	if _, dup := vendorResponseCodes[code]; dup {
+		panic(fmt.Sprintf("ptp: RegisterResponseCode called twice for response code %#x", uint16(code)))
+	}
+
+	vendorResponseCodes[code] = responseCode{name: name, msg: msg}
+}
+
+// lookupResponseCode returns the name and error message of a standard or registered vendor-extended response code.
+func lookupResponseCode(code OperationResponseCode) (responseCode, bool) {
+	if rc, ok := responseCodes[code]; ok {
+		return rc, true
+	}
+
+	vendorResponseCodesMu.RLock()
+	defer vendorResponseCodesMu.RUnlock()
+	rc, ok := vendorResponseCodes[code]
+
+	return rc, ok
+}
+
+// String returns the name of the response code as used in the specification, e.g. "Store_Full". The hexadecimal code
+// is returned for unknown response codes.
+func (rc OperationResponseCode) String() string {
+	if c, ok := lookupResponseCode(rc); ok {
+		return c.name
+	}
+
+	return fmt.Sprintf("%#04x", uint16(rc))
+}
+
+// OperationResponseError is returned by ResponseCodeAsError so that the response code can be retrieved from the error
+// using errors.As.
+type OperationResponseError struct {
+	Code OperationResponseCode
+	msg  string
+}
+
+func (e *OperationResponseError) Error() string {
+	return e.msg
+}
+
+// ResponseCodeAsError returns the response code as an *OperationResponseError. Nil is returned for RC_OK.
+func ResponseCodeAsError(code OperationResponseCode) error {
+	if code == RC_OK {
+		return nil
+	}
+
+	msg := fmt.Sprintf("unknown operation response code: %#x", uint16(code))
+	if rc, ok := lookupResponseCode(code); ok {
+		msg = rc.msg
+	}
+
+	return &OperationResponseError{Code: code, msg: msg}
+}
+
+// OperationResponseCodeAsError returns the response code as an *OperationResponseError.
+//
+// Deprecated: use ResponseCodeAsError.
 func OperationResponseCodeAsError(code OperationResponseCode) error {
-	var err string
-
-	switch code {
-	case RC_Undefined:
-		err = "undefined response code"
-	case RC_OK:
-		err = ""
-	case RC_GeneralError:
-		err = "general error occured"
-	case RC_SessionNotOpen:
-		err = "session not open: open a session first"
-	case RC_InvalidTransactionID:
-		err = "invalid transaction id"
-	case RC_OperationNotSupported:
-		err = "operation not supported"
-	case RC_ParameterNotSupported:
-		err = "paramter not supported"
-	case RC_IncompleteTransfer:
-		err = "incomplete transfer"
-	case RC_InvalidStorageID:
-		err = "invalid storage id"
-	case RC_InvalidObjectHandle:
-		err = "invalid object handle"
-	case RC_DevicePropNotSupported:
-		err = "device property not supported"
-	case RC_InvalidObjectFormatCode:
-		err = "invalid object format code"
-	case RC_StoreFull:
-		err = "store full"
-	case RC_ObjectWriteProtected:
-		err = "object write protected"
-	case RC_StoreReadOnly:
-		err = "store read only"
-	case RC_AccessDenied:
-		err = "access denied"
-	case RC_NoThumbnailPresent:
-		err = "no thumbnail present"
-	case RC_SelfTestFailed:
-		err = "self test failed"
-	case RC_PartialDeletion:
-		err = "partial deletion"
-	case RC_StoreNotAvailable:
-		err = "store not available"
-	case RC_SpecificationByFormatUnsupported:
-		err = "specification by format unsupported"
-	case RC_NoValidObjectInfo:
-		err = "no valid object info"
-	case RC_InvalidCodeFormat:
-		err = "invalid code format"
-	case RC_UnknownVendorCode:
-		err = "unknown vendor code"
-	case RC_CaptureAlreadyTerminated:
-		err = "capture already terminated"
-	case RC_DeviceBusy:
-		err = "device busy"
-	case RC_InvalidParentObject:
-		err = "invalid parent object"
-	case RC_InvalidDevicePropFormat:
-		err = "invalid device property format"
-	case RC_InvalidDevicePropValue:
-		err = "invalid device property value"
-	case RC_InvalidParameter:
-		err = "invalid parameter"
-	case RC_SessionAlreadyOpen:
-		err = "session already open"
-	case RC_TransactionCancelled:
-		err = "transaction cancelled"
-	case RC_SpecificationofDestinationUnsupported:
-		err = "specification of destination unsupported"
-	default:
-		err = fmt.Sprintf("unknown operation response code: %#x", code)
-	}
-
-	if err != "" {
-		return fmt.Errorf(err)
-	}
-
-	return nil
+	return ResponseCodeAsError(code)
+}
+
+// MaxOperationParameters is the maximum number of parameters of an operation request or response.
+const MaxOperationParameters = 5
+
+// DataDirection indicates in which direction the data phase of an operation transfers data, if it has one at all.
+type DataDirection uint8
+
+const (
+	// DD_None indicates the operation has no data phase.
+	DD_None DataDirection = iota
+	// DD_In indicates data is transferred from the Responder to the Initiator.
+	DD_In
+	// DD_Out indicates data is transferred from the Initiator to the Responder.
+	DD_Out
+)
+
+func (dd DataDirection) String() string {
+	switch dd {
+	case DD_None:
+		return "none"
+	case DD_In:
+		return "in"
+	case DD_Out:
+		return "out"
+	}
+
+	return fmt.Sprintf("unknown data direction %d", uint8(dd))
+}
+
+// OperationInfo describes a standard operation.
+type OperationInfo struct {
+	// Name is the name of the operation as used in the specification, e.g. "GetObjectInfo".
+	Name string
+	// Parameters is the maximum number of parameters of the operation request. Some parameters are optional, so
+	// fewer parameters can be passed.
+	Parameters int
+	// Data is the direction of the data phase of the operation.
+	Data DataDirection
+}
+
+// operations holds the details of all standard operations as defined by ISO 15740 and PTP v1.1.
+var operations = map[OperationCode]OperationInfo{
+	OC_GetDeviceInfo:          {"GetDeviceInfo", 0, DD_In},
+	OC_OpenSession:            {"OpenSession", 1, DD_None},
+	OC_CloseSession:           {"CloseSession", 0, DD_None},
+	OC_GetStorageIDs:          {"GetStorageIDs", 0, DD_In},
+	OC_GetStorageInfo:         {"GetStorageInfo", 1, DD_In},
+	OC_GetNumObjects:          {"GetNumObjects", 3, DD_None},
+	OC_GetObjectHandles:       {"GetObjectHandles", 3, DD_In},
+	OC_GetObjectInfo:          {"GetObjectInfo", 1, DD_In},
+	OC_GetObject:              {"GetObject", 1, DD_In},
+	OC_GetThumb:               {"GetThumb", 1, DD_In},
+	OC_DeleteObject:           {"DeleteObject", 2, DD_None},
+	OC_SendObjectInfo:         {"SendObjectInfo", 2, DD_Out},
+	OC_SendObject:             {"SendObject", 0, DD_Out},
+	OC_InitiateCapture:        {"InitiateCapture", 2, DD_None},
+	OC_FormatStore:            {"FormatStore", 2, DD_None},
+	OC_ResetDevice:            {"ResetDevice", 0, DD_None},
+	OC_SelfTest:               {"SelfTest", 1, DD_None},
+	OC_SetObjectProtection:    {"SetObjectProtection", 2, DD_None},
+	OC_PowerDown:              {"PowerDown", 0, DD_None},
+	OC_GetDevicePropDesc:      {"GetDevicePropDesc", 1, DD_In},
+	OC_GetDevicePropValue:     {"GetDevicePropValue", 1, DD_In},
+	OC_SetDevicePropValue:     {"SetDevicePropValue", 1, DD_Out},
+	OC_ResetDevicePropValue:   {"ResetDevicePropValue", 1, DD_None},
+	OC_TerminateOpenCapture:   {"TerminateOpenCapture", 1, DD_None},
+	OC_MoveObject:             {"MoveObject", 3, DD_None},
+	OC_CopyObject:             {"CopyObject", 3, DD_None},
+	OC_GetPartialObject:       {"GetPartialObject", 3, DD_In},
+	OC_InitiateOpenCapture:    {"InitiateOpenCapture", 2, DD_None},
+	OC_StartEnumHandles:       {"StartEnumHandles", 3, DD_None},
+	OC_EnumHandles:            {"EnumHandles", 2, DD_In},
+	OC_StopEnumHandles:        {"StopEnumHandles", 1, DD_None},
+	OC_GetVendorExtensionMaps: {"GetVendorExtensionMaps", 0, DD_In},
+	OC_GetVendorDeviceInfo:    {"GetVendorDeviceInfo", 1, DD_In},
+	OC_GetResizedImageObject:  {"GetResizedImageObject", 3, DD_In},
+	OC_GetFilesystemManifest:  {"GetFilesystemManifest", 3, DD_In},
+	OC_GetStreamInfo:          {"GetStreamInfo", 1, DD_In},
+	OC_GetStream:              {"GetStream", 0, DD_In},
+}
+
+// OperationCodeInfo returns the details of a standard operation. False is returned for vendor-extended and unknown
+// operation codes.
+func OperationCodeInfo(code OperationCode) (OperationInfo, bool) {
+	oi, ok := operations[code]
+	return oi, ok
+}
+
+// OperationCodeName returns the name of the operation for standard operation codes and the hexadecimal operation code
+// for all other codes, which is useful when logging or tracing operations.
+func OperationCodeName(code OperationCode) string {
+	if oi, ok := operations[code]; ok {
+		return oi.Name
+	}
+
+	return fmt.Sprintf("%#04x", uint16(code))
+}
+
+// OperationCodeByName returns the code of the standard operation with the given name, e.g. "GetObjectInfo". The name
+// is matched case-insensitively. False is returned when no standard operation goes by that name.
+func OperationCodeByName(name string) (OperationCode, bool) {
+	for code, oi := range operations {
+		if strings.EqualFold(oi.Name, name) {
+			return code, true
+		}
+	}
+
+	return 0, false
 }
 
 // OperationRequest consists of the ip-specific transmission of a 30-byte operation dataset from the Initiator to the
@@ -192,15 +362,12 @@ type OperationRequest struct {
 	// to 0x00000000 for the OpenSession operation.
 	TransactionID TransactionID
 
-	// ParameterN hold the operation-specific nth parameter. Operations may have at most five parameters. The
-	// interpretation of any parameter is dependent upon the OperationCode. Any unused parameter fields should be set to
-	// 0x00000000. If a parameter holds a value that is less than 32 bits, the lowest significant bits shall be used to
-	// store the value, with the most significant bits being set to zeros.
-	Parameter1 uint32
-	Parameter2 uint32
-	Parameter3 uint32
-	Parameter4 uint32
-	Parameter5 uint32
+	// Parameters hold the operation-specific parameters, the nth element being the nth parameter. Operations may have at
+	// most MaxOperationParameters parameters. The interpretation of any parameter is dependent upon the OperationCode.
+	// Any unused parameter fields should be set to 0x00000000, which is taken care of when the request is sent. If a
+	// parameter holds a value that is less than 32 bits, the lowest significant bits shall be used to store the value,
+	// with the most significant bits being set to zeros.
+	Parameters []uint32
 }
 
 func (oreq *OperationRequest) Session() SessionID {
@@ -222,16 +389,13 @@ type OperationResponse struct {
 	// is received by the Responder prior to responding.
 	TransactionID TransactionID
 
-	// These fields hold the operation-specific nth response parameter. Response datasets may have at most five
-	// parameters. The interpretation of any parameter is dependent upon the OperationCode for which the response has
-	// been generated, and secondarily may be a function of the particular ResponseCode itself. Any unused parameter
-	// fields should be set to 0x00000000. If a parameter holds a value that is less than 32 bits, the lowest
-	// significant bits shall be used to store the value, with the most significant bits being set to zeros.
-	Parameter1 uint32
-	Parameter2 uint32
-	Parameter3 uint32
-	Parameter4 uint32
-	Parameter5 uint32
+	// Parameters hold the operation-specific response parameters, the nth element being the nth parameter. Response
+	// datasets may have at most MaxOperationParameters parameters. The interpretation of any parameter is dependent upon
+	// the OperationCode for which the response has been generated, and secondarily may be a function of the particular
+	// ResponseCode itself. Not all responders send the unused parameters, so there may be less elements than the
+	// operation defines. If a parameter holds a value that is less than 32 bits, the lowest significant bits shall be
+	// used to store the value, with the most significant bits being set to zeros.
+	Parameters []uint32
 }
 
 func (ores *OperationResponse) Session() SessionID {
@@ -261,7 +425,7 @@ func GetDeviceInfo(tid TransactionID) OperationRequest {
 func OpenSession(sid SessionID) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_OpenSession,
-		Parameter1:    uint32(sid),
+		Parameters:    []uint32{uint32(sid)},
 	}
 }
 
@@ -285,7 +449,7 @@ func GetStorageIDs() OperationRequest {
 func GetStorageInfo(sid StorageID) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetStorageInfo,
-		Parameter1:    uint32(sid),
+		Parameters:    []uint32{uint32(sid)},
 	}
 }
 
@@ -313,9 +477,7 @@ func GetStorageInfo(sid StorageID) OperationRequest {
 func GetNumObjects(sid StorageID, code ObjectFormatCode, handle ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetNumObjects,
-		Parameter1:    uint32(sid),
-		Parameter2:    uint32(code),
-		Parameter3:    uint32(handle),
+		Parameters:    []uint32{uint32(sid), uint32(code), uint32(handle)},
 	}
 }
 
@@ -341,9 +503,7 @@ func GetNumObjects(sid StorageID, code ObjectFormatCode, handle ObjectHandle) Op
 func GetObjectHandles(sid StorageID, code ObjectFormatCode, handle ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetObjectHandles,
-		Parameter1:    uint32(sid),
-		Parameter2:    uint32(code),
-		Parameter3:    uint32(handle),
+		Parameters:    []uint32{uint32(sid), uint32(code), uint32(handle)},
 	}
 }
 
@@ -355,7 +515,7 @@ func GetObjectHandles(sid StorageID, code ObjectFormatCode, handle ObjectHandle)
 func GetObjectInfo(handle ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetObjectInfo,
-		Parameter1:    uint32(handle),
+		Parameters:    []uint32{uint32(handle)},
 	}
 }
 
@@ -368,7 +528,7 @@ func GetObjectInfo(handle ObjectHandle) OperationRequest {
 func GetObject(handle ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetObject,
-		Parameter1:    uint32(handle),
+		Parameters:    []uint32{uint32(handle)},
 	}
 }
 
@@ -377,7 +537,7 @@ func GetObject(handle ObjectHandle) OperationRequest {
 func GetThumb(handle ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetThumb,
-		Parameter1:    uint32(handle),
+		Parameters:    []uint32{uint32(handle)},
 	}
 }
 
@@ -402,8 +562,7 @@ func GetThumb(handle ObjectHandle) OperationRequest {
 func DeleteObject(handle ObjectHandle, code ObjectFormatCode) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_DeleteObject,
-		Parameter1:    uint32(handle),
-		Parameter2:    uint32(code),
+		Parameters:    []uint32{uint32(handle), uint32(code)},
 	}
 }
 
@@ -460,8 +619,7 @@ func DeleteObject(handle ObjectHandle, code ObjectFormatCode) OperationRequest {
 func SendObjectInfo(dest StorageID, parent ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_SendObjectInfo,
-		Parameter1:    uint32(dest),
-		Parameter2:    uint32(parent),
+		Parameters:    []uint32{uint32(dest), uint32(parent)},
 	}
 }
 
@@ -541,8 +699,7 @@ func SendObject() OperationRequest {
 func InitiateCapture(dest StorageID, code ObjectFormatCode) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_InitiateCapture,
-		Parameter1:    uint32(dest),
-		Parameter2:    uint32(code),
+		Parameters:    []uint32{uint32(dest), uint32(code)},
 	}
 }
 
@@ -553,8 +710,7 @@ func InitiateCapture(dest StorageID, code ObjectFormatCode) OperationRequest {
 func FormatStore(dest StorageID, fst FilesystemType) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_FormatStore,
-		Parameter1:    uint32(dest),
-		Parameter2:    uint32(fst),
+		Parameters:    []uint32{uint32(dest), uint32(fst)},
 	}
 }
 
@@ -574,7 +730,7 @@ func ResetDevice() OperationRequest {
 func SelfTest(testType SelfTestType) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_SelfTest,
-		Parameter1:    uint32(testType),
+		Parameters:    []uint32{uint32(testType)},
 	}
 }
 
@@ -584,8 +740,7 @@ func SelfTest(testType SelfTestType) OperationRequest {
 func SetObjectProtection(handle ObjectHandle, status ProtectionStatus) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_SetObjectProtection,
-		Parameter1:    uint32(handle),
-		Parameter2:    uint32(status),
+		Parameters:    []uint32{uint32(handle), uint32(status)},
 	}
 }
 
@@ -600,7 +755,7 @@ func PowerDown() OperationRequest {
 func GetDevicePropDesc(code DevicePropCode) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetDevicePropDesc,
-		Parameter1:    uint32(code),
+		Parameters:    []uint32{uint32(code)},
 	}
 }
 
@@ -611,7 +766,7 @@ func GetDevicePropDesc(code DevicePropCode) OperationRequest {
 func GetDevicePropValue(code DevicePropCode) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetDevicePropValue,
-		Parameter1:    uint32(code),
+		Parameters:    []uint32{uint32(code)},
 	}
 }
 
@@ -624,7 +779,7 @@ func SetDevicePropValue(code DevicePropCode, value interface{}) OperationRequest
 	// TODO: handle the data phase here. The value should be set in the data phase.
 	return OperationRequest{
 		OperationCode: OC_SetDevicePropValue,
-		Parameter1:    uint32(code),
+		Parameters:    []uint32{uint32(code)},
 	}
 }
 
@@ -633,7 +788,7 @@ func SetDevicePropValue(code DevicePropCode, value interface{}) OperationRequest
 func ResetDevicePropValue(code DevicePropCode) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_ResetDevicePropValue,
-		Parameter1:    uint32(code),
+		Parameters:    []uint32{uint32(code)},
 	}
 }
 
@@ -649,7 +804,7 @@ func ResetDevicePropValue(code DevicePropCode) OperationRequest {
 func TerminateOpenCapture(tid TransactionID) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_TerminateOpenCapture,
-		Parameter1:    uint32(tid),
+		Parameters:    []uint32{uint32(tid)},
 	}
 }
 
@@ -661,9 +816,7 @@ func TerminateOpenCapture(tid TransactionID) OperationRequest {
 func MoveObject(handle ObjectHandle, dest StorageID, newParent ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_MoveObject,
-		Parameter1:    uint32(handle),
-		Parameter2:    uint32(dest),
-		Parameter3:    uint32(newParent),
+		Parameters:    []uint32{uint32(handle), uint32(dest), uint32(newParent)},
 	}
 }
 
@@ -674,9 +827,7 @@ func MoveObject(handle ObjectHandle, dest StorageID, newParent ObjectHandle) Ope
 func CopyObject(handle ObjectHandle, dest StorageID, newParent ObjectHandle) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_CopyObject,
-		Parameter1:    uint32(handle),
-		Parameter2:    uint32(dest),
-		Parameter3:    uint32(newParent),
+		Parameters:    []uint32{uint32(handle), uint32(dest), uint32(newParent)},
 	}
 }
 
@@ -694,9 +845,7 @@ func CopyObject(handle ObjectHandle, dest StorageID, newParent ObjectHandle) Ope
 func GetPartialObject(handle ObjectHandle, offset uint32, maxBytes uint32) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_GetPartialObject,
-		Parameter1:    uint32(handle),
-		Parameter2:    offset,
-		Parameter3:    maxBytes,
+		Parameters:    []uint32{uint32(handle), offset, maxBytes},
 	}
 }
 
@@ -764,7 +913,6 @@ func GetPartialObject(handle ObjectHandle, offset uint32, maxBytes uint32) Opera
 func InitiateOpenCapture(sid StorageID, format ObjectFormatCode) OperationRequest {
 	return OperationRequest{
 		OperationCode: OC_InitiateOpenCapture,
-		Parameter1:    uint32(sid),
-		Parameter2:    uint32(format),
+		Parameters:    []uint32{uint32(sid), uint32(format)},
 	}
 }
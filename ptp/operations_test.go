@@ -1,8 +1,11 @@
 package ptp
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
-func TestOperationResponseCodeAsError(t *testing.T) {
+func TestResponseCodeAsError(t *testing.T) {
 	check := map[OperationResponseCode]string{
 		RC_Undefined:                             "undefined response code",
 		RC_GeneralError:                          "general error occured",
@@ -40,19 +43,80 @@ func TestOperationResponseCodeAsError(t *testing.T) {
 	}
 
 	for code, want := range check {
-		got := OperationResponseCodeAsError(code)
+		got := ResponseCodeAsError(code)
 		if got.Error() != want {
 			t.Errorf("OperationResponseCodeAsString() return = '%s', want '%s'", got, want)
 		}
 	}
 
 	var want error = nil
-	got := OperationResponseCodeAsError(RC_OK)
+	got := ResponseCodeAsError(RC_OK)
 	if got != want {
 		t.Errorf("OperationResponseCodeAsString() return = '%s', want '%#v'", got, want)
 	}
 }
 
+func TestOperationCodeInfo(t *testing.T) {
+	for code := OC_GetDeviceInfo; code <= OC_GetStream; code++ {
+		oi, ok := OperationCodeInfo(code)
+		if !ok || oi.Name == "" || oi.Parameters > MaxOperationParameters {
+			t.Errorf("OperationCodeInfo() %#x got = %v, %t; want a named operation with at most %d parameters", code, oi, ok, MaxOperationParameters)
+		}
+	}
+
+	oi, _ := OperationCodeInfo(OC_SendObjectInfo)
+	if oi.Name != "SendObjectInfo" || oi.Parameters != 2 || oi.Data != DD_Out {
+		t.Errorf("OperationCodeInfo() got = %v; want {SendObjectInfo 2 out}", oi)
+	}
+
+	if _, ok := OperationCodeInfo(OC_Undefinded); ok {
+		t.Errorf("OperationCodeInfo() %#x ok = true; want false", OC_Undefinded)
+	}
+}
+
+func TestOperationCodeName(t *testing.T) {
+	check := map[OperationCode]string{
+		OC_GetPartialObject:   "GetPartialObject",
+		OC_GetStreamInfo:      "GetStreamInfo",
+		OperationCode(0x902b): "0x902b",
+		OperationCode(0x1026): "0x1026",
+	}
+
+	for code, want := range check {
+		if got := OperationCodeName(code); got != want {
+			t.Errorf("OperationCodeName() got = %s; want %s", got, want)
+		}
+	}
+}
+
+func TestOperationCodeByName(t *testing.T) {
+	check := map[string]OperationCode{
+		"GetPartialObject":   OC_GetPartialObject,
+		"getdeviceinfo":      OC_GetDeviceInfo,
+		"SETDEVICEPROPVALUE": OC_SetDevicePropValue,
+	}
+
+	for name, want := range check {
+		if got, ok := OperationCodeByName(name); !ok || got != want {
+			t.Errorf("OperationCodeByName() got = %#04x, %t; want %#04x, true", uint16(got), ok, uint16(want))
+		}
+	}
+
+	if _, ok := OperationCodeByName("TakePicture"); ok {
+		t.Errorf("OperationCodeByName() got = true; want false")
+	}
+}
+
+func TestDataDirection_String(t *testing.T) {
+	check := map[DataDirection]string{DD_None: "none", DD_In: "in", DD_Out: "out", DataDirection(7): "unknown data direction 7"}
+
+	for dd, want := range check {
+		if got := dd.String(); got != want {
+			t.Errorf("String() got = %s; want %s", got, want)
+		}
+	}
+}
+
 func TestOperationRequest_Session(t *testing.T) {
 	oreq := &OperationRequest{
 		SessionID: 9,
@@ -92,8 +156,8 @@ func TestOpenSession(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("OpenSession() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam {
-		t.Errorf("OpenSession() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if got.Parameters[0] != wantParam {
+		t.Errorf("OpenSession() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -120,8 +184,8 @@ func TestGetStorageInfo(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetStorageInfo() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam {
-		t.Errorf("GetStorageInfo() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if got.Parameters[0] != wantParam {
+		t.Errorf("GetStorageInfo() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -134,14 +198,14 @@ func TestGetNumObjects(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetNumObjects() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("GetNumObjects() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("GetNumObjects() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if got.Parameter2 != wantParam2 {
-		t.Errorf("GetNumObjects() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if got.Parameters[1] != wantParam2 {
+		t.Errorf("GetNumObjects() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
-	if got.Parameter3 != wantParam3 {
-		t.Errorf("GetNumObjects() Parameter3 = '%#x', want '%#x'", got.Parameter3, wantParam3)
+	if got.Parameters[2] != wantParam3 {
+		t.Errorf("GetNumObjects() Parameter3 = '%#x', want '%#x'", got.Parameters[2], wantParam3)
 	}
 }
 
@@ -154,14 +218,14 @@ func TestGetObjectHandles(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetNumObjects() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("GetNumObjects() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("GetNumObjects() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if got.Parameter2 != wantParam2 {
-		t.Errorf("GetNumObjects() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if got.Parameters[1] != wantParam2 {
+		t.Errorf("GetNumObjects() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
-	if got.Parameter3 != wantParam3 {
-		t.Errorf("GetNumObjects() Parameter3 = '%#x', want '%#x'", got.Parameter3, wantParam3)
+	if got.Parameters[2] != wantParam3 {
+		t.Errorf("GetNumObjects() Parameter3 = '%#x', want '%#x'", got.Parameters[2], wantParam3)
 	}
 }
 
@@ -172,8 +236,8 @@ func TestGetObjectInfo(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetObjectInfo() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam {
-		t.Errorf("GetObjectInfo() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if got.Parameters[0] != wantParam {
+		t.Errorf("GetObjectInfo() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -184,8 +248,8 @@ func TestGetObject(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetObject() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam {
-		t.Errorf("GetObject() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if got.Parameters[0] != wantParam {
+		t.Errorf("GetObject() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -196,8 +260,8 @@ func TestGetThumb(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetThumb() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam {
-		t.Errorf("GetThumb() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if got.Parameters[0] != wantParam {
+		t.Errorf("GetThumb() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -209,11 +273,11 @@ func TestDeleteObject(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("DeleteObject() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("DeleteObject() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("DeleteObject() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if ObjectFormatCode(got.Parameter2) != wantParam2 {
-		t.Errorf("DeleteObject() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if ObjectFormatCode(got.Parameters[1]) != wantParam2 {
+		t.Errorf("DeleteObject() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
 }
 
@@ -225,11 +289,11 @@ func TestSendObjectInfo(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("SendObjectInfo() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("SendObjectInfo() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("SendObjectInfo() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if got.Parameter2 != wantParam2 {
-		t.Errorf("SendObjectInfo() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if got.Parameters[1] != wantParam2 {
+		t.Errorf("SendObjectInfo() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
 }
 
@@ -249,11 +313,11 @@ func TestInitiateCapture(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("InitiateCapture() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("InitiateCapture() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("InitiateCapture() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if ObjectFormatCode(got.Parameter2) != wantParam2 {
-		t.Errorf("InitiateCapture() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if ObjectFormatCode(got.Parameters[1]) != wantParam2 {
+		t.Errorf("InitiateCapture() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
 }
 
@@ -265,11 +329,11 @@ func TestFormatStore(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("FormatStore() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("FormatStore() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("FormatStore() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if FilesystemType(got.Parameter2) != wantParam2 {
-		t.Errorf("FormatStore() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if FilesystemType(got.Parameters[1]) != wantParam2 {
+		t.Errorf("FormatStore() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
 }
 
@@ -288,8 +352,8 @@ func TestSelfTest(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("SelfTest() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if SelfTestType(got.Parameter1) != wantParam {
-		t.Errorf("SelfTest() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if SelfTestType(got.Parameters[0]) != wantParam {
+		t.Errorf("SelfTest() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -301,11 +365,11 @@ func TestSetObjectProtection(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("SetObjectProtection() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("SetObjectProtection() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("SetObjectProtection() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if ProtectionStatus(got.Parameter2) != wantParam2 {
-		t.Errorf("SetObjectProtection() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if ProtectionStatus(got.Parameters[1]) != wantParam2 {
+		t.Errorf("SetObjectProtection() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
 }
 
@@ -324,8 +388,8 @@ func TestGetDevicePropDesc(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetDevicePropDesc() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if DevicePropCode(got.Parameter1) != wantParam {
-		t.Errorf("GetDevicePropDesc() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if DevicePropCode(got.Parameters[0]) != wantParam {
+		t.Errorf("GetDevicePropDesc() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -336,8 +400,8 @@ func TestGetDevicePropValue(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetDevicePropValue() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if DevicePropCode(got.Parameter1) != wantParam {
-		t.Errorf("GetDevicePropValue() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if DevicePropCode(got.Parameters[0]) != wantParam {
+		t.Errorf("GetDevicePropValue() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -348,8 +412,8 @@ func TestSetDevicePropValue(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("SetDevicePropValue() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if DevicePropCode(got.Parameter1) != wantParam {
-		t.Errorf("SetDevicePropValue() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if DevicePropCode(got.Parameters[0]) != wantParam {
+		t.Errorf("SetDevicePropValue() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -360,8 +424,8 @@ func TestResetDevicePropValue(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("ResetDevicePropValue() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if DevicePropCode(got.Parameter1) != wantParam {
-		t.Errorf("ResetDevicePropValue() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if DevicePropCode(got.Parameters[0]) != wantParam {
+		t.Errorf("ResetDevicePropValue() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -372,8 +436,8 @@ func TestTerminateOpenCapture(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("TerminateOpenCapture() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam {
-		t.Errorf("TerminateOpenCapture() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam)
+	if got.Parameters[0] != wantParam {
+		t.Errorf("TerminateOpenCapture() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam)
 	}
 }
 
@@ -386,14 +450,14 @@ func TestMoveObject(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("MoveObject() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("MoveObject() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("MoveObject() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if got.Parameter2 != wantParam2 {
-		t.Errorf("MoveObject() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if got.Parameters[1] != wantParam2 {
+		t.Errorf("MoveObject() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
-	if got.Parameter3 != wantParam3 {
-		t.Errorf("MoveObject() Parameter3 = '%#x', want '%#x'", got.Parameter3, wantParam3)
+	if got.Parameters[2] != wantParam3 {
+		t.Errorf("MoveObject() Parameter3 = '%#x', want '%#x'", got.Parameters[2], wantParam3)
 	}
 }
 
@@ -406,14 +470,14 @@ func TestCopyObject(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("CopyObject() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("CopyObject() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("CopyObject() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if got.Parameter2 != wantParam2 {
-		t.Errorf("CopyObject() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if got.Parameters[1] != wantParam2 {
+		t.Errorf("CopyObject() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
-	if got.Parameter3 != wantParam3 {
-		t.Errorf("CopyObject() Parameter3 = '%#x', want '%#x'", got.Parameter3, wantParam3)
+	if got.Parameters[2] != wantParam3 {
+		t.Errorf("CopyObject() Parameter3 = '%#x', want '%#x'", got.Parameters[2], wantParam3)
 	}
 }
 
@@ -426,14 +490,14 @@ func TestGetPartialObject(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("GetPartialObject() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("GetPartialObject() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("GetPartialObject() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
 	}
-	if got.Parameter2 != wantParam2 {
-		t.Errorf("GetPartialObject() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+	if got.Parameters[1] != wantParam2 {
+		t.Errorf("GetPartialObject() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
 	}
-	if got.Parameter3 != wantParam3 {
-		t.Errorf("GetPartialObject() Parameter3 = '%#x', want '%#x'", got.Parameter3, wantParam3)
+	if got.Parameters[2] != wantParam3 {
+		t.Errorf("GetPartialObject() Parameter3 = '%#x', want '%#x'", got.Parameters[2], wantParam3)
 	}
 }
 
@@ -445,10 +509,61 @@ func TestInitiateOpenCapture(t *testing.T) {
 	if got.OperationCode != wantCode {
 		t.Errorf("InitiateOpenCapture() OperationCode = '%#x', want '%#x'", got.OperationCode, wantCode)
 	}
-	if got.Parameter1 != wantParam1 {
-		t.Errorf("InitiateOpenCapture() Parameter1 = '%#x', want '%#x'", got.Parameter1, wantParam1)
+	if got.Parameters[0] != wantParam1 {
+		t.Errorf("InitiateOpenCapture() Parameter1 = '%#x', want '%#x'", got.Parameters[0], wantParam1)
+	}
+	if ObjectFormatCode(got.Parameters[1]) != wantParam2 {
+		t.Errorf("InitiateOpenCapture() Parameter2 = '%#x', want '%#x'", got.Parameters[1], wantParam2)
+	}
+}
+
+func TestOperationResponseError(t *testing.T) {
+	var ore *OperationResponseError
+	if err := ResponseCodeAsError(RC_StoreFull); !errors.As(err, &ore) || ore.Code != RC_StoreFull {
+		t.Errorf("ResponseCodeAsError() error = %#v; want *OperationResponseError with code %s", err, RC_StoreFull)
 	}
-	if ObjectFormatCode(got.Parameter2) != wantParam2 {
-		t.Errorf("InitiateOpenCapture() Parameter2 = '%#x', want '%#x'", got.Parameter2, wantParam2)
+}
+
+func TestOperationResponseCode_String(t *testing.T) {
+	check := map[OperationResponseCode]string{
+		RC_OK:                       "OK",
+		RC_StoreFull:                "Store_Full",
+		RC_StoreReadOnly:            "Store_Read-Only",
+		RC_InvalidDataSet:           "Invalid_Data_Set",
+		OperationResponseCode(5082): "0x13da",
+	}
+
+	for code, want := range check {
+		if got := code.String(); got != want {
+			t.Errorf("String() got = %s; want %s", got, want)
+		}
+	}
+}
+
+func TestRegisterResponseCode(t *testing.T) {
+	code := OperationResponseCode(0xa0ff)
+	RegisterResponseCode(code, "Test_Not_Ready", "test not ready")
+	defer func() {
+		vendorResponseCodesMu.Lock()
+		delete(vendorResponseCodes, code)
+		vendorResponseCodesMu.Unlock()
+	}()
+
+	if got := code.String(); got != "Test_Not_Ready" {
+		t.Errorf("String() got = %s; want Test_Not_Ready", got)
+	}
+	if got := ResponseCodeAsError(code); got.Error() != "test not ready" {
+		t.Errorf("ResponseCodeAsError() got = %s; want test not ready", got)
+	}
+
+	for _, c := range []OperationResponseCode{code, RC_StoreFull} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterResponseCode() %#x did not panic", uint16(c))
+				}
+			}()
+			RegisterResponseCode(c, "Duplicate", "duplicate")
+		}()
 	}
 }
@@ -1,5 +1,7 @@
 package ptp
 
+import "fmt"
+
 type StorageType uint16
 type FilesystemType uint16
 type AccessCapability uint16
@@ -26,6 +28,51 @@ const (
 	PS_ReadOnly     ProtectionStatus = 0x0001
 )
 
+func (st StorageType) String() string {
+	switch st {
+	case ST_Undefined:
+		return "undefined"
+	case ST_FixedROM:
+		return "fixed ROM"
+	case ST_RemovableROM:
+		return "removable ROM"
+	case ST_FixedRAM:
+		return "fixed RAM"
+	case ST_RemovableRAM:
+		return "removable RAM"
+	}
+
+	return fmt.Sprintf("unknown storage type %#04x", uint16(st))
+}
+
+func (ft FilesystemType) String() string {
+	switch ft {
+	case FT_Undefined:
+		return "undefined"
+	case FT_GenericFlat:
+		return "generic flat"
+	case FT_GenericHierarchical:
+		return "generic hierarchical"
+	case FT_DCF:
+		return "DCF"
+	}
+
+	return fmt.Sprintf("unknown filesystem type %#04x", uint16(ft))
+}
+
+func (ac AccessCapability) String() string {
+	switch ac {
+	case AC_ReadWrite:
+		return "read-write"
+	case AC_ReadOnly_NoDeletion:
+		return "read-only"
+	case AC_ReadOnly_Deletion:
+		return "read-only with deletion"
+	}
+
+	return fmt.Sprintf("unknown access capability %#04x", uint16(ac))
+}
+
 // This dataset is used to hold the state information for a storage device.
 type StorageInfo struct {
 	// The code that identifies the type of storage, particularly whether the store is inherently random-access or
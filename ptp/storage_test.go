@@ -0,0 +1,51 @@
+package ptp
+
+import "testing"
+
+func TestStorageType_String(t *testing.T) {
+	check := map[StorageType]string{
+		ST_Undefined:    "undefined",
+		ST_FixedROM:     "fixed ROM",
+		ST_RemovableROM: "removable ROM",
+		ST_FixedRAM:     "fixed RAM",
+		ST_RemovableRAM: "removable RAM",
+		0x8001:          "unknown storage type 0x8001",
+	}
+
+	for st, want := range check {
+		if got := st.String(); got != want {
+			t.Errorf("String() got = %s; want %s", got, want)
+		}
+	}
+}
+
+func TestFilesystemType_String(t *testing.T) {
+	check := map[FilesystemType]string{
+		FT_Undefined:           "undefined",
+		FT_GenericFlat:         "generic flat",
+		FT_GenericHierarchical: "generic hierarchical",
+		FT_DCF:                 "DCF",
+		0x0004:                 "unknown filesystem type 0x0004",
+	}
+
+	for ft, want := range check {
+		if got := ft.String(); got != want {
+			t.Errorf("String() got = %s; want %s", got, want)
+		}
+	}
+}
+
+func TestAccessCapability_String(t *testing.T) {
+	check := map[AccessCapability]string{
+		AC_ReadWrite:           "read-write",
+		AC_ReadOnly_NoDeletion: "read-only",
+		AC_ReadOnly_Deletion:   "read-only with deletion",
+		0x0003:                 "unknown access capability 0x0003",
+	}
+
+	for ac, want := range check {
+		if got := ac.String(); got != want {
+			t.Errorf("String() got = %s; want %s", got, want)
+		}
+	}
+}
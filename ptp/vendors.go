@@ -2,6 +2,52 @@ package ptp
 
 type VendorExtension uint32
 
+// String returns the vendor as accepted by VendorStringToType. Vendors that are not known are returned as "generic".
+func (ve VendorExtension) String() string {
+	switch ve {
+	case VE_EastmanKodakCompany:
+		return "kodak"
+	case VE_SeikoEpson:
+		return "epson"
+	case VE_AgilentTechnologiesInc:
+		return "agilent"
+	case VE_PolaroidCorporation:
+		return "polaroid"
+	case VE_AgfaGevaert:
+		return "agfa"
+	case VE_MicrosoftCorporation:
+		return "ms"
+	case VE_EquinoxResearchLtd:
+		return "equinox"
+	case VE_ViewQuestTechnologies:
+		return "vq"
+	case VE_STMicroelectronics:
+		return "st"
+	case VE_NikonCorporation:
+		return "nikon"
+	case VE_CanonInc:
+		return "canon"
+	case VE_FotoNationInc:
+		return "fn"
+	case VE_PENTAXCorporation:
+		return "pentax"
+	case VE_FujiPhotoFilmCoLtd:
+		return "fuji"
+	case VE_SonyCorporation:
+		return "sony"
+	case VE_NddMedicalTechnologies:
+		return "ndd"
+	case VE_SamsungElectronicsCoLtd:
+		return "samsung"
+	case VE_ParrotDronesSAS:
+		return "parrot"
+	case VE_PanasonicCorporation:
+		return "panasonic"
+	default:
+		return "generic"
+	}
+}
+
 const (
 	VE_EastmanKodakCompany     VendorExtension = 0x00000001
 	VE_SeikoEpson              VendorExtension = 0x00000002
@@ -17,6 +63,7 @@ const (
 	VE_FotoNationInc           VendorExtension = 0x0000000C
 	VE_PENTAXCorporation       VendorExtension = 0x0000000D
 	VE_FujiPhotoFilmCoLtd      VendorExtension = 0x0000000E
+	VE_SonyCorporation         VendorExtension = 0x00000011
 	VE_NddMedicalTechnologies  VendorExtension = 0x00000012
 	VE_SamsungElectronicsCoLtd VendorExtension = 0x0000001A
 	VE_ParrotDronesSAS         VendorExtension = 0x0000001B
@@ -53,6 +100,8 @@ func VendorStringToType(vendor string) VendorExtension {
 		return VE_PENTAXCorporation
 	case "fuji":
 		return VE_FujiPhotoFilmCoLtd
+	case "sony":
+		return VE_SonyCorporation
 	case "ndd":
 		return VE_NddMedicalTechnologies
 	case "samsung":
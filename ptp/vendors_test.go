@@ -18,6 +18,7 @@ func TestVendorStringToType(t *testing.T) {
 		"fn":        VE_FotoNationInc,
 		"pentax":    VE_PENTAXCorporation,
 		"fuji":      VE_FujiPhotoFilmCoLtd,
+		"sony":      VE_SonyCorporation,
 		"ndd":       VE_NddMedicalTechnologies,
 		"samsung":   VE_SamsungElectronicsCoLtd,
 		"parrot":    VE_ParrotDronesSAS,
@@ -32,3 +33,12 @@ func TestVendorStringToType(t *testing.T) {
 		}
 	}
 }
+
+func TestVendorExtension_String(t *testing.T) {
+	for _, name := range []string{"kodak", "epson", "agilent", "polaroid", "agfa", "ms", "equinox", "vq", "st", "nikon", "canon", "fn", "pentax", "fuji", "sony", "ndd", "samsung", "parrot", "panasonic", "generic"} {
+		got := VendorStringToType(name).String()
+		if got != name {
+			t.Errorf("String() return = %s, want %s", got, name)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package streamer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// MJPEGBoundary is the boundary used to separate the JPEG frames in the multipart stream.
+const MJPEGBoundary = "ptpipframe"
+
+// MJPEGServer serves JPEG frames as a multipart/x-mixed-replace stream over HTTP. Any browser or tool capable of
+// handling MJPEG streams, such as OBS or VLC, can display the stream.
+// Each connected client only ever receives the latest frame: when a client is too slow to keep up, frames are dropped
+// for that client only.
+type MJPEGServer struct {
+	frames  <-chan []byte
+	clients map[chan []byte]struct{}
+	mu      sync.Mutex
+	srv     *http.Server
+	ln      net.Listener
+}
+
+// NewMJPEGServer creates a new MJPEG server that will listen on the given address and stream the frames received on
+// the given channel. Call ListenAndServe to start streaming.
+func NewMJPEGServer(addr string, frames <-chan []byte) *MJPEGServer {
+	s := &MJPEGServer{
+		frames:  frames,
+		clients: make(map[chan []byte]struct{}),
+	}
+	s.srv = &http.Server{
+		Addr:    addr,
+		Handler: s,
+	}
+
+	return s
+}
+
+// Addr returns the address the server is listening on. This is useful when listening on port 0. It returns an empty
+// string when the server is not listening yet.
+func (s *MJPEGServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ln == nil {
+		return ""
+	}
+
+	return s.ln.Addr().String()
+}
+
+// Listen opens the listener for the server without serving any requests yet.
+func (s *MJPEGServer) Listen() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ListenAndServe starts distributing the frames to the connected clients and serves HTTP requests. It blocks until
+// the frame channel is closed or Close is called and will always return a non nil error. When the frame channel is
+// closed, http.ErrServerClosed is returned.
+func (s *MJPEGServer) ListenAndServe() error {
+	if s.Addr() == "" {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	go s.distribute()
+
+	return s.srv.Serve(s.ln)
+}
+
+// Close stops the server and disconnects all clients.
+func (s *MJPEGServer) Close() error {
+	return s.srv.Close()
+}
+
+// distribute sends each frame to all connected clients and closes the server when the frame channel is closed.
+func (s *MJPEGServer) distribute() {
+	for frame := range s.frames {
+		s.mu.Lock()
+		for ch := range s.clients {
+			select {
+			case ch <- frame:
+			default:
+				// The client is still busy with the previous frame: replace it with the current one.
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- frame
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.Close()
+}
+
+func (s *MJPEGServer) addClient() chan []byte {
+	ch := make(chan []byte, 1)
+
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch
+}
+
+func (s *MJPEGServer) removeClient(ch chan []byte) {
+	s.mu.Lock()
+	delete(s.clients, ch)
+	s.mu.Unlock()
+}
+
+// ServeHTTP writes the frames to the client as they come in until the client disconnects.
+func (s *MJPEGServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ch := s.addClient()
+	defer s.removeClient(ch)
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+MJPEGBoundary)
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusOK)
+
+	// Flush the headers right away so the client knows the stream has started before the first frame arrives.
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case frame := <-ch:
+			if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", MJPEGBoundary, len(frame)); err != nil {
+				return
+			}
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+			if _, err := w.Write([]byte("\r\n")); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package streamer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMJPEGServer_ServeHTTP(t *testing.T) {
+	frames := make(chan []byte)
+	s := NewMJPEGServer("127.0.0.1:0", frames)
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- s.ListenAndServe()
+	}()
+
+	res, err := http.Get("http://" + s.Addr() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	mt, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != "multipart/x-mixed-replace" {
+		t.Errorf("ServeHTTP() media type = %s; want multipart/x-mixed-replace", mt)
+	}
+	if params["boundary"] != MJPEGBoundary {
+		t.Errorf("ServeHTTP() boundary = %s; want %s", params["boundary"], MJPEGBoundary)
+	}
+
+	want := []byte{0xff, 0xd8, 0xff, 0xe0, 0x01, 0x02, 0xff, 0xd9}
+	// The client registers itself asynchronously, so keep sending until the frame comes through.
+	go func() {
+		for i := 0; i < 50; i++ {
+			frames <- want
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	mr := multipart.NewReader(res.Body, MJPEGBoundary)
+	p, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := p.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("ServeHTTP() part Content-Type = %s; want image/jpeg", ct)
+	}
+	got, err := ioutil.ReadAll(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ServeHTTP() frame = %#v; want %#v", got, want)
+	}
+
+	s.Close()
+	select {
+	case err := <-done:
+		if err != http.ErrServerClosed {
+			t.Errorf("ListenAndServe() error = %v; want %v", err, http.ErrServerClosed)
+		}
+	case <-time.After(time.Second):
+		t.Error("ListenAndServe() did not return after Close()")
+	}
+}
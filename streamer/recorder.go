@@ -0,0 +1,186 @@
+package streamer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordingTimeFormat is the time format used to name the recorded files.
+const RecordingTimeFormat = "20060102-150405"
+
+// Encoder creates the writer a recording is written to. The path passed in has no file extension: the Encoder must
+// add the extension matching the format it writes. The JPEG frames are written to the returned writer one after the
+// other, exactly as they come in, and the writer is closed when the recording is rotated or stopped.
+type Encoder func(path string) (io.WriteCloser, error)
+
+// RawMJPEG is the default Encoder which writes the frames as a raw MJPEG file with the .mjpeg extension. Such files can
+// be played back directly by e.g. ffplay or VLC.
+func RawMJPEG(path string) (io.WriteCloser, error) {
+	return os.Create(path + ".mjpeg")
+}
+
+// CommandEncoder returns an Encoder that pipes the frames to the stdin of an external command. This allows remuxing
+// the stream into any format, e.g. MP4 using ffmpeg. Each occurrence of {} in the arguments is replaced with the path
+// of the recording followed by the given extension. For example:
+//
+//	CommandEncoder(".mp4", "ffmpeg", "-f", "mjpeg", "-i", "-", "-c:v", "libx264", "{}")
+func CommandEncoder(ext, name string, args ...string) Encoder {
+	return func(path string) (io.WriteCloser, error) {
+		a := make([]string, len(args))
+		for i, arg := range args {
+			a[i] = strings.ReplaceAll(arg, "{}", path+ext)
+		}
+
+		cmd := exec.Command(name, a...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+
+		return &commandWriter{WriteCloser: stdin, cmd: cmd}, nil
+	}
+}
+
+// commandWriter waits for the command to finish when closed so that the recording is complete once Close returns.
+type commandWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (w *commandWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+
+	return w.cmd.Wait()
+}
+
+// Recorder writes the frames received on a channel to disk. The recording is split into multiple files when MaxSize
+// or MaxDuration is reached. The files are named after the time the first frame was received, followed by a sequence
+// number, e.g. liveview-20200725-143005-001.mjpeg.
+type Recorder struct {
+	// Dir is the directory the recordings are written to.
+	Dir string
+	// Prefix is prepended to the name of each recording.
+	Prefix string
+	// MaxSize is the number of bytes after which a new file will be started. Set to 0 to disable.
+	MaxSize int64
+	// MaxDuration is the duration after which a new file will be started. Set to 0 to disable.
+	MaxDuration time.Duration
+	// Encoder creates the files the frames are written to and defaults to RawMJPEG.
+	Encoder Encoder
+
+	frames  <-chan []byte
+	w       io.WriteCloser
+	size    int64
+	started time.Time
+	first   time.Time
+	part    int
+	closed  bool
+	mu      sync.Mutex
+}
+
+// NewRecorder creates a new Recorder writing the frames received on the given channel to the given directory. Call
+// Record to start recording.
+func NewRecorder(dir string, frames <-chan []byte) *Recorder {
+	return &Recorder{
+		Dir:     dir,
+		Prefix:  "liveview-",
+		Encoder: RawMJPEG,
+		frames:  frames,
+	}
+}
+
+// Record writes the frames to disk until the frame channel is closed or Close is called. It returns the first error
+// encountered when creating or writing a file.
+func (r *Recorder) Record() error {
+	defer r.Close()
+
+	for frame := range r.frames {
+		if err := r.write(frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close finishes the current file and stops the recording.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	return r.finish()
+}
+
+// write writes a single frame to the current file, rotating it when needed.
+func (r *Recorder) write(frame []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	if r.w != nil && r.mustRotate(int64(len(frame))) {
+		if err := r.finish(); err != nil {
+			return err
+		}
+	}
+
+	if r.w == nil {
+		r.started = time.Now()
+		if r.part == 0 {
+			r.first = r.started
+		}
+		r.part++
+		path := filepath.Join(r.Dir, fmt.Sprintf("%s%s-%03d", r.Prefix, r.first.Format(RecordingTimeFormat), r.part))
+		w, err := r.Encoder(path)
+		if err != nil {
+			return fmt.Errorf("recorder: %s", err)
+		}
+		r.w = w
+	}
+
+	n, err := r.w.Write(frame)
+	r.size += int64(n)
+
+	return err
+}
+
+// mustRotate returns true when adding the given number of bytes would exceed the limits of the current file. A file
+// will always hold at least one frame.
+func (r *Recorder) mustRotate(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.MaxSize > 0 && r.size+n > r.MaxSize {
+		return true
+	}
+
+	return r.MaxDuration > 0 && time.Since(r.started) >= r.MaxDuration
+}
+
+// finish closes the current file.
+func (r *Recorder) finish() error {
+	if r.w == nil {
+		return nil
+	}
+
+	err := r.w.Close()
+	r.w = nil
+	r.size = 0
+
+	return err
+}
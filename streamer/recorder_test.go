@@ -0,0 +1,102 @@
+package streamer
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder_Record(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	frames := make(chan []byte, 5)
+	r := NewRecorder(dir, frames)
+	r.MaxSize = 10
+
+	frame := []byte{0xff, 0xd8, 0x01, 0x02, 0xff, 0xd9}
+	for i := 0; i < 5; i++ {
+		frames <- frame
+	}
+	close(frames)
+
+	if err := r.Record(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "liveview-*.mjpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each file holds at least one frame, two frames do not fit in 10 bytes.
+	if len(files) != 5 {
+		t.Fatalf("Record() got %d files; want 5", len(files))
+	}
+	sort.Strings(files)
+	if !strings.HasSuffix(files[4], "-005.mjpeg") {
+		t.Errorf("Record() last file = %s; want it to end in -005.mjpeg", files[4])
+	}
+	got, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, frame) {
+		t.Errorf("Record() file contents = %#v; want %#v", got, frame)
+	}
+}
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRecorder_MaxDuration(t *testing.T) {
+	var bufs []*bufferCloser
+
+	frames := make(chan []byte)
+	r := NewRecorder("", frames)
+	r.MaxDuration = 20 * time.Millisecond
+	r.Encoder = func(path string) (io.WriteCloser, error) {
+		b := &bufferCloser{}
+		bufs = append(bufs, b)
+		return b, nil
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- r.Record()
+	}()
+
+	frames <- []byte{0x01}
+	frames <- []byte{0x02}
+	time.Sleep(30 * time.Millisecond)
+	frames <- []byte{0x03}
+	close(frames)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(bufs) != 2 {
+		t.Fatalf("Record() got %d recordings; want 2", len(bufs))
+	}
+	if !bytes.Equal(bufs[0].Bytes(), []byte{0x01, 0x02}) {
+		t.Errorf("Record() first recording = %#v; want %#v", bufs[0].Bytes(), []byte{0x01, 0x02})
+	}
+	if !bufs[0].closed || !bufs[1].closed {
+		t.Error("Record() recordings not closed; want closed")
+	}
+}
@@ -0,0 +1,186 @@
+package streamer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// rtpPayloadTypeJPEG is the static RTP payload type assigned to JPEG by RFC 3551.
+	rtpPayloadTypeJPEG = 26
+	// rtpClockRate is the clock rate used for video RTP timestamps.
+	rtpClockRate = 90000
+	// rtpMaxPayloadSize is the maximum size of the payload of a single RTP packet. It keeps the packets below the
+	// typical MTU of 1500 bytes.
+	rtpMaxPayloadSize = 1400
+)
+
+// jpegFrame holds the parts of a baseline JPEG image needed to send it using the RTP/JPEG payload format described in
+// RFC 2435.
+type jpegFrame struct {
+	// typ is the RTP/JPEG type: 0 for 4:2:2 and 1 for 4:2:0 chroma subsampling, with 64 added when restart markers are
+	// being used.
+	typ             uint8
+	width           uint16
+	height          uint16
+	qTables         []byte
+	restartInterval uint16
+	scan            []byte
+}
+
+// parseJPEG extracts the data required to send a baseline JPEG image over RTP. The Huffman tables are not transmitted
+// by RFC 2435 so the image is expected to use the standard tables, which virtually every camera does.
+func parseJPEG(b []byte) (*jpegFrame, error) {
+	if len(b) < 4 || b[0] != 0xff || b[1] != 0xd8 {
+		return nil, errors.New("not a JPEG image")
+	}
+
+	f := &jpegFrame{}
+	tables := make(map[uint8][]byte)
+	i := 2
+	for i+4 <= len(b) {
+		if b[i] != 0xff {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", i)
+		}
+		marker := b[i+1]
+		if marker == 0xff {
+			// Fill byte.
+			i++
+			continue
+		}
+		l := int(binary.BigEndian.Uint16(b[i+2 : i+4]))
+		if i+2+l > len(b) {
+			return nil, fmt.Errorf("JPEG segment %#x exceeds image size", marker)
+		}
+		seg := b[i+4 : i+2+l]
+
+		switch marker {
+		case 0xdb: // DQT
+			for len(seg) > 0 {
+				if seg[0]>>4 != 0 {
+					return nil, errors.New("only 8 bit quantization tables are supported")
+				}
+				if len(seg) < 65 {
+					return nil, errors.New("truncated quantization table")
+				}
+				tables[seg[0]&0x0f] = seg[1:65]
+				seg = seg[65:]
+			}
+		case 0xc0: // SOF0
+			if len(seg) < 15 || seg[5] != 3 {
+				return nil, errors.New("only baseline JPEG images with three components are supported")
+			}
+			f.height = binary.BigEndian.Uint16(seg[1:3])
+			f.width = binary.BigEndian.Uint16(seg[3:5])
+			switch seg[7] {
+			case 0x21:
+				f.typ = 0
+			case 0x22:
+				f.typ = 1
+			default:
+				return nil, fmt.Errorf("unsupported chroma subsampling %#x", seg[7])
+			}
+		case 0xc1, 0xc2, 0xc3, 0xc5, 0xc6, 0xc7, 0xc9, 0xca, 0xcb, 0xcd, 0xce, 0xcf:
+			return nil, errors.New("only baseline JPEG images are supported")
+		case 0xdd: // DRI
+			if len(seg) < 2 {
+				return nil, errors.New("truncated restart interval")
+			}
+			f.restartInterval = binary.BigEndian.Uint16(seg[0:2])
+		case 0xda: // SOS
+			end := len(b)
+			if b[end-2] == 0xff && b[end-1] == 0xd9 {
+				end -= 2
+			}
+			f.scan = b[i+2+l : end]
+
+			if f.width == 0 {
+				return nil, errors.New("JPEG image has no frame header")
+			}
+			if f.width > 2040 || f.height > 2040 {
+				return nil, fmt.Errorf("JPEG image too large for RTP: %dx%d", f.width, f.height)
+			}
+			for t := uint8(0); t < 2; t++ {
+				q, ok := tables[t]
+				if !ok {
+					return nil, fmt.Errorf("missing quantization table %d", t)
+				}
+				f.qTables = append(f.qTables, q...)
+			}
+			if f.restartInterval > 0 {
+				f.typ += 64
+			}
+
+			return f, nil
+		}
+
+		i += 2 + l
+	}
+
+	return nil, errors.New("JPEG image has no scan data")
+}
+
+// rtpPacketizer splits JPEG images into RTP packets.
+type rtpPacketizer struct {
+	seq  uint16
+	ssrc uint32
+}
+
+// packetize returns the RTP packets for a single JPEG frame with the given 90kHz timestamp. The marker bit is set on the
+// last packet of the frame.
+func (p *rtpPacketizer) packetize(f *jpegFrame, ts uint32) [][]byte {
+	var pkts [][]byte
+
+	for off := 0; off < len(f.scan) || off == 0; {
+		// RFC 2435 main JPEG header.
+		hdr := make([]byte, 8, 8+4+4+len(f.qTables))
+		binary.BigEndian.PutUint32(hdr[0:4], uint32(off)&0x00ffffff)
+		hdr[4] = f.typ
+		hdr[5] = 255 // Q values 128-255 indicate the quantization tables are sent in-band.
+		hdr[6] = uint8(f.width / 8)
+		hdr[7] = uint8(f.height / 8)
+
+		if f.restartInterval > 0 {
+			rst := make([]byte, 4)
+			binary.BigEndian.PutUint16(rst[0:2], f.restartInterval)
+			// Set both the first and last bits and a restart count of 0x3fff: the packet may hold any number of
+			// restart intervals.
+			binary.BigEndian.PutUint16(rst[2:4], 0xffff)
+			hdr = append(hdr, rst...)
+		}
+
+		if off == 0 {
+			qh := []byte{0, 0, 0, 0}
+			binary.BigEndian.PutUint16(qh[2:4], uint16(len(f.qTables)))
+			hdr = append(append(hdr, qh...), f.qTables...)
+		}
+
+		n := rtpMaxPayloadSize - len(hdr)
+		if n > len(f.scan)-off {
+			n = len(f.scan) - off
+		}
+		last := off+n >= len(f.scan)
+
+		pkt := make([]byte, 12, 12+len(hdr)+n)
+		pkt[0] = 0x80 // Version 2, no padding, no extension, no CSRC.
+		pkt[1] = rtpPayloadTypeJPEG
+		if last {
+			pkt[1] |= 0x80
+		}
+		binary.BigEndian.PutUint16(pkt[2:4], p.seq)
+		binary.BigEndian.PutUint32(pkt[4:8], ts)
+		binary.BigEndian.PutUint32(pkt[8:12], p.ssrc)
+		pkt = append(append(pkt, hdr...), f.scan[off:off+n]...)
+
+		pkts = append(pkts, pkt)
+		p.seq++
+		off += n
+
+		if last {
+			break
+		}
+	}
+
+	return pkts
+}
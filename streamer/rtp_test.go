@@ -0,0 +1,95 @@
+package streamer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestParseJPEG(t *testing.T) {
+	f, err := parseJPEG(testJPEG(t, 64, 48))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.width != 64 || f.height != 48 {
+		t.Errorf("parseJPEG() size = %dx%d; want 64x48", f.width, f.height)
+	}
+	// The standard library encodes using 4:2:0 chroma subsampling.
+	if f.typ != 1 {
+		t.Errorf("parseJPEG() typ = %d; want 1", f.typ)
+	}
+	if len(f.qTables) != 128 {
+		t.Errorf("parseJPEG() qTables length = %d; want 128", len(f.qTables))
+	}
+	if len(f.scan) == 0 {
+		t.Error("parseJPEG() scan is empty")
+	}
+
+	if _, err := parseJPEG([]byte{0x00, 0x01, 0x02, 0x03}); err == nil {
+		t.Error("parseJPEG() error = nil; want error")
+	}
+}
+
+func TestRtpPacketizer_packetize(t *testing.T) {
+	f, err := parseJPEG(testJPEG(t, 640, 480))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &rtpPacketizer{seq: 0xfffe, ssrc: 0x01020304}
+	pkts := p.packetize(f, 9000)
+	if len(pkts) < 2 {
+		t.Fatalf("packetize() got %d packets; want at least 2", len(pkts))
+	}
+
+	var scan []byte
+	for i, pkt := range pkts {
+		if pkt[0] != 0x80 {
+			t.Errorf("packetize() packet %d version byte = %#x; want 0x80", i, pkt[0])
+		}
+		marker := pkt[1]&0x80 != 0
+		if marker != (i == len(pkts)-1) {
+			t.Errorf("packetize() packet %d marker = %v; want %v", i, marker, i == len(pkts)-1)
+		}
+		if pt := pkt[1] & 0x7f; pt != rtpPayloadTypeJPEG {
+			t.Errorf("packetize() packet %d payload type = %d; want %d", i, pt, rtpPayloadTypeJPEG)
+		}
+		if seq := binary.BigEndian.Uint16(pkt[2:4]); seq != uint16(0xfffe+i) {
+			t.Errorf("packetize() packet %d sequence = %d; want %d", i, seq, uint16(0xfffe+i))
+		}
+		if ts := binary.BigEndian.Uint32(pkt[4:8]); ts != 9000 {
+			t.Errorf("packetize() packet %d timestamp = %d; want 9000", i, ts)
+		}
+
+		off := binary.BigEndian.Uint32(pkt[12:16]) & 0x00ffffff
+		if int(off) != len(scan) {
+			t.Errorf("packetize() packet %d fragment offset = %d; want %d", i, off, len(scan))
+		}
+		if pkt[18] != 80 || pkt[19] != 60 {
+			t.Errorf("packetize() packet %d size = %dx%d; want 80x60", i, pkt[18], pkt[19])
+		}
+		payload := pkt[20:]
+		if i == 0 {
+			if l := binary.BigEndian.Uint16(payload[2:4]); l != 128 {
+				t.Errorf("packetize() quantization table length = %d; want 128", l)
+			}
+			payload = payload[4+128:]
+		}
+		scan = append(scan, payload...)
+	}
+
+	if !bytes.Equal(scan, f.scan) {
+		t.Error("packetize() reassembled scan data does not match the original")
+	}
+}
@@ -0,0 +1,379 @@
+package streamer
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rtspVersion = "RTSP/1.0"
+	rtspTrack   = "track0"
+)
+
+// RTSPServer serves JPEG frames as an RTP/JPEG stream (RFC 2435) using RTSP (RFC 2326) so that standard video tooling
+// such as VLC, ffmpeg or an NVR can consume the liveview. The RTP packets are sent over UDP or interleaved on the RTSP
+// connection, depending on what the client asks for.
+type RTSPServer struct {
+	addr     string
+	frames   <-chan []byte
+	ln       net.Listener
+	sessions map[*rtspSession]struct{}
+	start    time.Time
+	mu       sync.Mutex
+	closed   chan struct{}
+}
+
+// NewRTSPServer creates a new RTSP server that will listen on the given address and stream the frames received on the
+// given channel. Call ListenAndServe to start streaming.
+func NewRTSPServer(addr string, frames <-chan []byte) *RTSPServer {
+	return &RTSPServer{
+		addr:     addr,
+		frames:   frames,
+		sessions: make(map[*rtspSession]struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Addr returns the address the server is listening on. It returns an empty string when the server is not listening
+// yet.
+func (s *RTSPServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ln == nil {
+		return ""
+	}
+
+	return s.ln.Addr().String()
+}
+
+// Listen opens the listener for the server without serving any requests yet.
+func (s *RTSPServer) Listen() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ListenAndServe starts distributing the frames to the playing clients and serves RTSP requests. It blocks until the
+// frame channel is closed or Close is called.
+func (s *RTSPServer) ListenAndServe() error {
+	if s.Addr() == "" {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	s.start = time.Now()
+	go s.distribute()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			select {
+			case <-s.closed:
+				return nil
+			default:
+				return err
+			}
+		}
+		go s.serve(conn)
+	}
+}
+
+// Close stops the server and disconnects all clients.
+func (s *RTSPServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.closed:
+		return nil
+	default:
+		close(s.closed)
+	}
+
+	for sess := range s.sessions {
+		sess.close()
+	}
+
+	if s.ln == nil {
+		return nil
+	}
+
+	return s.ln.Close()
+}
+
+// distribute packetizes each frame once and sends the packets to all playing sessions. The server is closed when the
+// frame channel is closed.
+func (s *RTSPServer) distribute() {
+	p := &rtpPacketizer{ssrc: randomUint32()}
+	for frame := range s.frames {
+		f, err := parseJPEG(frame)
+		if err != nil {
+			// Frames that cannot be sent using RTP/JPEG, e.g. progressive JPEGs, are dropped.
+			continue
+		}
+		ts := uint32(time.Since(s.start).Seconds() * rtpClockRate)
+		pkts := p.packetize(f, ts)
+
+		s.mu.Lock()
+		for sess := range s.sessions {
+			if sess.isPlaying() {
+				sess.send(pkts)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.Close()
+}
+
+// serve handles the RTSP requests of a single client connection.
+func (s *RTSPServer) serve(conn net.Conn) {
+	sess := &rtspSession{conn: conn, id: randomHex(8)}
+	s.mu.Lock()
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+		sess.close()
+	}()
+
+	r := bufio.NewReader(conn)
+	tp := textproto.NewReader(r)
+	for {
+		// Clients sending RTP over TCP will interleave their RTCP reports with the requests, we simply skip those.
+		b, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		if b[0] == '$' {
+			hdr := make([]byte, 4)
+			if _, err := io.ReadFull(r, hdr); err != nil {
+				return
+			}
+			if _, err := r.Discard(int(binary.BigEndian.Uint16(hdr[2:4]))); err != nil {
+				return
+			}
+			continue
+		}
+
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		if line == "" {
+			continue
+		}
+		hdr, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) != 3 {
+			return
+		}
+		if !s.handle(sess, parts[0], parts[1], hdr) {
+			return
+		}
+	}
+}
+
+// handle processes a single request and returns false when the connection must be closed.
+func (s *RTSPServer) handle(sess *rtspSession, method, url string, hdr textproto.MIMEHeader) bool {
+	res := textproto.MIMEHeader{}
+	res.Set("CSeq", hdr.Get("CSeq"))
+
+	var body string
+	status := "200 OK"
+	switch method {
+	case "OPTIONS":
+		res.Set("Public", "OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, GET_PARAMETER")
+	case "DESCRIBE":
+		host, _, _ := net.SplitHostPort(sess.conn.LocalAddr().String())
+		body = "v=0\r\n" +
+			"o=- 0 0 IN IP4 " + host + "\r\n" +
+			"s=PTP/IP liveview\r\n" +
+			"c=IN IP4 0.0.0.0\r\n" +
+			"t=0 0\r\n" +
+			"m=video 0 RTP/AVP " + strconv.Itoa(rtpPayloadTypeJPEG) + "\r\n" +
+			"a=control:" + rtspTrack + "\r\n"
+		res.Set("Content-Base", strings.TrimSuffix(url, "/")+"/")
+		res.Set("Content-Type", "application/sdp")
+	case "SETUP":
+		transport, err := sess.setup(hdr.Get("Transport"))
+		if err != nil {
+			status = "461 Unsupported Transport"
+			break
+		}
+		res.Set("Transport", transport)
+		res.Set("Session", sess.id)
+	case "PLAY":
+		sess.play()
+		res.Set("Session", sess.id)
+		res.Set("Range", "npt=0.000-")
+	case "GET_PARAMETER":
+		res.Set("Session", sess.id)
+	case "TEARDOWN":
+		res.Set("Session", sess.id)
+		sess.writeResponse(status, res, body)
+		return false
+	default:
+		status = "405 Method Not Allowed"
+	}
+
+	return sess.writeResponse(status, res, body) == nil
+}
+
+// rtspSession holds the state of a single client.
+type rtspSession struct {
+	id          string
+	conn        net.Conn
+	udp         net.Conn
+	interleaved bool
+	channel     uint8
+	playing     bool
+	mu          sync.Mutex
+}
+
+// setup configures the transport requested by the client and returns the transport header to respond with.
+func (sess *rtspSession) setup(transport string) (string, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	params := strings.Split(transport, ";")
+	if strings.HasPrefix(params[0], "RTP/AVP/TCP") {
+		sess.interleaved = true
+		for _, p := range params {
+			if strings.HasPrefix(p, "interleaved=") {
+				ch, err := strconv.Atoi(strings.Split(strings.TrimPrefix(p, "interleaved="), "-")[0])
+				if err != nil {
+					return "", err
+				}
+				sess.channel = uint8(ch)
+			}
+		}
+
+		return fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", sess.channel, sess.channel+1), nil
+	}
+
+	if !strings.HasPrefix(params[0], "RTP/AVP") {
+		return "", fmt.Errorf("unsupported transport %q", transport)
+	}
+
+	for _, p := range params {
+		if strings.HasPrefix(p, "client_port=") {
+			port := strings.Split(strings.TrimPrefix(p, "client_port="), "-")[0]
+			host, _, _ := net.SplitHostPort(sess.conn.RemoteAddr().String())
+			conn, err := net.Dial("udp", net.JoinHostPort(host, port))
+			if err != nil {
+				return "", err
+			}
+			sess.udp = conn
+			_, sport, _ := net.SplitHostPort(conn.LocalAddr().String())
+
+			return fmt.Sprintf("RTP/AVP;unicast;%s;server_port=%s-%s", p, sport, sport), nil
+		}
+	}
+
+	return "", fmt.Errorf("no client port in transport %q", transport)
+}
+
+func (sess *rtspSession) play() {
+	sess.mu.Lock()
+	sess.playing = true
+	sess.mu.Unlock()
+}
+
+func (sess *rtspSession) isPlaying() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.playing
+}
+
+// send writes the RTP packets to the client. Write errors are ignored: a client that went away will be cleaned up when
+// its RTSP connection is closed.
+func (sess *rtspSession) send(pkts [][]byte) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	for _, pkt := range pkts {
+		if sess.interleaved {
+			hdr := []byte{'$', sess.channel, 0, 0}
+			binary.BigEndian.PutUint16(hdr[2:4], uint16(len(pkt)))
+			sess.conn.SetWriteDeadline(time.Now().Add(time.Second))
+			if _, err := sess.conn.Write(append(hdr, pkt...)); err != nil {
+				return
+			}
+		} else if sess.udp != nil {
+			sess.udp.Write(pkt)
+		}
+	}
+}
+
+func (sess *rtspSession) writeResponse(status string, hdr textproto.MIMEHeader, body string) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	res := rtspVersion + " " + status + "\r\n"
+	for k, v := range hdr {
+		res += k + ": " + v[0] + "\r\n"
+	}
+	if body != "" {
+		res += "Content-Length: " + strconv.Itoa(len(body)) + "\r\n"
+	}
+	res += "\r\n" + body
+
+	sess.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_, err := io.WriteString(sess.conn, res)
+
+	return err
+}
+
+func (sess *rtspSession) close() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.playing = false
+	if sess.udp != nil {
+		sess.udp.Close()
+		sess.udp = nil
+	}
+	sess.conn.Close()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func randomUint32() uint32 {
+	b := make([]byte, 4)
+	rand.Read(b)
+
+	return binary.BigEndian.Uint32(b)
+}
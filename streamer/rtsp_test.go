@@ -0,0 +1,119 @@
+package streamer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rtspRequest(t *testing.T, conn net.Conn, r *textproto.Reader, method, url string, cseq int, hdr string) (string, textproto.MIMEHeader) {
+	if _, err := fmt.Fprintf(conn, "%s %s RTSP/1.0\r\nCSeq: %d\r\n%s\r\n", method, url, cseq, hdr); err != nil {
+		t.Fatal(err)
+	}
+	status, err := r.ReadLine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Get("CSeq"); got != fmt.Sprint(cseq) {
+		t.Errorf("%s CSeq = %s; want %d", method, got, cseq)
+	}
+
+	return status, res
+}
+
+func TestRTSPServer_interleaved(t *testing.T) {
+	frames := make(chan []byte)
+	s := NewRTSPServer("127.0.0.1:0", frames)
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- s.ListenAndServe()
+	}()
+
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	br := bufio.NewReader(conn)
+	r := textproto.NewReader(br)
+	url := "rtsp://" + s.Addr() + "/"
+
+	status, res := rtspRequest(t, conn, r, "OPTIONS", url, 1, "")
+	if status != "RTSP/1.0 200 OK" {
+		t.Errorf("OPTIONS status = %s; want RTSP/1.0 200 OK", status)
+	}
+	if !strings.Contains(res.Get("Public"), "PLAY") {
+		t.Errorf("OPTIONS Public = %s; want it to contain PLAY", res.Get("Public"))
+	}
+
+	_, res = rtspRequest(t, conn, r, "DESCRIBE", url, 2, "Accept: application/sdp\r\n")
+	l, err := strconv.Atoi(res.Get("Content-Length"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdp := make([]byte, l)
+	if _, err := io.ReadFull(br, sdp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sdp), "m=video 0 RTP/AVP 26") {
+		t.Errorf("DESCRIBE sdp = %s; want it to contain a JPEG video stream", sdp)
+	}
+
+	_, res = rtspRequest(t, conn, r, "SETUP", url+rtspTrack, 3, "Transport: RTP/AVP/TCP;unicast;interleaved=0-1\r\n")
+	if got := res.Get("Transport"); got != "RTP/AVP/TCP;unicast;interleaved=0-1" {
+		t.Errorf("SETUP Transport = %s; want RTP/AVP/TCP;unicast;interleaved=0-1", got)
+	}
+	session := res.Get("Session")
+	if session == "" {
+		t.Fatal("SETUP Session is empty")
+	}
+
+	status, _ = rtspRequest(t, conn, r, "PLAY", url, 4, "Session: "+session+"\r\n")
+	if status != "RTSP/1.0 200 OK" {
+		t.Errorf("PLAY status = %s; want RTSP/1.0 200 OK", status)
+	}
+
+	frames <- testJPEG(t, 64, 48)
+
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		t.Fatal(err)
+	}
+	if hdr[0] != '$' || hdr[1] != 0 {
+		t.Fatalf("interleaved header = %#v; want '$' on channel 0", hdr)
+	}
+	pkt := make([]byte, binary.BigEndian.Uint16(hdr[2:4]))
+	if _, err := io.ReadFull(br, pkt); err != nil {
+		t.Fatal(err)
+	}
+	if pt := pkt[1] & 0x7f; pt != rtpPayloadTypeJPEG {
+		t.Errorf("RTP payload type = %d; want %d", pt, rtpPayloadTypeJPEG)
+	}
+
+	close(frames)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ListenAndServe() error = %s; want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("ListenAndServe() did not return after closing the frame channel")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	ptpfmt "github.com/malc0mn/ptp-ip/fmt"
 	"github.com/malc0mn/ptp-ip/ip"
 	"github.com/malc0mn/ptp-ip/ptp"
-	"golang.org/x/image/font/basicfont"
 	"golang.org/x/image/math/fixed"
 	"image"
 	"math"
@@ -15,41 +14,87 @@ import (
 // NewFujiXT1Viewfinder returns a new Fuji X-T1 viewfinder containing a Widget list mimicking the real viewfinder.
 // The image is needed for the widgets to calibrate their origin so they can render in their own designated place.
 func NewFujiXT1Viewfinder(img *image.RGBA) *Viewfinder {
-	return &Viewfinder{
-		Widgets: map[ptp.DevicePropCode]*Widget{
-			ptp.DPC_BatteryLevel:             NewFujiBatteryLevelWidget(img),
-			ptp.DPC_CaptureDelay:             NewFujiCaptureDelayWidget(img),
-			ip.DPC_Fuji_CapturesRemaining:    NewFujiCapturesRemainingWidget(img),
-			ptp.DPC_ExposureBiasCompensation: NewFujiExposureBiasCompensationWidget(img),
-			ptp.DPC_ExposureProgramMode:      NewFujiExposureProgramModeWidget(img),
-			ip.DPC_Fuji_ExposureIndex:        NewFujiISOWidget(img),
-			ip.DPC_Fuji_FilmSimulation:       NewFujiFilmSimulationWidget(img),
-			ptp.DPC_FNumber:                  NewFujiFNumberWidget(img),
-			ip.DPC_Fuji_ImageAspectRatio:     NewFujiImageSizeWidget(img),
-			ip.DPC_Fuji_ImageQuality:         NewFujiImageQualityWidget(img),
-			ptp.DPC_WhiteBalance:             NewFujiWhiteBalanceWidget(img),
-		},
-	}
+	return FujiXT1Layout().Build(img)
 }
 
-func NewFujiBatteryLevelWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Max.X) - (float64(img.Bounds().Max.X) * 0.1)
-	y := img.Bounds().Max.Y - 8
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiBattery3Bars
-
-	return w
+// FujiXT1Layout returns the Layout mimicking the viewfinder of the Fuji X-T1.
+func FujiXT1Layout() Layout {
+	return Layout{
+		ptp.DPC_BatteryLevel: {
+			Place: Placement{Anchor: BottomRight, X: 0.1, DY: -8},
+			Draw:  drawFujiBattery3Bars,
+		},
+		ptp.DPC_CaptureDelay: {
+			Place: Placement{Anchor: TopLeft, X: 0.2, DY: 18},
+			Draw:  drawFujiCaptureDelay,
+		},
+		ip.DPC_Fuji_CapturesRemaining: {
+			Place: Placement{Anchor: TopRight, X: 0.25, DY: 18},
+			Text:  true,
+			Draw:  drawFujiCapturesRemaining,
+		},
+		ptp.DPC_ExposureBiasCompensation: {
+			// Make sure the center point of our bias widget is in the center of the image.
+			Place: Placement{Anchor: BottomCenter, DX: -VFGlyphs6x13.Width * len(getBias()) / 2, DY: -10},
+			Draw:  drawFujiExposureBiasCompensation,
+		},
+		ptp.DPC_ExposureProgramMode: {
+			Place: Placement{Anchor: BottomLeft, X: 0.1, DY: -10},
+			Draw:  drawFujiExposureProgramMode,
+		},
+		ip.DPC_Fuji_ExposureIndex: {
+			Place: Placement{Anchor: BottomRight, X: 0.2, DY: -10},
+			Draw:  drawFujiISO,
+		},
+		ip.DPC_Fuji_FilmSimulation: {
+			Place: Placement{Anchor: TopLeft, X: 0.3, DY: 18},
+			Draw:  drawFujiFilmSimulation,
+		},
+		ptp.DPC_ExposureTime: {
+			// Between the exposure program mode and the aperture, like the real thing.
+			Place: Placement{Anchor: BottomLeft, X: 0.16, DY: -10},
+			Text:  true,
+			Draw:  drawFujiShutterSpeed,
+		},
+		ptp.DPC_FNumber: {
+			Place: Placement{Anchor: BottomLeft, X: 0.25, DY: -10},
+			Text:  true,
+			Draw:  drawFujiFNumber,
+		},
+		ip.DPC_Fuji_FocusMeteringMode: {
+			// The focus point is drawn where it is on the frame, the placement is not used.
+			Draw: drawFujiFocusPoint,
+		},
+		ip.DPC_Fuji_ImageAspectRatio: {
+			// Right next to the image quality widget.
+			Place: Placement{Anchor: TopRight, X: 0.15, DX: VFGlyphs6x13.Width*3 + 1, DY: 18},
+			Draw:  drawFujiImageSize,
+		},
+		ip.DPC_Fuji_MovieRemainingTime: {
+			// Right below the captures remaining.
+			Place: Placement{Anchor: TopRight, X: 0.25, DY: 34},
+			Text:  true,
+			Draw:  DrawRecordingTime,
+		},
+		ip.DPC_Fuji_ImageQuality: {
+			Place: Placement{Anchor: TopRight, X: 0.15, DY: 18},
+			Draw:  drawFujiImageQuality,
+		},
+		ptp.DPC_WhiteBalance: {
+			Place: Placement{Anchor: TopLeft, X: 0.26, DY: 18},
+			Draw:  drawFujiWhiteBalance,
+		},
+	}
 }
 
 func drawFujiBattery3Bars(w *Widget, val int64) {
 	w.ResetToOrigin()
+	w.ResetColour()
 
 	var lvl string
 	switch ip.FujiBatteryLevel(val) {
 	case ip.BAT_Fuji_3bOne:
-		w.SetColour(255, 0, 0) // red
+		w.SetRGBA(w.Theme().Warning)
 		lvl = "baU"
 	case ip.BAT_Fuji_3bTwo:
 		lvl = "bCT"
@@ -60,17 +105,6 @@ func drawFujiBattery3Bars(w *Widget, val int64) {
 	w.DrawString(lvl)
 }
 
-func NewFujiCaptureDelayWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Min.X) + (float64(img.Bounds().Max.X) * 0.2)
-	y := 18
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiCaptureDelay
-
-	return w
-}
-
 func drawFujiCaptureDelay(w *Widget, val int64) {
 	w.ResetToOrigin()
 
@@ -86,36 +120,12 @@ func drawFujiCaptureDelay(w *Widget, val int64) {
 	w.DrawString(icon)
 }
 
-func NewFujiCapturesRemainingWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Max.X) - (float64(img.Bounds().Max.X) * 0.25)
-	y := 18
-
-	w := NewWhiteFontWidget(img, int(x), y)
-	w.Draw = drawFujiCapturesRemaining
-
-	return w
-}
-
 func drawFujiCapturesRemaining(w *Widget, val int64) {
 	w.ResetToOrigin()
 
 	w.DrawString(strconv.FormatInt(val, 10))
 }
 
-func NewFujiExposureBiasCompensationWidget(img *image.RGBA) *Widget {
-	// Make sure the center point of our bias widget is in the center of the image.
-	offset := VFGlyphs6x13.Width * len(getBias()) / 2
-
-	x := float64(img.Bounds().Max.X) - (float64(img.Bounds().Max.X) * 0.5) - float64(offset)
-	y := img.Bounds().Max.Y - 10
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiExposureBiasCompensation
-
-	return w
-}
-
 func getBias() []rune {
 	return []rune("6..5..4..0..1..2..3")
 }
@@ -131,7 +141,7 @@ func drawFujiExposureBiasCompensation(w *Widget, val int64) {
 	marker := []rune("                   ")
 
 	// Draw the leading +/- icon
-	w.Dot.X -= fixed.Int26_6(VFGlyphs6x13.Width * 3 * 64) // offset icon 3 glyphs to the left
+	w.Dot.X -= fixed.I(w.Theme().Glyphs.Advance * 3) // offset icon 3 glyphs to the left
 	w.DrawString("+-")
 	w.ResetToOrigin()
 
@@ -156,7 +166,7 @@ func drawFujiExposureBiasCompensation(w *Widget, val int64) {
 
 	// When the marker is on 0, the widget must be drawn in grey.
 	if onZero {
-		w.SetColour(100, 100, 100) // grey
+		w.SetRGBA(w.Theme().Inactive)
 	}
 
 	// Now draw the basic exposure bias compensation widget.
@@ -164,7 +174,7 @@ func drawFujiExposureBiasCompensation(w *Widget, val int64) {
 
 	// When the marker is on 0, the the marker and '0' position must be drawn in white.
 	if onZero {
-		w.SetColour(255, 255, 255) // white
+		w.SetRGBA(w.Theme().Colour)
 		for _, r := range []rune{'"', '!'} {
 			w.ResetToOrigin()
 			marker[pos] = r
@@ -176,22 +186,11 @@ func drawFujiExposureBiasCompensation(w *Widget, val int64) {
 
 	// Draw the marker on the the calculated position in yellow!
 	marker[pos] = '!'
-	w.SetColour(255, 185, 10) // yellow
+	w.SetRGBA(w.Theme().Highlight)
 	w.ResetToOrigin()
 	w.DrawString(string(marker))
 }
 
-func NewFujiExposureProgramModeWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Min.X) + (float64(img.Bounds().Max.X) * 0.1)
-	y := img.Bounds().Max.Y - 10
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiExposureProgramMode
-
-	return w
-}
-
 func drawFujiExposureProgramMode(w *Widget, val int64) {
 	w.ResetToOrigin()
 
@@ -210,17 +209,6 @@ func drawFujiExposureProgramMode(w *Widget, val int64) {
 	w.DrawString(icon)
 }
 
-func NewFujiISOWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Max.X) - (float64(img.Bounds().Max.X) * 0.2)
-	y := img.Bounds().Max.Y - 10
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiISO
-
-	return w
-}
-
 func drawFujiISO(w *Widget, val int64) {
 	w.ResetToOrigin()
 	w.ResetFace()
@@ -230,31 +218,20 @@ func drawFujiISO(w *Widget, val int64) {
 	w.DrawString("is") // iso icon
 
 	if strings.HasPrefix(iso, "S") {
-		w.Dot.X -= fixed.Int26_6(18 * 64) // offset to the left
-		w.Dot.Y -= fixed.Int26_6(8 * 64)
-		w.DrawString("ISO")              // auto icon
-		w.Dot.Y += fixed.Int26_6(8 * 64) // reset Y axis
-		iso = string([]rune(iso)[1:])    // drop the leading S
+		w.Dot.X -= w.Px(18) // offset to the left
+		w.Dot.Y -= w.Px(8)
+		w.DrawString("ISO")           // auto icon
+		w.Dot.Y += w.Px(8)            // reset Y axis
+		iso = string([]rune(iso)[1:]) // drop the leading S
 	}
 
-	w.Face = basicfont.Face7x13
-	w.Dot.X += fixed.Int26_6(6 * 64)
-	w.Dot.Y += fixed.Int26_6(2 * 64)
+	w.Face = w.Theme().Text
+	w.Dot.X += w.Px(6)
+	w.Dot.Y += w.Px(2)
 
 	w.DrawString(iso) // actual value
 }
 
-func NewFujiFilmSimulationWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Min.X) + (float64(img.Bounds().Max.X) * 0.3)
-	y := 18
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiFilmSimulation
-
-	return w
-}
-
 func drawFujiFilmSimulation(w *Widget, val int64) {
 	w.ResetToOrigin()
 
@@ -288,15 +265,34 @@ func drawFujiFilmSimulation(w *Widget, val int64) {
 	w.DrawString(flm)
 }
 
-func NewFujiFNumberWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Min.X) + (float64(img.Bounds().Max.X) * 0.25)
-	y := img.Bounds().Max.Y - 10
+func drawFujiFocusPoint(w *Widget, val int64) {
+	if r, ok := fujiFocusPoint(val, w.Dst.Bounds()); ok {
+		DrawOutline(w.Dst, r, w.Theme().Colour, w.Theme().Scale)
+	}
+}
 
-	w := NewWhiteFontWidget(img, int(x), y)
-	w.Draw = drawFujiFNumber
+const (
+	// fujiFocusGrid is the number of focus points of the Fuji focus point grid, horizontally as well as vertically.
+	fujiFocusGrid = 7
+	// fujiFocusArea is the fraction of the frame width and height covered by the focus point grid.
+	fujiFocusArea = 0.8
+)
 
-	return w
+// fujiFocusPoint returns the area covered by the focus point within the frame bounds b. The least significant byte of
+// the value holds the row of the focus point and the next byte its column, both counting from 1, as formatted by
+// ptpfmt.FujiFocusMeteringModeAsString. False is returned when the value does not hold a valid focus point.
+func fujiFocusPoint(val int64, b image.Rectangle) (image.Rectangle, bool) {
+	x, y := int(val>>8&0xFF), int(val&0xFF)
+	if x < 1 || x > fujiFocusGrid || y < 1 || y > fujiFocusGrid {
+		return image.Rectangle{}, false
+	}
+
+	cw := float64(b.Dx()) * fujiFocusArea / fujiFocusGrid
+	ch := float64(b.Dy()) * fujiFocusArea / fujiFocusGrid
+	left := float64(b.Min.X) + float64(b.Dx())*(1-fujiFocusArea)/2 + float64(x-1)*cw
+	top := float64(b.Min.Y) + float64(b.Dy())*(1-fujiFocusArea)/2 + float64(y-1)*ch
+
+	return image.Rect(int(left), int(top), int(left+cw), int(top+ch)), true
 }
 
 func drawFujiFNumber(w *Widget, val int64) {
@@ -305,15 +301,21 @@ func drawFujiFNumber(w *Widget, val int64) {
 	w.DrawString(strings.Replace(ptpfmt.FNumberAsString(uint16(val)), "f/", "F", 1))
 }
 
-func NewFujiImageSizeWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Max.X) - (float64(img.Bounds().Max.X) * 0.15) + float64(VFGlyphs6x13.Width*3) + 1
-	y := 18
+func drawFujiShutterSpeed(w *Widget, val int64) {
+	w.ResetToOrigin()
+
+	w.DrawString(fujiShutterSpeed(val))
+}
 
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiImageSize
+// fujiShutterSpeed formats the shutter speed the way the Fuji viewfinder does: fractions of a second are shown by
+// their denominator only, e.g. 250 for 1/250, and seconds are followed by a double quote, e.g. 2" for two seconds.
+func fujiShutterSpeed(val int64) string {
+	ss := ptpfmt.ExposureTimeAsString(uint32(val))
+	if d := strings.TrimPrefix(ss, "1/"); d != ss {
+		return d
+	}
 
-	return w
+	return strings.Replace(ss, "s", `"`, 1)
 }
 
 func drawFujiImageSize(w *Widget, val int64) {
@@ -333,17 +335,6 @@ func drawFujiImageSize(w *Widget, val int64) {
 	w.DrawString(icon)
 }
 
-func NewFujiImageQualityWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Max.X) - (float64(img.Bounds().Max.X) * 0.15)
-	y := 18
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiImageQuality
-
-	return w
-}
-
 func drawFujiImageQuality(w *Widget, val int64) {
 	w.ResetToOrigin()
 	w.ResetFace()
@@ -365,21 +356,10 @@ func drawFujiImageQuality(w *Widget, val int64) {
 	}
 
 	w.DrawString(icon)
-	w.Face = basicfont.Face7x13
+	w.Face = w.Theme().Text
 	w.DrawString("   " + qual)
 }
 
-func NewFujiWhiteBalanceWidget(img *image.RGBA) *Widget {
-	// Calculate starting position.
-	x := float64(img.Bounds().Min.X) + (float64(img.Bounds().Max.X) * 0.26)
-	y := 18
-
-	w := NewWhiteGlyphWidget(img, int(x), y)
-	w.Draw = drawFujiWhiteBalance
-
-	return w
-}
-
 func drawFujiWhiteBalance(w *Widget, val int64) {
 	w.ResetToOrigin()
 
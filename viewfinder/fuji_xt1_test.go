@@ -0,0 +1,44 @@
+package viewfinder
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFujiShutterSpeed(t *testing.T) {
+	check := map[int64]string{
+		0:      "",
+		40:     "250",
+		2500:   "4",
+		10000:  `1"`,
+		25000:  `2.5"`,
+		300000: `30"`,
+	}
+
+	for in, want := range check {
+		if got := fujiShutterSpeed(in); got != want {
+			t.Errorf("fujiShutterSpeed(%d) got = %s; want %s", in, got, want)
+		}
+	}
+}
+
+func TestFujiFocusPoint(t *testing.T) {
+	b := image.Rect(0, 0, 700, 700)
+	check := []struct {
+		val  int64
+		want image.Rectangle
+		ok   bool
+	}{
+		{0x03020702, image.Rect(550, 150, 630, 230), true},
+		{0x00000101, image.Rect(70, 70, 150, 150), true},
+		{0x00040404, image.Rect(310, 310, 390, 390), true},
+		{0x00000000, image.Rectangle{}, false},
+		{0x00000801, image.Rectangle{}, false},
+	}
+
+	for _, tt := range check {
+		if got, ok := fujiFocusPoint(tt.val, b); got != tt.want || ok != tt.ok {
+			t.Errorf("fujiFocusPoint(%#x) got = %v, %t; want %v, %t", tt.val, got, ok, tt.want, tt.ok)
+		}
+	}
+}
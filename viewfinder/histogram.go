@@ -0,0 +1,127 @@
+package viewfinder
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// HistogramMode defines which histograms are computed and drawn.
+type HistogramMode int
+
+const (
+	// HistogramOff disables the histogram.
+	HistogramOff HistogramMode = iota
+	// HistogramLuma computes the luminance histogram only.
+	HistogramLuma
+	// HistogramRGB computes a histogram for each of the red, green and blue channels on top of the luminance one.
+	HistogramRGB
+)
+
+// histogramSampleStep is the distance in pixels between two sampled pixels, horizontally as well as vertically. Only
+// sampling one pixel out of 16 gives an accurate enough histogram at a fraction of the cost.
+const histogramSampleStep = 4
+
+// Histogram holds the pixel count for each of the 256 possible values of the luminance and, optionally, the red, green
+// and blue channels.
+type Histogram struct {
+	Mode HistogramMode
+	Luma [256]uint32
+	R    [256]uint32
+	G    [256]uint32
+	B    [256]uint32
+}
+
+// NewHistogram computes the histogram of the given image using the given mode. It returns nil when the mode is
+// HistogramOff.
+func NewHistogram(img *image.RGBA, mode HistogramMode) *Histogram {
+	if mode == HistogramOff {
+		return nil
+	}
+
+	h := &Histogram{Mode: mode}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y += histogramSampleStep {
+		for x := b.Min.X; x < b.Max.X; x += histogramSampleStep {
+			i := img.PixOffset(x, y)
+			r, g, bl := img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+			// ITU-R BT.601 luma using integer maths: 0.299 R + 0.587 G + 0.114 B.
+			h.Luma[(19595*uint32(r)+38470*uint32(g)+7471*uint32(bl)+1<<15)>>16]++
+			if mode == HistogramRGB {
+				h.R[r]++
+				h.G[g]++
+				h.B[bl]++
+			}
+		}
+	}
+
+	return h
+}
+
+// max returns the highest count found in the histograms, used to scale them.
+func (h *Histogram) max() uint32 {
+	var m uint32
+	for i := 0; i < 256; i++ {
+		for _, v := range []uint32{h.Luma[i], h.R[i], h.G[i], h.B[i]} {
+			if v > m {
+				m = v
+			}
+		}
+	}
+
+	return m
+}
+
+// DrawHistogram draws the histogram onto the given image in the lower left corner, on a translucent background. The
+// histogram takes up a quarter of the image width.
+func DrawHistogram(img *image.RGBA, h *Histogram) {
+	if h == nil {
+		return
+	}
+
+	b := img.Bounds()
+	w := b.Dx() / 4
+	if w < 64 {
+		w = 64
+	}
+	ht := w / 2
+	margin := b.Dx() / 40
+	r := image.Rect(b.Min.X+margin, b.Max.Y-margin-ht, b.Min.X+margin+w, b.Max.Y-margin).Intersect(b)
+	if r.Empty() {
+		return
+	}
+
+	draw.Draw(img, r, image.NewUniform(color.RGBA{A: 128}), image.Point{}, draw.Over)
+
+	m := h.max()
+	if m == 0 {
+		return
+	}
+
+	if h.Mode == HistogramRGB {
+		drawChannel(img, r, &h.R, m, color.RGBA{R: 160, A: 160})
+		drawChannel(img, r, &h.G, m, color.RGBA{G: 160, A: 160})
+		drawChannel(img, r, &h.B, m, color.RGBA{B: 160, A: 160})
+	}
+	drawChannel(img, r, &h.Luma, m, color.RGBA{R: 200, G: 200, B: 200, A: 200})
+}
+
+// drawChannel draws a single histogram channel as vertical bars scaled to fit the given rectangle.
+func drawChannel(img *image.RGBA, r image.Rectangle, counts *[256]uint32, max uint32, c color.RGBA) {
+	src := image.NewUniform(c)
+	for x := 0; x < r.Dx(); x++ {
+		// Each column shows the highest count of the bins it covers so that narrow spikes do not go unnoticed.
+		var v uint32
+		for bin := x * 256 / r.Dx(); bin < (x+1)*256/r.Dx() || bin == x*256/r.Dx(); bin++ {
+			if counts[bin] > v {
+				v = counts[bin]
+			}
+		}
+		bar := int(uint64(v) * uint64(r.Dy()) / uint64(max))
+		if bar == 0 {
+			continue
+		}
+		col := image.Rect(r.Min.X+x, r.Max.Y-bar, r.Min.X+x+1, r.Max.Y)
+		draw.Draw(img, col, src, image.Point{}, draw.Over)
+	}
+}
@@ -0,0 +1,150 @@
+package viewfinder
+
+import (
+	"github.com/malc0mn/ptp-ip/ptp"
+	"golang.org/x/image/font/basicfont"
+	"image"
+	"image/color"
+)
+
+// Anchor defines the point of the image a widget is positioned against.
+type Anchor int
+
+const (
+	TopLeft Anchor = iota
+	TopCenter
+	TopRight
+	BottomLeft
+	BottomCenter
+	BottomRight
+)
+
+// Placement declares the position of a widget relative to the image it is drawn on, so that the position adapts to the
+// size of the liveview frames.
+// X and Y are fractions of the image width and height measured from the anchor towards the opposite side of the image.
+// For centered anchors, X is measured from the center to the right. DX and DY are pixel offsets added afterwards: a
+// positive DX moves the widget to the right and a positive DY moves it down, whatever the anchor.
+// Keep in mind that the Y coordinate is the baseline of the text being drawn.
+type Placement struct {
+	Anchor Anchor
+	X      float64
+	Y      float64
+	DX     int
+	DY     int
+}
+
+// Point calculates the starting position within the given bounds.
+func (p Placement) Point(b image.Rectangle) image.Point {
+	var x, y int
+
+	switch p.Anchor {
+	case TopLeft, BottomLeft:
+		x = b.Min.X + int(float64(b.Dx())*p.X)
+	case TopCenter, BottomCenter:
+		x = b.Min.X + b.Dx()/2 + int(float64(b.Dx())*p.X)
+	case TopRight, BottomRight:
+		x = b.Max.X - int(float64(b.Dx())*p.X)
+	}
+
+	switch p.Anchor {
+	case TopLeft, TopCenter, TopRight:
+		y = b.Min.Y + int(float64(b.Dy())*p.Y)
+	default:
+		y = b.Max.Y - int(float64(b.Dy())*p.Y)
+	}
+
+	return image.Point{X: x + p.DX, Y: y + p.DY}
+}
+
+// scaled returns a copy of the placement with the pixel offsets multiplied by the given factor.
+func (p Placement) scaled(factor int) Placement {
+	p.DX *= factor
+	p.DY *= factor
+
+	return p
+}
+
+// WidgetSpec declares a single widget: where to place it, how it looks and how to draw the value of the device
+// property it is bound to.
+type WidgetSpec struct {
+	Place Placement
+	// Text draws the widget with the text face of the theme instead of the glyphs face.
+	Text bool
+	// Face is the font face to draw with, overriding the faces of the theme.
+	Face *basicfont.Face
+	// Colour is the colour to draw in, overriding the colour of the theme.
+	Colour color.RGBA
+	Draw   WidgetDrawer
+}
+
+// Build creates the widget for the given image using the DefaultTheme.
+func (s WidgetSpec) Build(img *image.RGBA) *Widget {
+	return s.build(img, DefaultTheme().scaled())
+}
+
+// BuildWithTheme creates the widget for the given image using the given theme.
+func (s WidgetSpec) BuildWithTheme(img *image.RGBA, t Theme) *Widget {
+	return s.build(img, t.scaled())
+}
+
+// build expects a theme that has already been scaled.
+func (s WidgetSpec) build(img *image.RGBA, t Theme) *Widget {
+	f := t.Glyphs
+	if s.Text {
+		f = t.Text
+	}
+	if s.Face != nil {
+		f = ScaleFace(s.Face, t.Scale)
+	}
+	c := s.Colour
+	if c == (color.RGBA{}) {
+		c = t.Colour
+	}
+	p := s.Place.scaled(t.Scale).Point(img.Bounds())
+
+	w := NewWidget(img, c.R, c.G, c.B, f, p.X, p.Y)
+	w.theme = t
+	w.Draw = s.Draw
+
+	return w
+}
+
+// Layout binds widget specifications to the device properties holding the values they display. Layouts are plain
+// maps, so they can be adjusted before building a Viewfinder: move a widget by changing its Placement, drop it by
+// deleting its entry or add a new one for any device property, whatever the vendor.
+type Layout map[ptp.DevicePropCode]WidgetSpec
+
+// Build creates a Viewfinder holding all widgets of the layout for the given image using the DefaultTheme.
+func (l Layout) Build(img *image.RGBA) *Viewfinder {
+	return l.BuildWithTheme(img, DefaultTheme())
+}
+
+// BuildWithTheme creates a Viewfinder holding all widgets of the layout for the given image using the given theme.
+func (l Layout) BuildWithTheme(img *image.RGBA, t Theme) *Viewfinder {
+	t = t.scaled()
+	vf := &Viewfinder{Widgets: make(map[ptp.DevicePropCode]*Widget, len(l))}
+	for code, s := range l {
+		vf.Widgets[code] = s.build(img, t)
+	}
+
+	return vf
+}
+
+// layouts holds the layout factories per vendor.
+var layouts = map[ptp.VendorExtension]func() Layout{
+	ptp.VE_FujiPhotoFilmCoLtd: FujiXT1Layout,
+}
+
+// RegisterLayout sets the function returning the default Layout for the given vendor, replacing any existing one.
+func RegisterLayout(v ptp.VendorExtension, f func() Layout) {
+	layouts[v] = f
+}
+
+// LayoutForVendor returns a fresh copy of the default Layout for the given vendor or nil when there is none.
+func LayoutForVendor(v ptp.VendorExtension) Layout {
+	if f, ok := layouts[v]; ok {
+		return f()
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package viewfinder
+
+import (
+	"github.com/malc0mn/ptp-ip/ptp"
+	"image"
+	"testing"
+)
+
+func TestPlacement_Point(t *testing.T) {
+	b := image.Rect(0, 0, 640, 480)
+	check := []struct {
+		p    Placement
+		want image.Point
+	}{
+		{Placement{Anchor: TopLeft, X: 0.1, DY: 18}, image.Point{X: 64, Y: 18}},
+		{Placement{Anchor: TopCenter, X: 0.25}, image.Point{X: 480, Y: 0}},
+		{Placement{Anchor: TopRight, X: 0.15, DX: 19, DY: 18}, image.Point{X: 563, Y: 18}},
+		{Placement{Anchor: BottomLeft, Y: 0.5, DY: -10}, image.Point{X: 0, Y: 230}},
+		{Placement{Anchor: BottomCenter, DX: -57, DY: -10}, image.Point{X: 263, Y: 470}},
+		{Placement{Anchor: BottomRight, X: 0.1, DY: -8}, image.Point{X: 576, Y: 472}},
+	}
+
+	for _, c := range check {
+		if got := c.p.Point(b); got != c.want {
+			t.Errorf("Point() %+v got = %v; want %v", c.p, got, c.want)
+		}
+	}
+}
+
+func TestLayout_Build(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	l := LayoutForVendor(ptp.VE_FujiPhotoFilmCoLtd)
+	if l == nil {
+		t.Fatal("LayoutForVendor() got = nil; want layout")
+	}
+	delete(l, ptp.DPC_WhiteBalance)
+	l[ptp.DPC_BatteryLevel] = WidgetSpec{Place: Placement{Anchor: TopLeft, DX: 5, DY: 15}, Draw: drawFujiBattery3Bars}
+
+	vf := l.Build(img)
+	if _, ok := vf.Widgets[ptp.DPC_WhiteBalance]; ok {
+		t.Error("Build() white balance widget present; want it removed")
+	}
+	w := vf.Widgets[ptp.DPC_BatteryLevel]
+	if w.Dot.X.Round() != 5 || w.Dot.Y.Round() != 15 {
+		t.Errorf("Build() battery widget at %d,%d; want 5,15", w.Dot.X.Round(), w.Dot.Y.Round())
+	}
+
+	// Changing a layout must not affect the default one.
+	if _, ok := LayoutForVendor(ptp.VE_FujiPhotoFilmCoLtd)[ptp.DPC_WhiteBalance]; !ok {
+		t.Error("LayoutForVendor() white balance widget missing; want it present")
+	}
+	if LayoutForVendor(ptp.VE_EastmanKodakCompany) != nil {
+		t.Error("LayoutForVendor() got layout for vendor without viewfinder; want nil")
+	}
+}
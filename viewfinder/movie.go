@@ -0,0 +1,80 @@
+package viewfinder
+
+import (
+	"fmt"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/draw"
+)
+
+// The drawers in this file display the state of a movie recording. They are not bound to vendor specific values, so
+// any Layout can bind them to the device properties of a camera supporting movies.
+
+// audioLevelSegments is the number of segments of the audio level meter.
+const audioLevelSegments = 10
+
+// DrawRecordingState draws a red dot followed by REC while a movie is being recorded, that is when val is not 0.
+func DrawRecordingState(w *Widget, val int64) {
+	w.ResetToOrigin()
+	if val == 0 {
+		return
+	}
+
+	w.SetRGBA(w.Theme().Warning)
+	r := 3 * w.Theme().Scale
+	c := image.Point{X: w.Dot.X.Floor() + r, Y: w.Dot.Y.Floor() - w.Face.Metrics().Ascent.Floor()/2}
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				w.Dst.Set(c.X+x, c.Y+y, w.Src.At(0, 0))
+			}
+		}
+	}
+
+	w.Dot.X += fixed.I(2*r) + w.Px(3)
+	w.DrawString("REC")
+	w.ResetColour()
+}
+
+// DrawRecordingTime draws the number of seconds in val as a recording time, e.g. 1:05 or 1:02:05, such as the elapsed
+// or remaining recording time.
+func DrawRecordingTime(w *Widget, val int64) {
+	w.ResetToOrigin()
+
+	w.DrawString(formatRecordingTime(val))
+}
+
+// formatRecordingTime formats the given number of seconds as minutes and seconds, adding the hours when needed.
+func formatRecordingTime(sec int64) string {
+	if sec < 0 {
+		sec = 0
+	}
+	if sec >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", sec/60, sec%60)
+}
+
+// DrawAudioLevel draws a meter of the audio level in val, ranging from 0 to 100. The segments of the meter above 90 are
+// lit in the warning colour as the audio is about to clip.
+func DrawAudioLevel(w *Widget, val int64) {
+	w.ResetToOrigin()
+
+	t := w.Theme()
+	width, height, gap := 3*t.Scale, 8*t.Scale, t.Scale
+	x, y := w.Dot.X.Floor(), w.Dot.Y.Floor()
+	lit := int(val) * audioLevelSegments / 100
+	for i := 0; i < audioLevelSegments; i++ {
+		c := t.Inactive
+		switch {
+		case i < lit && i >= audioLevelSegments*9/10:
+			c = t.Warning
+		case i < lit:
+			c = t.Colour
+		}
+		r := image.Rect(x, y-height, x+width, y)
+		draw.Draw(w.Dst, r.Intersect(w.Dst.Bounds()), image.NewUniform(c), image.Point{}, draw.Src)
+		x += width + gap
+	}
+}
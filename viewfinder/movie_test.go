@@ -0,0 +1,62 @@
+package viewfinder
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFormatRecordingTime(t *testing.T) {
+	check := map[int64]string{
+		-1:   "0:00",
+		0:    "0:00",
+		65:   "1:05",
+		1679: "27:59",
+		3725: "1:02:05",
+	}
+	for sec, want := range check {
+		if got := formatRecordingTime(sec); got != want {
+			t.Errorf("formatRecordingTime(%d) got = %s; want %s", sec, got, want)
+		}
+	}
+}
+
+func TestDrawAudioLevel(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 60, 20))
+	w := WidgetSpec{Place: Placement{Anchor: TopLeft, DY: 10}, Draw: DrawAudioLevel}.Build(img)
+	th := DefaultTheme()
+
+	w.Draw(w, 100)
+	// The first segment is lit, the last one warns about clipping.
+	if got := img.RGBAAt(1, 5); got != th.Colour {
+		t.Errorf("DrawAudioLevel() first segment got = %v; want %v", got, th.Colour)
+	}
+	if got := img.RGBAAt(9*4+1, 5); got != th.Warning {
+		t.Errorf("DrawAudioLevel() last segment got = %v; want %v", got, th.Warning)
+	}
+
+	w.Draw(w, 50)
+	if got := img.RGBAAt(5*4+1, 5); got != th.Inactive {
+		t.Errorf("DrawAudioLevel() sixth segment got = %v; want %v", got, th.Inactive)
+	}
+	if got := img.RGBAAt(4*4+1, 5); got != th.Colour {
+		t.Errorf("DrawAudioLevel() fifth segment got = %v; want %v", got, th.Colour)
+	}
+}
+
+func TestDrawRecordingState(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 60, 20))
+	w := WidgetSpec{Place: Placement{Anchor: TopLeft, DY: 15}, Text: true, Draw: DrawRecordingState}.Build(img)
+
+	w.Draw(w, 0)
+	for i := range img.Pix {
+		if img.Pix[i] != 0 {
+			t.Fatalf("DrawRecordingState() drew while not recording")
+		}
+	}
+
+	w.Draw(w, 1)
+	// The center of the dot.
+	if got, want := img.RGBAAt(3, 15-5), DefaultTheme().Warning; got != want {
+		t.Errorf("DrawRecordingState() got = %v; want %v", got, want)
+	}
+}
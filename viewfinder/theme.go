@@ -0,0 +1,138 @@
+package viewfinder
+
+import (
+	"golang.org/x/image/font/basicfont"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Theme defines the colours and font faces the viewfinder widgets are drawn with.
+type Theme struct {
+	// Colour is the colour widgets are drawn in by default.
+	Colour color.RGBA
+	// Warning is used to draw attention to a problem, such as a low battery.
+	Warning color.RGBA
+	// Highlight is used to mark the current value on a scale, such as the exposure bias compensation marker.
+	Highlight color.RGBA
+	// Inactive is used for widgets showing a neutral or disabled state.
+	Inactive color.RGBA
+	// Text is the font face used for widgets displaying text and numbers.
+	Text *basicfont.Face
+	// Glyphs is the font face holding the icons used by the widgets.
+	Glyphs *basicfont.Face
+	// Scale enlarges the font faces and the widget offsets by the given integer factor, which keeps the overlay
+	// readable on high resolution preview windows. A value of 0 means 1.
+	Scale int
+}
+
+// DefaultTheme returns the theme mimicking the look of a real viewfinder: white text on the live view, red warnings and
+// yellow highlights.
+func DefaultTheme() Theme {
+	return Theme{
+		Colour:    color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		Warning:   color.RGBA{R: 255, A: 255},
+		Highlight: color.RGBA{R: 255, G: 185, B: 10, A: 255},
+		Inactive:  color.RGBA{R: 100, G: 100, B: 100, A: 255},
+		Text:      basicfont.Face7x13,
+		Glyphs:    VFGlyphs6x13,
+		Scale:     1,
+	}
+}
+
+// scaled returns a copy of the theme with all missing values set to those of the DefaultTheme and the font faces
+// enlarged by the scale factor.
+func (t Theme) scaled() Theme {
+	d := DefaultTheme()
+	for _, c := range []struct{ v, def *color.RGBA }{
+		{&t.Colour, &d.Colour}, {&t.Warning, &d.Warning}, {&t.Highlight, &d.Highlight}, {&t.Inactive, &d.Inactive},
+	} {
+		if *c.v == (color.RGBA{}) {
+			*c.v = *c.def
+		}
+	}
+	if t.Text == nil {
+		t.Text = d.Text
+	}
+	if t.Glyphs == nil {
+		t.Glyphs = d.Glyphs
+	}
+	if t.Scale < 1 {
+		t.Scale = 1
+	}
+
+	t.Text = ScaleFace(t.Text, t.Scale)
+	t.Glyphs = ScaleFace(t.Glyphs, t.Scale)
+
+	return t
+}
+
+// ScaleFace returns a copy of the font face enlarged by the given integer factor. Each pixel of the glyphs simply
+// becomes a square of factor by factor pixels, so the result looks just as crisp as the original. The face itself is
+// returned when the factor is 1 or less.
+func ScaleFace(f *basicfont.Face, factor int) *basicfont.Face {
+	if factor <= 1 {
+		return f
+	}
+
+	b := f.Mask.Bounds()
+	mask := image.NewAlpha(image.Rect(b.Min.X*factor, b.Min.Y*factor, b.Max.X*factor, b.Max.Y*factor))
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			_, _, _, a := f.Mask.At(x/factor, y/factor).RGBA()
+			mask.SetAlpha(x, y, color.Alpha{A: uint8(a >> 8)})
+		}
+	}
+
+	return &basicfont.Face{
+		Advance: f.Advance * factor,
+		Width:   f.Width * factor,
+		Height:  f.Height * factor,
+		Ascent:  f.Ascent * factor,
+		Descent: f.Descent * factor,
+		Left:    f.Left * factor,
+		Mask:    mask,
+		Ranges:  f.Ranges,
+	}
+}
+
+// faces holds the font faces that can be referred to by name, for example from a config file.
+var faces = map[string]*basicfont.Face{
+	"7x13":         basicfont.Face7x13,
+	"vfglyphs6x13": VFGlyphs6x13,
+}
+
+// RegisterFace makes the font face available under the given name, replacing any existing one. Use this to add a glyph
+// set drawn for a different vendor or a larger text face.
+func RegisterFace(name string, f *basicfont.Face) {
+	faces[name] = f
+}
+
+// FaceByName returns the font face registered under the given name.
+func FaceByName(name string) (*basicfont.Face, bool) {
+	f, ok := faces[name]
+
+	return f, ok
+}
+
+// FaceName returns the name the font face has been registered under or an empty string when it is not registered.
+func FaceName(f *basicfont.Face) string {
+	for n, face := range faces {
+		if face == f {
+			return n
+		}
+	}
+
+	return ""
+}
+
+// FaceNames returns the sorted names of all registered font faces.
+func FaceNames() []string {
+	names := make([]string, 0, len(faces))
+	for n := range faces {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
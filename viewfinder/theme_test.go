@@ -0,0 +1,68 @@
+package viewfinder
+
+import (
+	"github.com/malc0mn/ptp-ip/ptp"
+	"golang.org/x/image/font/basicfont"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestScaleFace(t *testing.T) {
+	f := ScaleFace(basicfont.Face7x13, 2)
+	if f.Width != 12 || f.Height != 26 || f.Advance != 14 || f.Ascent != 22 {
+		t.Errorf("ScaleFace() got = %dx%d advance %d ascent %d; want 12x26 advance 14 ascent 22", f.Width, f.Height, f.Advance, f.Ascent)
+	}
+
+	b := basicfont.Face7x13.Mask.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, want := basicfont.Face7x13.Mask.At(x, y).RGBA()
+			if _, _, _, got := f.Mask.At(x*2+1, y*2+1).RGBA(); got != want {
+				t.Fatalf("ScaleFace() alpha at %d,%d got = %d; want %d", x*2+1, y*2+1, got, want)
+			}
+		}
+	}
+
+	if ScaleFace(basicfont.Face7x13, 1) != basicfont.Face7x13 {
+		t.Error("ScaleFace() factor 1 got = copy; want same face")
+	}
+}
+
+func TestLayout_BuildWithTheme(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1280, 960))
+	red := color.RGBA{R: 255, A: 255}
+	vf := FujiXT1Layout().BuildWithTheme(img, Theme{Colour: red, Scale: 2})
+
+	w := vf.Widgets[ptp.DPC_BatteryLevel]
+	if w.Dot.Y.Round() != 944 {
+		t.Errorf("BuildWithTheme() battery widget Y got = %d; want 944", w.Dot.Y.Round())
+	}
+	if got := w.Src.(*image.Uniform).C; got != red {
+		t.Errorf("BuildWithTheme() colour got = %v; want %v", got, red)
+	}
+	if f := w.Face.(*basicfont.Face); f.Width != VFGlyphs6x13.Width*2 {
+		t.Errorf("BuildWithTheme() glyph width got = %d; want %d", f.Width, VFGlyphs6x13.Width*2)
+	}
+	if f := vf.Widgets[ptp.DPC_FNumber].Face.(*basicfont.Face); f.Width != basicfont.Face7x13.Width*2 {
+		t.Errorf("BuildWithTheme() text width got = %d; want %d", f.Width, basicfont.Face7x13.Width*2)
+	}
+	if w.Theme().Warning != DefaultTheme().Warning {
+		t.Errorf("BuildWithTheme() warning got = %v; want default %v", w.Theme().Warning, DefaultTheme().Warning)
+	}
+	if w.Px(3).Round() != 6 {
+		t.Errorf("Px() got = %d; want 6", w.Px(3).Round())
+	}
+}
+
+func TestFaceByName(t *testing.T) {
+	if f, ok := FaceByName("vfglyphs6x13"); !ok || f != VFGlyphs6x13 {
+		t.Errorf("FaceByName() got = %v, %v; want VFGlyphs6x13, true", f, ok)
+	}
+	if _, ok := FaceByName("does-not-exist"); ok {
+		t.Error("FaceByName() got = true; want false")
+	}
+	if n := FaceName(basicfont.Face7x13); n != "7x13" {
+		t.Errorf("FaceName() got = %s; want 7x13", n)
+	}
+}
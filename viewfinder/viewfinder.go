@@ -7,6 +7,7 @@ import (
 	"golang.org/x/image/math/fixed"
 	"image"
 	"image/color"
+	"image/draw"
 )
 
 // WidgetDrawer defines the signature of the drawer function of a widget.
@@ -29,9 +30,13 @@ func (vf *Viewfinder) DrawWidget(img *image.RGBA, code ptp.DevicePropCode, val i
 // starting position.
 // When the vendor has no viewfinder defined, nothing will happen.
 func NewViewfinder(img *image.RGBA, v ptp.VendorExtension) *Viewfinder {
-	switch v {
-	case ptp.VE_FujiPhotoFilmCoLtd:
-		return NewFujiXT1Viewfinder(img)
+	return NewThemedViewfinder(img, v, DefaultTheme())
+}
+
+// NewThemedViewfinder does the same as NewViewfinder but draws the widgets using the given theme.
+func NewThemedViewfinder(img *image.RGBA, v ptp.VendorExtension, t Theme) *Viewfinder {
+	if l := LayoutForVendor(v); l != nil {
+		return l.BuildWithTheme(img, t)
 	}
 
 	return nil
@@ -44,12 +49,31 @@ func DrawViewfinder(vf *Viewfinder, img *image.RGBA, s []*ptp.DevicePropDesc) {
 	}
 }
 
+// DrawOutline draws the outline of the rectangle r onto the image in the given colour, the lines being the given
+// number of pixels thick. Widgets use it to mark an area of the frame, such as the focus point.
+func DrawOutline(img draw.Image, r image.Rectangle, c color.RGBA, thickness int) {
+	if thickness < 1 {
+		thickness = 1
+	}
+
+	src := image.NewUniform(c)
+	for _, side := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thickness),
+		image.Rect(r.Min.X, r.Max.Y-thickness, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+thickness, r.Max.Y),
+		image.Rect(r.Max.X-thickness, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(img, side.Intersect(img.Bounds()), src, image.Point{}, draw.Src)
+	}
+}
+
 // Widget defines a viewfinder widget.
 type Widget struct {
 	*font.Drawer
 	origin fixed.Point26_6
 	face   font.Face
 	colour *image.Uniform
+	theme  Theme
 	Draw   WidgetDrawer
 }
 
@@ -58,6 +82,22 @@ func (w *Widget) SetColour(r, g, b uint8) {
 	w.Src = image.NewUniform(color.RGBA{R: r, G: g, B: b, A: 255})
 }
 
+// SetRGBA sets the font colour to the given colour, typically one of the colours of the Theme.
+func (w *Widget) SetRGBA(c color.RGBA) {
+	w.Src = image.NewUniform(c)
+}
+
+// Theme returns the theme the widget was made with. Its font faces have already been scaled.
+func (w *Widget) Theme() Theme {
+	return w.theme
+}
+
+// Px converts the given number of pixels to a distance to move the drawing position by, taking the scale of the theme
+// into account.
+func (w *Widget) Px(n int) fixed.Int26_6 {
+	return fixed.I(n * w.theme.Scale)
+}
+
 // ResetColour resets the colour to the original one when the widget was first made.
 func (w *Widget) ResetColour() {
 	w.Src = w.colour
@@ -89,6 +129,7 @@ func NewWidget(img *image.RGBA, r, g, b uint8, f *basicfont.Face, x, y int) *Wid
 		origin: point,
 		face:   f,
 		colour: col,
+		theme:  DefaultTheme(),
 	}
 }
 
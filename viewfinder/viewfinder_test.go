@@ -0,0 +1,28 @@
+package viewfinder
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawOutline(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	c := color.RGBA{R: 255, A: 255}
+	DrawOutline(img, image.Rect(2, 2, 8, 8), c, 1)
+
+	check := map[image.Point]bool{
+		{2, 2}: true,
+		{7, 7}: true,
+		{5, 2}: true,
+		{2, 5}: true,
+		{5, 5}: false,
+		{1, 1}: false,
+		{8, 8}: false,
+	}
+	for p, want := range check {
+		if got := img.RGBAAt(p.X, p.Y) == c; got != want {
+			t.Errorf("DrawOutline() pixel %v drawn = %t; want %t", p, got, want)
+		}
+	}
+}
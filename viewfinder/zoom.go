@@ -0,0 +1,44 @@
+package viewfinder
+
+import (
+	xdraw "golang.org/x/image/draw"
+	"image"
+)
+
+// PunchIn magnifies a region of the image by the given factor for critical focus checking. The region is centered on
+// the point at cx, cy, both expressed as a fraction of the image width and height so that 0.5, 0.5 is the center of
+// the image. The region is kept within the image bounds. A new image of the same size is returned, unless the factor
+// is 1 or less in which case the image itself is returned.
+func PunchIn(img *image.RGBA, factor, cx, cy float64) *image.RGBA {
+	if factor <= 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	w := int(float64(b.Dx()) / factor)
+	h := int(float64(b.Dy()) / factor)
+	if w < 1 || h < 1 {
+		return img
+	}
+
+	x := b.Min.X + int(float64(b.Dx())*cx) - w/2
+	y := b.Min.Y + int(float64(b.Dy())*cy) - h/2
+	x = clamp(x, b.Min.X, b.Max.X-w)
+	y = clamp(y, b.Min.Y, b.Max.Y-h)
+
+	dst := image.NewRGBA(b)
+	xdraw.ApproxBiLinear.Scale(dst, b, img, image.Rect(x, y, x+w, y+h), xdraw.Src, nil)
+
+	return dst
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+
+	return v
+}
@@ -0,0 +1,33 @@
+package viewfinder
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPunchIn(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	// Paint the bottom right quarter red.
+	for y := 50; y < 100; y++ {
+		for x := 50; x < 100; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	if got := PunchIn(img, 1, 0.5, 0.5); got != img {
+		t.Error("PunchIn() factor 1 returned a new image; want the original one")
+	}
+
+	// Zooming in on the bottom right corner with a factor of 2 must give a fully red image, even when the requested
+	// center would put the region partly outside of the image.
+	got := PunchIn(img, 2, 1, 1)
+	if got.Bounds() != img.Bounds() {
+		t.Errorf("PunchIn() bounds = %v; want %v", got.Bounds(), img.Bounds())
+	}
+	for _, p := range []image.Point{{0, 0}, {50, 50}, {99, 99}} {
+		if c := got.RGBAAt(p.X, p.Y); c.R != 255 {
+			t.Errorf("PunchIn() pixel %v = %v; want red", p, c)
+		}
+	}
+}